@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/backfill"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/notify"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+func main() {
+	mode := flag.String("mode", "api", `how ads are submitted: "api" (POST /v1/extract on -target) or "direct" (in this process)`)
+	target := flag.String("target", "http://localhost:8080", "base URL of the pipeline instance, with -mode api")
+	missing := flag.String("missing", "asr_results.json,vlm_results.json", "comma-separated result files; ads lacking any are selected")
+	staleBefore := flag.String("stale-before", "", "also select ads whose newest result predates this date")
+	since := flag.String("since", "", "only ads whose video was uploaded on or after this date")
+	until := flag.String("until", "", "only ads whose video was uploaded before this date")
+	all := flag.Bool("all", false, "select every ad in the date range, whatever its results")
+	concurrency := flag.Int("concurrency", 4, "ads processed at once")
+	rate := flag.Float64("rate", 0, "ads started per second (0 = unlimited)")
+	progressPath := flag.String("progress", ".backfill-progress.jsonl", `progress file for resuming ("" to disable)`)
+	priority := flag.String("priority", "batch", `job priority: "interactive" or "batch"`)
+	queuePriority := flag.Int("queue-priority", client.QueuePriorityBackfill, "order among jobs waiting for a worker, with -priority interactive (higher first)")
+	only := flag.String("streams", "", "comma-separated streams to run, leaving others' results as they are (default all)")
+	force := flag.Bool("force", true, "re-run streams whose results are already stored (false reuses them)")
+	timeout := flag.Duration("timeout", 0, "per-ad time limit (0 = the server's own)")
+	dryRun := flag.Bool("dry-run", false, "list the selected ads without submitting them")
+	flag.Parse()
+
+	f := backfill.Filter{All: *all}
+	if *missing != "" {
+		f.Missing = strings.Split(*missing, ",")
+	}
+	for _, d := range []struct {
+		name, value string
+		dst         *time.Time
+	}{
+		{"stale-before", *staleBefore, &f.StaleBefore},
+		{"since", *since, &f.Since},
+		{"until", *until, &f.Until},
+	} {
+		if d.value == "" {
+			continue
+		}
+		t, err := parseTime(d.value)
+		if err != nil {
+			log.Fatalf("-%s: %v", d.name, err)
+		}
+		*d.dst = t
+	}
+
+	cfg := config.Load()
+	app.RedactSecrets(cfg)
+	r2Client := app.NewR2Client(cfg)
+
+	// Ctrl-C stops starting new ads; in-flight ones finish and are recorded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ads, err := r2Client.ListAds(ctx)
+	if err != nil {
+		log.Fatalf("list ads: %v", err)
+	}
+	adIDs := backfill.Select(ads, f)
+	log.Printf("%d of %d ads selected", len(adIDs), len(ads))
+	if *dryRun {
+		for _, id := range adIDs {
+			fmt.Println(id)
+		}
+		return
+	}
+
+	base := client.ExtractRequest{Priority: *priority, QueuePriority: *queuePriority, Force: *force}
+	if *only != "" {
+		base.Streams = strings.Split(*only, ",")
+	}
+	var submit func(context.Context, string) error
+	flush := func(context.Context) error { return nil }
+	switch *mode {
+	case "api":
+		submit = apiSubmitter(*target, base, *timeout)
+	case "direct":
+		if err := app.ConfigureStreams(cfg); err != nil {
+			log.Fatalf("configure providers: %v", err)
+		}
+		h := handler.NewExtractHandler(cfg, r2Client, pool.New(*concurrency), admission.New(int64(cfg.MemoryBudgetMB)<<20))
+		flush = h.Flush
+		submit = func(ctx context.Context, adID string) error {
+			if *timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, *timeout)
+				defer cancel()
+			}
+			body := base
+			body.AdID = adID
+			resp, err := h.Extract(ctx, body)
+			if err != nil {
+				return err
+			}
+			log.Printf("%s done in %.0fms", adID, resp.ProcessingTimeMs)
+			return nil
+		}
+	default:
+		log.Fatal(`-mode must be "api" or "direct"`)
+	}
+
+	start := time.Now()
+	rep, err := backfill.Run(ctx, backfill.Options{
+		AdIDs:       adIDs,
+		Submit:      submit,
+		Concurrency: *concurrency,
+		Rate:        *rate,
+		Progress:    *progressPath,
+	})
+	if err != nil {
+		log.Fatalf("backfill: %v", err)
+	}
+	log.Printf("backfill: %d succeeded, %d failed, %d already done", rep.Succeeded, rep.Failed, rep.Skipped)
+
+	// ctx may already be cancelled by Ctrl-C; results are still flushed and
+	// the summary is still sent
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), time.Minute)
+	if err := flush(flushCtx); err != nil {
+		log.Printf("WARN: bigquery: %v", err)
+	}
+	cancelFlush()
+	notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	notify.New(cfg.NotifyWebhookURL, cfg.PublicURL, 0).BatchCompleted(notifyCtx, notify.Batch{
+		Name:        "Backfill",
+		Interrupted: ctx.Err() != nil,
+		Succeeded:   rep.Succeeded,
+		Failed:      rep.Failed,
+		Skipped:     rep.Skipped,
+		Errors:      rep.Errors,
+		Duration:    time.Since(start),
+	})
+	cancel()
+	if rep.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// apiSubmitter runs each ad through a running instance's /extract, as base
+// with the ad's ID, authenticating with PIPELINE_TOKEN if it is set.
+func apiSubmitter(target string, base client.ExtractRequest, timeout time.Duration) func(context.Context, string) error {
+	c := client.New(target, &http.Client{Timeout: timeout})
+	if token := os.Getenv("PIPELINE_TOKEN"); token != "" {
+		c = c.WithToken(client.StaticToken(token))
+	}
+	return func(ctx context.Context, adID string) error {
+		body := base
+		body.AdID = adID
+		_, err := c.Extract(ctx, body)
+		return err
+	}
+}
+
+// parseTime accepts a date or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/export"
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+)
+
+func main() {
+	out := flag.String("out", "dataset.jsonl", "output JSONL file (appended to)")
+	statePath := flag.String("state", ".export-state.json", "incremental export state file")
+	full := flag.Bool("full", false, "ignore the state file and export every processed ad")
+	flag.Parse()
+
+	cfg := config.Load()
+	app.RedactSecrets(cfg)
+	r2Client := r2.NewClient(
+		cfg.R2EndpointURL,
+		cfg.R2AccessKeyID,
+		cfg.R2SecretAccessKey,
+		cfg.R2Bucket,
+		httpclient.New(cfg.R2Transport),
+	)
+
+	st, err := export.LoadState(*statePath)
+	if err != nil {
+		log.Fatalf("load state: %v", err)
+	}
+	since := st.LastExport
+	if *full {
+		since = time.Time{}
+	}
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("open output: %v", err)
+	}
+	defer f.Close()
+
+	count, cursor, err := export.Run(context.Background(), r2Client, f, since)
+	if err != nil {
+		log.Fatalf("export: %v", err)
+	}
+
+	if err := export.SaveState(*statePath, export.State{LastExport: cursor}); err != nil {
+		log.Fatalf("save state: %v", err)
+	}
+	log.Printf("exported %d ads to %s (cursor %s)", count, *out, cursor.Format(time.RFC3339))
+}
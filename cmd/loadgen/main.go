@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/loadgen"
+)
+
+func main() {
+	target := flag.String("target", "http://localhost:8080", "base URL of the pipeline instance")
+	corpus := flag.String("corpus", "", "file with one ad ID per line")
+	ads := flag.String("ads", "", "comma-separated ad IDs (alternative to -corpus)")
+	rate := flag.Float64("rate", 1, "requests started per second")
+	duration := flag.Duration("duration", time.Minute, "how long to keep starting requests (0 = until -n)")
+	n := flag.Int("n", 0, "stop after this many requests (0 = until -duration)")
+	concurrency := flag.Int("concurrency", 16, "max requests in flight")
+	timeout := flag.Duration("timeout", 6*time.Minute, "per-request timeout")
+	flag.Parse()
+
+	var adIDs []string
+	switch {
+	case *corpus != "":
+		var err error
+		if adIDs, err = loadgen.LoadCorpus(*corpus); err != nil {
+			log.Fatal(err)
+		}
+	case *ads != "":
+		adIDs = strings.Split(*ads, ",")
+	default:
+		log.Fatal("-corpus or -ads is required")
+	}
+
+	// Ctrl-C stops starting new requests; in-flight ones are still measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("replaying %d ads against %s at %.2f req/s", len(adIDs), *target, *rate)
+	rep, err := loadgen.Run(ctx, loadgen.Options{
+		Target:      *target,
+		AdIDs:       adIDs,
+		Rate:        *rate,
+		Duration:    *duration,
+		Requests:    *n,
+		Concurrency: *concurrency,
+		Client:      &http.Client{Timeout: *timeout},
+	})
+	if err != nil {
+		log.Fatalf("loadgen: %v", err)
+	}
+	rep.Write(os.Stdout)
+}
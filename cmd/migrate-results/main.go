@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/backfill"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/notify"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+)
+
+func main() {
+	only := flag.String("streams", "", "comma-separated streams to check (default all)")
+	since := flag.String("since", "", "only ads whose video was uploaded on or after this date")
+	until := flag.String("until", "", "only ads whose video was uploaded before this date")
+	concurrency := flag.Int("concurrency", 2, "ads migrated at once")
+	rate := flag.Float64("rate", 0.5, "ads started per second (0 = unlimited)")
+	progressPath := flag.String("progress", ".migrate-progress.jsonl", `progress file for resuming ("" to disable)`)
+	timeout := flag.Duration("timeout", 0, "per-ad time limit (0 = none beyond each stream's)")
+	dryRun := flag.Bool("dry-run", false, "list the outdated results without re-running them")
+	flag.Parse()
+
+	f := backfill.Filter{All: true}
+	for _, d := range []struct {
+		name, value string
+		dst         *time.Time
+	}{
+		{"since", *since, &f.Since},
+		{"until", *until, &f.Until},
+	} {
+		if d.value == "" {
+			continue
+		}
+		t, err := parseTime(d.value)
+		if err != nil {
+			log.Fatalf("-%s: %v", d.name, err)
+		}
+		*d.dst = t
+	}
+	var among []string
+	if *only != "" {
+		among = strings.Split(*only, ",")
+	}
+
+	cfg := config.Load()
+	app.RedactSecrets(cfg)
+	r2Client := app.NewR2Client(cfg)
+
+	// Ctrl-C stops starting new ads; in-flight ones finish and are recorded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ads, err := r2Client.ListAds(ctx)
+	if err != nil {
+		log.Fatalf("list ads: %v", err)
+	}
+	// ads without any results have nothing to migrate
+	var withResults []r2.AdObjects
+	for _, ad := range ads {
+		if len(ad.Results) > 0 {
+			withResults = append(withResults, ad)
+		}
+	}
+	described := backfill.Select(withResults, f)
+	log.Printf("checking %d of %d ads", len(described), len(ads))
+
+	if !*dryRun {
+		if err := app.ConfigureStreams(cfg); err != nil {
+			log.Fatalf("configure providers: %v", err)
+		}
+	}
+	h := handler.NewExtractHandler(cfg, r2Client, pool.New(*concurrency), admission.New(int64(cfg.MemoryBudgetMB)<<20))
+
+	if *dryRun {
+		for _, id := range described {
+			m, err := h.Migrate(ctx, id, among, true)
+			if err != nil {
+				log.Printf("%s: %v", id, err)
+				continue
+			}
+			for _, stream := range m.Streams {
+				why := m.Outdated[stream]
+				if why == "" {
+					why = "derived from an outdated result"
+				}
+				fmt.Printf("%s\t%s\t%s\n", id, stream, why)
+			}
+		}
+		return
+	}
+
+	start := time.Now()
+	var migrated atomic.Int64
+	rep, err := backfill.Run(ctx, backfill.Options{
+		AdIDs: described,
+		Submit: func(ctx context.Context, adID string) error {
+			if *timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, *timeout)
+				defer cancel()
+			}
+			m, err := h.Migrate(ctx, adID, among, false)
+			if err != nil {
+				return err
+			}
+			if len(m.Streams) > 0 {
+				log.Printf("%s: re-ran %s", adID, strings.Join(m.Streams, ", "))
+				migrated.Add(1)
+			}
+			return nil
+		},
+		Concurrency: *concurrency,
+		Rate:        *rate,
+		Progress:    *progressPath,
+	})
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	log.Printf("migrate: %d checked, %d migrated, %d failed, %d already done", rep.Succeeded, migrated.Load(), rep.Failed, rep.Skipped)
+
+	notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	notify.New(cfg.NotifyWebhookURL, cfg.PublicURL, 0).BatchCompleted(notifyCtx, notify.Batch{
+		Name:        "Result migration",
+		Interrupted: ctx.Err() != nil,
+		Succeeded:   rep.Succeeded,
+		Failed:      rep.Failed,
+		Skipped:     rep.Skipped,
+		Errors:      rep.Errors,
+		Duration:    time.Since(start),
+	})
+	cancel()
+	if rep.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseTime accepts a date or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
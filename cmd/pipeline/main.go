@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/gcpauth"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/ratelimit"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+func main() {
+	videoPath := flag.String("video", "", "local video file for ASR (optional)")
+	keyframeDir := flag.String("keyframes", "", "directory of keyframe JPEGs, with or without metadata.json (optional)")
+	interval := flag.Duration("frame-interval", time.Second, "time between keyframes when the directory has no metadata.json")
+	outDir := flag.String("out", "out", "directory the result JSON files are written to")
+	post := flag.Bool("post", true, "also build the timeline and run key moments and summaries")
+	timeout := flag.Duration("timeout", 10*time.Minute, "overall time limit")
+
+	cfg := config.Load()
+	app.RedactSecrets(cfg)
+	maxFrames := flag.Int("max-frames", cfg.VLMMaxFrames, "cap on keyframes described (0 = all)")
+	batchSize := flag.Int("batch-size", cfg.VLMBatchSize, "keyframes per Gemini request")
+	selection := flag.String("selection", cfg.VLMFrameSelection, `frame selection over the cap: "entropy" or "even"`)
+	vlmContext := flag.String("context", cfg.VLMContext, `prompt continuity: "previous_frame" or "rolling_summary"`)
+	multilingual := flag.Bool("multilingual", cfg.VLMMultilingual, "transcribe and translate on-screen text in the language ASR detects")
+	tokenBudget := flag.Int("token-budget", cfg.JobTokenBudget, "Gemini tokens the run may use before skipping frames (0 = no ceiling)")
+	flag.Parse()
+
+	if *videoPath == "" && *keyframeDir == "" {
+		log.Fatal("-video or -keyframes is required")
+	}
+
+	if err := app.ConfigureDeepgram(cfg); err != nil {
+		log.Fatal(err)
+	}
+	streams.SetTransportOptions(cfg.DeepgramTransport, cfg.GeminiTransport)
+	streams.SetGeminiFileThreshold(cfg.GeminiFileThresholdKB << 10)
+	streams.SetGeminiHedge(cfg.GeminiHedgeAfter)
+	streams.SetGeminiCache(cfg.GeminiCacheTTL, cfg.GeminiCacheMinTokens)
+	if cfg.VertexProject != "" {
+		streams.SetGeminiVertex(cfg.VertexProject, cfg.VertexLocation, gcpauth.NewTokenSource(cfg.GoogleCredentialsFile, streams.VertexScope).Token)
+	}
+	streams.SetRetryPolicies(
+		retry.Policy{MaxAttempts: cfg.DeepgramRetryAttempts, BaseDelay: cfg.DeepgramRetryDelay, MaxDelay: retry.Default.MaxDelay},
+		retry.Policy{MaxAttempts: cfg.GeminiRetryAttempts, BaseDelay: cfg.GeminiRetryDelay, MaxDelay: retry.Default.MaxDelay},
+	)
+	// A local run only competes with itself, so Redis is not needed
+	streams.SetGeminiLimiter(ratelimit.New("gemini", cfg.GeminiRPM, cfg.GeminiTPM, ""))
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("create output dir: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	budget := streams.NewTokenBudget(int64(*tokenBudget))
+	ctx = streams.WithTokenBudget(ctx, budget)
+
+	var (
+		asrResult *streams.ASRResult
+		vlmResult *streams.VLMResult
+	)
+
+	if *videoPath != "" {
+		if !cfg.DeepgramConfigured() {
+			log.Fatal("DEEPGRAM_API_KEY is required with -video")
+		}
+		asrResult = runASR(ctx, cfg, *videoPath)
+		write(*outDir, "asr_results.json", asrResult)
+	}
+
+	if *keyframeDir != "" {
+		if !cfg.GeminiConfigured() {
+			log.Fatal("GEMINI_API_KEY or VERTEX_PROJECT is required with -keyframes")
+		}
+		keyframes, err := loadKeyframes(*keyframeDir, *interval)
+		if err != nil {
+			log.Fatalf("load keyframes: %v", err)
+		}
+		log.Printf("describing %d keyframes from %s", len(keyframes), *keyframeDir)
+
+		opts := streams.VLMOptions{
+			BatchSize:    *batchSize,
+			MaxFrames:    *maxFrames,
+			Selection:    *selection,
+			FrameTimeout: cfg.GeminiFrameTimeout,
+			Context:      *vlmContext,
+			SummaryEvery: cfg.VLMSummaryEvery,
+		}
+		if *multilingual && asrResult != nil {
+			opts.Language = asrResult.Language
+		}
+		t0 := time.Now()
+		vlmResult, err = streams.RunVLM(ctx, keyframes, cfg.GeminiAPIKey, opts)
+		if err != nil {
+			log.Fatalf("VLM: %v", err)
+		}
+		log.Printf("VLM: %d frames in %s", len(vlmResult.Frames), time.Since(t0).Round(time.Millisecond))
+		write(*outDir, "vlm_results.json", vlmResult)
+	}
+
+	if *post {
+		timeline := streams.BuildTimeline(asrResult, vlmResult)
+		write(*outDir, "timeline.json", timeline)
+		if cfg.GeminiConfigured() {
+			if moments, err := streams.RunKeyMoments(ctx, timeline, cfg.GeminiAPIKey); err != nil {
+				log.Printf("key moments: %v", err)
+			} else {
+				write(*outDir, "key_moments.json", moments)
+			}
+			if summary, err := streams.RunSummaries(ctx, timeline, cfg.GeminiAPIKey); err != nil {
+				log.Printf("summary: %v", err)
+			} else {
+				write(*outDir, "summary.json", summary)
+			}
+		}
+	}
+
+	quality := streams.ScoreQuality(asrResult, vlmResult, cfg.QualityFlagThreshold)
+	log.Printf("quality score %.2f (flagged=%v) %v", quality.Score, quality.Flagged, quality.Reasons)
+	log.Printf("gemini tokens used: %d", budget.Used())
+}
+
+func runASR(ctx context.Context, cfg *config.Config, path string) *streams.ASRResult {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open video: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Fatalf("stat video: %v", err)
+	}
+
+	t0 := time.Now()
+	result, err := streams.RunASR(ctx, f, info.Size(), cfg.DeepgramAPIKey, streams.ASROptions{Params: cfg.DeepgramParams})
+	if err != nil {
+		log.Fatalf("ASR: %v", err)
+	}
+	log.Printf("ASR: %d segments in %s", len(result.Segments), time.Since(t0).Round(time.Millisecond))
+	return result
+}
+
+// loadKeyframes reads dir/metadata.json, as written by entropy-frames-selector,
+// resolving each r2_key to the file of the same name in dir. Without
+// metadata every *.jpg is used in name order, interval apart.
+func loadKeyframes(dir string, interval time.Duration) ([]streams.KeyframeInput, error) {
+	var metas []r2.KeyframeMeta
+	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	switch {
+	case err == nil:
+		var meta r2.KeyframeMetadataFile
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("decode metadata.json: %w", err)
+		}
+		metas = meta.Keyframes
+	case os.IsNotExist(err):
+		paths, err := filepath.Glob(filepath.Join(dir, "*.jpg"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for i, p := range paths {
+			metas = append(metas, r2.KeyframeMeta{
+				Index:        i,
+				TimestampSec: float64(i) * interval.Seconds(),
+				R2Key:        p,
+			})
+		}
+	default:
+		return nil, err
+	}
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("no keyframes in %s", dir)
+	}
+
+	keyframes := make([]streams.KeyframeInput, 0, len(metas))
+	for _, m := range metas {
+		path := filepath.Join(dir, filepath.Base(m.R2Key))
+		keyframes = append(keyframes, streams.KeyframeInput{
+			FrameIndex:   m.Index,
+			TimestampSec: m.TimestampSec,
+			EntropyScore: m.EntropyScore,
+			Fetch: func(context.Context) ([]byte, error) {
+				return os.ReadFile(path)
+			},
+		})
+	}
+	return keyframes, nil
+}
+
+func write(dir, name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("encode %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatalf("write %s: %v", path, err)
+	}
+	log.Printf("wrote %s", path)
+}
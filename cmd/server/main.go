@@ -1,48 +1,286 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"sync"
+	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
 	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/durable"
+	"github.com/nikipaj1/video-description-pipeline/internal/events"
+	"github.com/nikipaj1/video-description-pipeline/internal/grpcapi"
 	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/natsjs"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
 	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
 )
 
 func main() {
 	cfg := config.Load()
+	app.RedactSecrets(cfg)
+
+	r2Client := app.NewR2Client(cfg)
+	if err := app.ConfigureStreams(cfg); err != nil {
+		log.Fatalf("configure providers: %v", err)
+	}
 
-	r2Client := r2.NewClient(
-		cfg.R2EndpointURL,
-		cfg.R2AccessKeyID,
-		cfg.R2SecretAccessKey,
-		cfg.R2Bucket,
-	)
+	if cfg.WarmUp {
+		go warmUp(cfg, r2Client)
+	}
 
+	// API endpoints are registered with handler.HandleVersioned, under
+	// /v1 and at their old unversioned paths
 	mux := http.NewServeMux()
 
-	// Health endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
-			"status": "ok",
-			"streams": map[string]bool{
-				"deepgram": cfg.DeepgramAPIKey != "",
-				"vlm":      cfg.GeminiAPIKey != "",
+	// API keys or OIDC tokens, and client certificates if configured, for
+	// the endpoints that start work, change capacity or hand out results;
+	// probes, metrics and the UI stay open. Each endpoint needs a scope,
+	// which only managed keys can lack.
+	keyring := app.NewKeyring(cfg, r2Client)
+	authn := auth.New(auth.Options{
+		APIKeys:  cfg.APIKeys,
+		Keys:     keyring,
+		Issuer:   cfg.OIDCIssuer,
+		Audience: cfg.OIDCAudience,
+		JWKSURL:  cfg.OIDCJWKSURL,
+	})
+	protect := func(scope string, h http.Handler) http.Handler {
+		h = authn.Require(scope, h)
+		if cfg.TLSClientCAFile != "" {
+			h = auth.RequireClientCert(h)
+		}
+		return h
+	}
+
+	// Client address allowlists, checked before any credential
+	proxies := mustPrefixes(cfg.TrustedProxies)
+	extractIPs := auth.NewIPAllowlist(mustPrefixes(cfg.ExtractAllowedIPs), proxies)
+	adminIPs := auth.NewIPAllowlist(mustPrefixes(cfg.AdminAllowedIPs), proxies)
+
+	// Health endpoint, kept for existing callers; probes should use /livez
+	// and /readyz. With ?detail=providers it adds each provider's recent
+	// latency, error rate, breaker state and rate-limit headroom.
+	handler.HandleVersioned(mux, "GET /health", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := handler.Health{
+			Status: "ok",
+			Streams: map[string]bool{
+				"deepgram": cfg.DeepgramConfigured(),
+				"vlm":      cfg.GeminiConfigured(),
 			},
+		}
+		if req.URL.Query().Get("detail") == "providers" {
+			body.Providers = streams.ProviderStatuses()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}))
+
+	// Extract endpoint, limited to cfg.Workers concurrent jobs and the
+	// memory budget
+	workers := pool.New(cfg.Workers)
+	memory := admission.New(int64(cfg.MemoryBudgetMB) << 20)
+	extract := handler.NewExtractHandler(cfg, r2Client, workers, memory)
+	handler.HandleVersioned(mux, "POST /extract", extractIPs.Middleware(protect(auth.ScopeExtract, extract)))
+
+	// In temporal mode jobs run as workflows on workers started with
+	// -source temporal; this process only starts them and waits
+	if cfg.ExecutionMode == "temporal" {
+		tc, err := durable.Dial(cfg.TemporalAddress, cfg.TemporalNamespace)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer tc.Close()
+		extract.RunWith(func(ctx context.Context, body handler.ExtractRequest) (*handler.ExtractResponse, error) {
+			return durable.Run(ctx, tc, cfg.TemporalTaskQueue, body)
 		})
-	})
+	}
+
+	// Per-stream completion events for the event mesh
+	if cfg.NATSURL != "" && cfg.NATSEventsPrefix != "" {
+		nc, js, err := natsjs.Dial(cfg.NATSURL)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer nc.Drain()
+		extract.OnStreamDone(natsjs.NewEvents(js, cfg.NATSEventsPrefix).StreamDone)
+	}
+
+	// Async jobs (POST /extract?async=true) and those another instance
+	// left unfinished
+	handler.HandleVersioned(mux, "GET /jobs/{id}", protect(auth.ScopeRead, http.HandlerFunc(extract.ServeJob)))
+	handler.HandleVersioned(mux, "DELETE /jobs/{id}", extractIPs.Middleware(protect(auth.ScopeExtract, http.HandlerFunc(extract.ServeCancelJob))))
+	go extract.ResumeJobs(context.Background())
+
+	// Storage event notifications start jobs for newly uploaded ads. Videos
+	// wait for their keyframes unless this service can extract its own.
+	if cfg.StorageEvents {
+		var keyframeWait time.Duration
+		if cfg.KeyframeFallback != "" {
+			keyframeWait = cfg.EventKeyframeWait
+		}
+		trigger := events.NewTrigger(func(ctx context.Context, adID string) error {
+			_, err := extract.Extract(ctx, handler.ExtractRequest{AdID: adID})
+			return err
+		}, r2Client.Exists, keyframeWait)
+		handler.HandleVersioned(mux, "POST /events/storage", extractIPs.Middleware(handler.NewStorageEventsHandler(cfg.EventsToken, trigger)))
+	}
+
+	// Stored results, for services without R2 credentials
+	results := handler.NewResultsHandler(r2Client)
+	handler.HandleVersioned(mux, "GET /results/{ad_id}", protect(auth.ScopeRead, results))
+	handler.HandleVersioned(mux, "GET /results/{ad_id}/asr", protect(auth.ScopeRead, results.Artifact("asr_results.json")))
+	handler.HandleVersioned(mux, "GET /results/{ad_id}/vlm", protect(auth.ScopeRead, results.Artifact("vlm_results.json")))
+	handler.HandleVersioned(mux, "DELETE /results/{ad_id}", adminIPs.Middleware(protect(auth.ScopeAdmin, http.HandlerFunc(results.Purge))))
+
+	// Which ads have results, for reporting without R2 access
+	handler.HandleVersioned(mux, "GET /ads", protect(auth.ScopeRead, http.HandlerFunc(extract.ServeAds)))
+
+	// Expiring links to stored artifacts, e.g. for external reviewers
+	handler.HandleVersioned(mux, "GET /results/{ad_id}/download", protect(auth.ScopeRead, handler.NewDownloadHandler(cfg, r2Client)))
 
-	// Extract endpoint
-	mux.Handle("POST /extract", handler.NewExtractHandler(cfg, r2Client))
+	// Gemini-written comparison of two extracted ads
+	handler.HandleVersioned(mux, "POST /compare", extractIPs.Middleware(protect(auth.ScopeExtract, handler.NewCompareHandler(cfg, r2Client))))
+
+	// Built-in web UI
+	mux.Handle("GET /ui/", handler.UIAssets())
+	handler.HandleVersioned(mux, "GET /ui/api/ads/{ad_id}", protect(auth.ScopeRead, handler.NewAdViewHandler(cfg, r2Client)))
+	mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+
+	// API description, for generating clients
+	handler.HandleVersioned(mux, "GET /openapi.json", handler.OpenAPI())
+
+	mux.HandleFunc("GET /livez", handler.Livez)
+	mux.Handle("GET /readyz", handler.NewReadinessHandler(cfg, workers, r2Client.Ping))
+
+	// Autoscaling hooks
+	mux.Handle("GET /metrics", handler.NewMetricsHandler(workers, memory))
+	scale := handler.NewScaleHandler(workers)
+	handler.HandleVersioned(mux, "GET /scale", adminIPs.Middleware(scale))
+	handler.HandleVersioned(mux, "PUT /scale", adminIPs.Middleware(protect(auth.ScopeAdmin, scale)))
+
+	// API key management
+	if keyring != nil {
+		keys := adminIPs.Middleware(protect(auth.ScopeAdmin, handler.NewKeysHandler(keyring)))
+		handler.HandleVersioned(mux, "GET /admin/keys", keys)
+		handler.HandleVersioned(mux, "POST /admin/keys", keys)
+		handler.HandleVersioned(mux, "DELETE /admin/keys/{id}", keys)
+	}
+
+	// gRPC API for internal services, answered by the same handlers with
+	// the same credentials and allowlist
+	if cfg.GRPCPort != "" {
+		go serveGRPC(cfg, extract, results, grpcapi.Options{
+			Auth:              authn,
+			RequireClientCert: cfg.TLSClientCAFile != "",
+			ExtractIPs:        extractIPs,
+		})
+	}
 
 	addr := ":" + cfg.Port
 	log.Printf("video-description-pipeline listening on %s", addr)
-	log.Printf("  deepgram: configured=%v", cfg.DeepgramAPIKey != "")
-	log.Printf("  gemini:   configured=%v", cfg.GeminiAPIKey != "")
+	log.Printf("  deepgram: configured=%v self-hosted=%v", cfg.DeepgramConfigured(), cfg.DeepgramURL != "")
+	log.Printf("  gemini:   configured=%v vertex=%v", cfg.GeminiConfigured(), cfg.VertexProject != "")
+	log.Printf("  workers: %d, memory budget: %d MiB", cfg.Workers, cfg.MemoryBudgetMB)
+	log.Printf("  execution: %s", cfg.ExecutionMode)
+	log.Printf("  auth: api keys=%d managed=%v oidc=%v mtls=%v", len(cfg.APIKeys), cfg.ManagedAPIKeys, cfg.OIDCIssuer != "", cfg.TLSClientCAFile != "")
+	log.Printf("  gemini rate limit: rpm=%d tpm=%d shared=%v", cfg.GeminiRPM, cfg.GeminiTPM, cfg.RedisURL != "")
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	// Large bodies (timelines, inline results) are gzipped for clients
+	// that accept it. Request bodies are capped before any handler reads
+	// them, and browser preflights answered before authentication.
+	// Unrouted requests get JSON errors like the rest.
+	// No route takes a video directly yet; one that does goes in Uploads.
+	var h http.Handler = handler.LimitBody(mux, handler.BodyLimits{
+		JSON:   int64(cfg.MaxJSONBodyKB) << 10,
+		Upload: int64(cfg.MaxUploadMB) << 20,
+	}, apierr.Mux(mux))
+	h = handler.CORS(handler.CORSOptions{
+		Origins: cfg.CORSAllowedOrigins,
+		Methods: cfg.CORSAllowedMethods,
+		Headers: cfg.CORSAllowedHeaders,
+		MaxAge:  cfg.CORSMaxAge,
+	}, h)
+	srv := &http.Server{Addr: addr, Handler: handler.Gzip(h)}
+	var err error
+	if cfg.TLSCertFile != "" {
+		if srv.TLSConfig, err = auth.ServerTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil {
 		log.Fatalf("server error: %v", err)
 	}
 }
+
+// serveGRPC serves the gRPC API on GRPC_PORT, over TLS when the HTTP API
+// uses it.
+func serveGRPC(cfg *config.Config, extract *handler.ExtractHandler, results *handler.ResultsHandler, opts grpcapi.Options) {
+	var serverOpts []grpc.ServerOption
+	if cfg.TLSCertFile != "" {
+		tlsConfig, err := auth.ServerTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatalf("grpc: %v", err)
+	}
+	log.Printf("gRPC API listening on %s", lis.Addr())
+	if err := grpcapi.NewServer(extract, results, opts, serverOpts...).Serve(lis); err != nil {
+		log.Fatalf("grpc server error: %v", err)
+	}
+}
+
+// mustPrefixes parses an allowlist, exiting on an invalid entry rather
+// than serving with a list that is not the one configured.
+func mustPrefixes(list []string) []netip.Prefix {
+	prefixes, err := auth.ParsePrefixes(list)
+	if err != nil {
+		log.Fatalf("ip allowlist: %v", err)
+	}
+	return prefixes
+}
+
+// warmUp opens connections to every provider while the server starts
+// listening, so the first extraction does not absorb cold-start latency.
+// Failures are logged, not fatal: a provider may recover before jobs arrive.
+func warmUp(cfg *config.Config, r2Client *r2.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	t0 := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := streams.WarmUp(ctx, cfg.DeepgramAPIKey, cfg.GeminiAPIKey, cfg.WarmUpVerify); err != nil {
+			log.Printf("WARN: provider warm-up: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := r2Client.Ping(ctx); err != nil {
+			log.Printf("WARN: r2 warm-up: %v", err)
+		}
+	}()
+	wg.Wait()
+	log.Printf("warm-up finished in %s", time.Since(t0).Round(time.Millisecond))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/cfqueue"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/durable"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/natsjs"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/worker"
+)
+
+func main() {
+	source := flag.String("source", "lines", `where jobs come from: "lines", "cfqueue" (Cloudflare Queues), "nats" (JetStream) or "temporal" (workflows)`)
+	jobs := flag.String("jobs", "-", `JSON lines of /extract request bodies ("-" = stdin), with -source lines`)
+	flag.Parse()
+
+	cfg := config.Load()
+	app.RedactSecrets(cfg)
+	r2Client := app.NewR2Client(cfg)
+	if err := app.ConfigureStreams(cfg); err != nil {
+		log.Fatalf("configure providers: %v", err)
+	}
+
+	// SIGTERM stops taking jobs; those running are finished first
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var js jetstream.JetStream
+	var events *natsjs.Events
+	if cfg.NATSURL != "" {
+		nc, conn, err := natsjs.Dial(cfg.NATSURL)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer nc.Drain()
+		js, events = conn, natsjs.NewEvents(conn, cfg.NATSEventsPrefix)
+	}
+
+	var src worker.Source
+	from := *jobs
+	switch *source {
+	case "lines":
+		var in io.Reader = os.Stdin
+		if *jobs != "-" {
+			f, err := os.Open(*jobs)
+			if err != nil {
+				log.Fatalf("open jobs: %v", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		src = worker.NewLineSource(in)
+	case "cfqueue":
+		if cfg.CFAccountID == "" || cfg.CFAPIToken == "" || cfg.CFQueueID == "" {
+			log.Fatalf("-source cfqueue needs CF_ACCOUNT_ID, CF_API_TOKEN and CF_QUEUE_ID")
+		}
+		src = cfqueue.NewSource(cfqueue.New(cfg.CFAccountID, cfg.CFAPIToken, nil), cfqueue.SourceOptions{
+			Queue:        cfg.CFQueueID,
+			ResultsQueue: cfg.CFResultsQueueID,
+			BatchSize:    cfg.CFQueueBatchSize,
+			Visibility:   cfg.CFQueueVisibility,
+			PollInterval: cfg.CFQueuePollInterval,
+			RetryDelay:   cfg.CFQueueRetryDelay,
+		})
+		from = "queue " + cfg.CFQueueID
+	case "nats":
+		if js == nil {
+			log.Fatalf("-source nats needs NATS_URL")
+		}
+		natsSrc, err := natsjs.NewSource(ctx, js, natsjs.SourceOptions{
+			Stream:     cfg.NATSStream,
+			Consumer:   cfg.NATSConsumer,
+			Subject:    cfg.NATSSubject,
+			AckWait:    cfg.NATSAckWait,
+			MaxDeliver: cfg.NATSMaxDeliver,
+			RetryDelay: cfg.NATSRetryDelay,
+		}, events)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		src = natsSrc
+		from = "stream " + cfg.NATSStream
+	case "temporal":
+		// workflows are run by durable.Serve below
+		from = "task queue " + cfg.TemporalTaskQueue
+	default:
+		log.Fatalf(`-source %q is not "lines", "cfqueue", "nats" or "temporal"`, *source)
+	}
+
+	workers := pool.New(cfg.Workers)
+	memory := admission.New(int64(cfg.MemoryBudgetMB) << 20)
+	extract := handler.NewExtractHandler(cfg, r2Client, workers, memory)
+	if events != nil {
+		extract.OnStreamDone(events.StreamDone)
+	}
+
+	// Probes and metrics only: a worker takes jobs from its source, never
+	// over HTTP
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"status": "ok", "role": "worker"})
+	})
+	mux.Handle("GET /metrics", handler.NewMetricsHandler(workers, memory))
+	mux.HandleFunc("GET /livez", handler.Livez)
+	mux.Handle("GET /readyz", handler.NewReadinessHandler(cfg, workers, r2Client.Ping))
+	addr := ":" + cfg.Port
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	log.Printf("video-description-pipeline worker on %s, health on %s", from, addr)
+	log.Printf("  workers: %d, memory budget: %d MiB", cfg.Workers, cfg.MemoryBudgetMB)
+	if *source == "temporal" {
+		tc, err := durable.Dial(cfg.TemporalAddress, cfg.TemporalNamespace)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer tc.Close()
+		err = durable.Serve(ctx, tc, extract, durable.Options{
+			TaskQueue:   cfg.TemporalTaskQueue,
+			Concurrency: cfg.Workers,
+			Attempts:    cfg.TemporalStepAttempts,
+		})
+		if err != nil {
+			log.Fatalf("worker: %v", err)
+		}
+	} else if err := worker.Run(ctx, src, extract, cfg.Workers); err != nil {
+		log.Fatalf("worker: %v", err)
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if err := extract.Flush(flushCtx); err != nil {
+		log.Printf("WARN: bigquery: %v", err)
+	}
+	cancel()
+	log.Printf("worker stopped")
+}
@@ -0,0 +1,90 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrOverBudget is returned when a job's projected memory cannot be admitted.
+var ErrOverBudget = errors.New("memory budget exceeded")
+
+// Budget tracks the memory committed to in-flight jobs. Jobs reserve their
+// projected footprint before starting and wait while the budget is full, so
+// the process sheds load instead of being OOM-killed. A nil Budget admits
+// everything.
+type Budget struct {
+	mu      sync.Mutex
+	limit   int64
+	used    int64
+	changed chan struct{} // closed and replaced whenever memory is released
+}
+
+// New returns a budget of limit bytes, or nil if limit <= 0.
+func New(limit int64) *Budget {
+	if limit <= 0 {
+		return nil
+	}
+	return &Budget{limit: limit, changed: make(chan struct{})}
+}
+
+// Reserve commits n bytes, waiting for other jobs to release memory if
+// needed. It fails immediately if n alone exceeds the budget, and when ctx
+// ends before enough memory is free. The returned func gives the memory back.
+func (b *Budget) Reserve(ctx context.Context, n int64) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	if n > b.limit {
+		return nil, fmt.Errorf("%w: job needs %d MiB, budget is %d MiB", ErrOverBudget, n>>20, b.limit>>20)
+	}
+
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return b.releaseFunc(n), nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %d of %d MiB in use", ErrOverBudget, b.Used()>>20, b.limit>>20)
+		}
+	}
+}
+
+func (b *Budget) releaseFunc(n int64) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.used -= n
+			close(b.changed)
+			b.changed = make(chan struct{})
+		})
+	}
+}
+
+// Used returns the bytes currently committed.
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Limit returns the budget size in bytes (0 for a nil Budget).
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
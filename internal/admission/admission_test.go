@@ -0,0 +1,73 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudget_WaitsForRelease(t *testing.T) {
+	b := New(100)
+
+	release, err := b.Reserve(context.Background(), 70)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	admitted := make(chan struct{})
+	go func() {
+		r, err := b.Reserve(context.Background(), 50)
+		if err != nil {
+			t.Errorf("second Reserve: %v", err)
+		}
+		close(admitted)
+		r()
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatal("second job admitted over budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	release() // idempotent
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatal("second job not admitted after release")
+	}
+	if used := b.Used(); used != 0 {
+		t.Errorf("used = %d, want 0", used)
+	}
+}
+
+func TestBudget_Rejects(t *testing.T) {
+	b := New(100 << 20)
+
+	if _, err := b.Reserve(context.Background(), 200<<20); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("oversized job err = %v", err)
+	}
+
+	release, _ := b.Reserve(context.Background(), 80<<20)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := b.Reserve(ctx, 30<<20)
+	if !errors.Is(err, ErrOverBudget) || err.Error() != "memory budget exceeded: 80 of 100 MiB in use" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestBudget_Nil(t *testing.T) {
+	var b *Budget
+	release, err := b.Reserve(context.Background(), 1<<40)
+	if err != nil {
+		t.Fatalf("nil budget should admit: %v", err)
+	}
+	release()
+	if New(0) != nil {
+		t.Error("New(0) should disable the budget")
+	}
+}
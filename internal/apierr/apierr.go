@@ -0,0 +1,85 @@
+package apierr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Write answers with status and a client.Error of message and the status's
+// code, as http.Error answers with plain text.
+func Write(w http.ResponseWriter, message string, status int) {
+	WriteDetails(w, message, status, nil)
+}
+
+// WriteDetails is Write with details in the body.
+func WriteDetails(w http.ResponseWriter, message string, status int, details map[string]any) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(client.Error{Code: Code(status), Message: message, Details: details})
+}
+
+// Mux serves mux, answering requests it has no route for with error bodies
+// too: 404, or 405 with the Allow header for paths routed for other
+// methods.
+func Mux(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, pattern := mux.Handler(req); pattern != "" {
+			mux.ServeHTTP(w, req)
+			return
+		}
+		// Let mux choose the status, and the methods to allow
+		rec := &unrouted{header: http.Header{}}
+		mux.ServeHTTP(rec, req)
+		if allow := rec.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		Write(w, strings.ToLower(http.StatusText(rec.status)), rec.status)
+	})
+}
+
+// unrouted keeps the status and headers of mux's own answer.
+type unrouted struct {
+	header http.Header
+	status int
+}
+
+func (u *unrouted) Header() http.Header         { return u.header }
+func (u *unrouted) Write(b []byte) (int, error) { return len(b), nil }
+func (u *unrouted) WriteHeader(status int)      { u.status = status }
+
+// Code is the error code sent with an HTTP status.
+func Code(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return client.CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return client.CodeUnauthenticated
+	case http.StatusForbidden:
+		return client.CodeForbidden
+	case http.StatusNotFound:
+		return client.CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return client.CodeMethodNotAllowed
+	case http.StatusConflict:
+		return client.CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return client.CodeTooLarge
+	case http.StatusUnprocessableEntity:
+		return client.CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return client.CodeRateLimited
+	case http.StatusBadGateway:
+		return client.CodeUpstream
+	case http.StatusServiceUnavailable:
+		return client.CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return client.CodeTimeout
+	}
+	return client.CodeInternal
+}
@@ -0,0 +1,61 @@
+package apierr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+func TestWriteDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteDetails(rec, "forbidden: the key lacks the \"admin\" scope", http.StatusForbidden, map[string]any{"scope": "admin"})
+
+	var body client.Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("%d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body.Code != client.CodeForbidden || body.Message == "" || body.Details["scope"] != "admin" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestMux(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things", func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) })
+	h := Mux(mux)
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/things", http.StatusOK, ""},
+		{http.MethodGet, "/nothing", http.StatusNotFound, client.CodeNotFound},
+		{http.MethodPost, "/things", http.StatusMethodNotAllowed, client.CodeMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+			continue
+		}
+		if tt.code == "" {
+			continue
+		}
+		var body client.Error
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != tt.code {
+			t.Errorf("%s %s: body %s", tt.method, tt.path, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/things", nil))
+	if allow := rec.Header().Get("Allow"); allow == "" {
+		t.Error("405 without Allow")
+	}
+}
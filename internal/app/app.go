@@ -0,0 +1,209 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/breaker"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/gcpauth"
+	"github.com/nikipaj1/video-description-pipeline/internal/health"
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/ratelimit"
+	"github.com/nikipaj1/video-description-pipeline/internal/redact"
+	"github.com/nikipaj1/video-description-pipeline/internal/replay"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// NewR2Client builds the storage client every command shares. With
+// FakeStorage it talks to the process's in-memory store instead of R2.
+func NewR2Client(cfg *config.Config) *r2.Client {
+	if cfg.FakeStorage {
+		cfg.R2EndpointURL = fakeStorageURL(cfg.R2Bucket, cfg.FakeStorageDir)
+		// requests are signed but not checked
+		cfg.R2AccessKeyID = cmp.Or(cfg.R2AccessKeyID, "fake")
+		cfg.R2SecretAccessKey = cmp.Or(cfg.R2SecretAccessKey, "fake")
+	}
+	client := r2.NewClient(
+		cfg.R2EndpointURL,
+		cfg.R2AccessKeyID,
+		cfg.R2SecretAccessKey,
+		cfg.R2Bucket,
+		httpclient.New(cfg.R2Transport),
+	)
+	client.SetRetryPolicy(retry.Policy{
+		MaxAttempts: cfg.R2RetryAttempts,
+		BaseDelay:   cfg.R2RetryDelay,
+		MaxDelay:    retry.Default.MaxDelay,
+	})
+	return client
+}
+
+var fakeStorage struct {
+	once sync.Once
+	url  string
+}
+
+// fakeStorageURL starts the process's in-memory S3 store on a loopback port
+// the first time it is called, seeding bucket from dir, and returns its URL.
+func fakeStorageURL(bucket, dir string) string {
+	fakeStorage.once.Do(func() {
+		store := s3fake.New()
+		if dir != "" {
+			if err := store.Load(bucket, dir); err != nil {
+				log.Fatalf("fake storage: load %s: %v", dir, err)
+			}
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			log.Fatalf("fake storage: %v", err)
+		}
+		go http.Serve(ln, store)
+		fakeStorage.url = "http://" + ln.Addr().String()
+		log.Printf("WARN: FAKE_STORAGE is set; objects are kept in memory at %s and lost on exit", fakeStorage.url)
+	})
+	return fakeStorage.url
+}
+
+// apiKeysObject is where managed API keys are kept.
+const apiKeysObject = "admin/api_keys.json"
+
+// NewKeyring returns the managed API keys kept in R2, or nil unless
+// cfg.ManagedAPIKeys is set.
+func NewKeyring(cfg *config.Config, r2Client *r2.Client) *auth.Keyring {
+	if !cfg.ManagedAPIKeys {
+		return nil
+	}
+	return auth.NewKeyring(r2KeyStore{r2Client})
+}
+
+type r2KeyStore struct{ c *r2.Client }
+
+func (s r2KeyStore) LoadKeys(ctx context.Context) ([]auth.Key, error) {
+	var keys []auth.Key
+	if err := s.c.DownloadJSON(ctx, apiKeysObject, &keys); err != nil && !errors.Is(err, r2.ErrNotFound) {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s r2KeyStore) SaveKeys(ctx context.Context, keys []auth.Key) error {
+	return s.c.UploadJSON(ctx, apiKeysObject, keys)
+}
+
+// ConfigureDeepgram points ASR at a self-hosted Deepgram when DEEPGRAM_URL
+// is set, adding its TLS settings to cfg.DeepgramTransport. Call it before
+// streams.SetTransportOptions.
+func ConfigureDeepgram(cfg *config.Config) error {
+	tlsCfg, err := httpclient.TLSConfig(cfg.DeepgramTLSCAFile, cfg.DeepgramTLSCertFile, cfg.DeepgramTLSKeyFile, cfg.DeepgramTLSSkipVerify)
+	if err != nil {
+		return fmt.Errorf("deepgram %w", err)
+	}
+	cfg.DeepgramTransport.TLS = tlsCfg
+	streams.SetDeepgramEndpoint(cfg.DeepgramURL, cfg.DeepgramAuth)
+	return nil
+}
+
+// RedactSecrets registers the credentials in cfg with the redact package
+// and routes the standard logger through it, so that neither log lines nor
+// error responses repeat them. Every command calls it right after loading
+// the configuration.
+func RedactSecrets(cfg *config.Config) {
+	redact.Secrets(
+		cfg.R2SecretAccessKey,
+		cfg.DeepgramAPIKey,
+		cfg.GeminiAPIKey,
+		cfg.WebhookSecret,
+		cfg.EventsToken,
+		cfg.CFAPIToken,
+	)
+	redact.Secrets(cfg.APIKeys...)
+	for _, secret := range cfg.WebhookTenantSecrets {
+		redact.Secrets(secret)
+	}
+	if u, err := url.Parse(cfg.RedisURL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			redact.Secrets(password)
+		}
+	}
+	log.SetOutput(redact.Writer(os.Stderr))
+}
+
+// ConfigureStreams installs the process-wide provider settings used by the
+// streams package: transports, retries, rate limiting, the Deepgram breaker
+// and health tracking. Any process that runs extraction jobs calls it once
+// at startup.
+//
+// With MockProviders the provider clients are replaced by canned ones, and
+// unset API keys in cfg are filled with a placeholder so that no stream is
+// skipped.
+func ConfigureStreams(cfg *config.Config) error {
+	if err := ConfigureDeepgram(cfg); err != nil {
+		return err
+	}
+	streams.SetTransportOptions(cfg.DeepgramTransport, cfg.GeminiTransport)
+	streams.SetGeminiFileThreshold(cfg.GeminiFileThresholdKB << 10)
+	streams.SetGeminiHedge(cfg.GeminiHedgeAfter)
+	streams.SetGeminiCache(cfg.GeminiCacheTTL, cfg.GeminiCacheMinTokens)
+	if cfg.VertexProject != "" && !cfg.MockProviders {
+		streams.SetGeminiVertex(cfg.VertexProject, cfg.VertexLocation, gcpauth.NewTokenSource(cfg.GoogleCredentialsFile, streams.VertexScope).Token)
+	}
+	streams.SetGeminiBatchPollInterval(cfg.GeminiBatchPollInterval)
+	streams.SetAllowedModels(cfg.DeepgramModels, cfg.GeminiModels)
+	streams.SetRetryPolicies(
+		retry.Policy{MaxAttempts: cfg.DeepgramRetryAttempts, BaseDelay: cfg.DeepgramRetryDelay, MaxDelay: retry.Default.MaxDelay},
+		retry.Policy{MaxAttempts: cfg.GeminiRetryAttempts, BaseDelay: cfg.GeminiRetryDelay, MaxDelay: retry.Default.MaxDelay},
+	)
+	streams.SetGeminiLimiter(ratelimit.New("gemini", cfg.GeminiRPM, cfg.GeminiTPM, cfg.RedisURL))
+	if cfg.DeepgramBreakerThreshold > 0 {
+		streams.SetDeepgramBreaker(breaker.New(cfg.DeepgramBreakerThreshold, cfg.DeepgramBreakerCooldown))
+	}
+	if cfg.ProviderHealthErrorRate > 0 {
+		streams.SetProviderHealth(
+			health.New(cfg.ProviderHealthWindow, cfg.ProviderHealthErrorRate, cfg.ProviderHealthMinSamples),
+			health.New(cfg.ProviderHealthWindow, cfg.ProviderHealthErrorRate, cfg.ProviderHealthMinSamples),
+		)
+	}
+
+	if cfg.MockProviders {
+		if err := streams.UseMockProviders(cfg.MockFixtures); err != nil {
+			return err
+		}
+		cfg.DeepgramAPIKey = cmp.Or(cfg.DeepgramAPIKey, "mock")
+		cfg.GeminiAPIKey = cmp.Or(cfg.GeminiAPIKey, "mock")
+		log.Printf("WARN: MOCK_PROVIDERS is set; results are canned, not real")
+	}
+
+	if cfg.ProviderRecordDir != "" {
+		var err error
+		streams.WrapTransports(func(provider string, rt http.RoundTripper) http.RoundTripper {
+			if err != nil {
+				return rt
+			}
+			var rec *replay.Recorder
+			rec, err = replay.NewRecorder(provider, filepath.Join(cfg.ProviderRecordDir, provider), rt)
+			if err != nil {
+				return rt
+			}
+			return rec
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("recording provider traffic to %s", cfg.ProviderRecordDir)
+	}
+	return nil
+}
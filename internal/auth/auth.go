@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+)
+
+// Principal is who a request authenticated as.
+type Principal struct {
+	Method  string // "api_key" | "jwt" | "mtls"
+	Subject string // the token's sub; for API keys, a fingerprint or the key's ID
+
+	// Managed keys only: what the key may do (nil = anything) and the
+	// tenant it is bound to
+	Scopes []string
+	Tenant string
+
+	key *Key
+}
+
+// Allows reports whether p may use endpoints needing scope. The admin
+// scope allows everything.
+func (p *Principal) Allows(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// Options configure an Authenticator. With none of APIKeys, Issuer and
+// Keys set, every request is let through.
+type Options struct {
+	// APIKeys are static bearer tokens with every scope
+	APIKeys []string
+
+	// Keys are the managed API keys, with their own scopes
+	Keys *Keyring
+
+	// OIDC: JWTs issued by Issuer for Audience are accepted, verified with
+	// the keys at JWKSURL, or those the issuer's discovery document lists
+	Issuer   string
+	Audience string
+	JWKSURL  string
+}
+
+// Authenticator checks the bearer token of API requests.
+type Authenticator struct {
+	keys    []string
+	keyring *Keyring
+	jwt     *Verifier
+}
+
+// New returns nil when opts configure no credentials.
+func New(opts Options) *Authenticator {
+	if len(opts.APIKeys) == 0 && opts.Issuer == "" && opts.Keys == nil {
+		return nil
+	}
+	a := &Authenticator{keys: opts.APIKeys, keyring: opts.Keys}
+	if opts.Issuer != "" {
+		a.jwt = NewVerifier(opts.Issuer, opts.Audience, opts.JWKSURL)
+	}
+	return a
+}
+
+// ErrUnauthenticated is returned for requests without valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticate checks req's bearer token, or its X-API-Key header, against
+// the static and then the managed API keys, and then as a JWT.
+func (a *Authenticator) Authenticate(req *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = req.Header.Get("X-API-Key")
+	}
+	return a.authenticate(req.Context(), token)
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			sum := sha256.Sum256([]byte(key))
+			return &Principal{Method: "api_key", Subject: "key:" + hex.EncodeToString(sum[:4])}, nil
+		}
+	}
+	if a.keyring != nil {
+		key, ok, err := a.keyring.lookup(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &Principal{Method: "api_key", Subject: key.ID, Scopes: key.Scopes, Tenant: key.Tenant, key: &key}, nil
+		}
+	}
+	if a.jwt == nil || strings.Count(token, ".") != 2 {
+		return nil, ErrUnauthenticated
+	}
+	claims, err := a.jwt.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Method: "jwt", Subject: claims.Subject}, nil
+}
+
+// Require rejects requests that do not authenticate with 401, or 503 when
+// the keys to check a token cannot be fetched; those whose credential lacks
+// scope with 403; and those over their key's rate limit with 429. A nil
+// *Authenticator lets every request through.
+func (a *Authenticator) Require(scope string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, err := a.Authenticate(req)
+		if err == nil {
+			err = a.admit(p, scope)
+		}
+		var scopeErr *ScopeError
+		var rateErr *RateLimitError
+		switch {
+		case errors.Is(err, ErrKeysUnavailable):
+			log.Printf("WARN: auth: %v", err)
+			apierr.Write(w, "authentication unavailable", http.StatusServiceUnavailable)
+			return
+		case errors.As(err, &scopeErr):
+			apierr.WriteDetails(w, "forbidden: "+err.Error(), http.StatusForbidden, map[string]any{"scope": scopeErr.Scope})
+			return
+		case errors.As(err, &rateErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.Wait.Seconds()))))
+			apierr.Write(w, err.Error(), http.StatusTooManyRequests)
+			return
+		case err != nil:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="video-description-pipeline"`)
+			apierr.Write(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithPrincipal(req.Context(), p)))
+	})
+}
+
+// Authorize checks token as Require checks a request's, for APIs not served
+// over HTTP. It fails with ErrKeysUnavailable, a *ScopeError, a
+// *RateLimitError or, for credentials that do not authenticate, any other
+// error. A nil *Authenticator authorizes every token as nobody.
+func (a *Authenticator) Authorize(ctx context.Context, token, scope string) (*Principal, error) {
+	if a == nil {
+		return nil, nil
+	}
+	p, err := a.authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.admit(p, scope); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// admit checks that p has scope and is within its key's rate limit.
+func (a *Authenticator) admit(p *Principal, scope string) error {
+	if !p.Allows(scope) {
+		return &ScopeError{Scope: scope}
+	}
+	if p.key != nil {
+		if wait := a.keyring.allow(*p.key); wait > 0 {
+			return &RateLimitError{RPM: p.key.RPM, Wait: wait}
+		}
+	}
+	return nil
+}
+
+// ScopeError is a credential that lacks the scope an endpoint needs.
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("the key lacks the %q scope", e.Scope)
+}
+
+// RateLimitError is a managed key over its rate limit, free again after
+// Wait.
+type RateLimitError struct {
+	RPM  int
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %d requests per minute exceeded", e.RPM)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying p, for FromContext.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal Require authenticated, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
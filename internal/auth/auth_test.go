@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// issuer is a stub OIDC issuer serving discovery and JWKS documents.
+type issuer struct {
+	*httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	jwksCalls atomic.Int32
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{rsaKey: rsaKey, ecKey: ecKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, req *http.Request) {
+		iss.jwksCalls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64.EncodeToString(rsaKey.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))), "y": b64.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *issuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+func (iss *issuer) claims(mod func(map[string]any)) map[string]any {
+	c := map[string]any{
+		"iss": iss.URL,
+		"sub": "svc-orchestrator",
+		"aud": []string{"video-pipeline", "other"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	if mod != nil {
+		mod(c)
+	}
+	return c
+}
+
+func TestVerifier_AcceptsValidTokens(t *testing.T) {
+	iss := newIssuer(t)
+	v := NewVerifier(iss.URL, "video-pipeline", "")
+	for _, alg := range []string{"RS256", "PS256", "ES256"} {
+		kid := "rsa1"
+		if alg == "ES256" {
+			kid = "ec1"
+		}
+		claims, err := v.Verify(context.Background(), iss.sign(t, alg, kid, iss.claims(nil)))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if claims.Subject != "svc-orchestrator" {
+			t.Errorf("%s: sub = %q", alg, claims.Subject)
+		}
+	}
+	if n := iss.jwksCalls.Load(); n != 1 {
+		t.Errorf("keys fetched %d times, want 1", n)
+	}
+}
+
+func TestVerifier_RejectsInvalidTokens(t *testing.T) {
+	iss := newIssuer(t)
+	v := NewVerifier(iss.URL, "video-pipeline", iss.URL+"/keys")
+	valid := iss.sign(t, "RS256", "rsa1", iss.claims(nil))
+
+	for name, token := range map[string]string{
+		"expired":         iss.sign(t, "RS256", "rsa1", iss.claims(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		"no expiry":       iss.sign(t, "RS256", "rsa1", iss.claims(func(c map[string]any) { delete(c, "exp") })),
+		"not yet valid":   iss.sign(t, "RS256", "rsa1", iss.claims(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() })),
+		"wrong issuer":    iss.sign(t, "RS256", "rsa1", iss.claims(func(c map[string]any) { c["iss"] = "https://evil.example" })),
+		"wrong audience":  iss.sign(t, "RS256", "rsa1", iss.claims(func(c map[string]any) { c["aud"] = "billing" })),
+		"unknown key":     iss.sign(t, "RS256", "rsa2", iss.claims(nil)),
+		"alg mismatch":    iss.sign(t, "ES256", "rsa1", iss.claims(nil)),
+		"tampered claims": strings.Replace(valid, strings.Split(valid, ".")[1], b64.EncodeToString([]byte(`{"iss":"`+iss.URL+`","sub":"admin","aud":"video-pipeline","exp":9999999999}`)), 1),
+		"alg none":        b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(valid, ".")[1] + ".",
+		"not a jwt":       "abc",
+	} {
+		if _, err := v.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestVerifier_RefetchesKeysForUnknownKeyID(t *testing.T) {
+	iss := newIssuer(t)
+	v := NewVerifier(iss.URL, "", iss.URL+"/keys")
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	v.Verify(context.Background(), iss.sign(t, "RS256", "rsa1", iss.claims(nil)))
+	v.Verify(context.Background(), iss.sign(t, "RS256", "rotated", iss.claims(nil)))
+	if n := iss.jwksCalls.Load(); n != 1 {
+		t.Fatalf("keys fetched %d times within jwksMinAge, want 1", n)
+	}
+	now = now.Add(2 * jwksMinAge)
+	v.Verify(context.Background(), iss.sign(t, "RS256", "rotated", iss.claims(nil)))
+	if n := iss.jwksCalls.Load(); n != 2 {
+		t.Errorf("keys fetched %d times, want 2", n)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	iss := newIssuer(t)
+	a := New(Options{APIKeys: []string{"k1", "k2"}, Issuer: iss.URL, Audience: "video-pipeline"})
+	var got *Principal
+	h := a.Require(ScopeExtract, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = FromContext(req.Context())
+	}))
+
+	for _, tc := range []struct {
+		name, header, value string
+		status              int
+		method              string
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized, ""},
+		{"wrong key", "Authorization", "Bearer k3", http.StatusUnauthorized, ""},
+		{"bearer key", "Authorization", "Bearer k2", http.StatusOK, "api_key"},
+		{"header key", "X-API-Key", "k1", http.StatusOK, "api_key"},
+		{"jwt", "Authorization", "Bearer " + iss.sign(t, "ES256", "ec1", iss.claims(nil)), http.StatusOK, "jwt"},
+	} {
+		got = nil
+		req := httptest.NewRequest(http.MethodPost, "/extract", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+			continue
+		}
+		if tc.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate", tc.name)
+		}
+		if tc.method != "" && (got == nil || got.Method != tc.method) {
+			t.Errorf("%s: principal %+v", tc.name, got)
+		}
+	}
+}
+
+func TestRequire_IssuerDown(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	a := New(Options{Issuer: down.URL})
+	token := b64.EncodeToString([]byte(`{"alg":"RS256","kid":"k"}`)) + "." + b64.EncodeToString([]byte(`{}`)) + ".c2ln"
+
+	if _, err := a.Authenticate(withBearer(token)); !errors.Is(err, ErrKeysUnavailable) {
+		t.Fatalf("err = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	a.Require(ScopeExtract, http.NotFoundHandler()).ServeHTTP(rec, withBearer(token))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+}
+
+func TestNew_Unconfigured(t *testing.T) {
+	if a := New(Options{}); a != nil {
+		t.Fatal("authenticator without credentials")
+	}
+	var a *Authenticator
+	rec := httptest.NewRecorder()
+	a.Require(ScopeExtract, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status %d", rec.Code)
+	}
+}
+
+func withBearer(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/extract", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
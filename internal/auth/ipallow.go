@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+)
+
+// ParsePrefixes parses CIDRs; a bare address stands for itself alone.
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an address or CIDR", s)
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR", s)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// IPAllowlist admits requests from clients whose address is in one of its
+// prefixes. Behind proxies in trustedProxies the client is the last address
+// in X-Forwarded-For that is not a trusted proxy; the header is ignored
+// from anyone else, so clients cannot choose their own address.
+type IPAllowlist struct {
+	allow   []netip.Prefix
+	proxies []netip.Prefix
+}
+
+// NewIPAllowlist returns nil, which admits everyone, when allow is empty.
+func NewIPAllowlist(allow, trustedProxies []netip.Prefix) *IPAllowlist {
+	if len(allow) == 0 {
+		return nil
+	}
+	return &IPAllowlist{allow: allow, proxies: trustedProxies}
+}
+
+// Middleware answers requests from other clients with 403.
+func (l *IPAllowlist) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !l.Allows(req.RemoteAddr, req.Header.Values("X-Forwarded-For")) {
+			log.Printf("WARN: %s %s from %s refused: not in the allowlist", req.Method, req.URL.Path, req.RemoteAddr)
+			apierr.Write(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Allows reports whether a client connecting from remoteAddr (host:port),
+// with the given X-Forwarded-For values, is admitted.
+func (l *IPAllowlist) Allows(remoteAddr string, forwardedFor []string) bool {
+	if l == nil {
+		return true
+	}
+	ip, ok := l.clientIP(remoteAddr, forwardedFor)
+	return ok && contains(l.allow, ip)
+}
+
+func (l *IPAllowlist) clientIP(remoteAddr string, forwardedFor []string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	ip = ip.Unmap()
+	if !contains(l.proxies, ip) {
+		return ip, true
+	}
+	var hops []string
+	for _, h := range forwardedFor {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for _, hop := range slices.Backward(hops) {
+		fwd, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if fwd = fwd.Unmap(); !contains(l.proxies, fwd) {
+			return fwd, true
+		}
+	}
+	// Only proxies: the request came from one of them
+	return ip, true
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(ip) })
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	allow, err := ParsePrefixes([]string{"10.0.0.0/8", "192.168.1.7", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, _ := ParsePrefixes([]string{"172.16.0.0/12"})
+	h := NewIPAllowlist(allow, proxies).Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	for _, tc := range []struct {
+		name, remote string
+		forwarded    []string
+		status       int
+	}{
+		{"allowed range", "10.1.2.3:5000", nil, http.StatusOK},
+		{"allowed address", "192.168.1.7:5000", nil, http.StatusOK},
+		{"other address", "192.168.1.8:5000", nil, http.StatusForbidden},
+		{"ipv6", "[2001:db8::1]:443", nil, http.StatusOK},
+		{"ipv4-mapped", "[::ffff:10.0.0.1]:443", nil, http.StatusOK},
+		{"spoofed header from a client", "8.8.8.8:5000", []string{"10.0.0.1"}, http.StatusForbidden},
+		{"forwarded by a trusted proxy", "172.16.0.2:5000", []string{"10.0.0.1"}, http.StatusOK},
+		{"refused behind a trusted proxy", "172.16.0.2:5000", []string{"8.8.8.8"}, http.StatusForbidden},
+		{"client prepends an allowed address", "172.16.0.2:5000", []string{"10.0.0.1, 8.8.8.8"}, http.StatusForbidden},
+		{"several trusted hops", "172.16.0.2:5000", []string{"10.0.0.1", "172.16.0.9"}, http.StatusOK},
+		{"garbage hop", "172.16.0.2:5000", []string{"not-an-ip"}, http.StatusForbidden},
+		{"proxy itself", "172.16.0.2:5000", nil, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/extract", nil)
+		req.RemoteAddr = tc.remote
+		for _, f := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	if _, err := ParsePrefixes([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("accepted an invalid CIDR")
+	}
+	if _, err := ParsePrefixes([]string{"example.com"}); err == nil {
+		t.Error("accepted a host name")
+	}
+	if l := NewIPAllowlist(nil, nil); l != nil {
+		t.Error("empty allowlist is not nil")
+	}
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/ratelimit"
+)
+
+// Scopes a managed API key can be granted. Static API keys, JWTs and
+// client certificates have every scope.
+const (
+	ScopeExtract = "extract" // start jobs
+	ScopeRead    = "read"    // read stored results
+	ScopeAdmin   = "admin"   // everything, including capacity and key management
+)
+
+// Scopes lists the valid scopes.
+var Scopes = []string{ScopeExtract, ScopeRead, ScopeAdmin}
+
+// Key is a managed API key. Only a hash of its secret is kept.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"` // hex SHA-256 of the secret
+	Scopes    []string  `json:"scopes"`
+	Tenant    string    `json:"tenant,omitempty"` // jobs may only be run for this tenant
+	RPM       int       `json:"rpm,omitempty"`    // requests per minute per replica, 0 = unlimited
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyStore persists managed keys.
+type KeyStore interface {
+	LoadKeys(ctx context.Context) ([]Key, error)
+	SaveKeys(ctx context.Context, keys []Key) error
+}
+
+// keyringRefresh bounds how long a key created or revoked through another
+// replica takes to be honoured here.
+const keyringRefresh = 30 * time.Second
+
+var (
+	// ErrKeyNotFound is returned by Revoke for an unknown key ID.
+	ErrKeyNotFound = errors.New("no such key")
+	// ErrInvalidKey is returned by Create for keys it cannot create.
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// Keyring holds the managed API keys, reloaded from its store every
+// keyringRefresh, and enforces their rate limits.
+type Keyring struct {
+	store KeyStore
+	now   func() time.Time
+
+	mu       sync.Mutex
+	byHash   map[string]Key
+	loaded   time.Time
+	limiters map[string]*ratelimit.Local // by key ID
+}
+
+func NewKeyring(store KeyStore) *Keyring {
+	return &Keyring{store: store, now: time.Now, limiters: map[string]*ratelimit.Local{}}
+}
+
+// lookup returns the key whose secret is token. When the store cannot be
+// read the keys last loaded stay in use; before any were loaded it returns
+// ErrKeysUnavailable.
+func (k *Keyring) lookup(ctx context.Context, token string) (Key, bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.now().Sub(k.loaded) >= keyringRefresh {
+		keys, err := k.store.LoadKeys(ctx)
+		switch {
+		case err == nil:
+			k.index(keys)
+		case k.byHash == nil:
+			return Key{}, false, fmt.Errorf("%w: api keys: %v", ErrKeysUnavailable, err)
+		default:
+			log.Printf("WARN: auth: reload api keys, keeping the last loaded: %v", err)
+			k.loaded = k.now()
+		}
+	}
+	key, ok := k.byHash[hashSecret(token)]
+	return key, ok, nil
+}
+
+func (k *Keyring) index(keys []Key) {
+	k.byHash = make(map[string]Key, len(keys))
+	for _, key := range keys {
+		k.byHash[key.Hash] = key
+	}
+	k.loaded = k.now()
+}
+
+// allow takes one request from key's rate limit. It returns 0 if the
+// request may proceed, or else how long until it could.
+func (k *Keyring) allow(key Key) time.Duration {
+	if key.RPM <= 0 {
+		return 0
+	}
+	k.mu.Lock()
+	l := k.limiters[key.ID]
+	if l == nil {
+		l = ratelimit.NewLocal(key.RPM, 0)
+		k.limiters[key.ID] = l
+	}
+	k.mu.Unlock()
+	return l.Allow(0)
+}
+
+// List returns the stored keys, oldest first.
+func (k *Keyring) List(ctx context.Context) ([]Key, error) {
+	return k.store.LoadKeys(ctx)
+}
+
+// Create stores a new key with key's name, scopes, tenant and rate limit
+// and returns it along with its secret, which is not kept and cannot be
+// shown again.
+func (k *Keyring) Create(ctx context.Context, key Key) (Key, string, error) {
+	for _, s := range key.Scopes {
+		if !slices.Contains(Scopes, s) {
+			return Key{}, "", fmt.Errorf("%w: unknown scope %q (want %q, %q or %q)", ErrInvalidKey, s, ScopeExtract, ScopeRead, ScopeAdmin)
+		}
+	}
+	if len(key.Scopes) == 0 {
+		return Key{}, "", fmt.Errorf("%w: a key needs at least one scope", ErrInvalidKey)
+	}
+	if key.RPM < 0 {
+		return Key{}, "", fmt.Errorf("%w: rpm must not be negative", ErrInvalidKey)
+	}
+
+	var b [32]byte
+	rand.Read(b[:])
+	secret := "vdp_" + base64.RawURLEncoding.EncodeToString(b[:])
+	rand.Read(b[:6])
+	key.ID = "key_" + hex.EncodeToString(b[:6])
+	key.Hash = hashSecret(secret)
+	key.CreatedAt = k.now().UTC().Truncate(time.Second)
+
+	err := k.update(ctx, func(keys []Key) ([]Key, error) { return append(keys, key), nil })
+	return key, secret, err
+}
+
+// Revoke deletes the key with the given ID.
+func (k *Keyring) Revoke(ctx context.Context, id string) error {
+	return k.update(ctx, func(keys []Key) ([]Key, error) {
+		i := slices.IndexFunc(keys, func(key Key) bool { return key.ID == id })
+		if i < 0 {
+			return nil, ErrKeyNotFound
+		}
+		return slices.Delete(keys, i, i+1), nil
+	})
+}
+
+// update applies fn to the stored keys and saves the result. Changes made
+// through other replicas at the same moment may be lost; key management is
+// rare enough for that not to matter.
+func (k *Keyring) update(ctx context.Context, fn func([]Key) ([]Key, error)) error {
+	keys, err := k.store.LoadKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if keys, err = fn(keys); err != nil {
+		return err
+	}
+	if err := k.store.SaveKeys(ctx, keys); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.index(keys)
+	k.mu.Unlock()
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
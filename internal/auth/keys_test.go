@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memStore is a KeyStore in memory that can be made to fail.
+type memStore struct {
+	keys  []Key
+	loads int
+	err   error
+}
+
+func (s *memStore) LoadKeys(context.Context) ([]Key, error) {
+	s.loads++
+	return slices.Clone(s.keys), s.err
+}
+
+func (s *memStore) SaveKeys(_ context.Context, keys []Key) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.keys = slices.Clone(keys)
+	return nil
+}
+
+func TestKeyring_Scopes(t *testing.T) {
+	ring := NewKeyring(&memStore{})
+	ctx := context.Background()
+	_, reader, err := ring.Create(ctx, Key{Name: "reporting", Scopes: []string{ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, _ := ring.Create(ctx, Key{Name: "ops", Scopes: []string{ScopeAdmin}})
+	bound, extractor, _ := ring.Create(ctx, Key{Name: "acme", Scopes: []string{ScopeExtract}, Tenant: "acme"})
+
+	a := New(Options{APIKeys: []string{"static"}, Keys: ring})
+	var got *Principal
+	for _, tc := range []struct {
+		name, token, scope string
+		status             int
+	}{
+		{"reader reads", reader, ScopeRead, http.StatusOK},
+		{"reader extracts", reader, ScopeExtract, http.StatusForbidden},
+		{"extractor extracts", extractor, ScopeExtract, http.StatusOK},
+		{"extractor administers", extractor, ScopeAdmin, http.StatusForbidden},
+		{"admin extracts", admin, ScopeExtract, http.StatusOK},
+		{"static key administers", "static", ScopeAdmin, http.StatusOK},
+		{"unknown key", "vdp_nope", ScopeRead, http.StatusUnauthorized},
+	} {
+		got = nil
+		rec := httptest.NewRecorder()
+		a.Require(tc.scope, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			got = FromContext(req.Context())
+		})).ServeHTTP(rec, withBearer(tc.token))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+		}
+		if tc.token == extractor && got != nil && (got.Tenant != "acme" || got.Subject != bound.ID) {
+			t.Errorf("%s: principal %+v", tc.name, got)
+		}
+	}
+}
+
+func TestKeyring_RateLimit(t *testing.T) {
+	ring := NewKeyring(&memStore{})
+	_, secret, _ := ring.Create(context.Background(), Key{Name: "batch", Scopes: []string{ScopeExtract}, RPM: 2})
+	h := New(Options{Keys: ring}).Require(ScopeExtract, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	var codes []int
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, withBearer(secret))
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 without Retry-After")
+		}
+	}
+	if !slices.Equal(codes, []int{200, 200, 429}) {
+		t.Errorf("statuses %v", codes)
+	}
+}
+
+func TestKeyring_Refresh(t *testing.T) {
+	store := &memStore{}
+	ring := NewKeyring(store)
+	now := time.Now()
+	ring.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Another replica creates a key
+	other := NewKeyring(store)
+	key, secret, _ := other.Create(ctx, Key{Name: "k", Scopes: []string{ScopeRead}})
+	if _, ok, _ := ring.lookup(ctx, secret); !ok {
+		t.Fatal("new key not found on first load")
+	}
+
+	// ... and revokes it; this replica notices after keyringRefresh
+	if err := other.Revoke(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := ring.lookup(ctx, secret); !ok {
+		t.Error("keys reloaded before keyringRefresh")
+	}
+	now = now.Add(keyringRefresh)
+	if _, ok, _ := ring.lookup(ctx, secret); ok {
+		t.Error("revoked key still accepted")
+	}
+
+	// A store outage keeps the last loaded keys
+	store.err = errors.New("r2 down")
+	now = now.Add(keyringRefresh)
+	if _, _, err := ring.lookup(ctx, secret); err != nil {
+		t.Errorf("outage after a load: %v", err)
+	}
+	if _, _, err := NewKeyring(store).lookup(ctx, secret); !errors.Is(err, ErrKeysUnavailable) {
+		t.Errorf("outage before any load: err = %v", err)
+	}
+}
+
+func TestKeyring_Create(t *testing.T) {
+	store := &memStore{}
+	ring := NewKeyring(store)
+	ctx := context.Background()
+	for _, k := range []Key{{Name: "none"}, {Scopes: []string{"write"}}, {Scopes: []string{ScopeRead}, RPM: -1}} {
+		if _, _, err := ring.Create(ctx, k); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Create(%+v) err = %v", k, err)
+		}
+	}
+	key, secret, err := ring.Create(ctx, Key{Name: "ok", Scopes: []string{ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.keys) != 1 || store.keys[0].Hash == secret || store.keys[0].ID != key.ID {
+		t.Errorf("stored %+v", store.keys)
+	}
+	if err := ring.Revoke(ctx, "key_missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Revoke err = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for cn, for a server or a client.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func write(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRequireClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "clients")
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	cfg, err := ServerTLSConfig(write(t, dir, "tls.crt", certPEM), write(t, dir, "tls.key", keyPEM), write(t, dir, "ca.crt", ca.pem))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /extract", RequireClientCert(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, FromContext(req.Context()).Subject)
+	})))
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, req *http.Request) {})
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = cfg
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientFor := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	cc, ck := ca.issue(t, "orchestrator", x509.ExtKeyUsageClientAuth)
+	good, _ := tls.X509KeyPair(cc, ck)
+	oc, ok := newTestCA(t, "other").issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+	foreign, _ := tls.X509KeyPair(oc, ok)
+
+	resp, err := clientFor(good).Post(server.URL+"/extract", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "orchestrator" {
+		t.Errorf("with a client certificate: %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = clientFor().Post(server.URL+"/extract", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("without a client certificate: %d, want 403", resp.StatusCode)
+	}
+
+	resp, err = clientFor().Get(server.URL + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("probe without a client certificate: %d", resp.StatusCode)
+	}
+
+	// The client does not offer a certificate the server's CAs did not
+	// issue, or the handshake fails if it does
+	if resp, err := clientFor(foreign).Post(server.URL+"/extract", "application/json", nil); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("certificate from another CA: %d, want 403", resp.StatusCode)
+		}
+	}
+}
+
+func TestServerTLSConfig_BadCA(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM := newTestCA(t, "ca").issue(t, "server", x509.ExtKeyUsageServerAuth)
+	_, err := ServerTLSConfig(write(t, dir, "tls.crt", certPEM), write(t, dir, "tls.key", keyPEM), write(t, dir, "ca.crt", []byte("not a certificate")))
+	if err == nil {
+		t.Error("accepted a CA file without certificates")
+	}
+}
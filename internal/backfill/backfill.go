@@ -0,0 +1,212 @@
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/ratelimit"
+)
+
+// Filter picks the ads a backfill processes.
+type Filter struct {
+	// Ads whose video was uploaded outside [Since, Until) are left alone.
+	// Zero bounds are open.
+	Since, Until time.Time
+
+	// An ad is selected if any of these result files is missing, if its
+	// newest result predates StaleBefore or its own video, or always with
+	// All.
+	Missing     []string
+	StaleBefore time.Time
+	All         bool
+}
+
+// Select returns the IDs of the ads that f picks, in the order given. Ads
+// without a video cannot be processed and are never selected.
+func Select(ads []r2.AdObjects, f Filter) []string {
+	var ids []string
+	for _, ad := range ads {
+		if ad.VideoModified.IsZero() {
+			continue
+		}
+		if (!f.Since.IsZero() && ad.VideoModified.Before(f.Since)) ||
+			(!f.Until.IsZero() && !ad.VideoModified.Before(f.Until)) {
+			continue
+		}
+		if f.All || missingAny(ad, f.Missing) || stale(ad, f.StaleBefore) {
+			ids = append(ids, ad.AdID)
+		}
+	}
+	return ids
+}
+
+func missingAny(ad r2.AdObjects, names []string) bool {
+	for _, name := range names {
+		if _, ok := ad.Results[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stale reports whether the newest result is older than the cutoff or than
+// the video it was made from. An ad with no results is not stale, only
+// missing.
+func stale(ad r2.AdObjects, before time.Time) bool {
+	var newest time.Time
+	for _, modified := range ad.Results {
+		if modified.After(newest) {
+			newest = modified
+		}
+	}
+	if newest.IsZero() {
+		return false
+	}
+	return newest.Before(ad.VideoModified) || (!before.IsZero() && newest.Before(before))
+}
+
+// Entry is one line of the progress file.
+type Entry struct {
+	AdID   string    `json:"ad_id"`
+	Status string    `json:"status"` // "done" | "failed"
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// LoadProgress returns the ads a previous run finished, from the last entry
+// recorded for each. A missing file means nothing is done yet.
+func LoadProgress(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open progress: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		// A line cut short by a crash is ignored; that ad is simply redone
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		done[e.AdID] = e.Status == "done"
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read progress: %w", err)
+	}
+	return done, nil
+}
+
+// Options configures a backfill run.
+type Options struct {
+	AdIDs       []string
+	Submit      func(ctx context.Context, adID string) error
+	Concurrency int     // ads processed at once
+	Rate        float64 // submissions started per second (0 = unlimited)
+	Progress    string  // progress file, appended to; "" disables resuming
+}
+
+// Report summarises a backfill run.
+type Report struct {
+	Skipped   int // already done in an earlier run
+	Succeeded int
+	Failed    int
+	Errors    map[string]string // failed ad -> error
+}
+
+// Run submits every ad not already done according to the progress file,
+// recording each outcome there as it happens so that an interrupted run can
+// be resumed. Failed ads are retried by the next run. When ctx ends no new
+// ads are started and Run waits for those in flight.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	rep := &Report{Errors: map[string]string{}}
+	done := map[string]bool{}
+	var progress *os.File
+	if opts.Progress != "" {
+		var err error
+		if done, err = LoadProgress(opts.Progress); err != nil {
+			return nil, err
+		}
+		progress, err = os.OpenFile(opts.Progress, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open progress: %w", err)
+		}
+		defer progress.Close()
+	}
+
+	var limiter ratelimit.Limiter
+	if opts.Rate > 0 {
+		// The local limiter refills per minute; allow at least one request
+		limiter = ratelimit.NewLocal(max(int(opts.Rate*60), 1), 0)
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(opts.Concurrency, 1))
+	)
+	record := func(adID string, err error) {
+		e := Entry{AdID: adID, Status: "done", At: time.Now().UTC()}
+		if err != nil {
+			e.Status, e.Error = "failed", err.Error()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			rep.Failed++
+			rep.Errors[adID] = err.Error()
+			log.Printf("%s failed: %v", adID, err)
+		} else {
+			rep.Succeeded++
+		}
+		if progress != nil {
+			line, _ := json.Marshal(e)
+			progress.Write(append(line, '\n'))
+		}
+	}
+
+	for _, adID := range opts.AdIDs {
+		if done[adID] {
+			rep.Skipped++
+			continue
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx, 0); err != nil {
+				break
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := opts.Submit(ctx, adID)
+			if err != nil && ctx.Err() != nil {
+				// Interrupted rather than failed: leave it for the next run
+				return
+			}
+			record(adID, err)
+		}()
+	}
+	wg.Wait()
+	return rep, nil
+}
@@ -0,0 +1,124 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+)
+
+func TestSelect(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	ads := []r2.AdObjects{
+		{AdID: "complete", VideoModified: day(1), Results: map[string]time.Time{"asr_results.json": day(2), "vlm_results.json": day(2)}},
+		{AdID: "no-vlm", VideoModified: day(1), Results: map[string]time.Time{"asr_results.json": day(2)}},
+		{AdID: "reuploaded", VideoModified: day(5), Results: map[string]time.Time{"asr_results.json": day(2), "vlm_results.json": day(2)}},
+		{AdID: "new", VideoModified: day(10)},
+		{AdID: "no-video", Results: map[string]time.Time{}},
+	}
+	missing := []string{"asr_results.json", "vlm_results.json"}
+
+	tests := []struct {
+		name string
+		f    Filter
+		want []string
+	}{
+		{"missing or stale", Filter{Missing: missing}, []string{"no-vlm", "reuploaded", "new"}},
+		{"stale before cutoff", Filter{StaleBefore: day(3)}, []string{"complete", "no-vlm", "reuploaded"}},
+		{"date range", Filter{All: true, Since: day(1), Until: day(10)}, []string{"complete", "no-vlm", "reuploaded"}},
+		{"all", Filter{All: true}, []string{"complete", "no-vlm", "reuploaded", "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Select(ads, tt.f); !slices.Equal(got, tt.want) {
+				t.Errorf("Select = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_ResumesFromProgress(t *testing.T) {
+	progress := filepath.Join(t.TempDir(), "progress.jsonl")
+	ids := []string{"a", "b", "c"}
+
+	var mu sync.Mutex
+	var submitted []string
+	submit := func(fail string) func(context.Context, string) error {
+		return func(_ context.Context, adID string) error {
+			mu.Lock()
+			submitted = append(submitted, adID)
+			mu.Unlock()
+			if adID == fail {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+
+	rep, err := Run(context.Background(), Options{AdIDs: ids, Submit: submit("b"), Concurrency: 2, Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Succeeded != 2 || rep.Failed != 1 || rep.Skipped != 0 {
+		t.Errorf("first run = %+v, want 2 succeeded, 1 failed", *rep)
+	}
+	if rep.Errors["b"] != "boom" {
+		t.Errorf("first run errors = %v, want b: boom", rep.Errors)
+	}
+
+	// The second run only retries the failure
+	submitted = nil
+	rep, err = Run(context.Background(), Options{AdIDs: ids, Submit: submit(""), Concurrency: 2, Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(submitted, []string{"b"}) {
+		t.Errorf("second run submitted %v, want [b]", submitted)
+	}
+	if rep.Succeeded != 1 || rep.Skipped != 2 {
+		t.Errorf("second run = %+v, want 1 succeeded, 2 skipped", *rep)
+	}
+
+	done, err := LoadProgress(progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if !done[id] {
+			t.Errorf("%s not recorded as done", id)
+		}
+	}
+}
+
+func TestRun_InterruptedNotRecorded(t *testing.T) {
+	progress := filepath.Join(t.TempDir(), "progress.jsonl")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := Run(ctx, Options{
+		AdIDs: []string{"a", "b"},
+		Submit: func(ctx context.Context, adID string) error {
+			cancel()
+			return ctx.Err()
+		},
+		Progress: progress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(progress); len(data) != 0 {
+		t.Errorf("progress = %q, want nothing recorded", data)
+	}
+}
+
+func TestLoadProgress_Missing(t *testing.T) {
+	done, err := LoadProgress(filepath.Join(t.TempDir(), "none.jsonl"))
+	if err != nil || len(done) != 0 {
+		t.Errorf("LoadProgress = %v, %v; want empty", done, err)
+	}
+}
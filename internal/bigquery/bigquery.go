@@ -0,0 +1,493 @@
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// Scope is the OAuth2 scope the sink's tokens need.
+const Scope = "https://www.googleapis.com/auth/bigquery"
+
+const (
+	apiBase = "https://bigquery.googleapis.com/bigquery/v2"
+
+	// maxInsertRows is the most rows sent in one insertAll request; Google
+	// recommends at most 500.
+	maxInsertRows = 500
+
+	// maxBuffered bounds rows held while BigQuery is unreachable. Jobs
+	// finishing past it are not recorded.
+	maxBuffered = 100000
+)
+
+// field is a column of a table schema.
+type field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// tables are the sink's tables and their schemas. Columns added here are
+// added to existing tables on startup; removing or retyping one needs a
+// migration by hand.
+var tables = []struct {
+	name   string
+	fields []field
+}{
+	{"segments", []field{
+		{"ad_id", "STRING", "REQUIRED"},
+		{"extracted_at", "TIMESTAMP", "REQUIRED"},
+		{"segment_index", "INTEGER", "NULLABLE"},
+		{"start_sec", "FLOAT", "NULLABLE"},
+		{"end_sec", "FLOAT", "NULLABLE"},
+		{"text", "STRING", "NULLABLE"},
+		{"confidence", "FLOAT", "NULLABLE"},
+	}},
+	{"frames", []field{
+		{"ad_id", "STRING", "REQUIRED"},
+		{"extracted_at", "TIMESTAMP", "REQUIRED"},
+		{"frame_index", "INTEGER", "NULLABLE"},
+		{"timestamp_sec", "FLOAT", "NULLABLE"},
+		{"description", "STRING", "NULLABLE"},
+		{"blocked", "BOOLEAN", "NULLABLE"},
+	}},
+	{"attributes", []field{
+		{"ad_id", "STRING", "REQUIRED"},
+		{"extracted_at", "TIMESTAMP", "REQUIRED"},
+		{"source", "STRING", "NULLABLE"},
+		{"name", "STRING", "NULLABLE"},
+		{"string_value", "STRING", "NULLABLE"},
+		{"number_value", "FLOAT", "NULLABLE"},
+		{"bool_value", "BOOLEAN", "NULLABLE"},
+	}},
+}
+
+// Job is one finished extraction's results.
+type Job struct {
+	AdID        string
+	ExtractedAt time.Time
+	Segments    []streams.ASRSegment
+	Frames      []streams.VLMFrame
+
+	// Attributes maps a source, such as "video_meta", to a result whose
+	// scalar fields become one attributes row each, named by their JSON
+	// path ("video.width"). Lists and provenance are left out.
+	Attributes map[string]any
+}
+
+// Options configures a Sink.
+type Options struct {
+	Project       string
+	Dataset       string
+	TablePrefix   string        // prepended to every table name
+	BatchRows     int           // buffered rows that trigger a flush
+	FlushInterval time.Duration // longest a row waits to be sent
+}
+
+// row is one insertAll row. insertId lets BigQuery drop rows sent twice by
+// a retry.
+type row struct {
+	InsertID string         `json:"insertId"`
+	JSON     map[string]any `json:"json"`
+}
+
+// Sink streams extraction results into BigQuery tables, creating them, or
+// adding columns they lack, before the first insert. Rows are buffered and
+// sent in batches in the background; a batch that cannot be sent is kept
+// for the next flush. A nil *Sink, as returned by New without a dataset,
+// does nothing.
+type Sink struct {
+	token func(ctx context.Context) (string, error)
+	opts  Options
+	http  *http.Client
+	base  string
+
+	mu       sync.Mutex
+	buf      map[string][]row // table -> rows
+	buffered int
+	kick     chan struct{}
+
+	flushMu sync.Mutex // one flush at a time
+	ready   bool       // tables checked
+}
+
+// New sends rows with access tokens from token (e.g. gcpauth.TokenSource's)
+// and starts flushing in the background.
+func New(token func(ctx context.Context) (string, error), opts Options) *Sink {
+	if opts.Dataset == "" {
+		return nil
+	}
+	s := &Sink{
+		token: token,
+		opts:  opts,
+		http:  &http.Client{Timeout: time.Minute},
+		base:  apiBase,
+		buf:   make(map[string][]row),
+		kick:  make(chan struct{}, 1),
+	}
+	go s.loop()
+	return s
+}
+
+// Add queues a job's rows. It never blocks on BigQuery.
+func (s *Sink) Add(job Job) {
+	if s == nil {
+		return
+	}
+	rows := jobRows(job)
+	n := 0
+	for _, r := range rows {
+		n += len(r)
+	}
+
+	s.mu.Lock()
+	if s.buffered+n > maxBuffered {
+		s.mu.Unlock()
+		log.Printf("WARN: bigquery: buffer full, dropping %d rows for %s", n, job.AdID)
+		return
+	}
+	for table, r := range rows {
+		s.buf[table] = append(s.buf[table], r...)
+	}
+	s.buffered += n
+	full := s.buffered >= max(s.opts.BatchRows, 1)
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Sink) loop() {
+	ticker := time.NewTicker(max(s.opts.FlushInterval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		if err := s.Flush(ctx); err != nil {
+			log.Printf("WARN: bigquery: %v", err)
+		}
+		cancel()
+	}
+}
+
+// Flush sends every buffered row, so a command can call it before exiting.
+// Rows that could not be sent stay buffered.
+func (s *Sink) Flush(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.buf
+	s.buf = make(map[string][]row)
+	s.buffered = 0
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var errs []error
+	if !s.ready {
+		if err := s.ensureTables(ctx); err != nil {
+			s.requeue(pending)
+			return fmt.Errorf("prepare tables: %w", err)
+		}
+		s.ready = true
+	}
+	for table, rows := range pending {
+		for len(rows) > 0 {
+			n := min(len(rows), maxInsertRows)
+			if err := s.insert(ctx, table, rows[:n]); err != nil {
+				s.requeue(map[string][]row{table: rows})
+				errs = append(errs, fmt.Errorf("insert into %s: %w", table, err))
+				break
+			}
+			rows = rows[n:]
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requeue puts rows back in front of those added since they were taken.
+func (s *Sink) requeue(rows map[string][]row) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for table, r := range rows {
+		if s.buffered+len(r) > maxBuffered {
+			log.Printf("WARN: bigquery: buffer full, dropping %d unsent rows for %s", len(r), table)
+			continue
+		}
+		s.buf[table] = append(r, s.buf[table]...)
+		s.buffered += len(r)
+	}
+}
+
+func (s *Sink) tablesURL() string {
+	return fmt.Sprintf("%s/projects/%s/datasets/%s/tables", s.base, url.PathEscape(s.opts.Project), url.PathEscape(s.opts.Dataset))
+}
+
+func (s *Sink) tableURL(table string) string {
+	return s.tablesURL() + "/" + url.PathEscape(s.opts.TablePrefix+table)
+}
+
+// ensureTables creates missing tables, partitioned by day of extraction and
+// clustered by ad, and adds missing columns to existing ones.
+func (s *Sink) ensureTables(ctx context.Context) error {
+	for _, t := range tables {
+		var existing struct {
+			Schema struct {
+				Fields []json.RawMessage `json:"fields"`
+			} `json:"schema"`
+		}
+		err := s.call(ctx, http.MethodGet, s.tableURL(t.name), nil, &existing)
+		var httpErr *retry.HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			err = s.call(ctx, http.MethodPost, s.tablesURL(), map[string]any{
+				"tableReference": map[string]string{
+					"projectId": s.opts.Project,
+					"datasetId": s.opts.Dataset,
+					"tableId":   s.opts.TablePrefix + t.name,
+				},
+				"schema":           map[string]any{"fields": t.fields},
+				"timePartitioning": map[string]string{"type": "DAY", "field": "extracted_at"},
+				"clustering":       map[string]any{"fields": []string{"ad_id"}},
+			}, nil)
+			// Another replica may have created it first
+			if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict {
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("create %s: %w", t.name, err)
+			}
+			log.Printf("bigquery: created table %s.%s%s", s.opts.Dataset, s.opts.TablePrefix, t.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get %s: %w", t.name, err)
+		}
+
+		// Existing fields are sent back as they are, so their modes and
+		// descriptions survive the patch
+		have := make(map[string]bool)
+		for _, raw := range existing.Schema.Fields {
+			var f field
+			json.Unmarshal(raw, &f)
+			have[f.Name] = true
+		}
+		fields := existing.Schema.Fields
+		var added []string
+		for _, f := range t.fields {
+			if have[f.Name] {
+				continue
+			}
+			f.Mode = "NULLABLE" // columns can only be added as nullable
+			raw, _ := json.Marshal(f)
+			fields = append(fields, raw)
+			added = append(added, f.Name)
+		}
+		if len(added) == 0 {
+			continue
+		}
+		if err := s.call(ctx, http.MethodPatch, s.tableURL(t.name), map[string]any{"schema": map[string]any{"fields": fields}}, nil); err != nil {
+			return fmt.Errorf("add columns to %s: %w", t.name, err)
+		}
+		log.Printf("bigquery: added %s to %s%s", strings.Join(added, ", "), s.opts.TablePrefix, t.name)
+	}
+	return nil
+}
+
+// insert streams rows into a table. Rows BigQuery rejects individually are
+// logged and dropped; they would fail again.
+func (s *Sink) insert(ctx context.Context, table string, rows []row) error {
+	var resp struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	err := s.call(ctx, http.MethodPost, s.tableURL(table)+"/insertAll", map[string]any{
+		"skipInvalidRows": true,
+		"rows":            rows,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	for _, ie := range resp.InsertErrors {
+		if ie.Index < 0 || ie.Index >= len(rows) {
+			continue
+		}
+		for _, e := range ie.Errors {
+			log.Printf("WARN: bigquery: %s row %s rejected: %s: %s", table, rows[ie.Index].InsertID, e.Reason, e.Message)
+		}
+	}
+	return nil
+}
+
+// call makes one API request with retries, decoding the response into out.
+func (s *Sink) call(ctx context.Context, method, u string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return retry.Do(ctx, retry.Default, func(ctx context.Context) error {
+		token, err := s.token(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := s.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 300 {
+			return retry.NewHTTPError("bigquery", resp, data)
+		}
+		if out != nil {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	})
+}
+
+// timestamp formats t as BigQuery's canonical TIMESTAMP string.
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999") + " UTC"
+}
+
+// jobRows flattens a job into rows per table.
+func jobRows(job Job) map[string][]row {
+	at := timestamp(job.ExtractedAt)
+	id := func(table string, key any) string {
+		return fmt.Sprintf("%s/%d/%s/%v", job.AdID, job.ExtractedAt.UnixNano(), table, key)
+	}
+	rows := make(map[string][]row)
+	for i, seg := range job.Segments {
+		rows["segments"] = append(rows["segments"], row{InsertID: id("segments", i), JSON: map[string]any{
+			"ad_id":         job.AdID,
+			"extracted_at":  at,
+			"segment_index": i,
+			"start_sec":     seg.Start,
+			"end_sec":       seg.End,
+			"text":          seg.Text,
+			"confidence":    seg.Confidence,
+		}})
+	}
+	for _, f := range job.Frames {
+		rows["frames"] = append(rows["frames"], row{InsertID: id("frames", f.FrameIndex), JSON: map[string]any{
+			"ad_id":         job.AdID,
+			"extracted_at":  at,
+			"frame_index":   f.FrameIndex,
+			"timestamp_sec": f.TimestampSec,
+			"description":   f.Description,
+			"blocked":       f.Blocked,
+		}})
+	}
+
+	sources := make([]string, 0, len(job.Attributes))
+	for source := range job.Attributes {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		for _, a := range flatten(job.Attributes[source]) {
+			r := map[string]any{
+				"ad_id":        job.AdID,
+				"extracted_at": at,
+				"source":       source,
+				"name":         a.name,
+			}
+			switch v := a.value.(type) {
+			case string:
+				r["string_value"] = v
+			case float64:
+				r["number_value"] = v
+			case bool:
+				r["bool_value"] = v
+			}
+			rows["attributes"] = append(rows["attributes"], row{InsertID: id("attributes", source+"."+a.name), JSON: r})
+		}
+	}
+	return rows
+}
+
+type attribute struct {
+	name  string
+	value any // string, float64 or bool
+}
+
+// flatten lists the scalar fields of v's JSON form by dotted path, sorted.
+func flatten(v any) []attribute {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var tree any
+	if json.Unmarshal(data, &tree) != nil {
+		return nil
+	}
+	var out []attribute
+	var walk func(prefix string, node any)
+	walk = func(prefix string, node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			for k, child := range n {
+				if k == "provenance" {
+					continue
+				}
+				name := k
+				if prefix != "" {
+					name = prefix + "." + k
+				}
+				walk(name, child)
+			}
+		case string, float64, bool:
+			if prefix != "" {
+				out = append(out, attribute{name: prefix, value: n})
+			}
+		}
+	}
+	walk("", tree)
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
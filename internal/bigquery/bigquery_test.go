@@ -0,0 +1,187 @@
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// fakeBigQuery serves the tables API for dataset "ds" of project "p". It
+// starts with a frames table lacking the "blocked" column and an up to date
+// attributes table.
+type fakeBigQuery struct {
+	mu       sync.Mutex
+	schemas  map[string][]field
+	created  []string
+	patched  []string
+	inserted map[string][]row
+	failOnce bool
+}
+
+func newFakeBigQuery() *fakeBigQuery {
+	frames := append([]field(nil), tables[1].fields[:5]...)
+	return &fakeBigQuery{
+		schemas:  map[string][]field{"ad_frames": frames, "ad_attributes": tables[2].fields},
+		inserted: make(map[string][]row),
+	}
+}
+
+func (f *fakeBigQuery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/projects/p/datasets/ds/tables")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	table, insert := strings.CutSuffix(strings.TrimPrefix(rest, "/"), "/insertAll")
+
+	var body struct {
+		TableReference struct {
+			TableID string `json:"tableId"`
+		} `json:"tableReference"`
+		Schema struct {
+			Fields []field `json:"fields"`
+		} `json:"schema"`
+		Rows []row `json:"rows"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch {
+	case r.Method == http.MethodPost && table == "":
+		f.schemas[body.TableReference.TableID] = body.Schema.Fields
+		f.created = append(f.created, body.TableReference.TableID)
+		json.NewEncoder(w).Encode(map[string]any{})
+	case f.schemas[table] == nil:
+		http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]any{"schema": map[string]any{"fields": f.schemas[table]}})
+	case r.Method == http.MethodPatch:
+		f.schemas[table] = body.Schema.Fields
+		f.patched = append(f.patched, table)
+		json.NewEncoder(w).Encode(map[string]any{})
+	case insert:
+		if f.failOnce {
+			f.failOnce = false
+			http.Error(w, "not ready", http.StatusBadRequest)
+			return
+		}
+		f.inserted[table] = append(f.inserted[table], body.Rows...)
+		json.NewEncoder(w).Encode(map[string]any{"kind": "bigquery#tableDataInsertAllResponse"})
+	default:
+		http.Error(w, "unexpected", http.StatusBadRequest)
+	}
+}
+
+func newTestSink(t *testing.T, f *fakeBigQuery) *Sink {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	s := New(func(context.Context) (string, error) { return "tok", nil }, Options{
+		Project:       "p",
+		Dataset:       "ds",
+		TablePrefix:   "ad_",
+		BatchRows:     1000,
+		FlushInterval: time.Hour,
+	})
+	s.base = srv.URL
+	return s
+}
+
+func testJob() Job {
+	return Job{
+		AdID:        "ad1",
+		ExtractedAt: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Segments:    []streams.ASRSegment{{Start: 0, End: 1.5, Text: "Hello", Confidence: 0.9}},
+		Frames: []streams.VLMFrame{
+			{FrameIndex: 0, TimestampSec: 0, Description: "A kitchen"},
+			{FrameIndex: 3, TimestampSec: 2, Blocked: true},
+		},
+		Attributes: map[string]any{
+			"video_meta": &streams.VideoMeta{
+				Container:  "mp4",
+				Video:      &streams.VideoTrack{Codec: "h264", Width: 1080},
+				Provenance: &streams.Provenance{Provider: "ffprobe"},
+			},
+			"audio_analysis": (*streams.AudioAnalysis)(nil),
+		},
+	}
+}
+
+func TestSink_Flush(t *testing.T) {
+	f := newFakeBigQuery()
+	s := newTestSink(t, f)
+	s.Add(testJob())
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(f.created, ",") != "ad_segments" {
+		t.Errorf("created %v, want [ad_segments]", f.created)
+	}
+	if strings.Join(f.patched, ",") != "ad_frames" || len(f.schemas["ad_frames"]) != 6 {
+		t.Errorf("patched %v to %v, want blocked added to ad_frames", f.patched, f.schemas["ad_frames"])
+	}
+	if n := len(f.inserted["ad_segments"]); n != 1 {
+		t.Errorf("%d segment rows, want 1", n)
+	}
+	if frames := f.inserted["ad_frames"]; len(frames) != 2 || frames[1].JSON["blocked"] != true || frames[1].InsertID != "ad1/1748779200000000000/frames/3" {
+		t.Errorf("frame rows = %+v", frames)
+	}
+
+	attrs := map[string]any{}
+	for _, r := range f.inserted["ad_attributes"] {
+		if r.JSON["source"] != "video_meta" {
+			t.Errorf("attribute from %v, want only video_meta", r.JSON["source"])
+		}
+		if r.JSON["extracted_at"] != "2025-06-01 12:00:00 UTC" {
+			t.Errorf("extracted_at = %v", r.JSON["extracted_at"])
+		}
+		attrs[r.JSON["name"].(string)] = r.JSON
+	}
+	for _, name := range []string{"container", "video.codec", "video.width"} {
+		if attrs[name] == nil {
+			t.Errorf("no %s attribute in %v", name, attrs)
+		}
+	}
+	if attrs["provenance.provider"] != nil {
+		t.Errorf("provenance was flattened")
+	}
+	if w := attrs["video.width"].(map[string]any)["number_value"]; w != float64(1080) {
+		t.Errorf("video.width = %v", w)
+	}
+}
+
+func TestSink_KeepsRowsThatFailed(t *testing.T) {
+	f := newFakeBigQuery()
+	f.failOnce = true
+	s := newTestSink(t, f)
+	s.Add(Job{AdID: "ad1", Segments: []streams.ASRSegment{{Text: "a"}}})
+	if err := s.Flush(context.Background()); err == nil {
+		t.Fatal("want the insert error")
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.inserted["ad_segments"]); n != 1 {
+		t.Errorf("%d rows inserted, want the failed one resent", n)
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	s := New(nil, Options{})
+	s.Add(testJob())
+	if s != nil || s.Flush(context.Background()) != nil {
+		t.Error("a sink without a dataset should do nothing")
+	}
+}
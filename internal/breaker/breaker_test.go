@@ -0,0 +1,88 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := New(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("call %d rejected: %v", i, err)
+		}
+		b.Record(false)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s, want open", b.State())
+	}
+	if err := b.Allow(); err != ErrOpen {
+		t.Errorf("Allow err = %v, want ErrOpen", err)
+	}
+}
+
+func TestBreaker_SuccessResetsCount(t *testing.T) {
+	b, _ := newTestBreaker(2, time.Minute)
+
+	b.Allow()
+	b.Record(false)
+	b.Allow()
+	b.Record(true)
+	b.Allow()
+	b.Record(false)
+
+	if b.State() != StateClosed {
+		t.Errorf("state = %s, want closed", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	b.Allow()
+	b.Record(false)
+
+	*now = now.Add(time.Minute)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %s, want half-open", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if err := b.Allow(); err != ErrOpen {
+		t.Errorf("second call during probe err = %v, want ErrOpen", err)
+	}
+
+	// Failed probe re-opens for a full cooldown
+	b.Record(false)
+	if err := b.Allow(); err != ErrOpen {
+		t.Errorf("after failed probe err = %v, want ErrOpen", err)
+	}
+
+	*now = now.Add(time.Minute)
+	b.Allow()
+	b.Record(true)
+	if b.State() != StateClosed {
+		t.Errorf("state = %s, want closed after successful probe", b.State())
+	}
+}
+
+func TestBreaker_ReleaseFreesProbe(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	b.Allow()
+	b.Record(false)
+	*now = now.Add(time.Minute)
+
+	b.Allow()
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Errorf("probe after release rejected: %v", err)
+	}
+}
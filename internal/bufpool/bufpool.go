@@ -0,0 +1,29 @@
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooled is the largest buffer kept for reuse. Bigger ones are left to
+// the GC so that one outsized object does not stay pinned in the pool.
+const maxPooled = 8 << 20
+
+var pool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Get returns an empty buffer, reusing the storage of one previously Put.
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put resets b and makes it available to Get. The caller must not use b, or
+// any slice of its contents, afterwards. A nil b is ignored.
+func Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooled {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
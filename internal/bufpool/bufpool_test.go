@@ -0,0 +1,28 @@
+package bufpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPut_ResetsBuffer(t *testing.T) {
+	b := Get()
+	b.WriteString("frame")
+	Put(b)
+
+	// The pool may or may not hand back the same buffer; either way it is
+	// empty.
+	if got := Get(); got.Len() != 0 {
+		t.Errorf("Get returned %d bytes of stale data", got.Len())
+	}
+}
+
+func TestPut_DropsOversized(t *testing.T) {
+	b := bytes.NewBuffer(make([]byte, 0, maxPooled+1))
+	b.WriteString("x")
+	Put(b)
+	if b.Len() != 1 {
+		t.Error("oversized buffer should be left untouched, not reset and pooled")
+	}
+	Put(nil)
+}
@@ -0,0 +1,83 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Source is the storage needed to assemble a bundle. *r2.Client satisfies it.
+type Source interface {
+	ListKeys(ctx context.Context, prefix string) ([]string, error)
+	DownloadObject(ctx context.Context, key string) ([]byte, error)
+}
+
+// Key returns the R2 key of an ad's bundle.
+func Key(adID string) string {
+	return fmt.Sprintf("ads/%s/extraction/bundle.zip", adID)
+}
+
+// Build zips every artifact under ads/{id}/extraction/ plus the keyframe
+// JPEGs (as thumbnails/) into a single archive. It returns the archive and
+// the archive paths it contains. Entries carry no timestamps so identical
+// inputs produce identical bytes.
+func Build(ctx context.Context, src Source, adID string) ([]byte, []string, error) {
+	extractionPrefix := fmt.Sprintf("ads/%s/extraction/", adID)
+	keyframePrefix := fmt.Sprintf("ads/%s/keyframes/", adID)
+
+	artifactKeys, err := src.ListKeys(ctx, extractionPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyframeKeys, err := src.ListKeys(ctx, keyframePrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	var entries []string
+
+	add := func(key, name string, method uint16) error {
+		data, err := src.DownloadObject(ctx, key)
+		if err != nil {
+			return err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			return fmt.Errorf("zip entry %s: %w", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("zip write %s: %w", name, err)
+		}
+		entries = append(entries, name)
+		return nil
+	}
+
+	for _, key := range artifactKeys {
+		if key == Key(adID) {
+			continue
+		}
+		name := "extraction/" + strings.TrimPrefix(key, extractionPrefix)
+		if err := add(key, name, zip.Deflate); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, key := range keyframeKeys {
+		if !strings.HasSuffix(key, ".jpg") {
+			continue
+		}
+		// JPEGs are already compressed
+		if err := add(key, "thumbnails/"+path.Base(key), zip.Store); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("close zip: %w", err)
+	}
+	return buf.Bytes(), entries, nil
+}
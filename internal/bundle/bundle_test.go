@@ -0,0 +1,76 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type memSource map[string][]byte
+
+func (m memSource) ListKeys(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m memSource) DownloadObject(_ context.Context, key string) ([]byte, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", key)
+	}
+	return data, nil
+}
+
+func TestBuild(t *testing.T) {
+	src := memSource{
+		"ads/a1/extraction/asr_results.json": []byte(`{"segments":[]}`),
+		"ads/a1/extraction/vlm_results.json": []byte(`{"frames":[]}`),
+		"ads/a1/extraction/bundle.zip":       []byte("old bundle"),
+		"ads/a1/keyframes/kf_000.jpg":        []byte("jpeg0"),
+		"ads/a1/keyframes/metadata.json":     []byte(`{}`),
+		"ads/a2/extraction/asr_results.json": []byte(`{}`),
+	}
+
+	data, entries, err := Build(context.Background(), src, "a1")
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	want := []string{
+		"extraction/asr_results.json",
+		"extraction/vlm_results.json",
+		"thumbnails/kf_000.jpg",
+	}
+	if strings.Join(entries, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	if len(zr.File) != 3 {
+		t.Fatalf("zip has %d files", len(zr.File))
+	}
+	f, _ := zr.File[2].Open()
+	got, _ := io.ReadAll(f)
+	if string(got) != "jpeg0" {
+		t.Errorf("thumbnail content = %q", got)
+	}
+
+	again, _, _ := Build(context.Background(), src, "a1")
+	if !bytes.Equal(data, again) {
+		t.Error("bundle bytes should be deterministic")
+	}
+}
@@ -0,0 +1,290 @@
+package cfqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/worker"
+)
+
+const apiBase = "https://api.cloudflare.com/client/v4"
+
+// Client calls the Cloudflare Queues REST API of one account.
+type Client struct {
+	base    string
+	account string
+	token   string
+	http    *http.Client
+}
+
+// New authenticates with an API token that has Queues read and write
+// permission. httpClient may be nil to use http.DefaultClient.
+func New(accountID, apiToken string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{base: apiBase, account: accountID, token: apiToken, http: httpClient}
+}
+
+// Message is a message leased from a pull consumer.
+type Message struct {
+	ID       string          `json:"id"`
+	Body     json.RawMessage `json:"body"`
+	Attempts int             `json:"attempts"`
+	LeaseID  string          `json:"lease_id"`
+}
+
+// Retry returns a leased message to the queue after Delay.
+type Retry struct {
+	LeaseID string
+	Delay   time.Duration
+}
+
+// Pull leases up to batchSize messages, hidden from other consumers for
+// visibility unless acknowledged or retried first.
+func (c *Client) Pull(ctx context.Context, queueID string, batchSize int, visibility time.Duration) ([]Message, error) {
+	var result struct {
+		Messages []Message `json:"messages"`
+	}
+	err := c.call(ctx, queueID, "/messages/pull", map[string]int64{
+		"batch_size":            int64(batchSize),
+		"visibility_timeout_ms": visibility.Milliseconds(),
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("pull: %w", err)
+	}
+	return result.Messages, nil
+}
+
+// Ack removes acked messages from the queue and schedules retries for the
+// others.
+func (c *Client) Ack(ctx context.Context, queueID string, acks []string, retries []Retry) error {
+	type lease struct {
+		LeaseID      string `json:"lease_id"`
+		DelaySeconds int    `json:"delay_seconds,omitempty"`
+	}
+	body := struct {
+		Acks    []lease `json:"acks"`
+		Retries []lease `json:"retries"`
+	}{Acks: []lease{}, Retries: []lease{}}
+	for _, id := range acks {
+		body.Acks = append(body.Acks, lease{LeaseID: id})
+	}
+	for _, r := range retries {
+		body.Retries = append(body.Retries, lease{LeaseID: r.LeaseID, DelaySeconds: int(r.Delay.Seconds())})
+	}
+	if err := c.call(ctx, queueID, "/messages/ack", body, nil); err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	return nil
+}
+
+// Send publishes v as a JSON message.
+func (c *Client) Send(ctx context.Context, queueID string, v any) error {
+	err := c.call(ctx, queueID, "/messages", map[string]any{"body": v, "content_type": "json"}, nil)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// call POSTs to a queue endpoint and decodes the envelope's result into out.
+func (c *Client) call(ctx context.Context, queueID, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/accounts/%s/queues/%s%s", c.base, c.account, queueID, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var env struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if !env.Success || resp.StatusCode >= 300 {
+		if len(env.Errors) > 0 {
+			return fmt.Errorf("status %d: %s (code %d)", resp.StatusCode, env.Errors[0].Message, env.Errors[0].Code)
+		}
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if out != nil && len(env.Result) > 0 {
+		return json.Unmarshal(env.Result, out)
+	}
+	return nil
+}
+
+// Completion is the message written to the results queue for each job.
+type Completion struct {
+	Event  string                   `json:"event"` // "extraction.completed" | "extraction.failed"
+	AdID   string                   `json:"ad_id"`
+	Result *handler.ExtractResponse `json:"result,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// SourceOptions configures a Source.
+type SourceOptions struct {
+	Queue        string        // queue ID jobs are pulled from
+	ResultsQueue string        // queue ID completions are sent to ("" = none)
+	BatchSize    int           // messages leased per pull
+	Visibility   time.Duration // lease length; must cover a whole job
+	PollInterval time.Duration // wait after finding the queue empty
+	RetryDelay   time.Duration // before a failed job is redelivered
+}
+
+// Source is a worker.Source over a Queues pull consumer. Each message is an
+// /extract request body. Jobs that succeed, or fail on an invalid request,
+// are acknowledged and reported to the results queue; other failures are
+// retried, and the queue's retry limit and dead-letter queue decide when to
+// give up. Messages that are not a valid request are dropped.
+type Source struct {
+	c    *Client
+	opts SourceOptions
+
+	mu      sync.Mutex
+	pending []Message
+}
+
+func NewSource(c *Client, opts SourceOptions) *Source {
+	return &Source{c: c, opts: opts}
+}
+
+func (s *Source) Receive(ctx context.Context) (*worker.Delivery, error) {
+	for {
+		msg, err := s.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var req handler.ExtractRequest
+		if err := decodeBody(msg.Body, &req); err != nil {
+			log.Printf("WARN: dropping queue message %s: %v", msg.ID, err)
+			s.ack(msg.LeaseID, nil)
+			continue
+		}
+		return &worker.Delivery{
+			Request: req,
+			Done: func(resp *handler.ExtractResponse, err error) {
+				s.done(msg, req.AdID, resp, err)
+			},
+		}, nil
+	}
+}
+
+// next returns a leased message, pulling a new batch when none are left and
+// polling while the queue is empty or unreachable.
+func (s *Source) next(ctx context.Context) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) == 0 {
+		msgs, err := s.c.Pull(ctx, s.opts.Queue, max(s.opts.BatchSize, 1), s.opts.Visibility)
+		if ctx.Err() != nil {
+			return Message{}, ctx.Err()
+		}
+		if err != nil {
+			// An API hiccup should not stop the worker; try again after a poll
+			log.Printf("WARN: queue %s: %v", s.opts.Queue, err)
+		} else if len(msgs) > 0 {
+			s.pending = msgs
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-time.After(s.opts.PollInterval):
+		}
+	}
+	msg := s.pending[0]
+	s.pending = s.pending[1:]
+	return msg, nil
+}
+
+func (s *Source) done(msg Message, adID string, resp *handler.ExtractResponse, err error) {
+	var jobErr *handler.JobError
+	if err != nil && !(errors.As(err, &jobErr) && jobErr.Status == http.StatusBadRequest) {
+		s.ack("", &Retry{LeaseID: msg.LeaseID, Delay: s.opts.RetryDelay})
+		return
+	}
+
+	if s.opts.ResultsQueue != "" {
+		c := Completion{Event: "extraction.completed", AdID: adID, Result: resp}
+		if err != nil {
+			c.Event, c.Error = "extraction.failed", err.Error()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.c.Send(ctx, s.opts.ResultsQueue, c); err != nil {
+			// Leave the job to be redelivered rather than lose its completion
+			log.Printf("WARN: completion for %s: %v", adID, err)
+			s.ack("", &Retry{LeaseID: msg.LeaseID, Delay: s.opts.RetryDelay})
+			return
+		}
+	}
+	s.ack(msg.LeaseID, nil)
+}
+
+// ack acknowledges or retries one message. A failure only means the lease
+// expires and the message is delivered again.
+func (s *Source) ack(leaseID string, retry *Retry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var acks []string
+	var retries []Retry
+	if leaseID != "" {
+		acks = append(acks, leaseID)
+	}
+	if retry != nil {
+		retries = append(retries, *retry)
+	}
+	if err := s.c.Ack(ctx, s.opts.Queue, acks, retries); err != nil {
+		log.Printf("WARN: queue %s: %v", s.opts.Queue, err)
+	}
+}
+
+// decodeBody reads a message body into v. Pull consumers return JSON
+// messages as JSON, text messages as a string, which may itself hold JSON,
+// and bytes messages base64-encoded.
+func decodeBody(body json.RawMessage, v any) error {
+	var s string
+	if err := json.Unmarshal(body, &s); err != nil {
+		return json.Unmarshal(body, v)
+	}
+	if json.Valid([]byte(s)) {
+		return json.Unmarshal([]byte(s), v)
+	}
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return errors.New("body is neither JSON nor base64")
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,150 @@
+package cfqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+// fakeQueues serves the Queues API for one account, recording acks,
+// retries and sent messages.
+type fakeQueues struct {
+	mu      sync.Mutex
+	pending []Message
+	pulls   int
+	acks    []string
+	retries []string
+	sent    []Completion
+}
+
+func (f *fakeQueues) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []map[string]any{{"code": 10000, "message": "Authentication error"}}})
+		return
+	}
+	var result any
+	switch r.URL.Path {
+	case "/accounts/acct/queues/jobs/messages/pull":
+		f.pulls++
+		result = map[string]any{"messages": f.pending}
+		f.pending = nil
+	case "/accounts/acct/queues/jobs/messages/ack":
+		var body struct {
+			Acks, Retries []struct {
+				LeaseID string `json:"lease_id"`
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, a := range body.Acks {
+			f.acks = append(f.acks, a.LeaseID)
+		}
+		for _, a := range body.Retries {
+			f.retries = append(f.retries, a.LeaseID)
+		}
+	case "/accounts/acct/queues/results/messages":
+		var body struct {
+			Body Completion `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.sent = append(f.sent, body.Body)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": result})
+}
+
+func newTestSource(t *testing.T, f *fakeQueues, token string) *Source {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c := New("acct", token, srv.Client())
+	c.base = srv.URL
+	return NewSource(c, SourceOptions{
+		Queue:        "jobs",
+		ResultsQueue: "results",
+		BatchSize:    10,
+		Visibility:   time.Minute,
+		PollInterval: 10 * time.Millisecond,
+		RetryDelay:   time.Second,
+	})
+}
+
+func TestSource(t *testing.T) {
+	f := &fakeQueues{pending: []Message{
+		{ID: "1", LeaseID: "l1", Body: json.RawMessage(`{"ad_id":"a"}`)},
+		{ID: "2", LeaseID: "l2", Body: json.RawMessage(`"{\"ad_id\":\"b\"}"`)},
+		{ID: "3", LeaseID: "l3", Body: json.RawMessage(`"not a job"`)},
+		{ID: "4", LeaseID: "l4", Body: json.RawMessage(`"eyJhZF9pZCI6ImMifQ=="`)}, // {"ad_id":"c"}
+	}}
+	src := newTestSource(t, f, "tok")
+	ctx := context.Background()
+
+	var ads []string
+	for range 3 {
+		d, err := src.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ads = append(ads, d.Request.AdID)
+		switch d.Request.AdID {
+		case "a":
+			d.Done(&handler.ExtractResponse{AdID: "a"}, nil)
+		case "b":
+			d.Done(nil, &handler.JobError{Status: http.StatusBadRequest, Err: errors.New("no keyframes")})
+		case "c":
+			d.Done(nil, errors.New("deepgram: 502"))
+		}
+	}
+	if strings.Join(ads, ",") != "a,b,c" {
+		t.Errorf("received %v, want [a b c]", ads)
+	}
+	if f.pulls != 1 {
+		t.Errorf("%d pulls, want 1 for the batch", f.pulls)
+	}
+	if strings.Join(f.acks, ",") != "l1,l2,l3" {
+		t.Errorf("acked %v, want [l1 l2 l3]", f.acks)
+	}
+	if strings.Join(f.retries, ",") != "l4" {
+		t.Errorf("retried %v, want [l4]", f.retries)
+	}
+	if len(f.sent) != 2 ||
+		f.sent[0].Event != "extraction.completed" || f.sent[0].Result == nil || f.sent[0].Result.AdID != "a" ||
+		f.sent[1].Event != "extraction.failed" || f.sent[1].AdID != "b" || f.sent[1].Error == "" {
+		t.Errorf("sent %+v", f.sent)
+	}
+}
+
+func TestSource_PollsUntilCancelled(t *testing.T) {
+	f := &fakeQueues{}
+	src := newTestSource(t, f, "wrong")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := src.Receive(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's", err)
+	}
+	if f.pulls != 0 {
+		t.Errorf("unauthorised pulls were served")
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	srv := httptest.NewServer(&fakeQueues{})
+	defer srv.Close()
+	c := New("acct", "wrong", srv.Client())
+	c.base = srv.URL
+	_, err := c.Pull(context.Background(), "jobs", 1, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "Authentication error") {
+		t.Errorf("err = %v, want the API's message", err)
+	}
+}
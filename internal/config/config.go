@@ -1,6 +1,21 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/hooks"
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+)
 
 type Config struct {
 	// R2 / S3
@@ -13,8 +28,325 @@ type Config struct {
 	DeepgramAPIKey string
 	GeminiAPIKey   string
 
-	// Server
-	Port string
+	// Deepgram query parameters set on every transcription, on top of the
+	// built-in ones (an empty value removes one)
+	DeepgramParams map[string]string
+
+	// Models requests may choose with asr_model and vlm_model, besides
+	// the defaults
+	DeepgramModels []string
+	GeminiModels   []string
+
+	// Self-hosted Deepgram: DeepgramURL replaces the cloud API, DeepgramAuth
+	// is how the key is sent ("token", "bearer" or "none"), and the TLS
+	// settings trust a private CA or present a client certificate
+	DeepgramURL           string
+	DeepgramAuth          string
+	DeepgramTLSCAFile     string
+	DeepgramTLSCertFile   string
+	DeepgramTLSKeyFile    string
+	DeepgramTLSSkipVerify bool
+
+	// Gemini through Vertex AI instead of the API-key endpoint: with
+	// VertexProject set, requests go to VertexLocation's endpoint ("global"
+	// or a region) authenticated as GoogleCredentialsFile, or the metadata
+	// server's service account without one
+	VertexProject  string
+	VertexLocation string
+
+	// Local development: answer every provider call with canned results,
+	// optionally overridden by fixture files, instead of calling out
+	MockProviders bool
+	MockFixtures  string
+
+	// Local development and end-to-end tests: keep objects in an in-memory
+	// S3 store inside the process instead of R2, seeded from the files
+	// under FakeStorageDir (laid out as in the bucket, e.g. ads/{id}/video.mp4)
+	FakeStorage    bool
+	FakeStorageDir string
+
+	// Write every provider request/response pair, sanitized, to this
+	// directory as replay fixtures ("" = off)
+	ProviderRecordDir string
+
+	// Gemini budgets shared by all jobs (0 = unlimited). With RedisURL set the
+	// budget is enforced across every instance.
+	GeminiRPM int
+	GeminiTPM int
+	RedisURL  string
+
+	// Deepgram circuit breaker: open after this many consecutive failures
+	// (0 disables), probe again after the cooldown
+	DeepgramBreakerThreshold int
+	DeepgramBreakerCooldown  time.Duration
+
+	// Provider health: a provider whose error rate over the window reaches
+	// the threshold (0 disables) after at least the minimum number of calls
+	// is skipped until failures age out
+	ProviderHealthWindow     time.Duration
+	ProviderHealthErrorRate  float64
+	ProviderHealthMinSamples int
+
+	// Connection pool and HTTP/2 settings per provider. Zero fields keep
+	// each provider's built-in defaults.
+	DeepgramTransport httpclient.Options
+	GeminiTransport   httpclient.Options
+	R2Transport       httpclient.Options
+
+	// Retry policies per provider: total attempts and initial backoff
+	DeepgramRetryAttempts int
+	DeepgramRetryDelay    time.Duration
+	GeminiRetryAttempts   int
+	GeminiRetryDelay      time.Duration
+	R2RetryAttempts       int
+	R2RetryDelay          time.Duration
+
+	// VLM: consecutive keyframes sent per Gemini request (1 = one per frame),
+	// and a cap on keyframes described per ad (0 = all) with the strategy
+	// used to pick them ("entropy" or "even")
+	VLMBatchSize      int
+	VLMMaxFrames      int
+	VLMFrameSelection string
+
+	// Continuity given to each VLM prompt: "previous_frame" or
+	// "rolling_summary", condensed every VLMSummaryEvery frames
+	VLMContext      string
+	VLMSummaryEvery int
+
+	// Ask the VLM to transcribe and translate on-screen text when ASR
+	// detects a language other than English; VLM then waits for ASR
+	VLMMultilingual bool
+
+	// Ads without keyframes/metadata.json get keyframes extracted with
+	// ffmpeg instead of skipping VLM: "" (off), "scene" (a frame per scene
+	// change scoring over KeyframeSceneThreshold) or "interval" (a frame
+	// every KeyframeInterval), at most KeyframeMaxFrames per ad
+	KeyframeFallback       string
+	KeyframeSceneThreshold float64
+	KeyframeInterval       time.Duration
+	KeyframeMaxFrames      int
+
+	// Upper bound on one VLM Gemini request, retries included (0 = none)
+	GeminiFrameTimeout time.Duration
+
+	// A second VLM request is sent for a frame whose first has not answered
+	// within this long; the first answer wins (0 = no hedging)
+	GeminiHedgeAfter time.Duration
+
+	// Batch-priority jobs: how often a submitted Gemini batch is polled and
+	// how long the whole job may take
+	GeminiBatchPollInterval time.Duration
+	GeminiBatchTimeout      time.Duration
+
+	// Keyframes larger than this go through the Gemini Files API instead of
+	// being inlined as base64 (0 = always inline)
+	GeminiFileThresholdKB int
+
+	// VLM instructions of at least GeminiCacheMinTokens are put in a Gemini
+	// context cache for the job, living GeminiCacheTTL past its last
+	// extension (0 = no caching)
+	GeminiCacheTTL       time.Duration
+	GeminiCacheMinTokens int
+
+	// Gemini tokens one job may use; per-frame analyses stop taking frames
+	// once it is spent (0 = no ceiling)
+	JobTokenBudget int
+
+	// Quality: jobs scoring below this are flagged for review
+	QualityFlagThreshold float64
+
+	// Outputs
+	BundleArtifacts bool // write extraction/bundle.zip by default
+
+	// What happens to a JSON artifact that does not match its schema:
+	// "reject" (the stream fails and nothing is stored), "warn" (stored
+	// and logged) or "off" (not checked)
+	ArtifactValidation string
+
+	// Optional analysis streams run in every job, by name (see
+	// AnalysisStreamNames); each costs extra provider calls or compute.
+	// Unset, DefaultAnalysisStreams run; "none" runs none.
+	AnalysisStreams []string
+
+	// The content_rating stream asks Gemini unless ContentRatingURL names a
+	// moderation API; jobs whose ad rates ContentRatingQuarantine or worse
+	// are quarantined
+	ContentRatingURL        string
+	ContentRatingQuarantine string
+
+	// Post-processing hooks run after every stream, before the bundle:
+	// PostHooks names built-in transforms ("merged,srt,vtt,csv") and
+	// TransformURL, if set, receives every job's results and returns an
+	// artifact to store
+	PostHooks        []string
+	TransformURL     string
+	TransformTimeout time.Duration
+
+	// Webhooks
+	WebhookURL    string        // default receiver; requests may override
+	WebhookURLTTL time.Duration // lifetime of presigned artifact URLs
+
+	// Webhooks are signed with the request's tenant's secret in
+	// WebhookTenantSecrets, or else WebhookSecret ("" = unsigned)
+	WebhookSecret        string
+	WebhookTenantSecrets map[string]string
+
+	// Download links (GET /results/{ad_id}/download): lifetime of the
+	// presigned URLs handed out, and the longest a caller may ask for. R2
+	// honours presigned URLs for at most seven days.
+	DownloadURLTTL    time.Duration
+	DownloadURLMaxTTL time.Duration
+
+	// Chat alerts (Slack or Discord incoming webhook): an ad whose jobs fail
+	// NotifyFailureThreshold times in a row, and finished backfills.
+	// PublicURL is where this service is reached, for links to the web UI.
+	NotifyWebhookURL       string
+	NotifyFailureThreshold int
+	PublicURL              string
+
+	// API authentication (POST /extract, PUT /scale): requests must present
+	// one of APIKeys or a JWT issued by OIDCIssuer for OIDCAudience, checked
+	// against the keys at OIDCJWKSURL or those the issuer's discovery
+	// document lists. With neither set the API is open.
+	APIKeys      []string
+	OIDCIssuer   string
+	OIDCAudience string
+	OIDCJWKSURL  string
+
+	// Managed API keys, kept in R2 and created or revoked through
+	// /admin/keys, each with its own scopes, tenant and rate limit. APIKeys
+	// and OIDC tokens have every scope, so one of them is needed to manage
+	// keys.
+	ManagedAPIKeys bool
+
+	// CORS: browser origins ("*" = any) allowed to call the API, with the
+	// methods and request headers they may use and how long browsers may
+	// cache a preflight. No origins = no CORS headers.
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+	CORSMaxAge         time.Duration
+
+	// Request body limits: every route's, and that of the routes that take
+	// a video directly
+	MaxJSONBodyKB int
+	MaxUploadMB   int
+
+	// Client address allowlists (CIDRs or addresses; empty = anyone) for the
+	// extraction API (POST /extract, DELETE /jobs/{id}, POST /compare) and the
+	// admin endpoints (/scale, /admin/keys). Behind TrustedProxies the client
+	// is taken from X-Forwarded-For.
+	ExtractAllowedIPs []string
+	AdminAllowedIPs   []string
+	TrustedProxies    []string
+
+	// TLS: with TLSCertFile and TLSKeyFile set the server speaks HTTPS.
+	// With TLSClientCAFile set as well, the API endpoints above also need a
+	// client certificate signed by one of its CAs.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	// Storage events (POST /events/storage), served only when enabled:
+	// bearer token senders must present, and how long an uploaded video
+	// waits for keyframes before its job starts anyway when
+	// KeyframeFallback is set
+	StorageEvents     bool
+	EventsToken       string
+	EventKeyframeWait time.Duration
+
+	// Videos fetched from a request's video_url: the hosts allowed (empty
+	// for any; "*.example.com" for subdomains) and how long the download
+	// may take. Their size is capped at MaxUploadMB.
+	VideoURLAllowedHosts []string
+	VideoURLTimeout      time.Duration
+
+	// Cloudflare Queues (cmd/worker -source cfqueue): jobs are pulled from
+	// CFQueueID and, if CFResultsQueueID is set, a completion message per
+	// job is sent there. A pulled job stays hidden from other consumers for
+	// CFQueueVisibility; a failed one is redelivered after CFQueueRetryDelay.
+	CFAccountID         string
+	CFAPIToken          string
+	CFQueueID           string
+	CFResultsQueueID    string
+	CFQueueBatchSize    int
+	CFQueueVisibility   time.Duration
+	CFQueuePollInterval time.Duration
+	CFQueueRetryDelay   time.Duration
+
+	// Temporal: with ExecutionMode "temporal" the server starts every job as
+	// a workflow on TemporalTaskQueue instead of running it, and workers
+	// started with -source temporal run them. A stream is tried
+	// TemporalStepAttempts times before it is reported as errored.
+	ExecutionMode        string
+	TemporalAddress      string
+	TemporalNamespace    string
+	TemporalTaskQueue    string
+	TemporalStepAttempts int
+
+	// NATS JetStream: workers started with -source nats pull jobs from
+	// NATSStream through the durable consumer NATSConsumer, optionally
+	// filtered to NATSSubject. A job is acknowledged once its artifacts are
+	// stored; one that fails is redelivered after NATSRetryDelay, at most
+	// NATSMaxDeliver times. With NATSEventsPrefix set, the server and workers
+	// publish {prefix}.stream.{name} as each stream finishes, and workers
+	// {prefix}.job as each job they pulled does.
+	NATSURL          string
+	NATSStream       string
+	NATSConsumer     string
+	NATSSubject      string
+	NATSAckWait      time.Duration
+	NATSMaxDeliver   int
+	NATSRetryDelay   time.Duration
+	NATSEventsPrefix string
+
+	// BigQuery sink: with BigQueryDataset set, every job's segments, frames
+	// and attributes are streamed into tables there, batched by
+	// BigQueryBatchRows or BigQueryFlushInterval. GoogleCredentialsFile is a
+	// service account key; without one the metadata server is asked.
+	BigQueryProject       string
+	BigQueryDataset       string
+	BigQueryTablePrefix   string
+	BigQueryBatchRows     int
+	BigQueryFlushInterval time.Duration
+	GoogleCredentialsFile string
+
+	// StatsD push (alongside /metrics): StatsDAddr is host:port, defaulting
+	// to a Datadog agent at DD_AGENT_HOST when that is set. StatsDFormat is
+	// "dogstatsd" (tagged) or "statsd"; StatsDTags ("env:prod,tenant:acme")
+	// go on every metric.
+	StatsDAddr     string
+	StatsDFormat   string
+	StatsDPrefix   string
+	StatsDTags     string
+	StatsDInterval time.Duration
+
+	// Admission control: projected memory of running jobs is kept under the
+	// budget (0 = unlimited); jobs wait up to AdmissionWait for room, then
+	// get a 503. AdmissionFrameKB is the assumed size of one keyframe.
+	MemoryBudgetMB   int
+	AdmissionWait    time.Duration
+	AdmissionFrameKB int
+
+	// Startup: open provider connections at boot, optionally treating a
+	// rejected API key as a warning-worthy failure
+	WarmUp       bool
+	WarmUpVerify bool
+
+	// Server: port and how many extraction jobs run at once (adjustable at
+	// runtime through PUT /scale)
+	Port    string
+	Workers int
+
+	// GRPCPort, if set, serves the gRPC API on this port alongside HTTP
+	GRPCPort string
+
+	// Heartbeat period for /extract requests that accept JSON lines
+	ProgressInterval time.Duration
+
+	// /readyz fails once the job at the head of the queue has waited this
+	// long, meaning running jobs have stopped finishing (0 = never)
+	ReadyQueueStall time.Duration
 }
 
 func Load() *Config {
@@ -27,8 +359,340 @@ func Load() *Config {
 		DeepgramAPIKey: getenv("DEEPGRAM_API_KEY", ""),
 		GeminiAPIKey:   getenv("GEMINI_API_KEY", ""),
 
-		Port: getenv("PORT", "8080"),
+		DeepgramParams: getenvMap("DEEPGRAM_PARAMS"),
+		DeepgramModels: getenvListOr("DEEPGRAM_MODELS", "nova-3", "nova-2"),
+		GeminiModels:   getenvListOr("GEMINI_MODELS", "gemini-2.0-flash", "gemini-1.5-pro"),
+
+		DeepgramURL:           getenv("DEEPGRAM_URL", ""),
+		DeepgramAuth:          getenv("DEEPGRAM_AUTH", "token"),
+		DeepgramTLSCAFile:     getenv("DEEPGRAM_TLS_CA_FILE", ""),
+		DeepgramTLSCertFile:   getenv("DEEPGRAM_TLS_CERT_FILE", ""),
+		DeepgramTLSKeyFile:    getenv("DEEPGRAM_TLS_KEY_FILE", ""),
+		DeepgramTLSSkipVerify: getenvBool("DEEPGRAM_TLS_INSECURE_SKIP_VERIFY", false),
+
+		VertexProject:  getenv("VERTEX_PROJECT", ""),
+		VertexLocation: getenv("VERTEX_LOCATION", "us-central1"),
+
+		MockProviders: getenvBool("MOCK_PROVIDERS", false),
+		MockFixtures:  getenv("MOCK_FIXTURES", ""),
+
+		FakeStorage:    getenvBool("FAKE_STORAGE", false),
+		FakeStorageDir: getenv("FAKE_STORAGE_DIR", ""),
+
+		ProviderRecordDir: getenv("PROVIDER_RECORD_DIR", ""),
+
+		GeminiRPM: getenvInt("GEMINI_RPM", 0),
+		GeminiTPM: getenvInt("GEMINI_TPM", 0),
+		RedisURL:  getenv("REDIS_URL", ""),
+
+		DeepgramBreakerThreshold: getenvInt("DEEPGRAM_BREAKER_THRESHOLD", 5),
+		DeepgramBreakerCooldown:  getenvDuration("DEEPGRAM_BREAKER_COOLDOWN", 30*time.Second),
+
+		ProviderHealthWindow:     getenvDuration("PROVIDER_HEALTH_WINDOW", time.Minute),
+		ProviderHealthErrorRate:  getenvFloat("PROVIDER_HEALTH_ERROR_RATE", 0.5),
+		ProviderHealthMinSamples: getenvInt("PROVIDER_HEALTH_MIN_SAMPLES", 10),
+
+		DeepgramTransport: getenvTransport("DEEPGRAM"),
+		GeminiTransport:   getenvTransport("GEMINI"),
+		R2Transport:       getenvTransport("R2"),
+
+		DeepgramRetryAttempts: getenvInt("DEEPGRAM_RETRY_ATTEMPTS", 3),
+		DeepgramRetryDelay:    getenvDuration("DEEPGRAM_RETRY_DELAY", 2*time.Second),
+		GeminiRetryAttempts:   getenvInt("GEMINI_RETRY_ATTEMPTS", 3),
+		GeminiRetryDelay:      getenvDuration("GEMINI_RETRY_DELAY", 500*time.Millisecond),
+		R2RetryAttempts:       getenvInt("R2_RETRY_ATTEMPTS", 3),
+		R2RetryDelay:          getenvDuration("R2_RETRY_DELAY", 200*time.Millisecond),
+
+		VLMBatchSize:      getenvInt("VLM_BATCH_SIZE", 1),
+		VLMMaxFrames:      getenvInt("VLM_MAX_FRAMES", 0),
+		VLMFrameSelection: getenv("VLM_FRAME_SELECTION", "entropy"),
+
+		VLMContext:      getenv("VLM_CONTEXT", "previous_frame"),
+		VLMSummaryEvery: getenvInt("VLM_SUMMARY_EVERY", 5),
+		VLMMultilingual: getenvBool("VLM_MULTILINGUAL", false),
+
+		KeyframeFallback:       getenv("KEYFRAME_FALLBACK", ""),
+		KeyframeSceneThreshold: getenvFloat("KEYFRAME_SCENE_THRESHOLD", 0.3),
+		KeyframeInterval:       getenvDuration("KEYFRAME_INTERVAL", 2*time.Second),
+		KeyframeMaxFrames:      getenvInt("KEYFRAME_MAX_FRAMES", 60),
+
+		GeminiFrameTimeout:    getenvDuration("GEMINI_FRAME_TIMEOUT", 30*time.Second),
+		GeminiFileThresholdKB: getenvInt("GEMINI_FILE_THRESHOLD_KB", 1024),
+		GeminiCacheTTL:        getenvDuration("GEMINI_CACHE_TTL", 10*time.Minute),
+		GeminiCacheMinTokens:  getenvInt("GEMINI_CACHE_MIN_TOKENS", 4096),
+		JobTokenBudget:        getenvInt("JOB_TOKEN_BUDGET", 0),
+		GeminiHedgeAfter:      getenvDuration("GEMINI_HEDGE_AFTER", 0),
+
+		GeminiBatchPollInterval: getenvDuration("GEMINI_BATCH_POLL_INTERVAL", 30*time.Second),
+		GeminiBatchTimeout:      getenvDuration("GEMINI_BATCH_TIMEOUT", 24*time.Hour),
+
+		QualityFlagThreshold: getenvFloat("QUALITY_FLAG_THRESHOLD", 0.6),
+
+		BundleArtifacts:    getenvBool("BUNDLE_ARTIFACTS", false),
+		ArtifactValidation: getenv("ARTIFACT_VALIDATION", "reject"),
+
+		AnalysisStreams: getenvListOr("ANALYSIS_STREAMS", DefaultAnalysisStreams...),
+
+		ContentRatingURL:        getenv("CONTENT_RATING_URL", ""),
+		ContentRatingQuarantine: getenv("CONTENT_RATING_QUARANTINE", "explicit"),
+
+		PostHooks:        getenvList("POST_HOOKS"),
+		TransformURL:     getenv("TRANSFORM_URL", ""),
+		TransformTimeout: getenvDuration("TRANSFORM_TIMEOUT", 30*time.Second),
+
+		WebhookURL:    getenv("WEBHOOK_URL", ""),
+		WebhookURLTTL: getenvDuration("WEBHOOK_URL_TTL", 15*time.Minute),
+
+		WebhookSecret:        getenv("WEBHOOK_SECRET", ""),
+		WebhookTenantSecrets: getenvMap("WEBHOOK_TENANT_SECRETS"),
+
+		DownloadURLTTL:    getenvDuration("DOWNLOAD_URL_TTL", 24*time.Hour),
+		DownloadURLMaxTTL: getenvDuration("DOWNLOAD_URL_MAX_TTL", 7*24*time.Hour),
+
+		NotifyWebhookURL:       getenv("NOTIFY_WEBHOOK_URL", ""),
+		NotifyFailureThreshold: getenvInt("NOTIFY_FAILURE_THRESHOLD", 3),
+		PublicURL:              getenv("PUBLIC_URL", ""),
+
+		APIKeys:      getenvList("API_KEYS"),
+		OIDCIssuer:   getenv("OIDC_ISSUER", ""),
+		OIDCAudience: getenv("OIDC_AUDIENCE", ""),
+		OIDCJWKSURL:  getenv("OIDC_JWKS_URL", ""),
+
+		ManagedAPIKeys: getenvBool("MANAGED_API_KEYS", false),
+
+		CORSAllowedOrigins: getenvList("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods: getenvListOr("CORS_ALLOWED_METHODS", "GET", "POST", "PUT", "DELETE"),
+		CORSAllowedHeaders: getenvListOr("CORS_ALLOWED_HEADERS", "Authorization", "Content-Type", "Accept", "X-API-Key"),
+		CORSMaxAge:         getenvDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		MaxJSONBodyKB: getenvInt("MAX_JSON_BODY_KB", 1024),
+		MaxUploadMB:   getenvInt("MAX_UPLOAD_MB", 2048),
+
+		ExtractAllowedIPs: getenvList("EXTRACT_ALLOWED_IPS"),
+		AdminAllowedIPs:   getenvList("ADMIN_ALLOWED_IPS"),
+		TrustedProxies:    getenvList("TRUSTED_PROXIES"),
+
+		TLSCertFile:     getenv("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getenv("TLS_KEY_FILE", ""),
+		TLSClientCAFile: getenv("TLS_CLIENT_CA_FILE", ""),
+
+		StorageEvents:     getenvBool("STORAGE_EVENTS", false),
+		EventsToken:       getenv("EVENTS_TOKEN", ""),
+		EventKeyframeWait: getenvDuration("EVENT_KEYFRAME_WAIT", 10*time.Minute),
+
+		VideoURLAllowedHosts: getenvList("VIDEO_URL_ALLOWED_HOSTS"),
+		VideoURLTimeout:      getenvDuration("VIDEO_URL_TIMEOUT", 5*time.Minute),
+
+		CFAccountID:         getenv("CF_ACCOUNT_ID", ""),
+		CFAPIToken:          getenv("CF_API_TOKEN", ""),
+		CFQueueID:           getenv("CF_QUEUE_ID", ""),
+		CFResultsQueueID:    getenv("CF_RESULTS_QUEUE_ID", ""),
+		CFQueueBatchSize:    getenvInt("CF_QUEUE_BATCH_SIZE", 1),
+		CFQueueVisibility:   getenvDuration("CF_QUEUE_VISIBILITY", 15*time.Minute),
+		CFQueuePollInterval: getenvDuration("CF_QUEUE_POLL_INTERVAL", 5*time.Second),
+		CFQueueRetryDelay:   getenvDuration("CF_QUEUE_RETRY_DELAY", time.Minute),
+
+		ExecutionMode:        getenv("EXECUTION_MODE", "local"),
+		TemporalAddress:      getenv("TEMPORAL_ADDRESS", "localhost:7233"),
+		TemporalNamespace:    getenv("TEMPORAL_NAMESPACE", "default"),
+		TemporalTaskQueue:    getenv("TEMPORAL_TASK_QUEUE", "video-extraction"),
+		TemporalStepAttempts: getenvInt("TEMPORAL_STEP_ATTEMPTS", 3),
+
+		NATSURL:          getenv("NATS_URL", ""),
+		NATSStream:       getenv("NATS_STREAM", "EXTRACTION"),
+		NATSConsumer:     getenv("NATS_CONSUMER", "video-extraction"),
+		NATSSubject:      getenv("NATS_SUBJECT", ""),
+		NATSAckWait:      getenvDuration("NATS_ACK_WAIT", 5*time.Minute),
+		NATSMaxDeliver:   getenvInt("NATS_MAX_DELIVER", 5),
+		NATSRetryDelay:   getenvDuration("NATS_RETRY_DELAY", time.Minute),
+		NATSEventsPrefix: getenv("NATS_EVENTS_PREFIX", ""),
+
+		BigQueryProject:       getenv("BIGQUERY_PROJECT", ""),
+		BigQueryDataset:       getenv("BIGQUERY_DATASET", ""),
+		BigQueryTablePrefix:   getenv("BIGQUERY_TABLE_PREFIX", ""),
+		BigQueryBatchRows:     getenvInt("BIGQUERY_BATCH_ROWS", 500),
+		BigQueryFlushInterval: getenvDuration("BIGQUERY_FLUSH_INTERVAL", 10*time.Second),
+		GoogleCredentialsFile: getenv("GOOGLE_APPLICATION_CREDENTIALS", ""),
+
+		StatsDAddr:     getenv("STATSD_ADDR", agentAddr()),
+		StatsDFormat:   getenv("STATSD_FORMAT", "dogstatsd"),
+		StatsDPrefix:   getenv("STATSD_PREFIX", "pipeline."),
+		StatsDTags:     getenv("STATSD_TAGS", ""),
+		StatsDInterval: getenvDuration("STATSD_INTERVAL", 10*time.Second),
+
+		MemoryBudgetMB:   getenvInt("MEMORY_BUDGET_MB", 0),
+		AdmissionWait:    getenvDuration("ADMISSION_WAIT", 30*time.Second),
+		AdmissionFrameKB: getenvInt("ADMISSION_FRAME_KB", 512),
+
+		WarmUp:       getenvBool("WARMUP", true),
+		WarmUpVerify: getenvBool("WARMUP_VERIFY", true),
+
+		Port:    getenv("PORT", "8080"),
+		Workers: getenvInt("WORKERS", 8),
+
+		GRPCPort: os.Getenv("GRPC_PORT"),
+
+		ProgressInterval: getenvDuration("PROGRESS_INTERVAL", 15*time.Second),
+
+		ReadyQueueStall: getenvDuration("READY_QUEUE_STALL", 10*time.Minute),
+	}
+}
+
+// DeepgramConfigured reports whether ASR can run: with an API key, or
+// against a self-hosted Deepgram that does not authenticate.
+func (c *Config) DeepgramConfigured() bool {
+	return c.DeepgramAPIKey != "" || (c.DeepgramURL != "" && c.DeepgramAuth == "none")
+}
+
+// GeminiConfigured reports whether Gemini can be called, with an API key or
+// through Vertex AI.
+func (c *Config) GeminiConfigured() bool {
+	return c.GeminiAPIKey != "" || c.VertexProject != ""
+}
+
+// Validate reports settings that would keep jobs from running as intended.
+// Load never fails, so a bad value is otherwise only noticed job by job.
+func (c *Config) Validate() error {
+	var errs []error
+	if (c.R2EndpointURL == "" && !c.FakeStorage) || c.R2Bucket == "" {
+		errs = append(errs, errors.New("R2_ENDPOINT_URL and R2_BUCKET are required"))
+	}
+	if !c.DeepgramConfigured() && !c.GeminiConfigured() && !c.MockProviders {
+		errs = append(errs, errors.New("neither DEEPGRAM_API_KEY nor GEMINI_API_KEY (or VERTEX_PROJECT) is set"))
+	}
+	if c.DeepgramURL != "" {
+		if u, err := url.Parse(c.DeepgramURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("DEEPGRAM_URL %q is not an absolute http(s) URL", c.DeepgramURL))
+		}
+	}
+	for k := range c.DeepgramParams {
+		if k == "callback" || k == "callback_method" {
+			errs = append(errs, fmt.Errorf("DEEPGRAM_PARAMS: %q is not supported; results are read from the response", k))
+		}
+	}
+	if c.DeepgramAuth != "token" && c.DeepgramAuth != "bearer" && c.DeepgramAuth != "none" {
+		errs = append(errs, fmt.Errorf(`DEEPGRAM_AUTH %q is not "token", "bearer" or "none"`, c.DeepgramAuth))
+	}
+	if (c.DeepgramTLSCertFile == "") != (c.DeepgramTLSKeyFile == "") {
+		errs = append(errs, errors.New("DEEPGRAM_TLS_CERT_FILE and DEEPGRAM_TLS_KEY_FILE must be set together"))
+	}
+	if c.VLMFrameSelection != "entropy" && c.VLMFrameSelection != "even" {
+		errs = append(errs, fmt.Errorf(`VLM_FRAME_SELECTION %q is not "entropy" or "even"`, c.VLMFrameSelection))
+	}
+	if c.VLMContext != "previous_frame" && c.VLMContext != "rolling_summary" {
+		errs = append(errs, fmt.Errorf(`VLM_CONTEXT %q is not "previous_frame" or "rolling_summary"`, c.VLMContext))
+	}
+	if c.ArtifactValidation != "reject" && c.ArtifactValidation != "warn" && c.ArtifactValidation != "off" {
+		errs = append(errs, fmt.Errorf(`ARTIFACT_VALIDATION %q is not "reject", "warn" or "off"`, c.ArtifactValidation))
+	}
+	switch c.KeyframeFallback {
+	case "":
+	case "scene", "interval":
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			errs = append(errs, fmt.Errorf("KEYFRAME_FALLBACK is %q but ffmpeg is not installed", c.KeyframeFallback))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(`KEYFRAME_FALLBACK %q is not "scene" or "interval"`, c.KeyframeFallback))
+	}
+	if c.ExecutionMode != "local" && c.ExecutionMode != "temporal" {
+		errs = append(errs, fmt.Errorf(`EXECUTION_MODE %q is not "local" or "temporal"`, c.ExecutionMode))
+	}
+	if c.OIDCIssuer == "" && (c.OIDCAudience != "" || c.OIDCJWKSURL != "") {
+		errs = append(errs, errors.New("OIDC_AUDIENCE or OIDC_JWKS_URL is set but OIDC_ISSUER is not"))
+	}
+	if c.ManagedAPIKeys && len(c.APIKeys) == 0 && c.OIDCIssuer == "" {
+		errs = append(errs, errors.New("MANAGED_API_KEYS needs API_KEYS or OIDC_ISSUER: managing keys takes a credential with every scope"))
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "" || u.Path != "") {
+			errs = append(errs, fmt.Errorf(`CORS_ALLOWED_ORIGINS: %q is not "*" or an origin such as https://dash.example.com`, origin))
+		}
+	}
+	if c.DownloadURLTTL <= 0 || c.DownloadURLTTL > c.DownloadURLMaxTTL || c.DownloadURLMaxTTL > 7*24*time.Hour {
+		errs = append(errs, errors.New("DOWNLOAD_URL_TTL must be positive and no longer than DOWNLOAD_URL_MAX_TTL, which is at most 168h"))
+	}
+	if c.MaxJSONBodyKB < 1 || c.MaxUploadMB < 1 {
+		errs = append(errs, errors.New("MAX_JSON_BODY_KB and MAX_UPLOAD_MB must be positive"))
+	}
+	if c.VideoURLTimeout <= 0 {
+		errs = append(errs, errors.New("VIDEO_URL_TIMEOUT must be positive"))
+	}
+	for _, l := range []struct {
+		key  string
+		list []string
+	}{
+		{"EXTRACT_ALLOWED_IPS", c.ExtractAllowedIPs},
+		{"ADMIN_ALLOWED_IPS", c.AdminAllowedIPs},
+		{"TRUSTED_PROXIES", c.TrustedProxies},
+	} {
+		if _, err := auth.ParsePrefixes(l.list); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.key, err))
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, errors.New("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE: client certificates are only asked for over HTTPS"))
+	}
+	if c.StorageEvents && c.EventsToken == "" {
+		errs = append(errs, errors.New("STORAGE_EVENTS is set but EVENTS_TOKEN is not: storage events start paid jobs"))
+	}
+	if c.NATSEventsPrefix != "" && c.NATSURL == "" {
+		errs = append(errs, errors.New("NATS_EVENTS_PREFIX is set but NATS_URL is not"))
+	}
+	if c.StatsDFormat != "dogstatsd" && c.StatsDFormat != "statsd" {
+		errs = append(errs, fmt.Errorf(`STATSD_FORMAT %q is not "dogstatsd" or "statsd"`, c.StatsDFormat))
+	}
+	for _, name := range c.AnalysisStreams {
+		if !slices.Contains(AnalysisStreamNames, name) && name != "none" {
+			errs = append(errs, fmt.Errorf("ANALYSIS_STREAMS: unknown stream %q (want one of %s, or none)", name, strings.Join(AnalysisStreamNames, ", ")))
+		}
+	}
+	for _, name := range []string{"key_moments", "summary"} {
+		if slices.Contains(c.AnalysisStreams, name) && !slices.Contains(c.AnalysisStreams, "timeline") {
+			errs = append(errs, fmt.Errorf("ANALYSIS_STREAMS: %s needs timeline", name))
+		}
+	}
+	if c.ContentRatingURL != "" {
+		if u, err := url.Parse(c.ContentRatingURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("CONTENT_RATING_URL %q is not an absolute http(s) URL", c.ContentRatingURL))
+		}
 	}
+	switch c.ContentRatingQuarantine {
+	case "suggestive", "explicit", "none":
+	default:
+		errs = append(errs, fmt.Errorf(`CONTENT_RATING_QUARANTINE %q is not "suggestive", "explicit" or "none"`, c.ContentRatingQuarantine))
+	}
+	for _, name := range c.PostHooks {
+		if _, ok := hooks.Builtin(name); !ok {
+			errs = append(errs, fmt.Errorf(`POST_HOOKS: unknown hook %q (want "merged", "srt", "vtt" or "csv")`, name))
+		}
+	}
+	if c.TransformURL != "" {
+		if u, err := url.Parse(c.TransformURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("TRANSFORM_URL %q is not an absolute http(s) URL", c.TransformURL))
+		}
+	}
+	if c.BigQueryDataset != "" && c.BigQueryProject == "" {
+		errs = append(errs, errors.New("BIGQUERY_DATASET is set but BIGQUERY_PROJECT is not"))
+	}
+	return errors.Join(errs...)
+}
+
+// AnalysisStreamNames are the streams ANALYSIS_STREAMS can enable.
+var AnalysisStreamNames = []string{"video_meta", "audio_analysis", "timeline", "key_moments", "summary", "people", "presenter", "visual_stats", "content_rating", "products", "cta", "music", "hook_analysis", "entities"}
+
+// DefaultAnalysisStreams run when ANALYSIS_STREAMS is unset.
+var DefaultAnalysisStreams = []string{"video_meta", "audio_analysis", "timeline", "key_moments", "summary"}
+
+// agentAddr is the DogStatsD address of a Datadog agent announced through
+// DD_AGENT_HOST, as the agent's Kubernetes setup does.
+func agentAddr() string {
+	if host := os.Getenv("DD_AGENT_HOST"); host != "" {
+		return net.JoinHostPort(host, getenv("DD_DOGSTATSD_PORT", "8125"))
+	}
+	return ""
 }
 
 func getenv(key, fallback string) string {
@@ -37,3 +701,83 @@ func getenv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getenvList splits a comma-separated list, dropping empty entries.
+func getenvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// getenvListOr is getenvList with a default for an unset or empty list.
+func getenvListOr(key string, fallback ...string) []string {
+	if list := getenvList(key); len(list) > 0 {
+		return list
+	}
+	return fallback
+}
+
+// getenvMap reads comma-separated key=value pairs, dropping entries without
+// a key.
+func getenvMap(key string) map[string]string {
+	m := map[string]string{}
+	for _, kv := range getenvList(key) {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.TrimSpace(k) != "" {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
+
+func getenvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getenvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getenvBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+// getenvTransport reads one provider's transport knobs, e.g.
+// GEMINI_MAX_CONNS_PER_HOST. The HTTP/2 ping settings are shared.
+func getenvTransport(prefix string) httpclient.Options {
+	return httpclient.Options{
+		MaxConnsPerHost:     getenvInt(prefix+"_MAX_CONNS_PER_HOST", 0),
+		MaxIdleConnsPerHost: getenvInt(prefix+"_MAX_IDLE_CONNS_PER_HOST", 0),
+		IdleConnTimeout:     getenvDuration(prefix+"_IDLE_CONN_TIMEOUT", 0),
+		DisableHTTP2:        !getenvBool(prefix+"_HTTP2", true),
+		HTTP2PingInterval:   getenvDuration("HTTP2_PING_INTERVAL", 0),
+		HTTP2PingTimeout:    getenvDuration("HTTP2_PING_TIMEOUT", 0),
+	}
+}
@@ -0,0 +1,190 @@
+package durable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+// WorkflowName is the Temporal workflow type of an extraction job. Its
+// input is an /extract request body and its result an /extract response.
+const WorkflowName = "Extract"
+
+// Activity names: Activities' methods, prefixed.
+const (
+	activityPrefix = "extract."
+	planActivity   = activityPrefix + "Plan"
+	stepActivity   = activityPrefix + "RunStep"
+	finishActivity = activityPrefix + "Finish"
+)
+
+// errBadRequest is the application error type of requests the handler
+// rejects; they are not retried.
+const errBadRequest = "BadRequest"
+
+// Plan is the handler's plan with the retry policy of its steps.
+type Plan struct {
+	handler.Plan
+	Attempts int `json:"attempts"`
+}
+
+// Extract runs a job as a workflow: every stream is an activity, started
+// once the streams it requires have finished and retried on its own when it
+// errors, and a final activity scores the job and delivers its webhook.
+// Streams that still error after every attempt are reported as errored, as
+// they would be by /extract.
+func Extract(ctx workflow.Context, body handler.ExtractRequest) (*handler.ExtractResponse, error) {
+	start := workflow.Now(ctx)
+	short := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+
+	var plan Plan
+	if err := workflow.ExecuteActivity(short, planActivity, body).Get(ctx, &plan); err != nil {
+		return nil, err
+	}
+	stepOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: plan.Timeout,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: int32(plan.Attempts)},
+	}
+
+	done := make(map[string]workflow.Future, len(plan.Steps))
+	set := make(map[string]workflow.Settable, len(plan.Steps))
+	for _, step := range plan.Steps {
+		done[step.Stream], set[step.Stream] = workflow.NewFuture(ctx)
+	}
+	for _, step := range plan.Steps {
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			for _, req := range step.Requires {
+				done[req].Get(ctx, nil)
+			}
+			var sr handler.StreamResult
+			err := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, stepOptions), stepActivity, body, step.Stream).Get(ctx, &sr)
+			if err != nil {
+				sr = handler.StreamResult{Stream: step.Stream, Status: "error", Error: message(err)}
+			}
+			set[step.Stream].Set(sr, nil)
+		})
+	}
+	results := make([]handler.StreamResult, len(plan.Steps))
+	for i, step := range plan.Steps {
+		if err := done[step.Stream].Get(ctx, &results[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp handler.ExtractResponse
+	elapsed := workflow.Now(ctx).Sub(start)
+	if err := workflow.ExecuteActivity(short, finishActivity, body, results, elapsed).Get(ctx, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// message is the cause of a failed activity, without the workflow
+// engine's wrapping.
+func message(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Message()
+	}
+	return err.Error()
+}
+
+// Activities run a job's steps on h.
+type Activities struct {
+	h        *handler.ExtractHandler
+	attempts int
+}
+
+func (a *Activities) Plan(ctx context.Context, body handler.ExtractRequest) (*Plan, error) {
+	plan, err := a.h.Plan(body)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), errBadRequest, err)
+	}
+	return &Plan{Plan: *plan, Attempts: a.attempts}, nil
+}
+
+// RunStep fails when the stream errors, so that it is retried; skipped and
+// unavailable streams are results, not failures.
+func (a *Activities) RunStep(ctx context.Context, body handler.ExtractRequest, stream string) (handler.StreamResult, error) {
+	sr := a.h.RunStep(ctx, body, stream)
+	if sr.Status == "error" {
+		return sr, errors.New(sr.Error)
+	}
+	return sr, nil
+}
+
+func (a *Activities) Finish(ctx context.Context, body handler.ExtractRequest, results []handler.StreamResult, elapsed time.Duration) (*handler.ExtractResponse, error) {
+	return a.h.Finish(ctx, body, results, elapsed), nil
+}
+
+// Options configure a worker.
+type Options struct {
+	TaskQueue string
+
+	// Concurrency bounds the steps run at once, across jobs
+	Concurrency int
+
+	// Attempts is how many times a step is tried before its stream is
+	// reported as errored
+	Attempts int
+}
+
+// Dial connects to a Temporal frontend.
+func Dial(address, namespace string) (client.Client, error) {
+	c, err := client.Dial(client.Options{HostPort: address, Namespace: namespace})
+	if err != nil {
+		return nil, fmt.Errorf("temporal: %w", err)
+	}
+	return c, nil
+}
+
+// Serve runs extraction workflows and their activities from opts.TaskQueue
+// on h until ctx ends, then waits for running steps to finish.
+func Serve(ctx context.Context, c client.Client, h *handler.ExtractHandler, opts Options) error {
+	w := worker.New(c, opts.TaskQueue, worker.Options{
+		MaxConcurrentActivityExecutionSize: max(opts.Concurrency, 1),
+		WorkerStopTimeout:                  10 * time.Minute,
+		DisableRegistrationAliasing:        true,
+	})
+	w.RegisterWorkflowWithOptions(Extract, workflow.RegisterOptions{Name: WorkflowName})
+	w.RegisterActivityWithOptions(&Activities{h: h, attempts: max(opts.Attempts, 1)}, activity.RegisterOptions{Name: activityPrefix})
+
+	if err := w.Start(); err != nil {
+		return fmt.Errorf("temporal worker: %w", err)
+	}
+	<-ctx.Done()
+	w.Stop()
+	return nil
+}
+
+// Run starts a job as a workflow on taskQueue and waits for its response.
+// Requests the workflow rejects are reported as a *handler.JobError with
+// status 400, as Extract reports them.
+func Run(ctx context.Context, c client.Client, taskQueue string, body handler.ExtractRequest) (*handler.ExtractResponse, error) {
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("extract-%s-%d", body.AdID, time.Now().UnixNano()),
+		TaskQueue: taskQueue,
+	}, WorkflowName, body)
+	if err != nil {
+		return nil, fmt.Errorf("start workflow: %w", err)
+	}
+	var resp handler.ExtractResponse
+	if err := run.Get(ctx, &resp); err != nil {
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.Type() == errBadRequest {
+			return nil, &handler.JobError{Status: http.StatusBadRequest, Err: errors.New(appErr.Message())}
+		}
+		return nil, fmt.Errorf("workflow %s: %w", run.GetID(), err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,131 @@
+package durable
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+type fakeSteps struct {
+	mu       sync.Mutex
+	events   []string
+	attempts map[string]int
+	failures map[string]int // attempts that fail, per stream
+}
+
+func (f *fakeSteps) log(event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
+// last is the position of the last occurrence of event.
+func (f *fakeSteps) last(event string) int {
+	for i := len(f.events) - 1; i >= 0; i-- {
+		if f.events[i] == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func newEnv(f *fakeSteps, planErr error) *testsuite.TestWorkflowEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(Extract, workflow.RegisterOptions{Name: WorkflowName})
+	env.RegisterActivityWithOptions(func(ctx context.Context, body handler.ExtractRequest) (*Plan, error) {
+		if planErr != nil {
+			return nil, temporal.NewNonRetryableApplicationError(planErr.Error(), errBadRequest, planErr)
+		}
+		return &Plan{
+			Plan: handler.Plan{Timeout: time.Minute, Steps: []handler.Step{
+				{Stream: "asr"},
+				{Stream: "vlm"},
+				{Stream: "timeline", Requires: []string{"asr", "vlm"}},
+			}},
+			Attempts: 3,
+		}, nil
+	}, activity.RegisterOptions{Name: planActivity})
+	env.RegisterActivityWithOptions(func(ctx context.Context, body handler.ExtractRequest, stream string) (handler.StreamResult, error) {
+		f.mu.Lock()
+		f.attempts[stream]++
+		fail := f.attempts[stream] <= f.failures[stream]
+		f.mu.Unlock()
+		f.log("start " + stream)
+		defer f.log("end " + stream)
+		if fail {
+			return handler.StreamResult{}, errors.New(stream + " provider returned 500")
+		}
+		return handler.StreamResult{Stream: stream, Status: "success", ResultCount: 1}, nil
+	}, activity.RegisterOptions{Name: stepActivity})
+	env.RegisterActivityWithOptions(func(ctx context.Context, body handler.ExtractRequest, results []handler.StreamResult, elapsed time.Duration) (*handler.ExtractResponse, error) {
+		return &handler.ExtractResponse{AdID: body.AdID, Streams: results}, nil
+	}, activity.RegisterOptions{Name: finishActivity})
+	return env
+}
+
+func TestExtract_RunsStepsInOrderAndRetries(t *testing.T) {
+	f := &fakeSteps{attempts: map[string]int{}, failures: map[string]int{"vlm": 2}}
+	env := newEnv(f, nil)
+	env.ExecuteWorkflow(WorkflowName, handler.ExtractRequest{AdID: "ad1"})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var resp handler.ExtractResponse
+	if err := env.GetWorkflowResult(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, sr := range resp.Streams {
+		got = append(got, sr.Stream+":"+sr.Status)
+	}
+	if want := []string{"asr:success", "vlm:success", "timeline:success"}; !slices.Equal(got, want) {
+		t.Errorf("streams = %v, want %v", got, want)
+	}
+	if f.attempts["vlm"] != 3 {
+		t.Errorf("vlm tried %d times, want 3", f.attempts["vlm"])
+	}
+	if start := f.last("start timeline"); start < f.last("end asr") || start < f.last("end vlm") {
+		t.Errorf("timeline started before its requirements finished: %v", f.events)
+	}
+}
+
+func TestExtract_StepOutOfAttemptsIsReportedAsError(t *testing.T) {
+	f := &fakeSteps{attempts: map[string]int{}, failures: map[string]int{"asr": 10}}
+	env := newEnv(f, nil)
+	env.ExecuteWorkflow(WorkflowName, handler.ExtractRequest{AdID: "ad1"})
+	var resp handler.ExtractResponse
+	if err := env.GetWorkflowResult(&resp); err != nil {
+		t.Fatal(err)
+	}
+	asr := resp.Streams[0]
+	if asr.Status != "error" || asr.Error != "asr provider returned 500" {
+		t.Errorf("asr = %+v", asr)
+	}
+	if f.attempts["asr"] != 3 {
+		t.Errorf("asr tried %d times, want 3", f.attempts["asr"])
+	}
+	if resp.Streams[2].Status != "success" {
+		t.Errorf("timeline = %+v; it should still run", resp.Streams[2])
+	}
+}
+
+func TestExtract_BadRequestIsNotRetried(t *testing.T) {
+	env := newEnv(&fakeSteps{attempts: map[string]int{}}, errors.New("ad_id is required"))
+	env.ExecuteWorkflow(WorkflowName, handler.ExtractRequest{})
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != errBadRequest {
+		t.Fatalf("workflow error = %v", err)
+	}
+}
@@ -0,0 +1,808 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+	"github.com/nikipaj1/video-description-pipeline/internal/schema"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+const bucket = "e2e"
+
+// seedAd stores what upstream services leave for an ad: its video, and
+// keyframes with their metadata.
+func seedAd(t *testing.T, store *s3fake.Server, adID string, frames int) {
+	t.Helper()
+	store.Put(bucket, "ads/"+adID+"/video.mp4", []byte("not really a video"), "video/mp4")
+	var meta r2.KeyframeMetadataFile
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+		for p := range img.Pix {
+			img.Pix[p] = uint8(i * 40)
+		}
+		img.Set(0, 0, color.White)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		key := fmt.Sprintf("ads/%s/keyframes/frame_%03d.jpg", adID, i)
+		store.Put(bucket, key, buf.Bytes(), "image/jpeg")
+		meta.Keyframes = append(meta.Keyframes, r2.KeyframeMeta{Index: i, FrameNumber: i * 30, TimestampSec: float64(i), EntropyScore: 5, R2Key: key})
+	}
+	data, _ := json.Marshal(meta)
+	store.Put(bucket, "ads/"+adID+"/keyframes/metadata.json", data, "application/json")
+}
+
+// newHandler returns a handler storing in store, through the R2 client it
+// also returns, and calling the canned providers.
+func newHandler(t *testing.T, store *s3fake.Server) (*handler.ExtractHandler, *r2.Client) {
+	t.Helper()
+	server := httptest.NewServer(store)
+	t.Cleanup(server.Close)
+
+	t.Setenv("R2_ENDPOINT_URL", server.URL)
+	t.Setenv("R2_BUCKET", bucket)
+	t.Setenv("R2_ACCESS_KEY_ID", "key")
+	t.Setenv("R2_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("MOCK_PROVIDERS", "true")
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ConfigureStreams(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r2Client := app.NewR2Client(cfg)
+	return handler.NewExtractHandler(cfg, r2Client, pool.New(1), admission.New(0)), r2Client
+}
+
+// TestExtract runs a whole job, from downloading the ad to uploading its
+// results, against in-memory storage and the canned providers.
+func TestExtract(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 3)
+	h, r2Client := newHandler(t, store)
+
+	resp, err := h.Extract(context.Background(), handler.ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, sr := range resp.Streams {
+		status[sr.Stream] = sr.Status
+		if sr.Status != "success" {
+			continue
+		}
+		data, ok := store.Get(bucket, sr.R2Key)
+		if !ok {
+			t.Errorf("%s: %s not stored", sr.Stream, sr.R2Key)
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Errorf("%s: %v", sr.R2Key, err)
+		}
+		if err := schema.Validate(path.Base(sr.R2Key), v); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, name := range []string{"asr", "vlm", "timeline", "key_moments", "summary"} {
+		if status[name] != "success" {
+			t.Errorf("%s = %q, want success (all: %v)", name, status[name], status)
+		}
+	}
+	if resp.Quality == nil {
+		t.Error("no quality score")
+	}
+
+	var vlm struct {
+		SchemaVersion int `json:"schema_version"`
+		Frames        []struct {
+			Description string `json:"description"`
+		} `json:"frames"`
+	}
+	data, _ := store.Get(bucket, "ads/ad1/extraction/vlm_results.json")
+	if err := json.Unmarshal(data, &vlm); err != nil || vlm.SchemaVersion != streams.VLMSchemaVersion || len(vlm.Frames) != 3 || vlm.Frames[0].Description == "" {
+		t.Errorf("vlm_results.json = %s", data)
+	}
+
+	// The results endpoints serve what was stored
+	results := handler.NewResultsHandler(r2Client)
+	mux := http.NewServeMux()
+	mux.Handle("GET /results/{ad_id}", results)
+	mux.Handle("GET /results/{ad_id}/vlm", results.Artifact("vlm_results.json"))
+	mux.HandleFunc("DELETE /results/{ad_id}", results.Purge)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/ad1", nil))
+	var all client.Results
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /results/ad1: %d %s", rec.Code, rec.Body)
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, data)
+	if !bytes.Equal(all.Artifacts["vlm_results"], compact.Bytes()) || all.Artifacts["asr_results"] == nil {
+		t.Errorf("artifacts = %v", slices.Collect(maps.Keys(all.Artifacts)))
+	}
+	allTag := rec.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, "/results/ad1", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+allTag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if allTag == "" || rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("revalidating GET /results/ad1: %d, ETag %q", rec.Code, allTag)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/ad1/vlm", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), data) || etag == "" {
+		t.Errorf("GET /results/ad1/vlm: %d, ETag %q", rec.Code, etag)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/results/ad1/vlm", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("revalidation: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/ad2/vlm", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown ad: %d", rec.Code)
+	}
+
+	// Purging removes the results but not the inputs
+	stored := store.Keys(bucket, "ads/ad1/extraction/")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/results/ad1", nil))
+	var purged client.Purged
+	if err := json.Unmarshal(rec.Body.Bytes(), &purged); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /results/ad1: %d %s", rec.Code, rec.Body)
+	}
+	if !slices.Equal(purged.Deleted, stored) {
+		t.Errorf("deleted %v, stored %v", purged.Deleted, stored)
+	}
+	if keys := store.Keys(bucket, "ads/ad1/extraction/"); len(keys) > 0 {
+		t.Errorf("left %v", keys)
+	}
+	if _, ok := store.Get(bucket, "ads/ad1/keyframes/metadata.json"); !ok {
+		t.Error("keyframes purged")
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/results/ad1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("purge again: %d", rec.Code)
+	}
+}
+
+// TestListAds pages through GET /ads.
+func TestListAds(t *testing.T) {
+	store := s3fake.New()
+	for _, id := range []string{"a1", "a2", "a3", "b1"} {
+		store.Put(bucket, "ads/"+id+"/extraction/asr_results.json", []byte(`{}`), "application/json")
+		store.Put(bucket, "ads/"+id+"/video.mp4", []byte("v"), "video/mp4")
+	}
+	store.Put(bucket, "ads/a2/extraction/vlm_results.json", []byte(`{}`), "application/json")
+	store.Put(bucket, "ads/a1-x/video.mp4", []byte("v"), "video/mp4") // no results
+	h, _ := newHandler(t, store)
+
+	list := func(query string) client.AdList {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeAds(rec, httptest.NewRequest(http.MethodGet, "/ads?"+query, nil))
+		var out client.AdList
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET /ads?%s: %d %s", query, rec.Code, rec.Body)
+		}
+		return out
+	}
+	var ids []string
+	page := list("prefix=a&limit=2")
+	for _, ad := range page.Ads {
+		ids = append(ids, ad.AdID)
+	}
+	if page.NextToken == "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	if streams := page.Ads[1].Streams; len(streams) != 2 || streams["vlm"].IsZero() {
+		t.Errorf("a2 streams = %v", streams)
+	}
+	page = list("prefix=a&limit=2&token=" + page.NextToken)
+	for _, ad := range page.Ads {
+		ids = append(ids, ad.AdID)
+	}
+	if !slices.Equal(ids, []string{"a1", "a2", "a3"}) || page.NextToken != "" {
+		t.Errorf("listed %v, next %q", ids, page.NextToken)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeAds(rec, httptest.NewRequest(http.MethodGet, "/ads?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: %d", rec.Code)
+	}
+}
+
+// TestAsyncJob submits a job through POST /extract?async=true and polls
+// GET /jobs/{id} until it finishes.
+func TestAsyncJob(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	mux := http.NewServeMux()
+	mux.Handle("POST /extract", h)
+	mux.HandleFunc("GET /jobs/{id}", h.ServeJob)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract?async=true", strings.NewReader(`{"ad_id":"ad1"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	var job client.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "queued" || len(job.Streams) == 0 || job.Streams[0].Status != "pending" {
+		t.Errorf("submitted = %+v", job)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/v1/jobs/"+job.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !job.Done() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("poll: %d %s", rec.Code, rec.Body)
+		}
+		job = client.Job{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Status != "succeeded" || job.Progress != 1 || job.Result == nil {
+		t.Fatalf("job = %+v", job)
+	}
+	for _, sr := range job.Streams {
+		if sr.Status == "pending" || sr.Status == "running" {
+			t.Errorf("%s still %s", sr.Stream, sr.Status)
+		}
+	}
+	// runJob stores the outcome, then clears the active record, just after
+	// it is visible in memory
+	for time.Now().Before(deadline) && (len(store.Keys(bucket, "jobs/"+job.ID)) == 0 || len(store.Keys(bucket, "jobs/active/")) > 0) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := store.Get(bucket, "jobs/"+job.ID+".json"); !ok {
+		t.Error("finished job not stored")
+	}
+	if keys := store.Keys(bucket, "jobs/active/"); len(keys) > 0 {
+		t.Errorf("still active: %v", keys)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/00000000000000000000000000000000", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: %d", rec.Code)
+	}
+}
+
+// TestWebhookTenantSecret signs webhooks with the secret of the tenant the
+// caller's key is bound to, never one a request names.
+func TestWebhookTenantSecret(t *testing.T) {
+	secrets := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		for _, secret := range []string{"default", "acme"} {
+			if client.VerifyWebhook(r.Header, secret, body, 0, time.Now()) == nil {
+				secrets <- secret
+				return
+			}
+		}
+		secrets <- "unsigned"
+	}))
+	defer receiver.Close()
+	t.Setenv("WEBHOOK_URL", receiver.URL)
+	t.Setenv("WEBHOOK_SECRET", "default")
+	t.Setenv("WEBHOOK_TENANT_SECRETS", "acme=acme")
+
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	bound := auth.WithPrincipal(context.Background(), &auth.Principal{Method: "api_key", Tenant: "acme"})
+	unbound := auth.WithPrincipal(context.Background(), &auth.Principal{Method: "api_key"})
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"bound key", bound, "acme"},
+		{"unbound key", unbound, "default"},
+		{"no credential", context.Background(), "default"},
+	} {
+		if _, err := h.Extract(tc.ctx, handler.ExtractRequest{AdID: "ad1", Tenant: "acme"}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-secrets:
+			if got != tc.want {
+				t.Errorf("%s: signed with %s, want %s", tc.name, got, tc.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no webhook", tc.name)
+		}
+	}
+}
+
+// TestCancelJob cancels an async job left active by an instance that
+// stopped, through DELETE /jobs/{id}.
+func TestCancelJob(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs/{id}", h.ServeJob)
+	mux.HandleFunc("DELETE /jobs/{id}", h.ServeCancelJob)
+
+	id := "0123456789abcdef0123456789abcdef"
+	stale := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	store.Put(bucket, "jobs/active/"+id+".json", []byte(`{"job_id":"`+id+`","ad_id":"ad1","status":"running",`+
+		`"streams":[{"stream":"asr","status":"success"},{"stream":"vlm","status":"running"}],`+
+		`"updated_at":"`+stale+`","request":{"ad_id":"ad1"}}`), "application/json")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rec.Code, rec.Body)
+	}
+	var job client.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "cancelled" || job.Streams[0].Status != "success" || job.Streams[1].Status != "cancelled" {
+		t.Errorf("job = %+v", job)
+	}
+	if _, ok := store.Get(bucket, "jobs/"+id+".json"); !ok {
+		t.Error("cancelled job not stored")
+	}
+	if keys := store.Keys(bucket, "jobs/active/"); len(keys) > 0 {
+		t.Errorf("still active: %v", keys)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("cancel again: %d %s", rec.Code, rec.Body)
+	}
+}
+
+// TestExtractStreams re-runs one stream of an extracted ad and checks the
+// other results are left alone.
+func TestExtractStreams(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+
+	if _, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+	asr := []byte(`{"transcript":"kept"}`)
+	store.Put(bucket, "ads/ad1/extraction/asr_results.json", asr, "application/json")
+
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Streams: []string{"vlm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Streams) != 1 || resp.Streams[0].Stream != "vlm" || resp.Streams[0].Status != "success" {
+		t.Errorf("streams = %+v", resp.Streams)
+	}
+	if data, _ := store.Get(bucket, "ads/ad1/extraction/asr_results.json"); !bytes.Equal(data, asr) {
+		t.Errorf("asr_results.json rewritten: %s", data)
+	}
+
+	_, err = h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Streams: []string{"nope"}})
+	var jobErr *handler.JobError
+	if !errors.As(err, &jobErr) || jobErr.Status != http.StatusBadRequest {
+		t.Errorf("unknown stream: %v", err)
+	}
+}
+
+// TestExtractCached runs an ad twice: the second job reuses the stored
+// results, unless forced.
+func TestExtractCached(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+
+	first, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string]string{}
+	for _, sr := range first.Streams {
+		if sr.Status == "success" {
+			stored[sr.Stream] = sr.R2Key
+		}
+	}
+	for _, sr := range second.Streams {
+		if key, ok := stored[sr.Stream]; ok && (sr.Status != "cached" || sr.R2Key != key) {
+			t.Errorf("%s = %s %s, want cached %s", sr.Stream, sr.Status, sr.R2Key, key)
+		}
+	}
+	if second.Quality == nil || second.Quality.Score != first.Quality.Score {
+		t.Errorf("quality = %+v, want %+v", second.Quality, first.Quality)
+	}
+
+	forced, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range forced.Streams {
+		if sr.Status == "cached" {
+			t.Errorf("%s cached despite force", sr.Stream)
+		}
+	}
+}
+
+// TestExtractFillMissing runs only the streams an earlier job left
+// without results.
+func TestExtractFillMissing(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+
+	// A job that only got as far as the transcript
+	if _, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Streams: []string{"asr"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get(bucket, "ads/ad1/extraction/vlm_results.json"); ok {
+		t.Fatal("vlm stored")
+	}
+
+	// Stored results are kept even for a chosen model
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Mode: client.ModeFillMissing, ASRModel: "nova-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, sr := range resp.Streams {
+		status[sr.Stream] = sr.Status
+	}
+	if status["asr"] != "cached" || status["vlm"] != "success" {
+		t.Errorf("streams = %+v", resp.Streams)
+	}
+	if _, ok := store.Get(bucket, "ads/ad1/extraction/vlm_results.json"); !ok {
+		t.Error("vlm not stored")
+	}
+
+	for _, body := range []string{`{"ad_id":"ad1","mode":"fill-missing","force":true}`, `{"ad_id":"ad1","mode":"refill"}`} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestAnalysisStreams runs the derived and ffmpeg streams only when
+// ANALYSIS_STREAMS or the request asks for them.
+func TestAnalysisStreams(t *testing.T) {
+	t.Setenv("ANALYSIS_STREAMS", "none")
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range resp.Streams {
+		if slices.Contains(config.DefaultAnalysisStreams, sr.Stream) {
+			t.Errorf("%s ran with ANALYSIS_STREAMS=none", sr.Stream)
+		}
+	}
+
+	resp, err = h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Streams: []string{"timeline"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Streams) != 1 || resp.Streams[0].Stream != "timeline" || resp.Streams[0].Status != "success" {
+		t.Errorf("streams = %+v", resp.Streams)
+	}
+}
+
+// TestExtractIncludeResults embeds the asr and vlm outputs in the
+// response, cached ones included.
+func TestExtractIncludeResults(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 3)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+
+	plain, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range plain.Streams {
+		if sr.Result != nil {
+			t.Errorf("%s has a result without include_results", sr.Stream)
+		}
+	}
+
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", IncludeResults: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range resp.Streams {
+		switch sr.Stream {
+		case "vlm":
+			var vlm streams.VLMResult
+			if err := json.Unmarshal(sr.Result, &vlm); err != nil || sr.Status != "cached" || len(vlm.Frames) != 3 {
+				t.Errorf("vlm = %s %s (%v), want cached with 3 frames", sr.Status, sr.Result, err)
+			}
+			stored, _ := store.Get(bucket, sr.R2Key)
+			var want streams.VLMResult
+			json.Unmarshal(stored, &want)
+			if want.Frames[0].Description != vlm.Frames[0].Description {
+				t.Errorf("vlm result differs from %s", sr.R2Key)
+			}
+		case "asr":
+			var asr streams.ASRResult
+			if err := json.Unmarshal(sr.Result, &asr); err != nil || asr.SchemaVersion != streams.ASRSchemaVersion {
+				t.Errorf("asr = %s (%v)", sr.Result, err)
+			}
+		default:
+			if sr.Result != nil {
+				t.Errorf("%s has a result", sr.Stream)
+			}
+		}
+	}
+}
+
+// TestExtractPromptTemplate describes frames with a request's own prompt,
+// which stored descriptions do not stand in for.
+func TestExtractPromptTemplate(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+	if _, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", Streams: []string{"vlm"}, VLMPromptTemplate: "After %s, at %.1fs: which product?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range resp.Streams {
+		if sr.Stream == "vlm" && sr.Status != "success" {
+			t.Errorf("vlm = %+v", sr)
+		}
+	}
+	data, _ := store.Get(bucket, "ads/ad1/extraction/vlm_results.json")
+	var vlm struct {
+		Provenance struct {
+			PromptVersion string `json:"prompt_version"`
+		} `json:"provenance"`
+	}
+	if err := json.Unmarshal(data, &vlm); err != nil || !strings.HasPrefix(vlm.Provenance.PromptVersion, "custom-") {
+		t.Errorf("vlm_results.json = %s", data)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(`{"ad_id":"ad1","vlm_prompt_template":"At %d"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad template: %d %s", rec.Code, rec.Body)
+	}
+}
+
+// TestExtractModels runs jobs with models and a language chosen in the
+// request.
+func TestExtractModels(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+
+	if _, err := h.Extract(context.Background(), handler.ExtractRequest{AdID: "ad1", ASRModel: "nova-2", VLMModel: "gemini-1.5-pro"}); err != nil {
+		t.Fatal(err)
+	}
+	for file, model := range map[string]string{"asr_results.json": "nova-2", "vlm_results.json": "gemini-1.5-pro"} {
+		data, _ := store.Get(bucket, "ads/ad1/extraction/"+file)
+		var out struct {
+			Provenance struct {
+				Model string `json:"model"`
+			} `json:"provenance"`
+		}
+		if err := json.Unmarshal(data, &out); err != nil || out.Provenance.Model != model {
+			t.Errorf("%s = %s", file, data)
+		}
+	}
+
+	if _, err := h.Extract(context.Background(), handler.ExtractRequest{AdID: "ad1", Streams: []string{"asr"}, Language: "es"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := store.Get(bucket, "ads/ad1/extraction/asr_results.json")
+	var asr struct {
+		Language string `json:"language"`
+		Segments []struct {
+			Language string `json:"language"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &asr); err != nil || asr.Language != "es" || len(asr.Segments) == 0 || asr.Segments[0].Language != "es" {
+		t.Errorf("asr_results.json = %s", data)
+	}
+
+	for _, body := range []string{`{"ad_id":"ad1","vlm_model":"gemini-ultra"}`, `{"ad_id":"ad1","language":"spanish"}`, `{"ad_id":"ad1","deepgram_params":{"model":"whisper-large"}}`} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestExtractQueuePriority(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	h, _ := newHandler(t, store)
+
+	if _, err := h.Extract(context.Background(), handler.ExtractRequest{AdID: "ad1", QueuePriority: client.QueuePriorityMax}); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{`{"ad_id":"ad1","queue_priority":11}`, `{"ad_id":"ad1","queue_priority":-11}`} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler.OpenAPI().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/v1/extract", "/v1/health", "/v1/jobs/{id}", "/v1/results/{ad_id}", "/livez"} {
+		if doc.Paths[p] == nil {
+			t.Errorf("no %s in paths", p)
+		}
+	}
+	for _, ref := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(rec.Body.String(), -1) {
+		if doc.Components.Schemas[ref[1]] == nil {
+			t.Errorf("unresolved reference to %s", ref[1])
+		}
+	}
+
+	// Errors are the documented body
+	store := s3fake.New()
+	h, _ := newHandler(t, store)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(`{"ad_id":"ad1","queue_priority":11}`)))
+	var body client.Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != client.CodeInvalidRequest || body.Message == "" {
+		t.Errorf("error body = %s", rec.Body)
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 1)
+	h, _ := newHandler(t, store)
+	mux := http.NewServeMux()
+	handler.HandleVersioned(mux, "POST /extract", h)
+	handler.HandleVersioned(mux, "GET /jobs/{id}", http.HandlerFunc(h.ServeJob))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/extract?async=true", strings.NewReader(`{"ad_id":"ad1"}`)))
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("POST /v1/extract: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+	loc := rec.Header().Get("Location")
+
+	// The old paths still work, pointing to their successors
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(loc, handler.APIVersion), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Link") != "<"+loc+`>; rel="successor-version"` {
+		t.Errorf("GET unversioned job: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+}
+
+func TestExtractDryRun(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 3)
+	h, _ := newHandler(t, store)
+	ctx := context.Background()
+	two := 2
+
+	resp, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", DryRun: true, MaxFrames: &two})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := client.DryRun{VideoBytes: int64(len("not really a video")), Keyframes: 3, FramesDescribed: 2, GeminiCalls: 2}
+	if resp.DryRun == nil || *resp.DryRun != want {
+		t.Errorf("dry_run = %+v, want %+v", resp.DryRun, want)
+	}
+	status := map[string]string{}
+	for _, sr := range resp.Streams {
+		status[sr.Stream] = sr.Status
+	}
+	if status["asr"] != "would_run" || status["vlm"] != "would_run" {
+		t.Errorf("streams = %+v", resp.Streams)
+	}
+	if _, ok := store.Get(bucket, "ads/ad1/extraction/vlm_results.json"); ok {
+		t.Error("dry run stored results")
+	}
+
+	// Once extracted, stored results would be reused,
+	if _, err := h.Extract(ctx, handler.ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+	// and a keyframe listed without an image is found
+	var meta r2.KeyframeMetadataFile
+	data, _ := store.Get(bucket, "ads/ad1/keyframes/metadata.json")
+	json.Unmarshal(data, &meta)
+	meta.Keyframes = append(meta.Keyframes, r2.KeyframeMeta{Index: 3, TimestampSec: 3, R2Key: "ads/ad1/keyframes/frame_003.jpg"})
+	data, _ = json.Marshal(meta)
+	store.Put(bucket, "ads/ad1/keyframes/metadata.json", data, "application/json")
+	resp, err = h.Extract(ctx, handler.ExtractRequest{AdID: "ad1", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sr := range resp.Streams {
+		if sr.Stream == "vlm" && sr.Status != "cached" {
+			t.Errorf("vlm = %+v, want cached", sr)
+		}
+	}
+	if d := resp.DryRun; d.UnreadableKeyframes != 1 || d.GeminiCalls != 0 {
+		t.Errorf("dry_run = %+v", d)
+	}
+
+	if _, err := h.Extract(ctx, handler.ExtractRequest{AdID: "nope", DryRun: true}); err == nil {
+		t.Error("dry run of an ad without a video succeeded")
+	}
+}
@@ -0,0 +1,163 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/grpcapi"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+	pb "github.com/nikipaj1/video-description-pipeline/pkg/pipelinepb"
+)
+
+// newGRPCClient serves the gRPC API over an in-memory connection.
+func newGRPCClient(t *testing.T, store *s3fake.Server, opts grpcapi.Options) pb.PipelineClient {
+	t.Helper()
+	h, r2Client := newHandler(t, store)
+	srv := grpcapi.NewServer(h, handler.NewResultsHandler(r2Client), opts)
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewPipelineClient(conn)
+}
+
+func TestGRPC(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 3)
+	c := newGRPCClient(t, store, grpcapi.Options{})
+	ctx := context.Background()
+
+	resp, err := c.Extract(ctx, &pb.ExtractRequest{AdId: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAdId() != "ad1" || len(resp.GetStreams()) == 0 || resp.GetQuality() == nil {
+		t.Errorf("response = %v", resp)
+	}
+
+	res, err := c.GetResults(ctx, &pb.GetResultsRequest{AdId: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vlm struct {
+		Frames []json.RawMessage `json:"frames"`
+	}
+	if err := json.Unmarshal(res.GetArtifacts()["vlm_results"], &vlm); err != nil || len(vlm.Frames) != 3 {
+		t.Errorf("vlm_results = %s", res.GetArtifacts()["vlm_results"])
+	}
+	if _, err := c.GetResults(ctx, &pb.GetResultsRequest{AdId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetResults(nope) err = %v, want NotFound", err)
+	}
+	if _, err := c.Extract(ctx, &pb.ExtractRequest{AdId: "ad1", QueuePriority: 11}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Extract(queue_priority 11) err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCExtractStream(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 3)
+	c := newGRPCClient(t, store, grpcapi.Options{})
+
+	stream, err := c.ExtractStream(context.Background(), &pb.ExtractRequest{AdId: "ad1", Streams: []string{"vlm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*pb.ProgressEvent
+	for {
+		ev, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) == 0 || events[0].GetStage() != "queued" {
+		t.Fatalf("events = %v", events)
+	}
+	last := events[len(events)-1]
+	if last.GetEvent() != "result" || last.GetResult().GetAdId() != "ad1" {
+		t.Errorf("last event = %v", last)
+	}
+	var frames, finished int
+	for _, ev := range events {
+		switch ev.GetEvent() {
+		case "frame":
+			frames++
+			if ev.GetFramesTotal() != 3 || ev.GetFramesDone() != int32(frames) {
+				t.Errorf("frame event = %v", ev)
+			}
+		case "stream":
+			if ev.GetStream().GetStream() == "vlm" && ev.GetStream().GetStatus() != "running" {
+				finished++
+			}
+		}
+	}
+	if frames != 3 || finished != 1 {
+		t.Errorf("%d frame events and %d vlm finished, want 3 and 1: %v", frames, finished, events)
+	}
+}
+
+func TestGRPCJobs(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 2)
+	c := newGRPCClient(t, store, grpcapi.Options{})
+	ctx := context.Background()
+
+	job, err := c.Submit(ctx, &pb.ExtractRequest{AdId: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for job.GetStatus() != "succeeded" {
+		if time.Now().After(deadline) || job.GetStatus() == "failed" {
+			t.Fatalf("job = %v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if job, err = c.GetJob(ctx, &pb.GetJobRequest{JobId: job.GetJobId()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.GetResult().GetAdId() != "ad1" || job.GetCreatedAt().AsTime().IsZero() {
+		t.Errorf("job = %v", job)
+	}
+	if _, err := c.GetJob(ctx, &pb.GetJobRequest{JobId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetJob(nope) err = %v, want NotFound", err)
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	store := s3fake.New()
+	seedAd(t, store, "ad1", 1)
+	c := newGRPCClient(t, store, grpcapi.Options{Auth: auth.New(auth.Options{APIKeys: []string{"secret"}})})
+
+	if _, err := c.GetResults(context.Background(), &pb.GetResultsRequest{AdId: "ad1"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("err without a token = %v, want Unauthenticated", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if _, err := c.Extract(ctx, &pb.ExtractRequest{AdId: "ad1"}); err != nil {
+		t.Errorf("err with a token = %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is an object written to or removed from the bucket.
+type Event struct {
+	Key     string
+	Created bool // false for deletions
+}
+
+// s3Notification is an S3 event notification, as sent by S3 and by
+// S3-compatible stores such as MinIO.
+type s3Notification struct {
+	Records []struct {
+		EventName string `json:"eventName"` // e.g. "ObjectCreated:Put"
+		S3        struct {
+			Object struct {
+				Key string `json:"key"` // URL-encoded
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// snsEnvelope wraps messages delivered through an SNS HTTP subscription.
+type snsEnvelope struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// r2Notification is a Cloudflare R2 event notification message.
+type r2Notification struct {
+	Action string `json:"action"` // e.g. "PutObject"
+	Object struct {
+		Key string `json:"key"`
+	} `json:"object"`
+}
+
+// Parse reads S3 event notifications, bare or wrapped by SNS, and R2 event
+// notifications, singly or as a JSON array. Test events and SNS
+// subscription confirmations carry no events; the latter are logged so the
+// subscription can be confirmed by hand.
+func Parse(body []byte) ([]Event, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] == '[' {
+		var msgs []json.RawMessage
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			return nil, fmt.Errorf("decode events: %w", err)
+		}
+		var out []Event
+		for _, m := range msgs {
+			evs, err := Parse(m)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, evs...)
+		}
+		return out, nil
+	}
+
+	var probe struct {
+		Records json.RawMessage `json:"Records"`
+		Type    string          `json:"Type"`
+		Action  string          `json:"action"`
+		Event   string          `json:"Event"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("decode events: %w", err)
+	}
+	switch {
+	case probe.Records != nil:
+		var n s3Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			return nil, fmt.Errorf("decode S3 notification: %w", err)
+		}
+		var out []Event
+		for _, r := range n.Records {
+			key, err := url.QueryUnescape(r.S3.Object.Key)
+			if err != nil {
+				return nil, fmt.Errorf("decode S3 key %q: %w", r.S3.Object.Key, err)
+			}
+			out = append(out, Event{Key: key, Created: strings.HasPrefix(r.EventName, "ObjectCreated:")})
+		}
+		return out, nil
+	case probe.Type == "Notification":
+		var env snsEnvelope
+		json.Unmarshal(body, &env)
+		return Parse([]byte(env.Message))
+	case probe.Type == "SubscriptionConfirmation":
+		var env snsEnvelope
+		json.Unmarshal(body, &env)
+		log.Printf("SNS subscription awaiting confirmation: visit %s", env.SubscribeURL)
+		return nil, nil
+	case probe.Action != "":
+		var n r2Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			return nil, fmt.Errorf("decode R2 notification: %w", err)
+		}
+		created := n.Action == "PutObject" || n.Action == "CopyObject" || n.Action == "CompleteMultipartUpload"
+		return []Event{{Key: n.Object.Key, Created: created}}, nil
+	case probe.Event == "s3:TestEvent":
+		return nil, nil
+	}
+	return nil, errors.New("not an S3 or R2 event notification")
+}
+
+// classify recognises the objects that can start a job.
+func classify(key string) (adID, kind string) {
+	rest, ok := strings.CutPrefix(key, "ads/")
+	if !ok {
+		return "", ""
+	}
+	if id, ok := strings.CutSuffix(rest, "/video.mp4"); ok && id != "" && !strings.Contains(id, "/") {
+		return id, "video"
+	}
+	if id, ok := strings.CutSuffix(rest, "/keyframes/metadata.json"); ok && id != "" && !strings.Contains(id, "/") {
+		return id, "keyframes"
+	}
+	return "", ""
+}
+
+// resubmitAfter is how long an ad is not submitted again after a job was
+// started for it. It absorbs duplicate deliveries and the metadata.json that
+// a job writes itself when it extracts keyframes.
+const resubmitAfter = 30 * time.Minute
+
+// Trigger starts extraction jobs from storage events: an ad is submitted
+// once both its video and its keyframe metadata exist, whichever arrives
+// second. With keyframeWait set, a video whose keyframes have not appeared
+// by then is submitted anyway, for deployments that extract keyframes
+// themselves.
+type Trigger struct {
+	submit       func(ctx context.Context, adID string) error
+	exists       func(ctx context.Context, key string) (bool, error)
+	keyframeWait time.Duration
+
+	mu      sync.Mutex
+	waiting map[string]*time.Timer // ads whose video arrived before keyframes
+	started map[string]time.Time
+	now     func() time.Time
+}
+
+// NewTrigger submits ads with submit, which runs detached from the event
+// that caused it, and checks for objects with exists (e.g. r2.Client.Exists).
+// keyframeWait 0 waits for keyframes indefinitely.
+func NewTrigger(submit func(ctx context.Context, adID string) error, exists func(ctx context.Context, key string) (bool, error), keyframeWait time.Duration) *Trigger {
+	return &Trigger{
+		submit:       submit,
+		exists:       exists,
+		keyframeWait: keyframeWait,
+		waiting:      make(map[string]*time.Timer),
+		started:      make(map[string]time.Time),
+		now:          time.Now,
+	}
+}
+
+// Handle acts on one event. Objects other than videos and keyframe metadata,
+// and deletions, are ignored.
+func (t *Trigger) Handle(ctx context.Context, ev Event) error {
+	adID, kind := classify(ev.Key)
+	if kind == "" || !ev.Created {
+		return nil
+	}
+
+	switch kind {
+	case "video":
+		ok, err := t.exists(ctx, fmt.Sprintf("ads/%s/keyframes/metadata.json", adID))
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.start(adID, "video uploaded")
+			return nil
+		}
+		if t.keyframeWait > 0 {
+			t.wait(adID)
+		}
+	case "keyframes":
+		// Whoever removes an ad from waiting submits it, so the timer and
+		// this event never both do
+		t.mu.Lock()
+		timer, waiting := t.waiting[adID]
+		delete(t.waiting, adID)
+		t.mu.Unlock()
+		if waiting {
+			timer.Stop()
+			t.start(adID, "keyframes written")
+			return nil
+		}
+		ok, err := t.exists(ctx, fmt.Sprintf("ads/%s/video.mp4", adID))
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.start(adID, "keyframes written")
+		}
+	}
+	return nil
+}
+
+func (t *Trigger) wait(adID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.waiting[adID]; ok {
+		return
+	}
+	t.waiting[adID] = time.AfterFunc(t.keyframeWait, func() {
+		t.mu.Lock()
+		_, waiting := t.waiting[adID]
+		delete(t.waiting, adID)
+		t.mu.Unlock()
+		if waiting {
+			t.start(adID, "no keyframes after "+t.keyframeWait.String())
+		}
+	})
+}
+
+func (t *Trigger) start(adID, reason string) {
+	now := t.now()
+	t.mu.Lock()
+	for id, at := range t.started {
+		if now.Sub(at) >= resubmitAfter {
+			delete(t.started, id)
+		}
+	}
+	_, recent := t.started[adID]
+	if !recent {
+		t.started[adID] = now
+	}
+	t.mu.Unlock()
+	if recent {
+		log.Printf("%s: %s, but a job was started in the last %s", adID, reason, resubmitAfter)
+		return
+	}
+
+	log.Printf("%s: starting extraction (%s)", adID, reason)
+	go func() {
+		if err := t.submit(context.Background(), adID); err != nil {
+			log.Printf("%s: event-triggered extraction failed: %v", adID, err)
+		}
+	}()
+}
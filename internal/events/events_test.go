@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Event
+	}{
+		{
+			"s3",
+			`{"Records":[{"eventName":"ObjectCreated:Put","s3":{"object":{"key":"ads/summer+sale%281%29/video.mp4"}}},
+			             {"eventName":"ObjectRemoved:Delete","s3":{"object":{"key":"ads/a1/video.mp4"}}}]}`,
+			[]Event{{Key: "ads/summer sale(1)/video.mp4", Created: true}, {Key: "ads/a1/video.mp4"}},
+		},
+		{
+			"sns",
+			`{"Type":"Notification","Message":"{\"Records\":[{\"eventName\":\"ObjectCreated:CompleteMultipartUpload\",\"s3\":{\"object\":{\"key\":\"ads/a1/video.mp4\"}}}]}"}`,
+			[]Event{{Key: "ads/a1/video.mp4", Created: true}},
+		},
+		{
+			"r2 batch",
+			`[{"account":"x","action":"PutObject","bucket":"b","object":{"key":"ads/a1/keyframes/metadata.json","size":812}},
+			  {"account":"x","action":"DeleteObject","bucket":"b","object":{"key":"ads/a2/video.mp4"}}]`,
+			[]Event{{Key: "ads/a1/keyframes/metadata.json", Created: true}, {Key: "ads/a2/video.mp4"}},
+		},
+		{"s3 test event", `{"Service":"Amazon S3","Event":"s3:TestEvent"}`, nil},
+		{"sns confirmation", `{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example/confirm"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte(`{"hello":"world"}`)); err == nil {
+		t.Error("expected an error for an unknown body")
+	}
+}
+
+// fakeBucket records submissions and answers exists from a set of keys.
+type fakeBucket struct {
+	mu        sync.Mutex
+	keys      map[string]bool
+	submitted chan string
+}
+
+func newFakeBucket(keys ...string) *fakeBucket {
+	b := &fakeBucket{keys: map[string]bool{}, submitted: make(chan string, 10)}
+	for _, k := range keys {
+		b.keys[k] = true
+	}
+	return b
+}
+
+func (b *fakeBucket) exists(_ context.Context, key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.keys[key], nil
+}
+
+func (b *fakeBucket) submit(_ context.Context, adID string) error {
+	b.submitted <- adID
+	return nil
+}
+
+// expect waits for the given submissions, then checks nothing else follows.
+func (b *fakeBucket) expect(t *testing.T, ids ...string) {
+	t.Helper()
+	for _, want := range ids {
+		select {
+		case got := <-b.submitted:
+			if got != want {
+				t.Errorf("submitted %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s not submitted", want)
+		}
+	}
+	select {
+	case got := <-b.submitted:
+		t.Errorf("unexpected submission of %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTrigger_WaitsForBothObjects(t *testing.T) {
+	b := newFakeBucket("ads/a1/video.mp4")
+	tr := NewTrigger(b.submit, b.exists, 0)
+	ctx := context.Background()
+
+	tr.Handle(ctx, Event{Key: "ads/a1/video.mp4", Created: true})
+	tr.Handle(ctx, Event{Key: "ads/a1/keyframes/kf_000.jpg", Created: true})
+	b.expect(t) // no keyframes yet
+
+	tr.Handle(ctx, Event{Key: "ads/a1/keyframes/metadata.json", Created: true})
+	b.expect(t, "a1")
+
+	// Redelivered event
+	tr.Handle(ctx, Event{Key: "ads/a1/keyframes/metadata.json", Created: true})
+	b.expect(t)
+
+	// Keyframes for an ad whose video is not uploaded yet
+	tr.Handle(ctx, Event{Key: "ads/a2/keyframes/metadata.json", Created: true})
+	b.expect(t)
+}
+
+func TestTrigger_ResubmitsAfterWindow(t *testing.T) {
+	b := newFakeBucket("ads/a1/keyframes/metadata.json")
+	tr := NewTrigger(b.submit, b.exists, 0)
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	tr.Handle(ctx, Event{Key: "ads/a1/video.mp4", Created: true})
+	b.expect(t, "a1")
+	tr.Handle(ctx, Event{Key: "ads/a1/video.mp4", Created: true})
+	b.expect(t)
+
+	now = now.Add(resubmitAfter)
+	tr.Handle(ctx, Event{Key: "ads/a1/video.mp4", Created: true})
+	b.expect(t, "a1")
+}
+
+func TestTrigger_KeyframeWait(t *testing.T) {
+	b := newFakeBucket()
+	tr := NewTrigger(b.submit, b.exists, 20*time.Millisecond)
+	ctx := context.Background()
+
+	// Keyframes never come: submitted once the wait is over
+	tr.Handle(ctx, Event{Key: "ads/a1/video.mp4", Created: true})
+	b.expect(t, "a1")
+
+	// Keyframes arrive during the wait: submitted once, straight away
+	tr = NewTrigger(b.submit, b.exists, time.Hour)
+	tr.Handle(ctx, Event{Key: "ads/a2/video.mp4", Created: true})
+	tr.Handle(ctx, Event{Key: "ads/a2/keyframes/metadata.json", Created: true})
+	b.expect(t, "a2")
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// Record is one line of the exported JSONL dataset.
+type Record struct {
+	AdID       string               `json:"ad_id"`
+	Transcript string               `json:"transcript"`
+	Segments   []streams.ASRSegment `json:"segments"`
+	Frames     []streams.VLMFrame   `json:"frames"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// State is persisted between runs so that exports can be incremental.
+type State struct {
+	LastExport time.Time `json:"last_export"`
+}
+
+// LoadState reads the export state file. A missing file yields a zero State,
+// which exports everything.
+func LoadState(path string) (State, error) {
+	var st State
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("decode state: %w", err)
+	}
+	return st, nil
+}
+
+// SaveState writes the export state file.
+func SaveState(path string, st State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// Run writes one JSONL record per ad whose results changed after since and
+// returns the newest modification time seen, to be used as the next cursor.
+// Ads whose results cannot be read are logged and skipped.
+func Run(ctx context.Context, client *r2.Client, w io.Writer, since time.Time) (int, time.Time, error) {
+	objects, err := client.ListExtractionResults(ctx, since)
+	if err != nil {
+		return 0, since, err
+	}
+
+	updated := make(map[string]time.Time)
+	cursor := since
+	for _, obj := range objects {
+		if obj.LastModified.After(updated[obj.AdID]) {
+			updated[obj.AdID] = obj.LastModified
+		}
+		if obj.LastModified.After(cursor) {
+			cursor = obj.LastModified
+		}
+	}
+
+	adIDs := make([]string, 0, len(updated))
+	for id := range updated {
+		adIDs = append(adIDs, id)
+	}
+	sort.Strings(adIDs)
+
+	enc := json.NewEncoder(w)
+	count := 0
+	for _, adID := range adIDs {
+		rec, err := loadRecord(ctx, client, adID)
+		if err != nil {
+			log.Printf("WARN: export skipping %s: %v", adID, err)
+			continue
+		}
+		rec.UpdatedAt = updated[adID]
+		if err := enc.Encode(rec); err != nil {
+			return count, since, fmt.Errorf("write record %s: %w", adID, err)
+		}
+		count++
+	}
+	return count, cursor, nil
+}
+
+func loadRecord(ctx context.Context, client *r2.Client, adID string) (*Record, error) {
+	var (
+		asr streams.ASRResult
+		vlm streams.VLMResult
+	)
+	asrErr := client.DownloadJSON(ctx, fmt.Sprintf("ads/%s/extraction/asr_results.json", adID), &asr)
+	vlmErr := client.DownloadJSON(ctx, fmt.Sprintf("ads/%s/extraction/vlm_results.json", adID), &vlm)
+	if asrErr != nil && vlmErr != nil {
+		return nil, fmt.Errorf("no readable results: %w", errors.Join(asrErr, vlmErr))
+	}
+	return buildRecord(adID, &asr, &vlm), nil
+}
+
+// buildRecord flattens stream results into a dataset record. Frames whose
+// description is an error placeholder are dropped.
+func buildRecord(adID string, asr *streams.ASRResult, vlm *streams.VLMResult) *Record {
+	rec := &Record{
+		AdID:     adID,
+		Segments: asr.Segments,
+	}
+
+	texts := make([]string, 0, len(asr.Segments))
+	for _, s := range asr.Segments {
+		texts = append(texts, s.Text)
+	}
+	rec.Transcript = strings.Join(texts, " ")
+
+	for _, f := range vlm.Frames {
+		if strings.HasPrefix(f.Description, "[Error:") {
+			continue
+		}
+		rec.Frames = append(rec.Frames, f)
+	}
+	return rec
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+func TestBuildRecord(t *testing.T) {
+	asr := &streams.ASRResult{Segments: []streams.ASRSegment{
+		{Start: 0, End: 1.5, Text: "Hello there"},
+		{Start: 2, End: 3.5, Text: "Buy now"},
+	}}
+	vlm := &streams.VLMResult{Frames: []streams.VLMFrame{
+		{FrameIndex: 0, TimestampSec: 0, Description: "A woman smiles."},
+		{FrameIndex: 4, TimestampSec: 2, Description: "[Error: gemini returned 500: oops]"},
+		{FrameIndex: 9, TimestampSec: 4, Description: "Product close-up."},
+	}}
+
+	rec := buildRecord("ad-1", asr, vlm)
+
+	if rec.AdID != "ad-1" {
+		t.Errorf("ad_id = %q", rec.AdID)
+	}
+	if rec.Transcript != "Hello there Buy now" {
+		t.Errorf("transcript = %q", rec.Transcript)
+	}
+	if len(rec.Frames) != 2 {
+		t.Fatalf("expected 2 frames (error frame dropped), got %d", len(rec.Frames))
+	}
+	if rec.Frames[1].FrameIndex != 9 {
+		t.Errorf("frame 1 index = %d", rec.Frames[1].FrameIndex)
+	}
+}
+
+func TestState_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	st, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState missing file: %v", err)
+	}
+	if !st.LastExport.IsZero() {
+		t.Errorf("expected zero state, got %v", st.LastExport)
+	}
+
+	want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := SaveState(path, State{LastExport: want}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	st, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if !st.LastExport.Equal(want) {
+		t.Errorf("last_export = %v, want %v", st.LastExport, want)
+	}
+}
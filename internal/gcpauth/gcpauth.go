@@ -0,0 +1,237 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+	metadataURL     = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// refreshBefore renews a token this long before it expires, so a token
+	// handed out is still good for a request or two.
+	refreshBefore = 2 * time.Minute
+)
+
+// credentials is a Google credentials file: a service account key or the
+// application default credentials `gcloud auth application-default login`
+// writes.
+type credentials struct {
+	Type string `json:"type"` // "service_account" | "authorized_user"
+
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenSource hands out OAuth2 access tokens for Google APIs, renewing them
+// as they expire.
+type TokenSource struct {
+	file   string
+	scopes []string
+	client *http.Client
+
+	tokenURI    string // overridden by tests
+	metadataURL string
+
+	mu      sync.Mutex
+	creds   *credentials
+	key     *rsa.PrivateKey
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource authenticates with the credentials file at path, or with
+// the GCE / Cloud Run metadata server when path is empty. The file is read
+// on first use.
+func NewTokenSource(path string, scopes ...string) *TokenSource {
+	return &TokenSource{
+		file:        path,
+		scopes:      scopes,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		tokenURI:    defaultTokenURI,
+		metadataURL: metadataURL,
+	}
+}
+
+// Token returns a valid access token.
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && time.Until(ts.expires) > refreshBefore {
+		return ts.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if ts.file == "" {
+		req, err = ts.metadataRequest(ctx)
+	} else {
+		req, err = ts.fileRequest(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("fetch token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch token: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("fetch token: unexpected response: %s", strings.TrimSpace(string(body)))
+	}
+	ts.token = tok.AccessToken
+	ts.expires = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+func (ts *TokenSource) metadataRequest(ctx context.Context) (*http.Request, error) {
+	u := ts.metadataURL
+	if len(ts.scopes) > 0 {
+		u += "?scopes=" + url.QueryEscape(strings.Join(ts.scopes, ","))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return req, nil
+}
+
+// fileRequest exchanges the credentials file for a token: a signed JWT for a
+// service account, the refresh token for a user.
+func (ts *TokenSource) fileRequest(ctx context.Context) (*http.Request, error) {
+	if err := ts.load(); err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	tokenURI := ts.tokenURI
+	switch ts.creds.Type {
+	case "service_account":
+		if ts.creds.TokenURI != "" && ts.tokenURI == defaultTokenURI {
+			tokenURI = ts.creds.TokenURI
+		}
+		assertion, err := ts.signJWT(tokenURI, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", ts.creds.ClientID)
+		form.Set("client_secret", ts.creds.ClientSecret)
+		form.Set("refresh_token", ts.creds.RefreshToken)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+func (ts *TokenSource) load() error {
+	if ts.creds != nil {
+		return nil
+	}
+	data, err := os.ReadFile(ts.file)
+	if err != nil {
+		return fmt.Errorf("read credentials: %w", err)
+	}
+	var c credentials
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("decode credentials %s: %w", ts.file, err)
+	}
+	switch c.Type {
+	case "service_account":
+		key, err := parseKey(c.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("credentials %s: %w", ts.file, err)
+		}
+		ts.key = key
+	case "authorized_user":
+		if c.RefreshToken == "" {
+			return fmt.Errorf("credentials %s have no refresh_token", ts.file)
+		}
+	default:
+		return fmt.Errorf("credentials %s: unsupported type %q", ts.file, c.Type)
+	}
+	ts.creds = &c
+	return nil
+}
+
+func parseKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// signJWT builds the RS256 assertion a service account trades for a token.
+func (ts *TokenSource) signJWT(audience string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   ts.creds.ClientEmail,
+		"scope": strings.Join(ts.scopes, " "),
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("sign token request: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,102 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenSource_ServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		r.ParseForm()
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var c map[string]any
+		json.Unmarshal(claims, &c)
+		if c["iss"] != "sa@proj.iam.gserviceaccount.com" || c["scope"] != "a b" {
+			http.Error(w, "bad claims", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "tok1", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "sa.json")
+	creds, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "sa@proj.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL,
+	})
+	os.WriteFile(path, creds, 0o600)
+
+	ts := NewTokenSource(path, "a", "b")
+	for range 2 {
+		tok, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok != "tok1" {
+			t.Errorf("token = %q", tok)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("%d token requests, want 1 while the token is fresh", calls)
+	}
+}
+
+func TestTokenSource_Metadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("scopes") != "a" {
+			http.Error(w, "bad request", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "meta", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	ts := NewTokenSource("", "a")
+	ts.metadataURL = srv.URL
+	if tok, err := ts.Token(context.Background()); err != nil || tok != "meta" {
+		t.Errorf("token = %q, %v", tok, err)
+	}
+}
+
+func TestTokenSource_BadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.json")
+	os.WriteFile(path, []byte(`{"type":"external_account"}`), 0o600)
+	_, err := NewTokenSource(path).Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+	pb "github.com/nikipaj1/video-description-pipeline/pkg/pipelinepb"
+)
+
+// Conversions between the protobuf messages and the JSON wire types they
+// mirror.
+
+func fromRequest(r *pb.ExtractRequest) client.ExtractRequest {
+	body := client.ExtractRequest{
+		AdID:              r.GetAdId(),
+		Bundle:            r.Bundle,
+		WebhookURL:        r.GetWebhookUrl(),
+		Multilingual:      r.Multilingual,
+		Priority:          r.GetPriority(),
+		Tenant:            r.GetTenant(),
+		QueuePriority:     int(r.GetQueuePriority()),
+		VideoURL:          r.GetVideoUrl(),
+		Streams:           r.GetStreams(),
+		Force:             r.GetForce(),
+		VLMPromptTemplate: r.GetVlmPromptTemplate(),
+		Language:          r.GetLanguage(),
+		ASRModel:          r.GetAsrModel(),
+		VLMModel:          r.GetVlmModel(),
+		DeepgramParams:    r.GetDeepgramParams(),
+		DryRun:            r.GetDryRun(),
+		IncludeResults:    r.GetIncludeResults(),
+		Mode:              r.GetMode(),
+	}
+	if r.MaxFrames != nil {
+		n := int(*r.MaxFrames)
+		body.MaxFrames = &n
+	}
+	return body
+}
+
+func toStreamResult(sr *client.StreamResult) *pb.StreamResult {
+	return &pb.StreamResult{
+		Stream:      sr.Stream,
+		Status:      sr.Status,
+		ResultCount: int32(sr.ResultCount),
+		R2Key:       sr.R2Key,
+		Error:       sr.Error,
+		Result:      sr.Result,
+	}
+}
+
+func toStreamResults(srs []client.StreamResult) []*pb.StreamResult {
+	out := make([]*pb.StreamResult, len(srs))
+	for i := range srs {
+		out[i] = toStreamResult(&srs[i])
+	}
+	return out
+}
+
+func toResponse(r *client.ExtractResponse) *pb.ExtractResponse {
+	if r == nil {
+		return nil
+	}
+	out := &pb.ExtractResponse{
+		AdId:             r.AdID,
+		Partial:          r.Partial,
+		Streams:          toStreamResults(r.Streams),
+		ProcessingTimeMs: r.ProcessingTimeMs,
+		GeminiTokens:     r.GeminiTokens,
+		Quarantined:      r.Quarantined,
+	}
+	if d := r.DryRun; d != nil {
+		out.DryRun = &pb.DryRun{
+			VideoBytes:          d.VideoBytes,
+			Keyframes:           int32(d.Keyframes),
+			UnreadableKeyframes: int32(d.UnreadableKeyframes),
+			FramesDescribed:     int32(d.FramesDescribed),
+			GeminiCalls:         int32(d.GeminiCalls),
+		}
+	}
+	if q := r.Quality; q != nil {
+		out.Quality = &pb.QualityScore{
+			Score:            q.Score,
+			VlmErrorRate:     q.VLMErrorRate,
+			VlmBlockedFrames: int32(q.VLMBlocked),
+			AsrConfidence:    q.ASRConfidence,
+			SpeechCoverage:   q.SpeechCoverage,
+			Flagged:          q.Flagged,
+			Reasons:          q.Reasons,
+		}
+	}
+	return out
+}
+
+func toEvent(ev client.ProgressEvent) *pb.ProgressEvent {
+	out := &pb.ProgressEvent{
+		Event:       ev.Event,
+		Stage:       ev.Stage,
+		ElapsedMs:   ev.ElapsedMs,
+		Result:      toResponse(ev.Result),
+		FramesDone:  int32(ev.FramesDone),
+		FramesTotal: int32(ev.FramesTotal),
+	}
+	if ev.Stream != nil {
+		out.Stream = toStreamResult(ev.Stream)
+	}
+	return out
+}
+
+func toJob(j *client.Job) *pb.Job {
+	return &pb.Job{
+		JobId:     j.ID,
+		AdId:      j.AdID,
+		Status:    j.Status,
+		Stage:     j.Stage,
+		Streams:   toStreamResults(j.Streams),
+		Progress:  j.Progress,
+		Result:    toResponse(j.Result),
+		Error:     j.Error,
+		CreatedAt: timestamppb.New(j.CreatedAt),
+		UpdatedAt: timestamppb.New(j.UpdatedAt),
+	}
+}
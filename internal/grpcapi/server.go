@@ -0,0 +1,227 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+	pb "github.com/nikipaj1/video-description-pipeline/pkg/pipelinepb"
+)
+
+// Options are the checks every call passes, as the HTTP API applies them.
+type Options struct {
+	// Auth checks the bearer token in the "authorization" metadata, or the
+	// "x-api-key" metadata; nil lets every call through
+	Auth *auth.Authenticator
+
+	// RequireClientCert rejects calls over connections without a verified
+	// client certificate
+	RequireClientCert bool
+
+	// ExtractIPs limits who may start jobs; "x-forwarded-for" metadata is
+	// trusted as the X-Forwarded-For header is
+	ExtractIPs *auth.IPAllowlist
+}
+
+// scopes are the scope each method needs; those that start jobs are also
+// subject to Options.ExtractIPs.
+var scopes = map[string]string{
+	pb.Pipeline_Extract_FullMethodName:       auth.ScopeExtract,
+	pb.Pipeline_ExtractStream_FullMethodName: auth.ScopeExtract,
+	pb.Pipeline_Submit_FullMethodName:        auth.ScopeExtract,
+	pb.Pipeline_GetJob_FullMethodName:        auth.ScopeRead,
+	pb.Pipeline_GetResults_FullMethodName:    auth.ScopeRead,
+}
+
+// NewServer returns a gRPC server with the Pipeline service registered,
+// answering calls through the same handlers as the HTTP API.
+func NewServer(extract *handler.ExtractHandler, results *handler.ResultsHandler, opts Options, serverOpts ...grpc.ServerOption) *grpc.Server {
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+			ctx, err := opts.check(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+			ctx, err := opts.check(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return next(srv, &contextStream{ss, ctx})
+		}),
+	)
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterPipelineServer(s, &service{extract: extract, results: results})
+	return s
+}
+
+// check admits a call to method, returning ctx with the caller's
+// principal for auth.FromContext.
+func (o Options) check(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := scopes[method]
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "unknown method")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, _ := peer.FromContext(ctx)
+
+	if scope == auth.ScopeExtract && o.ExtractIPs != nil {
+		if p == nil || !o.ExtractIPs.Allows(p.Addr.String(), md.Get("x-forwarded-for")) {
+			return nil, status.Error(codes.PermissionDenied, "forbidden")
+		}
+	}
+	if o.RequireClientCert {
+		var principal *auth.Principal
+		if p != nil {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				principal, _ = auth.ClientCertPrincipal(&info.State)
+			}
+		}
+		if principal == nil {
+			return nil, status.Error(codes.PermissionDenied, "client certificate required")
+		}
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+	if o.Auth == nil {
+		return ctx, nil
+	}
+
+	var token string
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+	} else if v := md.Get("x-api-key"); len(v) > 0 {
+		token = v[0]
+	}
+	principal, err := o.Auth.Authorize(ctx, token, scope)
+	var scopeErr *auth.ScopeError
+	var rateErr *auth.RateLimitError
+	switch {
+	case errors.Is(err, auth.ErrKeysUnavailable):
+		return nil, status.Error(codes.Unavailable, "authentication unavailable")
+	case errors.As(err, &scopeErr):
+		return nil, status.Error(codes.PermissionDenied, "forbidden: "+err.Error())
+	case errors.As(err, &rateErr):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Unauthenticated, "unauthorized: "+err.Error())
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// contextStream is a server stream carrying the context check returned.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+type service struct {
+	pb.UnimplementedPipelineServer
+	extract *handler.ExtractHandler
+	results *handler.ResultsHandler
+}
+
+func (s *service) Extract(ctx context.Context, req *pb.ExtractRequest) (*pb.ExtractResponse, error) {
+	resp, err := s.extract.Extract(ctx, fromRequest(req))
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return toResponse(resp), nil
+}
+
+func (s *service) ExtractStream(req *pb.ExtractRequest, stream grpc.ServerStreamingServer[pb.ProgressEvent]) error {
+	// A send that fails means the client has gone, which ends the stream's
+	// context and with it the job
+	t0 := time.Now()
+	resp, err := s.extract.ExtractWithProgress(stream.Context(), fromRequest(req), func(ev client.ProgressEvent) {
+		stream.Send(toEvent(ev))
+	})
+	if err != nil {
+		return statusOf(err)
+	}
+	return stream.Send(&pb.ProgressEvent{Event: "result", Result: toResponse(resp), ElapsedMs: time.Since(t0).Milliseconds()})
+}
+
+func (s *service) Submit(ctx context.Context, req *pb.ExtractRequest) (*pb.Job, error) {
+	body := fromRequest(req)
+	if err := s.extract.Prepare(ctx, &body); err != nil {
+		return nil, statusOf(err)
+	}
+	// The job outlives the call
+	job, err := s.extract.Submit(context.WithoutCancel(ctx), body)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toJob(job), nil
+}
+
+func (s *service) GetJob(ctx context.Context, req *pb.GetJobRequest) (*pb.Job, error) {
+	job, err := s.extract.Job(ctx, req.GetJobId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return toJob(job), nil
+}
+
+func (s *service) GetResults(ctx context.Context, req *pb.GetResultsRequest) (*pb.Results, error) {
+	res, err := s.results.Load(ctx, req.GetAdId())
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	out := &pb.Results{AdId: res.AdID, Artifacts: make(map[string][]byte, len(res.Artifacts))}
+	for name, data := range res.Artifacts {
+		out.Artifacts[name] = data
+	}
+	return out, nil
+}
+
+// statusOf is err as a gRPC status, its code matching the HTTP status the
+// HTTP API would answer with.
+func statusOf(err error) error {
+	code := codes.Internal
+	var jobErr *handler.JobError
+	switch {
+	case errors.Is(err, handler.ErrNoSuchJob), errors.Is(err, handler.ErrNoResults):
+		code = codes.NotFound
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.As(err, &jobErr):
+		code = codeFor(jobErr.Status)
+	}
+	return status.Error(code, err.Error())
+}
+
+func codeFor(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+const (
+	defaultAdsLimit = 100
+	maxAdsLimit     = 1000
+)
+
+type (
+	AdList    = client.AdList
+	AdSummary = client.AdSummary
+)
+
+// ServeAds serves GET /ads: the ads with extraction results, in ID order,
+// with when each stream's result was written. ?prefix= restricts them to
+// IDs with that prefix, ?limit= sets the page size, and ?token= continues
+// from the page that returned it as next_token. Only streams with a JSON
+// result are listed, so the bundle is not.
+func (h *ExtractHandler) ServeAds(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	prefix, token := q.Get("prefix"), q.Get("token")
+	if strings.Contains(prefix, "/") || strings.Contains(token, "/") {
+		apierr.Write(w, "prefix and token must not contain /", http.StatusBadRequest)
+		return
+	}
+	limit := defaultAdsLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxAdsLimit {
+			apierr.Write(w, "limit must be between 1 and "+strconv.Itoa(maxAdsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ads, next, err := h.r2.ListAdPage(req.Context(), prefix, token, limit)
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out := AdList{Ads: make([]AdSummary, 0, len(ads)), NextToken: next}
+	for _, ad := range ads {
+		sum := AdSummary{AdID: ad.AdID, Streams: map[string]time.Time{}}
+		a := &Assets{AdID: ad.AdID}
+		for _, s := range h.streams {
+			c, ok := s.(Cacheable)
+			if !ok {
+				continue
+			}
+			if modified, ok := ad.Results[path.Base(c.Key(a))]; ok {
+				sum.Streams[s.Name()] = modified
+			}
+		}
+		for _, modified := range ad.Results {
+			if modified.After(sum.UpdatedAt) {
+				sum.UpdatedAt = modified
+			}
+		}
+		out.Ads = append(out.Ads, sum)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
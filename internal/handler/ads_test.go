@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+)
+
+// TestServeAdsPaging follows next_token through GET /ads until the last
+// page, past ads without results.
+func TestServeAdsPaging(t *testing.T) {
+	store := s3fake.New()
+	var want []string
+	for i := range 12 {
+		id := fmt.Sprintf("ad%02d", i)
+		store.Put(testBucket, "ads/"+id+"/video.mp4", []byte("v"), "video/mp4")
+		if i%4 == 3 {
+			continue // not extracted yet
+		}
+		store.Put(testBucket, "ads/"+id+"/extraction/asr_results.json", []byte(`{}`), "application/json")
+		want = append(want, id)
+	}
+	h := newTestHandler(t, store, nil)
+
+	var got []string
+	token, pages := "", 0
+	for {
+		var page AdList
+		q := url.Values{"limit": {"4"}, "token": {token}}
+		if code := serve(t, h.ServeAds, httptest.NewRequest(http.MethodGet, "/ads?"+q.Encode(), nil), &page); code != http.StatusOK {
+			t.Fatalf("page %d: %d", pages, code)
+		}
+		pages++
+		for _, ad := range page.Ads {
+			if ad.Streams["asr"].IsZero() || ad.UpdatedAt.IsZero() {
+				t.Errorf("%s = %+v", ad.AdID, ad)
+			}
+			got = append(got, ad.AdID)
+		}
+		if page.NextToken == "" {
+			break
+		}
+		if len(page.Ads) != 4 || page.NextToken != page.Ads[3].AdID {
+			t.Fatalf("page %d: %d ads, next_token %q", pages, len(page.Ads), page.NextToken)
+		}
+		token = page.NextToken
+	}
+	if !slices.Equal(got, want) || pages != 3 {
+		t.Errorf("listed %v in %d pages, want %v", got, pages, want)
+	}
+
+	var page AdList
+	serve(t, h.ServeAds, httptest.NewRequest(http.MethodGet, "/ads?prefix=ad1", nil), &page)
+	if len(page.Ads) != 1 || page.Ads[0].AdID != "ad10" || page.NextToken != "" {
+		t.Errorf("prefix ad1: %+v", page)
+	}
+
+	for _, query := range []string{"limit=0", "limit=1001", "limit=x", "prefix=a/b", "token=ad01/extraction"} {
+		if code := serve(t, h.ServeAds, httptest.NewRequest(http.MethodGet, "/ads?"+query, nil), nil); code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", query, code)
+		}
+	}
+}
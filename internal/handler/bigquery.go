@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/bigquery"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// sinkJob gathers what a job produced for the BigQuery sink: transcript
+// segments, frame descriptions, and the scalar fields of every other
+// stream's output and of the quality score as attributes. Streams that
+// failed or were skipped contribute nothing.
+func sinkJob(a *Assets, quality *streams.QualityScore) bigquery.Job {
+	ctx := context.Background() // every stream has finished
+	job := bigquery.Job{
+		AdID:        a.AdID,
+		ExtractedAt: time.Now(),
+		Attributes:  map[string]any{"quality": quality},
+	}
+	if asr := output[*streams.ASRResult](ctx, a, "asr"); asr != nil {
+		job.Segments = asr.Segments
+	}
+	if vlm := output[*streams.VLMResult](ctx, a, "vlm"); vlm != nil {
+		job.Frames = vlm.Frames
+	}
+	for name, v := range a.outputs() {
+		if name != "asr" && name != "vlm" {
+			job.Attributes[name] = v
+		}
+	}
+	return job
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// CompareHandler serves POST /compare: a Gemini-written comparison of two
+// ads that have been extracted, typically an original and its variant. It
+// reads their stored timelines, plus key moments and hook analyses where
+// present, and stores nothing.
+type CompareHandler struct {
+	cfg *config.Config
+	r2  *r2.Client
+}
+
+func NewCompareHandler(cfg *config.Config, r2Client *r2.Client) *CompareHandler {
+	return &CompareHandler{cfg: cfg, r2: r2Client}
+}
+
+func (h *CompareHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body client.CompareRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+	body.AdA, body.AdB = strings.TrimSpace(body.AdA), strings.TrimSpace(body.AdB)
+	switch {
+	case body.AdA == "" || body.AdB == "":
+		apierr.Write(w, "ad_a and ad_b are required", http.StatusBadRequest)
+		return
+	case body.AdA == body.AdB:
+		apierr.Write(w, "ad_a and ad_b must be different ads", http.StatusBadRequest)
+		return
+	}
+	if !h.cfg.GeminiConfigured() {
+		apierr.Write(w, "Gemini not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := streams.GeminiHealth(); err != nil {
+		apierr.Write(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := req.Context()
+	var inputs [2]streams.CompareInput
+	for i, adID := range []string{body.AdA, body.AdB} {
+		in, err := h.load(ctx, adID)
+		if errors.Is(err, r2.ErrNotFound) {
+			apierr.Write(w, fmt.Sprintf("ad %s has no timeline; run /extract first", adID), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		inputs[i] = in
+	}
+
+	cmp, err := streams.RunCompare(ctx, inputs[0], inputs[1], h.cfg.GeminiAPIKey)
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+// load reads what the comparison uses of one ad's results. Only the
+// timeline is required; its absence is reported as r2.ErrNotFound.
+func (h *CompareHandler) load(ctx context.Context, adID string) (streams.CompareInput, error) {
+	in := streams.CompareInput{AdID: adID, Timeline: &streams.Timeline{}}
+	if err := h.r2.DownloadJSON(ctx, extractionKey(adID, "timeline.json"), in.Timeline); err != nil {
+		return in, err
+	}
+	var moments streams.KeyMomentsResult
+	switch err := h.r2.DownloadJSON(ctx, extractionKey(adID, "key_moments.json"), &moments); {
+	case err == nil:
+		in.KeyMoments = &moments
+	case !errors.Is(err, r2.ErrNotFound):
+		return in, err
+	}
+	var hook streams.HookResult
+	switch err := h.r2.DownloadJSON(ctx, extractionKey(adID, "hook_analysis.json"), &hook); {
+	case err == nil:
+		in.Hook = &hook
+	case !errors.Is(err, r2.ErrNotFound):
+		return in, err
+	}
+	return in, nil
+}
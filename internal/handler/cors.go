@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configure which browser origins may call the API.
+type CORSOptions struct {
+	Origins []string // exact origins, e.g. "https://dash.example.com", or "*"
+	Methods []string
+	Headers []string // request headers scripts may send
+	MaxAge  time.Duration
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins. Other origins get no CORS headers, so browsers keep them
+// from reading responses; requests without an Origin pass through as is.
+// It must wrap authentication: preflights carry no credentials.
+func CORS(opts CORSOptions, next http.Handler) http.Handler {
+	if len(opts.Origins) == 0 {
+		return next
+	}
+	anyOrigin := slices.Contains(opts.Origins, "*")
+	methods := strings.Join(opts.Methods, ", ")
+	headers := strings.Join(opts.Headers, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := anyOrigin || slices.Contains(opts.Origins, origin)
+		// Credentials go in headers scripts set, not cookies, so responses
+		// are never shared with credentials mode
+		if allowed && anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if allowed {
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if allowed {
+			h.Set("Access-Control-Expose-Headers", "Retry-After, Content-Disposition")
+		}
+		next.ServeHTTP(w, req)
+	})
+}
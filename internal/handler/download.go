@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// DownloadHandler serves GET /results/{ad_id}/download?artifact=<file>: a
+// presigned URL for one of the ad's stored artifacts, such as
+// vlm_results.json or bundle.zip, that works without credentials until it
+// expires. The optional expires parameter (e.g. "168h") picks the lifetime,
+// up to cfg.DownloadURLMaxTTL; it defaults to cfg.DownloadURLTTL.
+type DownloadHandler struct {
+	cfg *config.Config
+	r2  *r2.Client
+}
+
+func NewDownloadHandler(cfg *config.Config, r2Client *r2.Client) *DownloadHandler {
+	return &DownloadHandler{cfg: cfg, r2: r2Client}
+}
+
+func (h *DownloadHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	adID := req.PathValue("ad_id")
+	artifact := req.URL.Query().Get("artifact")
+	if artifact == "" {
+		apierr.Write(w, "artifact is required", http.StatusBadRequest)
+		return
+	}
+	// Only files directly under the ad's extraction prefix
+	if path.Base(artifact) != artifact || artifact == ".." {
+		apierr.Write(w, "artifact must be a file name such as vlm_results.json", http.StatusBadRequest)
+		return
+	}
+	ttl := h.cfg.DownloadURLTTL
+	if v := req.URL.Query().Get("expires"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > h.cfg.DownloadURLMaxTTL {
+			apierr.Write(w, fmt.Sprintf("expires must be a duration such as 72h, at most %s", h.cfg.DownloadURLMaxTTL), http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	ctx := req.Context()
+	key := extractionKey(adID, artifact)
+	ok, err := h.r2.Exists(ctx, key)
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !ok {
+		apierr.Write(w, fmt.Sprintf("ad %s has no %s", adID, artifact), http.StatusNotFound)
+		return
+	}
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	url, err := h.r2.PresignGet(ctx, key, ttl)
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(client.DownloadLink{URL: url, ExpiresAt: expiresAt})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/bufpool"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// dryRun reads a job's inputs from R2 and reports what running it would
+// do, without a worker slot. No provider is called and nothing is stored:
+// a video_url is not fetched, and ads without keyframe metadata get no
+// keyframes from ffmpeg. Streams are reported as cached where a stored
+// result would be reused, skipped where Checkable says so, and would_run
+// otherwise.
+func (h *ExtractHandler) dryRun(ctx context.Context, body ExtractRequest) (*ExtractResponse, error) {
+	t0 := time.Now()
+	a := h.newAssets(ctx, body)
+	a.fetchKeyframes = func() []r2.KeyframeMeta {
+		metas, err := h.r2.DownloadKeyframeMetadata(ctx, body.AdID)
+		if err != nil {
+			log.Printf("WARN: no keyframe metadata for %s: %v", body.AdID, err)
+			return nil
+		}
+		return metas
+	}
+	defer a.closeVideo()
+
+	a.prefetchKeyframes()
+	video, err := a.Video(ctx)
+	if err != nil {
+		return nil, &JobError{Status: http.StatusInternalServerError, Err: fmt.Errorf("download video: %w", err)}
+	}
+	report := &client.DryRun{VideoBytes: video.Size()}
+	metas := a.Keyframes(ctx)
+	report.Keyframes = len(metas)
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	for _, m := range metas {
+		buf.Reset()
+		if err := h.r2.DownloadObjectTo(ctx, m.R2Key, buf); err != nil {
+			log.Printf("WARN: dry run of %s: %v", body.AdID, err)
+			report.UnreadableKeyframes++
+		}
+	}
+
+	// In registration order, so reusable sees whether what a stream
+	// requires would be cached
+	a.runs = make(map[string]*streamRun, len(h.streams))
+	for _, s := range h.streams {
+		a.runs[s.Name()] = &streamRun{wanted: h.wanted(s, a)}
+	}
+	resp := &ExtractResponse{AdID: body.AdID, DryRun: report}
+	for _, s := range h.streams {
+		run := a.runs[s.Name()]
+		if !run.wanted {
+			continue
+		}
+		run.result = StreamResult{Stream: s.Name(), Status: "would_run"}
+		if c, ok := s.(Cacheable); ok && h.reusable(s, a) {
+			if key := c.Key(a); key != "" {
+				if sr, _, ok := h.cached(ctx, s, key, a); ok {
+					run.result = sr
+				}
+			}
+		}
+		if c, ok := s.(Checkable); ok && run.result.Status == "would_run" {
+			if err := c.Check(ctx, a); err != nil {
+				run.result.Status, run.result.Error = "skipped", err.Error()
+			}
+		}
+		resp.Streams = append(resp.Streams, run.result)
+	}
+
+	if run := a.runs["vlm"]; run != nil && run.result.Status == "would_run" {
+		opts := h.vlmOptions(a.MaxFrames)
+		opts.PromptTemplate = body.VLMPromptTemplate
+		report.FramesDescribed = len(metas)
+		if a.MaxFrames > 0 {
+			report.FramesDescribed = min(len(metas), a.MaxFrames)
+		}
+		report.GeminiCalls = streams.VLMCalls(len(metas), opts, a.Batch)
+	}
+	resp.ProcessingTimeMs = float64(time.Since(t0).Milliseconds())
+	return resp, nil
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/events"
+)
+
+// maxEventBody bounds a notification body; S3 batches are far smaller.
+const maxEventBody = 1 << 20
+
+// StorageEventsHandler serves POST /events/storage: S3 or R2 event
+// notifications that start jobs once an ad's video and keyframes are both
+// in the bucket.
+type StorageEventsHandler struct {
+	token   string
+	trigger *events.Trigger
+}
+
+// NewStorageEventsHandler requires token as a bearer token or a token query
+// parameter, the latter for senders such as SNS that cannot set headers. An
+// empty token refuses every request.
+func NewStorageEventsHandler(token string, trigger *events.Trigger) *StorageEventsHandler {
+	return &StorageEventsHandler{token: token, trigger: trigger}
+}
+
+func (h *StorageEventsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		got = req.URL.Query().Get("token")
+	}
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) != 1 {
+		apierr.Write(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxEventBody))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		tooLarge(w, tooBig.Limit)
+		return
+	}
+	if err != nil {
+		apierr.Write(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	evs, err := events.Parse(body)
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, ev := range evs {
+		if err := h.trigger.Handle(req.Context(), ev); err != nil {
+			// Asking the sender to redeliver is the only way to retry
+			log.Printf("WARN: storage event %s: %v", ev.Key, err)
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]int{"events": len(evs)})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/events"
+)
+
+func TestStorageEventsToken(t *testing.T) {
+	trigger := events.NewTrigger(func(context.Context, string) error {
+		t.Error("job submitted")
+		return nil
+	}, func(context.Context, string) (bool, error) { return false, nil }, 0)
+
+	for _, tc := range []struct {
+		name, token, auth, query string
+		want                     int
+	}{
+		{"no token configured", "", "", "", http.StatusUnauthorized},
+		{"no token configured, empty bearer", "", "Bearer ", "", http.StatusUnauthorized},
+		{"missing", "secret", "", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer nope", "", http.StatusUnauthorized},
+		{"bearer", "secret", "Bearer secret", "", http.StatusBadRequest},
+		{"query", "secret", "", "?token=secret", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/events/storage"+tc.query, strings.NewReader("not json"))
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		NewStorageEventsHandler(tc.token, trigger).ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
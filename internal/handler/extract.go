@@ -1,195 +1,568 @@
 package handler
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/bigquery"
 	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/gcpauth"
+	"github.com/nikipaj1/video-description-pipeline/internal/ingest"
+	"github.com/nikipaj1/video-description-pipeline/internal/notify"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
 	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/redact"
+	"github.com/nikipaj1/video-description-pipeline/internal/statsd"
 	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/internal/webhook"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
 )
 
 type ExtractHandler struct {
-	cfg *config.Config
-	r2  *r2.Client
-}
+	cfg     *config.Config
+	r2      *r2.Client
+	workers *pool.Pool
+	memory  *admission.Budget
+	notify  *notify.Notifier
+	sink    *bigquery.Sink
+	stats   *statsd.Client
+	streams []Stream
+
+	// remote, if set, runs jobs in place of run
+	remote func(ctx context.Context, body ExtractRequest) (*ExtractResponse, error)
+
+	streamDone []func(adID string, sr StreamResult)
 
-func NewExtractHandler(cfg *config.Config, r2Client *r2.Client) *ExtractHandler {
-	return &ExtractHandler{cfg: cfg, r2: r2Client}
+	jobs jobs // async jobs
 }
 
-type extractRequest struct {
-	AdID string `json:"ad_id"`
+func NewExtractHandler(cfg *config.Config, r2Client *r2.Client, workers *pool.Pool, memory *admission.Budget) *ExtractHandler {
+	stats, err := statsd.New(cfg.StatsDAddr, cfg.StatsDPrefix, statsd.ParseTags(cfg.StatsDTags), cfg.StatsDFormat == "dogstatsd")
+	if err != nil {
+		log.Printf("WARN: metrics will not be pushed: %v", err)
+	}
+	if stats != nil {
+		go pushMetrics(stats, workers, memory, cfg.StatsDInterval)
+	}
+	h := &ExtractHandler{
+		cfg:     cfg,
+		r2:      r2Client,
+		workers: workers,
+		memory:  memory,
+		notify:  notify.New(cfg.NotifyWebhookURL, cfg.PublicURL, cfg.NotifyFailureThreshold),
+		sink: bigquery.New(gcpauth.NewTokenSource(cfg.GoogleCredentialsFile, bigquery.Scope).Token, bigquery.Options{
+			Project:       cfg.BigQueryProject,
+			Dataset:       cfg.BigQueryDataset,
+			TablePrefix:   cfg.BigQueryTablePrefix,
+			BatchRows:     cfg.BigQueryBatchRows,
+			FlushInterval: cfg.BigQueryFlushInterval,
+		}),
+		stats: stats,
+	}
+	for _, s := range builtinStreams(h) {
+		if err := h.Register(s); err != nil {
+			panic(err)
+		}
+	}
+	return h
 }
 
-type streamResult struct {
-	Stream      string `json:"stream"`
-	Status      string `json:"status"` // "success" | "error" | "skipped"
-	ResultCount int    `json:"result_count"`
-	R2Key       string `json:"r2_key,omitempty"`
-	Error       string `json:"error,omitempty"`
+// RunWith hands jobs to run instead of running them here, e.g. to start
+// them as workflows. The handler still validates requests and answers with
+// what run returns; the outcome is recorded wherever the job actually runs.
+func (h *ExtractHandler) RunWith(run func(ctx context.Context, body ExtractRequest) (*ExtractResponse, error)) {
+	h.remote = run
 }
 
-type extractResponse struct {
-	AdID             string         `json:"ad_id"`
-	Streams          []streamResult `json:"streams"`
-	ProcessingTimeMs float64        `json:"processing_time_ms"`
+// Flush sends results the BigQuery sink still holds, for commands to call
+// before exiting.
+func (h *ExtractHandler) Flush(ctx context.Context) error {
+	return h.sink.Flush(ctx)
 }
 
+// The request and response bodies are the Go client's types, so the two
+// cannot drift apart.
+type (
+	ExtractRequest  = client.ExtractRequest
+	StreamResult    = client.StreamResult
+	ExtractResponse = client.ExtractResponse
+)
+
 func (h *ExtractHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		apierr.Write(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var body extractRequest
-	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	var body ExtractRequest
+	if !decodeJSON(w, req, &body) {
 		return
 	}
-	if body.AdID == "" {
-		http.Error(w, "ad_id is required", http.StatusBadRequest)
+	if err := h.Prepare(req.Context(), &body); err != nil {
+		var jobErr *JobError
+		errors.As(err, &jobErr)
+		apierr.Write(w, err.Error(), jobErr.Status)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Minute)
-	defer cancel()
+	// Async jobs are answered with their ID straight away and followed
+	// through GET /v1/jobs/{id}
+	if req.URL.Query().Get("async") == "true" {
+		job, err := h.Submit(req.Context(), body)
+		if err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", APIVersion+"/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
 
-	t0 := time.Now()
+	// Clients that accept JSON lines get a 200 straight away and periodic
+	// heartbeats, so idle timeouts in front of us do not cut long jobs off.
+	var progress *progressStream
+	if wantsProgress(req) {
+		progress = startProgress(w, h.cfg.ProgressInterval)
+		defer progress.close()
+	}
 
-	// Download video bytes from R2 (needed for Deepgram)
-	videoBytes, err := h.r2.DownloadVideo(ctx, body.AdID)
+	var reporter progressReporter = noProgress{}
+	if progress != nil {
+		reporter = progress
+	}
+	resp, err := h.execute(req.Context(), body, reporter)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("download video: %v", err), http.StatusInternalServerError)
+		jobErr := &JobError{Status: http.StatusInternalServerError, Err: err}
+		errors.As(err, &jobErr)
+		if progress != nil {
+			progress.finish(progressEvent{Event: "error", Status: jobErr.Status, Error: err.Error()})
+			return
+		}
+		if jobErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(jobErr.RetryAfter.Seconds())))
+		}
+		apierr.Write(w, err.Error(), jobErr.Status)
 		return
 	}
 
-	// Download keyframe metadata (needed for VLM)
-	keyframeMetas, err := h.r2.DownloadKeyframeMetadata(ctx, body.AdID)
-	if err != nil {
-		log.Printf("WARN: no keyframe metadata for %s: %v (VLM will be skipped)", body.AdID, err)
-		keyframeMetas = nil
+	if progress != nil {
+		progress.finish(progressEvent{Event: "result", Result: resp})
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
-	// Download keyframe images for VLM
-	var keyframeInputs []streams.KeyframeInput
-	if keyframeMetas != nil {
-		images, err := h.r2.DownloadKeyframeImages(ctx, body.AdID, keyframeMetas)
-		if err != nil {
-			log.Printf("WARN: failed to download keyframe images for %s: %v", body.AdID, err)
-		} else {
-			for _, m := range keyframeMetas {
-				if imgBytes, ok := images[m.R2Key]; ok {
-					keyframeInputs = append(keyframeInputs, streams.KeyframeInput{
-						FrameIndex:   m.Index,
-						TimestampSec: m.TimestampSec,
-						ImageBytes:   imgBytes,
-					})
-				}
+// JobError is a job that could not run: no worker slot or memory, or its
+// video could not be opened. Status is the HTTP status /extract answers with.
+type JobError struct {
+	Status     int
+	RetryAfter time.Duration // suggested wait before resubmitting, if any
+	Err        error
+}
+
+func (e *JobError) Error() string { return e.Err.Error() }
+func (e *JobError) Unwrap() error { return e.Err }
+
+// Prepare checks body before it is run or submitted, as POST /extract does.
+// Keys bound to a tenant, as FromContext reports on ctx, run jobs for that
+// tenant only, and body's tenant is set to theirs. Otherwise it is cleared:
+// the tenant picks the webhook signing secret, so callers may not choose
+// it. It fails with a *JobError with status 400 or 403.
+func (h *ExtractHandler) Prepare(ctx context.Context, body *ExtractRequest) error {
+	if err := h.validateRequest(body); err != nil {
+		return &JobError{Status: http.StatusBadRequest, Err: err}
+	}
+	p := auth.FromContext(ctx)
+	if p == nil || p.Tenant == "" {
+		body.Tenant = ""
+		return nil
+	}
+	if body.Tenant != "" && body.Tenant != p.Tenant {
+		return &JobError{Status: http.StatusForbidden, Err: fmt.Errorf("forbidden: the key is bound to tenant %q", p.Tenant)}
+	}
+	body.Tenant = p.Tenant
+	return nil
+}
+
+// Extract runs a job in-process exactly as POST /extract would, for
+// commands and APIs that drive the pipeline without going through HTTP.
+// Requests Prepare rejects are reported as its *JobError.
+func (h *ExtractHandler) Extract(ctx context.Context, body ExtractRequest) (*ExtractResponse, error) {
+	if err := h.Prepare(ctx, &body); err != nil {
+		return nil, err
+	}
+	return h.execute(ctx, body, noProgress{})
+}
+
+// ExtractWithProgress is Extract, calling report as the job moves between
+// stages, its streams start and finish, and frames are described. report
+// is called from one goroutine at a time; it does not receive the final
+// result or error, which are returned.
+func (h *ExtractHandler) ExtractWithProgress(ctx context.Context, body ExtractRequest, report func(client.ProgressEvent)) (*ExtractResponse, error) {
+	if err := h.Prepare(ctx, &body); err != nil {
+		return nil, err
+	}
+	progress := &progressFunc{report: report, t0: time.Now()}
+	progress.setStage("queued")
+	ctx = streams.WithFrameProgress(ctx, progress.frameDone)
+	return h.execute(ctx, body, progress)
+}
+
+func (h *ExtractHandler) execute(ctx context.Context, body ExtractRequest, progress progressReporter) (*ExtractResponse, error) {
+	// Dry runs only read from R2, so they run here in any execution mode
+	if body.DryRun {
+		resp, err := h.dryRun(ctx, body)
+		return resp, redact.Err(err)
+	}
+	// Fetched here, so the video is in place wherever the job runs
+	if err := h.ingestVideo(ctx, body); err != nil {
+		err = redact.Err(err)
+		h.reportOutcome(ctx, body.AdID, nil, err)
+		return nil, err
+	}
+	if h.remote != nil {
+		resp, err := h.remote(ctx, body)
+		return resp, redact.Err(err)
+	}
+	resp, err := h.run(ctx, body, progress)
+	err = redact.Err(err)
+	h.reportOutcome(ctx, body.AdID, resp, err)
+	return resp, err
+}
+
+// reportOutcome records the job's metrics and counts it towards the ad's
+// failure alerts: it failed if it could not run, a stream errored or it ran
+// out of time. Jobs turned away for lack of capacity, or abandoned by the
+// caller, say nothing about the ad and are not counted towards alerts.
+func (h *ExtractHandler) reportOutcome(ctx context.Context, adID string, resp *ExtractResponse, err error) {
+	h.recordJob(resp, err)
+	var jobErr *JobError
+	if ctx.Err() != nil || (errors.As(err, &jobErr) && jobErr.Status == http.StatusServiceUnavailable) {
+		return
+	}
+	var errs []string
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		for _, sr := range resp.Streams {
+			if sr.Status == "error" {
+				errs = append(errs, sr.Stream+": "+sr.Error)
 			}
 		}
+		if resp.Partial && len(errs) == 0 {
+			errs = append(errs, "job timed out with partial results")
+		}
 	}
+	h.notify.JobFinished(adID, errs)
+}
 
-	// Run Deepgram + VLM concurrently
-	var (
-		mu          sync.Mutex
-		results     []streamResult
-		wg          sync.WaitGroup
-	)
-
-	// ASR stream (Deepgram) — starts immediately, only needs video bytes
-	if h.cfg.DeepgramAPIKey != "" {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			sr := h.runASR(ctx, body.AdID, videoBytes)
-			mu.Lock()
-			results = append(results, sr)
-			mu.Unlock()
-		}()
-	} else {
-		results = append(results, streamResult{
-			Stream: "asr", Status: "skipped", Error: "DEEPGRAM_API_KEY not configured",
-		})
+func (h *ExtractHandler) validateRequest(r *ExtractRequest) error {
+	if r.AdID == "" {
+		return errors.New("ad_id is required")
+	}
+	for _, name := range r.Streams {
+		if !slices.ContainsFunc(h.streams, func(s Stream) bool { return s.Name() == name }) {
+			return fmt.Errorf("streams: unknown stream %q", name)
+		}
+	}
+	if r.MaxFrames != nil && *r.MaxFrames < 0 {
+		return errors.New("max_frames must not be negative")
 	}
+	if r.Priority != "" && r.Priority != client.PriorityInteractive && r.Priority != client.PriorityBatch {
+		return errors.New(`priority must be "interactive" or "batch"`)
+	}
+	if r.Mode != "" && r.Mode != client.ModeFillMissing {
+		return fmt.Errorf("mode must be %q", client.ModeFillMissing)
+	}
+	if r.Mode == client.ModeFillMissing && r.Force {
+		return errors.New("mode fill-missing cannot be combined with force")
+	}
+	if r.QueuePriority < client.QueuePriorityMin || r.QueuePriority > client.QueuePriorityMax {
+		return fmt.Errorf("queue_priority must be from %d to %d", client.QueuePriorityMin, client.QueuePriorityMax)
+	}
+	if r.VLMPromptTemplate != "" {
+		if err := streams.ValidatePromptTemplate(r.VLMPromptTemplate); err != nil {
+			return fmt.Errorf("vlm_prompt_template: %w", err)
+		}
+	}
+	if err := streams.ValidateDeepgramParams(r.DeepgramParams); err != nil {
+		return fmt.Errorf("deepgram_params: %w", err)
+	}
+	if model := r.DeepgramParams["model"]; model != "" {
+		if err := streams.ValidateDeepgramModel(model); err != nil {
+			return fmt.Errorf("deepgram_params: %w", err)
+		}
+	}
+	if r.Language != "" {
+		if err := streams.ValidateLanguage(r.Language); err != nil {
+			return err
+		}
+	}
+	if r.ASRModel != "" {
+		if err := streams.ValidateDeepgramModel(r.ASRModel); err != nil {
+			return fmt.Errorf("asr_model: %w", err)
+		}
+	}
+	if r.VLMModel != "" {
+		if err := streams.ValidateGeminiModel(r.VLMModel); err != nil {
+			return fmt.Errorf("vlm_model: %w", err)
+		}
+	}
+	if r.VideoURL != "" {
+		if err := ingest.ValidateURL(r.VideoURL, h.cfg.VideoURLAllowedHosts); err != nil {
+			return fmt.Errorf("video_url: %w", err)
+		}
+	}
+	if r.WebhookURL != "" {
+		if err := webhook.ValidateURL(r.WebhookURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// VLM stream (Gemini) — needs keyframe images
-	if h.cfg.GeminiAPIKey != "" && len(keyframeInputs) > 0 {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			sr := h.runVLM(ctx, body.AdID, keyframeInputs)
-			mu.Lock()
-			results = append(results, sr)
-			mu.Unlock()
-		}()
-	} else {
-		reason := "GEMINI_API_KEY not configured"
-		if len(keyframeInputs) == 0 {
-			reason = "no keyframe images available"
+// run is one extraction job. progress is told as the job moves between
+// stages and streams.
+func (h *ExtractHandler) run(ctx context.Context, body ExtractRequest, progress progressReporter) (*ExtractResponse, error) {
+	batch := body.Priority == client.PriorityBatch
+
+	// Wait for a worker slot; time spent queued does not count against the
+	// job's deadline. Queued jobs start highest queue_priority first. Batch
+	// jobs spend nearly all their time waiting on the Batch API and do not
+	// take one.
+	if !batch {
+		release, err := h.workers.AcquirePriority(ctx, body.QueuePriority)
+		if err != nil {
+			return nil, &JobError{Status: http.StatusServiceUnavailable, Err: fmt.Errorf("queued request abandoned: %w", err)}
 		}
-		results = append(results, streamResult{
-			Stream: "vlm", Status: "skipped", Error: reason,
-		})
+		defer release()
 	}
 
-	wg.Wait()
+	// Reserve the job's projected memory, waiting a bounded time for other
+	// jobs to free some.
+	admitCtx, admitCancel := context.WithTimeout(ctx, h.cfg.AdmissionWait)
+	releaseMem, err := h.memory.Reserve(admitCtx, h.jobMemory())
+	admitCancel()
+	if err != nil {
+		log.Printf("WARN: rejecting %s: %v", body.AdID, err)
+		return nil, &JobError{Status: http.StatusServiceUnavailable, RetryAfter: 30 * time.Second, Err: err}
+	}
+	defer releaseMem()
+	progress.setStage("extracting")
 
-	elapsed := time.Since(t0).Milliseconds()
+	ctx, cancel := context.WithTimeout(ctx, h.jobTimeout(batch))
+	defer cancel()
 
-	resp := extractResponse{
-		AdID:             body.AdID,
-		Streams:          results,
-		ProcessingTimeMs: float64(elapsed),
+	t0 := time.Now()
+	a := h.newAssets(ctx, body)
+	defer a.closeVideo()
+
+	// Keyframe metadata is fetched while the video is opened, so the ASR
+	// stream does not wait on keyframes and VLM does not wait on the video.
+	// The video is opened up front, though only ASR reads it, so that a
+	// missing video fails the job instead of just the stream.
+	a.prefetchKeyframes()
+	if _, err := a.Video(ctx); err != nil {
+		return nil, &JobError{Status: http.StatusInternalServerError, Err: fmt.Errorf("download video: %w", err)}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	results := h.runStreams(ctx, a, progress)
+	return h.finish(ctx, a, results, time.Since(t0), ctx.Err() != nil), nil
 }
 
-func (h *ExtractHandler) runASR(ctx context.Context, adID string, videoBytes []byte) streamResult {
-	asrResult, err := streams.RunASR(ctx, videoBytes, h.cfg.DeepgramAPIKey)
-	if err != nil {
-		log.Printf("ASR failed for %s: %v", adID, err)
-		return streamResult{Stream: "asr", Status: "error", Error: err.Error()}
+// jobTimeout is how long a job's streams may run. Batch jobs wait on the
+// Gemini Batch API.
+func (h *ExtractHandler) jobTimeout(batch bool) time.Duration {
+	if batch {
+		return h.cfg.GeminiBatchTimeout
+	}
+	return 5 * time.Minute
+}
+
+// newAssets prepares a job's inputs, which are fetched when first needed.
+// Ads the frame selector has not reached yet get keyframes from ffmpeg when
+// KEYFRAME_FALLBACK is set.
+func (h *ExtractHandler) newAssets(ctx context.Context, body ExtractRequest) *Assets {
+	a := &Assets{
+		AdID:          body.AdID,
+		Request:       body,
+		Batch:         body.Priority == client.PriorityBatch,
+		MaxFrames:     h.cfg.VLMMaxFrames,
+		Tokens:        streams.NewTokenBudget(int64(h.cfg.JobTokenBudget)),
+		keyframesDone: make(chan struct{}),
+		fetchKeyframes: func() []r2.KeyframeMeta {
+			metas, err := h.r2.DownloadKeyframeMetadata(ctx, body.AdID)
+			if errors.Is(err, r2.ErrNotFound) && h.cfg.KeyframeFallback != "" {
+				metas, err = h.extractKeyframes(ctx, body.AdID)
+			}
+			if err != nil {
+				log.Printf("WARN: no keyframe metadata for %s: %v (VLM will be skipped)", body.AdID, err)
+				return nil
+			}
+			return metas
+		},
+		openVideo: func(ctx context.Context) (*r2.ObjectReader, error) {
+			return h.r2.OpenVideo(ctx, body.AdID)
+		},
+	}
+	if len(body.Streams) > 0 {
+		a.only = body.Streams
+	}
+	if body.MaxFrames != nil {
+		a.MaxFrames = *body.MaxFrames
+	}
+	return a
+}
+
+// finish scores a job whose streams have run, builds its response, with
+// the asr and vlm outputs if the request asks for them, and hands it to the
+// webhook and the BigQuery sink.
+func (h *ExtractHandler) finish(ctx context.Context, a *Assets, results []StreamResult, elapsed time.Duration, partial bool) *ExtractResponse {
+	asrResult := output[*streams.ASRResult](ctx, a, "asr")
+	vlmResult := output[*streams.VLMResult](ctx, a, "vlm")
+	quality := streams.ScoreQuality(asrResult, vlmResult, h.cfg.QualityFlagThreshold)
+	if quality.Flagged {
+		log.Printf("WARN: extraction for %s flagged (score %.2f): %v", a.AdID, quality.Score, quality.Reasons)
+	}
+
+	resp := ExtractResponse{
+		AdID:             a.AdID,
+		Partial:          partial,
+		Streams:          results,
+		Quality:          (*client.QualityScore)(quality),
+		ProcessingTimeMs: float64(elapsed.Milliseconds()),
+		GeminiTokens:     a.Tokens.Used(),
+	}
+	if a.Request.IncludeResults {
+		for i, sr := range resp.Streams {
+			var out any
+			switch {
+			case sr.Stream == "asr" && asrResult != nil:
+				out = asrResult
+			case sr.Stream == "vlm" && vlmResult != nil:
+				out = vlmResult
+			default:
+				continue
+			}
+			if data, err := json.Marshal(out); err == nil {
+				resp.Streams[i].Result = data
+			}
+		}
+	}
+	if rating := output[*streams.ContentRatingResult](ctx, a, "content_rating"); rating != nil && rating.Quarantine {
+		log.Printf("WARN: quarantining %s: content rated %s", a.AdID, rating.Rating)
+		resp.Quarantined = true
 	}
 
-	r2Key := fmt.Sprintf("ads/%s/extraction/asr_results.json", adID)
-	if err := h.r2.UploadJSON(ctx, r2Key, asrResult); err != nil {
-		log.Printf("ASR upload failed for %s: %v", adID, err)
-		return streamResult{Stream: "asr", Status: "error", Error: err.Error()}
+	if target := cmp.Or(a.Request.WebhookURL, h.cfg.WebhookURL); target != "" {
+		go h.notifyWebhook(target, h.webhookSecret(a.Request.Tenant), &resp)
+	}
+	// A job that only found stored results adds nothing new to the dataset
+	fresh := slices.ContainsFunc(results, func(sr StreamResult) bool { return sr.Status != "cached" })
+	if h.sink != nil && fresh {
+		h.sink.Add(sinkJob(a, quality))
 	}
+	return &resp
+}
 
-	return streamResult{
-		Stream:      "asr",
-		Status:      "success",
-		ResultCount: len(asrResult.Segments),
-		R2Key:       r2Key,
+// webhookSecret is the secret webhooks for tenant are signed with. tenant
+// is the one Prepare bound the job to, never the caller's choice.
+func (h *ExtractHandler) webhookSecret(tenant string) string {
+	if secret, ok := h.cfg.WebhookTenantSecrets[tenant]; ok && tenant != "" {
+		return secret
 	}
+	return h.cfg.WebhookSecret
 }
 
-func (h *ExtractHandler) runVLM(ctx context.Context, adID string, keyframes []streams.KeyframeInput) streamResult {
-	vlmResult, err := streams.RunVLM(ctx, keyframes, h.cfg.GeminiAPIKey)
-	if err != nil {
-		log.Printf("VLM failed for %s: %v", adID, err)
-		return streamResult{Stream: "vlm", Status: "error", Error: err.Error()}
+// notifyWebhook delivers the job result with presigned URLs for every
+// produced artifact, signed with secret if set. Targets other than
+// WEBHOOK_URL came from the caller and must be public. It runs detached
+// from the request, which has already been answered.
+func (h *ExtractHandler) notifyWebhook(target, secret string, resp *ExtractResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	payload := &webhook.Payload{
+		Event:  "extraction.completed",
+		AdID:   resp.AdID,
+		Result: resp,
+	}
+	expires := time.Now().Add(h.cfg.WebhookURLTTL).UTC()
+	for _, sr := range resp.Streams {
+		if (sr.Status != "success" && sr.Status != "partial") || sr.R2Key == "" {
+			continue
+		}
+		signed, err := h.r2.PresignGet(ctx, sr.R2Key, h.cfg.WebhookURLTTL)
+		if err != nil {
+			log.Printf("WARN: presign %s for webhook: %v", sr.R2Key, err)
+			continue
+		}
+		payload.Artifacts = append(payload.Artifacts, webhook.Artifact{
+			Stream:    sr.Stream,
+			R2Key:     sr.R2Key,
+			URL:       signed,
+			ExpiresAt: expires,
+		})
 	}
 
-	r2Key := fmt.Sprintf("ads/%s/extraction/vlm_results.json", adID)
-	if err := h.r2.UploadJSON(ctx, r2Key, vlmResult); err != nil {
-		log.Printf("VLM upload failed for %s: %v", adID, err)
-		return streamResult{Stream: "vlm", Status: "error", Error: err.Error()}
+	deliver := webhook.Deliver
+	if target != h.cfg.WebhookURL {
+		deliver = webhook.DeliverPublic
+	}
+	if err := deliver(ctx, target, secret, payload); err != nil {
+		log.Printf("webhook delivery failed for %s: %v", resp.AdID, err)
 	}
+}
+
+// persistTimeout bounds writes of finished results. It is separate from the
+// job deadline so that work completed just before a timeout is not lost.
+const persistTimeout = 30 * time.Second
 
-	return streamResult{
-		Stream:      "vlm",
-		Status:      "success",
-		ResultCount: len(vlmResult.Frames),
-		R2Key:       r2Key,
+func persistContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), persistTimeout)
+}
+
+// uploadJSON writes an artifact under its own persistTimeout.
+func (h *ExtractHandler) uploadJSON(ctx context.Context, key string, v any) error {
+	ctx, cancel := persistContext(ctx)
+	defer cancel()
+	return h.r2.UploadJSON(ctx, key, v)
+}
+
+func (h *ExtractHandler) vlmOptions(maxFrames int) streams.VLMOptions {
+	return streams.VLMOptions{
+		BatchSize:    h.cfg.VLMBatchSize,
+		MaxFrames:    maxFrames,
+		Selection:    h.cfg.VLMFrameSelection,
+		FrameTimeout: h.cfg.GeminiFrameTimeout,
+		Context:      h.cfg.VLMContext,
+		SummaryEvery: h.cfg.VLMSummaryEvery,
 	}
 }
+
+// jobBaseMemory covers a job's result structs, JSON encoding and HTTP buffers.
+const jobBaseMemory = 4 << 20
+
+// jobMemory projects a job's peak memory: the keyframe images RunVLM holds at
+// once, each alive as raw bytes, as base64 and inside the JSON request body,
+// plus jobBaseMemory. The video is streamed to Deepgram and never buffered,
+// so its size does not count.
+func (h *ExtractHandler) jobMemory() int64 {
+	frame := int64(h.cfg.AdmissionFrameKB) << 10
+	frames := int64(streams.FramesInFlight(h.vlmOptions(0)))
+	return jobBaseMemory + frames*frame*3
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+)
+
+// postExtract serves POST /extract with body and returns the status and,
+// for a 200, the streams' statuses.
+func postExtract(t *testing.T, h *ExtractHandler, body string) (int, map[string]string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/extract", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	var resp ExtractResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, sr := range resp.Streams {
+		status[sr.Stream] = sr.Status
+	}
+	return rec.Code, status
+}
+
+// TestExtractSelectedStreams runs only the streams a request names, again
+// even when they are stored, and leaves the others' results alone.
+func TestExtractSelectedStreams(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	h := newTestHandler(t, store, nil)
+
+	code, status := postExtract(t, h, `{"ad_id":"ad1","streams":["asr"]}`)
+	if code != http.StatusOK || len(status) != 1 || status["asr"] != "success" {
+		t.Fatalf("asr only: %d %v", code, status)
+	}
+	if _, ok := store.Get(testBucket, "ads/ad1/extraction/vlm_results.json"); ok {
+		t.Error("vlm ran")
+	}
+
+	asr := []byte(`{"transcript":"kept"}`)
+	store.Put(testBucket, "ads/ad1/extraction/asr_results.json", asr, "application/json")
+	code, status = postExtract(t, h, `{"ad_id":"ad1","streams":["vlm"]}`)
+	if code != http.StatusOK || len(status) != 1 || status["vlm"] != "success" {
+		t.Errorf("vlm only: %d %v", code, status)
+	}
+	if data, _ := store.Get(testBucket, "ads/ad1/extraction/asr_results.json"); !bytes.Equal(data, asr) {
+		t.Errorf("asr_results.json rewritten: %s", data)
+	}
+	// Named streams run even though their results are stored
+	if code, status = postExtract(t, h, `{"ad_id":"ad1","streams":["vlm"]}`); status["vlm"] != "success" {
+		t.Errorf("vlm again: %d %v", code, status)
+	}
+
+	if code, _ := postExtract(t, h, `{"ad_id":"ad1","streams":["nope"]}`); code != http.StatusBadRequest {
+		t.Errorf("unknown stream: %d, want 400", code)
+	}
+}
+
+// TestExtractFillMissingHTTP completes a job that only got as far as the
+// transcript, and refuses fill-missing with force.
+func TestExtractFillMissingHTTP(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	h := newTestHandler(t, store, nil)
+
+	if code, _ := postExtract(t, h, `{"ad_id":"ad1","streams":["asr"]}`); code != http.StatusOK {
+		t.Fatalf("asr only: %d", code)
+	}
+	// Stored results are kept even for a chosen model
+	code, status := postExtract(t, h, `{"ad_id":"ad1","mode":"fill-missing","asr_model":"nova-2"}`)
+	if code != http.StatusOK || status["asr"] != "cached" || status["vlm"] != "success" {
+		t.Errorf("fill-missing: %d %v", code, status)
+	}
+	code, status = postExtract(t, h, `{"ad_id":"ad1","mode":"fill-missing"}`)
+	for name, s := range status {
+		if s != "cached" && s != "skipped" {
+			t.Errorf("fill-missing again: %s %s", name, s)
+		}
+	}
+
+	for _, body := range []string{`{"ad_id":"ad1","mode":"fill-missing","force":true}`, `{"ad_id":"ad1","mode":"refill"}`} {
+		if code, _ := postExtract(t, h, body); code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", body, code)
+		}
+	}
+}
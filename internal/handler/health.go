@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// storageCheckTTL is how long a storage check result is reused, so frequent
+// probes from several kubelets do not each cost an R2 request.
+const storageCheckTTL = 10 * time.Second
+
+// Health is the body of a GET /health response. Providers is only set
+// with ?detail=providers.
+type Health struct {
+	Status    string                   `json:"status"`
+	Streams   map[string]bool          `json:"streams"`
+	Providers []streams.ProviderStatus `json:"providers,omitempty"`
+}
+
+// Probe is the body of /livez and /readyz responses; Checks, for /readyz,
+// is "ok" or the failure of each check by name.
+type Probe struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Livez answers 200 for as long as the process can serve HTTP. It checks no
+// dependency: a failing liveness probe restarts the pod, which cures nothing
+// when R2 or a provider is down.
+func Livez(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Probe{Status: "ok"})
+}
+
+// ReadinessHandler serves /readyz: 200 while the instance should receive
+// jobs, 503 with the failing checks otherwise. Failing readiness only takes
+// the pod out of rotation, so it covers what a restart would not fix: bad
+// configuration, unreachable storage and a queue that stopped moving.
+type ReadinessHandler struct {
+	cfg     *config.Config
+	pool    *pool.Pool
+	storage func(ctx context.Context) error
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	storeErr  error
+}
+
+// NewReadinessHandler checks cfg, ping (e.g. r2.Client.Ping) and the job
+// pool.
+func NewReadinessHandler(cfg *config.Config, p *pool.Pool, ping func(ctx context.Context) error) *ReadinessHandler {
+	return &ReadinessHandler{cfg: cfg, pool: p, storage: ping}
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	checks := map[string]string{
+		"config":  result(h.cfg.Validate()),
+		"storage": result(h.checkStorage(req.Context())),
+		"queue":   result(h.checkQueue()),
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, v := range checks {
+		if v != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(Probe{Status: status, Checks: checks})
+}
+
+func (h *ReadinessHandler) checkStorage(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.checkedAt) < storageCheckTTL {
+		return h.storeErr
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	h.storeErr = h.storage(ctx)
+	h.checkedAt = time.Now()
+	return h.storeErr
+}
+
+func (h *ReadinessHandler) checkQueue() error {
+	stall := h.cfg.ReadyQueueStall
+	if stall <= 0 {
+		return nil
+	}
+	if s := h.pool.Stats(); s.OldestWait > stall {
+		return fmt.Errorf("%d jobs queued, oldest waiting %s", s.Queued, s.OldestWait.Round(time.Second))
+	}
+	return nil
+}
+
+func result(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
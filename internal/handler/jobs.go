@@ -0,0 +1,507 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Async jobs are stored in R2 so that any instance can report them and
+// jobs cut off by a restart are picked up again: at jobs/active/{id}.json
+// while they run, refreshed every jobHeartbeat, and at jobs/{id}.json once
+// they finish. Cancelling a job that runs on another instance leaves a
+// marker at jobs/cancel/{id}, which that instance checks every
+// jobCancelPoll.
+const (
+	jobHeartbeat  = time.Minute
+	jobCancelPoll = 10 * time.Second
+	// jobStaleAfter is how long an active job may go without a heartbeat
+	// before ResumeJobs takes it over
+	jobStaleAfter = 3 * jobHeartbeat
+	// jobRetryWindow is how long a job turned away for lack of capacity
+	// keeps being retried
+	jobRetryWindow = 30 * time.Minute
+	// jobRetention is how long finished jobs stay in memory; after that
+	// they are read back from R2
+	jobRetention = time.Hour
+)
+
+type Job = client.Job
+
+// jobRecord is an async job as stored: its state and the request that
+// started it.
+type jobRecord struct {
+	Job
+	Request ExtractRequest `json:"request"`
+
+	queuedAt time.Time               // when this instance took the job on
+	cancel   context.CancelCauseFunc // stops the job's run here; set by track
+	mu       sync.Mutex              // guards Job
+	saveMu   sync.Mutex              // orders saves
+}
+
+// errJobCancelled is the cause of a cancelled job's context.
+var errJobCancelled = errors.New("job cancelled")
+
+// jobs holds the async jobs running here and those that finished recently.
+type jobs struct {
+	mu   sync.Mutex
+	byID map[string]*jobRecord
+}
+
+func (j *jobs) get(id string) *jobRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.byID[id]
+}
+
+// add tracks rec unless a job with its ID already is, and reports whether
+// it did.
+func (j *jobs) add(rec *jobRecord) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.byID == nil {
+		j.byID = map[string]*jobRecord{}
+	}
+	if _, ok := j.byID[rec.ID]; ok {
+		return false
+	}
+	j.byID[rec.ID] = rec
+	return true
+}
+
+func (j *jobs) remove(id string) {
+	j.mu.Lock()
+	delete(j.byID, id)
+	j.mu.Unlock()
+}
+
+func activeJobKey(id string) string { return "jobs/active/" + id + ".json" }
+func jobKey(id string) string       { return "jobs/" + id + ".json" }
+func cancelJobKey(id string) string { return "jobs/cancel/" + id }
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validJobID reports whether id could have come from newJobID, so that
+// ids from URLs are safe to use in keys.
+func validJobID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// Submit starts body as an async job and returns it as queued. The job
+// runs in the background as POST /extract would run it, detached from ctx.
+// body must have been validated.
+func (h *ExtractHandler) Submit(ctx context.Context, body ExtractRequest) (*Job, error) {
+	now := time.Now().UTC()
+	rec := &jobRecord{
+		Job:      Job{ID: newJobID(), AdID: body.AdID, Status: "queued", CreatedAt: now, UpdatedAt: now},
+		Request:  body,
+		queuedAt: now,
+	}
+	if err := h.resetStreams(rec); err != nil {
+		return nil, err
+	}
+	runCtx, _ := h.track(rec)
+	// A job that could not be saved still runs, but does not survive a
+	// restart and is only visible here
+	h.saveJob(ctx, rec)
+	go h.runJob(runCtx, rec)
+	return rec.snapshot(), nil
+}
+
+// track makes rec visible to GET and DELETE /jobs/{id}, returning the
+// context its run is to get. rec.cancel is set first, so that a job can be
+// cancelled from the moment it is visible, before its run starts. It
+// reports false, tracking nothing, if a job with rec's ID already is.
+func (h *ExtractHandler) track(rec *jobRecord) (context.Context, bool) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	rec.cancel = cancel
+	if !h.jobs.add(rec) {
+		cancel(nil)
+		return nil, false
+	}
+	return ctx, true
+}
+
+// resetStreams lists the streams the job will run as pending.
+func (h *ExtractHandler) resetStreams(rec *jobRecord) error {
+	plan, err := h.Plan(rec.Request)
+	if err != nil {
+		return err
+	}
+	rec.Streams = rec.Streams[:0]
+	for _, step := range plan.Steps {
+		rec.Streams = append(rec.Streams, StreamResult{Stream: step.Stream, Status: "pending"})
+	}
+	rec.Progress = 0
+	return nil
+}
+
+// runJob runs an async job to completion, retrying it while there is no
+// capacity for it, and stores its outcome. A cancelled job stops where it
+// is; what its streams had finished is kept.
+func (h *ExtractHandler) runJob(ctx context.Context, rec *jobRecord) {
+	defer rec.cancel(nil)
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobCancelPoll)
+		defer ticker.Stop()
+		saved := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if ok, _ := h.r2.Exists(ctx, cancelJobKey(rec.ID)); ok {
+				rec.cancel(errJobCancelled)
+			}
+			if time.Since(saved) >= jobHeartbeat {
+				h.saveJob(ctx, rec)
+				saved = time.Now()
+			}
+		}
+	}()
+
+	var resp *ExtractResponse
+	var err error
+	// A job cancelled before its run started never runs
+	for ctx.Err() == nil {
+		rec.update(func(j *Job) { j.Status = "running" })
+		resp, err = h.execute(ctx, rec.Request, rec)
+		var jobErr *JobError
+		if !errors.As(err, &jobErr) || jobErr.Status != http.StatusServiceUnavailable || time.Since(rec.queuedAt) > jobRetryWindow {
+			break
+		}
+		wait := jobErr.RetryAfter
+		if wait == 0 {
+			wait = 30 * time.Second
+		}
+		log.Printf("job %s (%s): retrying in %s: %v", rec.ID, rec.AdID, wait, err)
+		rec.update(func(j *Job) { j.Status, j.Stage = "queued", "" })
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+	close(stop)
+
+	cancelled := errors.Is(context.Cause(ctx), errJobCancelled)
+	if cancelled {
+		log.Printf("job %s (%s): cancelled", rec.ID, rec.AdID)
+	}
+	rec.update(func(j *Job) {
+		if cancelled {
+			cancelJob(j, resp)
+			return
+		}
+		if err != nil {
+			j.Status, j.Error = "failed", err.Error()
+			return
+		}
+		j.Status, j.Stage, j.Result = "succeeded", "", resp
+		j.Streams, j.Progress = resp.Streams, 1
+	})
+	if err := h.storeFinishedJob(context.WithoutCancel(ctx), rec); err != nil {
+		log.Printf("WARN: job %s (%s): %v", rec.ID, rec.AdID, err)
+	}
+	time.AfterFunc(jobRetention, func() { h.jobs.remove(rec.ID) })
+}
+
+// storeFinishedJob moves a finished job from jobs/active/ to jobs/, listed
+// for purges of its ad first.
+func (h *ExtractHandler) storeFinishedJob(ctx context.Context, rec *jobRecord) error {
+	rec.saveMu.Lock()
+	defer rec.saveMu.Unlock()
+	ctx, cancel := persistContext(ctx)
+	defer cancel()
+	if err := recordObjects(ctx, h.r2, rec.AdID, jobKey(rec.ID)); err != nil {
+		return err
+	}
+	if err := h.r2.UploadJSON(ctx, jobKey(rec.ID), rec.stored()); err != nil {
+		return err
+	}
+	if err := h.r2.Delete(ctx, activeJobKey(rec.ID)); err != nil {
+		return err
+	}
+	return h.r2.Delete(ctx, cancelJobKey(rec.ID))
+}
+
+// cancelJob marks j cancelled, keeping resp, the partial response if the
+// run got that far; streams that had not finished are marked cancelled.
+func cancelJob(j *Job, resp *ExtractResponse) {
+	j.Status, j.Error = "cancelled", errJobCancelled.Error()
+	if resp != nil {
+		j.Result, j.Streams = resp, resp.Streams
+	}
+	for i := range j.Streams {
+		if s := j.Streams[i].Status; s == "pending" || s == "running" {
+			j.Streams[i].Status = "cancelled"
+		}
+	}
+}
+
+// saveJob refreshes a running job's record in R2, logging failures.
+func (h *ExtractHandler) saveJob(ctx context.Context, rec *jobRecord) {
+	rec.saveMu.Lock()
+	defer rec.saveMu.Unlock()
+	rec.update(func(*Job) {})
+	if err := h.uploadJSON(ctx, activeJobKey(rec.ID), rec.stored()); err != nil {
+		log.Printf("WARN: job %s (%s): save: %v", rec.ID, rec.AdID, err)
+	}
+}
+
+// update changes the job under its lock and marks it updated.
+func (rec *jobRecord) update(fn func(j *Job)) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	fn(&rec.Job)
+	rec.UpdatedAt = time.Now().UTC()
+}
+
+// snapshot copies the job's current state.
+func (rec *jobRecord) snapshot() *Job {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	j := rec.Job
+	j.Streams = append([]StreamResult(nil), rec.Streams...)
+	return &j
+}
+
+// stored is the record as written to R2.
+func (rec *jobRecord) stored() any {
+	return struct {
+		*Job
+		Request ExtractRequest `json:"request"`
+	}{rec.snapshot(), rec.Request}
+}
+
+func (rec *jobRecord) setStage(stage string) {
+	rec.update(func(j *Job) { j.Stage = stage })
+}
+
+func (rec *jobRecord) streamStarted(name string) {
+	rec.update(func(j *Job) {
+		for i := range j.Streams {
+			if j.Streams[i].Stream == name {
+				j.Streams[i].Status = "running"
+			}
+		}
+	})
+}
+
+func (rec *jobRecord) streamFinished(sr StreamResult) {
+	rec.update(func(j *Job) {
+		done := 0
+		for i := range j.Streams {
+			if j.Streams[i].Stream == sr.Stream {
+				j.Streams[i] = sr
+			}
+			if s := j.Streams[i].Status; s != "pending" && s != "running" {
+				done++
+			}
+		}
+		if len(j.Streams) > 0 {
+			j.Progress = float64(done) / float64(len(j.Streams))
+		}
+	})
+}
+
+// ServeJob serves GET /jobs/{id}: an async job's state, from memory while
+// it runs here and from R2 otherwise. Keys bound to a tenant only see that
+// tenant's jobs.
+func (h *ExtractHandler) ServeJob(w http.ResponseWriter, req *http.Request) {
+	job, err := h.Job(req.Context(), req.PathValue("id"))
+	if errors.Is(err, ErrNoSuchJob) {
+		apierr.Write(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJob(w, http.StatusOK, job)
+}
+
+// ServeCancelJob serves DELETE /jobs/{id}: it stops a queued or running async
+// job, whose in-flight provider calls are abandoned, and marks it
+// cancelled. A job running here, or on another instance, is answered 202
+// while it winds down; the other instance notices within jobCancelPoll. A
+// job no instance is running any more is cancelled on the spot, answered
+// 200. Finished jobs are answered 409.
+func (h *ExtractHandler) ServeCancelJob(w http.ResponseWriter, req *http.Request) {
+	rec, local, ok := h.findJob(w, req)
+	if !ok {
+		return
+	}
+	job := &rec.Job
+	if local {
+		job = rec.snapshot()
+	}
+	if job.Done() {
+		apierr.WriteDetails(w, "job already "+job.Status, http.StatusConflict, map[string]any{"status": job.Status})
+		return
+	}
+	if local {
+		rec.cancel(errJobCancelled)
+		writeJob(w, http.StatusAccepted, job)
+		return
+	}
+
+	ctx := req.Context()
+	if time.Since(rec.UpdatedAt) >= jobStaleAfter {
+		// Left by an instance that stopped; nothing would see a marker
+		rec.update(func(j *Job) { cancelJob(j, nil) })
+		if err := h.storeFinishedJob(ctx, rec); err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJob(w, http.StatusOK, &rec.Job)
+		return
+	}
+	if err := h.r2.UploadObject(ctx, cancelJobKey(rec.ID), nil, "text/plain"); err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJob(w, http.StatusAccepted, job)
+}
+
+// findJob looks up the job in the path, in memory while it runs here
+// (local) and in R2 otherwise, and answers the request itself if there is
+// no such job for the caller: keys bound to a tenant only see that
+// tenant's jobs.
+func (h *ExtractHandler) findJob(w http.ResponseWriter, req *http.Request) (rec *jobRecord, local, ok bool) {
+	rec, local, err := h.lookupJob(req.Context(), req.PathValue("id"))
+	if errors.Is(err, ErrNoSuchJob) {
+		apierr.Write(w, err.Error(), http.StatusNotFound)
+		return nil, false, false
+	}
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return nil, false, false
+	}
+	return rec, local, true
+}
+
+// ErrNoSuchJob is returned for job IDs that name no job the caller may see.
+var ErrNoSuchJob = errors.New("no such job")
+
+// lookupJob is findJob for callers other than HTTP handlers; the tenant is
+// the one FromContext reports on ctx.
+func (h *ExtractHandler) lookupJob(ctx context.Context, id string) (rec *jobRecord, local bool, err error) {
+	if !validJobID(id) {
+		return nil, false, ErrNoSuchJob
+	}
+	if rec = h.jobs.get(id); rec != nil {
+		local = true
+	} else {
+		rec = &jobRecord{}
+		err := h.r2.DownloadJSON(ctx, jobKey(id), rec)
+		if errors.Is(err, r2.ErrNotFound) {
+			err = h.r2.DownloadJSON(ctx, activeJobKey(id), rec)
+		}
+		if errors.Is(err, r2.ErrNotFound) {
+			return nil, false, ErrNoSuchJob
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if p := auth.FromContext(ctx); p != nil && p.Tenant != "" && p.Tenant != rec.Request.Tenant {
+		return nil, false, ErrNoSuchJob
+	}
+	return rec, local, nil
+}
+
+// Job returns an async job's state as GET /jobs/{id} reports it, or
+// ErrNoSuchJob.
+func (h *ExtractHandler) Job(ctx context.Context, id string) (*Job, error) {
+	rec, local, err := h.lookupJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if local {
+		return rec.snapshot(), nil
+	}
+	return &rec.Job, nil
+}
+
+func writeJob(w http.ResponseWriter, status int, job *Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ResumeJobs takes over, every jobStaleAfter until ctx ends, async jobs
+// whose instance stopped refreshing them, e.g. because it restarted, and
+// runs them again from the start. Two instances may occasionally both take
+// over a job; it then runs twice, and the later run's results are kept.
+func (h *ExtractHandler) ResumeJobs(ctx context.Context) {
+	ticker := time.NewTicker(jobStaleAfter)
+	defer ticker.Stop()
+	for {
+		if err := h.resumeJobs(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("WARN: resume jobs: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *ExtractHandler) resumeJobs(ctx context.Context) error {
+	keys, err := h.r2.ListKeys(ctx, "jobs/active/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		id := strings.TrimSuffix(strings.TrimPrefix(key, "jobs/active/"), ".json")
+		if !validJobID(id) || h.jobs.get(id) != nil {
+			continue
+		}
+		rec := &jobRecord{}
+		if err := h.r2.DownloadJSON(ctx, key, rec); err != nil {
+			log.Printf("WARN: resume job %s: %v", id, err)
+			continue
+		}
+		if time.Since(rec.UpdatedAt) < jobStaleAfter {
+			continue
+		}
+		if err := h.resetStreams(rec); err != nil {
+			log.Printf("WARN: resume job %s: %v", id, err)
+			continue
+		}
+		rec.Status, rec.Stage, rec.queuedAt = "queued", "", time.Now()
+		runCtx, ok := h.track(rec)
+		if !ok {
+			continue
+		}
+		log.Printf("job %s (%s): resuming", rec.ID, rec.AdID)
+		h.saveJob(ctx, rec)
+		go h.runJob(runCtx, rec)
+	}
+	return nil
+}
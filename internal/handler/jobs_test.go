@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/app"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+)
+
+const testBucket = "test-bucket"
+
+// newTestHandler returns a handler calling the canned providers and storing
+// in store, reached through wrap if it is not nil.
+func newTestHandler(t *testing.T, store *s3fake.Server, wrap func(http.Handler) http.Handler) *ExtractHandler {
+	t.Helper()
+	var storage http.Handler = store
+	if wrap != nil {
+		storage = wrap(store)
+	}
+	server := httptest.NewServer(storage)
+	t.Cleanup(server.Close)
+
+	t.Setenv("R2_ENDPOINT_URL", server.URL)
+	t.Setenv("R2_BUCKET", testBucket)
+	t.Setenv("R2_ACCESS_KEY_ID", "key")
+	t.Setenv("R2_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("MOCK_PROVIDERS", "true")
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ConfigureStreams(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return NewExtractHandler(cfg, app.NewR2Client(cfg), pool.New(1), admission.New(0))
+}
+
+// seedTestAd uploads a video and frames keyframes for adID.
+func seedTestAd(t *testing.T, store *s3fake.Server, adID string, frames int) {
+	t.Helper()
+	store.Put(testBucket, "ads/"+adID+"/video.mp4", []byte("not really a video"), "video/mp4")
+	var meta r2.KeyframeMetadataFile
+	for i := range frames {
+		img := image.NewGray(image.Rect(0, 0, 16, 16))
+		for p := range img.Pix {
+			img.Pix[p] = uint8(i*40 + p)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		key := fmt.Sprintf("ads/%s/keyframes/frame_%03d.jpg", adID, i)
+		store.Put(testBucket, key, buf.Bytes(), "image/jpeg")
+		meta.Keyframes = append(meta.Keyframes, r2.KeyframeMeta{Index: i, FrameNumber: i * 30, TimestampSec: float64(i), EntropyScore: 5, R2Key: key})
+	}
+	data, _ := json.Marshal(meta)
+	store.Put(testBucket, "ads/"+adID+"/keyframes/metadata.json", data, "application/json")
+}
+
+// serve answers req with fn and decodes the response into v, if not nil.
+func serve(t *testing.T, fn http.HandlerFunc, req *http.Request, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	fn(rec, req)
+	if v != nil && rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v: %s", req.Method, req.URL, err, rec.Body)
+		}
+	}
+	return rec.Code
+}
+
+// routed serves pattern with fn, so that path values are set.
+func routed(pattern string, fn http.HandlerFunc) http.HandlerFunc {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, fn)
+	return mux.ServeHTTP
+}
+
+// waitJob polls GET /jobs/{id} until the job is done.
+func waitJob(t *testing.T, h *ExtractHandler, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var job Job
+		if code := serve(t, routed("GET /jobs/{id}", h.ServeJob), httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil), &job); code != http.StatusOK {
+			t.Fatalf("GET /jobs/%s: %d", id, code)
+		}
+		if job.Done() {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestCancelJobBeforeStart cancels a job while Submit is still saving it,
+// before its run has started.
+func TestCancelJobBeforeStart(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	saving := make(chan string, 1)
+	release := make(chan struct{})
+	h := newTestHandler(t, store, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodPut && strings.Contains(req.URL.Path, "/jobs/active/") {
+				select {
+				case saving <- strings.TrimSuffix(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:], ".json"):
+					<-release
+				default:
+				}
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	// Released once the job is cancelled, or if the test fails first
+	unblock := sync.OnceFunc(func() { close(release) })
+	t.Cleanup(unblock)
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := h.Submit(context.Background(), ExtractRequest{AdID: "ad1"})
+		submitted <- err
+	}()
+	id := <-saving
+	code := serve(t, routed("DELETE /jobs/{id}", h.ServeCancelJob), httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil), nil)
+	unblock()
+	if code != http.StatusAccepted {
+		t.Errorf("DELETE: %d, want 202", code)
+	}
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+
+	job := waitJob(t, h, id)
+	if job.Status != "cancelled" || job.Result != nil {
+		t.Fatalf("job = %+v", job)
+	}
+	for _, sr := range job.Streams {
+		if sr.Status != "cancelled" {
+			t.Errorf("%s %s, want cancelled", sr.Stream, sr.Status)
+		}
+	}
+	if keys := store.Keys(testBucket, "ads/ad1/extraction/"); len(keys) > 0 {
+		t.Errorf("cancelled job wrote %v", keys)
+	}
+}
+
+// TestAsyncJobHTTP submits a job through POST /extract?async=true as a
+// tenant's key, polls it, and checks what other callers can do with it.
+func TestAsyncJobHTTP(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	h := newTestHandler(t, store, nil)
+	as := func(req *http.Request, tenant string) *http.Request {
+		return req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Method: "api_key", Tenant: tenant}))
+	}
+	getJob, cancelJob := routed("GET /jobs/{id}", h.ServeJob), routed("DELETE /jobs/{id}", h.ServeCancelJob)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, as(httptest.NewRequest(http.MethodPost, "/extract?async=true", strings.NewReader(`{"ad_id":"ad1"}`)), "acme"))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	var job Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if loc := rec.Header().Get("Location"); loc != APIVersion+"/jobs/"+job.ID {
+		t.Errorf("Location = %q", loc)
+	}
+
+	// Another tenant's job does not exist for the caller
+	for name, fn := range map[string]http.HandlerFunc{"GET": getJob, "DELETE": cancelJob} {
+		if code := serve(t, fn, as(httptest.NewRequest(name, "/jobs/"+job.ID, nil), "other"), nil); code != http.StatusNotFound {
+			t.Errorf("%s as another tenant: %d, want 404", name, code)
+		}
+	}
+
+	done := waitJob(t, h, job.ID)
+	if done.Status != "succeeded" || done.Result == nil || done.Progress != 1 {
+		t.Fatalf("job = %+v", done)
+	}
+	if code := serve(t, getJob, as(httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil), "acme"), &job); code != http.StatusOK || job.Status != "succeeded" {
+		t.Errorf("GET as its tenant: %d %s", code, job.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	cancelJob(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+job.ID, nil))
+	var body struct {
+		Details map[string]any `json:"details"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusConflict || body.Details["status"] != "succeeded" {
+		t.Errorf("cancel finished job: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, id := range []string{"0123456789abcdef0123456789abcdef", "not-a-job", "0123456789ABCDEF0123456789ABCDEF"} {
+		if code := serve(t, getJob, httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil), nil); code != http.StatusNotFound {
+			t.Errorf("GET /jobs/%s: %d, want 404", id, code)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/keyframes"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+)
+
+// extractKeyframes stands in for entropy-frames-selector on an ad it has not
+// processed yet. ffmpeg reads the video straight from R2; the frames and
+// their metadata.json are uploaded in the selector's layout, so later runs
+// and the UI find them as usual. metadata.json goes last, as its presence
+// means the keyframes are complete.
+func (h *ExtractHandler) extractKeyframes(ctx context.Context, adID string) ([]r2.KeyframeMeta, error) {
+	t0 := time.Now()
+	url, err := h.videoURL(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "keyframes-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	frames, err := keyframes.Extract(ctx, url, dir, keyframes.Options{
+		Mode:           h.cfg.KeyframeFallback,
+		SceneThreshold: h.cfg.KeyframeSceneThreshold,
+		Interval:       h.cfg.KeyframeInterval,
+		MaxFrames:      h.cfg.KeyframeMaxFrames,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("extract keyframes: %w", err)
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("extract keyframes: ffmpeg selected no frames")
+	}
+
+	metas := make([]r2.KeyframeMeta, len(frames))
+	for i, f := range frames {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("ads/%s/keyframes/kf_%03d.jpg", adID, i)
+		if err := h.r2.UploadObject(ctx, key, data, "image/jpeg"); err != nil {
+			return nil, fmt.Errorf("upload keyframe: %w", err)
+		}
+		metas[i] = r2.KeyframeMeta{
+			Index:        i,
+			FrameNumber:  f.FrameNumber,
+			TimestampSec: f.TimestampSec,
+			EntropyScore: f.EntropyScore,
+			R2Key:        key,
+		}
+	}
+	key := fmt.Sprintf("ads/%s/keyframes/metadata.json", adID)
+	if err := h.r2.UploadJSON(ctx, key, r2.KeyframeMetadataFile{Keyframes: metas}); err != nil {
+		return nil, fmt.Errorf("upload keyframe metadata: %w", err)
+	}
+	written := []string{key}
+	for _, m := range metas {
+		written = append(written, m.R2Key)
+	}
+	if err := recordObjects(ctx, h.r2, adID, written...); err != nil {
+		log.Printf("WARN: %s: record keyframes for purges: %v", adID, err)
+	}
+	log.Printf("%s: extracted %d keyframes with ffmpeg (%s) in %s",
+		adID, len(metas), h.cfg.KeyframeFallback, time.Since(t0).Round(time.Millisecond))
+	return metas, nil
+}
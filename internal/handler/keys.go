@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+)
+
+// KeysHandler manages API keys: GET /admin/keys lists them, POST
+// /admin/keys creates one and returns its secret, the only time it is
+// shown, and DELETE /admin/keys/{id} revokes one.
+type KeysHandler struct {
+	keys *auth.Keyring
+}
+
+func NewKeysHandler(keys *auth.Keyring) *KeysHandler {
+	return &KeysHandler{keys: keys}
+}
+
+type createKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Tenant string   `json:"tenant,omitempty"`
+	RPM    int      `json:"rpm,omitempty"`
+}
+
+type createKeyResponse struct {
+	auth.Key
+	Secret string `json:"secret"`
+}
+
+func (h *KeysHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	switch {
+	case req.Method == http.MethodGet:
+		keys, err := h.keys.List(ctx)
+		if err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for i := range keys {
+			keys[i].Hash = ""
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+
+	case req.Method == http.MethodPost:
+		var body createKeyRequest
+		if !decodeJSON(w, req, &body) {
+			return
+		}
+		key, secret, err := h.keys.Create(ctx, auth.Key{Name: body.Name, Scopes: body.Scopes, Tenant: body.Tenant, RPM: body.RPM})
+		if errors.Is(err, auth.ErrInvalidKey) {
+			apierr.Write(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		key.Hash = ""
+		writeJSON(w, http.StatusCreated, createKeyResponse{Key: key, Secret: secret})
+
+	case req.Method == http.MethodDelete && req.PathValue("id") != "":
+		err := h.keys.Revoke(ctx, req.PathValue("id"))
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			apierr.Write(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		apierr.Write(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+)
+
+// BodyLimits configures LimitBody, in bytes.
+type BodyLimits struct {
+	JSON   int64
+	Upload int64
+	// Uploads are the patterns, as given to HandleVersioned, of the routes
+	// that take a video directly
+	Uploads []string
+}
+
+// LimitBody caps request bodies routed by mux: those of the upload routes at
+// limits.Upload, any other at limits.JSON. The route decides, never the
+// Content-Type, which the client picks. Bodies that declare a larger
+// Content-Length are answered 413 at once; others fail when a read passes
+// the limit, which decodeJSON reports as 413.
+func LimitBody(mux *http.ServeMux, limits BodyLimits, next http.Handler) http.Handler {
+	uploads := make(map[string]bool, 2*len(limits.Uploads))
+	for _, pattern := range limits.Uploads {
+		uploads[pattern] = true
+		uploads[versioned(pattern)] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		limit := limits.JSON
+		if _, pattern := mux.Handler(req); uploads[pattern] {
+			limit = limits.Upload
+		}
+		if req.ContentLength > limit {
+			tooLarge(w, limit)
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, limit)
+		next.ServeHTTP(w, req)
+	})
+}
+
+func tooLarge(w http.ResponseWriter, limit int64) {
+	apierr.WriteDetails(w, fmt.Sprintf("request body too large: the limit is %s", byteSize(limit)), http.StatusRequestEntityTooLarge, map[string]any{"limit_bytes": limit})
+}
+
+// decodeJSON decodes a request body into v. It answers the request and
+// returns false when the body is too large (413) or not valid JSON (400).
+func decodeJSON(w http.ResponseWriter, req *http.Request, v any) bool {
+	err := json.NewDecoder(req.Body).Decode(v)
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooBig):
+		tooLarge(w, tooBig.Limit)
+		return false
+	case err != nil:
+		apierr.Write(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%d GiB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
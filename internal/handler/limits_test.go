@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBodyByRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	read := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, err := io.ReadAll(req.Body); err != nil {
+			tooLarge(w, 0)
+		}
+	})
+	HandleVersioned(mux, "POST /extract", read)
+	HandleVersioned(mux, "PUT /upload", read)
+	h := LimitBody(mux, BodyLimits{JSON: 8, Upload: 64, Uploads: []string{"PUT /upload"}}, mux)
+
+	body := strings.Repeat("x", 32)
+	for _, tc := range []struct {
+		method, path, contentType string
+		chunked                   bool
+		want                      int
+	}{
+		{"POST", "/v1/extract", "application/json", false, http.StatusRequestEntityTooLarge},
+		// The client's Content-Type does not raise the limit
+		{"POST", "/v1/extract", "application/octet-stream", false, http.StatusRequestEntityTooLarge},
+		{"POST", "/v1/extract", "video/mp4", true, http.StatusRequestEntityTooLarge},
+		{"PUT", "/v1/upload", "video/mp4", false, http.StatusOK},
+		{"PUT", "/upload", "application/json", true, http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(body))
+		req.Header.Set("Content-Type", tc.contentType)
+		if tc.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s as %s: status %d, want %d", tc.method, tc.path, tc.contentType, rec.Code, tc.want)
+		}
+	}
+}
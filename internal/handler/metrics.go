@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/admission"
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/pool"
+	"github.com/nikipaj1/video-description-pipeline/internal/statsd"
+)
+
+// MetricsHandler serves worker pool load and committed memory in the
+// Prometheus text format, for an HPA via prometheus-adapter or KEDA's
+// Prometheus scaler.
+type MetricsHandler struct {
+	pool   *pool.Pool
+	memory *admission.Budget
+}
+
+func NewMetricsHandler(p *pool.Pool, memory *admission.Budget) *MetricsHandler {
+	return &MetricsHandler{pool: p, memory: memory}
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range poolMetrics(h.pool, h.memory) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, m.help, m.name, m.kind, m.name, strconv.FormatFloat(m.value, 'f', -1, 64))
+	}
+}
+
+type metric struct {
+	name, kind, help string
+	value            float64
+}
+
+// poolMetrics are the values served by /metrics and pushed to StatsD.
+func poolMetrics(p *pool.Pool, memory *admission.Budget) []metric {
+	s := p.Stats()
+	return []metric{
+		{"pipeline_queue_depth", "gauge", "Extraction jobs waiting for a worker.", float64(s.Queued)},
+		{"pipeline_jobs_running", "gauge", "Extraction jobs in progress.", float64(s.Running)},
+		{"pipeline_workers", "gauge", "Extraction jobs allowed to run at once.", float64(s.Workers)},
+		{"pipeline_jobs_completed_total", "counter", "Extraction jobs finished since start.", float64(s.Completed)},
+		{"pipeline_jobs_per_minute", "gauge", "Extraction jobs finished in the last minute.", s.RatePerMinute},
+		{"pipeline_memory_committed_bytes", "gauge", "Projected memory reserved by running jobs.", float64(memory.Used())},
+		{"pipeline_memory_budget_bytes", "gauge", "Memory budget for jobs (0 = unlimited).", float64(memory.Limit())},
+	}
+}
+
+// pushMetrics sends the gauges of poolMetrics to StatsD every interval, for
+// hosts whose agents cannot scrape /metrics. Job counts are sent as jobs
+// finish instead, by recordJob.
+func pushMetrics(c *statsd.Client, p *pool.Pool, memory *admission.Budget, every time.Duration) {
+	for range time.Tick(every) {
+		for _, m := range poolMetrics(p, memory) {
+			if m.kind == "gauge" {
+				c.Gauge(strings.TrimPrefix(m.name, "pipeline_"), m.value)
+			}
+		}
+	}
+}
+
+// streamProviders tags stream metrics with the service the stream depends
+// on.
+var streamProviders = map[string]string{
+	"asr":            "deepgram",
+	"vlm":            "gemini",
+	"key_moments":    "gemini",
+	"summary":        "gemini",
+	"video_meta":     "ffprobe",
+	"audio_analysis": "ffmpeg",
+	"people":         "gemini",
+	"presenter":      "gemini",
+	"products":       "gemini",
+	"cta":            "gemini",
+	"music":          "gemini",
+	"hook_analysis":  "gemini",
+	"entities":       "gemini",
+}
+
+// recordJob counts a finished job by outcome and each of its streams by
+// status.
+func (h *ExtractHandler) recordJob(resp *ExtractResponse, err error) {
+	if h.stats == nil {
+		return
+	}
+	var jobErr *JobError
+	switch {
+	case errors.As(err, &jobErr) && jobErr.Status == http.StatusServiceUnavailable:
+		h.stats.Count("jobs", 1, "status:rejected")
+		return
+	case err != nil:
+		h.stats.Count("jobs", 1, "status:error")
+		return
+	}
+
+	status := "success"
+	if resp.Partial {
+		status = "partial"
+	}
+	h.stats.Count("jobs", 1, "status:"+status)
+	h.stats.Timing("job.duration", time.Duration(resp.ProcessingTimeMs*float64(time.Millisecond)), "status:"+status)
+	for _, sr := range resp.Streams {
+		tags := []string{"stream:" + sr.Stream, "status:" + sr.Status}
+		if p := streamProviders[sr.Stream]; p != "" {
+			tags = append(tags, "provider:"+p)
+		}
+		h.stats.Count("streams", 1, tags...)
+	}
+}
+
+// ScaleHandler reports a scaling hint on GET and changes the worker count on
+// PUT. Load is (running + queued) / workers, so a KEDA metrics-api scaler
+// targeting a load of 1 adds replicas while jobs are queueing.
+type ScaleHandler struct {
+	pool *pool.Pool
+}
+
+func NewScaleHandler(p *pool.Pool) *ScaleHandler {
+	return &ScaleHandler{pool: p}
+}
+
+type scaleResponse struct {
+	pool.Stats
+	Load float64 `json:"load"`
+}
+
+type scaleRequest struct {
+	Workers int `json:"workers"`
+}
+
+func (h *ScaleHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body scaleRequest
+		if !decodeJSON(w, req, &body) {
+			return
+		}
+		if body.Workers < 1 {
+			apierr.Write(w, "workers must be at least 1", http.StatusBadRequest)
+			return
+		}
+		h.pool.SetWorkers(body.Workers)
+	default:
+		apierr.Write(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s := h.pool.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scaleResponse{
+		Stats: s,
+		Load:  float64(s.Running+s.Queued) / float64(s.Workers),
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Migration is what Migrate found, and did, for one ad.
+type Migration struct {
+	// Outdated gives why each outdated stream's stored result is
+	Outdated map[string]string `json:"outdated,omitempty"`
+	// Streams are re-run in this order: the outdated streams and those
+	// that derive from them
+	Streams []string       `json:"streams,omitempty"`
+	Results []StreamResult `json:"results,omitempty"`
+}
+
+// Migrate re-runs, one at a time, the streams of adID whose stored results
+// streams.Outdated reports, followed by the streams that require them. Only
+// the streams named in among are checked, or all with none. The result each
+// run replaces is kept at ads/{id}/extraction/versions/{prompt or model
+// version}/{file}. With dryRun nothing is run. An error is returned if a
+// stream failed; the streams after it are not run.
+func (h *ExtractHandler) Migrate(ctx context.Context, adID string, among []string, dryRun bool) (*Migration, error) {
+	m := &Migration{Outdated: map[string]string{}}
+	a := &Assets{AdID: adID}
+	old := map[string]any{}
+	for _, s := range h.streams {
+		name := s.Name()
+		if slices.ContainsFunc(h.requires(s), func(req string) bool { return slices.Contains(m.Streams, req) }) {
+			m.Streams = append(m.Streams, name)
+			if r, ok := s.(Reloadable); ok {
+				old[name] = h.reload(ctx, r, name, a)
+			}
+			continue
+		}
+		r, ok := s.(Reloadable)
+		if !ok || (len(among) > 0 && !slices.Contains(among, name)) {
+			continue
+		}
+		v, err := r.Load(ctx, a)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", name, err)
+		}
+		if reason := streams.Outdated(provenanceOf(v)); reason != "" {
+			m.Outdated[name] = reason
+			m.Streams = append(m.Streams, name)
+			old[name] = v
+		}
+	}
+	if dryRun {
+		return m, nil
+	}
+
+	body := ExtractRequest{AdID: adID, Priority: client.PriorityBatch, Force: true}
+	for _, name := range m.Streams {
+		sr := h.RunStep(ctx, body, name)
+		m.Results = append(m.Results, sr)
+		if sr.Status == "error" {
+			return m, fmt.Errorf("%s: %s", name, sr.Error)
+		}
+		if sr.R2Key == "" {
+			continue
+		}
+		if err := h.archive(ctx, adID, path.Base(sr.R2Key), old[name]); err != nil {
+			return m, fmt.Errorf("keep previous %s: %w", name, err)
+		}
+	}
+	return m, nil
+}
+
+// archive stores a replaced result under its version. Results without
+// provenance, such as timelines, are derived entirely from others' and are
+// not kept.
+func (h *ExtractHandler) archive(ctx context.Context, adID, file string, v any) error {
+	p := provenanceOf(v)
+	if p == nil {
+		return nil
+	}
+	version := p.PromptVersion
+	if version == "" {
+		version = p.Model
+	}
+	return h.r2.UploadJSON(ctx, extractionKey(adID, path.Join("versions", version, file)), v)
+}
+
+// provenanceOf returns a stored output's provenance, if it has any.
+func provenanceOf(v any) *streams.Provenance {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out struct {
+		Provenance *streams.Provenance `json:"provenance"`
+	}
+	if json.Unmarshal(data, &out) != nil {
+		return nil
+	}
+	return out.Provenance
+}
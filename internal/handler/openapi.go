@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/auth"
+	"github.com/nikipaj1/video-description-pipeline/internal/openapi"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// apiOperations are the endpoints cmd/server registers under APIVersion,
+// described by the types they read and write. Add new endpoints here too.
+var apiOperations = []openapi.Operation{
+	{Method: "GET", Path: "/health", Summary: "Configured streams, and with ?detail=providers each provider's health",
+		Query:    []openapi.Param{{Name: "detail", Description: `"providers" adds provider health`}},
+		Response: Health{}},
+	{Method: "POST", Path: "/extract", Summary: "Run an extraction job and wait for it, or start it with ?async=true",
+		Scope:    auth.ScopeExtract,
+		Query:    []openapi.Param{{Name: "async", Type: "boolean", Description: "answer 202 with the job at once"}},
+		Request:  ExtractRequest{},
+		Response: ExtractResponse{},
+		Also:     map[int]any{http.StatusAccepted: Job{}}},
+	{Method: "GET", Path: "/jobs/{id}", Summary: "An async job", Scope: auth.ScopeRead, Response: Job{}},
+	{Method: "DELETE", Path: "/jobs/{id}", Summary: "Cancel an async job", Scope: auth.ScopeExtract, Response: Job{}},
+
+	{Method: "GET", Path: "/results/{ad_id}", Summary: "Every JSON artifact stored for an ad", Scope: auth.ScopeRead, Response: Results{}},
+	{Method: "GET", Path: "/results/{ad_id}/asr", Summary: "An ad's stored transcript", Scope: auth.ScopeRead, Response: streams.ASRResult{}},
+	{Method: "GET", Path: "/results/{ad_id}/vlm", Summary: "An ad's stored frame descriptions", Scope: auth.ScopeRead, Response: streams.VLMResult{}},
+	{Method: "DELETE", Path: "/results/{ad_id}", Summary: "Delete everything stored for an ad", Scope: auth.ScopeAdmin, Response: Purged{}},
+	{Method: "GET", Path: "/results/{ad_id}/download", Summary: "An expiring link to a stored artifact",
+		Scope: auth.ScopeRead,
+		Query: []openapi.Param{
+			{Name: "artifact", Description: "file name, e.g. asr_results.json"},
+			{Name: "expires", Description: "link lifetime, e.g. 1h"},
+		},
+		Response: client.DownloadLink{}},
+	{Method: "GET", Path: "/ads", Summary: "A page of the ads with results",
+		Scope: auth.ScopeRead,
+		Query: []openapi.Param{
+			{Name: "prefix", Description: "only ad IDs with this prefix"},
+			{Name: "limit", Type: "integer", Description: "page size"},
+			{Name: "token", Description: "next_token of the previous page"},
+		},
+		Response: AdList{}},
+	{Method: "POST", Path: "/compare", Summary: "Compare two extracted ads", Scope: auth.ScopeExtract,
+		Request: client.CompareRequest{}, Response: client.Comparison{}},
+	{Method: "POST", Path: "/events/storage", Summary: "Storage event notifications, which start jobs for new ads",
+		Query:  []openapi.Param{{Name: "token", Description: "events token, for senders that cannot set headers"}},
+		Status: http.StatusAccepted,
+		Response: struct {
+			Events int `json:"events"`
+		}{}},
+
+	{Method: "GET", Path: "/scale", Summary: "Worker pool load", Response: scaleResponse{}},
+	{Method: "PUT", Path: "/scale", Summary: "Resize the worker pool", Scope: auth.ScopeAdmin,
+		Request: scaleRequest{}, Response: scaleResponse{}},
+
+	{Method: "GET", Path: "/admin/keys", Summary: "Managed API keys", Scope: auth.ScopeAdmin,
+		Response: struct {
+			Keys []auth.Key `json:"keys"`
+		}{}},
+	{Method: "POST", Path: "/admin/keys", Summary: "Create an API key; the secret is only returned here", Scope: auth.ScopeAdmin,
+		Request: createKeyRequest{}, Response: createKeyResponse{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/keys/{id}", Summary: "Revoke an API key", Scope: auth.ScopeAdmin, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/ui/api/ads/{ad_id}", Summary: "What the web UI shows for an ad", Scope: auth.ScopeRead, Response: AdView{}},
+	{Method: "GET", Path: "/openapi.json", Summary: "This document"},
+}
+
+// fixedOperations are those served outside APIVersion.
+var fixedOperations = []openapi.Operation{
+	{Method: "GET", Path: "/livez", Summary: "Liveness probe", Response: Probe{}},
+	{Method: "GET", Path: "/readyz", Summary: "Readiness probe; 503 with the failing checks", Response: Probe{}},
+	{Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", ContentType: "text/plain"},
+}
+
+// OpenAPI serves the OpenAPI document of the HTTP API, for generating
+// clients. Its schemas are those of the request and response types, and
+// errors are client.Error bodies.
+func OpenAPI() http.Handler {
+	ops := slices.Clone(fixedOperations)
+	for _, op := range apiOperations {
+		op.Path = APIVersion + op.Path
+		ops = append(ops, op)
+	}
+	return openapi.New("video-description-pipeline", "1.0.0", ops, client.Error{}).Handler()
+}
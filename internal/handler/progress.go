@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// ndjson is the media type a client asks for, via Accept, to receive a
+// synchronous /extract as JSON lines with progress heartbeats.
+const ndjson = "application/x-ndjson"
+
+// wantsProgress reports whether the Accept header lists ndjson.
+func wantsProgress(req *http.Request) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == ndjson {
+			return true
+		}
+	}
+	return false
+}
+
+// progressReporter is told as a job moves between stages and as its
+// streams start and finish: a progressStream for synchronous requests, or
+// an async job's record.
+type progressReporter interface {
+	setStage(stage string)
+	streamStarted(name string)
+	streamFinished(sr StreamResult)
+}
+
+// noProgress reports to nobody, for jobs no one follows.
+type noProgress struct{}
+
+func (noProgress) setStage(string)             {}
+func (noProgress) streamStarted(string)        {}
+func (noProgress) streamFinished(StreamResult) {}
+
+// progressFunc passes a job's progress to a callback, one event at a time,
+// for APIs that stream it other than as JSON lines.
+type progressFunc struct {
+	mu     sync.Mutex
+	report func(progressEvent)
+	t0     time.Time
+}
+
+func (p *progressFunc) send(ev progressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev.ElapsedMs = time.Since(p.t0).Milliseconds()
+	p.report(ev)
+}
+
+func (p *progressFunc) setStage(stage string) {
+	p.send(progressEvent{Event: "progress", Stage: stage})
+}
+
+func (p *progressFunc) streamStarted(name string) {
+	p.send(progressEvent{Event: "stream", Stream: &StreamResult{Stream: name, Status: "running"}})
+}
+
+func (p *progressFunc) streamFinished(sr StreamResult) {
+	p.send(progressEvent{Event: "stream", Stream: &sr})
+}
+
+func (p *progressFunc) frameDone(done, total int) {
+	p.send(progressEvent{Event: "frame", FramesDone: done, FramesTotal: total})
+}
+
+// progressEvent is one line of a progress stream.
+type progressEvent = client.ProgressEvent
+
+// progressStream answers a request immediately with 200 and then writes a
+// heartbeat line every interval until the job finishes, so proxies with
+// short idle timeouts keep the connection open. Once started, failures can
+// no longer change the status code and are reported as an "error" event.
+type progressStream struct {
+	mu    sync.Mutex
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	t0    time.Time
+	stage string
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func startProgress(w http.ResponseWriter, interval time.Duration) *progressStream {
+	p := &progressStream{
+		w:     w,
+		rc:    http.NewResponseController(w),
+		t0:    time.Now(),
+		stage: "queued",
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	w.Header().Set("Content-Type", ndjson)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	p.write(progressEvent{Event: "progress", Stage: p.stage})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(cmp.Or(interval, 15*time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				stage := p.stage
+				p.mu.Unlock()
+				p.write(progressEvent{Event: "progress", Stage: stage})
+			}
+		}
+	}()
+	return p
+}
+
+// setStage reports that the job moved on. It is a no-op on a nil stream so
+// callers need not check whether progress was requested.
+func (p *progressStream) setStage(stage string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.stage = stage
+	p.mu.Unlock()
+	p.write(progressEvent{Event: "progress", Stage: stage})
+}
+
+// Streams starting and finishing are not reported; heartbeats carry the
+// stage only.
+func (p *progressStream) streamStarted(string)        {}
+func (p *progressStream) streamFinished(StreamResult) {}
+
+// finish stops the heartbeats and writes the final event.
+func (p *progressStream) finish(ev progressEvent) {
+	p.close()
+	p.write(ev)
+}
+
+// close stops the heartbeats; the handler must not return before it has.
+func (p *progressStream) close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *progressStream) write(ev progressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev.ElapsedMs = time.Since(p.t0).Milliseconds()
+	// Write errors mean the client has gone; the job carries on regardless.
+	json.NewEncoder(p.w).Encode(ev)
+	p.rc.Flush()
+}
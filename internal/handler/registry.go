@@ -0,0 +1,523 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"sync"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/redact"
+	"github.com/nikipaj1/video-description-pipeline/internal/schema"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Stream is one kind of result a job produces. Every registered stream runs
+// in every job, concurrently with the others unless it requires them.
+type Stream interface {
+	// Name identifies the stream in responses and in other streams'
+	// Requires.
+	Name() string
+
+	// Requires lists the streams whose outputs Run reads; Run starts once
+	// they have finished, whatever their outcome. AllStreams waits for every
+	// other stream, except those that also require AllStreams and were
+	// registered after it. A stream that has work to do before it needs another's
+	// output can leave it out and call Assets.Output, which waits, instead.
+	Requires() []string
+
+	// Run produces the stream's artifact, or fails. Skip ends it without
+	// one.
+	Run(ctx context.Context, a *Assets) (*Artifact, error)
+}
+
+// AllStreams in Requires runs a stream after all others, e.g. to transform
+// or package their artifacts.
+const AllStreams = "*"
+
+// Optional is implemented by streams that run only for some jobs. Streams
+// a job does not want are left out of its response.
+type Optional interface {
+	Wanted(a *Assets) bool
+}
+
+// Staged is implemented by streams that work on other streams' results, to
+// report the job's stage ("post_processing", "bundling") when they start.
+// Streams without it count as "extracting".
+type Staged interface {
+	Stage() string
+}
+
+// Reloadable is implemented by streams whose stored artifact can be read
+// back. A job that runs only some streams, such as a workflow step, reads
+// the others' outputs this way instead of running them.
+type Reloadable interface {
+	// Load returns the output an earlier run stored, or nil if there is
+	// none.
+	Load(ctx context.Context, a *Assets) (any, error)
+}
+
+// Cacheable is implemented by streams whose artifact is stored at a key
+// known before they run. Unless the request sets force, or names the
+// stream in streams, a job reuses an artifact already at Key as the
+// stream's result, with status "cached", instead of running it again. An
+// artifact derived from streams that did run again is not reused. Key
+// returns "" when the request asks for output a stored artifact may not
+// match.
+type Cacheable interface {
+	Key(a *Assets) string
+}
+
+// Checkable is implemented by streams that can tell before running whether
+// they would be skipped, which dry runs report. Check returns the Skip error
+// Run would end with up front, or nil.
+type Checkable interface {
+	Check(ctx context.Context, a *Assets) error
+}
+
+// stages orders progress stages; a job's stage only moves forward.
+var stages = []string{"extracting", "post_processing", "bundling"}
+
+// Artifact is a stream's result.
+type Artifact struct {
+	// Value is the stream's output, which later streams read through
+	// Assets.Output. Unless the stream stored it itself, it is written to
+	// R2 as JSON at Key.
+	Value  any
+	Key    string
+	Stored bool // the stream wrote Key itself
+
+	Count   int    // entries produced, reported as result_count
+	Partial string // why the output is incomplete, if it is
+}
+
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return e.reason }
+
+// Skip ends a stream without an artifact; it is reported as skipped with
+// reason.
+func Skip(reason string) error { return &skipError{reason: reason} }
+
+// Assets are a job's inputs and the outputs of its finished streams.
+type Assets struct {
+	AdID      string
+	Request   ExtractRequest
+	Batch     bool // run through the Gemini Batch API
+	MaxFrames int
+	Tokens    *streams.TokenBudget // Gemini tokens the job's streams use
+
+	fetchKeyframes func() []r2.KeyframeMeta
+	keyframesOnce  sync.Once
+	keyframes      []r2.KeyframeMeta
+	keyframesDone  chan struct{}
+
+	imagesOnce sync.Once
+	imagesMu   sync.Mutex
+	images     map[string]*keyframeImage // nil unless images are shared
+
+	openVideo func(ctx context.Context) (*r2.ObjectReader, error)
+	videoOnce sync.Once
+	video     *r2.ObjectReader
+	videoErr  error
+
+	// only, if not nil, restricts the job to these streams; the others'
+	// outputs are reloaded
+	only []string
+	runs map[string]*streamRun
+}
+
+// Video opens the ad's video on first use. It is streamed rather than
+// buffered, so only one stream can read it.
+func (a *Assets) Video(ctx context.Context) (*r2.ObjectReader, error) {
+	a.videoOnce.Do(func() { a.video, a.videoErr = a.openVideo(ctx) })
+	return a.video, a.videoErr
+}
+
+// closeVideo closes the video if a stream opened it.
+func (a *Assets) closeVideo() {
+	a.videoOnce.Do(func() {})
+	if a.video != nil {
+		a.video.Close()
+	}
+}
+
+// Keyframes waits for the ad's keyframe metadata. It is nil if the ad has
+// none.
+func (a *Assets) Keyframes(ctx context.Context) []r2.KeyframeMeta {
+	a.prefetchKeyframes()
+	if !waitFor(ctx, a.keyframesDone) {
+		return nil
+	}
+	return a.keyframes
+}
+
+// prefetchKeyframes starts fetching the keyframe metadata if no stream has
+// asked for it yet.
+func (a *Assets) prefetchKeyframes() {
+	a.keyframesOnce.Do(func() {
+		go func() {
+			defer close(a.keyframesDone)
+			a.keyframes = a.fetchKeyframes()
+		}()
+	})
+}
+
+// keyframeReaders are the streams that read keyframe images.
+var keyframeReaders = []string{"vlm", "people", "presenter", "visual_stats", "content_rating", "products", "cta", "hook_analysis"}
+
+// keyframeImage is one keyframe's bytes, downloaded by the first stream to
+// read it.
+type keyframeImage struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// keyframeImage writes the keyframe image at key into buf. When more than
+// one wanted stream reads keyframes, each image is downloaded once per job
+// and kept for the others, at the cost of holding the ad's keyframes until
+// the job ends; otherwise it is downloaded straight into buf.
+func (a *Assets) keyframeImage(ctx context.Context, key string, buf *bytes.Buffer, download func(context.Context, string, *bytes.Buffer) error) error {
+	a.imagesOnce.Do(func() {
+		readers := 0
+		for _, name := range keyframeReaders {
+			if run := a.runs[name]; run != nil && run.wanted {
+				readers++
+			}
+		}
+		if readers > 1 {
+			a.images = map[string]*keyframeImage{}
+		}
+	})
+	if a.images == nil {
+		return download(ctx, key, buf)
+	}
+
+	a.imagesMu.Lock()
+	img, ok := a.images[key]
+	if !ok {
+		img = &keyframeImage{done: make(chan struct{})}
+		a.images[key] = img
+	}
+	a.imagesMu.Unlock()
+	if !ok {
+		if img.err = download(ctx, key, buf); img.err == nil {
+			img.data = bytes.Clone(buf.Bytes())
+		} else {
+			// Not kept, so that the next reader tries again
+			a.imagesMu.Lock()
+			delete(a.images, key)
+			a.imagesMu.Unlock()
+		}
+		close(img.done)
+		return img.err
+	}
+	if !waitFor(ctx, img.done) {
+		return context.Cause(ctx)
+	}
+	if img.err != nil {
+		return download(ctx, key, buf)
+	}
+	_, err := buf.Write(img.data)
+	return err
+}
+
+// Output waits for the named stream and returns its artifact's Value: nil
+// if it failed, was skipped or is not registered.
+func (a *Assets) Output(ctx context.Context, name string) any {
+	run := a.runs[name]
+	if run == nil || !waitFor(ctx, run.done) {
+		return nil
+	}
+	return run.output
+}
+
+// waitFor waits until done is closed or ctx ends, reporting which. A closed
+// channel wins, so finished work stays readable after the job's deadline.
+func waitFor(ctx context.Context, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+	}
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// outputs returns the Value of every finished stream that produced one.
+func (a *Assets) outputs() map[string]any {
+	out := make(map[string]any)
+	for name, run := range a.runs {
+		select {
+		case <-run.done:
+			if run.output != nil {
+				out[name] = run.output
+			}
+		default:
+		}
+	}
+	return out
+}
+
+// output is Output as a concrete type, for the built-in streams.
+func output[T any](ctx context.Context, a *Assets, name string) T {
+	v, _ := a.Output(ctx, name).(T)
+	return v
+}
+
+type streamRun struct {
+	done   chan struct{}
+	output any
+	result StreamResult
+	wanted bool
+}
+
+// Register adds a stream to every job after those already registered. A
+// stream can only require streams registered before it, so requirements
+// never form a cycle.
+func (h *ExtractHandler) Register(s Stream) error {
+	for _, existing := range h.streams {
+		if existing.Name() == s.Name() {
+			return fmt.Errorf("stream %q is already registered", s.Name())
+		}
+	}
+	for _, req := range s.Requires() {
+		if req != AllStreams && !slices.ContainsFunc(h.streams, func(r Stream) bool { return r.Name() == req }) {
+			return fmt.Errorf("stream %q requires %q, which is not registered before it", s.Name(), req)
+		}
+	}
+	h.streams = append(h.streams, s)
+	return nil
+}
+
+// OnStreamDone registers fn to be told as each stream of a job finishes,
+// once its artifact is stored. fn runs before streams that require this
+// one start, so it should return quickly. Register observers before the
+// handler takes jobs.
+func (h *ExtractHandler) OnStreamDone(fn func(adID string, sr StreamResult)) {
+	h.streamDone = append(h.streamDone, fn)
+}
+
+// runStreams runs every wanted stream of a job and returns their results in
+// registration order. When the job is restricted to some streams, the
+// others are reloaded alongside.
+func (h *ExtractHandler) runStreams(ctx context.Context, a *Assets, progress progressReporter) []StreamResult {
+	ctx = streams.WithTokenBudget(ctx, a.Tokens)
+	a.runs = make(map[string]*streamRun, len(h.streams))
+	for _, s := range h.streams {
+		a.runs[s.Name()] = &streamRun{done: make(chan struct{}), wanted: h.wanted(s, a)}
+	}
+
+	var stageMu sync.Mutex
+	stage := 0
+	advance := func(s Stream) {
+		staged, ok := s.(Staged)
+		if !ok {
+			return
+		}
+		stageMu.Lock()
+		defer stageMu.Unlock()
+		if i := slices.Index(stages, staged.Stage()); i > stage {
+			stage = i
+			progress.setStage(stages[i])
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range h.streams {
+		run := a.runs[s.Name()]
+		if !run.wanted {
+			if r, ok := s.(Reloadable); ok && a.only != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer close(run.done)
+					run.output = h.reload(ctx, r, s.Name(), a)
+				}()
+			} else {
+				close(run.done)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(run.done)
+			for _, dep := range h.requires(s) {
+				<-a.runs[dep].done
+			}
+			advance(s)
+			progress.streamStarted(s.Name())
+			cached := false
+			if c, ok := s.(Cacheable); ok && h.reusable(s, a) {
+				if key := c.Key(a); key != "" {
+					run.result, run.output, cached = h.cached(ctx, s, key, a)
+				}
+			}
+			if !cached {
+				run.result, run.output = h.runStream(ctx, s, a)
+			}
+			progress.streamFinished(run.result)
+			for _, fn := range h.streamDone {
+				fn(a.AdID, run.result)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var results []StreamResult
+	for _, s := range h.streams {
+		if run := a.runs[s.Name()]; run.wanted {
+			results = append(results, run.result)
+		}
+	}
+	return results
+}
+
+func (h *ExtractHandler) wanted(s Stream, a *Assets) bool {
+	if a.only != nil && !slices.Contains(a.only, s.Name()) {
+		return false
+	}
+	opt, ok := s.(Optional)
+	return !ok || opt.Wanted(a)
+}
+
+// reusable reports whether a stored artifact may stand in for running s:
+// the request neither forces a re-run nor names s, and, unless it fills in
+// missing results, every stream s requires that ran in this job was itself
+// cached.
+func (h *ExtractHandler) reusable(s Stream, a *Assets) bool {
+	if a.Request.Force || slices.Contains(a.Request.Streams, s.Name()) {
+		return false
+	}
+	if a.Request.Mode == client.ModeFillMissing {
+		return true
+	}
+	for _, dep := range h.requires(s) {
+		if run := a.runs[dep]; run.wanted && run.result.Status != "cached" {
+			return false
+		}
+	}
+	return true
+}
+
+// cached returns s's result and output from the artifact stored at key, or
+// false if there is none, its output cannot be read back or it was cut
+// short.
+func (h *ExtractHandler) cached(ctx context.Context, s Stream, key string, a *Assets) (StreamResult, any, bool) {
+	ok, err := h.r2.Exists(ctx, key)
+	if err != nil {
+		log.Printf("WARN: %s for %s: %v", s.Name(), a.AdID, err)
+	}
+	if !ok {
+		return StreamResult{}, nil, false
+	}
+	var out any
+	if r, ok := s.(Reloadable); ok {
+		if out = h.reload(ctx, r, s.Name(), a); out == nil {
+			return StreamResult{}, nil, false
+		}
+		// A result cut short by a deadline or token budget is finished
+		// by running again
+		if p, ok := out.(interface{ Partial() bool }); ok && p.Partial() {
+			return StreamResult{}, nil, false
+		}
+	}
+	return StreamResult{Stream: s.Name(), Status: "cached", R2Key: key}, out, true
+}
+
+// reload reads a stream's stored output, as nil if there is none or it
+// cannot be read.
+func (h *ExtractHandler) reload(ctx context.Context, r Reloadable, name string, a *Assets) any {
+	v, err := r.Load(ctx, a)
+	if err != nil {
+		log.Printf("WARN: reload %s for %s: %v", name, a.AdID, err)
+		return nil
+	}
+	return v
+}
+
+// requires resolves AllStreams to the streams that do not themselves wait
+// for all others, plus those that do and were registered before s.
+func (h *ExtractHandler) requires(s Stream) []string {
+	reqs := s.Requires()
+	if !slices.Contains(reqs, AllStreams) {
+		return reqs
+	}
+	var all []string
+	before := true
+	for _, other := range h.streams {
+		if other.Name() == s.Name() {
+			before = false
+			continue
+		}
+		if before || !slices.Contains(other.Requires(), AllStreams) {
+			all = append(all, other.Name())
+		}
+	}
+	return all
+}
+
+// validateArtifact checks an artifact against its file's schema, as
+// ARTIFACT_VALIDATION says. Under "warn" mismatches are only logged.
+func (h *ExtractHandler) validateArtifact(art *Artifact) error {
+	if h.cfg.ArtifactValidation == "off" {
+		return nil
+	}
+	err := schema.Validate(path.Base(art.Key), art.Value)
+	if err != nil && h.cfg.ArtifactValidation == "warn" {
+		log.Printf("WARN: %s: %v", art.Key, err)
+		return nil
+	}
+	return err
+}
+
+// runStream runs one stream and stores its artifact, turning the outcome
+// into the stream's entry in the response.
+func (h *ExtractHandler) runStream(ctx context.Context, s Stream, a *Assets) (StreamResult, any) {
+	name := s.Name()
+	art, err := s.Run(ctx, a)
+	err = redact.Err(err)
+	var skip *skipError
+	switch {
+	case errors.As(err, &skip):
+		return StreamResult{Stream: name, Status: "skipped", Error: skip.reason}, nil
+	case errors.Is(err, streams.ErrProviderUnavailable):
+		log.Printf("%s skipped for %s: %v", name, a.AdID, err)
+		return StreamResult{Stream: name, Status: "unavailable", Error: err.Error()}, nil
+	case errors.Is(err, streams.ErrProviderDegraded):
+		log.Printf("%s skipped for %s: %v", name, a.AdID, err)
+		return StreamResult{Stream: name, Status: "skipped", Error: err.Error()}, nil
+	case err != nil:
+		log.Printf("%s failed for %s: %v", name, a.AdID, err)
+		return StreamResult{Stream: name, Status: "error", Error: err.Error()}, nil
+	}
+
+	if art.Key != "" && !art.Stored {
+		if err := h.validateArtifact(art); err != nil {
+			log.Printf("%s output for %s rejected: %v", name, a.AdID, err)
+			return StreamResult{Stream: name, Status: "error", Error: err.Error()}, nil
+		}
+		if err := redact.Err(h.uploadJSON(ctx, art.Key, art.Value)); err != nil {
+			log.Printf("%s upload failed for %s: %v", name, a.AdID, err)
+			return StreamResult{Stream: name, Status: "error", Error: err.Error()}, nil
+		}
+	}
+
+	sr := StreamResult{Stream: name, Status: "success", ResultCount: art.Count, R2Key: art.Key}
+	if art.Partial != "" {
+		sr.Status, sr.Error = "partial", art.Partial
+		log.Printf("%s for %s is partial: %s", name, a.AdID, art.Partial)
+	}
+	return sr, art.Value
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKeyframeImage(t *testing.T) {
+	var downloads atomic.Int32
+	download := func(_ context.Context, key string, buf *bytes.Buffer) error {
+		downloads.Add(1)
+		if key == "missing" {
+			return errors.New("not found")
+		}
+		buf.WriteString("jpeg " + key)
+		return nil
+	}
+	ctx := context.Background()
+
+	// Shared by vlm and people: downloaded once
+	a := &Assets{runs: map[string]*streamRun{"vlm": {wanted: true}, "people": {wanted: true}}}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if err := a.keyframeImage(ctx, "f0", &buf, download); err != nil || buf.String() != "jpeg f0" {
+				t.Errorf("f0 = %q, %v", buf.String(), err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("%d downloads, want 1", n)
+	}
+
+	// Failures are not kept
+	var buf bytes.Buffer
+	a.keyframeImage(ctx, "missing", &buf, download)
+	if err := a.keyframeImage(ctx, "missing", &buf, download); err == nil || downloads.Load() != 3 {
+		t.Errorf("err = %v after %d downloads", err, downloads.Load())
+	}
+
+	// Read by vlm alone: never kept
+	downloads.Store(0)
+	a = &Assets{runs: map[string]*streamRun{"vlm": {wanted: true}, "people": {}}}
+	for range 2 {
+		buf.Reset()
+		a.keyframeImage(ctx, "f0", &buf, download)
+	}
+	if n := downloads.Load(); n != 2 || a.images != nil {
+		t.Errorf("%d downloads, want 2", n)
+	}
+}
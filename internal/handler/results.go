@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// ResultsHandler serves an ad's stored results, read from R2 so that
+// downstream services need only the HTTP API: GET /results/{ad_id} returns
+// every JSON artifact, and Artifact serves single ones such as
+// GET /results/{ad_id}/asr.
+type ResultsHandler struct {
+	r2 *r2.Client
+}
+
+func NewResultsHandler(r2Client *r2.Client) *ResultsHandler {
+	return &ResultsHandler{r2: r2Client}
+}
+
+type (
+	Results = client.Results
+	Purged  = client.Purged
+)
+
+// ServeHTTP serves GET /results/{ad_id}. Its ETag is a hash of the body,
+// so clients that send If-None-Match get a 304 without the body while no
+// artifact has changed.
+func (h *ResultsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	out, err := h.Load(req.Context(), req.PathValue("ad_id"))
+	if errors.Is(err, ErrNoResults) {
+		apierr.Write(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(out)
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, compared
+// weakly as RFC 9110 asks, or is "*".
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoResults is returned for ads with no stored results.
+var ErrNoResults = errors.New("no results")
+
+// Load reads the ad's stored results as GET /results/{ad_id} returns them.
+// It fails with ErrNoResults for an ad that has none.
+func (h *ResultsHandler) Load(ctx context.Context, adID string) (*Results, error) {
+	prefix := extractionKey(adID, "")
+	keys, err := h.r2.ListKeys(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Results{AdID: adID, Artifacts: map[string]json.RawMessage{}}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, key := range keys {
+		// Only the current artifacts, not archived versions or bundles
+		file := strings.TrimPrefix(key, prefix)
+		if strings.Contains(file, "/") || path.Ext(file) != ".json" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := h.r2.DownloadObject(ctx, key)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, r2.ErrNotFound):
+				// deleted since the listing
+			case err != nil:
+				errs = append(errs, err)
+			case !json.Valid(data):
+				errs = append(errs, fmt.Errorf("%s is not valid JSON", key))
+			default:
+				out.Artifacts[strings.TrimSuffix(file, ".json")] = data
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(out.Artifacts) == 0 {
+		return nil, fmt.Errorf("ad %s has %w", adID, ErrNoResults)
+	}
+	return out, nil
+}
+
+// Purge serves DELETE /results/{ad_id}: it removes everything the pipeline
+// wrote for the ad, and lists the deleted keys. That is the ad's
+// extraction/ prefix, archived versions and bundles included, and the
+// objects recordObjects listed: the records of its finished async jobs,
+// which hold the request and results, and a video fetched from video_url
+// or keyframes extracted with ffmpeg. A video and keyframes uploaded by
+// others are left alone, and so are jobs still running, which store their
+// results and records when they finish; purge again after them. A purge
+// cut short can be repeated.
+func (h *ResultsHandler) Purge(w http.ResponseWriter, req *http.Request) {
+	adID := req.PathValue("ad_id")
+	ctx := req.Context()
+	keys, err := h.r2.ListKeys(ctx, extractionKey(adID, ""))
+	if err != nil {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var written adObjects
+	if err := h.r2.DownloadJSON(ctx, adObjectsKey(adID), &written); err != nil && !errors.Is(err, r2.ErrNotFound) {
+		apierr.Write(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(keys) == 0 && len(written.Keys) == 0 {
+		apierr.Write(w, fmt.Sprintf("ad %s has no results", adID), http.StatusNotFound)
+		return
+	}
+	// The list goes last, so that a purge cut short still finds the rest
+	keys = append(keys, written.Keys...)
+	if len(written.Keys) > 0 {
+		keys = append(keys, adObjectsKey(adID))
+	}
+
+	out := Purged{AdID: adID, Deleted: make([]string, 0, len(keys))}
+	for _, key := range keys {
+		if err := h.r2.Delete(ctx, key); err != nil {
+			log.Printf("WARN: purge %s: %v after deleting %d keys", adID, err, len(out.Deleted))
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		out.Deleted = append(out.Deleted, key)
+	}
+	log.Printf("purged %s: %d keys", adID, len(out.Deleted))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// adObjects lists the objects outside extraction/ that the pipeline stored
+// for an ad, for Purge to find.
+type adObjects struct {
+	Keys []string `json:"keys"`
+}
+
+func adObjectsKey(adID string) string { return "ads/" + adID + "/pipeline_objects.json" }
+
+// adObjectsMu orders the updates of adObjects lists made here.
+var adObjectsMu sync.Mutex
+
+// recordObjects adds keys to the ad's adObjects list.
+func recordObjects(ctx context.Context, r2Client *r2.Client, adID string, keys ...string) error {
+	adObjectsMu.Lock()
+	defer adObjectsMu.Unlock()
+	var list adObjects
+	if err := r2Client.DownloadJSON(ctx, adObjectsKey(adID), &list); err != nil && !errors.Is(err, r2.ErrNotFound) {
+		return err
+	}
+	n := len(list.Keys)
+	for _, key := range keys {
+		if !slices.Contains(list.Keys, key) {
+			list.Keys = append(list.Keys, key)
+		}
+	}
+	if len(list.Keys) == n {
+		return nil
+	}
+	return r2Client.UploadJSON(ctx, adObjectsKey(adID), list)
+}
+
+// Artifact serves one stored artifact of the ad in the path, e.g.
+// "asr_results.json", as stored. Its ETag is passed on, so clients that
+// send If-None-Match get a 304 without the body while it is unchanged.
+func (h *ResultsHandler) Artifact(file string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		adID := req.PathValue("ad_id")
+		// R2 is only asked about a single strong tag; lists, weak tags and
+		// "*" are compared here
+		inm := req.Header.Get("If-None-Match")
+		ask := strings.TrimPrefix(inm, "W/")
+		if strings.Contains(ask, ",") || ask == "*" {
+			ask = ""
+		}
+		data, etag, err := h.r2.DownloadObjectIfNoneMatch(req.Context(), extractionKey(adID, file), ask)
+		if err == nil && etag != "" && etagMatches(inm, etag) {
+			err = r2.ErrNotModified
+		}
+		switch {
+		case errors.Is(err, r2.ErrNotModified):
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case errors.Is(err, r2.ErrNotFound):
+			apierr.Write(w, fmt.Sprintf("ad %s has no %s", adID, file), http.StatusNotFound)
+			return
+		case err != nil:
+			apierr.Write(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.Write(data)
+	})
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+)
+
+func TestPurge(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	seedTestAd(t, store, "ad2", 2)
+	h := newTestHandler(t, store, nil)
+	ctx := context.Background()
+
+	job, err := h.Submit(ctx, ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, h, job.ID)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(store.Keys(testBucket, "jobs/"+job.ID)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := h.Extract(ctx, ExtractRequest{AdID: "ad2"}); err != nil {
+		t.Fatal(err)
+	}
+	// As if fetched from video_url
+	if err := recordObjects(ctx, h.r2, "ad1", videoKey("ad1")); err != nil {
+		t.Fatal(err)
+	}
+
+	purge := routed("DELETE /results/{ad_id}", NewResultsHandler(h.r2).Purge)
+	var purged Purged
+	if code := serve(t, purge, httptest.NewRequest(http.MethodDelete, "/results/ad1", nil), &purged); code != http.StatusOK {
+		t.Fatalf("purge: %d", code)
+	}
+	for _, key := range []string{"ads/ad1/extraction/asr_results.json", "jobs/" + job.ID + ".json", videoKey("ad1"), adObjectsKey("ad1")} {
+		if !slices.Contains(purged.Deleted, key) {
+			t.Errorf("%s not in %v", key, purged.Deleted)
+		}
+		if _, ok := store.Get(testBucket, key); ok {
+			t.Errorf("%s still stored", key)
+		}
+	}
+	// Uploaded by others, or another ad's
+	for _, key := range []string{"ads/ad1/keyframes/metadata.json", "ads/ad2/extraction/asr_results.json"} {
+		if _, ok := store.Get(testBucket, key); !ok {
+			t.Errorf("%s purged", key)
+		}
+	}
+
+	if code := serve(t, purge, httptest.NewRequest(http.MethodDelete, "/results/ad1", nil), nil); code != http.StatusNotFound {
+		t.Errorf("second purge: %d, want 404", code)
+	}
+}
+
+func TestResultsETag(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 2)
+	h := newTestHandler(t, store, nil)
+	if _, err := h.Extract(context.Background(), ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+	results := NewResultsHandler(h.r2)
+	mux := http.NewServeMux()
+	mux.Handle("GET /results/{ad_id}", results)
+	mux.Handle("GET /results/{ad_id}/asr", results.Artifact("asr_results.json"))
+
+	for _, path := range []string{"/results/ad1", "/results/ad1/asr"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" || rec.Body.Len() == 0 {
+			t.Fatalf("%s: %d, ETag %q", path, rec.Code, etag)
+		}
+		for header, want := range map[string]int{
+			etag:               http.StatusNotModified,
+			"W/" + etag:        http.StatusNotModified,
+			`"other", ` + etag: http.StatusNotModified,
+			`"other"`:          http.StatusOK,
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", header)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Errorf("%s If-None-Match %s: %d, want %d", path, header, rec.Code, want)
+			}
+			if want == http.StatusNotModified && (rec.Body.Len() > 0 || rec.Header().Get("ETag") != etag) {
+				t.Errorf("%s If-None-Match %s: body %q, ETag %q", path, header, rec.Body, rec.Header().Get("ETag"))
+			}
+		}
+	}
+
+	// A changed artifact changes the ETag
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/ad1", nil))
+	store.Put(testBucket, "ads/ad1/extraction/asr_results.json", []byte(`{"transcript":"changed"}`), "application/json")
+	req := httptest.NewRequest(http.MethodGet, "/results/ad1", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("after a change: %d, want 200", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results/ad2", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown ad: %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Plan is a job split into steps that can run separately, even on different
+// machines, as a workflow engine runs them: one step per stream, then
+// Finish. Steps exchange outputs through the artifacts they store.
+type Plan struct {
+	Steps []Step `json:"steps"`
+
+	// Timeout bounds one step, including storing its artifact
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Step runs one stream once the steps it requires have finished.
+type Step struct {
+	Stream   string   `json:"stream"`
+	Requires []string `json:"requires,omitempty"`
+}
+
+// Plan lists the streams a job runs, in response order. Invalid requests
+// are reported as a *JobError with status 400.
+func (h *ExtractHandler) Plan(body ExtractRequest) (*Plan, error) {
+	if err := h.validateRequest(&body); err != nil {
+		return nil, &JobError{Status: http.StatusBadRequest, Err: err}
+	}
+	a := &Assets{AdID: body.AdID, Request: body}
+	if len(body.Streams) > 0 {
+		a.only = body.Streams
+	}
+	var names []string
+	for _, s := range h.streams {
+		if h.wanted(s, a) {
+			names = append(names, s.Name())
+		}
+	}
+	plan := &Plan{Timeout: h.jobTimeout(body.Priority == client.PriorityBatch) + 2*persistTimeout}
+	for _, s := range h.streams {
+		if !slices.Contains(names, s.Name()) {
+			continue
+		}
+		step := Step{Stream: s.Name()}
+		for _, req := range h.requires(s) {
+			if slices.Contains(names, req) {
+				step.Requires = append(step.Requires, req)
+			}
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// RunStep runs one stream of a job, reading the outputs of the streams it
+// requires from their stored artifacts.
+func (h *ExtractHandler) RunStep(ctx context.Context, body ExtractRequest, stream string) StreamResult {
+	ctx, cancel := context.WithTimeout(ctx, h.jobTimeout(body.Priority == client.PriorityBatch))
+	defer cancel()
+
+	a := h.newAssets(ctx, body)
+	a.only = []string{stream}
+	defer a.closeVideo()
+
+	results := h.runStreams(ctx, a, noProgress{})
+	if len(results) == 0 {
+		return StreamResult{Stream: stream, Status: "skipped", Error: "not part of this job"}
+	}
+	return results[0]
+}
+
+// Finish completes a job whose steps have run, as Extract does after its
+// streams: it scores the job from the stored transcript and frame
+// descriptions, delivers the webhook, feeds the BigQuery sink and records
+// the outcome.
+func (h *ExtractHandler) Finish(ctx context.Context, body ExtractRequest, results []StreamResult, elapsed time.Duration) *ExtractResponse {
+	a := h.newAssets(ctx, body)
+	a.only = []string{}
+	h.runStreams(ctx, a, noProgress{})
+
+	partial := slices.ContainsFunc(results, func(sr StreamResult) bool { return sr.Status == "partial" })
+	resp := h.finish(ctx, a, results, elapsed, partial)
+	h.reportOutcome(ctx, body.AdID, resp, nil)
+	return resp
+}
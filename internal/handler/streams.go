@@ -0,0 +1,780 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/bundle"
+	"github.com/nikipaj1/video-description-pipeline/internal/hooks"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// builtinStreams are registered by NewExtractHandler, in response order.
+// Configured post-processing hooks come just before the bundle, so it
+// includes their artifacts.
+func builtinStreams(h *ExtractHandler) []Stream {
+	s := []Stream{
+		asrStream{h},
+		vlmStream{h},
+		videoMetaStream{h},
+		audioStream{h},
+		peopleStream{h},
+		presenterStream{h},
+		visualStatsStream{h},
+		contentRatingStream{h},
+		productsStream{h},
+		ctaStream{h},
+		musicStream{h},
+		hookAnalysisStream{h},
+		entitiesStream{h},
+		timelineStream{h},
+		keyMomentsStream{h},
+		summaryStream{h},
+	}
+	for i, name := range h.cfg.PostHooks {
+		// unknown names fail Validate
+		if hook, ok := hooks.Builtin(name); ok && !slices.Contains(h.cfg.PostHooks[:i], name) {
+			s = append(s, hookStream{h, name, hook})
+		}
+	}
+	if h.cfg.TransformURL != "" {
+		s = append(s, hookStream{h, "transform", hooks.Remote(h.cfg.TransformURL, h.cfg.TransformTimeout)})
+	}
+	return append(s, bundleStream{h})
+}
+
+func extractionKey(adID, file string) string {
+	return fmt.Sprintf("ads/%s/extraction/%s", adID, file)
+}
+
+// loadJSON reads a stored JSON artifact for a Reloadable stream; T is the
+// stream's output type.
+func loadJSON[T any](ctx context.Context, h *ExtractHandler, adID, file string) (any, error) {
+	data, err := h.r2.DownloadObject(ctx, extractionKey(adID, file))
+	if errors.Is(err, r2.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", file, err)
+	}
+	return v, nil
+}
+
+// asrStream transcribes the video with Deepgram, streaming it from R2 as
+// soon as the job starts. A degraded provider is skipped up front rather
+// than waited on.
+type asrStream struct{ h *ExtractHandler }
+
+func (asrStream) Name() string       { return "asr" }
+func (asrStream) Requires() []string { return nil }
+
+func (s asrStream) Check(ctx context.Context, a *Assets) error {
+	if !s.h.cfg.DeepgramConfigured() {
+		return Skip("DEEPGRAM_API_KEY not configured")
+	}
+	if err := streams.DeepgramHealth(); err != nil {
+		return Skip(err.Error())
+	}
+	return nil
+}
+
+func (s asrStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	if err := s.Check(ctx, a); err != nil {
+		return nil, err
+	}
+	video, err := a.Video(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunASR(ctx, video, video.Size(), s.h.cfg.DeepgramAPIKey, streams.ASROptions{Language: a.Request.Language, Params: s.deepgramParams(a)})
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: res, Key: extractionKey(a.AdID, "asr_results.json"), Count: len(res.Segments)}, nil
+}
+
+// deepgramParams are DEEPGRAM_PARAMS with the request's deepgram_params
+// and asr_model applied on top.
+func (s asrStream) deepgramParams(a *Assets) map[string]string {
+	params := maps.Clone(s.h.cfg.DeepgramParams)
+	if params == nil {
+		params = map[string]string{}
+	}
+	maps.Copy(params, a.Request.DeepgramParams)
+	if a.Request.ASRModel != "" {
+		params["model"] = a.Request.ASRModel
+	}
+	return params
+}
+
+func (s asrStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.ASRResult](ctx, s.h, a.AdID, "asr_results.json")
+}
+
+// Key is "" for a chosen model, language or Deepgram parameters, which the
+// stored transcript need not have come from, unless the request fills in
+// missing results.
+func (asrStream) Key(a *Assets) string {
+	if a.Request.Mode != client.ModeFillMissing && (a.Request.ASRModel != "" || a.Request.Language != "" || len(a.Request.DeepgramParams) > 0) {
+		return ""
+	}
+	return extractionKey(a.AdID, "asr_results.json")
+}
+
+// vlmStream describes the keyframes with Gemini, interactively or through
+// the Batch API. Keyframe images are fetched lazily, one frame ahead at a
+// time, into pooled buffers instead of being held for the whole job.
+type vlmStream struct{ h *ExtractHandler }
+
+func (vlmStream) Name() string       { return "vlm" }
+func (vlmStream) Requires() []string { return nil }
+
+func (s vlmStream) Check(ctx context.Context, a *Assets) error {
+	_, err := s.h.geminiKeyframes(ctx, a)
+	return err
+}
+
+func (s vlmStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	h := s.h
+	inputs, err := h.geminiKeyframes(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	// A batch holds every encoded frame until it is submitted, so it
+	// reserves memory for all of them first, as the bundle does
+	run := streams.RunVLM
+	if a.Batch {
+		admitCtx, cancel := context.WithTimeout(ctx, h.cfg.AdmissionWait)
+		release, err := h.memory.Reserve(admitCtx, int64(len(inputs))*int64(h.cfg.AdmissionFrameKB)<<10*2)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		run = streams.RunVLMBatch
+	}
+
+	opts := h.vlmOptions(a.MaxFrames)
+	opts.PromptTemplate, opts.Model = a.Request.VLMPromptTemplate, a.Request.VLMModel
+	if h.multilingual(a) {
+		if asr := output[*streams.ASRResult](ctx, a, "asr"); asr != nil {
+			opts.Language = asr.Language
+		}
+	}
+	res, err := run(ctx, inputs, h.cfg.GeminiAPIKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	art := &Artifact{Value: res, Key: extractionKey(a.AdID, "vlm_results.json"), Count: len(res.Frames)}
+	overBudget := slices.ContainsFunc(res.SkippedFrames, func(f streams.SkippedFrame) bool { return f.Reason == streams.SkipOverBudget })
+	switch {
+	case len(res.Frames) == 0 && (res.Incomplete || overBudget):
+		return nil, cutShort(ctx, "any frame was described")
+	case res.Incomplete || overBudget:
+		art.Partial = cutShort(ctx, "every frame was described").Error()
+	}
+	return art, nil
+}
+
+// cutShort explains why a stream stopped before what had happened: the
+// job's token budget ran out, or the job ended.
+func cutShort(ctx context.Context, what string) error {
+	if streams.BudgetExhausted(ctx) && ctx.Err() == nil {
+		return errors.New("token budget ran out before " + what)
+	}
+	return fmt.Errorf("job ended before %s: %w", what, context.Cause(ctx))
+}
+
+// multilingual reports whether VLM prompts follow the ad's detected
+// language, which makes the vlm stream wait for asr.
+func (h *ExtractHandler) multilingual(a *Assets) bool {
+	if a.Request.Multilingual != nil {
+		return *a.Request.Multilingual
+	}
+	return h.cfg.VLMMultilingual
+}
+
+// keyframeInputs lists the ad's keyframes for the streams package. Each
+// image is fetched from R2 into a pooled buffer when it is needed.
+func (h *ExtractHandler) keyframeInputs(ctx context.Context, a *Assets) []streams.KeyframeInput {
+	var inputs []streams.KeyframeInput
+	for _, m := range a.Keyframes(ctx) {
+		key := m.R2Key
+		inputs = append(inputs, streams.KeyframeInput{
+			FrameIndex:   m.Index,
+			TimestampSec: m.TimestampSec,
+			EntropyScore: m.EntropyScore,
+			FetchInto: func(ctx context.Context, buf *bytes.Buffer) error {
+				return a.keyframeImage(ctx, key, buf, h.r2.DownloadObjectTo)
+			},
+		})
+	}
+	return inputs
+}
+
+func (s vlmStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.VLMResult](ctx, s.h, a.AdID, "vlm_results.json")
+}
+
+// Key is "" for a custom prompt, chosen model, multilingual setting or
+// frame cap and in batch mode, none of which the stored descriptions need
+// have been written with, unless the request fills in missing results.
+func (vlmStream) Key(a *Assets) string {
+	r := a.Request
+	if r.Mode != client.ModeFillMissing && (r.VLMPromptTemplate != "" || r.VLMModel != "" || r.Multilingual != nil || r.MaxFrames != nil || a.Batch) {
+		return ""
+	}
+	return extractionKey(a.AdID, "vlm_results.json")
+}
+
+// analysisWanted reports whether ANALYSIS_STREAMS enables the named stream
+// or the request names it.
+func (h *ExtractHandler) analysisWanted(name string, a *Assets) bool {
+	return slices.Contains(h.cfg.AnalysisStreams, name) || slices.Contains(a.Request.Streams, name)
+}
+
+// geminiKeyframes is what the per-frame Gemini analyses need: keyframes and
+// a provider to ask about them.
+func (h *ExtractHandler) geminiKeyframes(ctx context.Context, a *Assets) ([]streams.KeyframeInput, error) {
+	inputs := h.keyframeInputs(ctx, a)
+	switch {
+	case len(inputs) == 0:
+		return nil, Skip("no keyframe images available")
+	case !h.cfg.GeminiConfigured():
+		return nil, Skip("Gemini not configured")
+	}
+	if err := streams.GeminiHealth(); err != nil {
+		return nil, Skip(err.Error())
+	}
+	return inputs, nil
+}
+
+// peopleStream reports the people in each keyframe: how many, how they are
+// framed and the emotion they show.
+type peopleStream struct{ h *ExtractHandler }
+
+func (peopleStream) Name() string            { return "people" }
+func (peopleStream) Requires() []string      { return nil }
+func (s peopleStream) Wanted(a *Assets) bool { return s.h.analysisWanted("people", a) }
+
+func (s peopleStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	inputs, err := s.h.geminiKeyframes(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunPeople(ctx, inputs, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return frameAnalysisArtifact(ctx, res, extractionKey(a.AdID, "people.json"), len(res.Frames), res.Incomplete)
+}
+
+func (s peopleStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.PeopleResult](ctx, s.h, a.AdID, "people.json")
+}
+
+func (peopleStream) Key(a *Assets) string { return extractionKey(a.AdID, "people.json") }
+
+// presenterStream follows the presenter from keyframe to keyframe, telling
+// single-creator ads from montages.
+type presenterStream struct{ h *ExtractHandler }
+
+func (presenterStream) Name() string            { return "presenter" }
+func (presenterStream) Requires() []string      { return nil }
+func (s presenterStream) Wanted(a *Assets) bool { return s.h.analysisWanted("presenter", a) }
+
+func (s presenterStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	inputs, err := s.h.geminiKeyframes(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunPresenter(ctx, inputs, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return frameAnalysisArtifact(ctx, res, extractionKey(a.AdID, "presenter.json"), len(res.Frames), res.Incomplete)
+}
+
+func (s presenterStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.PresenterResult](ctx, s.h, a.AdID, "presenter.json")
+}
+
+func (presenterStream) Key(a *Assets) string { return extractionKey(a.AdID, "presenter.json") }
+
+// visualStatsStream measures each keyframe's colors, brightness and
+// contrast locally, with no provider involved.
+type visualStatsStream struct{ h *ExtractHandler }
+
+func (visualStatsStream) Name() string            { return "visual_stats" }
+func (visualStatsStream) Requires() []string      { return nil }
+func (s visualStatsStream) Wanted(a *Assets) bool { return s.h.analysisWanted("visual_stats", a) }
+
+func (s visualStatsStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	inputs := s.h.keyframeInputs(ctx, a)
+	if len(inputs) == 0 {
+		return nil, Skip("no keyframe images available")
+	}
+	res, err := streams.RunVisualStats(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return frameAnalysisArtifact(ctx, res, extractionKey(a.AdID, "visual_stats.json"), len(res.Frames), res.Incomplete)
+}
+
+func (s visualStatsStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.VisualStatsResult](ctx, s.h, a.AdID, "visual_stats.json")
+}
+
+func (visualStatsStream) Key(a *Assets) string { return extractionKey(a.AdID, "visual_stats.json") }
+
+// contentRatingStream rates each keyframe for brand safety, with Gemini or
+// a moderation API, and decides whether the ad is quarantined.
+type contentRatingStream struct{ h *ExtractHandler }
+
+func (contentRatingStream) Name() string            { return "content_rating" }
+func (contentRatingStream) Requires() []string      { return nil }
+func (s contentRatingStream) Wanted(a *Assets) bool { return s.h.analysisWanted("content_rating", a) }
+
+func (s contentRatingStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	var inputs []streams.KeyframeInput
+	if s.h.cfg.ContentRatingURL != "" {
+		if inputs = s.h.keyframeInputs(ctx, a); len(inputs) == 0 {
+			return nil, Skip("no keyframe images available")
+		}
+	} else {
+		var err error
+		if inputs, err = s.h.geminiKeyframes(ctx, a); err != nil {
+			return nil, err
+		}
+	}
+	res, err := streams.RunContentRating(ctx, inputs, s.h.cfg.GeminiAPIKey, streams.RatingOptions{
+		ModerationURL: s.h.cfg.ContentRatingURL,
+		QuarantineAt:  s.h.cfg.ContentRatingQuarantine,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return frameAnalysisArtifact(ctx, res, extractionKey(a.AdID, "content_rating.json"), len(res.Frames), res.Incomplete)
+}
+
+func (s contentRatingStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.ContentRatingResult](ctx, s.h, a.AdID, "content_rating.json")
+}
+
+func (contentRatingStream) Key(a *Assets) string { return extractionKey(a.AdID, "content_rating.json") }
+
+// productsStream boxes the advertised product in each keyframe.
+type productsStream struct{ h *ExtractHandler }
+
+func (productsStream) Name() string            { return "products" }
+func (productsStream) Requires() []string      { return nil }
+func (s productsStream) Wanted(a *Assets) bool { return s.h.analysisWanted("products", a) }
+
+func (s productsStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	inputs, err := s.h.geminiKeyframes(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunProducts(ctx, inputs, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return frameAnalysisArtifact(ctx, res, extractionKey(a.AdID, "products.json"), len(res.Frames), res.Incomplete)
+}
+
+func (s productsStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.ProductsResult](ctx, s.h, a.AdID, "products.json")
+}
+
+func (productsStream) Key(a *Assets) string { return extractionKey(a.AdID, "products.json") }
+
+// ctaStream finds calls to action in the keyframes' on-screen text, read
+// by Gemini, and in the transcript. It reads the text while ASR runs, and
+// searches whichever of the two it gets.
+type ctaStream struct{ h *ExtractHandler }
+
+func (ctaStream) Name() string            { return "cta" }
+func (ctaStream) Requires() []string      { return nil }
+func (s ctaStream) Wanted(a *Assets) bool { return s.h.analysisWanted("cta", a) }
+
+func (s ctaStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	var text []streams.FrameText
+	var incomplete bool
+	if inputs, err := s.h.geminiKeyframes(ctx, a); err == nil {
+		text, incomplete = streams.ReadOnScreenText(ctx, inputs, s.h.cfg.GeminiAPIKey)
+	}
+	asr := output[*streams.ASRResult](ctx, a, "asr")
+	if len(text) == 0 && asr == nil {
+		if incomplete {
+			return nil, cutShort(ctx, "any frame was read")
+		}
+		return nil, Skip("no on-screen text or transcript")
+	}
+
+	res := streams.DetectCTAs(text, asr)
+	res.Incomplete = incomplete
+	art := &Artifact{Value: res, Key: extractionKey(a.AdID, "cta_results.json"), Count: len(res.CTAs)}
+	if incomplete {
+		art.Partial = cutShort(ctx, "every frame was read").Error()
+	}
+	return art, nil
+}
+
+func (s ctaStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.CTAResult](ctx, s.h, a.AdID, "cta_results.json")
+}
+
+func (ctaStream) Key(a *Assets) string { return extractionKey(a.AdID, "cta_results.json") }
+
+// musicStream classifies the soundtrack's backing music, sending Gemini the
+// start of the audio track. A video without sound skips the stream.
+type musicStream struct{ h *ExtractHandler }
+
+func (musicStream) Name() string            { return "music" }
+func (musicStream) Requires() []string      { return nil }
+func (s musicStream) Wanted(a *Assets) bool { return s.h.analysisWanted("music", a) }
+
+func (s musicStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	switch {
+	case !streams.FFmpegAvailable():
+		return nil, Skip("ffmpeg not installed")
+	case !s.h.cfg.GeminiConfigured():
+		return nil, Skip("Gemini not configured")
+	}
+	if err := streams.GeminiHealth(); err != nil {
+		return nil, Skip(err.Error())
+	}
+	url, err := s.h.videoURL(ctx, a.AdID)
+	if err != nil {
+		return nil, err
+	}
+	clip, err := streams.ExtractAudioClip(ctx, url)
+	if errors.Is(err, streams.ErrNoAudio) {
+		return nil, Skip(err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	music, err := streams.RunMusic(ctx, clip, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: music, Key: extractionKey(a.AdID, "music.json"), Count: 1}, nil
+}
+
+func (s musicStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.MusicResult](ctx, s.h, a.AdID, "music.json")
+}
+
+func (musicStream) Key(a *Assets) string { return extractionKey(a.AdID, "music.json") }
+
+// hookAnalysisStream assesses the ad's opening seconds, its keyframes and
+// what is said over them, once the transcript is in.
+type hookAnalysisStream struct{ h *ExtractHandler }
+
+func (hookAnalysisStream) Name() string            { return "hook_analysis" }
+func (hookAnalysisStream) Requires() []string      { return []string{"asr"} }
+func (s hookAnalysisStream) Wanted(a *Assets) bool { return s.h.analysisWanted("hook_analysis", a) }
+
+func (s hookAnalysisStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	inputs, err := s.h.geminiKeyframes(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := streams.RunHook(ctx, inputs, output[*streams.ASRResult](ctx, a, "asr"), s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: hook, Key: extractionKey(a.AdID, "hook_analysis.json"), Count: 1}, nil
+}
+
+func (s hookAnalysisStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.HookResult](ctx, s.h, a.AdID, "hook_analysis.json")
+}
+
+func (hookAnalysisStream) Key(a *Assets) string { return extractionKey(a.AdID, "hook_analysis.json") }
+
+// entitiesStream extracts brands, products, prices, discount codes and URLs
+// from the transcript.
+type entitiesStream struct{ h *ExtractHandler }
+
+func (entitiesStream) Name() string            { return "entities" }
+func (entitiesStream) Requires() []string      { return []string{"asr"} }
+func (s entitiesStream) Wanted(a *Assets) bool { return s.h.analysisWanted("entities", a) }
+
+func (s entitiesStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	asr := output[*streams.ASRResult](ctx, a, "asr")
+	switch {
+	case asr == nil || len(asr.Segments) == 0:
+		return nil, Skip("no transcript")
+	case !s.h.cfg.GeminiConfigured():
+		return nil, Skip("Gemini not configured")
+	}
+	if err := streams.GeminiHealth(); err != nil {
+		return nil, Skip(err.Error())
+	}
+	res, err := streams.RunEntities(ctx, asr, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: res, Key: extractionKey(a.AdID, "entities.json"), Count: len(res.Entities)}, nil
+}
+
+func (s entitiesStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.EntitiesResult](ctx, s.h, a.AdID, "entities.json")
+}
+
+func (entitiesStream) Key(a *Assets) string { return extractionKey(a.AdID, "entities.json") }
+
+// frameAnalysisArtifact wraps a per-frame analysis, failing it if the job
+// ended or ran out of tokens before any frame was analyzed and marking it
+// partial if that happened before all were.
+func frameAnalysisArtifact(ctx context.Context, value any, key string, frames int, incomplete bool) (*Artifact, error) {
+	art := &Artifact{Value: value, Key: key, Count: frames}
+	if incomplete {
+		if frames == 0 {
+			return nil, cutShort(ctx, "any frame was analyzed")
+		}
+		art.Partial = cutShort(ctx, "every frame was analyzed").Error()
+	}
+	return art, nil
+}
+
+// videoMetaStream reads container metadata with ffprobe, which fetches only
+// the header.
+type videoMetaStream struct{ h *ExtractHandler }
+
+func (videoMetaStream) Name() string            { return "video_meta" }
+func (videoMetaStream) Requires() []string      { return nil }
+func (s videoMetaStream) Wanted(a *Assets) bool { return s.h.analysisWanted("video_meta", a) }
+
+func (s videoMetaStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	if !streams.ProbeAvailable() {
+		return nil, Skip("ffprobe not installed")
+	}
+	url, err := s.h.videoURL(ctx, a.AdID)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := streams.RunProbe(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: meta, Key: extractionKey(a.AdID, "video_meta.json"), Count: 1}, nil
+}
+
+func (s videoMetaStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.VideoMeta](ctx, s.h, a.AdID, "video_meta.json")
+}
+
+func (videoMetaStream) Key(a *Assets) string { return extractionKey(a.AdID, "video_meta.json") }
+
+// audioStream decodes the soundtrack with ffmpeg alongside ASR, then splits
+// it into speech and music once the transcript is known. A video without
+// sound skips the stream.
+type audioStream struct{ h *ExtractHandler }
+
+func (audioStream) Name() string            { return "audio_analysis" }
+func (audioStream) Requires() []string      { return nil }
+func (s audioStream) Wanted(a *Assets) bool { return s.h.analysisWanted("audio_analysis", a) }
+
+func (s audioStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	if !streams.FFmpegAvailable() {
+		return nil, Skip("ffmpeg not installed")
+	}
+	url, err := s.h.videoURL(ctx, a.AdID)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := streams.AnalyzeAudio(ctx, url)
+	if errors.Is(err, streams.ErrNoAudio) {
+		return nil, Skip(err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	audio.AddSpeech(output[*streams.ASRResult](ctx, a, "asr"))
+	return &Artifact{Value: audio, Key: extractionKey(a.AdID, "audio_analysis.json"), Count: 1}, nil
+}
+
+func (s audioStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.AudioAnalysis](ctx, s.h, a.AdID, "audio_analysis.json")
+}
+
+func (audioStream) Key(a *Assets) string { return extractionKey(a.AdID, "audio_analysis.json") }
+
+// timelineStream merges the transcript and frame descriptions. It runs even
+// after the job's deadline, so that partial results are still merged.
+type timelineStream struct{ h *ExtractHandler }
+
+func (timelineStream) Name() string            { return "timeline" }
+func (timelineStream) Requires() []string      { return []string{"asr", "vlm"} }
+func (timelineStream) Stage() string           { return "post_processing" }
+func (s timelineStream) Wanted(a *Assets) bool { return s.h.analysisWanted("timeline", a) }
+
+func (timelineStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	asr := output[*streams.ASRResult](ctx, a, "asr")
+	vlm := output[*streams.VLMResult](ctx, a, "vlm")
+	if asr == nil && vlm == nil {
+		return nil, Skip("no transcript or frame descriptions")
+	}
+	timeline := streams.BuildTimeline(asr, vlm)
+	return &Artifact{Value: timeline, Key: extractionKey(a.AdID, "timeline.json"), Count: len(timeline.Entries)}, nil
+}
+
+func (s timelineStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.Timeline](ctx, s.h, a.AdID, "timeline.json")
+}
+
+func (timelineStream) Key(a *Assets) string { return extractionKey(a.AdID, "timeline.json") }
+
+// geminiTimeline is what the Gemini post-processing streams need: a timeline
+// and time and quota to spend on it.
+func (h *ExtractHandler) geminiTimeline(ctx context.Context, a *Assets) (*streams.Timeline, error) {
+	timeline := output[*streams.Timeline](ctx, a, "timeline")
+	switch {
+	case timeline == nil:
+		return nil, Skip("no timeline")
+	case ctx.Err() != nil:
+		return nil, Skip(fmt.Sprintf("job ended: %v", context.Cause(ctx)))
+	case !h.cfg.GeminiConfigured():
+		return nil, Skip("Gemini not configured")
+	}
+	if err := streams.GeminiHealth(); err != nil {
+		return nil, Skip(err.Error())
+	}
+	return timeline, nil
+}
+
+type keyMomentsStream struct{ h *ExtractHandler }
+
+func (keyMomentsStream) Name() string            { return "key_moments" }
+func (keyMomentsStream) Requires() []string      { return []string{"timeline"} }
+func (keyMomentsStream) Stage() string           { return "post_processing" }
+func (s keyMomentsStream) Wanted(a *Assets) bool { return s.h.analysisWanted("key_moments", a) }
+
+func (s keyMomentsStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	timeline, err := s.h.geminiTimeline(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunKeyMoments(ctx, timeline, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: res, Key: extractionKey(a.AdID, "key_moments.json"), Count: len(res.Moments)}, nil
+}
+
+func (s keyMomentsStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.KeyMomentsResult](ctx, s.h, a.AdID, "key_moments.json")
+}
+
+func (keyMomentsStream) Key(a *Assets) string { return extractionKey(a.AdID, "key_moments.json") }
+
+type summaryStream struct{ h *ExtractHandler }
+
+func (summaryStream) Name() string            { return "summary" }
+func (summaryStream) Requires() []string      { return []string{"timeline"} }
+func (summaryStream) Stage() string           { return "post_processing" }
+func (s summaryStream) Wanted(a *Assets) bool { return s.h.analysisWanted("summary", a) }
+
+func (s summaryStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	timeline, err := s.h.geminiTimeline(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	res, err := streams.RunSummaries(ctx, timeline, s.h.cfg.GeminiAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{Value: res, Key: extractionKey(a.AdID, "summary.json"), Count: 3}, nil
+}
+
+func (s summaryStream) Load(ctx context.Context, a *Assets) (any, error) {
+	return loadJSON[*streams.SummaryResult](ctx, s.h, a.AdID, "summary.json")
+}
+
+func (summaryStream) Key(a *Assets) string { return extractionKey(a.AdID, "summary.json") }
+
+// hookStream runs a post-processing hook over every other stream's output
+// and stores what it returns. Like the bundle it runs after the job's
+// deadline; a remote hook is bounded by TRANSFORM_TIMEOUT instead.
+type hookStream struct {
+	h    *ExtractHandler
+	name string
+	hook hooks.Hook
+}
+
+func (s hookStream) Name() string     { return s.name }
+func (hookStream) Requires() []string { return []string{AllStreams} }
+func (hookStream) Stage() string      { return "post_processing" }
+
+func (s hookStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	out, err := s.hook(context.WithoutCancel(ctx), a.AdID, a.outputs())
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, Skip("nothing to transform")
+	}
+	ctx, cancel := persistContext(ctx)
+	defer cancel()
+	key := extractionKey(a.AdID, out.File)
+	if err := s.h.r2.UploadObject(ctx, key, out.Data, out.ContentType); err != nil {
+		return nil, err
+	}
+	return &Artifact{Key: key, Stored: true, Count: 1}, nil
+}
+
+// bundleStream zips every artifact and keyframe once all other streams are
+// done. The zip is built in memory, so it reserves room for every keyframe
+// on top of the job's own reservation.
+type bundleStream struct{ h *ExtractHandler }
+
+func (bundleStream) Name() string       { return "bundle" }
+func (bundleStream) Requires() []string { return []string{AllStreams} }
+func (bundleStream) Stage() string      { return "bundling" }
+
+func (s bundleStream) Wanted(a *Assets) bool {
+	if a.Request.Bundle != nil {
+		return *a.Request.Bundle
+	}
+	return s.h.cfg.BundleArtifacts
+}
+
+func (bundleStream) Key(a *Assets) string { return bundle.Key(a.AdID) }
+
+func (s bundleStream) Run(ctx context.Context, a *Assets) (*Artifact, error) {
+	h := s.h
+	ctx, cancel := persistContext(ctx)
+	defer cancel()
+
+	keyframes := len(a.Keyframes(ctx))
+	release, err := h.memory.Reserve(ctx, int64(keyframes)*int64(h.cfg.AdmissionFrameKB)<<10+jobBaseMemory)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	data, entries, err := bundle.Build(ctx, h.r2, a.AdID)
+	if err != nil {
+		return nil, err
+	}
+	key := bundle.Key(a.AdID)
+	if err := h.r2.UploadObject(ctx, key, data, "application/zip"); err != nil {
+		return nil, err
+	}
+	return &Artifact{Key: key, Stored: true, Count: len(entries)}, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/s3fake"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+func TestCacheKeys(t *testing.T) {
+	yes, two := true, 2
+	for _, tc := range []struct {
+		name   string
+		stream Cacheable
+		a      *Assets
+		cached bool
+	}{
+		{"asr", asrStream{}, &Assets{}, true},
+		{"asr language", asrStream{}, &Assets{Request: ExtractRequest{Language: "de"}}, false},
+		{"asr deepgram_params", asrStream{}, &Assets{Request: ExtractRequest{DeepgramParams: map[string]string{"diarize": "true"}}}, false},
+		{"asr deepgram_params, fill-missing", asrStream{}, &Assets{Request: ExtractRequest{DeepgramParams: map[string]string{"diarize": "true"}, Mode: client.ModeFillMissing}}, true},
+		{"vlm", vlmStream{}, &Assets{}, true},
+		{"vlm multilingual", vlmStream{}, &Assets{Request: ExtractRequest{Multilingual: &yes}}, false},
+		{"vlm max_frames", vlmStream{}, &Assets{Request: ExtractRequest{MaxFrames: &two}}, false},
+		{"vlm batch", vlmStream{}, &Assets{Batch: true}, false},
+	} {
+		tc.a.AdID = "ad1"
+		if got := tc.stream.Key(tc.a) != ""; got != tc.cached {
+			t.Errorf("%s: cached = %v, want %v", tc.name, got, tc.cached)
+		}
+	}
+}
+
+// TestCachedRejectsPartial checks that VLM results cut short are run again
+// while the complete transcript is reused.
+func TestCachedRejectsPartial(t *testing.T) {
+	store := s3fake.New()
+	seedTestAd(t, store, "ad1", 3)
+	h := newTestHandler(t, store, nil)
+	ctx := context.Background()
+	if _, err := h.Extract(ctx, ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cut := range map[string]func(*streams.VLMResult){
+		"incomplete": func(r *streams.VLMResult) { r.Incomplete = true },
+		"over budget": func(r *streams.VLMResult) {
+			r.SkippedFrames = []streams.SkippedFrame{{FrameIndex: 2, Reason: streams.SkipOverBudget}}
+		},
+	} {
+		data, _ := store.Get(testBucket, "ads/ad1/extraction/vlm_results.json")
+		var vlm streams.VLMResult
+		if err := json.Unmarshal(data, &vlm); err != nil {
+			t.Fatal(err)
+		}
+		cut(&vlm)
+		data, _ = json.Marshal(vlm)
+		store.Put(testBucket, "ads/ad1/extraction/vlm_results.json", data, "application/json")
+
+		resp, err := h.Extract(ctx, ExtractRequest{AdID: "ad1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := map[string]string{}
+		for _, sr := range resp.Streams {
+			status[sr.Stream] = sr.Status
+		}
+		if status["asr"] != "cached" || status["vlm"] != "success" {
+			t.Errorf("%s: statuses %v", name, status)
+		}
+	}
+}
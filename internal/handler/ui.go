@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/apierr"
+	"github.com/nikipaj1/video-description-pipeline/internal/config"
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UIAssets serves the built-in web UI's static files. Mount it at /ui/.
+func UIAssets() http.Handler {
+	sub, _ := fs.Sub(uiFiles, "ui")
+	return http.StripPrefix("/ui/", http.FileServerFS(sub))
+}
+
+// AdView is everything the UI shows for one ad. Results that have not been
+// written yet are nil.
+type AdView struct {
+	AdID       string                    `json:"ad_id"`
+	ASR        *streams.ASRResult        `json:"asr"`
+	VLM        *streams.VLMResult        `json:"vlm"`
+	KeyMoments *streams.KeyMomentsResult `json:"key_moments"`
+	Summary    *streams.SummaryResult    `json:"summary"`
+	Thumbnails map[int]string            `json:"thumbnails"` // frame index -> presigned keyframe URL
+}
+
+// AdViewHandler serves GET /ui/api/ads/{ad_id}: an ad's stored results and
+// links to its keyframes, read from R2 so the browser needs no R2
+// credentials.
+type AdViewHandler struct {
+	cfg *config.Config
+	r2  *r2.Client
+}
+
+func NewAdViewHandler(cfg *config.Config, r2Client *r2.Client) *AdViewHandler {
+	return &AdViewHandler{cfg: cfg, r2: r2Client}
+}
+
+func (h *AdViewHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	adID := req.PathValue("ad_id")
+	if adID == "" {
+		apierr.Write(w, "ad_id is required", http.StatusBadRequest)
+		return
+	}
+	ctx := req.Context()
+	view := AdView{AdID: adID, Thumbnails: map[int]string{}}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	load := func(name string, v any) bool {
+		err := h.r2.DownloadJSON(ctx, "ads/"+adID+"/extraction/"+name, v)
+		if err != nil && !errors.Is(err, r2.ErrNotFound) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}
+		return err == nil
+	}
+	for _, fn := range []func(){
+		func() {
+			var v streams.ASRResult
+			if load("asr_results.json", &v) {
+				view.ASR = &v
+			}
+		},
+		func() {
+			var v streams.VLMResult
+			if load("vlm_results.json", &v) {
+				view.VLM = &v
+			}
+		},
+		func() {
+			var v streams.KeyMomentsResult
+			if load("key_moments.json", &v) {
+				view.KeyMoments = &v
+			}
+		},
+		func() {
+			var v streams.SummaryResult
+			if load("summary.json", &v) {
+				view.Summary = &v
+			}
+		},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.addThumbnails(ctx, &view)
+	}()
+	wg.Wait()
+
+	if len(errs) > 0 {
+		apierr.Write(w, errors.Join(errs...).Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// addThumbnails links each keyframe listed in the ad's metadata. Thumbnails
+// are decoration, so failures are only logged.
+func (h *AdViewHandler) addThumbnails(ctx context.Context, view *AdView) {
+	metas, err := h.r2.DownloadKeyframeMetadata(ctx, view.AdID)
+	if err != nil {
+		if !errors.Is(err, r2.ErrNotFound) {
+			log.Printf("WARN: ui %s: %v", view.AdID, err)
+		}
+		return
+	}
+	for _, m := range metas {
+		url, err := h.r2.PresignGet(ctx, m.R2Key, h.cfg.WebhookURLTTL)
+		if err != nil {
+			log.Printf("WARN: ui %s: %v", view.AdID, err)
+			return
+		}
+		view.Thumbnails[m.Index] = url
+	}
+}
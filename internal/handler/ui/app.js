@@ -0,0 +1,163 @@
+"use strict";
+
+const $ = (sel) => document.querySelector(sel);
+
+function el(tag, attrs = {}, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) e.setAttribute(k, v);
+  e.append(...children);
+  return e;
+}
+
+function time(sec) {
+  const s = Math.max(0, sec);
+  return `${Math.floor(s / 60)}:${(s % 60).toFixed(1).padStart(4, "0")}`;
+}
+
+// Error responses carry {code, message, details}; proxies may send text.
+async function errorMessage(resp) {
+  const text = await resp.text();
+  try {
+    return JSON.parse(text).message ?? text;
+  } catch {
+    return text;
+  }
+}
+
+// authHeaders carries the API key, if one is entered, for servers that
+// require a credential. It is kept for the browser session.
+function authHeaders() {
+  const key = $("#api-key").value.trim();
+  if (key) {
+    sessionStorage.setItem("apiKey", key);
+    return { Authorization: `Bearer ${key}` };
+  }
+  sessionStorage.removeItem("apiKey");
+  return {};
+}
+
+function showStage(stage, elapsedMs) {
+  let past = true;
+  for (const li of document.querySelectorAll("#stages li")) {
+    const current = li.dataset.stage === stage;
+    if (current) past = false;
+    li.className = current ? "current" : past ? "past" : "";
+  }
+  if (elapsedMs !== undefined) $("#elapsed").textContent = `${(elapsedMs / 1000).toFixed(0)}s elapsed`;
+}
+
+function showStreams(resp) {
+  const body = $("#streams tbody");
+  body.replaceChildren(...resp.streams.map((s) =>
+    el("tr", {},
+      el("td", {}, s.stream),
+      el("td", { class: `status-${s.status}` }, s.status),
+      el("td", {}, String(s.result_count)),
+      el("td", {}, s.error || ""))));
+  $("#streams").hidden = false;
+}
+
+function showError(msg) {
+  $("#error").textContent = msg;
+  $("#error").hidden = false;
+}
+
+// extract runs a job, reading the JSON-lines progress stream as it arrives.
+async function extract(adID, priority) {
+  $("#progress").hidden = false;
+  $("#streams").hidden = true;
+  $("#error").hidden = true;
+  showStage("queued", 0);
+
+  const resp = await fetch("/v1/extract", {
+    method: "POST",
+    headers: { "Content-Type": "application/json", Accept: "application/x-ndjson", ...authHeaders() },
+    body: JSON.stringify({ ad_id: adID, priority }),
+  });
+  if (!resp.ok) {
+    showError(`${resp.status}: ${await errorMessage(resp)}`);
+    return;
+  }
+
+  const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) break;
+    buf += value;
+    let nl;
+    while ((nl = buf.indexOf("\n")) >= 0) {
+      const line = buf.slice(0, nl).trim();
+      buf = buf.slice(nl + 1);
+      if (!line) continue;
+      const ev = JSON.parse(line);
+      if (ev.event === "progress") {
+        showStage(ev.stage, ev.elapsed_ms);
+      } else if (ev.event === "result") {
+        showStage("done", ev.elapsed_ms);
+        showStreams(ev.result);
+        await loadResults(adID);
+      } else if (ev.event === "error") {
+        showError(`${ev.status}: ${ev.error}`);
+      }
+    }
+  }
+}
+
+async function loadResults(adID) {
+  const resp = await fetch(`/v1/ui/api/ads/${encodeURIComponent(adID)}`, { headers: authHeaders() });
+  if (!resp.ok) {
+    showError(`${resp.status}: ${await errorMessage(resp)}`);
+    return;
+  }
+  const view = await resp.json();
+  $("#results").hidden = false;
+
+  const summary = $("#summary");
+  summary.replaceChildren();
+  if (view.summary) {
+    summary.append(el("h2", {}, "Summary"),
+      el("p", {}, view.summary.combined),
+      el("p", {}, el("strong", {}, "Sound off: "), view.summary.sound_off),
+      el("p", {}, el("strong", {}, "Eyes closed: "), view.summary.eyes_closed));
+  }
+
+  const moments = $("#moments");
+  moments.replaceChildren();
+  if (view.key_moments && view.key_moments.moments.length) {
+    moments.append(el("h2", {}, "Key moments"),
+      ...view.key_moments.moments.map((m) =>
+        el("span", { title: m.description }, `${m.type} ${time(m.start)}–${time(m.end)}`)));
+  }
+
+  const frames = view.vlm ? view.vlm.frames : [];
+  $("#frames").replaceChildren(...(frames.length ? frames.map((f) => {
+    const thumb = view.thumbnails[f.frame_index];
+    return el("li", { class: f.blocked ? "blocked" : "" },
+      thumb ? el("img", { src: thumb, alt: `Frame ${f.frame_index}`, loading: "lazy" }) : el("div"),
+      el("div", {}, el("span", { class: "time" }, time(f.timestamp_sec)), el("p", {}, f.description)));
+  }) : [el("li", {}, "No frame descriptions yet.")]));
+
+  const segments = view.asr ? view.asr.segments : [];
+  $("#transcript").replaceChildren(...(segments.length ? segments.map((s) =>
+    el("li", {}, el("span", { class: "time" }, time(s.start)), s.text)) : [el("li", {}, "No transcript yet.")]));
+}
+
+$("#extract").addEventListener("submit", (e) => {
+  e.preventDefault();
+  extract($("#ad-id").value.trim(), $("#priority").value).catch((err) => showError(err.message));
+});
+
+$("#load").addEventListener("click", () => {
+  const adID = $("#ad-id").value.trim();
+  if (adID) loadResults(adID).catch((err) => showError(err.message));
+});
+
+$("#api-key").value = sessionStorage.getItem("apiKey") ?? "";
+
+// Links such as /ui/?ad_id=123, e.g. from notifications, open that ad
+const linked = new URLSearchParams(location.search).get("ad_id");
+if (linked) {
+  $("#ad-id").value = linked;
+  loadResults(linked).catch((err) => showError(err.message));
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// APIVersion prefixes the paths of the API's endpoints. Probes, metrics and
+// the UI keep fixed paths outside it.
+const APIVersion = "/v1"
+
+// HandleVersioned registers h on mux for pattern under APIVersion, e.g. at
+// "POST /v1/extract" for "POST /extract", and at pattern itself for callers
+// from before versioning, whose responses carry a Deprecation header and a
+// Link to the versioned path. The unversioned paths are served until the
+// next API version.
+func HandleVersioned(mux *http.ServeMux, pattern string, h http.Handler) {
+	mux.Handle(versioned(pattern), h)
+
+	var logged sync.Once
+	mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		logged.Do(func() {
+			log.Printf("WARN: %s called without the %s prefix; unversioned paths are deprecated", pattern, APIVersion)
+		})
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+APIVersion+req.URL.EscapedPath()+`>; rel="successor-version"`)
+		h.ServeHTTP(w, req)
+	}))
+}
+
+// versioned is pattern under APIVersion.
+func versioned(pattern string) string {
+	method, path, _ := strings.Cut(pattern, " ")
+	return method + " " + APIVersion + path
+}
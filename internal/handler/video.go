@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/ingest"
+)
+
+// videoURLTTL bounds how long ffmpeg and ffprobe may take to read a video
+// through its presigned URL.
+const videoURLTTL = time.Hour
+
+// videoURL lets ffmpeg tools read an ad's video straight from R2, fetching
+// only the byte ranges they need.
+func (h *ExtractHandler) videoURL(ctx context.Context, adID string) (string, error) {
+	return h.r2.PresignGet(ctx, videoKey(adID), videoURLTTL)
+}
+
+func videoKey(adID string) string {
+	return fmt.Sprintf("ads/%s/video.mp4", adID)
+}
+
+// ingestVideo stores the video at the request's video_url as the ad's
+// video, where every stream reads it from. An ad that already has a video
+// keeps it unless the job is forced, so retried requests do not download
+// it again. The video is spooled through a temporary file rather than
+// memory. Videos that are not allowed, not videos or too large fail the
+// job with 422, unreachable ones with 502.
+func (h *ExtractHandler) ingestVideo(ctx context.Context, body ExtractRequest) error {
+	if body.VideoURL == "" {
+		return nil
+	}
+	key := videoKey(body.AdID)
+	if !body.Force {
+		ok, err := h.r2.Exists(ctx, key)
+		if err != nil {
+			return &JobError{Status: http.StatusInternalServerError, Err: err}
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	t0 := time.Now()
+	f, err := os.CreateTemp("", "video-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	fetchCtx, cancel := context.WithTimeout(ctx, h.cfg.VideoURLTimeout)
+	contentType, n, err := ingest.Fetch(fetchCtx, body.VideoURL, f, ingest.Options{
+		MaxBytes:     int64(h.cfg.MaxUploadMB) << 20,
+		AllowedHosts: h.cfg.VideoURLAllowedHosts,
+	})
+	cancel()
+	if errors.Is(err, ingest.ErrRejected) {
+		return &JobError{Status: http.StatusUnprocessableEntity, Err: fmt.Errorf("video_url: %w", err)}
+	}
+	if err != nil {
+		return &JobError{Status: http.StatusBadGateway, Err: fmt.Errorf("video_url: %w", err)}
+	}
+	if err := h.r2.UploadFrom(ctx, key, f, contentType); err != nil {
+		return &JobError{Status: http.StatusInternalServerError, Err: err}
+	}
+	if err := recordObjects(ctx, h.r2, body.AdID, key); err != nil {
+		log.Printf("WARN: %s: record video for purges: %v", body.AdID, err)
+	}
+	log.Printf("%s: stored %d-byte video from video_url in %s", body.AdID, n, time.Since(t0).Round(time.Millisecond))
+	return nil
+}
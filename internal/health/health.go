@@ -0,0 +1,92 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// numBuckets is how many slices the window is divided into. Old outcomes
+// expire one slice at a time.
+const numBuckets = 10
+
+type bucket struct {
+	start      time.Time
+	ok, failed int
+}
+
+// Tracker records call outcomes for one provider over a sliding window and
+// reports the provider degraded while the error rate in that window is at or
+// above a threshold. Callers skip a degraded provider, so recovery comes from
+// failures ageing out of the window; the calls made after that decide whether
+// it stays healthy.
+type Tracker struct {
+	mu         sync.Mutex
+	window     time.Duration
+	threshold  float64
+	minSamples int
+	buckets    [numBuckets]bucket
+	now        func() time.Time
+}
+
+// New returns a tracker that degrades once at least minSamples calls in the
+// last window failed at a rate of threshold or more (0 < threshold <= 1).
+func New(window time.Duration, threshold float64, minSamples int) *Tracker {
+	return &Tracker{
+		window:     window,
+		threshold:  threshold,
+		minSamples: max(minSamples, 1),
+		now:        time.Now,
+	}
+}
+
+// Record adds the outcome of one call.
+func (t *Tracker) Record(healthy bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	width := t.window / numBuckets
+	start := t.now().Truncate(width)
+	b := &t.buckets[(start.UnixNano()/int64(width))%numBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	if healthy {
+		b.ok++
+	} else {
+		b.failed++
+	}
+}
+
+// ErrorRate returns the failure ratio over the window and the number of calls
+// it is based on.
+func (t *Tracker) ErrorRate() (rate float64, samples int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := t.now().Add(-t.window)
+	var ok, failed int
+	for _, b := range t.buckets {
+		if b.start.After(cutoff) {
+			ok += b.ok
+			failed += b.failed
+		}
+	}
+	if ok+failed == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(ok+failed), ok + failed
+}
+
+// Check returns an error describing the degradation, or nil while the
+// provider is healthy. A nil Tracker is always healthy.
+func (t *Tracker) Check() error {
+	if t == nil {
+		return nil
+	}
+	rate, samples := t.ErrorRate()
+	if samples < t.minSamples || rate < t.threshold {
+		return nil
+	}
+	return fmt.Errorf("%.0f%% of %d calls failed in the last %s", rate*100, samples, t.window)
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := New(time.Minute, 0.5, 4)
+	tr.now = func() time.Time { return now }
+	return tr, &now
+}
+
+func TestTracker_DegradesOnErrorRate(t *testing.T) {
+	tr, _ := newTestTracker()
+
+	tr.Record(true)
+	tr.Record(false)
+	tr.Record(false)
+	if err := tr.Check(); err != nil {
+		t.Fatalf("degraded below minSamples: %v", err)
+	}
+
+	tr.Record(true)
+	err := tr.Check()
+	if err == nil {
+		t.Fatal("expected degraded at 50% errors")
+	}
+	if err.Error() != "50% of 4 calls failed in the last 1m0s" {
+		t.Errorf("err = %q", err)
+	}
+}
+
+func TestTracker_RecoversAsFailuresAge(t *testing.T) {
+	tr, now := newTestTracker()
+
+	for range 5 {
+		tr.Record(false)
+	}
+	if tr.Check() == nil {
+		t.Fatal("expected degraded")
+	}
+
+	*now = now.Add(30 * time.Second)
+	for range 4 {
+		tr.Record(true)
+	}
+	if tr.Check() == nil {
+		t.Fatal("old failures should still count within the window")
+	}
+
+	*now = now.Add(31 * time.Second)
+	if err := tr.Check(); err != nil {
+		t.Errorf("expected recovery once failures left the window: %v", err)
+	}
+	if rate, samples := tr.ErrorRate(); rate != 0 || samples != 4 {
+		t.Errorf("rate = %v over %d samples", rate, samples)
+	}
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tr *Tracker
+	if err := tr.Check(); err != nil {
+		t.Errorf("nil tracker should be healthy: %v", err)
+	}
+}
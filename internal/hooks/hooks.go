@@ -0,0 +1,184 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+// Results are the outputs of a job's finished streams, by stream name.
+type Results map[string]any
+
+// Output is a file a hook produced, stored as ads/{id}/extraction/{File}.
+type Output struct {
+	File        string
+	ContentType string
+	Data        []byte
+}
+
+// Hook turns a job's results into one more artifact. It returns nil if the
+// results hold nothing to work on.
+type Hook func(ctx context.Context, adID string, results Results) (*Output, error)
+
+var builtins = map[string]Hook{
+	"merged": merged,
+	"srt":    subtitles(srtCue, "", "transcript.srt", "application/x-subrip"),
+	"vtt":    subtitles(vttCue, "WEBVTT\n\n", "transcript.vtt", "text/vtt"),
+	"csv":    timelineCSV,
+}
+
+// Builtin returns the built-in hook called name: "merged" (every result in
+// one results.json), "srt" or "vtt" (transcript subtitles) or "csv" (the
+// timeline as a spreadsheet).
+func Builtin(name string) (Hook, bool) {
+	h, ok := builtins[name]
+	return h, ok
+}
+
+func merged(_ context.Context, adID string, results Results) (*Output, error) {
+	data, err := json.MarshalIndent(map[string]any{"ad_id": adID, "streams": results}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal results: %w", err)
+	}
+	return &Output{File: "results.json", ContentType: "application/json", Data: data}, nil
+}
+
+func subtitles(cue func(i int, start, end float64, text string) string, header, file, contentType string) Hook {
+	return func(_ context.Context, _ string, results Results) (*Output, error) {
+		asr, _ := results["asr"].(*streams.ASRResult)
+		if asr == nil || len(asr.Segments) == 0 {
+			return nil, nil
+		}
+		var b strings.Builder
+		b.WriteString(header)
+		for i, seg := range asr.Segments {
+			b.WriteString(cue(i+1, seg.Start, seg.End, seg.Text))
+		}
+		return &Output{File: file, ContentType: contentType, Data: []byte(b.String())}, nil
+	}
+}
+
+func srtCue(i int, start, end float64, text string) string {
+	return fmt.Sprintf("%d\n%s --> %s\n%s\n\n", i, clock(start, ","), clock(end, ","), text)
+}
+
+func vttCue(_ int, start, end float64, text string) string {
+	return fmt.Sprintf("%s --> %s\n%s\n\n", clock(start, "."), clock(end, "."), text)
+}
+
+// clock formats seconds as HH:MM:SS followed by sep and milliseconds.
+func clock(sec float64, sep string) string {
+	ms := int64(sec*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+func timelineCSV(_ context.Context, _ string, results Results) (*Output, error) {
+	tl, _ := results["timeline"].(*streams.Timeline)
+	if tl == nil || len(tl.Entries) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"start", "end", "source", "frame_index", "text"})
+	for _, e := range tl.Entries {
+		frame := ""
+		if e.FrameIndex != nil {
+			frame = strconv.Itoa(*e.FrameIndex)
+		}
+		w.Write([]string{
+			strconv.FormatFloat(e.Start, 'f', 3, 64),
+			strconv.FormatFloat(e.End, 'f', 3, 64),
+			e.Source, frame, e.Text,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("write csv: %w", err)
+	}
+	return &Output{File: "timeline.csv", ContentType: "text/csv", Data: buf.Bytes()}, nil
+}
+
+// maxTransformBytes bounds what an external transform can return.
+const maxTransformBytes = 64 << 20
+
+// Remote returns a hook that POSTs {"ad_id", "streams"} as JSON to url and
+// stores the response body as transform.{ext}, the extension following the
+// response's Content-Type. A 204 stores nothing. Network errors, 429s and
+// 5xx responses are retried.
+func Remote(url string, timeout time.Duration) Hook {
+	client := &http.Client{Timeout: timeout}
+	return func(ctx context.Context, adID string, results Results) (*Output, error) {
+		body, err := json.Marshal(map[string]any{"ad_id": adID, "streams": results})
+		if err != nil {
+			return nil, fmt.Errorf("marshal results: %w", err)
+		}
+		var out *Output
+		err = retry.Do(ctx, retry.Default, func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(io.LimitReader(resp.Body, maxTransformBytes+1))
+			if err != nil {
+				return err
+			}
+			switch {
+			case resp.StatusCode >= 300:
+				return retry.NewHTTPError("transform", resp, data)
+			case len(data) > maxTransformBytes:
+				return fmt.Errorf("transform response exceeds %d bytes", maxTransformBytes)
+			case resp.StatusCode == http.StatusNoContent:
+				return nil
+			}
+			contentType := resp.Header.Get("Content-Type")
+			if contentType == "" {
+				contentType = http.DetectContentType(data)
+			}
+			out = &Output{File: "transform" + extension(contentType), ContentType: contentType, Data: data}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("transform hook: %w", err)
+		}
+		return out, nil
+	}
+}
+
+// extension picks a file extension for a content type, preferring the
+// common ones mime lists after rarer aliases.
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json":
+		return ".json"
+	case "text/plain":
+		return ".txt"
+	case "text/csv":
+		return ".csv"
+	case "text/html":
+		return ".html"
+	case "text/markdown":
+		return ".md"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+func results() Results {
+	frame := 2
+	return Results{
+		"asr": &streams.ASRResult{Segments: []streams.ASRSegment{
+			{Start: 0, End: 1.5, Text: "Meet the new blender."},
+			{Start: 61.25, End: 3723.004, Text: "Order now"},
+		}},
+		"timeline": &streams.Timeline{Entries: []streams.TimelineEntry{
+			{Start: 0, End: 1.5, Source: "speech", Text: "Meet the new blender."},
+			{Start: 1.5, End: 3, Source: "visual", FrameIndex: &frame, Text: `A "pro" blender, on a counter`},
+		}},
+	}
+}
+
+func run(t *testing.T, name string, r Results) *Output {
+	t.Helper()
+	hook, ok := Builtin(name)
+	if !ok {
+		t.Fatalf("no builtin %q", name)
+	}
+	out, err := hook(context.Background(), "ad1", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSRT(t *testing.T) {
+	out := run(t, "srt", results())
+	want := "1\n00:00:00,000 --> 00:00:01,500\nMeet the new blender.\n\n" +
+		"2\n00:01:01,250 --> 01:02:03,004\nOrder now\n\n"
+	if out.File != "transcript.srt" || string(out.Data) != want {
+		t.Errorf("srt = %s %q", out.File, out.Data)
+	}
+}
+
+func TestVTT(t *testing.T) {
+	out := run(t, "vtt", results())
+	if !strings.HasPrefix(string(out.Data), "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\nMeet the new blender.\n\n") {
+		t.Errorf("vtt = %q", out.Data)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	out := run(t, "csv", results())
+	want := "start,end,source,frame_index,text\n" +
+		"0.000,1.500,speech,,Meet the new blender.\n" +
+		"1.500,3.000,visual,2,\"A \"\"pro\"\" blender, on a counter\"\n"
+	if string(out.Data) != want {
+		t.Errorf("csv = %q", out.Data)
+	}
+}
+
+func TestMerged(t *testing.T) {
+	out := run(t, "merged", results())
+	var got struct {
+		AdID    string                     `json:"ad_id"`
+		Streams map[string]json.RawMessage `json:"streams"`
+	}
+	if err := json.Unmarshal(out.Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.AdID != "ad1" || len(got.Streams) != 2 {
+		t.Errorf("merged = %s", out.Data)
+	}
+}
+
+func TestBuiltins_NothingToDo(t *testing.T) {
+	for _, name := range []string{"srt", "vtt", "csv"} {
+		if out := run(t, name, Results{}); out != nil {
+			t.Errorf("%s produced %s without input", name, out.File)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("ad,hook\nad1,3s\n"))
+	}))
+	defer srv.Close()
+
+	out, err := Remote(srv.URL, time.Second)(context.Background(), "ad1", results())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.File != "transform.csv" || out.ContentType != "text/csv; charset=utf-8" || string(out.Data) != "ad,hook\nad1,3s\n" {
+		t.Errorf("output = %+v", out)
+	}
+	if got["ad_id"] != "ad1" || got["streams"].(map[string]any)["asr"] == nil {
+		t.Errorf("posted %v", got)
+	}
+}
+
+func TestRemote_NoContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	out, err := Remote(srv.URL, time.Second)(context.Background(), "ad1", results())
+	if out != nil || err != nil {
+		t.Errorf("Remote = %v, %v", out, err)
+	}
+}
+
+func TestRemote_ClientErrorNotRetried(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad input", http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	_, err := Remote(srv.URL, time.Second)(context.Background(), "ad1", results())
+	var httpErr *retry.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("err = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("called %d times", calls)
+	}
+}
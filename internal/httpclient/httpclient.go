@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Options tunes the transport of a provider client. Zero fields take the
+// defaults listed in Defaults.
+type Options struct {
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	// DisableHTTP2 forces HTTP/1.1 for providers that misbehave over h2.
+	DisableHTTP2 bool
+	// HTTP2PingInterval sends a PING on an HTTP/2 connection that has been
+	// silent this long, and HTTP2PingTimeout closes it if no answer arrives,
+	// so dead connections are dropped instead of hanging requests.
+	HTTP2PingInterval time.Duration
+	HTTP2PingTimeout  time.Duration
+
+	// TLS replaces the default TLS settings, e.g. to trust a private CA
+	// (see TLSConfig).
+	TLS *tls.Config
+}
+
+// Override returns o with every non-zero field of with applied on top.
+func (o Options) Override(with Options) Options {
+	if with.MaxIdleConnsPerHost > 0 {
+		o.MaxIdleConnsPerHost = with.MaxIdleConnsPerHost
+	}
+	if with.MaxConnsPerHost > 0 {
+		o.MaxConnsPerHost = with.MaxConnsPerHost
+	}
+	if with.DialTimeout > 0 {
+		o.DialTimeout = with.DialTimeout
+	}
+	if with.KeepAlive > 0 {
+		o.KeepAlive = with.KeepAlive
+	}
+	if with.TLSHandshakeTimeout > 0 {
+		o.TLSHandshakeTimeout = with.TLSHandshakeTimeout
+	}
+	if with.ResponseHeaderTimeout > 0 {
+		o.ResponseHeaderTimeout = with.ResponseHeaderTimeout
+	}
+	if with.IdleConnTimeout > 0 {
+		o.IdleConnTimeout = with.IdleConnTimeout
+	}
+	if with.DisableHTTP2 {
+		o.DisableHTTP2 = true
+	}
+	if with.HTTP2PingInterval > 0 {
+		o.HTTP2PingInterval = with.HTTP2PingInterval
+	}
+	if with.HTTP2PingTimeout > 0 {
+		o.HTTP2PingTimeout = with.HTTP2PingTimeout
+	}
+	if with.TLS != nil {
+		o.TLS = with.TLS
+	}
+	return o
+}
+
+// Defaults are suitable for JSON APIs that answer within a minute.
+var Defaults = Options{
+	MaxIdleConnsPerHost:   16,
+	MaxConnsPerHost:       64,
+	DialTimeout:           10 * time.Second,
+	KeepAlive:             30 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 60 * time.Second,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+// New builds a client with its own connection pool. There is no overall
+// Client.Timeout: request lifetimes are governed by their contexts, while
+// the transport timeouts catch stalled connects and unresponsive servers.
+func New(opts Options) *http.Client {
+	d := Defaults.Override(opts)
+
+	dialer := &net.Dialer{Timeout: d.DialTimeout, KeepAlive: d.KeepAlive}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          d.MaxIdleConnsPerHost * 4,
+		MaxIdleConnsPerHost:   d.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       d.MaxConnsPerHost,
+		TLSHandshakeTimeout:   d.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: d.ResponseHeaderTimeout,
+		IdleConnTimeout:       d.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       d.TLS,
+	}
+	if d.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	} else if d.HTTP2PingInterval > 0 || d.HTTP2PingTimeout > 0 {
+		transport.HTTP2 = &http.HTTP2Config{
+			SendPingTimeout: d.HTTP2PingInterval,
+			PingTimeout:     d.HTTP2PingTimeout,
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// TLSConfig builds client TLS settings for a server with a certificate from
+// a private CA: caFile (PEM) is trusted in addition to the system roots,
+// certFile and keyFile, if set, are presented as a client certificate, and
+// insecure skips verifying the server altogether. It returns nil when
+// nothing is set.
+func TLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls CA: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tls CA: no certificates in " + caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
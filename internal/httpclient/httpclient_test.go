@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNew_AppliesOverridesAndDefaults(t *testing.T) {
+	c := New(Options{MaxConnsPerHost: 7, ResponseHeaderTimeout: 5 * time.Minute})
+
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport = %T", c.Transport)
+	}
+	if tr.MaxConnsPerHost != 7 {
+		t.Errorf("MaxConnsPerHost = %d, want 7", tr.MaxConnsPerHost)
+	}
+	if tr.ResponseHeaderTimeout != 5*time.Minute {
+		t.Errorf("ResponseHeaderTimeout = %v", tr.ResponseHeaderTimeout)
+	}
+	if tr.MaxIdleConnsPerHost != Defaults.MaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d, want default", tr.MaxIdleConnsPerHost)
+	}
+	if tr.TLSHandshakeTimeout != Defaults.TLSHandshakeTimeout {
+		t.Errorf("TLSHandshakeTimeout = %v, want default", tr.TLSHandshakeTimeout)
+	}
+	if c.Timeout != 0 {
+		t.Errorf("client timeout = %v, want none", c.Timeout)
+	}
+}
+
+func TestNew_HTTP2Settings(t *testing.T) {
+	tr := New(Options{DisableHTTP2: true}).Transport.(*http.Transport)
+	if tr.ForceAttemptHTTP2 || tr.Protocols.HTTP2() || !tr.Protocols.HTTP1() {
+		t.Errorf("HTTP/2 should be disabled: force=%v protocols=%v", tr.ForceAttemptHTTP2, tr.Protocols)
+	}
+
+	tr = New(Options{HTTP2PingInterval: 15 * time.Second, HTTP2PingTimeout: 5 * time.Second}).Transport.(*http.Transport)
+	if !tr.ForceAttemptHTTP2 || tr.HTTP2 == nil || tr.HTTP2.SendPingTimeout != 15*time.Second || tr.HTTP2.PingTimeout != 5*time.Second {
+		t.Errorf("HTTP2 config = %+v", tr.HTTP2)
+	}
+}
+
+func TestOptions_Override(t *testing.T) {
+	base := Options{MaxConnsPerHost: 32, ResponseHeaderTimeout: time.Minute}
+	got := base.Override(Options{MaxConnsPerHost: 100, DisableHTTP2: true})
+	if got.MaxConnsPerHost != 100 || got.ResponseHeaderTimeout != time.Minute || !got.DisableHTTP2 {
+		t.Errorf("Override = %+v", got)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	if cfg, err := TLSConfig("", "", "", false); cfg != nil || err != nil {
+		t.Errorf("nothing set: cfg = %v, err = %v", cfg, err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := TLSConfig(caFile, "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := New(Options{TLS: cfg}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request trusting the CA: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := New(Options{}).Get(server.URL); err == nil {
+		t.Error("request without the CA succeeded")
+	}
+
+	if _, err := TLSConfig(filepath.Join(t.TempDir(), "missing.pem"), "", "", false); err == nil {
+		t.Error("missing CA file accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is wrapped into the errors of connections PublicDialer
+// refuses.
+var ErrNotPublic = errors.New("not a public address")
+
+// PublicDialer returns a dialer that connects to public addresses only, not
+// to loopback, private or link-local ones, for clients whose URLs come from
+// callers and must not reach services on the private network. The address
+// is checked as it is dialled, after name resolution, so host names that
+// resolve to private addresses are refused too.
+func PublicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, Control: publicOnly}
+}
+
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !public(ip) {
+		return fmt.Errorf("%s is %w", ip, ErrNotPublic)
+	}
+	return nil
+}
+
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
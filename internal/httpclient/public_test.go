@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:2800:220::1": true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"192.168.0.1":      false,
+		"169.254.169.254":  false,
+		"::1":              false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"0.0.0.0":          false,
+	} {
+		if got := public(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("public(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPublicDialer_RefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+
+	_, err := PublicDialer(time.Second).DialContext(context.Background(), "tcp", server.Listener.Addr().String())
+	if !errors.Is(err, ErrNotPublic) {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+)
+
+// ErrRejected is wrapped into errors about the video itself, as opposed to
+// failures reaching it: a URL that is not allowed, a response other than
+// 2xx, a body that is not a video or is too large.
+var ErrRejected = errors.New("video rejected")
+
+// maxRedirects bounds the redirects Fetch follows.
+const maxRedirects = 5
+
+// Options bounds what Fetch accepts.
+type Options struct {
+	MaxBytes int64
+
+	// AllowedHosts are host names, or "*.example.com" for any subdomain,
+	// the URL and every redirect must point at; empty allows any
+	AllowedHosts []string
+
+	// Client fetches the video. The default refuses to connect to
+	// loopback, private and link-local addresses.
+	Client *http.Client
+}
+
+// ValidateURL checks that raw is an absolute https URL on an allowed host.
+func ValidateURL(raw string, allowedHosts []string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	return validate(u, allowedHosts)
+}
+
+func validate(u *url.URL, allowedHosts []string) error {
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: %s is not an absolute https URL", ErrRejected, u.Redacted())
+	}
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s is not allowed", ErrRejected, host)
+}
+
+// Fetch downloads the video at raw into dst and returns its content type
+// and size. The response must be a video: its Content-Type is video/*, or
+// it is generic (application/octet-stream or missing) and the body sniffs
+// as a video. Bodies over o.MaxBytes are rejected; dst may then hold part
+// of one.
+func Fetch(ctx context.Context, raw string, dst io.Writer, o Options) (string, int64, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	if err := validate(u, o.AllowedHosts); err != nil {
+		return "", 0, err
+	}
+
+	c := *defaultClient
+	if o.Client != nil {
+		c = *o.Client
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("%w: more than %d redirects", ErrRejected, maxRedirects)
+		}
+		return validate(req.URL, o.AllowedHosts)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := c.Do(req)
+	if errors.Is(err, httpclient.ErrNotPublic) {
+		return "", 0, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return "", 0, fmt.Errorf("%s answered %d", u.Host, resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", 0, fmt.Errorf("%w: %s answered %d", ErrRejected, u.Host, resp.StatusCode)
+	case o.MaxBytes > 0 && resp.ContentLength > o.MaxBytes:
+		return "", 0, fmt.Errorf("%w: %d bytes is more than the limit of %d", ErrRejected, resp.ContentLength, o.MaxBytes)
+	}
+
+	body := bufio.NewReaderSize(resp.Body, 512)
+	contentType, err := videoType(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return "", 0, err
+	}
+	var r io.Reader = body
+	if o.MaxBytes > 0 {
+		r = io.LimitReader(body, o.MaxBytes+1)
+	}
+	n, err := io.Copy(dst, r)
+	if err != nil {
+		return "", n, err
+	}
+	if o.MaxBytes > 0 && n > o.MaxBytes {
+		return "", n, fmt.Errorf("%w: more than the limit of %d bytes", ErrRejected, o.MaxBytes)
+	}
+	return contentType, n, nil
+}
+
+// videoType returns the video's content type, from header or, for generic
+// ones, from sniffing the start of body.
+func videoType(header string, body *bufio.Reader) (string, error) {
+	mt, _, err := mime.ParseMediaType(header)
+	if header != "" && err != nil {
+		return "", fmt.Errorf("%w: content type %q: %w", ErrRejected, header, err)
+	}
+	if strings.HasPrefix(mt, "video/") {
+		return mt, nil
+	}
+	if mt != "" && mt != "application/octet-stream" && mt != "binary/octet-stream" {
+		return "", fmt.Errorf("%w: content type %s is not a video", ErrRejected, mt)
+	}
+	head, err := body.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", err
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !strings.HasPrefix(sniffed, "video/") {
+		return "", fmt.Errorf("%w: content looks like %s, not a video", ErrRejected, sniffed)
+	}
+	return sniffed, nil
+}
+
+// defaultClient connects to public addresses only, so request URLs cannot
+// reach services on the private network.
+var defaultClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:           httpclient.PublicDialer(10 * time.Second).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
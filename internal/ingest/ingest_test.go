@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mp4 is the start of an MP4 file, enough to sniff as one.
+var mp4 = append([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), make([]byte, 1000)...)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ad.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write(mp4)
+		case "/generic":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(mp4)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/disguised":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("<html></html>"))
+		case "/chunked":
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write(mp4)
+			w.(http.Flusher).Flush()
+			w.Write(mp4)
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/away":
+			http.Redirect(w, r, "https://elsewhere.example/ad.mp4", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		path     string
+		max      int64
+		allowed  []string
+		want     string // content type, or "" for an error
+		rejected bool
+	}{
+		{path: "/ad.mp4", want: "video/mp4"},
+		{path: "/generic", want: "video/mp4"},
+		{path: "/ad.mp4", max: 100, rejected: true},
+		{path: "/chunked", max: int64(len(mp4)) + 10, rejected: true},
+		{path: "/page", rejected: true},
+		{path: "/disguised", rejected: true},
+		{path: "/missing", rejected: true},
+		{path: "/busy"},
+		{path: "/ad.mp4", allowed: []string{"127.0.0.1"}, want: "video/mp4"},
+		{path: "/ad.mp4", allowed: []string{"*.example.com"}, rejected: true},
+		{path: "/away", rejected: true, allowed: []string{"127.0.0.1"}},
+	} {
+		var buf bytes.Buffer
+		ct, n, err := Fetch(context.Background(), server.URL+tc.path, &buf, Options{MaxBytes: tc.max, AllowedHosts: tc.allowed, Client: server.Client()})
+		if tc.want != "" {
+			if err != nil || ct != tc.want || n != int64(len(mp4)) || !bytes.Equal(buf.Bytes(), mp4) {
+				t.Errorf("%s: %q, %d bytes, %v", tc.path, ct, n, err)
+			}
+			continue
+		}
+		if err == nil || errors.Is(err, ErrRejected) != tc.rejected {
+			t.Errorf("%s (max %d, allowed %v): err = %v, rejected want %v", tc.path, tc.max, tc.allowed, err, tc.rejected)
+		}
+	}
+}
+
+func TestFetch_PrivateAddress(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private address reached")
+	}))
+	defer server.Close()
+
+	_, _, err := Fetch(context.Background(), server.URL+"/ad.mp4", &bytes.Buffer{}, Options{})
+	if !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://cdn.example.com/a.mp4": true,
+		"http://cdn.example.com/a.mp4":  false,
+		"/a.mp4":                        false,
+		"https://other.org/a.mp4":       false,
+		"https://example.com/a.mp4":     false,
+		"https://a.b.example.com/a.mp4": true,
+	} {
+		err := ValidateURL(raw, []string{"*.example.com"})
+		if (err == nil) != ok {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+	if err := ValidateURL("https://anything.test/x", nil); err != nil {
+		t.Errorf("no allowlist: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package keyframes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options selects which frames Extract keeps.
+type Options struct {
+	Mode           string        // "scene" or "interval"
+	SceneThreshold float64       // scene: minimum scene-change score, 0-1
+	Interval       time.Duration // interval: time between frames
+	MaxFrames      int           // frames kept, thinned evenly over time (0 = all)
+}
+
+// Frame is one extracted keyframe, written to Path.
+type Frame struct {
+	Path         string
+	FrameNumber  int // in the source video; 0 if its frame rate is unknown
+	TimestampSec float64
+	EntropyScore float64 // Shannon entropy of the luma histogram, 0-8 bits
+}
+
+var (
+	ptsTime  = regexp.MustCompile(`pts_time:\s*(-?[0-9.]+)`)
+	inputFPS = regexp.MustCompile(`Video: .*?, ([0-9.]+) fps,`)
+)
+
+// Extract runs ffmpeg over input, a path or URL ffmpeg can read, writing
+// JPEG keyframes into dir. Scene mode always keeps the first frame, so an ad
+// shot in a single take still gets one. Frames are returned in time order.
+func Extract(ctx context.Context, input, dir string, opts Options) ([]Frame, error) {
+	var sel string
+	switch opts.Mode {
+	case "scene":
+		sel = fmt.Sprintf("select='eq(n,0)+gt(scene,%g)'", opts.SceneThreshold)
+	case "interval":
+		if opts.Interval <= 0 {
+			return nil, fmt.Errorf("keyframe interval must be positive")
+		}
+		sel = fmt.Sprintf("select='isnan(prev_selected_t)+gte(t-prev_selected_t,%g)'", opts.Interval.Seconds())
+	default:
+		return nil, fmt.Errorf("unknown keyframe mode %q", opts.Mode)
+	}
+
+	// showinfo logs each selected frame's timestamp to stderr, in output order
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostdin",
+		"-i", input,
+		"-vf", sel+",showinfo",
+		"-fps_mode", "vfr",
+		"-q:v", "3",
+		filepath.Join(dir, "frame_%05d.jpg"),
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, tail(stderr.String(), 5))
+	}
+
+	timestamps, fps := parseLog(stderr.String())
+	paths, err := filepath.Glob(filepath.Join(dir, "frame_*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	if len(paths) != len(timestamps) {
+		return nil, fmt.Errorf("ffmpeg wrote %d frames but logged %d", len(paths), len(timestamps))
+	}
+
+	frames := make([]Frame, len(paths))
+	for i, p := range paths {
+		frames[i] = Frame{Path: p, TimestampSec: timestamps[i]}
+		if fps > 0 {
+			frames[i].FrameNumber = int(math.Round(timestamps[i] * fps))
+		}
+	}
+	frames = thin(frames, opts.MaxFrames)
+
+	for i := range frames {
+		if frames[i].EntropyScore, err = entropy(frames[i].Path); err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+// parseLog reads the selected frames' timestamps and the input frame rate
+// (0 if not reported) from ffmpeg's stderr.
+func parseLog(log string) (timestamps []float64, fps float64) {
+	sc := bufio.NewScanner(strings.NewReader(log))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if fps == 0 {
+			if m := inputFPS.FindStringSubmatch(line); m != nil {
+				fps, _ = strconv.ParseFloat(m[1], 64)
+			}
+		}
+		if !strings.Contains(line, "showinfo") {
+			continue
+		}
+		if m := ptsTime.FindStringSubmatch(line); m != nil {
+			ts, err := strconv.ParseFloat(m[1], 64)
+			if err == nil {
+				timestamps = append(timestamps, max(ts, 0))
+			}
+		}
+	}
+	return timestamps, fps
+}
+
+// thin keeps n frames spread evenly over frames, first and last included.
+func thin(frames []Frame, n int) []Frame {
+	if n <= 0 || len(frames) <= n {
+		return frames
+	}
+	if n == 1 {
+		return frames[:1]
+	}
+	out := make([]Frame, n)
+	for i := range out {
+		out[i] = frames[i*(len(frames)-1)/(n-1)]
+	}
+	return out
+}
+
+// entropy scores how much visual detail an image holds, so that frame caps
+// prefer busy frames over fades and blank title cards.
+func entropy(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+
+	var hist [256]int
+	b := img.Bounds()
+	if yc, ok := img.(*image.YCbCr); ok {
+		// JPEGs decode to YCbCr, whose Y plane already is the luma
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := yc.Y[yc.YOffset(b.Min.X, y):]
+			for _, v := range row[:b.Dx()] {
+				hist[v]++
+			}
+		}
+	} else {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := img.At(x, y).RGBA()
+				// ITU-R BT.601 luma, from 16-bit channels
+				hist[(299*r+587*g+114*bl)/1000>>8]++
+			}
+		}
+	}
+	total := float64(b.Dx() * b.Dy())
+	var h float64
+	for _, n := range hist {
+		if n > 0 {
+			p := float64(n) / total
+			h -= p * math.Log2(p)
+		}
+	}
+	return h, nil
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
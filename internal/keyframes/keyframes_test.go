@@ -0,0 +1,118 @@
+package keyframes
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseLog(t *testing.T) {
+	log := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'video.mp4':
+  Stream #0:0[0x1](und): Video: h264 (High) (avc1 / 0x31637661), yuv420p, 1080x1920, 3964 kb/s, 29.97 fps, 29.97 tbr, 30k tbn (default)
+[Parsed_showinfo_1 @ 0x5581] config in time_base: 1/30000, frame_rate: 30000/1001
+[Parsed_showinfo_1 @ 0x5581] n:   0 pts:      0 pts_time:0       duration:   1001 duration_time:0.0333667
+[Parsed_showinfo_1 @ 0x5581] n:   1 pts:  64064 pts_time:2.13547 duration:   1001 duration_time:0.0333667
+[Parsed_showinfo_1 @ 0x5581] n:   2 pts: 270270 pts_time:9.009   duration:   1001 duration_time:0.0333667
+`
+	timestamps, fps := parseLog(log)
+	if want := []float64{0, 2.13547, 9.009}; !slices.Equal(timestamps, want) {
+		t.Errorf("timestamps = %v, want %v", timestamps, want)
+	}
+	if fps != 29.97 {
+		t.Errorf("fps = %v, want 29.97", fps)
+	}
+}
+
+func TestThin(t *testing.T) {
+	var frames []Frame
+	for i := range 10 {
+		frames = append(frames, Frame{TimestampSec: float64(i)})
+	}
+	var got []float64
+	for _, f := range thin(frames, 4) {
+		got = append(got, f.TimestampSec)
+	}
+	if want := []float64{0, 3, 6, 9}; !slices.Equal(got, want) {
+		t.Errorf("thin = %v, want %v", got, want)
+	}
+	if len(thin(frames, 0)) != 10 || len(thin(frames, 20)) != 10 {
+		t.Error("thin should keep every frame when uncapped or under the cap")
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, fill func(x, y int) uint8) string {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		for y := range 64 {
+			for x := range 64 {
+				v := fill(x, y)
+				img.Set(x, y, color.RGBA{v, v, v, 255})
+			}
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	rng := rand.New(rand.NewSource(1))
+	flat := write("flat.jpg", func(x, y int) uint8 { return 128 })
+	noise := write("noise.jpg", func(x, y int) uint8 { return uint8(rng.Intn(256)) })
+
+	low, err := entropy(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := entropy(noise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low > 0.5 || high < 6 {
+		t.Errorf("entropy flat = %.2f, noise = %.2f; want near 0 and near 8", low, high)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	dir := t.TempDir()
+	video := filepath.Join(dir, "video.mp4")
+	gen := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=5:size=320x240:rate=25", video)
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Fatalf("generate video: %v: %s", err, out)
+	}
+
+	frames, err := Extract(context.Background(), video, dir, Options{Mode: "interval", Interval: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for _, f := range frames {
+		got = append(got, f.TimestampSec)
+		if f.EntropyScore <= 0 {
+			t.Errorf("frame at %.1fs: entropy %.2f", f.TimestampSec, f.EntropyScore)
+		}
+	}
+	if want := []float64{0, 2, 4}; !slices.Equal(got, want) {
+		t.Errorf("timestamps = %v, want %v", got, want)
+	}
+	if frames[2].FrameNumber != 100 {
+		t.Errorf("frame number = %d, want 100", frames[2].FrameNumber)
+	}
+}
@@ -0,0 +1,221 @@
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a load run against a pipeline instance.
+type Options struct {
+	Target      string        // base URL, e.g. http://localhost:8080
+	AdIDs       []string      // corpus, replayed in order and wrapped around
+	Rate        float64       // requests started per second
+	Duration    time.Duration // stop starting requests after this long (0 = until Requests)
+	Requests    int           // stop after this many requests (0 = until Duration)
+	Concurrency int           // max requests in flight; ticks beyond it are dropped
+	Client      *http.Client
+}
+
+// Report summarises a load run.
+type Report struct {
+	Sent      int
+	Failed    int // transport errors and non-200 responses
+	Dropped   int // not sent because Concurrency requests were in flight
+	Elapsed   time.Duration
+	Latencies []time.Duration // of every sent request, sorted
+	Streams   map[string]int  // "stream/status" counts from successful responses
+	Errors    map[string]int  // failure reasons
+}
+
+type extractResponse struct {
+	Streams []struct {
+		Stream string `json:"stream"`
+		Status string `json:"status"`
+	} `json:"streams"`
+}
+
+// LoadCorpus reads ad IDs from a file, one per line. Blank lines and lines
+// starting with # are ignored.
+func LoadCorpus(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open corpus: %w", err)
+	}
+	defer f.Close()
+
+	var ids []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids = append(ids, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read corpus: %w", err)
+	}
+	return ids, nil
+}
+
+// Run sends POST /v1/extract requests at opts.Rate until the duration or request
+// count is reached, then waits for in-flight requests to finish.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if len(opts.AdIDs) == 0 {
+		return nil, fmt.Errorf("empty corpus")
+	}
+	if opts.Rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, fmt.Errorf("set a duration or a request count")
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url := strings.TrimRight(opts.Target, "/") + "/v1/extract"
+
+	rep := &Report{Streams: map[string]int{}, Errors: map[string]int{}}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(opts.Concurrency, 1))
+	)
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	tick := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+	defer tick.Stop()
+
+	t0 := time.Now()
+	for i := 0; opts.Requests <= 0 || i < opts.Requests; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-tick.C:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			rep.Dropped++
+			continue
+		}
+		adID := opts.AdIDs[i%len(opts.AdIDs)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Requests outlive the run's duration so they are measured in full
+			latency, resp, err := send(context.WithoutCancel(ctx), client, url, adID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Sent++
+			rep.Latencies = append(rep.Latencies, latency)
+			if err != nil {
+				rep.Failed++
+				rep.Errors[err.Error()]++
+				return
+			}
+			for _, s := range resp.Streams {
+				rep.Streams[s.Stream+"/"+s.Status]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	rep.Elapsed = time.Since(t0)
+	slices.Sort(rep.Latencies)
+	return rep, nil
+}
+
+func send(ctx context.Context, client *http.Client, url, adID string) (time.Duration, *extractResponse, error) {
+	// Forced, so that ads sent more than once are processed each time
+	// rather than answered from stored results
+	body, _ := json.Marshal(map[string]any{"ad_id": adID, "force": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), nil, fmt.Errorf("transport error")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, nil, fmt.Errorf("read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return latency, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var out extractResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return latency, nil, fmt.Errorf("invalid response body")
+	}
+	return latency, &out, nil
+}
+
+// Percentile returns the p-th percentile (0-100) of the sorted latencies.
+func (r *Report) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(r.Latencies)-1))
+	return r.Latencies[i]
+}
+
+// Write prints a human-readable summary.
+func (r *Report) Write(w io.Writer) {
+	errorRate := 0.0
+	if r.Sent > 0 {
+		errorRate = float64(r.Failed) / float64(r.Sent) * 100
+	}
+	fmt.Fprintf(w, "sent %d, failed %d (%.1f%%), dropped %d in %s (%.2f req/s)\n",
+		r.Sent, r.Failed, errorRate, r.Dropped, r.Elapsed.Round(time.Millisecond),
+		float64(r.Sent)/r.Elapsed.Seconds())
+	fmt.Fprintf(w, "latency p50 %s  p90 %s  p99 %s  max %s\n",
+		r.Percentile(50).Round(time.Millisecond), r.Percentile(90).Round(time.Millisecond),
+		r.Percentile(99).Round(time.Millisecond), r.Percentile(100).Round(time.Millisecond))
+
+	for _, section := range []struct {
+		title  string
+		counts map[string]int
+	}{{"streams", r.Streams}, {"errors", r.Errors}} {
+		if len(section.counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", section.title)
+		keys := make([]string, 0, len(section.counts))
+		for k := range section.counts {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %-28s %d\n", k, section.counts[k])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			AdID string `json:"ad_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		seen = append(seen, body.AdID)
+		mu.Unlock()
+
+		if body.AdID == "bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"streams": []map[string]string{
+				{"stream": "asr", "status": "success"},
+				{"stream": "vlm", "status": "error"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	rep, err := Run(context.Background(), Options{
+		Target:      server.URL,
+		AdIDs:       []string{"a", "bad"},
+		Rate:        200,
+		Requests:    4,
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if rep.Sent != 4 || rep.Failed != 2 || len(rep.Latencies) != 4 {
+		t.Errorf("sent %d, failed %d, latencies %d", rep.Sent, rep.Failed, len(rep.Latencies))
+	}
+	if rep.Streams["asr/success"] != 2 || rep.Streams["vlm/error"] != 2 {
+		t.Errorf("streams = %v", rep.Streams)
+	}
+	if rep.Errors["HTTP 500"] != 2 {
+		t.Errorf("errors = %v", rep.Errors)
+	}
+	if len(seen) != 4 {
+		t.Errorf("server saw %v", seen)
+	}
+
+	var sb strings.Builder
+	rep.Write(&sb)
+	if !strings.Contains(sb.String(), "failed 2 (50.0%)") {
+		t.Errorf("report:\n%s", sb.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	rep := &Report{}
+	for i := 1; i <= 100; i++ {
+		rep.Latencies = append(rep.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	if p := rep.Percentile(50); p != 50*time.Millisecond {
+		t.Errorf("p50 = %v", p)
+	}
+	if p := rep.Percentile(100); p != 100*time.Millisecond {
+		t.Errorf("max = %v", p)
+	}
+}
+
+func TestLoadCorpus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.txt")
+	os.WriteFile(path, []byte("# sample\nad-1\n\n  ad-2  \n"), 0o644)
+
+	ids, err := LoadCorpus(path)
+	if err != nil {
+		t.Fatalf("LoadCorpus: %v", err)
+	}
+	if strings.Join(ids, ",") != "ad-1,ad-2" {
+		t.Errorf("ids = %v", ids)
+	}
+}
@@ -0,0 +1,260 @@
+package natsjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+	"github.com/nikipaj1/video-description-pipeline/internal/worker"
+)
+
+// Dial connects to a NATS server with JetStream enabled.
+func Dial(url string) (*nats.Conn, jetstream.JetStream, error) {
+	nc, err := nats.Connect(url, nats.Name("video-description-pipeline"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("nats: %w", err)
+	}
+	return nc, js, nil
+}
+
+// fetchWait is how long one pull waits for a job before pulling again.
+const fetchWait = 30 * time.Second
+
+// SourceOptions configure the durable consumer jobs are pulled through.
+type SourceOptions struct {
+	Stream     string        // JetStream stream the jobs are published to
+	Consumer   string        // durable consumer name, shared by every worker
+	Subject    string        // filter subject ("" = the whole stream)
+	AckWait    time.Duration // silence after which a job is redelivered
+	MaxDeliver int           // deliveries before a job is given up (0 = no limit)
+	RetryDelay time.Duration // before a failed job is redelivered
+}
+
+type fetcher interface {
+	Fetch(batch int, opts ...jetstream.FetchOpt) (jetstream.MessageBatch, error)
+}
+
+// Source is a worker.Source over a JetStream durable pull consumer. Each
+// message is an /extract request body. A job is acknowledged only once it
+// has finished and its artifacts are stored, and reported as in progress
+// meanwhile so that long jobs are not redelivered. Jobs that fail on an
+// invalid request are acknowledged too; other failures are redelivered
+// after RetryDelay, up to MaxDeliver times. Messages that are not a valid
+// request are terminated.
+type Source struct {
+	cons   fetcher
+	opts   SourceOptions
+	events *Events
+}
+
+// NewSource creates or updates the durable consumer and pulls jobs through
+// it. events, if not nil, is sent each job's completion before the job is
+// acknowledged.
+func NewSource(ctx context.Context, js jetstream.JetStream, opts SourceOptions, events *Events) (*Source, error) {
+	cons, err := js.CreateOrUpdateConsumer(ctx, opts.Stream, jetstream.ConsumerConfig{
+		Durable:       opts.Consumer,
+		FilterSubject: opts.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       opts.AckWait,
+		MaxDeliver:    opts.MaxDeliver,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("nats consumer %s on %s: %w", opts.Consumer, opts.Stream, err)
+	}
+	return &Source{cons: cons, opts: opts, events: events}, nil
+}
+
+func (s *Source) Receive(ctx context.Context) (*worker.Delivery, error) {
+	for {
+		msg, err := s.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var req handler.ExtractRequest
+		if err := json.Unmarshal(msg.Data(), &req); err != nil {
+			log.Printf("WARN: dropping message on %s: %v", msg.Subject(), err)
+			msg.Term()
+			continue
+		}
+
+		stop := s.keepAlive(msg)
+		return &worker.Delivery{
+			Request: req,
+			Done: func(resp *handler.ExtractResponse, err error) {
+				stop()
+				s.done(msg, req.AdID, resp, err)
+			},
+		}, nil
+	}
+}
+
+// next pulls one message, waiting while the stream is empty or the server
+// unreachable.
+func (s *Source) next(ctx context.Context) (jetstream.Msg, error) {
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchWait)
+		batch, err := s.cons.Fetch(1, jetstream.FetchContext(fetchCtx))
+		if err == nil {
+			for msg := range batch.Messages() {
+				cancel()
+				return msg, nil
+			}
+			err = batch.Error()
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		// A disconnect should not stop the worker; the client reconnects
+		log.Printf("WARN: nats consumer %s: %v", s.opts.Consumer, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// keepAlive tells the server the job is still running every half AckWait,
+// until stop is called.
+func (s *Source) keepAlive(msg jetstream.Msg) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(max(s.opts.AckWait/2, time.Second))
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := msg.InProgress(); err != nil {
+					log.Printf("WARN: nats: extend %s: %v", msg.Subject(), err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *Source) done(msg jetstream.Msg, adID string, resp *handler.ExtractResponse, runErr error) {
+	var jobErr *handler.JobError
+	if runErr != nil && !(errors.As(runErr, &jobErr) && jobErr.Status == http.StatusBadRequest) {
+		s.nak(msg)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if s.events != nil {
+		// Redeliveries of the same message publish with the same ID, so
+		// the completion is not duplicated
+		id := adID
+		if meta, err := msg.Metadata(); err == nil {
+			id = fmt.Sprintf("%s/%d", meta.Stream, meta.Sequence.Stream)
+		}
+		if err := s.events.JobDone(ctx, id, adID, resp, runErr); err != nil {
+			// Leave the job to be redelivered rather than lose its completion
+			log.Printf("WARN: completion for %s: %v", adID, err)
+			s.nak(msg)
+			return
+		}
+	}
+	if err := msg.DoubleAck(ctx); err != nil {
+		log.Printf("WARN: nats: ack %s: %v", adID, err)
+	}
+}
+
+// nak redelivers a message after RetryDelay. A failure only means it is
+// redelivered once AckWait passes.
+func (s *Source) nak(msg jetstream.Msg) {
+	if err := msg.NakWithDelay(s.opts.RetryDelay); err != nil {
+		log.Printf("WARN: nats: nak %s: %v", msg.Subject(), err)
+	}
+}
+
+type publisher interface {
+	Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Events publishes completion events to JetStream: {prefix}.stream.{name}
+// as each stream of a job finishes and {prefix}.job when the job does. The
+// subjects must belong to a stream. A nil *Events publishes nothing.
+type Events struct {
+	js     publisher
+	prefix string
+}
+
+// NewEvents returns nil without a prefix.
+func NewEvents(js jetstream.JetStream, prefix string) *Events {
+	if prefix == "" {
+		return nil
+	}
+	return &Events{js: js, prefix: prefix}
+}
+
+// StreamEvent is published as a stream finishes.
+type StreamEvent struct {
+	Event string `json:"event"` // "stream.completed"
+	AdID  string `json:"ad_id"`
+	handler.StreamResult
+}
+
+// JobEvent is published as a job finishes.
+type JobEvent struct {
+	Event  string                   `json:"event"` // "extraction.completed" | "extraction.failed"
+	AdID   string                   `json:"ad_id"`
+	Result *handler.ExtractResponse `json:"result,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// StreamDone publishes a stream's completion, for handler.OnStreamDone.
+// Failures are logged: a lost event must not hold up the job.
+func (e *Events) StreamDone(adID string, sr handler.StreamResult) {
+	if e == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.publish(ctx, e.prefix+".stream."+sr.Stream, StreamEvent{Event: "stream.completed", AdID: adID, StreamResult: sr}); err != nil {
+		log.Printf("WARN: %s event for %s: %v", sr.Stream, adID, err)
+	}
+}
+
+// JobDone publishes a job's completion. id deduplicates it: events
+// published again with the same id within the stream's duplicate window
+// are dropped.
+func (e *Events) JobDone(ctx context.Context, id, adID string, resp *handler.ExtractResponse, jobErr error) error {
+	if e == nil {
+		return nil
+	}
+	ev := JobEvent{Event: "extraction.completed", AdID: adID, Result: resp}
+	if jobErr != nil {
+		ev.Event, ev.Error = "extraction.failed", jobErr.Error()
+	}
+	return e.publish(ctx, e.prefix+".job", ev, jetstream.WithMsgID(id))
+}
+
+func (e *Events) publish(ctx context.Context, subject string, v any, opts ...jetstream.PublishOpt) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.js.Publish(ctx, subject, data, opts...)
+	return err
+}
@@ -0,0 +1,243 @@
+package natsjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+type fakeMsg struct {
+	jetstream.Msg
+	data []byte
+	seq  uint64
+
+	mu         sync.Mutex
+	acked      bool
+	nakDelay   time.Duration
+	terminated bool
+	progress   int
+}
+
+func (m *fakeMsg) Data() []byte    { return m.data }
+func (m *fakeMsg) Subject() string { return "extract.jobs" }
+
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{Stream: "EXTRACTION", Sequence: jetstream.SequencePair{Stream: m.seq}}, nil
+}
+
+func (m *fakeMsg) DoubleAck(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked = true
+	return nil
+}
+
+func (m *fakeMsg) NakWithDelay(d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nakDelay = d
+	return nil
+}
+
+func (m *fakeMsg) Term() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terminated = true
+	return nil
+}
+
+func (m *fakeMsg) InProgress() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.progress++
+	return nil
+}
+
+type fakeBatch struct {
+	msgs chan jetstream.Msg
+	err  error
+}
+
+func (b *fakeBatch) Messages() <-chan jetstream.Msg { return b.msgs }
+func (b *fakeBatch) Error() error                   { return b.err }
+
+// fakeConsumer hands out queued messages, one per fetch, and times out
+// fetches once none are left.
+type fakeConsumer struct{ msgs []*fakeMsg }
+
+func (c *fakeConsumer) Fetch(batch int, opts ...jetstream.FetchOpt) (jetstream.MessageBatch, error) {
+	b := &fakeBatch{msgs: make(chan jetstream.Msg, 1)}
+	if len(c.msgs) > 0 {
+		b.msgs <- c.msgs[0]
+		c.msgs = c.msgs[1:]
+	} else {
+		b.err = context.DeadlineExceeded
+	}
+	close(b.msgs)
+	return b, nil
+}
+
+type published struct {
+	subject string
+	data    []byte
+	opts    int
+}
+
+type fakePublisher struct {
+	mu   sync.Mutex
+	msgs []published
+	err  error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.msgs = append(p.msgs, published{subject, data, len(opts)})
+	return &jetstream.PubAck{}, nil
+}
+
+func TestSource_AcksAfterJobAndPublishesCompletion(t *testing.T) {
+	msg := &fakeMsg{data: []byte(`{"ad_id":"ad1"}`), seq: 42}
+	pub := &fakePublisher{}
+	src := &Source{
+		cons:   &fakeConsumer{msgs: []*fakeMsg{{data: []byte("not json")}, msg}},
+		opts:   SourceOptions{AckWait: time.Minute, RetryDelay: time.Minute},
+		events: &Events{js: pub, prefix: "extraction"},
+	}
+
+	d, err := src.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Request.AdID != "ad1" {
+		t.Fatalf("received %+v", d.Request)
+	}
+	if msg.acked {
+		t.Fatal("acked before the job finished")
+	}
+	d.Done(&handler.ExtractResponse{AdID: "ad1"}, nil)
+
+	if !msg.acked {
+		t.Error("not acked")
+	}
+	if len(pub.msgs) != 1 || pub.msgs[0].subject != "extraction.job" || pub.msgs[0].opts != 1 {
+		t.Fatalf("published %+v", pub.msgs)
+	}
+	var ev JobEvent
+	json.Unmarshal(pub.msgs[0].data, &ev)
+	if ev.Event != "extraction.completed" || ev.AdID != "ad1" || ev.Result == nil {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestSource_DropsInvalidMessages(t *testing.T) {
+	bad := &fakeMsg{data: []byte("not json")}
+	src := &Source{cons: &fakeConsumer{msgs: []*fakeMsg{bad, {data: []byte(`{"ad_id":"ad1"}`)}}}}
+	if _, err := src.Receive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bad.terminated {
+		t.Error("invalid message not terminated")
+	}
+}
+
+func TestSource_FailedJobIsRedelivered(t *testing.T) {
+	msg := &fakeMsg{data: []byte(`{"ad_id":"ad1"}`)}
+	pub := &fakePublisher{}
+	src := &Source{
+		cons:   &fakeConsumer{msgs: []*fakeMsg{msg}},
+		opts:   SourceOptions{AckWait: time.Minute, RetryDelay: 2 * time.Minute},
+		events: &Events{js: pub, prefix: "extraction"},
+	}
+	d, _ := src.Receive(context.Background())
+	d.Done(nil, &handler.JobError{Status: http.StatusServiceUnavailable, Err: errors.New("memory budget exhausted")})
+
+	if msg.acked || msg.nakDelay != 2*time.Minute {
+		t.Errorf("acked=%v nak delay=%v", msg.acked, msg.nakDelay)
+	}
+	if len(pub.msgs) != 0 {
+		t.Errorf("published %d events for a job that will be retried", len(pub.msgs))
+	}
+}
+
+func TestSource_InvalidRequestIsAcked(t *testing.T) {
+	msg := &fakeMsg{data: []byte(`{}`)}
+	pub := &fakePublisher{}
+	src := &Source{cons: &fakeConsumer{msgs: []*fakeMsg{msg}}, events: &Events{js: pub, prefix: "extraction"}}
+	d, _ := src.Receive(context.Background())
+	d.Done(nil, &handler.JobError{Status: http.StatusBadRequest, Err: errors.New("ad_id is required")})
+
+	if !msg.acked {
+		t.Error("invalid request not acked")
+	}
+	var ev JobEvent
+	json.Unmarshal(pub.msgs[0].data, &ev)
+	if ev.Event != "extraction.failed" || ev.Error != "ad_id is required" {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestSource_CompletionFailureRedelivers(t *testing.T) {
+	msg := &fakeMsg{data: []byte(`{"ad_id":"ad1"}`)}
+	src := &Source{
+		cons:   &fakeConsumer{msgs: []*fakeMsg{msg}},
+		opts:   SourceOptions{RetryDelay: time.Minute},
+		events: &Events{js: &fakePublisher{err: errors.New("no responders")}, prefix: "extraction"},
+	}
+	d, _ := src.Receive(context.Background())
+	d.Done(&handler.ExtractResponse{AdID: "ad1"}, nil)
+	if msg.acked || msg.nakDelay != time.Minute {
+		t.Errorf("acked=%v nak delay=%v", msg.acked, msg.nakDelay)
+	}
+}
+
+func TestSource_KeepsLongJobsAlive(t *testing.T) {
+	msg := &fakeMsg{data: []byte(`{"ad_id":"ad1"}`)}
+	src := &Source{cons: &fakeConsumer{msgs: []*fakeMsg{msg}}, opts: SourceOptions{AckWait: 2 * time.Second}}
+	d, _ := src.Receive(context.Background())
+	time.Sleep(2500 * time.Millisecond)
+	d.Done(&handler.ExtractResponse{AdID: "ad1"}, nil)
+
+	msg.mu.Lock()
+	defer msg.mu.Unlock()
+	if msg.progress < 2 {
+		t.Errorf("reported in progress %d times, want 2", msg.progress)
+	}
+}
+
+func TestSource_ReceiveStopsWithContext(t *testing.T) {
+	src := &Source{cons: &fakeConsumer{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := src.Receive(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Receive = %v", err)
+	}
+}
+
+func TestEvents_StreamDone(t *testing.T) {
+	pub := &fakePublisher{}
+	(&Events{js: pub, prefix: "extraction"}).StreamDone("ad1", handler.StreamResult{Stream: "asr", Status: "success", ResultCount: 3})
+
+	if len(pub.msgs) != 1 || pub.msgs[0].subject != "extraction.stream.asr" {
+		t.Fatalf("published %+v", pub.msgs)
+	}
+	var ev map[string]any
+	json.Unmarshal(pub.msgs[0].data, &ev)
+	if ev["event"] != "stream.completed" || ev["ad_id"] != "ad1" || ev["stream"] != "asr" || ev["result_count"] != 3.0 {
+		t.Errorf("event = %v", ev)
+	}
+
+	var none *Events
+	none.StreamDone("ad1", handler.StreamResult{Stream: "asr"}) // must not panic
+}
@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxListed bounds the ads or errors listed in one message; the rest are
+// counted.
+const maxListed = 10
+
+// maxTracked bounds the ads whose failures are remembered, since an ad that
+// fails once may never be resubmitted. Past it, counting starts over.
+const maxTracked = 10000
+
+// flavor is the markup of a chat service's incoming webhooks.
+type flavor struct {
+	field string // JSON field holding the message
+	limit int    // longest message accepted, in characters
+	bold  func(string) string
+	link  func(text, url string) string
+}
+
+var (
+	slack = flavor{
+		field: "text",
+		limit: 3000,
+		bold:  func(s string) string { return "*" + s + "*" },
+		link:  func(text, u string) string { return "<" + u + "|" + text + ">" },
+	}
+	discord = flavor{
+		field: "content",
+		limit: 2000,
+		bold:  func(s string) string { return "**" + s + "**" },
+		link:  func(text, u string) string { return "[" + text + "](" + u + ")" },
+	}
+)
+
+// flavorFor picks Discord markup for Discord webhooks and Slack's, which
+// Mattermost and most other services also accept, for everything else.
+func flavorFor(webhookURL string) flavor {
+	u, err := url.Parse(webhookURL)
+	if err == nil && (strings.HasSuffix(u.Hostname(), "discord.com") || strings.HasSuffix(u.Hostname(), "discordapp.com")) {
+		return discord
+	}
+	return slack
+}
+
+// Notifier posts alerts to a Slack or Discord incoming webhook. A nil
+// *Notifier, as returned by New without a URL, does nothing.
+type Notifier struct {
+	url       string
+	publicURL string
+	threshold int
+	flavor    flavor
+	client    *http.Client
+
+	mu       sync.Mutex
+	failures map[string][]string // ad -> errors of its consecutive failed jobs
+}
+
+// New posts to webhookURL, linking results through the web UI at publicURL
+// if set. An ad is reported once threshold of its jobs in a row have failed.
+func New(webhookURL, publicURL string, threshold int) *Notifier {
+	if webhookURL == "" {
+		return nil
+	}
+	return &Notifier{
+		url:       webhookURL,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		threshold: max(threshold, 1),
+		flavor:    flavorFor(webhookURL),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		failures:  make(map[string][]string),
+	}
+}
+
+// JobFinished records a job's outcome: errs is empty for a job that
+// succeeded. When an ad's consecutive failures reach the threshold they are
+// reported in the background and the count starts over.
+func (n *Notifier) JobFinished(adID string, errs []string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if len(errs) == 0 {
+		delete(n.failures, adID)
+		n.mu.Unlock()
+		return
+	}
+	if _, ok := n.failures[adID]; !ok && len(n.failures) >= maxTracked {
+		clear(n.failures)
+	}
+	n.failures[adID] = append(n.failures[adID], strings.Join(errs, "; "))
+	history := n.failures[adID]
+	if len(history) < n.threshold {
+		n.mu.Unlock()
+		return
+	}
+	delete(n.failures, adID)
+	n.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s — %d jobs in a row failed\n",
+		n.flavor.bold(fmt.Sprintf("Extraction failing for ad %s", adID)), len(history))
+	fmt.Fprintf(&b, "Last error: %s", history[len(history)-1])
+	if link := n.adLink(adID); link != "" {
+		b.WriteString("\n" + link)
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		n.post(ctx, b.String())
+	}()
+}
+
+// Batch summarises a finished batch of jobs, such as a backfill run.
+type Batch struct {
+	Name        string
+	Interrupted bool
+	Succeeded   int
+	Failed      int
+	Skipped     int
+	Errors      map[string]string // failed ad -> error
+	Duration    time.Duration
+}
+
+// BatchCompleted reports a finished batch and waits for the message to be
+// sent, so a command can call it just before exiting.
+func (n *Notifier) BatchCompleted(ctx context.Context, b Batch) {
+	if n == nil {
+		return
+	}
+	state := "finished"
+	if b.Interrupted {
+		state = "interrupted"
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s in %s: %d succeeded, %d failed, %d already done",
+		n.flavor.bold(b.Name+" "+state), b.Duration.Round(time.Second), b.Succeeded, b.Failed, b.Skipped)
+
+	ids := make([]string, 0, len(b.Errors))
+	for id := range b.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for i, id := range ids {
+		if i == maxListed {
+			fmt.Fprintf(&s, "\n…and %d more", len(ids)-maxListed)
+			break
+		}
+		ad := id
+		if n.publicURL != "" {
+			ad = n.flavor.link(id, n.uiURL(id))
+		}
+		fmt.Fprintf(&s, "\n• %s: %s", ad, b.Errors[id])
+	}
+	n.post(ctx, s.String())
+}
+
+// adLink links to the ad in the web UI, if the service's address is known.
+func (n *Notifier) adLink(adID string) string {
+	if n.publicURL == "" {
+		return ""
+	}
+	return n.flavor.link("View results", n.uiURL(adID))
+}
+
+func (n *Notifier) uiURL(adID string) string {
+	return n.publicURL + "/ui/?ad_id=" + url.QueryEscape(adID)
+}
+
+// post sends text, cut to the service's limit. Alerts are best effort:
+// failures are only logged.
+func (n *Notifier) post(ctx context.Context, text string) {
+	if r := []rune(text); len(r) > n.flavor.limit {
+		text = string(r[:n.flavor.limit-1]) + "…"
+	}
+	body, _ := json.Marshal(map[string]string{n.flavor.field: text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("WARN: notify: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		log.Printf("WARN: notify: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("WARN: notify: webhook answered %s", resp.Status)
+	}
+}
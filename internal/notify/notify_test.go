@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receiver collects posted messages under field.
+func receiver(t *testing.T, field string) (*httptest.Server, chan string) {
+	t.Helper()
+	got := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got <- body[field]
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+func TestJobFinished_ReportsRepeatedFailures(t *testing.T) {
+	server, got := receiver(t, "text")
+	n := New(server.URL, "https://extract.example.com/", 3)
+
+	n.JobFinished("ad-1", []string{"asr: deepgram 500"})
+	n.JobFinished("ad-1", nil) // a success resets the count
+	n.JobFinished("ad-1", []string{"asr: deepgram 500"})
+	n.JobFinished("ad-2", []string{"vlm: timeout"})
+	n.JobFinished("ad-1", []string{"asr: deepgram 500"})
+	n.JobFinished("ad-1", []string{"asr: deepgram 502", "vlm: quota"})
+
+	select {
+	case msg := <-got:
+		for _, want := range []string{"*Extraction failing for ad ad-1*", "3 jobs in a row", "asr: deepgram 502; vlm: quota", "<https://extract.example.com/ui/?ad_id=ad-1|View results>"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message %q does not contain %q", msg, want)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message posted")
+	}
+	select {
+	case msg := <-got:
+		t.Errorf("unexpected second message %q", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBatchCompleted_Discord(t *testing.T) {
+	server, got := receiver(t, "content")
+	n := New(server.URL, "", 1)
+	n.flavor = discord
+
+	errs := map[string]string{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		errs[id] = "boom"
+	}
+	n.BatchCompleted(context.Background(), Batch{Name: "Backfill", Succeeded: 40, Failed: 12, Errors: errs, Duration: 90 * time.Second})
+
+	msg := <-got
+	for _, want := range []string{"**Backfill finished** in 1m30s: 40 succeeded, 12 failed", "• a: boom", "…and 2 more"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, "• k:") {
+		t.Errorf("message lists more than %d ads: %q", maxListed, msg)
+	}
+}
+
+func TestFlavorFor(t *testing.T) {
+	if f := flavorFor("https://discord.com/api/webhooks/1/abc"); f.field != "content" {
+		t.Errorf("discord webhook got field %q", f.field)
+	}
+	if f := flavorFor("https://hooks.slack.com/services/T/B/x"); f.field != "text" {
+		t.Errorf("slack webhook got field %q", f.field)
+	}
+}
+
+func TestNil(t *testing.T) {
+	var n *Notifier = New("", "", 3)
+	n.JobFinished("ad-1", []string{"boom"})
+	n.BatchCompleted(context.Background(), Batch{})
+}
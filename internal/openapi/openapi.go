@@ -0,0 +1,278 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Operation is one endpoint of an API.
+type Operation struct {
+	Method  string
+	Path    string // e.g. "/jobs/{id}"; path parameters are strings
+	Summary string
+	Scope   string // the scope a credential needs; "" for open endpoints
+
+	Query []Param
+
+	// Request and Response are values of the JSON body types, e.g.
+	// client.ExtractRequest{}; nil for none. Status is the success status,
+	// 200 by default. ContentType replaces JSON for responses that are not,
+	// e.g. "text/plain"; Response is then ignored. Also lists other
+	// success responses by status, e.g. 202 for a request run async.
+	Request     any
+	Response    any
+	Status      int
+	ContentType string
+	Also        map[int]any
+}
+
+// Param is a query parameter.
+type Param struct {
+	Name        string
+	Type        string // "string" (default), "integer" or "boolean"
+	Description string
+}
+
+// Document is an OpenAPI 3.0 document, ready to be encoded as JSON.
+type Document map[string]any
+
+// New describes ops in an OpenAPI 3.0 document. Request and response
+// schemas are derived from the Go types by their JSON encoding: each named
+// struct becomes a component schema, and fields without omitempty are
+// required. Every operation answers errors with errorBody's type.
+func New(title, version string, ops []Operation, errorBody any) Document {
+	g := &generator{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+	errRef := g.schema(reflect.TypeOf(errorBody))
+
+	paths := map[string]map[string]any{}
+	for _, op := range ops {
+		responses := map[string]any{
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errRef}},
+			},
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case op.ContentType != "":
+			ok["content"] = map[string]any{op.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		case op.Response != nil:
+			ok["content"] = map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.Response))}}
+		}
+		responses[fmt.Sprint(status)] = ok
+		for status, body := range op.Also {
+			responses[fmt.Sprint(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(body))}},
+			}
+		}
+
+		o := map[string]any{
+			"operationId": operationID(op),
+			"summary":     op.Summary,
+			"responses":   responses,
+		}
+		var params []any
+		for _, name := range pathParams(op.Path) {
+			params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, p := range op.Query {
+			typ := p.Type
+			if typ == "" {
+				typ = "string"
+			}
+			params = append(params, map[string]any{"name": p.Name, "in": "query", "description": p.Description, "schema": map[string]any{"type": typ}})
+		}
+		if params != nil {
+			o["parameters"] = params
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.Request))}},
+			}
+		}
+		if op.Scope != "" {
+			o["description"] = fmt.Sprintf("Needs the %q scope.", op.Scope)
+			o["x-scope"] = op.Scope
+			o["security"] = []any{map[string]any{"bearer": []any{}}, map[string]any{"apiKey": []any{}}}
+		}
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = o
+	}
+
+	return Document{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// Handler serves the document as JSON, encoded once.
+func (d Document) Handler() http.Handler {
+	data, err := json.Marshal(d)
+	if err != nil {
+		panic(err) // only maps, slices and strings
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(?:\.\.\.)?\}`)
+
+func pathParams(route string) []string {
+	var names []string
+	for _, m := range pathParam.FindAllStringSubmatch(route, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+var versionSegment = regexp.MustCompile(`^/v[0-9]+/`)
+
+// operationID is e.g. "getJobsId" for GET /v1/jobs/{id}, for client
+// generators that name methods after it. A leading version is left out, so
+// that a new version's methods keep their names.
+func operationID(op Operation) string {
+	id := strings.ToLower(op.Method)
+	route := versionSegment.ReplaceAllString(op.Path, "/")
+	for _, part := range strings.FieldsFunc(route, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '_' || r == '.' }) {
+		id += upperFirst(part)
+	}
+	return id
+}
+
+type generator struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schema returns the schema of t, a reference for named structs, adding
+// those to g.schemas.
+func (g *generator) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.name(t)
+			g.names[t] = name
+			g.schemas[name] = map[string]any{} // placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	// Interfaces: anything
+	return map[string]any{}
+}
+
+// name is t's name, capitalized for generated clients, and qualified by
+// its package if another type has it.
+func (g *generator) name(t reflect.Type) string {
+	name := upperFirst(t.Name())
+	if _, taken := g.schemas[name]; taken {
+		name = upperFirst(path.Base(t.PkgPath())) + name
+	}
+	return name
+}
+
+func upperFirst(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// object is the schema of struct type t's fields, those of embedded
+// structs included, as encoding/json sees them.
+func (g *generator) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					add(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+	s := map[string]any{"type": "object", "properties": props}
+	if required != nil {
+		s["required"] = required
+	}
+	return s
+}
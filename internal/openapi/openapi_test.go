@@ -0,0 +1,84 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created_at"`
+}
+
+type item struct {
+	base
+	Name   string            `json:"name,omitempty"`
+	Tags   []string          `json:"tags"`
+	Labels map[string]int    `json:"labels,omitempty"`
+	Parent *item             `json:"parent,omitempty"`
+	Raw    json.RawMessage   `json:"raw,omitempty"`
+	Hidden string            `json:"-"`
+	Extra  map[string]string `json:"extra,omitempty"`
+}
+
+type problem struct {
+	Message string `json:"message"`
+}
+
+func TestNew(t *testing.T) {
+	doc := New("test", "1", []Operation{
+		{Method: "GET", Path: "/v2/items/{id}", Summary: "An item", Scope: "read", Response: item{}},
+		{Method: "POST", Path: "/items", Query: []Param{{Name: "dry", Type: "boolean"}}, Request: item{}, Response: item{}, Status: 201},
+	}, problem{})
+
+	// Through JSON, as clients see it
+	rec := httptest.NewRecorder()
+	doc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	var got struct {
+		Paths map[string]map[string]struct {
+			OperationID string           `json:"operationId"`
+			Parameters  []map[string]any `json:"parameters"`
+			Security    []any            `json:"security"`
+			Responses   map[string]any   `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	get := got.Paths["/v2/items/{id}"]["get"]
+	if get.OperationID != "getItemsId" || len(get.Parameters) != 1 || get.Parameters[0]["in"] != "path" || get.Security == nil {
+		t.Errorf("GET /v2/items/{id} = %+v", get)
+	}
+	post := got.Paths["/items"]["post"]
+	if post.Responses["201"] == nil || post.Responses["default"] == nil || len(post.Parameters) != 1 || post.Security != nil {
+		t.Errorf("POST /items = %+v", post)
+	}
+
+	s, ok := got.Components.Schemas["Item"]
+	if !ok {
+		t.Fatalf("schemas = %v", got.Components.Schemas)
+	}
+	slices.Sort(s.Required)
+	if want := []string{"created_at", "id", "tags"}; !slices.Equal(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+	if _, ok := s.Properties["Hidden"]; ok {
+		t.Error(`field tagged "-" described`)
+	}
+	if s.Properties["parent"]["$ref"] != "#/components/schemas/Item" || s.Properties["created_at"]["format"] != "date-time" {
+		t.Errorf("properties = %v", s.Properties)
+	}
+	if _, ok := got.Components.Schemas["Problem"]; !ok {
+		t.Error("error body not described")
+	}
+}
@@ -0,0 +1,157 @@
+package pool
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// rateWindow is the period over which the processing rate is measured.
+const rateWindow = time.Minute
+
+// Pool limits how many jobs run at once. Jobs beyond the limit wait in
+// priority order, first come first served within a priority. The limit can
+// be changed while jobs are running, e.g. by an autoscaler, and queue depth
+// and throughput are exposed for scaling decisions.
+type Pool struct {
+	mu        sync.Mutex
+	workers   int
+	running   int
+	waiting   []waiter // highest priority first
+	completed uint64
+	finished  []time.Time // completion times within rateWindow
+	now       func() time.Time
+}
+
+type waiter struct {
+	ready    chan struct{}
+	since    time.Time
+	priority int
+}
+
+// Stats is a snapshot of a pool's load.
+type Stats struct {
+	Workers       int     `json:"workers"`
+	Running       int     `json:"running"`
+	Queued        int     `json:"queued"`
+	Completed     uint64  `json:"completed"`
+	RatePerMinute float64 `json:"rate_per_minute"` // jobs finished in the last minute
+
+	// OldestWait is how long the job at the head of the queue, the next to
+	// start, has waited. It keeps growing if running jobs stop finishing.
+	OldestWait time.Duration `json:"oldest_wait_ns"`
+}
+
+// New returns a pool running at most workers jobs at a time (minimum 1).
+func New(workers int) *Pool {
+	return &Pool{workers: max(workers, 1), now: time.Now}
+}
+
+// Acquire waits for a free worker slot at priority 0. The returned func must
+// be called when the job finishes. If ctx ends first the job leaves the queue
+// and ctx's error is returned.
+func (p *Pool) Acquire(ctx context.Context) (release func(), err error) {
+	return p.AcquirePriority(ctx, 0)
+}
+
+// AcquirePriority is Acquire for a job of the given priority: queued jobs
+// with a higher priority start before it, whenever they arrived. Running jobs
+// are never interrupted.
+func (p *Pool) AcquirePriority(ctx context.Context, priority int) (release func(), err error) {
+	p.mu.Lock()
+	if p.running < p.workers && len(p.waiting) == 0 {
+		p.running++
+		p.mu.Unlock()
+		return p.releaseFunc(), nil
+	}
+	ready := make(chan struct{})
+	i, _ := slices.BinarySearchFunc(p.waiting, priority, func(w waiter, prio int) int {
+		// After every waiter of the same or higher priority
+		if w.priority >= prio {
+			return -1
+		}
+		return 1
+	})
+	p.waiting = slices.Insert(p.waiting, i, waiter{ready, p.now(), priority})
+	p.mu.Unlock()
+
+	select {
+	case <-ready:
+		return p.releaseFunc(), nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		select {
+		case <-ready:
+			// Granted a slot while giving up; hand it on
+			p.running--
+			p.dispatch()
+		default:
+			for i, w := range p.waiting {
+				if w.ready == ready {
+					p.waiting = append(p.waiting[:i], p.waiting[i+1:]...)
+					break
+				}
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.running--
+			p.completed++
+			p.finished = append(p.finished, p.now())
+			p.dispatch()
+		})
+	}
+}
+
+// dispatch starts queued jobs while slots are free. Caller holds p.mu.
+func (p *Pool) dispatch() {
+	for p.running < p.workers && len(p.waiting) > 0 {
+		close(p.waiting[0].ready)
+		p.waiting = p.waiting[1:]
+		p.running++
+	}
+}
+
+// SetWorkers changes the concurrency limit (minimum 1). Lowering it never
+// interrupts running jobs; new jobs wait until enough of them finish.
+func (p *Pool) SetWorkers(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers = max(n, 1)
+	p.dispatch()
+}
+
+// Stats returns the pool's current load.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := p.now().Add(-rateWindow)
+	i := 0
+	for i < len(p.finished) && !p.finished[i].After(cutoff) {
+		i++
+	}
+	p.finished = p.finished[i:]
+
+	s := Stats{
+		Workers:       p.workers,
+		Running:       p.running,
+		Queued:        len(p.waiting),
+		Completed:     p.completed,
+		RatePerMinute: float64(len(p.finished)) * float64(time.Minute) / float64(rateWindow),
+	}
+	if len(p.waiting) > 0 {
+		s.OldestWait = p.now().Sub(p.waiting[0].since)
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package pool
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_QueuesBeyondWorkers(t *testing.T) {
+	p := New(1)
+
+	release1, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, _ := p.Acquire(context.Background())
+		acquired <- release
+	}()
+
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+	select {
+	case <-acquired:
+		t.Fatal("second job should wait for a free worker")
+	default:
+	}
+
+	release1()
+	release2 := <-acquired
+	release2()
+
+	s := p.Stats()
+	if s.Running != 0 || s.Queued != 0 || s.Completed != 2 || s.RatePerMinute != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestPool_SetWorkersStartsQueuedJobs(t *testing.T) {
+	p := New(1)
+	release, _ := p.Acquire(context.Background())
+	defer release()
+
+	done := make(chan struct{})
+	go func() {
+		r, _ := p.Acquire(context.Background())
+		r()
+		close(done)
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+
+	p.SetWorkers(2)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("raising workers should start the queued job")
+	}
+}
+
+func TestPool_CancelLeavesQueue(t *testing.T) {
+	p := New(1)
+	release, _ := p.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := p.Acquire(ctx)
+		errc <- err
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	release()
+	if s := p.Stats(); s.Queued != 0 || s.Running != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestPool_PriorityOrder(t *testing.T) {
+	p := New(1)
+	release, _ := p.Acquire(context.Background())
+
+	order := make(chan int, 4)
+	var queued int
+	for _, prio := range []int{-1, 0, 5, 0} {
+		go func() {
+			r, _ := p.AcquirePriority(context.Background(), prio)
+			order <- prio
+			r()
+		}()
+		queued++
+		waitFor(t, func() bool { return p.Stats().Queued == queued })
+	}
+
+	release()
+	var got []int
+	for range 4 {
+		got = append(got, <-order)
+	}
+	if want := []int{5, 0, 0, -1}; !slices.Equal(got, want) {
+		t.Errorf("start order = %v, want %v", got, want)
+	}
+}
+
+func TestPool_RateWindow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := New(2)
+	p.now = func() time.Time { return now }
+
+	for range 3 {
+		r, _ := p.Acquire(context.Background())
+		r()
+	}
+	now = now.Add(2 * time.Minute)
+	if s := p.Stats(); s.RatePerMinute != 0 || s.Completed != 3 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestPool_OldestWait(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var elapsed atomic.Int64
+	p := New(1)
+	p.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+	release, _ := p.Acquire(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r, _ := p.Acquire(context.Background())
+		r()
+		close(done)
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+
+	elapsed.Store(int64(5 * time.Minute))
+	if s := p.Stats(); s.OldestWait != 5*time.Minute {
+		t.Errorf("oldest wait = %v, want 5m", s.OldestWait)
+	}
+	release()
+	<-done
+	if s := p.Stats(); s.OldestWait != 0 {
+		t.Errorf("oldest wait with empty queue = %v, want 0", s.OldestWait)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,19 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/nikipaj1/video-description-pipeline/internal/bufpool"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
 )
 
 type Client struct {
-	s3     *s3.Client
-	bucket string
+	s3      *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	retry   retry.Policy
 }
 
 type KeyframeMeta struct {
@@ -31,10 +40,33 @@ type KeyframeMetadataFile struct {
 	Keyframes []KeyframeMeta `json:"keyframes"`
 }
 
-func NewClient(endpointURL, accessKeyID, secretAccessKey, bucket string) *Client {
+// ResultObject describes a result file written under ads/{id}/extraction/.
+type ResultObject struct {
+	AdID         string
+	Key          string
+	Name         string // file name, e.g. "asr_results.json"
+	LastModified time.Time
+}
+
+// AdObjects summarises what storage holds for one ad.
+type AdObjects struct {
+	AdID          string
+	VideoModified time.Time            // zero if the ad has no video.mp4
+	HasKeyframes  bool                 // keyframes/metadata.json exists
+	Results       map[string]time.Time // extraction result file name -> last modified
+}
+
+// NewClient creates an R2 client. httpClient may be nil to use the SDK's
+// default transport.
+func NewClient(endpointURL, accessKeyID, secretAccessKey, bucket string, httpClient *http.Client) *Client {
 	cfg := aws.Config{
 		Region:      "auto",
 		Credentials: credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+		// Retries are handled by c.retry so all providers share one policy
+		Retryer: func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	if httpClient != nil {
+		cfg.HTTPClient = httpClient
 	}
 
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
@@ -42,100 +74,524 @@ func NewClient(endpointURL, accessKeyID, secretAccessKey, bucket string) *Client
 		o.BaseEndpoint = &endpointURL
 	})
 
-	return &Client{s3: client, bucket: bucket}
+	return &Client{
+		s3:      client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
+		retry:   retry.Default,
+	}
 }
 
-// DownloadVideo downloads the raw video bytes from R2.
-func (c *Client) DownloadVideo(ctx context.Context, adID string) ([]byte, error) {
-	key := fmt.Sprintf("ads/%s/video.mp4", adID)
-	out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
+// SetRetryPolicy replaces the retry policy applied to every R2 call.
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retry = p
+}
+
+// get downloads an object's bytes, retrying transient failures including
+// ones that happen mid-body. The body is read through a pooled buffer and
+// copied out once at its final size.
+func (c *Client) get(ctx context.Context, key string) ([]byte, error) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if err := c.getInto(ctx, key, buf); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// ErrNotFound is wrapped into download errors for keys that do not exist.
+var ErrNotFound = errors.New("object not found")
+
+// notFound marks a 404 from R2 as ErrNotFound.
+func notFound(err error) error {
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && status.HTTPStatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
+// getInto is get writing into buf, which is reset before each attempt.
+func (c *Client) getInto(ctx context.Context, key string, buf *bytes.Buffer) error {
+	return notFound(retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: &c.bucket,
+			Key:    &key,
+		})
+		if err != nil {
+			return err
+		}
+		defer out.Body.Close()
+		return readBody(buf, out)
+	}))
+}
+
+// readBody reads an object body into buf, sized up front from Content-Length
+// so the buffer grows at most once.
+func readBody(buf *bytes.Buffer, out *s3.GetObjectOutput) error {
+	buf.Reset()
+	if n := aws.ToInt64(out.ContentLength); n > 0 {
+		// ReadFrom wants MinRead spare bytes before it detects EOF
+		buf.Grow(int(n) + bytes.MinRead)
+	}
+	_, err := buf.ReadFrom(out.Body)
+	return err
+}
+
+// put uploads bytes, retrying transient failures.
+func (c *Client) put(ctx context.Context, key string, body []byte, contentType string) error {
+	return retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		_, err := c.s3.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &c.bucket,
+			Key:         &key,
+			Body:        bytes.NewReader(body),
+			ContentType: &contentType,
+		})
+		return err
+	})
+}
+
+// listPages calls fn for every page of objects under prefix.
+func (c *Client) listPages(ctx context.Context, prefix string, fn func(*s3.ListObjectsV2Output)) error {
+	p := s3.NewListObjectsV2Paginator(c.s3, &s3.ListObjectsV2Input{
 		Bucket: &c.bucket,
-		Key:    &key,
+		Prefix: &prefix,
 	})
+	for p.HasMorePages() {
+		var page *s3.ListObjectsV2Output
+		err := retry.Do(ctx, c.retry, func(ctx context.Context) error {
+			var err error
+			page, err = p.NextPage(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fn(page)
+	}
+	return nil
+}
+
+// Ping checks that the bucket is reachable with the configured credentials.
+// It also leaves a warm connection in the client's pool.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.s3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &c.bucket})
+	if err != nil {
+		return fmt.Errorf("head bucket %s: %w", c.bucket, err)
+	}
+	return nil
+}
+
+// Exists reports whether key is in the bucket.
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	err := notFound(retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		_, err := c.s3.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &c.bucket, Key: &key})
+		return err
+	}))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("download video %s: %w", key, err)
+		return false, fmt.Errorf("head %s: %w", key, err)
 	}
-	defer out.Body.Close()
-	return io.ReadAll(out.Body)
+	return true, nil
+}
+
+// OpenVideo opens the raw video for streaming. The caller must Close it.
+func (c *Client) OpenVideo(ctx context.Context, adID string) (*ObjectReader, error) {
+	key := fmt.Sprintf("ads/%s/video.mp4", adID)
+	r, err := c.OpenObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("open video %s: %w", key, err)
+	}
+	return r, nil
 }
 
 // DownloadKeyframeMetadata fetches the metadata.json written by entropy-frames-selector.
 func (c *Client) DownloadKeyframeMetadata(ctx context.Context, adID string) ([]KeyframeMeta, error) {
 	key := fmt.Sprintf("ads/%s/keyframes/metadata.json", adID)
-	out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: &c.bucket,
-		Key:    &key,
-	})
+	data, err := c.get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("download metadata %s: %w", key, err)
 	}
-	defer out.Body.Close()
 
 	var meta KeyframeMetadataFile
-	if err := json.NewDecoder(out.Body).Decode(&meta); err != nil {
+	if err := json.Unmarshal(data, &meta); err != nil {
 		return nil, fmt.Errorf("decode metadata: %w", err)
 	}
 	return meta.Keyframes, nil
 }
 
-// DownloadKeyframeImages downloads all keyframe JPEGs for an ad.
-// Returns a map of r2_key -> image bytes.
-func (c *Client) DownloadKeyframeImages(ctx context.Context, adID string, metas []KeyframeMeta) (map[string][]byte, error) {
-	images := make(map[string][]byte, len(metas))
-	for _, m := range metas {
-		out, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
-			Bucket: &c.bucket,
-			Key:    &m.R2Key,
+// UploadJSON uploads a JSON-serializable value to R2. Output is indented and
+// newline-terminated so result files diff cleanly between reruns.
+func (c *Client) UploadJSON(ctx context.Context, key string, data any) error {
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal json: %w", err)
+	}
+	body = append(body, '\n')
+	if err := c.put(ctx, key, body, "application/json"); err != nil {
+		return fmt.Errorf("upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// DownloadJSON fetches an object and decodes it as JSON into v.
+func (c *Client) DownloadJSON(ctx context.Context, key string, v any) error {
+	data, err := c.get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListExtractionResults walks every ads/{id}/extraction/ prefix and returns the
+// result files modified after since. A zero since returns everything.
+func (c *Client) ListExtractionResults(ctx context.Context, since time.Time) ([]ResultObject, error) {
+	var results []ResultObject
+	err := c.listPages(ctx, "ads/", func(page *s3.ListObjectsV2Output) {
+		for _, obj := range page.Contents {
+			// ads/{id}/extraction/{name}.json
+			parts := strings.Split(*obj.Key, "/")
+			if len(parts) != 4 || parts[2] != "extraction" || !strings.HasSuffix(parts[3], ".json") {
+				continue
+			}
+			var modified time.Time
+			if obj.LastModified != nil {
+				modified = *obj.LastModified
+			}
+			if !modified.After(since) {
+				continue
+			}
+			results = append(results, ResultObject{
+				AdID:         parts[1],
+				Key:          *obj.Key,
+				Name:         parts[3],
+				LastModified: modified,
+			})
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list results: %w", err)
+	}
+	return results, nil
+}
+
+// ListAds walks ads/ once and summarises every ad's video, keyframes and
+// extraction results, sorted by ad ID.
+func (c *Client) ListAds(ctx context.Context) ([]AdObjects, error) {
+	ads := make(map[string]*AdObjects)
+	err := c.listPages(ctx, "ads/", func(page *s3.ListObjectsV2Output) {
+		for _, obj := range page.Contents {
+			parts := strings.Split(*obj.Key, "/")
+			if len(parts) < 3 || parts[1] == "" {
+				continue
+			}
+			ad := ads[parts[1]]
+			if ad == nil {
+				ad = &AdObjects{AdID: parts[1], Results: make(map[string]time.Time)}
+				ads[parts[1]] = ad
+			}
+			ad.add(parts, aws.ToTime(obj.LastModified))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list ads: %w", err)
+	}
+
+	out := make([]AdObjects, 0, len(ads))
+	for _, ad := range ads {
+		out = append(out, *ad)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AdID < out[j].AdID })
+	return out, nil
+}
+
+// ListAdPage summarises, in key order, up to limit ads with extraction
+// results whose IDs start with prefix, after the ad after. next is the last
+// ad returned while more ads may follow, to be passed as after for the
+// next page, and empty at the end. Ads are listed by their prefixes, and
+// only the extraction results of those on the page are looked at, so only
+// AdID and Results are set.
+func (c *Client) ListAdPage(ctx context.Context, prefix, after string, limit int) (ads []AdObjects, next string, err error) {
+	in := &s3.ListObjectsV2Input{
+		Bucket:    &c.bucket,
+		Prefix:    aws.String("ads/" + prefix),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(int32(limit)),
+	}
+	if after != "" {
+		// "0" sorts right after "/", so past that ad's prefix
+		in.StartAfter = aws.String("ads/" + after + "0")
+	}
+	p := s3.NewListObjectsV2Paginator(c.s3, in)
+	for p.HasMorePages() {
+		var page *s3.ListObjectsV2Output
+		err := retry.Do(ctx, c.retry, func(ctx context.Context) error {
+			var err error
+			page, err = p.NextPage(ctx)
+			return err
 		})
 		if err != nil {
-			return nil, fmt.Errorf("download keyframe %s: %w", m.R2Key, err)
+			return nil, "", fmt.Errorf("list ads: %w", err)
 		}
-		data, err := io.ReadAll(out.Body)
-		out.Body.Close()
+		found, err := c.adResults(ctx, page.CommonPrefixes)
 		if err != nil {
-			return nil, fmt.Errorf("read keyframe %s: %w", m.R2Key, err)
+			return nil, "", err
+		}
+		for _, ad := range found {
+			if len(ad.Results) == 0 {
+				continue
+			}
+			if ads = append(ads, ad); len(ads) == limit {
+				return ads, ad.AdID, nil
+			}
 		}
-		images[m.R2Key] = data
 	}
-	return images, nil
+	return ads, "", nil
 }
 
-// ListKeyframeKeys lists all .jpg keys under ads/{adID}/keyframes/.
-func (c *Client) ListKeyframeKeys(ctx context.Context, adID string) ([]string, error) {
-	prefix := fmt.Sprintf("ads/%s/keyframes/", adID)
-	out, err := c.s3.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: &c.bucket,
-		Prefix: &prefix,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("list keyframes: %w", err)
+// adResultsConcurrency bounds the extraction listings adResults runs at
+// once.
+const adResultsConcurrency = 16
+
+// adResults lists the extraction results of the ads with the given
+// prefixes, e.g. "ads/ad1/", in their order.
+func (c *Client) adResults(ctx context.Context, prefixes []types.CommonPrefix) ([]AdObjects, error) {
+	ads := make([]AdObjects, len(prefixes))
+	errs := make([]error, len(prefixes))
+	sem := make(chan struct{}, adResultsConcurrency)
+	var wg sync.WaitGroup
+	for i, cp := range prefixes {
+		ads[i] = AdObjects{AdID: strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), "ads/"), "/"), Results: make(map[string]time.Time)}
+		if ads[i].AdID == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = c.listPages(ctx, "ads/"+ads[i].AdID+"/extraction/", func(page *s3.ListObjectsV2Output) {
+				for _, obj := range page.Contents {
+					ads[i].add(strings.Split(*obj.Key, "/"), aws.ToTime(obj.LastModified))
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("list ad results: %w", err)
+	}
+	return ads, nil
+}
+
+// add records one of the ad's objects, its key split at "/".
+func (ad *AdObjects) add(parts []string, modified time.Time) {
+	switch {
+	case len(parts) == 3 && parts[2] == "video.mp4":
+		ad.VideoModified = modified
+	case len(parts) == 4 && parts[2] == "keyframes" && parts[3] == "metadata.json":
+		ad.HasKeyframes = true
+	case len(parts) == 4 && parts[2] == "extraction" && strings.HasSuffix(parts[3], ".json"):
+		ad.Results[parts[3]] = modified
 	}
+}
 
+// ListKeys returns every key under prefix, sorted.
+func (c *Client) ListKeys(ctx context.Context, prefix string) ([]string, error) {
 	var keys []string
-	for _, obj := range out.Contents {
-		if strings.HasSuffix(*obj.Key, ".jpg") {
+	err := c.listPages(ctx, prefix, func(page *s3.ListObjectsV2Output) {
+		for _, obj := range page.Contents {
 			keys = append(keys, *obj.Key)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", prefix, err)
 	}
 	sort.Strings(keys)
 	return keys, nil
 }
 
-// UploadJSON uploads a JSON-serializable value to R2.
-func (c *Client) UploadJSON(ctx context.Context, key string, data any) error {
-	body, err := json.Marshal(data)
+// DownloadObject returns the raw bytes of an object.
+func (c *Client) DownloadObject(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.get(ctx, key)
 	if err != nil {
-		return fmt.Errorf("marshal json: %w", err)
+		return nil, fmt.Errorf("download %s: %w", key, err)
 	}
-	contentType := "application/json"
-	_, err = c.s3.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      &c.bucket,
-		Key:         &key,
-		Body:        bytes.NewReader(body),
-		ContentType: &contentType,
+	return data, nil
+}
+
+// DownloadObjectTo reads an object into buf, replacing its contents. With a
+// buffer from bufpool this avoids allocating per download on hot paths such
+// as keyframe fetches.
+func (c *Client) DownloadObjectTo(ctx context.Context, key string, buf *bytes.Buffer) error {
+	if err := c.getInto(ctx, key, buf); err != nil {
+		return fmt.Errorf("download %s: %w", key, err)
+	}
+	return nil
+}
+
+// ErrNotModified is returned by DownloadObjectIfNoneMatch when the object's
+// ETag still matches the one the caller already has.
+var ErrNotModified = errors.New("not modified")
+
+// DownloadObjectIfNoneMatch returns an object's bytes and current ETag unless
+// the ETag equals etag, in which case it returns ErrNotModified without
+// transferring the body. An empty etag always downloads.
+func (c *Client) DownloadObjectIfNoneMatch(ctx context.Context, key, etag string) ([]byte, string, error) {
+	in := &s3.GetObjectInput{Bucket: &c.bucket, Key: &key}
+	if etag != "" {
+		in.IfNoneMatch = &etag
+	}
+
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	var current string
+	err := retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		out, err := c.s3.GetObject(ctx, in)
+		if err != nil {
+			return err
+		}
+		defer out.Body.Close()
+		current = aws.ToString(out.ETag)
+		return readBody(buf, out)
 	})
+
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && status.HTTPStatusCode() == http.StatusNotModified {
+		return nil, etag, ErrNotModified
+	}
 	if err != nil {
+		return nil, "", fmt.Errorf("download %s: %w", key, notFound(err))
+	}
+	return bytes.Clone(buf.Bytes()), current, nil
+}
+
+// UploadObject uploads raw bytes with the given content type.
+func (c *Client) UploadObject(ctx context.Context, key string, body []byte, contentType string) error {
+	if err := c.put(ctx, key, body, contentType); err != nil {
 		return fmt.Errorf("upload %s: %w", key, err)
 	}
 	return nil
 }
+
+// UploadFrom uploads what body holds, e.g. a file too large to keep in
+// memory. body is rewound before each attempt.
+func (c *Client) UploadFrom(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	err := retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := c.s3.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &c.bucket,
+			Key:         &key,
+			Body:        body,
+			ContentType: &contentType,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes key. Deleting a key that does not exist is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	err := retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		_, err := c.s3.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &c.bucket, Key: &key})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// PresignGet returns a URL that allows an unauthenticated GET of key until ttl
+// elapses.
+func (c *Client) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := c.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: &c.bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// ObjectReader streams an object's body. Seeking re-issues a ranged GET, so a
+// consumer that needs to retry can rewind to the start without the object
+// ever being buffered in memory.
+type ObjectReader struct {
+	c    *Client
+	ctx  context.Context
+	key  string
+	size int64
+	body io.ReadCloser
+}
+
+// OpenObject starts a GET for key and returns a reader over its body.
+func (c *Client) OpenObject(ctx context.Context, key string) (*ObjectReader, error) {
+	r := &ObjectReader{c: c, ctx: ctx, key: key}
+	if err := r.open(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ObjectReader) open(offset int64) error {
+	in := &s3.GetObjectInput{Bucket: &r.c.bucket, Key: &r.key}
+	if offset > 0 {
+		rng := fmt.Sprintf("bytes=%d-", offset)
+		in.Range = &rng
+	}
+	return notFound(retry.Do(r.ctx, r.c.retry, func(ctx context.Context) error {
+		out, err := r.c.s3.GetObject(ctx, in)
+		if err != nil {
+			return err
+		}
+		if offset == 0 && out.ContentLength != nil {
+			r.size = *out.ContentLength
+		}
+		r.body = out.Body
+		return nil
+	}))
+}
+
+// Size is the object's length in bytes.
+func (r *ObjectReader) Size() int64 { return r.size }
+
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.body == nil {
+		return 0, fmt.Errorf("read %s: reader closed", r.key)
+	}
+	return r.body.Read(p)
+}
+
+// Seek supports only absolute offsets (io.SeekStart).
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart || offset < 0 {
+		return 0, fmt.Errorf("seek %s: only absolute offsets are supported", r.key)
+	}
+	r.Close()
+	if err := r.open(offset); err != nil {
+		return 0, fmt.Errorf("reopen %s: %w", r.key, err)
+	}
+	return offset, nil
+}
+
+func (r *ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
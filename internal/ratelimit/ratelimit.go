@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter gates provider calls against requests-per-minute and
+// tokens-per-minute budgets. Wait blocks until the call may proceed.
+type Limiter interface {
+	Wait(ctx context.Context, tokens int) error
+}
+
+// New returns a limiter for the given budgets. A zero budget is unlimited;
+// if both are zero New returns nil. With a Redis URL the budget is shared by
+// every process using the same name, otherwise it is process-wide.
+func New(name string, rpm, tpm int, redisURL string) Limiter {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	local := NewLocal(rpm, tpm)
+	if redisURL == "" {
+		return local
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.Printf("WARN: invalid REDIS_URL, %s rate limit is process-local: %v", name, err)
+		return local
+	}
+	return &Redis{
+		client:   redis.NewClient(opts),
+		name:     name,
+		rpm:      rpm,
+		tpm:      tpm,
+		fallback: local,
+	}
+}
+
+// Local is a pair of token buckets refilled continuously. Each bucket holds
+// up to one minute of budget.
+type Local struct {
+	mu       sync.Mutex
+	rpm, tpm float64
+	reqs     float64 // available requests
+	toks     float64 // available tokens
+	last     time.Time
+	now      func() time.Time
+}
+
+func NewLocal(rpm, tpm int) *Local {
+	l := &Local{rpm: float64(rpm), tpm: float64(tpm), now: time.Now}
+	l.reqs, l.toks = l.rpm, l.tpm
+	l.last = l.now()
+	return l
+}
+
+func (l *Local) Wait(ctx context.Context, tokens int) error {
+	for {
+		wait := l.reserve(tokens)
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Allow is Wait without the waiting: it takes budget for one call and
+// returns 0 if there is enough, or else how long until there will be.
+func (l *Local) Allow(tokens int) time.Duration {
+	return l.reserve(tokens)
+}
+
+// reserve takes budget for one call if available and returns 0, otherwise it
+// returns how long until enough budget will have refilled.
+func (l *Local) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	elapsed := now.Sub(l.last).Minutes()
+	l.last = now
+	l.reqs = math.Min(l.rpm, l.reqs+elapsed*l.rpm)
+	l.toks = math.Min(l.tpm, l.toks+elapsed*l.tpm)
+
+	// A single call larger than the whole budget is let through once the
+	// bucket is full rather than blocking forever.
+	need := math.Min(float64(tokens), l.tpm)
+
+	var waitMin float64
+	if l.rpm > 0 && l.reqs < 1 {
+		waitMin = math.Max(waitMin, (1-l.reqs)/l.rpm)
+	}
+	if l.tpm > 0 && l.toks < need {
+		waitMin = math.Max(waitMin, (need-l.toks)/l.tpm)
+	}
+	if waitMin > 0 {
+		return max(time.Duration(waitMin*float64(time.Minute)), time.Millisecond)
+	}
+
+	if l.rpm > 0 {
+		l.reqs--
+	}
+	if l.tpm > 0 {
+		l.toks -= need
+	}
+	return 0
+}
+
+// Redis enforces the budgets cluster-wide with per-minute fixed windows.
+// If Redis is unreachable it falls back to the process-local limiter so
+// jobs keep flowing.
+type Redis struct {
+	client   *redis.Client
+	name     string
+	rpm, tpm int
+	fallback *Local
+
+	warnOnce sync.Once
+}
+
+// reserveScript atomically admits a call if both windows have room.
+var reserveScript = redis.NewScript(`
+local reqs = tonumber(redis.call('GET', KEYS[1]) or '0')
+local toks = tonumber(redis.call('GET', KEYS[2]) or '0')
+local rpm, tpm, need = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+if (rpm > 0 and reqs + 1 > rpm) or (tpm > 0 and toks > 0 and toks + need > tpm) then
+  return 0
+end
+redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], 120)
+redis.call('INCRBY', KEYS[2], need)
+redis.call('EXPIRE', KEYS[2], 120)
+return 1
+`)
+
+func (r *Redis) Wait(ctx context.Context, tokens int) error {
+	for {
+		now := time.Now()
+		window := now.Unix() / 60
+		keys := []string{
+			windowKey(r.name, "rpm", window),
+			windowKey(r.name, "tpm", window),
+		}
+		ok, err := reserveScript.Run(ctx, r.client, keys, r.rpm, r.tpm, tokens).Int()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.warnOnce.Do(func() {
+				log.Printf("WARN: redis rate limiter for %s unavailable, using local limit: %v", r.name, err)
+			})
+			return r.fallback.Wait(ctx, tokens)
+		}
+		if ok == 1 {
+			return nil
+		}
+
+		next := time.Unix((window+1)*60, 0)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(next.Sub(now)):
+		}
+	}
+}
+
+func windowKey(name, kind string, window int64) string {
+	return "ratelimit:" + name + ":" + kind + ":" + time.Unix(window*60, 0).UTC().Format("200601021504")
+}
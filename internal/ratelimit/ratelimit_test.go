@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLocal(rpm, tpm int) (*Local, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLocal(rpm, tpm)
+	l.now = func() time.Time { return now }
+	l.last = now
+	return l, &now
+}
+
+func TestLocal_RequestBudget(t *testing.T) {
+	l, now := newTestLocal(2, 0)
+
+	if w := l.reserve(100); w != 0 {
+		t.Fatalf("first call waited %v", w)
+	}
+	if w := l.reserve(100); w != 0 {
+		t.Fatalf("second call waited %v", w)
+	}
+	// Budget exhausted: one request refills every 30s
+	if w := l.reserve(100); w != 30*time.Second {
+		t.Errorf("third call wait = %v, want 30s", w)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if w := l.reserve(100); w != 0 {
+		t.Errorf("after refill wait = %v, want 0", w)
+	}
+}
+
+func TestLocal_TokenBudget(t *testing.T) {
+	l, now := newTestLocal(0, 1000)
+
+	if w := l.reserve(800); w != 0 {
+		t.Fatalf("first call waited %v", w)
+	}
+	// 200 left, need 500: 300 tokens at 1000/min = 18s
+	if w := l.reserve(500); w != 18*time.Second {
+		t.Errorf("wait = %v, want 18s", w)
+	}
+
+	*now = now.Add(18 * time.Second)
+	if w := l.reserve(500); w != 0 {
+		t.Errorf("after refill wait = %v, want 0", w)
+	}
+}
+
+func TestLocal_OversizedCallAdmittedWhenFull(t *testing.T) {
+	l, _ := newTestLocal(0, 100)
+	if w := l.reserve(5000); w != 0 {
+		t.Errorf("oversized call on full bucket waited %v", w)
+	}
+}
+
+func TestLocal_WaitHonoursContext(t *testing.T) {
+	l := NewLocal(1, 0)
+	l.Wait(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	if l := New("gemini", 0, 0, ""); l != nil {
+		t.Errorf("expected nil limiter, got %T", l)
+	}
+	if _, ok := New("gemini", 10, 0, "").(*Local); !ok {
+		t.Error("expected local limiter without redis")
+	}
+}
@@ -0,0 +1,98 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every secret removed from a string.
+const Mask = "[REDACTED]"
+
+// minSecretLen keeps placeholders such as "mock" from being treated as
+// secrets and masked wherever the word appears.
+const minSecretLen = 8
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// Secrets registers values, typically API keys and tokens from the
+// configuration, to be masked wherever they appear. Values shorter than
+// eight characters are ignored.
+func Secrets(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		if len(v) >= minSecretLen {
+			secrets = append(secrets, v)
+		}
+	}
+}
+
+// patterns find credentials whose value is not known in advance: secret
+// query parameters (including those of presigned URLs), credential headers
+// as Go prints them in errors or dumps, JSON credential fields, and the
+// shape of Google API keys.
+var patterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)([?&](?:key|api_key|apikey|access_token|token|client_secret|x-amz-signature|x-amz-credential|x-amz-security-token)=)[^&\s"'<>]+`), "${1}" + Mask},
+	{regexp.MustCompile(`(?i)\b(authorization|proxy-authorization|x-api-key|x-goog-api-key|x-signature)("?\s*[:=]\s*\[?"?)(bearer |token |basic )?[^\s"',\]]+`), "${1}${2}${3}" + Mask},
+	{regexp.MustCompile(`(?i)("(?:api_key|apikey|api_token|access_token|refresh_token|client_secret|private_key|password|secret)"\s*:\s*")[^"]*`), "${1}" + Mask},
+	{regexp.MustCompile(`AIza[0-9A-Za-z_\-]{35}`), Mask},
+}
+
+// String returns s with every registered secret and credential-looking
+// value masked.
+func String(s string) string {
+	mu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	mu.RUnlock()
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// Err returns err with its message masked by String. The original stays
+// reachable through errors.Is and errors.As. Err(nil) is nil.
+func Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redacted{msg: msg, err: err}
+}
+
+type redacted struct {
+	msg string
+	err error
+}
+
+func (e *redacted) Error() string { return e.msg }
+func (e *redacted) Unwrap() error { return e.err }
+
+// Writer masks everything written to w, for log output:
+// log.SetOutput(redact.Writer(os.Stderr)). Each Write is masked on its
+// own, as the log package writes whole entries.
+func Writer(w io.Writer) io.Writer {
+	return writer{w}
+}
+
+type writer struct{ w io.Writer }
+
+func (lw writer) Write(p []byte) (int, error) {
+	if _, err := lw.w.Write([]byte(String(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,326 @@
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxRecordedString is the longest string kept verbatim in a recorded JSON
+// body. Longer ones, in practice base64 images, are replaced by a digest.
+const maxRecordedString = 1024
+
+// secretParams are query parameters never written to a fixture.
+var secretParams = []string{"key"}
+
+// keptHeaders are the only headers recorded. Everything else, credentials
+// included, is dropped.
+var keptHeaders = []string{"Content-Type", "Retry-After", "X-Goog-Upload-Url", "X-Goog-Upload-Status"}
+
+// Interaction is one recorded request/response pair, stored as a JSON file.
+type Interaction struct {
+	Provider string   `json:"provider"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body is the sanitized JSON body, if the request had one. Other bodies,
+	// such as videos, are only identified by BodySHA256.
+	Body       json.RawMessage `json:"body,omitempty"`
+	BodySHA256 string          `json:"body_sha256"`
+}
+
+type Response struct {
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`      // JSON bodies
+	BodyText string            `json:"body_text,omitempty"` // anything else
+}
+
+// Recorder is a RoundTripper that forwards requests and writes each
+// exchange to Dir as a sanitized fixture, numbered in the order responses
+// arrive.
+type Recorder struct {
+	Provider string
+	Dir      string
+	Next     http.RoundTripper
+
+	seq atomic.Int64
+}
+
+// NewRecorder records provider's traffic through next into dir, numbering
+// after any fixtures already there.
+func NewRecorder(provider, dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create record dir: %w", err)
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{Provider: provider, Dir: dir, Next: next}
+	r.seq.Store(int64(len(existing)))
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The request body is hashed as the transport reads it, so large
+	// uploads are streamed rather than buffered.
+	body := &capture{json: isJSON(req.Header.Get("Content-Type")), sum: sha256.New()}
+	if req.Body != nil {
+		body.ReadCloser = req.Body
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+
+	resp, err := r.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Provider: r.Provider,
+		Request:  Request{Method: req.Method, URL: sanitizeURL(req.URL)},
+		Response: Response{Status: resp.StatusCode, Headers: map[string]string{}},
+	}
+	in.Request.Body, in.Request.BodySHA256 = body.result()
+	for _, h := range keptHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			in.Response.Headers[h] = v
+		}
+	}
+	if json.Valid(respBody) && len(respBody) > 0 {
+		in.Response.Body = respBody
+	} else {
+		in.Response.BodyText = string(respBody)
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false) // keep URLs and prompts readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(in); err != nil {
+		return nil, fmt.Errorf("encode interaction: %w", err)
+	}
+	name := fmt.Sprintf("%04d-%s-%s.json", r.seq.Add(1), r.Provider, operation(req.URL))
+	if err := os.WriteFile(filepath.Join(r.Dir, name), data.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("write interaction: %w", err)
+	}
+	return resp, nil
+}
+
+// capture hashes a request body as it is read, and keeps it if it is JSON.
+type capture struct {
+	io.ReadCloser
+	json bool
+	buf  bytes.Buffer
+	sum  hash.Hash
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if c.json {
+		c.buf.Write(p[:n])
+	} else {
+		c.sum.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *capture) result() (json.RawMessage, string) {
+	if !c.json {
+		return nil, hex.EncodeToString(c.sum.Sum(nil))
+	}
+	return sanitizeJSON(c.buf.Bytes())
+}
+
+// Replayer is a RoundTripper that answers from recorded fixtures and never
+// touches the network.
+type Replayer struct {
+	mu   sync.Mutex
+	recs []*Interaction
+	used []bool
+}
+
+// Load reads every fixture in dir, in name order.
+func Load(dir string) (*Replayer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	r := &Replayer{}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var in Interaction
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", p, err)
+		}
+		r.recs = append(r.recs, &in)
+	}
+	if len(r.recs) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+	r.used = make([]bool, len(r.recs))
+	return r, nil
+}
+
+// RoundTrip answers with the first unused recording of the same method, URL
+// and body. Once all matching recordings are used, the last is repeated, so
+// retries and hedges of a recorded call still get an answer.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := &capture{json: isJSON(req.Header.Get("Content-Type")), sum: sha256.New()}
+	if req.Body != nil {
+		c.ReadCloser = req.Body
+		_, err := io.Copy(io.Discard, c)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, sum := c.result()
+	u := sanitizeURL(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, in := range r.recs {
+		if in.Request.Method != req.Method || in.Request.URL != u || in.Request.BodySHA256 != sum {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("replay: no recording of %s %s with body %.12s", req.Method, u, sum)
+	}
+	r.used[match] = true
+
+	in := r.recs[match]
+	resp := &http.Response{
+		StatusCode: in.Response.Status,
+		Status:     fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	for k, v := range in.Response.Headers {
+		resp.Header.Set(k, v)
+	}
+	body := []byte(in.Response.BodyText)
+	if len(in.Response.Body) > 0 {
+		// Undo the fixture's indentation
+		var buf bytes.Buffer
+		json.Compact(&buf, in.Response.Body)
+		body = buf.Bytes()
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// Unused returns the recordings never replayed, for tests asserting that a
+// run made every call it was recorded making.
+func (r *Replayer) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for i, in := range r.recs {
+		if !r.used[i] {
+			out = append(out, in.Request.Method+" "+in.Request.URL)
+		}
+	}
+	return out
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json"
+}
+
+// sanitizeURL drops secret query parameters and sorts the rest.
+func sanitizeURL(u *url.URL) string {
+	q := u.Query()
+	for _, p := range secretParams {
+		q.Del(p)
+	}
+	out := *u
+	out.RawQuery = q.Encode()
+	out.User = nil
+	return out.String()
+}
+
+// sanitizeJSON re-encodes body canonically, with long strings replaced by
+// their digest, and returns it with its hash. Bodies that are not valid
+// JSON are hashed as they are.
+func sanitizeJSON(body []byte) (json.RawMessage, string) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		sum := sha256.Sum256(body)
+		return nil, hex.EncodeToString(sum[:])
+	}
+	out, _ := json.Marshal(shorten(v))
+	sum := sha256.Sum256(out)
+	return out, hex.EncodeToString(sum[:])
+}
+
+func shorten(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = shorten(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = shorten(e)
+		}
+	case string:
+		if len(v) > maxRecordedString {
+			sum := sha256.Sum256([]byte(v))
+			return fmt.Sprintf("sha256:%s (%d bytes)", hex.EncodeToString(sum[:]), len(v))
+		}
+	}
+	return v
+}
+
+// operation names a fixture after the API call, e.g. "generateContent" for
+// /v1beta/models/gemini-2.0-flash:generateContent.
+func operation(u *url.URL) string {
+	p := u.Path
+	if i := strings.LastIndexAny(p, "/:"); i >= 0 {
+		p = p[i+1:]
+	}
+	if p == "" {
+		return "root"
+	}
+	return p
+}
@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"answer":"` + r.URL.Query().Get("n") + `"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, err := NewRecorder("gemini", dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rec}
+	image := strings.Repeat("A", 4000)
+	post := func(c *http.Client, n string) (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/x:generate?key=SECRET&n="+n,
+			strings.NewReader(`{"prompt":"describe","image":"`+image+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Token SECRET")
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), nil
+	}
+	for _, n := range []string{"1", "2"} {
+		if _, err := post(client, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "0001-gemini-generate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"SECRET", "session=", image} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("fixture contains %.20q", leak)
+		}
+	}
+	if !strings.Contains(string(data), "sha256:") {
+		t.Error("long string not replaced by its digest")
+	}
+
+	r, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: r}
+	for _, n := range []string{"2", "1", "1"} {
+		body, err := post(client, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"answer":"` + n + `"}`; body != want {
+			t.Errorf("replayed %q, want %q", body, want)
+		}
+	}
+	if unused := r.Unused(); len(unused) != 0 {
+		t.Errorf("unused = %v", unused)
+	}
+	if _, err := post(client, "3"); err == nil {
+		t.Error("unrecorded request answered")
+	}
+}
+
+func TestRecordStreamsNonJSONBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("plain"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, _ := NewRecorder("deepgram", dir, http.DefaultTransport)
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/listen", strings.NewReader("video bytes"))
+	req.Header.Set("Content-Type", "video/mp4")
+	resp, err := (&http.Client{Transport: rec}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	r, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.recs[0].Request.Body != nil || r.recs[0].Response.BodyText != "plain" {
+		t.Errorf("recorded %+v", r.recs[0])
+	}
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/listen", strings.NewReader("other video"))
+	req.Header.Set("Content-Type", "video/mp4")
+	if _, err := (&http.Client{Transport: r}).Do(req); err == nil {
+		t.Error("different body matched the recording")
+	}
+}
@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Policy controls how many times an operation is attempted and how long to
+// wait between attempts.
+type Policy struct {
+	MaxAttempts int           // total attempts including the first; <= 1 disables retries
+	BaseDelay   time.Duration // backoff before the second attempt, doubled each time
+	MaxDelay    time.Duration // cap on a single backoff
+}
+
+// Default is used when a provider has no explicit policy.
+var Default = Policy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 20 * time.Second}
+
+// HTTPError is a non-2xx provider response. It implements HTTPStatusCode so
+// it is classified the same way as AWS SDK response errors.
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *HTTPError) HTTPStatusCode() int { return e.StatusCode }
+
+// NewHTTPError builds an HTTPError from a response and its already-read body.
+func NewHTTPError(provider string, resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{Provider: provider, StatusCode: resp.StatusCode, Body: string(body)}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
+
+// IsRetryable reports whether err is likely transient: connection failures,
+// timeouts, 429 and 5xx responses. Context cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		code := status.HTTPStatusCode()
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the policy's
+// attempts are used up, or ctx ends. The last error from fn is returned.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	attempts := max(p.MaxAttempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !IsRetryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.backoff(attempt, err)):
+		}
+	}
+}
+
+// backoff returns a full-jitter delay for the given completed attempt,
+// honouring a provider's Retry-After hint when it is longer.
+func (p Policy) backoff(attempt int, err error) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	var d time.Duration
+	if ceiling > 0 {
+		d = rand.N(ceiling) + 1
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > d {
+		d = min(httpErr.RetryAfter, max(p.MaxDelay, d))
+	}
+	return d
+}
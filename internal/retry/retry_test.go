@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var fast = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fast, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return &HTTPError{Provider: "gemini", StatusCode: 503}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestDo_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fast, func(context.Context) error {
+		calls++
+		return &HTTPError{Provider: "gemini", StatusCode: 400, Body: "bad request"}
+	})
+	if err == nil || err.Error() != "gemini returned 400: bad request" {
+		t.Errorf("err = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestDo_ExhaustsAttempts(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), fast, func(context.Context) error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("err = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&HTTPError{StatusCode: 429}, true},
+		{&HTTPError{StatusCode: 500}, true},
+		{&HTTPError{StatusCode: 404}, false},
+		{fmt.Errorf("wrapped: %w", &HTTPError{StatusCode: 502}), true},
+		{context.Canceled, false},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), false},
+		{io.ErrUnexpectedEOF, true},
+		{errors.New("decode response: invalid character"), false},
+	} {
+		if got := IsRetryable(tc.err); got != tc.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestBackoff_BoundsAndRetryAfter(t *testing.T) {
+	p := Policy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt <= 6; attempt++ {
+		d := p.backoff(attempt, errors.New("x"))
+		if d <= 0 || d > time.Second {
+			t.Errorf("attempt %d backoff = %v, out of bounds", attempt, d)
+		}
+	}
+
+	resp := &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {"3"}}}
+	err := NewHTTPError("deepgram", resp, nil)
+	if err.RetryAfter != 3*time.Second {
+		t.Fatalf("RetryAfter = %v", err.RetryAfter)
+	}
+	// Retry-After beyond MaxDelay is capped
+	if d := p.backoff(1, err); d != time.Second {
+		t.Errorf("backoff with Retry-After = %v, want 1s", d)
+	}
+}
@@ -0,0 +1,291 @@
+package s3fake
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-memory S3-compatible object store, enough of the API for
+// the R2 client: path-style GET, HEAD, PUT and DELETE of objects,
+// ListObjectsV2 and HEAD of buckets. Buckets exist as soon as they are
+// named. Requests are not authenticated.
+type Server struct {
+	mu      sync.Mutex
+	objects map[string]object // by bucket + "/" + key
+}
+
+type object struct {
+	data        []byte
+	contentType string
+	etag        string
+	modified    time.Time
+}
+
+// New returns an empty store.
+func New() *Server {
+	return &Server{objects: map[string]object{}}
+}
+
+// Put stores an object, as a PUT would, and returns its ETag.
+func (s *Server) Put(bucket, key string, data []byte, contentType string) string {
+	sum := md5.Sum(data)
+	o := object{
+		data:        bytes.Clone(data),
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		modified:    time.Now().UTC(),
+	}
+	s.mu.Lock()
+	s.objects[bucket+"/"+key] = o
+	s.mu.Unlock()
+	return o.etag
+}
+
+// Get returns an object's bytes, or false if there is none.
+func (s *Server) Get(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[bucket+"/"+key]
+	return bytes.Clone(o.data), ok
+}
+
+// Keys lists a bucket's keys under prefix, sorted.
+func (s *Server) Keys(bucket, prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.objects {
+		if key, ok := strings.CutPrefix(k, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Load puts every file under dir into bucket, keyed by its path relative to
+// dir, e.g. dir/ads/a1/video.mp4 as ads/a1/video.mp4.
+func (s *Server) Load(bucket, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		s.Put(bucket, filepath.ToSlash(rel), data, http.DetectContentType(data))
+		return nil
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case bucket == "":
+		writeError(w, http.StatusBadRequest, "InvalidBucketName", "no bucket in path")
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucket)
+	case key == "":
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" on a bucket")
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.get(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		s.put(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, bucket+"/"+key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" on an object")
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	o, ok := s.objects[bucket+"/"+key]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	h := w.Header()
+	h.Set("ETag", o.etag)
+	h.Set("Last-Modified", o.modified.Format(http.TimeFormat))
+	h.Set("Content-Type", o.contentType)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == o.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, status := o.data, http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		if err != nil || start < 0 || start >= len(data) {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "only bytes=N- ranges within the object are supported")
+			return
+		}
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+		data, status = data[start:], http.StatusPartialContent
+	}
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	var body io.Reader = r.Body
+	// SDKs send checksummed uploads in aws-chunked encoding
+	if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") || r.Header.Get("X-Amz-Decoded-Content-Length") != "" {
+		body = dechunk(r.Body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	w.Header().Set("ETag", s.Put(bucket, key, data, r.Header.Get("Content-Type")))
+	w.WriteHeader(http.StatusOK)
+}
+
+// dechunk decodes an aws-chunked body: hex-sized chunks, each size
+// optionally followed by ";chunk-signature=...", ending with a zero-size
+// chunk and trailers.
+func dechunk(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+			size, err := strconv.ParseInt(sizeHex, 16, 64)
+			if err != nil {
+				pw.CloseWithError(fmt.Errorf("chunk size %q: %w", sizeHex, err))
+				return
+			}
+			if size == 0 {
+				pw.Close()
+				return
+			}
+			if _, err := io.CopyN(pw, br, size); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			br.ReadString('\n') // the chunk's trailing CRLF
+		}
+	}()
+	return pr
+}
+
+type listResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listEntry    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+}
+
+// list answers ListObjectsV2. Continuation tokens are the last key or
+// common prefix of the previous page.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	prefix, delim := q.Get("prefix"), q.Get("delimiter")
+	maxKeys := 1000
+	if n, err := strconv.Atoi(q.Get("max-keys")); err == nil && n > 0 {
+		maxKeys = min(n, 1000)
+	}
+	after := cmp.Or(q.Get("continuation-token"), q.Get("start-after"))
+
+	res := listResult{Name: bucket, Prefix: prefix, Delimiter: delim, MaxKeys: maxKeys}
+	last := ""
+	for _, key := range s.Keys(bucket, prefix) {
+		if key <= after || delim != "" && strings.HasSuffix(after, delim) && strings.HasPrefix(key, after) {
+			continue
+		}
+		if delim != "" {
+			if i := strings.Index(key[len(prefix):], delim); i >= 0 {
+				cp := key[:len(prefix)+i+len(delim)]
+				if cp == last {
+					continue
+				}
+				if res.KeyCount == maxKeys {
+					res.IsTruncated, res.NextContinuationToken = true, last
+					break
+				}
+				res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: cp})
+				res.KeyCount++
+				last = cp
+				continue
+			}
+		}
+		if res.KeyCount == maxKeys {
+			res.IsTruncated, res.NextContinuationToken = true, last
+			break
+		}
+		s.mu.Lock()
+		o, ok := s.objects[bucket+"/"+key]
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+		res.Contents = append(res.Contents, listEntry{
+			Key:          key,
+			LastModified: o.modified.Format(time.RFC3339Nano),
+			ETag:         o.etag,
+			Size:         len(o.data),
+		})
+		res.KeyCount++
+		last = key
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(res)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, message)
+}
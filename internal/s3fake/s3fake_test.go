@@ -0,0 +1,128 @@
+package s3fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/r2"
+)
+
+func newClient(t *testing.T) (*Server, *r2.Client) {
+	t.Helper()
+	s := New()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, r2.NewClient(server.URL, "key", "secret", "bucket", nil)
+}
+
+func TestServer_R2Client(t *testing.T) {
+	s, c := newClient(t)
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if err := c.UploadJSON(ctx, "ads/a1/extraction/summary.json", map[string]string{"combined": "ok"}); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	var got map[string]string
+	if err := c.DownloadJSON(ctx, "ads/a1/extraction/summary.json", &got); err != nil || got["combined"] != "ok" {
+		t.Fatalf("download = %v, %v", got, err)
+	}
+	if ok, err := c.Exists(ctx, "ads/a1/extraction/summary.json"); !ok || err != nil {
+		t.Errorf("exists = %v, %v", ok, err)
+	}
+	if ok, err := c.Exists(ctx, "ads/a1/missing.json"); ok || err != nil {
+		t.Errorf("exists missing = %v, %v", ok, err)
+	}
+	if _, err := c.DownloadObject(ctx, "ads/a1/missing.json"); !errors.Is(err, r2.ErrNotFound) {
+		t.Errorf("download missing: %v", err)
+	}
+
+	_, etag, err := c.DownloadObjectIfNoneMatch(ctx, "ads/a1/extraction/summary.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.DownloadObjectIfNoneMatch(ctx, "ads/a1/extraction/summary.json", etag); !errors.Is(err, r2.ErrNotModified) {
+		t.Errorf("conditional get: %v", err)
+	}
+
+	s.Put("bucket", "ads/a1/video.mp4", []byte("0123456789"), "video/mp4")
+	r, err := c.OpenObject(ctx, "ads/a1/video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "456789" || r.Size() != 10 {
+		t.Errorf("after seek = %q, size %d", rest, r.Size())
+	}
+}
+
+func TestServer_ListPages(t *testing.T) {
+	s, c := newClient(t)
+	for i := range 1005 {
+		s.Put("bucket", fmt.Sprintf("ads/a%04d/video.mp4", i), []byte("v"), "video/mp4")
+	}
+	s.Put("bucket", "admin/api_keys.json", []byte("{}"), "application/json")
+
+	ads, err := c.ListAds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1005 {
+		t.Errorf("listed %d ads, want 1005", len(ads))
+	}
+	if keys := s.Keys("bucket", "admin/"); len(keys) != 1 {
+		t.Errorf("keys = %v", keys)
+	}
+}
+
+func TestServer_ListAdPage(t *testing.T) {
+	s, c := newClient(t)
+	want := 0
+	for i := range 1005 {
+		s.Put("bucket", fmt.Sprintf("ads/a%04d/video.mp4", i), []byte("v"), "video/mp4")
+		s.Put("bucket", fmt.Sprintf("ads/a%04d/keyframes/frame_000.jpg", i), []byte("j"), "image/jpeg")
+		if i%3 == 0 {
+			s.Put("bucket", fmt.Sprintf("ads/a%04d/extraction/asr_results.json", i), []byte("{}"), "application/json")
+			want++
+		}
+	}
+
+	for _, limit := range []int{7, 1000} {
+		var got []string
+		after := ""
+		for {
+			ads, next, err := c.ListAdPage(context.Background(), "", after, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ad := range ads {
+				if _, ok := ad.Results["asr_results.json"]; !ok {
+					t.Fatalf("%s: results %v", ad.AdID, ad.Results)
+				}
+				got = append(got, ad.AdID)
+			}
+			if next == "" {
+				break
+			}
+			after = next
+		}
+		if len(got) != want || !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != want {
+			t.Errorf("limit %d: listed %d ads, want %d", limit, len(got), want)
+		}
+	}
+
+	ads, next, err := c.ListAdPage(context.Background(), "a100", "", 10)
+	if err != nil || len(ads) != 1 || ads[0].AdID != "a1002" || next != "" {
+		t.Errorf("prefix a100: %v, %q, %v", ads, next, err)
+	}
+}
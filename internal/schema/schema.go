@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"slices"
+	"strings"
+)
+
+// The artifacts directory holds a JSON Schema for each JSON artifact, named
+// after the artifact's file.
+//
+//go:embed artifacts/*.json
+var files embed.FS
+
+// node is the subset of JSON Schema the artifact schemas use: type,
+// properties, required, items, enum, minimum, maximum, minLength, and $ref to
+// another schema file.
+type node struct {
+	Ref        string           `json:"$ref,omitempty"`
+	Type       types            `json:"type,omitempty"`
+	Properties map[string]*node `json:"properties,omitempty"`
+	Required   []string         `json:"required,omitempty"`
+	Items      *node            `json:"items,omitempty"`
+	Enum       []any            `json:"enum,omitempty"`
+	Minimum    *float64         `json:"minimum,omitempty"`
+	Maximum    *float64         `json:"maximum,omitempty"`
+	MinLength  int              `json:"minLength,omitempty"`
+}
+
+// types is a schema's "type", a name or a list of them.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*t = types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+var schemas = func() map[string]*node {
+	entries, err := files.ReadDir("artifacts")
+	if err != nil {
+		panic(err)
+	}
+	m := map[string]*node{}
+	for _, e := range entries {
+		data, err := files.ReadFile(path.Join("artifacts", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var s node
+		if err := json.Unmarshal(data, &s); err != nil {
+			panic(fmt.Sprintf("schema %s: %v", e.Name(), err))
+		}
+		m[e.Name()] = &s
+	}
+	return m
+}()
+
+// maxErrors caps how many violations one error lists.
+const maxErrors = 10
+
+// Validate checks v, as it would be stored as file (e.g. "vlm_results.json"),
+// against the file's schema. Files without a schema always pass.
+func Validate(file string, v any) error {
+	s := schemas[file]
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var errs []error
+	s.check("", doc, &errs)
+	if len(errs) > maxErrors {
+		errs = append(errs[:maxErrors], fmt.Errorf("and %d more", len(errs)-maxErrors))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s does not match its schema: %w", file, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (s *node) check(at string, v any, errs *[]error) {
+	if s.Ref != "" {
+		ref := schemas[s.Ref]
+		if ref == nil {
+			*errs = append(*errs, fmt.Errorf("%s: unknown schema %s", where(at), s.Ref))
+			return
+		}
+		s = ref
+	}
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return is(t, v) }) {
+		*errs = append(*errs, fmt.Errorf("%s: %s is not %s", where(at), kind(v), strings.Join(s.Type, " or ")))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		*errs = append(*errs, fmt.Errorf("%s: %v is not one of %v", where(at), v, s.Enum))
+	}
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*errs = append(*errs, fmt.Errorf("%s: %v is less than %v", where(at), v, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*errs = append(*errs, fmt.Errorf("%s: %v is more than %v", where(at), v, *s.Maximum))
+		}
+	case string:
+		if len([]rune(v)) < s.MinLength {
+			*errs = append(*errs, fmt.Errorf("%s: shorter than %d characters", where(at), s.MinLength))
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.check(fmt.Sprintf("%s[%d]", at, i), item, errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fmt.Errorf("%s: missing", where(join(at, name))))
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+			if pv, ok := v[name]; ok {
+				s.Properties[name].check(join(at, name), pv, errs)
+			}
+		}
+	}
+}
+
+func is(typ string, v any) bool {
+	switch typ {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+func join(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func where(at string) string {
+	if at == "" {
+		return "document"
+	}
+	return at
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/streams"
+)
+
+func TestValidate_EmptyResults(t *testing.T) {
+	// results with nothing found, as streams produce them for silent or
+	// frameless ads
+	for file, v := range map[string]any{
+		"asr_results.json":    &streams.ASRResult{},
+		"vlm_results.json":    &streams.VLMResult{},
+		"people.json":         &streams.PeopleResult{},
+		"presenter.json":      &streams.PresenterResult{Format: "montage"},
+		"visual_stats.json":   &streams.VisualStatsResult{},
+		"content_rating.json": &streams.ContentRatingResult{Rating: "safe"},
+		"products.json":       &streams.ProductsResult{},
+		"cta_results.json":    &streams.CTAResult{},
+		"music.json":          &streams.MusicResult{Genre: "none", Mood: "none", Energy: "none"},
+		"hook_analysis.json":  &streams.HookResult{HookType: "other", Strength: "moderate"},
+		"entities.json":       &streams.EntitiesResult{},
+		"video_meta.json":     &streams.VideoMeta{},
+		"audio_analysis.json": &streams.AudioAnalysis{},
+		"timeline.json":       &streams.Timeline{},
+		"key_moments.json":    &streams.KeyMomentsResult{},
+		"summary.json":        &streams.SummaryResult{},
+	} {
+		if err := Validate(file, v); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestValidate_Results(t *testing.T) {
+	first := 1.5
+	for file, v := range map[string]any{
+		"asr_results.json": &streams.ASRResult{
+			DurationSec: 12,
+			Segments:    []streams.ASRSegment{{Start: 0, End: 2.5, Text: "Hi", Confidence: 0.93}},
+			Provenance:  &streams.Provenance{Provider: "deepgram", Model: "nova-3", Params: map[string]string{"utterances": "true"}},
+		},
+		"vlm_results.json": &streams.VLMResult{
+			Frames:        []streams.VLMFrame{{FrameIndex: 0, TimestampSec: 0, Description: "A kitchen"}},
+			SkippedFrames: []streams.SkippedFrame{{FrameIndex: 1, TimestampSec: 1.2, Reason: streams.SkipOverBudget}},
+		},
+		"people.json": &streams.PeopleResult{
+			Frames:         []streams.PeopleFrame{{FrameIndex: 0, TimestampSec: 1.5, PeopleCount: 1, Framing: "upper_body", Emotion: "happy"}, {FrameIndex: 1, Error: "timeout"}},
+			FirstPersonSec: &first,
+		},
+		"timeline.json": streams.BuildTimeline(
+			&streams.ASRResult{Segments: []streams.ASRSegment{{Start: 0, End: 2, Text: "Hi"}}},
+			&streams.VLMResult{Frames: []streams.VLMFrame{{FrameIndex: 0, TimestampSec: 0.5, Description: "A kitchen"}}},
+		),
+	} {
+		if err := Validate(file, v); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	err := Validate("asr_results.json", map[string]any{
+		"duration_sec": -1,
+		"segments":     []any{map[string]any{"start": 0, "end": "2", "text": "Hi", "confidence": 1.7}},
+		"provenance":   map[string]any{"model": "nova-3"},
+	})
+	if err == nil {
+		t.Fatal("invalid transcript passed")
+	}
+	for _, want := range []string{
+		"duration_sec: -1 is less than 0",
+		"segments[0].confidence: 1.7 is more than 1",
+		"segments[0].end: string is not number",
+		"provenance.provider: missing",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q lacks %q", err, want)
+		}
+	}
+
+	err = Validate("key_moments.json", map[string]any{"moments": []any{map[string]any{"type": "intro", "start": 0, "end": 1, "description": ""}}})
+	if err == nil || !strings.Contains(err.Error(), "moments[0].type: intro is not one of") {
+		t.Errorf("err = %v", err)
+	}
+	if err := Validate("key_moments.json", "moments"); err == nil || !strings.Contains(err.Error(), "document: string is not object") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidate_Unknown(t *testing.T) {
+	if err := Validate("transcript.srt", "1\n00:00:00,000 --> 00:00:02,000\nHi\n"); err != nil {
+		t.Errorf("file without a schema: %v", err)
+	}
+}
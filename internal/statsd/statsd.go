@@ -0,0 +1,68 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client pushes metrics to a StatsD server or a Datadog agent over UDP.
+// Sends are fire and forget: a missing agent loses metrics, never blocks a
+// job. A nil *Client, as returned by New without an address, does nothing.
+type Client struct {
+	conn      net.Conn
+	prefix    string
+	tags      []string
+	dogstatsd bool
+}
+
+// New sends to addr (host:port), prefixing every metric name with prefix.
+// tags ("env:prod") are added to every metric; like per-metric tags they
+// are sent only in DogStatsD format, as plain StatsD has no tags.
+func New(addr, prefix string, tags []string, dogstatsd bool) (*Client, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return &Client{conn: conn, prefix: prefix, tags: tags, dogstatsd: dogstatsd}, nil
+}
+
+// ParseTags splits a comma-separated tag list, dropping empty entries.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func (c *Client) Gauge(name string, value float64, tags ...string) {
+	c.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+func (c *Client) Count(name string, value int64, tags ...string) {
+	c.send(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+func (c *Client) Timing(name string, d time.Duration, tags ...string) {
+	c.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+func (c *Client) send(name, value, kind string, tags []string) {
+	if c == nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(c.prefix + name + ":" + value + "|" + kind)
+	if c.dogstatsd && len(c.tags)+len(tags) > 0 {
+		b.WriteString("|#" + strings.Join(append(append([]string(nil), c.tags...), tags...), ","))
+	}
+	c.conn.Write([]byte(b.String()))
+}
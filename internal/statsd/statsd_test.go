@@ -0,0 +1,75 @@
+package statsd
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *net.UDPConn, n int) []string {
+	var out []string
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for range n {
+		m, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, string(buf[:m]))
+	}
+	return out
+}
+
+func TestClient_DogStatsD(t *testing.T) {
+	srv := listen(t)
+	c, err := New(srv.LocalAddr().String(), "pipeline.", []string{"env:prod"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Gauge("queue_depth", 3)
+	c.Count("jobs", 1, "status:success")
+	c.Timing("job.duration", 1500*time.Millisecond)
+
+	got := read(t, srv, 3)
+	want := []string{
+		"pipeline.queue_depth:3|g|#env:prod",
+		"pipeline.jobs:1|c|#env:prod,status:success",
+		"pipeline.job.duration:1500|ms|#env:prod",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestClient_PlainStatsD(t *testing.T) {
+	srv := listen(t)
+	c, _ := New(srv.LocalAddr().String(), "", []string{"env:prod"}, false)
+	c.Count("jobs", 2, "status:error")
+	if got := read(t, srv, 1); got[0] != "jobs:2|c" {
+		t.Errorf("sent %q, want no tags", got[0])
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	c, err := New("", "", nil, true)
+	if c != nil || err != nil {
+		t.Fatalf("New = %v, %v", c, err)
+	}
+	c.Gauge("x", 1) // must not panic
+}
+
+func TestParseTags(t *testing.T) {
+	if got := ParseTags(" env:prod, ,tenant:acme,"); !slices.Equal(got, []string{"env:prod", "tenant:acme"}) {
+		t.Errorf("ParseTags = %q", got)
+	}
+}
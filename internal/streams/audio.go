@@ -0,0 +1,284 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoAudio is returned by AnalyzeAudio for a video without an audio track.
+var ErrNoAudio = errors.New("video has no audio track")
+
+const (
+	audioSampleRate = 11025 // enough for loudness envelopes and onsets
+	audioHop        = 110   // samples per analysis frame, ~10ms
+	silenceDBFS     = -45.0 // frames quieter than this count as silence
+
+	minTempoBPM = 60.0
+	maxTempoBPM = 180.0
+)
+
+// AudioAnalysis describes an ad's soundtrack.
+type AudioAnalysis struct {
+	DurationSec float64       `json:"duration_sec"`
+	Loudness    AudioLoudness `json:"loudness"`
+
+	// Shares of the ad's duration. Silence is anything under -45 dBFS.
+	// Speech comes from the transcript; music is sound outside speech,
+	// which includes sound effects. Without a transcript only silence is
+	// known.
+	SilenceRatio float64  `json:"silence_ratio"`
+	SpeechRatio  *float64 `json:"speech_ratio,omitempty"`
+	MusicRatio   *float64 `json:"music_ratio,omitempty"`
+
+	// Tempo is estimated from loudness onsets. Confidence (0-1) is low for
+	// speech-only or beatless audio, where TempoBPM and Beats mean little.
+	TempoBPM        float64   `json:"tempo_bpm,omitempty"`
+	TempoConfidence float64   `json:"tempo_confidence"`
+	Beats           []float64 `json:"beats,omitempty"` // seconds
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	active []bool // per analysis frame: louder than silenceDBFS
+}
+
+// AudioLoudness is the EBU R128 measurement of the whole track. Values are
+// nil for digital silence, where they are minus infinity.
+type AudioLoudness struct {
+	IntegratedLUFS *float64 `json:"integrated_lufs,omitempty"`
+	RangeLU        float64  `json:"range_lu"`
+	TruePeakDBFS   *float64 `json:"true_peak_dbfs,omitempty"`
+}
+
+// FFmpegAvailable reports whether ffmpeg is on the PATH.
+var FFmpegAvailable = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+})
+
+// AnalyzeAudio decodes the first audio track of input, a path or URL ffmpeg
+// can read, measuring loudness with ffmpeg's ebur128 filter and analysing a
+// mono downmix for silence and tempo. Speech and music shares are filled in
+// by AddSpeech once the transcript is known.
+func AnalyzeAudio(ctx context.Context, input string) (*AudioAnalysis, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostdin",
+		"-i", input,
+		"-map", "0:a:0",
+		"-af", fmt.Sprintf("ebur128=peak=true:framelog=quiet,aresample=%d", audioSampleRate),
+		"-ac", "1",
+		"-f", "s16le", "-",
+	)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "matches no streams") {
+			return nil, ErrNoAudio
+		}
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, tail(stderr.String(), 5))
+	}
+
+	samples := make([]int16, stdout.Len()/2)
+	binary.Read(bytes.NewReader(stdout.Bytes()), binary.LittleEndian, samples)
+
+	a := analyzePCM(samples)
+	a.Loudness = parseEBUR128(stderr.String())
+	a.Provenance = &Provenance{Provider: "ffmpeg", Model: "ebur128+onset-tempo"}
+	return a, nil
+}
+
+// AddSpeech splits the audible time into speech, per the transcript's
+// segments, and music.
+func (a *AudioAnalysis) AddSpeech(asr *ASRResult) {
+	if asr == nil || len(a.active) == 0 {
+		return
+	}
+	frameRate := float64(audioSampleRate) / audioHop
+	speech := make([]bool, len(a.active))
+	for _, s := range asr.Segments {
+		from := max(int(s.Start*frameRate), 0)
+		to := min(int(math.Ceil(s.End*frameRate)), len(speech))
+		for i := from; i < to; i++ {
+			speech[i] = true
+		}
+	}
+	var speechFrames, musicFrames int
+	for i, active := range a.active {
+		switch {
+		case speech[i]:
+			speechFrames++
+		case active:
+			musicFrames++
+		}
+	}
+	n := float64(len(a.active))
+	speechRatio, musicRatio := float64(speechFrames)/n, float64(musicFrames)/n
+	a.SpeechRatio, a.MusicRatio = &speechRatio, &musicRatio
+}
+
+// analyzePCM measures silence and tempo from mono samples at
+// audioSampleRate.
+func analyzePCM(samples []int16) *AudioAnalysis {
+	frameRate := float64(audioSampleRate) / audioHop
+	n := len(samples) / audioHop
+	a := &AudioAnalysis{
+		DurationSec: float64(len(samples)) / audioSampleRate,
+		active:      make([]bool, n),
+	}
+	if n == 0 {
+		return a
+	}
+
+	// Log energy per frame, and its rises as onset strength
+	logEnergy := make([]float64, n)
+	silent := 0
+	for i := range n {
+		var sum float64
+		for _, s := range samples[i*audioHop : (i+1)*audioHop] {
+			v := float64(s) / 32768
+			sum += v * v
+		}
+		rms := math.Sqrt(sum / audioHop)
+		db := 20 * math.Log10(max(rms, 1e-10))
+		logEnergy[i] = db
+		a.active[i] = db > silenceDBFS
+		if !a.active[i] {
+			silent++
+		}
+	}
+	a.SilenceRatio = float64(silent) / float64(n)
+
+	onset := make([]float64, n)
+	for i := 1; i < n; i++ {
+		onset[i] = max(logEnergy[i]-logEnergy[i-1], 0)
+	}
+	period, confidence := estimatePeriod(onset, frameRate)
+	if period == 0 {
+		return a
+	}
+	a.TempoBPM = math.Round(60*frameRate/period*10) / 10
+	a.TempoConfidence = math.Round(confidence*100) / 100
+	a.Beats = beatTimes(onset, period, frameRate)
+	return a
+}
+
+// estimatePeriod finds the beat period, in frames, as the strongest
+// autocorrelation of the onset envelope among lags in the tempo range.
+// Confidence is that autocorrelation relative to the envelope's energy.
+func estimatePeriod(onset []float64, frameRate float64) (float64, float64) {
+	minLag := int(60 * frameRate / maxTempoBPM)
+	maxLag := int(math.Ceil(60 * frameRate / minTempoBPM))
+	// At least four beats are needed to call it a tempo
+	if len(onset) < 4*maxLag {
+		return 0, 0
+	}
+
+	var mean float64
+	for _, v := range onset {
+		mean += v
+	}
+	mean /= float64(len(onset))
+	centred := make([]float64, len(onset))
+	var energy float64
+	for i, v := range onset {
+		centred[i] = v - mean
+		energy += centred[i] * centred[i]
+	}
+	if energy == 0 {
+		return 0, 0
+	}
+
+	ac := make([]float64, maxLag+2)
+	for lag := minLag - 1; lag <= maxLag+1; lag++ {
+		var sum float64
+		for i := lag; i < len(centred); i++ {
+			sum += centred[i] * centred[i-lag]
+		}
+		// Normalise for the shrinking overlap so long lags are not penalised
+		ac[lag] = sum / float64(len(centred)-lag)
+	}
+	best := minLag
+	for lag := minLag; lag <= maxLag; lag++ {
+		if ac[lag] > ac[best] {
+			best = lag
+		}
+	}
+	if ac[best] <= 0 {
+		return 0, 0
+	}
+
+	// Parabolic interpolation between neighbouring lags
+	period := float64(best)
+	if l, c, r := ac[best-1], ac[best], ac[best+1]; l-2*c+r != 0 {
+		period += 0.5 * (l - r) / (l - 2*c + r)
+	}
+	confidence := ac[best] / (energy / float64(len(centred)))
+	return period, min(confidence, 1)
+}
+
+// beatTimes places beats period frames apart at the phase that lands on the
+// most onset strength.
+func beatTimes(onset []float64, period, frameRate float64) []float64 {
+	bestPhase, bestScore := 0, -1.0
+	for phase := range int(period) {
+		var score float64
+		for t := float64(phase); int(math.Round(t)) < len(onset); t += period {
+			score += onset[int(math.Round(t))]
+		}
+		if score > bestScore {
+			bestPhase, bestScore = phase, score
+		}
+	}
+	var beats []float64
+	for t := float64(bestPhase); int(math.Round(t)) < len(onset); t += period {
+		beats = append(beats, math.Round(t/frameRate*1000)/1000)
+	}
+	return beats
+}
+
+var (
+	ebuIntegrated = regexp.MustCompile(`I:\s+(-?[0-9.]+|-inf) LUFS`)
+	ebuRange      = regexp.MustCompile(`LRA:\s+(-?[0-9.]+) LU\b`)
+	ebuPeak       = regexp.MustCompile(`Peak:\s+(-?[0-9.]+|-inf) dBFS`)
+)
+
+// parseEBUR128 reads the summary the ebur128 filter logs when it finishes.
+// The last match is used, in case per-frame lines were logged too.
+func parseEBUR128(log string) AudioLoudness {
+	last := func(re *regexp.Regexp) *float64 {
+		m := re.FindAllStringSubmatch(log, -1)
+		if m == nil {
+			return nil
+		}
+		v, err := strconv.ParseFloat(m[len(m)-1][1], 64)
+		if err != nil || math.IsInf(v, 0) {
+			return nil
+		}
+		return &v
+	}
+	l := AudioLoudness{
+		IntegratedLUFS: last(ebuIntegrated),
+		TruePeakDBFS:   last(ebuPeak),
+	}
+	if r := last(ebuRange); r != nil {
+		l.RangeLU = *r
+	}
+	return l
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
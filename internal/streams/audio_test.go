@@ -0,0 +1,116 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// clickTrack is bpm short noise bursts per minute over a quiet hum, with
+// the first silentSec seconds left silent.
+func clickTrack(bpm, seconds, silentSec float64) []int16 {
+	samples := make([]int16, int(seconds*audioSampleRate))
+	beat := int(60 / bpm * audioSampleRate)
+	for i := int(silentSec * audioSampleRate); i < len(samples); i++ {
+		v := 300 * math.Sin(2*math.Pi*220*float64(i)/audioSampleRate)
+		if i%beat < audioSampleRate/50 { // 20ms click
+			v += 12000 * math.Sin(2*math.Pi*1000*float64(i)/audioSampleRate)
+		}
+		samples[i] = int16(v)
+	}
+	return samples
+}
+
+func TestAnalyzePCM_Tempo(t *testing.T) {
+	a := analyzePCM(clickTrack(120, 12, 0))
+	if math.Abs(a.TempoBPM-120) > 2 {
+		t.Errorf("tempo = %v BPM, want 120", a.TempoBPM)
+	}
+	if a.TempoConfidence < 0.3 {
+		t.Errorf("confidence = %v, want a clear beat", a.TempoConfidence)
+	}
+	if len(a.Beats) < 22 || len(a.Beats) > 25 {
+		t.Errorf("%d beats, want ~24", len(a.Beats))
+	}
+	if a.Beats[0] > 0.05 {
+		t.Errorf("first beat at %vs, want 0", a.Beats[0])
+	}
+}
+
+func TestAnalyzePCM_SilenceAndSpeech(t *testing.T) {
+	a := analyzePCM(clickTrack(100, 10, 4))
+	if math.Abs(a.SilenceRatio-0.4) > 0.02 {
+		t.Errorf("silence = %v, want 0.4", a.SilenceRatio)
+	}
+
+	a.AddSpeech(&ASRResult{Segments: []ASRSegment{{Start: 4, End: 7}}})
+	if math.Abs(*a.SpeechRatio-0.3) > 0.02 || math.Abs(*a.MusicRatio-0.3) > 0.02 {
+		t.Errorf("speech = %v, music = %v, want 0.3 each", *a.SpeechRatio, *a.MusicRatio)
+	}
+}
+
+func TestAnalyzePCM_TooShortForTempo(t *testing.T) {
+	a := analyzePCM(clickTrack(120, 2, 0))
+	if a.TempoBPM != 0 || a.Beats != nil {
+		t.Errorf("tempo = %v, %d beats; want none from 2s", a.TempoBPM, len(a.Beats))
+	}
+}
+
+func TestParseEBUR128(t *testing.T) {
+	log := `[Parsed_ebur128_0 @ 0x55d1] Summary:
+
+  Integrated loudness:
+    I:         -14.2 LUFS
+    Threshold: -24.6 LUFS
+
+  Loudness range:
+    LRA:         5.3 LU
+    Threshold: -34.5 LUFS
+    LRA low:   -18.1 LUFS
+    LRA high:  -12.8 LUFS
+
+  True peak:
+    Peak:       -0.8 dBFS
+`
+	l := parseEBUR128(log)
+	if l.IntegratedLUFS == nil || *l.IntegratedLUFS != -14.2 || l.RangeLU != 5.3 || l.TruePeakDBFS == nil || *l.TruePeakDBFS != -0.8 {
+		t.Errorf("loudness = %+v", l)
+	}
+
+	silent := parseEBUR128("I:         -70.0 LUFS\n    LRA:         0.0 LU\n    Peak:       -inf dBFS\n")
+	if silent.TruePeakDBFS != nil {
+		t.Errorf("peak = %v, want nil for -inf", *silent.TruePeakDBFS)
+	}
+}
+
+func TestAnalyzeAudio(t *testing.T) {
+	if !FFmpegAvailable() {
+		t.Skip("ffmpeg not installed")
+	}
+	dir := t.TempDir()
+	withAudio := filepath.Join(dir, "audio.mp4")
+	silent := filepath.Join(dir, "silent.mp4")
+	for _, args := range [][]string{
+		{"-f", "lavfi", "-i", "testsrc=duration=3", "-f", "lavfi", "-i", "sine=frequency=440:duration=3", "-shortest", withAudio},
+		{"-f", "lavfi", "-i", "testsrc=duration=3", silent},
+	} {
+		gen := exec.Command("ffmpeg", append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+		if out, err := gen.CombinedOutput(); err != nil {
+			t.Fatalf("generate video: %v: %s", err, out)
+		}
+	}
+
+	a, err := AnalyzeAudio(context.Background(), withAudio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Loudness.IntegratedLUFS == nil || math.Abs(a.DurationSec-3) > 0.1 || a.SilenceRatio > 0.05 {
+		t.Errorf("analysis = %+v", a)
+	}
+	if _, err := AnalyzeAudio(context.Background(), silent); !errors.Is(err, ErrNoAudio) {
+		t.Errorf("err = %v, want ErrNoAudio", err)
+	}
+}
@@ -0,0 +1,342 @@
+package streams
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// geminiBatchPoll is how often a submitted batch is checked for completion.
+var geminiBatchPoll = 30 * time.Second
+
+// SetGeminiBatchPollInterval sets how often RunVLMBatch polls a submitted
+// batch.
+func SetGeminiBatchPollInterval(d time.Duration) {
+	geminiBatchPoll = d
+}
+
+// batchFrameContext stands in for the previous frame's description: batched
+// requests run independently, so there is none.
+const batchFrameContext = "Not available; describe this frame on its own."
+
+// Terminal batch states. Any other state means the batch is still queued or
+// running.
+const (
+	batchSucceeded = "BATCH_STATE_SUCCEEDED"
+	batchFailed    = "BATCH_STATE_FAILED"
+	batchCancelled = "BATCH_STATE_CANCELLED"
+	batchExpired   = "BATCH_STATE_EXPIRED"
+)
+
+type geminiBatchRequest struct {
+	Request  geminiRequest     `json:"request"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type geminiBatchCreate struct {
+	Batch struct {
+		DisplayName string `json:"display_name"`
+		InputConfig struct {
+			Requests struct {
+				Requests []geminiBatchRequest `json:"requests"`
+			} `json:"requests"`
+		} `json:"input_config"`
+	} `json:"batch"`
+}
+
+// geminiBatch is the long-running operation returned when a batch is created
+// and each time it is polled.
+type geminiBatch struct {
+	Name     string `json:"name"` // e.g. "batches/abc123"
+	Done     bool   `json:"done"`
+	Metadata struct {
+		State string `json:"state"`
+	} `json:"metadata"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Response struct {
+		InlinedResponses struct {
+			InlinedResponses []struct {
+				Metadata map[string]string `json:"metadata"`
+				Response *geminiResponse   `json:"response"`
+				Error    *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"inlinedResponses"`
+		} `json:"inlinedResponses"`
+	} `json:"response"`
+}
+
+// RunVLMBatch describes keyframes through Gemini's asynchronous Batch API,
+// which costs about half as much as generateContent but may take hours. All
+// frames are collected into one batch, submitted, polled every
+// geminiBatchPoll until done, and assembled in frame order. Frames are
+// described independently, without the previous frame as context. If ctx
+// ends first the batch is cancelled and the result is Incomplete. Vertex AI
+// has no equivalent that takes requests inline, so there it is an error.
+func RunVLMBatch(ctx context.Context, keyframes []KeyframeInput, apiKey string, opts VLMOptions) (*VLMResult, error) {
+	if geminiVertex != nil {
+		return nil, errors.New("batch priority is not available with Gemini through Vertex AI")
+	}
+	if opts.Model != "" {
+		if err := ValidateGeminiModel(opts.Model); err != nil {
+			return nil, err
+		}
+		ctx = withGeminiModel(ctx, opts.Model)
+	}
+	params := map[string]string{"context": "none", "mode": "batch"}
+	keyframes, skipped := selectKeyframes(keyframes, opts.MaxFrames, opts.Selection)
+	if skipped != nil {
+		params["max_frames"] = strconv.Itoa(opts.MaxFrames)
+		params["selection"] = cmp.Or(opts.Selection, SelectByEntropy)
+	}
+	lang := promptLanguage(opts.Language)
+	if lang != "" {
+		params["language"] = lang
+	}
+	budget := tokenBudget(ctx)
+	if budget.Limit() > 0 {
+		params["token_budget"] = strconv.FormatInt(budget.Limit(), 10)
+	}
+	template, promptVersion := vlmPromptTemplate, vlmPromptVersion
+	if opts.PromptTemplate != "" {
+		if err := ValidatePromptTemplate(opts.PromptTemplate); err != nil {
+			return nil, err
+		}
+		template, promptVersion = opts.PromptTemplate, customPromptVersion(opts.PromptTemplate)
+	}
+	result := &VLMResult{
+		SchemaVersion: VLMSchemaVersion,
+		SkippedFrames: skipped,
+		Provenance:    withModel(ctx, geminiProvenance(promptVersion, params)),
+	}
+
+	// Collect: each frame is encoded into its request as soon as it loads,
+	// so only the encoded batch is held, not the raw images as well. Usage
+	// is only known once the batch is done, so the token budget is held
+	// against the requests' estimates; frames past it are skipped.
+	descs := make([]string, len(keyframes))
+	errs := make([]error, len(keyframes))
+	estimates := make([]int, len(keyframes))
+	var planned int64
+	var requests []geminiBatchRequest
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+	i := 0
+	for lf := range prefetchFrames(fetchCtx, keyframes) {
+		if budget.Limit() > 0 && budget.Used()+planned >= budget.Limit() {
+			lf.release()
+			result.SkippedFrames = append(result.SkippedFrames, overBudget(keyframes[i:])...)
+			keyframes = keyframes[:i]
+			break
+		}
+		if lf.err != nil {
+			errs[i] = lf.err
+		} else {
+			img, cleanup, err := imagePart(ctx, apiKey, lf.img)
+			if err != nil {
+				errs[i] = err
+			} else {
+				cleanups = append(cleanups, cleanup)
+				prompt := withLanguage(fmt.Sprintf(template, batchFrameContext, lf.kf.TimestampSec), lang)
+				req := geminiRequest{Contents: []geminiContent{{Parts: []geminiPart{{Text: prompt}, img}}}}
+				estimates[i] = estimateTokens(req)
+				planned += int64(estimates[i])
+				requests = append(requests, geminiBatchRequest{
+					Request:  req,
+					Metadata: map[string]string{"key": strconv.Itoa(i)},
+				})
+			}
+		}
+		lf.release()
+		i++
+	}
+	if ctx.Err() != nil {
+		result.Incomplete = true
+		result.normalize()
+		return result, nil
+	}
+
+	if len(requests) > 0 {
+		// Submit, then poll until the batch finishes
+		batch, err := submitGeminiBatch(ctx, apiKey, requests)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("gemini batch %s submitted with %d frames", batch.Name, len(requests))
+		batch, err = waitGeminiBatch(ctx, apiKey, batch)
+		if ctx.Err() != nil {
+			cancelGeminiBatch(context.WithoutCancel(ctx), apiKey, batch.Name)
+			result.Incomplete = true
+			result.normalize()
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Assemble answers by the key each request was tagged with
+		answered := make([]bool, len(keyframes))
+		for _, r := range batch.Response.InlinedResponses.InlinedResponses {
+			k, err := strconv.Atoi(r.Metadata["key"])
+			if err != nil || k < 0 || k >= len(keyframes) {
+				continue
+			}
+			answered[k] = true
+			switch {
+			case r.Error != nil:
+				errs[k] = fmt.Errorf("gemini error: %s", r.Error.Message)
+			case r.Response == nil:
+				errs[k] = fmt.Errorf("empty response from gemini")
+			default:
+				budget.add(cmp.Or(r.Response.UsageMetadata.TotalTokenCount, estimates[k]))
+				descs[k], errs[k] = r.Response.text()
+			}
+		}
+		for _, req := range requests {
+			if k, _ := strconv.Atoi(req.Metadata["key"]); !answered[k] {
+				errs[k] = fmt.Errorf("no response for frame in batch %s", batch.Name)
+			}
+		}
+	}
+
+	for i, kf := range keyframes {
+		desc, err := descs[i], errs[i]
+		if err != nil {
+			desc = fmt.Sprintf("[Error: %v]", err)
+		}
+		result.Frames = append(result.Frames, VLMFrame{
+			FrameIndex:   kf.FrameIndex,
+			TimestampSec: kf.TimestampSec,
+			Description:  desc,
+			Blocked:      errors.Is(err, ErrBlocked),
+		})
+	}
+	result.normalize()
+	return result, nil
+}
+
+func submitGeminiBatch(ctx context.Context, apiKey string, requests []geminiBatchRequest) (*geminiBatch, error) {
+	var create geminiBatchCreate
+	create.Batch.DisplayName = fmt.Sprintf("vlm-%d-frames", len(requests))
+	create.Batch.InputConfig.Requests.Requests = requests
+	body, err := json.Marshal(create)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch: %w", err)
+	}
+
+	url := geminiURL(apiKey, "models/"+geminiModelFor(ctx)+":batchGenerateContent")
+	var batch geminiBatch
+	if err := geminiBatchCall(ctx, http.MethodPost, url, body, &batch); err != nil {
+		return nil, fmt.Errorf("submit gemini batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// waitGeminiBatch polls until the batch reaches a terminal state. A failed,
+// cancelled or expired batch is an error. On ctx ending it returns the last
+// state seen.
+func waitGeminiBatch(ctx context.Context, apiKey string, batch *geminiBatch) (*geminiBatch, error) {
+	url := geminiURL(apiKey, batch.Name)
+	for {
+		switch batch.Metadata.State {
+		case batchSucceeded:
+			return batch, nil
+		case batchFailed, batchCancelled, batchExpired:
+			msg := batch.Metadata.State
+			if batch.Error != nil {
+				msg += ": " + batch.Error.Message
+			}
+			return batch, fmt.Errorf("gemini batch %s ended: %s", batch.Name, msg)
+		}
+		if batch.Done && batch.Error != nil {
+			return batch, fmt.Errorf("gemini batch %s ended: %s", batch.Name, batch.Error.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return batch, context.Cause(ctx)
+		case <-time.After(geminiBatchPoll):
+		}
+
+		var next geminiBatch
+		if err := geminiBatchCall(ctx, http.MethodGet, url, nil, &next); err != nil {
+			if ctx.Err() != nil {
+				return batch, err
+			}
+			// A failed poll is not fatal: the batch carries on server-side
+			log.Printf("WARN: poll gemini batch %s: %v", batch.Name, err)
+			continue
+		}
+		next.Name = cmp.Or(next.Name, batch.Name)
+		batch = &next
+	}
+}
+
+// cancelGeminiBatch asks Gemini to stop a batch whose result is no longer
+// wanted. It is best effort: an abandoned batch still finishes and bills.
+func cancelGeminiBatch(ctx context.Context, apiKey, name string) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	url := geminiURL(apiKey, name+":cancel")
+	if err := geminiBatchCall(ctx, http.MethodPost, url, nil, nil); err != nil {
+		log.Printf("WARN: cancel gemini batch %s: %v", name, err)
+	}
+}
+
+// geminiBatchCall makes a Batch API call with retries and decodes the answer
+// into out, if given. Batch calls bypass the generateContent rate limiter,
+// which the Batch API's separate quota does not share.
+func geminiBatchCall(ctx context.Context, method, url string, body []byte, out any) error {
+	return retry.Do(ctx, geminiRetry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if err := authorizeGemini(req); err != nil {
+			return err
+		}
+
+		resp, err := geminiClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("gemini request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewHTTPError("gemini", resp, respBody)
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withBatchPoll(t *testing.T) {
+	t.Helper()
+	old := geminiBatchPoll
+	SetGeminiBatchPollInterval(time.Millisecond)
+	t.Cleanup(func() { SetGeminiBatchPollInterval(old) })
+}
+
+func TestRunVLMBatch_SubmitPollAssemble(t *testing.T) {
+	withBatchPoll(t)
+
+	var mu sync.Mutex
+	polls := 0
+	var submitted []geminiBatchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":batchGenerateContent"):
+			var create geminiBatchCreate
+			json.NewDecoder(r.Body).Decode(&create)
+			submitted = create.Batch.InputConfig.Requests.Requests
+			json.NewEncoder(w).Encode(map[string]any{
+				"name":     "batches/b1",
+				"metadata": map[string]any{"state": "BATCH_STATE_PENDING"},
+			})
+		case r.URL.Path == "/v1beta/batches/b1":
+			polls++
+			if polls < 2 {
+				json.NewEncoder(w).Encode(map[string]any{
+					"name":     "batches/b1",
+					"metadata": map[string]any{"state": "BATCH_STATE_RUNNING"},
+				})
+				return
+			}
+			// Answers arrive out of order; frame 2 was refused
+			text := func(s string) map[string]any {
+				return map[string]any{"candidates": []map[string]any{
+					{"content": map[string]any{"parts": []map[string]any{{"text": s}}}},
+				}}
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"name":     "batches/b1",
+				"done":     true,
+				"metadata": map[string]any{"state": "BATCH_STATE_SUCCEEDED"},
+				"response": map[string]any{"inlinedResponses": map[string]any{"inlinedResponses": []map[string]any{
+					{"metadata": map[string]string{"key": "1"}, "response": text("second")},
+					{"metadata": map[string]string{"key": "0"}, "response": text("first")},
+					{"metadata": map[string]string{"key": "2"}, "response": map[string]any{
+						"promptFeedback": map[string]any{"blockReason": "SAFETY"},
+					}},
+				}}},
+			})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	keyframes := []KeyframeInput{
+		{FrameIndex: 10, TimestampSec: 0, ImageBytes: []byte("a")},
+		{FrameIndex: 11, TimestampSec: 1, ImageBytes: []byte("b")},
+		{FrameIndex: 12, TimestampSec: 2, ImageBytes: []byte("c")},
+	}
+	result, err := RunVLMBatch(context.Background(), keyframes, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLMBatch error: %v", err)
+	}
+
+	if len(submitted) != 3 {
+		t.Fatalf("submitted %d requests, want 3", len(submitted))
+	}
+	if prompt := submitted[1].Request.Contents[0].Parts[0].Text; !strings.Contains(prompt, batchFrameContext) {
+		t.Errorf("batched prompt should not carry previous-frame context: %s", prompt)
+	}
+	if polls != 2 {
+		t.Errorf("polls = %d, want 2", polls)
+	}
+	if result.Incomplete || len(result.Frames) != 3 {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Frames[0].Description != "first" || result.Frames[1].Description != "second" {
+		t.Errorf("frames = %+v", result.Frames)
+	}
+	if !result.Frames[2].Blocked || result.Frames[2].FrameIndex != 12 {
+		t.Errorf("frame 2 = %+v, want blocked", result.Frames[2])
+	}
+	if result.Provenance.Params["mode"] != "batch" {
+		t.Errorf("provenance = %+v", result.Provenance)
+	}
+}
+
+func TestRunVLMBatch_CancelledWhenJobEnds(t *testing.T) {
+	withBatchPoll(t)
+
+	var mu sync.Mutex
+	cancelled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, ":cancel") {
+			cancelled = true
+			w.Write([]byte("{}"))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":     "batches/b2",
+			"metadata": map[string]any{"state": "BATCH_STATE_PENDING"},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := RunVLMBatch(ctx, []KeyframeInput{{ImageBytes: []byte("a")}}, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLMBatch error: %v", err)
+	}
+	if !result.Incomplete || len(result.Frames) != 0 {
+		t.Errorf("result = %+v, want incomplete with no frames", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !cancelled {
+		t.Error("batch should be cancelled once the job ends")
+	}
+}
@@ -0,0 +1,79 @@
+package streams
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SkipOverBudget is the reason recorded for frames left undescribed because
+// the job's token budget ran out.
+const SkipOverBudget = "over_budget"
+
+// TokenBudget meters the Gemini tokens one job uses against a ceiling. Every
+// Gemini call made under WithTokenBudget counts; per-frame analyses stop
+// taking new frames once the ceiling is reached, while single calls such as
+// summaries still run. A nil *TokenBudget meters nothing.
+type TokenBudget struct {
+	limit int64 // 0 = no ceiling
+	used  atomic.Int64
+}
+
+// NewTokenBudget returns a budget of limit tokens (0 = unlimited, still
+// metered).
+func NewTokenBudget(limit int64) *TokenBudget {
+	return &TokenBudget{limit: max(limit, 0)}
+}
+
+type budgetKey struct{}
+
+// WithTokenBudget makes Gemini calls under ctx count against b.
+func WithTokenBudget(ctx context.Context, b *TokenBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// Used is the number of tokens used so far.
+func (b *TokenBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.used.Load()
+}
+
+// Limit is the ceiling, 0 for none.
+func (b *TokenBudget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Exhausted reports whether the ceiling has been reached.
+func (b *TokenBudget) Exhausted() bool {
+	return b != nil && b.limit > 0 && b.used.Load() >= b.limit
+}
+
+func (b *TokenBudget) add(n int) {
+	if b != nil && n > 0 {
+		b.used.Add(int64(n))
+	}
+}
+
+// BudgetExhausted reports whether ctx carries a token budget that has run
+// out.
+func BudgetExhausted(ctx context.Context) bool {
+	return tokenBudget(ctx).Exhausted()
+}
+
+func tokenBudget(ctx context.Context) *TokenBudget {
+	b, _ := ctx.Value(budgetKey{}).(*TokenBudget)
+	return b
+}
+
+// overBudget lists keyframes as skipped for the budget.
+func overBudget(keyframes []KeyframeInput) []SkippedFrame {
+	var out []SkippedFrame
+	for _, kf := range keyframes {
+		out = append(out, SkippedFrame{FrameIndex: kf.FrameIndex, TimestampSec: round3(kf.TimestampSec), Reason: SkipOverBudget})
+	}
+	return out
+}
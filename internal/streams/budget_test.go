@@ -0,0 +1,108 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunVLM_StopsAtTokenBudget(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates":    []map[string]any{{"content": map[string]any{"parts": []map[string]any{{"text": "A frame."}}}}},
+			"usageMetadata": map[string]any{"totalTokenCount": 1000},
+		})
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	budget := NewTokenBudget(2500)
+	ctx := WithTokenBudget(context.Background(), budget)
+	res, err := RunVLM(ctx, testKeyframes(5), "key", VLMOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the third frame is described under budget and takes usage past it
+	if calls != 3 || len(res.Frames) != 3 || budget.Used() != 3000 {
+		t.Fatalf("%d calls, %d frames, %d tokens used", calls, len(res.Frames), budget.Used())
+	}
+	want := []SkippedFrame{{3, 4.5, SkipOverBudget}, {4, 6, SkipOverBudget}}
+	if len(res.SkippedFrames) != 2 || res.SkippedFrames[0] != want[0] || res.SkippedFrames[1] != want[1] {
+		t.Errorf("skipped = %+v", res.SkippedFrames)
+	}
+	if res.Incomplete || res.Provenance.Params["token_budget"] != "2500" {
+		t.Errorf("incomplete = %v, provenance = %+v", res.Incomplete, res.Provenance)
+	}
+}
+
+func TestAnalyzeFrames_StopsAtTokenBudget(t *testing.T) {
+	prompts := stubFrameAnswers(t, func(int) map[string]any { return map[string]any{"people_count": 1} })
+
+	budget := NewTokenBudget(1)
+	ctx := WithTokenBudget(context.Background(), budget)
+	// 12 frames are two batches; the first uses the budget up
+	answers := analyzeFrames[struct{}](ctx, "key", testKeyframes(analysisBatchSize+4), peoplePromptTemplate)
+	if len(*prompts) != 1 || len(answers) != analysisBatchSize || !budget.Exhausted() {
+		t.Errorf("%d requests, %d answers, %d tokens used", len(*prompts), len(answers), budget.Used())
+	}
+}
+
+func TestTokenBudget_Unlimited(t *testing.T) {
+	b := NewTokenBudget(0)
+	b.add(1 << 40)
+	if b.Exhausted() || b.Used() != 1<<40 {
+		t.Errorf("unlimited budget: exhausted %v, used %d", b.Exhausted(), b.Used())
+	}
+	var none *TokenBudget
+	if none.Exhausted() || BudgetExhausted(context.Background()) {
+		t.Error("no budget reported exhausted")
+	}
+}
+
+func TestRunVLMBatch_HoldsEstimatesAgainstBudget(t *testing.T) {
+	withBatchPoll(t)
+
+	var submitted int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ":batchGenerateContent") {
+			var create geminiBatchCreate
+			json.NewDecoder(r.Body).Decode(&create)
+			submitted = len(create.Batch.InputConfig.Requests.Requests)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":     "batches/b1",
+			"done":     true,
+			"metadata": map[string]any{"state": "BATCH_STATE_SUCCEEDED"},
+			"response": map[string]any{"inlinedResponses": map[string]any{"inlinedResponses": []map[string]any{
+				{"metadata": map[string]string{"key": "0"}, "response": map[string]any{
+					"candidates":    []map[string]any{{"content": map[string]any{"parts": []map[string]any{{"text": "first"}}}}},
+					"usageMetadata": map[string]any{"totalTokenCount": 700},
+				}},
+			}}},
+		})
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	// any budget below two frames' estimates submits only the first
+	budget := NewTokenBudget(1)
+	res, err := RunVLMBatch(WithTokenBudget(context.Background(), budget), testKeyframes(3), "key", VLMOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted != 1 || len(res.Frames) != 1 || res.Frames[0].Description != "first" || res.Incomplete {
+		t.Fatalf("submitted %d, result = %+v", submitted, res)
+	}
+	if len(res.SkippedFrames) != 2 || res.SkippedFrames[0].Reason != SkipOverBudget || budget.Used() != 700 {
+		t.Errorf("skipped = %+v, used = %d", res.SkippedFrames, budget.Used())
+	}
+}
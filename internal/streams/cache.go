@@ -0,0 +1,211 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// Gemini context caching for the instructions VLM requests share. A cache
+// bills its tokens at a reduced rate on every request that uses it, plus
+// storage for as long as it lives.
+var (
+	geminiCacheTTL       time.Duration // 0 disables caching
+	geminiCacheMinTokens = 4096        // Gemini refuses smaller caches
+)
+
+// SetGeminiCache enables context caching of VLM prompt instructions of at
+// least minTokens (estimated), each cache living ttl past its last
+// extension.
+func SetGeminiCache(ttl time.Duration, minTokens int) {
+	geminiCacheTTL = ttl
+	geminiCacheMinTokens = minTokens
+}
+
+type geminiCachedContent struct {
+	Name              string         `json:"name,omitempty"` // e.g. "cachedContents/abc123"
+	Model             string         `json:"model,omitempty"`
+	SystemInstruction *geminiContent `json:"systemInstruction,omitempty"`
+	TTL               string         `json:"ttl,omitempty"`
+	ExpireTime        time.Time      `json:"expireTime,omitzero"`
+}
+
+type cachedContentKey struct{}
+
+// withCachedContent marks ctx so Gemini requests made under it use the named
+// cached content.
+func withCachedContent(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, cachedContentKey{}, name)
+}
+
+// promptCache holds the caches of one VLM run, by the instructions they
+// hold. A nil *promptCache caches nothing.
+type promptCache struct {
+	apiKey string
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	name    string
+	expires time.Time
+	failed  bool // send the instructions inline from now on
+}
+
+// newPromptCache returns the cache for one run, or nil with caching off.
+// The caller must close it.
+func newPromptCache(apiKey string) *promptCache {
+	if geminiCacheTTL <= 0 {
+		return nil
+	}
+	return &promptCache{apiKey: apiKey, ttl: geminiCacheTTL, entries: map[string]*cacheEntry{}}
+}
+
+// prompt assembles a prompt from its per-request header and the
+// instructions shared across the run. When the instructions are cached only
+// the header is sent, and the returned context names the cache.
+func (c *promptCache) prompt(ctx context.Context, header, instructions string) (context.Context, string) {
+	if name := c.lookup(ctx, instructions); name != "" {
+		return withCachedContent(ctx, name), header
+	}
+	return ctx, header + "\n\n" + instructions
+}
+
+// lookup returns the cache holding instructions, creating it on first use
+// and extending it once past half its lifetime. It returns "" for
+// instructions too short to cache, and after caching them failed.
+func (c *promptCache) lookup(ctx context.Context, instructions string) string {
+	if c == nil || len(instructions)/4 < geminiCacheMinTokens {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[instructions]
+	switch {
+	case e == nil:
+		e = &cacheEntry{}
+		c.entries[instructions] = e
+		cc, err := createGeminiCache(ctx, c.apiKey, instructions, c.ttl)
+		if err != nil {
+			log.Printf("WARN: gemini context cache: %v; sending instructions inline", err)
+			e.failed = true
+			return ""
+		}
+		e.name, e.expires = cc.Name, cc.ExpireTime
+	case e.failed:
+		return ""
+	case time.Until(e.expires) < c.ttl/2:
+		expires, err := extendGeminiCache(ctx, c.apiKey, e.name, c.ttl)
+		if err != nil {
+			log.Printf("WARN: gemini context cache %s: %v; sending instructions inline", e.name, err)
+			e.failed = true
+			return ""
+		}
+		e.expires = expires
+	}
+	return e.name
+}
+
+// close deletes the run's caches. Gemini would expire them after the TTL
+// anyway, so deletion failures are ignored.
+func (c *promptCache) close(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		if e.name != "" {
+			// cached contents are deleted like files, by resource name
+			deleteGeminiFile(context.WithoutCancel(ctx), c.apiKey, e.name)
+		}
+	}
+	clear(c.entries)
+}
+
+func createGeminiCache(ctx context.Context, apiKey, instructions string, ttl time.Duration) (*geminiCachedContent, error) {
+	body, err := json.Marshal(geminiCachedContent{
+		Model:             geminiModelName(ctx),
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: instructions}}},
+		TTL:               cacheTTL(ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cc, err := geminiCacheCall(ctx, http.MethodPost, geminiURL(apiKey, "cachedContents"), body)
+	if err != nil {
+		return nil, err
+	}
+	if cc.ExpireTime.IsZero() {
+		cc.ExpireTime = time.Now().Add(ttl)
+	}
+	return cc, nil
+}
+
+func extendGeminiCache(ctx context.Context, apiKey, name string, ttl time.Duration) (time.Time, error) {
+	body, err := json.Marshal(geminiCachedContent{TTL: cacheTTL(ttl)})
+	if err != nil {
+		return time.Time{}, err
+	}
+	cc, err := geminiCacheCall(ctx, http.MethodPatch, geminiURL(apiKey, name+"?updateMask=ttl"), body)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if cc.ExpireTime.IsZero() {
+		return time.Now().Add(ttl), nil
+	}
+	return cc.ExpireTime, nil
+}
+
+func geminiCacheCall(ctx context.Context, method, url string, body []byte) (*geminiCachedContent, error) {
+	var cc geminiCachedContent
+	err := retry.Do(ctx, geminiRetry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("create cache request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := authorizeGemini(req); err != nil {
+			return err
+		}
+		resp, err := geminiClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("gemini cache request: %w", err)
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read cache response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewHTTPError("gemini", resp, respBody)
+		}
+		if err := json.Unmarshal(respBody, &cc); err != nil {
+			return fmt.Errorf("decode cache response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cc.Name == "" {
+		return nil, fmt.Errorf("gemini cache: no name returned")
+	}
+	return &cc, nil
+}
+
+// cacheTTL formats ttl as the API's duration, whole seconds with an "s".
+func cacheTTL(ttl time.Duration) string {
+	return fmt.Sprintf("%ds", int(ttl.Seconds()))
+}
@@ -0,0 +1,121 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withGeminiCache(t *testing.T, ttl time.Duration, minTokens int) {
+	t.Helper()
+	oldTTL, oldMin := geminiCacheTTL, geminiCacheMinTokens
+	SetGeminiCache(ttl, minTokens)
+	t.Cleanup(func() { SetGeminiCache(oldTTL, oldMin) })
+}
+
+func TestRunVLM_CachesInstructions(t *testing.T) {
+	withGeminiCache(t, time.Minute, 10)
+
+	var (
+		mu       sync.Mutex
+		calls    []string
+		prompts  []string
+		creation geminiCachedContent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1beta/cachedContents":
+			json.NewDecoder(r.Body).Decode(&creation)
+			// expiring within half the TTL, so the next frame extends it
+			json.NewEncoder(w).Encode(geminiCachedContent{Name: "cachedContents/c1", ExpireTime: time.Now().Add(10 * time.Second)})
+		case r.Method == http.MethodPatch:
+			if r.URL.Query().Get("updateMask") != "ttl" {
+				t.Errorf("update mask = %q", r.URL.Query().Get("updateMask"))
+			}
+			json.NewEncoder(w).Encode(geminiCachedContent{Name: "cachedContents/c1", ExpireTime: time.Now().Add(time.Minute)})
+		case r.Method == http.MethodDelete:
+		default:
+			var req geminiRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.CachedContent != "cachedContents/c1" {
+				t.Errorf("cachedContent = %q", req.CachedContent)
+			}
+			prompts = append(prompts, req.Contents[0].Parts[0].Text)
+			w.Write(mockGeminiResponse("A frame."))
+		}
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	res, err := RunVLM(context.Background(), testKeyframes(3), "key", VLMOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frames) != 3 || res.Frames[2].Description != "A frame." {
+		t.Fatalf("frames = %+v", res.Frames)
+	}
+	want := "POST /v1beta/cachedContents,POST /v1beta/models/gemini-2.0-flash:generateContent," +
+		"PATCH /v1beta/cachedContents/c1,POST /v1beta/models/gemini-2.0-flash:generateContent," +
+		"POST /v1beta/models/gemini-2.0-flash:generateContent,DELETE /v1beta/cachedContents/c1"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls =\n%s\nwant\n%s", got, want)
+	}
+	if creation.Model != "models/gemini-2.0-flash" || creation.TTL != "60s" || creation.SystemInstruction.Parts[0].Text != vlmInstructions {
+		t.Errorf("cache created with %+v", creation)
+	}
+	if want := fmt.Sprintf(vlmFrameHeader, "This is the first frame of the ad.", 0.0); prompts[0] != want {
+		t.Errorf("prompt = %q, want the header alone", prompts[0])
+	}
+}
+
+func TestRunVLM_CacheFailureFallsBack(t *testing.T) {
+	withGeminiCache(t, time.Minute, 10)
+
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/cachedContents") {
+			http.Error(w, `{"error": {"message": "model does not support caching"}}`, http.StatusBadRequest)
+			return
+		}
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.CachedContent != "" {
+			t.Errorf("cachedContent = %q", req.CachedContent)
+		}
+		prompts = append(prompts, req.Contents[0].Parts[0].Text)
+		w.Write(mockGeminiResponse("A frame."))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	if _, err := RunVLM(context.Background(), testKeyframes(2), "key", VLMOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range prompts {
+		if !strings.HasSuffix(p, vlmInstructions) {
+			t.Errorf("prompt without its instructions: %q", p)
+		}
+	}
+}
+
+func TestPromptCache_ShortInstructions(t *testing.T) {
+	withGeminiCache(t, time.Minute, 4096)
+	c := newPromptCache("key")
+	// no server: too short to cache, so nothing is requested
+	if _, prompt := c.prompt(context.Background(), "Header", "Short."); prompt != "Header\n\nShort." {
+		t.Errorf("prompt = %q", prompt)
+	}
+}
@@ -0,0 +1,143 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CompareInput is one ad's stored results, as much as the comparison uses.
+// KeyMoments and Hook may be nil.
+type CompareInput struct {
+	AdID       string
+	Timeline   *Timeline
+	KeyMoments *KeyMomentsResult
+	Hook       *HookResult
+}
+
+// Comparison contrasts two ads, typically an original and its variant.
+type Comparison struct {
+	AdA string `json:"ad_a"`
+	AdB string `json:"ad_b"`
+
+	// Pacing is measured from the timelines; the rest is Gemini's reading
+	PacingA Pacing `json:"pacing_a"`
+	PacingB Pacing `json:"pacing_b"`
+
+	SharedElements []string         `json:"shared_elements"`
+	Hook           AspectComparison `json:"hook"`
+	Pacing         AspectComparison `json:"pacing"`
+	Differences    []Difference     `json:"differences"` // anything else that changed
+	Summary        string           `json:"summary"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Pacing is how densely an ad packs its shots and words.
+type Pacing struct {
+	DurationSec    float64 `json:"duration_sec"`
+	Shots          int     `json:"shots"`            // described keyframes
+	SecPerShot     float64 `json:"sec_per_shot"`     // 0 without shots
+	WordsPerMinute float64 `json:"words_per_minute"` // of speech over the whole ad
+}
+
+// AspectComparison describes one aspect in each ad and how they differ.
+type AspectComparison struct {
+	A          string `json:"a"`
+	B          string `json:"b"`
+	Difference string `json:"difference"`
+}
+
+// Difference is any other aspect in which the ads differ.
+type Difference struct {
+	Aspect string `json:"aspect"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+}
+
+const comparePromptTemplate = `Below are the merged timelines of two versions of a video advertisement, ad A and ad B. SPEECH lines are the transcript, VISUAL lines describe keyframes.
+
+%s
+
+%s
+
+Compare the two for a creative team iterating on the ad:
+- shared_elements: what both ads have in common (product, claims, scenes, offer, call to action), one short phrase each
+- hook: how each ad opens in its first seconds (a, b), and what the difference is likely to do to viewers who might scroll past (difference)
+- pacing: each ad's rhythm of cuts, speech and information (a, b), and the difference; the measured pacing above is accurate, so use it
+- differences: any other aspects that changed (for example "offer", "call to action", "music", "presenter", "length"), each with how it appears in a and b
+- summary: two sentences on what B changes relative to A
+
+Only use information present above. Respond with a JSON object:
+{"shared_elements": ["<phrase>"], "hook": {"a": "<text>", "b": "<text>", "difference": "<text>"}, "pacing": {"a": "<text>", "b": "<text>", "difference": "<text>"}, "differences": [{"aspect": "<aspect>", "a": "<text>", "b": "<text>"}], "summary": "<text>"}`
+
+// RunCompare asks Gemini for a structured comparison of two ads' stored
+// results. Both timelines are required.
+func RunCompare(ctx context.Context, a, b CompareInput, apiKey string) (*Comparison, error) {
+	if a.Timeline == nil || b.Timeline == nil {
+		return nil, errors.New("both ads need a timeline")
+	}
+	out := &Comparison{
+		AdA:        a.AdID,
+		AdB:        b.AdID,
+		PacingA:    measurePacing(a.Timeline),
+		PacingB:    measurePacing(b.Timeline),
+		Provenance: geminiProvenance(comparePromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	prompt := fmt.Sprintf(comparePromptTemplate, renderForCompare("A", a, out.PacingA), renderForCompare("B", b, out.PacingB))
+
+	var answer Comparison
+	if err := callGeminiJSON(ctx, apiKey, prompt, &answer); err != nil {
+		return nil, fmt.Errorf("compare: %w", err)
+	}
+	out.SharedElements = answer.SharedElements
+	out.Hook = answer.Hook
+	out.Pacing = answer.Pacing
+	out.Differences = answer.Differences
+	out.Summary = strings.TrimSpace(answer.Summary)
+	if out.SharedElements == nil {
+		out.SharedElements = []string{}
+	}
+	if out.Differences == nil {
+		out.Differences = []Difference{}
+	}
+	return out, nil
+}
+
+// measurePacing counts the timeline's shots and spoken words.
+func measurePacing(tl *Timeline) Pacing {
+	p := Pacing{DurationSec: round3(tl.DurationSec)}
+	var words int
+	for _, e := range tl.Entries {
+		switch e.Source {
+		case "visual":
+			p.Shots++
+		case "speech":
+			words += len(strings.Fields(e.Text))
+		}
+	}
+	if p.Shots > 0 {
+		p.SecPerShot = round3(tl.DurationSec / float64(p.Shots))
+	}
+	if tl.DurationSec > 0 {
+		p.WordsPerMinute = round3(float64(words) / tl.DurationSec * 60)
+	}
+	return p
+}
+
+func renderForCompare(label string, in CompareInput, p Pacing) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "AD %s (%.1fs long; %d shots, %.1fs per shot; %.0f words per minute)\n", label, p.DurationSec, p.Shots, p.SecPerShot, p.WordsPerMinute)
+	b.WriteString(in.Timeline.Render())
+	if in.KeyMoments != nil && len(in.KeyMoments.Moments) > 0 {
+		b.WriteString("Key moments:\n")
+		for _, m := range in.KeyMoments.Moments {
+			fmt.Fprintf(&b, "[%.1fs-%.1fs] %s: %s\n", m.Start, m.End, m.Type, m.Description)
+		}
+	}
+	if h := in.Hook; h != nil {
+		fmt.Fprintf(&b, "Hook assessment: %s hook, %s; techniques: %s. %s\n", h.HookType, h.Strength, strings.Join(h.Techniques, ", "), h.Rationale)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunCompare(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Contents[0].Parts[0].Text
+		w.Write(mockGeminiResponse(`{"shared_elements": ["bottle"], "hook": {"a": "x", "b": "y", "difference": "z"}, "summary": " B is faster. "}`))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	frame := 0
+	a := CompareInput{AdID: "orig", Timeline: &Timeline{DurationSec: 30, Entries: []TimelineEntry{
+		{Start: 0, End: 10, Source: "speech", Text: "one two three four five"},
+		{Start: 0, Source: "visual", FrameIndex: &frame, Text: "A hiker."},
+	}}}
+	b := CompareInput{AdID: "variant", Timeline: &Timeline{DurationSec: 15}, Hook: &HookResult{HookType: "question", Strength: "strong", Techniques: []string{"fast_cuts"}}}
+
+	res, err := RunCompare(context.Background(), a, b, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AdA != "orig" || res.AdB != "variant" || res.Summary != "B is faster." || res.Hook.Difference != "z" {
+		t.Errorf("result = %+v", res)
+	}
+	if res.Differences == nil || len(res.SharedElements) != 1 {
+		t.Errorf("lists = %v, %v", res.SharedElements, res.Differences)
+	}
+	if res.PacingA != (Pacing{DurationSec: 30, Shots: 1, SecPerShot: 30, WordsPerMinute: 10}) {
+		t.Errorf("pacing a = %+v", res.PacingA)
+	}
+	for _, want := range []string{"AD A (30.0s long; 1 shots", "AD B (15.0s long", "Hook assessment: question hook, strong"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+
+	if _, err := RunCompare(context.Background(), a, CompareInput{AdID: "none"}, "key"); err == nil {
+		t.Error("no error without a timeline")
+	}
+}
@@ -0,0 +1,169 @@
+package streams
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CTAResult is the output of the cta stream: every call to action the ad
+// makes, on screen or spoken.
+type CTAResult struct {
+	CTAs []CTA `json:"ctas"`
+
+	// Sources are what was searched: "on_screen_text", "transcript" or both
+	Sources      []string    `json:"sources"`
+	OnScreenText []FrameText `json:"on_screen_text,omitempty"`
+
+	Incomplete bool        `json:"incomplete,omitempty"` // the job ended before every frame was read
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// CTA is one call to action, spanning the frames and transcript segments it
+// was found in.
+type CTA struct {
+	Type    string  `json:"type"` // "promo_code" or one of the ctaPatterns types
+	Text    string  `json:"text"` // as first shown or said
+	Code    string  `json:"code,omitempty"`
+	Channel string  `json:"channel"` // "visual", "audio" or "both"
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+}
+
+// FrameText is the text legible in one keyframe.
+type FrameText struct {
+	FrameIndex   int     `json:"frame_index"`
+	TimestampSec float64 `json:"timestamp_sec"`
+	Text         string  `json:"text"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// ctaPatterns recognize calls to action by type. They are matched
+// case-insensitively against on-screen text and the transcript alike.
+var ctaPatterns = []struct {
+	typ string
+	re  *regexp.Regexp
+}{
+	{"shop", regexp.MustCompile(`(?i)\bshop (?:now|today|online|the \w+)\b`)},
+	{"buy", regexp.MustCompile(`(?i)\bbuy (?:now|today|(?:it|one|yours) (?:now|today))\b`)},
+	{"order", regexp.MustCompile(`(?i)\border (?:now|today|online|yours)\b`)},
+	{"sign_up", regexp.MustCompile(`(?i)\b(?:sign up|join (?:now|today|for free|free)|register (?:now|today))\b`)},
+	{"download", regexp.MustCompile(`(?i)\b(?:download (?:now|today|the app|it free|for free)|get the app|install (?:now|today))\b`)},
+	{"try", regexp.MustCompile(`(?i)\b(?:try (?:it )?(?:now|today|(?:it )?free|for free)|start (?:your )?free trial)\b`)},
+	{"learn_more", regexp.MustCompile(`(?i)\b(?:learn|find out) more\b`)},
+	{"link", regexp.MustCompile(`(?i)\b(?:(?:link|tap|click) in (?:bio|description)|swipe up|(?:tap|click) (?:below|the link|here))\b`)},
+	{"visit", regexp.MustCompile(`(?i)\b(?:visit|go to|head to) (?:us|our \w+|[a-z0-9-]+\.(?:com|co|io|net|org|shop|store)\b)`)},
+}
+
+// promoCode matches "use code X" and the like. An X not introduced by such a
+// verb must hold a digit or be in capitals to count, so that "the code is"
+// is not a code.
+var promoCode = regexp.MustCompile(`(?i)\b(use|enter|apply|with|promo|discount|coupon)?\s*code\s*:?\s+([a-z0-9]{3,20})\b`)
+
+// ctaMatcherVersion identifies the patterns above in provenance; bump it
+// whenever they change.
+const ctaMatcherVersion = "cta-patterns-v1"
+
+// ctaMergeGap joins sightings of the same call to action up to this many
+// seconds apart into one, across channels too.
+const ctaMergeGap = 3.0
+
+const onScreenTextPromptTemplate = `Read the on-screen text in these %d frames from a video advertisement. Each image is preceded by its frame index and timestamp.
+
+For each frame transcribe all legible text overlaid on or shown in the frame: captions, titles, buttons, prices, URLs and promo codes, and text on the product or its packaging. Keep the original spelling and capitalization, and join separate lines or blocks with " / ". Give an empty string when the frame has no legible text.
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "text": "<text>"}]`
+
+// ReadOnScreenText transcribes the text in each keyframe with Gemini. The
+// second result reports whether the job ended before every frame was read.
+func ReadOnScreenText(ctx context.Context, keyframes []KeyframeInput, apiKey string) ([]FrameText, bool) {
+	answers := analyzeFrames[FrameText](ctx, apiKey, keyframes, onScreenTextPromptTemplate)
+	frames := []FrameText{}
+	for _, a := range answers {
+		f := FrameText{FrameIndex: a.kf.FrameIndex, TimestampSec: round3(a.kf.TimestampSec)}
+		if a.err != nil {
+			f.Error = a.err.Error()
+		} else {
+			f.Text = strings.Join(strings.Fields(a.value.Text), " ")
+		}
+		frames = append(frames, f)
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].TimestampSec < frames[j].TimestampSec })
+	return frames, len(answers) < len(keyframes)
+}
+
+// DetectCTAs finds calls to action in on-screen text and the transcript,
+// either of which may be nil. Matching is by pattern, with no provider
+// calls; a CTA both shown and said within ctaMergeGap has channel "both".
+func DetectCTAs(text []FrameText, asr *ASRResult) *CTAResult {
+	result := &CTAResult{
+		CTAs:         []CTA{},
+		Sources:      []string{},
+		OnScreenText: text,
+		Provenance:   &Provenance{Provider: "local", Model: ctaMatcherVersion},
+	}
+	var found []CTA
+	if text != nil {
+		result.Sources = append(result.Sources, "on_screen_text")
+		result.Provenance = geminiProvenance(onScreenTextPromptVersion, map[string]string{"response_mime_type": "application/json", "matcher": ctaMatcherVersion})
+		for _, f := range text {
+			for _, c := range matchCTAs(f.Text) {
+				c.Channel, c.Start, c.End = "visual", f.TimestampSec, f.TimestampSec
+				found = append(found, c)
+			}
+		}
+	}
+	if asr != nil {
+		result.Sources = append(result.Sources, "transcript")
+		for _, s := range asr.Segments {
+			for _, c := range matchCTAs(s.Text) {
+				c.Channel, c.Start, c.End = "audio", round3(s.Start), round3(s.End)
+				found = append(found, c)
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	for _, c := range found {
+		i := slices.IndexFunc(result.CTAs, func(prev CTA) bool {
+			return prev.Type == c.Type && prev.Code == c.Code && c.Start-prev.End <= ctaMergeGap
+		})
+		if i < 0 {
+			result.CTAs = append(result.CTAs, c)
+			continue
+		}
+		prev := &result.CTAs[i]
+		prev.End = max(prev.End, c.End)
+		if prev.Channel != c.Channel {
+			prev.Channel = "both"
+		}
+	}
+	return result
+}
+
+// matchCTAs returns the calls to action in s, one per type and code.
+func matchCTAs(s string) []CTA {
+	var out []CTA
+	for _, p := range ctaPatterns {
+		if m := p.re.FindString(s); m != "" {
+			out = append(out, CTA{Type: p.typ, Text: m})
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range promoCode.FindAllStringSubmatch(s, -1) {
+		verb, code := m[1], m[2]
+		if verb == "" && !strings.ContainsFunc(code, unicode.IsDigit) && code != strings.ToUpper(code) {
+			continue
+		}
+		code = strings.ToUpper(code)
+		if !seen[code] {
+			seen[code] = true
+			out = append(out, CTA{Type: "promo_code", Text: strings.TrimSpace(m[0]), Code: code})
+		}
+	}
+	return out
+}
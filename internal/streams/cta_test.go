@@ -0,0 +1,65 @@
+package streams
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDetectCTAs(t *testing.T) {
+	text := []FrameText{
+		{FrameIndex: 0, TimestampSec: 0, Text: "Summer sale"},
+		{FrameIndex: 1, TimestampSec: 9, Text: "SHOP NOW / Use code SUMMER20"},
+		{FrameIndex: 2, TimestampSec: 11, Text: "Shop now"},
+		{FrameIndex: 3, TimestampSec: 14, Text: "The code is simple / code BOGO"},
+	}
+	asr := &ASRResult{Segments: []ASRSegment{
+		{Start: 1, End: 4, Text: "Learn more about our new range."},
+		{Start: 10, End: 12.5, Text: "Shop now and use code summer20 at checkout."},
+	}}
+
+	res := DetectCTAs(text, asr)
+	if !slices.Equal(res.Sources, []string{"on_screen_text", "transcript"}) {
+		t.Errorf("sources = %v", res.Sources)
+	}
+	want := []CTA{
+		{Type: "learn_more", Text: "Learn more", Channel: "audio", Start: 1, End: 4},
+		{Type: "shop", Text: "SHOP NOW", Channel: "both", Start: 9, End: 12.5},
+		{Type: "promo_code", Text: "Use code SUMMER20", Code: "SUMMER20", Channel: "both", Start: 9, End: 12.5},
+		{Type: "promo_code", Text: "code BOGO", Code: "BOGO", Channel: "visual", Start: 14, End: 14},
+	}
+	if len(res.CTAs) != len(want) {
+		t.Fatalf("ctas = %+v", res.CTAs)
+	}
+	for i, c := range res.CTAs {
+		if c != want[i] {
+			t.Errorf("cta %d = %+v, want %+v", i, c, want[i])
+		}
+	}
+}
+
+func TestDetectCTAs_TranscriptOnly(t *testing.T) {
+	res := DetectCTAs(nil, &ASRResult{Segments: []ASRSegment{
+		{Start: 0, End: 3, Text: "Order today."},
+		{Start: 20, End: 22, Text: "Order today!"},
+	}})
+	if len(res.CTAs) != 2 || res.CTAs[1].Start != 20 || res.OnScreenText != nil {
+		t.Errorf("result = %+v", res)
+	}
+	if res.Provenance.Provider != "local" {
+		t.Errorf("provenance = %+v", res.Provenance)
+	}
+}
+
+func TestReadOnScreenText(t *testing.T) {
+	stubFrameAnswers(t, func(idx int) map[string]any {
+		if idx == 1 {
+			return map[string]any{"text": "  20% OFF\n/  Order   today "}
+		}
+		return map[string]any{"text": ""}
+	})
+	frames, incomplete := ReadOnScreenText(context.Background(), testKeyframes(2), "key")
+	if incomplete || len(frames) != 2 || frames[1].Text != "20% OFF / Order today" || frames[1].TimestampSec != 1.5 {
+		t.Errorf("frames = %+v, incomplete %v", frames, incomplete)
+	}
+}
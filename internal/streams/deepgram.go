@@ -1,42 +1,61 @@
 package streams
 
 import (
-	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
 )
 
 // ASRResult is the output of the Deepgram transcription stream.
 type ASRResult struct {
-	Segments []ASRSegment `json:"segments"`
+	SchemaVersion int          `json:"schema_version"`
+	DurationSec   float64      `json:"duration_sec"`
+	Language      string       `json:"language,omitempty"` // detected or given, as a BCP 47 code; the main one for multilingual ads
+	Segments      []ASRSegment `json:"segments"`
+	Provenance    *Provenance  `json:"provenance,omitempty"`
 }
 
 type ASRSegment struct {
-	Start float64 `json:"start"`
-	End   float64 `json:"end"`
-	Text  string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Language   string  `json:"language,omitempty"` // most of the segment's words', else the transcript's
 }
 
 type wordEntry struct {
-	Word  string  `json:"word"`
-	Start float64 `json:"start"`
-	End   float64 `json:"end"`
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+	Language   string  `json:"language,omitempty"` // with language=multi
 }
 
 // deepgramResponse represents the relevant parts of Deepgram's API response.
 type deepgramResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
 	Results struct {
 		Utterances []struct {
-			Start      float64 `json:"start"`
-			End        float64 `json:"end"`
-			Transcript string  `json:"transcript"`
+			Start      float64     `json:"start"`
+			End        float64     `json:"end"`
+			Transcript string      `json:"transcript"`
+			Confidence float64     `json:"confidence"`
+			Words      []wordEntry `json:"words"`
 		} `json:"utterances"`
 		Channels []struct {
-			Alternatives []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
 				Words []wordEntry `json:"words"`
 			} `json:"alternatives"`
 		} `json:"channels"`
@@ -46,79 +65,275 @@ type deepgramResponse struct {
 // deepgramBaseURL can be overridden in tests.
 var deepgramBaseURL = "https://api.deepgram.com"
 
-// RunASR sends video bytes to Deepgram Nova-3 pre-recorded API and returns
-// timestamped transcript segments.
-func RunASR(ctx context.Context, videoBytes []byte, apiKey string) (*ASRResult, error) {
-	url := deepgramBaseURL + "/v1/listen?model=nova-3&smart_format=true&utterances=true&punctuate=true"
+// Deepgram authentication schemes: the cloud API's "Token <key>", a bearer
+// token, or none for self-hosted deployments that do not check.
+const (
+	DeepgramAuthToken  = "token"
+	DeepgramAuthBearer = "bearer"
+	DeepgramAuthNone   = "none"
+)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(videoBytes))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+var (
+	deepgramAuthScheme = DeepgramAuthToken
+	deepgramSelfHosted bool
+)
+
+// SetDeepgramEndpoint sends ASR requests to a self-hosted Deepgram
+// deployment at baseURL, authenticating with authScheme. An empty baseURL
+// keeps Deepgram's cloud API.
+func SetDeepgramEndpoint(baseURL, authScheme string) {
+	if baseURL != "" {
+		deepgramBaseURL = strings.TrimSuffix(baseURL, "/")
+		deepgramSelfHosted = true
 	}
-	req.Header.Set("Authorization", "Token "+apiKey)
-	req.Header.Set("Content-Type", "video/mp4")
+	deepgramAuthScheme = cmp.Or(authScheme, DeepgramAuthToken)
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("deepgram request: %w", err)
+// authorizeDeepgram adds the API key to req as the auth scheme asks.
+func authorizeDeepgram(req *http.Request, apiKey string) {
+	switch deepgramAuthScheme {
+	case DeepgramAuthNone:
+	case DeepgramAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	default:
+		req.Header.Set("Authorization", "Token "+apiKey)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("deepgram returned %d: %s", resp.StatusCode, string(body))
+// ASROptions tunes a transcription.
+type ASROptions struct {
+	// Language is the BCP 47 code of the language to transcribe, or
+	// LanguageMulti for speech that switches between languages; empty
+	// detects it. See ValidateLanguage.
+	Language string
+
+	// Params are Deepgram query parameters set on top of the defaults, e.g.
+	// {"filler_words": "true"}; an empty value removes a default
+	Params map[string]string
+}
+
+// LanguageMulti asks Deepgram for multilingual transcription, which tags
+// every word with its language.
+const LanguageMulti = "multi"
+
+// ValidateLanguage rejects a language hint that is neither LanguageMulti
+// nor shaped like a BCP 47 code, such as "es" or "pt-BR".
+func ValidateLanguage(lang string) error {
+	if lang == LanguageMulti {
+		return nil
+	}
+	subtags := strings.Split(lang, "-")
+	ok := len(lang) <= 35 && len(subtags[0]) >= 2 && len(subtags[0]) <= 3
+	for _, sub := range subtags {
+		ok = ok && sub != "" && len(sub) <= 8 && strings.Trim(strings.ToLower(sub), "abcdefghijklmnopqrstuvwxyz0123456789") == ""
 	}
+	if !ok {
+		return fmt.Errorf("language %q is not a BCP 47 code or %q", lang, LanguageMulti)
+	}
+	return nil
+}
 
-	var dgResp deepgramResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dgResp); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+// deepgramReservedParams would make Deepgram answer somewhere other than
+// the response RunASR reads.
+var deepgramReservedParams = []string{"callback", "callback_method"}
+
+// ValidateDeepgramParams rejects parameters RunASR cannot honor.
+func ValidateDeepgramParams(params map[string]string) error {
+	for k := range params {
+		if slices.Contains(deepgramReservedParams, k) {
+			return fmt.Errorf("deepgram parameter %q is not supported", k)
+		}
+	}
+	return nil
+}
+
+// RunASR streams the video to Deepgram Nova-3 pre-recorded API and returns
+// timestamped transcript segments. size must be the exact length of video.
+// Retries are only attempted if video is an io.Seeker, since the body has to
+// be replayed from the start.
+func RunASR(ctx context.Context, video io.Reader, size int64, apiKey string, opts ASROptions) (*ASRResult, error) {
+	if err := ValidateDeepgramParams(opts.Params); err != nil {
+		return nil, err
+	}
+	if opts.Language != "" {
+		if err := ValidateLanguage(opts.Language); err != nil {
+			return nil, err
+		}
+	}
+	params := url.Values{
+		"model":        {deepgramModel},
+		"smart_format": {"true"},
+		"utterances":   {"true"},
+		"punctuate":    {"true"},
+
+		// Transcribe in the ad's language rather than assuming English
+		"detect_language": {"true"},
+	}
+	if opts.Language != "" {
+		params.Set("language", opts.Language)
+		params.Del("detect_language")
+	}
+	for k, v := range opts.Params {
+		if v == "" {
+			params.Del(k)
+		} else {
+			params.Set(k, v)
+		}
+	}
+	listenURL := deepgramBaseURL + "/v1/listen?" + params.Encode()
+
+	policy := deepgramRetry
+	seeker, canRewind := video.(io.Seeker)
+	if !canRewind {
+		policy.MaxAttempts = 1
+	}
+
+	var dgResp *deepgramResponse
+	attempt := 0
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempt++
+		if attempt > 1 {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("rewind video: %w", err)
+			}
+		}
+		var err error
+		dgResp, err = callDeepgram(ctx, listenURL, video, size, apiKey)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ASRResult{
+		SchemaVersion: ASRSchemaVersion,
+		DurationSec:   dgResp.Metadata.Duration,
+		Provenance: &Provenance{
+			Provider: "deepgram",
+			Model:    cmp.Or(params.Get("model"), deepgramModel),
+			Params:   flattenParams(params),
+		},
 	}
 
-	result := &ASRResult{}
+	var detected string
+	var words []wordEntry
+	if len(dgResp.Results.Channels) > 0 {
+		ch := dgResp.Results.Channels[0]
+		detected = ch.DetectedLanguage
+		if len(ch.Alternatives) > 0 {
+			words = ch.Alternatives[0].Words
+		}
+	}
+	// The language asked for or, with multilingual transcription, the one
+	// most words are in
+	switch lang := params.Get("language"); lang {
+	case "":
+		result.Language = detected
+	case LanguageMulti:
+		result.Language = cmp.Or(mainLanguage(words), detected)
+	default:
+		result.Language = lang
+	}
 
 	// Primary: use utterances (sentence-level segments with timestamps)
 	for _, u := range dgResp.Results.Utterances {
 		text := strings.TrimSpace(u.Transcript)
 		if text != "" {
 			result.Segments = append(result.Segments, ASRSegment{
-				Start: u.Start,
-				End:   u.End,
-				Text:  text,
+				Start:      u.Start,
+				End:        u.End,
+				Text:       text,
+				Confidence: u.Confidence,
+				Language:   cmp.Or(mainLanguage(u.Words), result.Language),
 			})
 		}
 	}
 
 	// Fallback: if no utterances, group word-level results into ~3s chunks
-	if len(result.Segments) == 0 && len(dgResp.Results.Channels) > 0 {
-		alts := dgResp.Results.Channels[0].Alternatives
-		if len(alts) > 0 {
-			result.Segments = groupWordsIntoChunks(alts[0].Words, 3.0)
+	if len(result.Segments) == 0 {
+		result.Segments = groupWordsIntoChunks(words, 3.0)
+		for i := range result.Segments {
+			result.Segments[i].Language = cmp.Or(result.Segments[i].Language, result.Language)
 		}
 	}
 
+	result.normalize()
 	return result, nil
 }
 
+// callDeepgram makes a single transcription attempt.
+func callDeepgram(ctx context.Context, listenURL string, video io.Reader, size int64, apiKey string) (*deepgramResponse, error) {
+	// NopCloser keeps the transport from closing the caller's reader
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, listenURL, io.NopCloser(video))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = size
+	authorizeDeepgram(req, apiKey)
+	req.Header.Set("Content-Type", "video/mp4")
+
+	if err := DeepgramHealth(); err != nil {
+		return nil, err
+	}
+	if deepgramBreaker != nil {
+		if err := deepgramBreaker.Allow(); err != nil {
+			return nil, fmt.Errorf("deepgram: %w", ErrProviderUnavailable)
+		}
+	}
+
+	start := time.Now()
+	resp, err := deepgramClient.Do(req)
+	if err != nil {
+		recordOutcome(ctx, deepgramBreaker, false)
+		recordHealth(ctx, deepgramHealth, false)
+		deepgramStats.observe(ctx, start, nil, true)
+		return nil, fmt.Errorf("deepgram request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Only server-side trouble counts against the breaker; 4xx means the
+	// provider is up and rejected this particular request.
+	healthy := resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
+	recordOutcome(ctx, deepgramBreaker, healthy)
+	recordHealth(ctx, deepgramHealth, healthy)
+	deepgramStats.observe(ctx, start, resp, !healthy)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, retry.NewHTTPError("deepgram", resp, body)
+	}
+
+	var dgResp deepgramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dgResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &dgResp, nil
+}
+
 func groupWordsIntoChunks(words []wordEntry, chunkDuration float64) []ASRSegment {
 	var segments []ASRSegment
 	var chunk []string
-	var chunkStart float64
-	started := false
+	var chunkStart, confSum float64
+	started, first := false, 0
 
-	for _, w := range words {
+	for i, w := range words {
 		if !started {
 			chunkStart = w.Start
-			started = true
+			started, first = true, i
 		}
 		chunk = append(chunk, w.Word)
+		confSum += w.Confidence
 
 		if w.End-chunkStart >= chunkDuration {
 			segments = append(segments, ASRSegment{
-				Start: chunkStart,
-				End:   w.End,
-				Text:  strings.Join(chunk, " "),
+				Start:      chunkStart,
+				End:        w.End,
+				Text:       strings.Join(chunk, " "),
+				Confidence: confSum / float64(len(chunk)),
+				Language:   mainLanguage(words[first : i+1]),
 			})
 			chunk = nil
+			confSum = 0
 			started = false
 		}
 	}
@@ -126,11 +341,38 @@ func groupWordsIntoChunks(words []wordEntry, chunkDuration float64) []ASRSegment
 	// Flush remaining
 	if len(chunk) > 0 && len(words) > 0 {
 		segments = append(segments, ASRSegment{
-			Start: chunkStart,
-			End:   words[len(words)-1].End,
-			Text:  strings.Join(chunk, " "),
+			Start:      chunkStart,
+			End:        words[len(words)-1].End,
+			Text:       strings.Join(chunk, " "),
+			Confidence: confSum / float64(len(chunk)),
+			Language:   mainLanguage(words[first:]),
 		})
 	}
 
 	return segments
 }
+
+// mainLanguage is the language most of words are tagged with, the first
+// to reach that count on a tie, or "" if none are tagged.
+func mainLanguage(words []wordEntry) string {
+	counts := map[string]int{}
+	var best string
+	for _, w := range words {
+		if w.Language == "" {
+			continue
+		}
+		counts[w.Language]++
+		if counts[w.Language] > counts[best] {
+			best = w.Language
+		}
+	}
+	return best
+}
+
+func flattenParams(v url.Values) map[string]string {
+	out := make(map[string]string, len(v))
+	for k := range v {
+		out[k] = v.Get(k)
+	}
+	return out
+}
@@ -3,10 +3,16 @@ package streams
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/breaker"
 )
 
 // ---------------------------------------------------------------------------
@@ -115,6 +121,9 @@ func TestRunASR_Utterances(t *testing.T) {
 		if r.Header.Get("Authorization") != "Token test-key" {
 			t.Errorf("auth = %q", r.Header.Get("Authorization"))
 		}
+		if r.URL.Query().Get("model") != "nova-3" {
+			t.Errorf("model = %q", r.URL.Query().Get("model"))
+		}
 		if r.Header.Get("Content-Type") != "video/mp4" {
 			t.Errorf("content-type = %q", r.Header.Get("Content-Type"))
 		}
@@ -122,11 +131,15 @@ func TestRunASR_Utterances(t *testing.T) {
 		if string(body) != "fake-video" {
 			t.Errorf("body = %q", string(body))
 		}
+		if r.ContentLength != int64(len("fake-video")) {
+			t.Errorf("content-length = %d", r.ContentLength)
+		}
 
 		json.NewEncoder(w).Encode(map[string]any{
+			"metadata": map[string]any{"duration": 12.5},
 			"results": map[string]any{
 				"utterances": []map[string]any{
-					{"start": 0.0, "end": 2.5, "transcript": "Hello world"},
+					{"start": 0.0, "end": 2.5, "transcript": "Hello world", "confidence": 0.93},
 					{"start": 3.0, "end": 5.0, "transcript": "  Buy now  "},
 					{"start": 6.0, "end": 7.0, "transcript": "   "},
 				},
@@ -139,7 +152,7 @@ func TestRunASR_Utterances(t *testing.T) {
 	deepgramBaseURL = server.URL
 	defer func() { deepgramBaseURL = old }()
 
-	result, err := RunASR(context.Background(), []byte("fake-video"), "test-key")
+	result, err := RunASR(context.Background(), strings.NewReader("fake-video"), 10, "test-key", ASROptions{})
 	if err != nil {
 		t.Fatalf("RunASR error: %v", err)
 	}
@@ -153,6 +166,15 @@ func TestRunASR_Utterances(t *testing.T) {
 	if result.Segments[1].Text != "Buy now" {
 		t.Errorf("seg 1 = %q", result.Segments[1].Text)
 	}
+	if result.Segments[0].Confidence != 0.93 {
+		t.Errorf("seg 0 confidence = %v", result.Segments[0].Confidence)
+	}
+	if result.DurationSec != 12.5 {
+		t.Errorf("duration = %v, want 12.5", result.DurationSec)
+	}
+	if p := result.Provenance; p == nil || p.Provider != "deepgram" || p.Model != "nova-3" || p.Params["utterances"] != "true" {
+		t.Errorf("provenance = %+v", result.Provenance)
+	}
 }
 
 func TestRunASR_FallbackToWords(t *testing.T) {
@@ -186,7 +208,7 @@ func TestRunASR_FallbackToWords(t *testing.T) {
 	deepgramBaseURL = server.URL
 	defer func() { deepgramBaseURL = old }()
 
-	result, err := RunASR(context.Background(), []byte("video"), "key")
+	result, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{})
 	if err != nil {
 		t.Fatalf("RunASR error: %v", err)
 	}
@@ -216,7 +238,7 @@ func TestRunASR_EmptyResponse(t *testing.T) {
 	deepgramBaseURL = server.URL
 	defer func() { deepgramBaseURL = old }()
 
-	result, err := RunASR(context.Background(), []byte("video"), "key")
+	result, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{})
 	if err != nil {
 		t.Fatalf("RunASR error: %v", err)
 	}
@@ -236,8 +258,159 @@ func TestRunASR_ServerError(t *testing.T) {
 	deepgramBaseURL = server.URL
 	defer func() { deepgramBaseURL = old }()
 
-	_, err := RunASR(context.Background(), []byte("video"), "key")
+	_, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{})
 	if err == nil {
 		t.Fatal("expected error for 500 response")
 	}
 }
+
+func TestRunASR_CircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	old := deepgramBaseURL
+	deepgramBaseURL = server.URL
+	defer func() { deepgramBaseURL = old }()
+
+	SetDeepgramBreaker(breaker.New(2, time.Minute))
+	defer SetDeepgramBreaker(nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{}); err == nil {
+			t.Fatal("expected error for 502 response")
+		}
+	}
+
+	_, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{})
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want ErrProviderUnavailable", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected open breaker to skip the request, got %d calls", calls)
+	}
+}
+
+func TestRunASR_SelfHosted(t *testing.T) {
+	for _, tc := range []struct {
+		scheme, want string
+	}{
+		{DeepgramAuthBearer, "Bearer key"},
+		{DeepgramAuthNone, ""},
+	} {
+		t.Run(tc.scheme, func(t *testing.T) {
+			var path, auth string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, auth = r.URL.Path, r.Header.Get("Authorization")
+				json.NewEncoder(w).Encode(map[string]any{"results": map[string]any{}})
+			}))
+			defer server.Close()
+
+			oldURL, oldClient := deepgramBaseURL, deepgramClient
+			t.Cleanup(func() {
+				deepgramBaseURL, deepgramClient, deepgramSelfHosted = oldURL, oldClient, false
+				SetDeepgramEndpoint("", "")
+			})
+			SetDeepgramEndpoint(server.URL+"/", tc.scheme)
+			deepgramClient = server.Client()
+
+			if _, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if path != "/v1/listen" || auth != tc.want {
+				t.Errorf("path = %q, authorization = %q, want %q", path, auth, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunASR_Params(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		json.NewEncoder(w).Encode(map[string]any{"results": map[string]any{}})
+	}))
+	defer server.Close()
+	old := deepgramBaseURL
+	deepgramBaseURL = server.URL
+	defer func() { deepgramBaseURL = old }()
+
+	opts := ASROptions{Params: map[string]string{"filler_words": "true", "model": "nova-3-medical", "smart_format": ""}}
+	result, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("filler_words") != "true" || query.Get("model") != "nova-3-medical" || query.Has("smart_format") || query.Get("utterances") != "true" {
+		t.Errorf("query = %v", query)
+	}
+	if result.Provenance.Model != "nova-3-medical" || result.Provenance.Params["filler_words"] != "true" {
+		t.Errorf("provenance = %+v", result.Provenance)
+	}
+
+	if _, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{Params: map[string]string{"callback": "https://example.com"}}); err == nil {
+		t.Error("callback accepted")
+	}
+}
+
+func TestRunASR_Language(t *testing.T) {
+	var query url.Values
+	var answer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(answer))
+	}))
+	defer server.Close()
+	old := deepgramBaseURL
+	deepgramBaseURL = server.URL
+	defer func() { deepgramBaseURL = old }()
+
+	answer = `{"results":{"utterances":[{"start":0,"end":1,"transcript":"Hola","confidence":0.9}]}}`
+	result, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{Language: "es"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("language") != "es" || query.Has("detect_language") {
+		t.Errorf("query = %v", query)
+	}
+	if result.Language != "es" || result.Segments[0].Language != "es" {
+		t.Errorf("result = %+v", result)
+	}
+
+	answer = `{"results":{
+		"utterances":[
+			{"start":0,"end":1,"transcript":"Hola amigos","confidence":0.9,"words":[{"word":"hola","language":"es"},{"word":"amigos","language":"es"}]},
+			{"start":1,"end":2,"transcript":"Buy now","confidence":0.9,"words":[{"word":"buy","language":"en"},{"word":"now","language":"en"}]},
+			{"start":2,"end":3,"transcript":"Gracias","confidence":0.9,"words":[{"word":"gracias","language":"es"}]}],
+		"channels":[{"alternatives":[{"words":[
+			{"word":"hola","language":"es"},{"word":"amigos","language":"es"},{"word":"buy","language":"en"},
+			{"word":"now","language":"en"},{"word":"gracias","language":"es"}]}]}]}}`
+	result, err = RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{Language: LanguageMulti})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var langs []string
+	for _, s := range result.Segments {
+		langs = append(langs, s.Language)
+	}
+	if query.Get("language") != "multi" || result.Language != "es" || strings.Join(langs, ",") != "es,en,es" {
+		t.Errorf("language %q, segments %v", result.Language, langs)
+	}
+
+	if _, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{Language: "spanish please"}); err == nil {
+		t.Error("invalid language accepted")
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	for lang, ok := range map[string]bool{
+		"es": true, "pt-BR": true, "de": true, "es-419": true, "zh-Hant-TW": true, "multi": true,
+		"": false, "e": false, "spanish": false, "pt_BR": false, "en-": false, "en&x=1": false,
+	} {
+		if err := ValidateLanguage(lang); (err == nil) != ok {
+			t.Errorf("%q: %v", lang, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// EntitiesResult is the output of the entities stream: what the transcript
+// names that downstream systems key on.
+type EntitiesResult struct {
+	Entities   []Entity    `json:"entities"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Entity is one mention in the transcript, timed by the segment it was said
+// in.
+type Entity struct {
+	Type    string  `json:"type"`  // one of entityTypes
+	Text    string  `json:"text"`  // as transcribed
+	Value   string  `json:"value"` // normalized; see normalizeEntity
+	Segment int     `json:"segment"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+}
+
+var entityTypes = []string{"brand", "product", "price", "discount_code", "url"}
+
+const entitiesPromptTemplate = `Below is the transcript of a video advertisement, one numbered segment per line with its start and end in seconds.
+
+%s
+List every mention of:
+- brand: a company or brand name
+- product: a named product or product line (not a generic word such as "bottle")
+- price: an amount of money, including discounts stated as amounts or percentages ("twenty percent off")
+- discount_code: a promo, discount or coupon code
+- url: a website, domain or app store name given as a destination
+
+For each give the segment it is in, the words exactly as they appear in that segment (text), and a normalized value: prices in digits with the currency symbol or a percent sign ("$19.99", "20%%"), codes in capitals without spaces, URLs as a bare lowercase domain and path ("example.com/shop"), names as usually spelled. Mentions spoken as words ("example dot com") count. List each mention once per segment; give an empty array when there are none.
+
+Respond with a JSON array:
+[{"type": "<type>", "segment": <int>, "text": "<text>", "value": "<value>"}]`
+
+// RunEntities extracts brands, products, prices, discount codes and URLs
+// from the transcript with Gemini. Mentions whose text is not in the
+// segment they are attributed to are dropped, as are unknown types.
+func RunEntities(ctx context.Context, asr *ASRResult, apiKey string) (*EntitiesResult, error) {
+	res := &EntitiesResult{
+		Entities:   []Entity{},
+		Provenance: geminiProvenance(entitiesPromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	if asr == nil || len(asr.Segments) == 0 {
+		return res, nil
+	}
+
+	var lines strings.Builder
+	for i, s := range asr.Segments {
+		fmt.Fprintf(&lines, "[%d] (%.1f-%.1f) %s\n", i, s.Start, s.End, strings.TrimSpace(s.Text))
+	}
+	var answer []Entity
+	if err := callGeminiJSON(ctx, apiKey, fmt.Sprintf(entitiesPromptTemplate, lines.String()), &answer); err != nil {
+		return nil, fmt.Errorf("extract entities: %w", err)
+	}
+
+	for _, e := range answer {
+		e.Type = strings.ToLower(strings.TrimSpace(e.Type))
+		e.Text = strings.Join(strings.Fields(e.Text), " ")
+		if !slices.Contains(entityTypes, e.Type) || e.Segment < 0 || e.Segment >= len(asr.Segments) || e.Text == "" {
+			continue
+		}
+		seg := asr.Segments[e.Segment]
+		if !strings.Contains(strings.ToLower(strings.Join(strings.Fields(seg.Text), " ")), strings.ToLower(e.Text)) {
+			continue
+		}
+		e.Value = normalizeEntity(e.Type, e.Value, e.Text)
+		e.Start, e.End = round3(seg.Start), round3(seg.End)
+		if !slices.ContainsFunc(res.Entities, func(prev Entity) bool {
+			return prev.Type == e.Type && prev.Value == e.Value && prev.Segment == e.Segment
+		}) {
+			res.Entities = append(res.Entities, e)
+		}
+	}
+	slices.SortStableFunc(res.Entities, func(a, b Entity) int { return a.Segment - b.Segment })
+	return res, nil
+}
+
+// normalizeEntity tidies the model's normalized value, falling back to the
+// text as said: codes are capitalized without spaces and URLs lowercased
+// without scheme or "www.".
+func normalizeEntity(typ, value, text string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		value = text
+	}
+	switch typ {
+	case "discount_code":
+		return strings.ToUpper(strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, value))
+	case "url":
+		value = strings.ToLower(value)
+		for _, prefix := range []string{"https://", "http://", "www."} {
+			value = strings.TrimPrefix(value, prefix)
+		}
+		return strings.TrimRight(value, "/.")
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunEntities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !strings.Contains(req.Contents[0].Parts[0].Text, "[1] (4.0-9.0) Use code summer 20 at Example dot com.") {
+			t.Errorf("prompt lacks numbered segments:\n%s", req.Contents[0].Parts[0].Text)
+		}
+		w.Write(mockGeminiResponse(`[
+			{"type": "url", "segment": 1, "text": "example dot com", "value": "https://www.Example.com/"},
+			{"type": "discount_code", "segment": 1, "text": "summer 20", "value": "summer 20"},
+			{"type": "Brand", "segment": 0, "text": "Hydro  Peak", "value": "HydroPeak"},
+			{"type": "brand", "segment": 0, "text": "HydroPeak", "value": "HydroPeak"},
+			{"type": "price", "segment": 0, "text": "$5", "value": "$5"},
+			{"type": "price", "segment": 7, "text": "$5", "value": "$5"},
+			{"type": "slogan", "segment": 0, "text": "Meet", "value": "Meet"}
+		]`))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	asr := &ASRResult{Segments: []ASRSegment{
+		{Start: 0, End: 4, Text: "Meet the Hydro Peak bottle."},
+		{Start: 4, End: 9, Text: "Use code summer 20 at Example dot com."},
+	}}
+	res, err := RunEntities(context.Background(), asr, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second brand repeats the first's value in its segment; the $5 is
+	// not in segment 0, segment 7 does not exist and slogans are not a type
+	want := []Entity{
+		{Type: "brand", Text: "Hydro Peak", Value: "HydroPeak", Segment: 0, Start: 0, End: 4},
+		{Type: "url", Text: "example dot com", Value: "example.com", Segment: 1, Start: 4, End: 9},
+		{Type: "discount_code", Text: "summer 20", Value: "SUMMER20", Segment: 1, Start: 4, End: 9},
+	}
+	if len(res.Entities) != len(want) {
+		t.Fatalf("entities = %+v", res.Entities)
+	}
+	for i := range want {
+		if res.Entities[i] != want[i] {
+			t.Errorf("entity %d = %+v, want %+v", i, res.Entities[i], want[i])
+		}
+	}
+}
+
+func TestRunEntities_NoTranscript(t *testing.T) {
+	res, err := RunEntities(context.Background(), &ASRResult{}, "key")
+	if err != nil || res.Entities == nil || len(res.Entities) != 0 {
+		t.Errorf("RunEntities = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,154 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/bufpool"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// geminiFileThreshold is the image size above which frames are uploaded via
+// the Gemini Files API and referenced by URI rather than inlined as base64,
+// which inflates the request by a third and can hit request size limits.
+// 0 always inlines.
+var geminiFileThreshold int
+
+// SetGeminiFileThreshold sets the size in bytes above which keyframes are
+// sent through the Files API.
+func SetGeminiFileThreshold(n int) {
+	geminiFileThreshold = n
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mime_type"`
+	FileURI  string `json:"file_uri"`
+}
+
+type geminiFile struct {
+	Name string `json:"name"` // e.g. "files/abc123"
+	URI  string `json:"uri"`
+}
+
+// imagePart returns the request part for a JPEG frame, uploading it first if
+// it is over the threshold. cleanup deletes the uploaded file; Gemini would
+// expire it after 48 hours anyway, so deletion failures are ignored.
+func imagePart(ctx context.Context, apiKey string, img []byte) (part geminiPart, cleanup func(), err error) {
+	// Vertex AI has no Files API; its file references are Cloud Storage URIs
+	if geminiVertex != nil || geminiFileThreshold <= 0 || len(img) <= geminiFileThreshold {
+		return geminiPart{InlineData: &geminiInline{
+			MimeType: "image/jpeg",
+			Data:     encodeBase64(img),
+		}}, func() {}, nil
+	}
+
+	f, err := uploadGeminiFile(ctx, apiKey, img, "image/jpeg")
+	if err != nil {
+		return geminiPart{}, nil, err
+	}
+	return geminiPart{FileData: &geminiFileData{MimeType: "image/jpeg", FileURI: f.URI}},
+		func() { deleteGeminiFile(context.WithoutCancel(ctx), apiKey, f.Name) }, nil
+}
+
+// encodeBase64 is base64.StdEncoding.EncodeToString with the intermediate
+// byte slice taken from the buffer pool, leaving the string as the only
+// allocation.
+func encodeBase64(src []byte) string {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	n := base64.StdEncoding.EncodedLen(len(src))
+	buf.Grow(n)
+	dst := buf.AvailableBuffer()[:n]
+	base64.StdEncoding.Encode(dst, src)
+	return string(dst)
+}
+
+// uploadGeminiFile stores data with the Files API using the two-step
+// resumable protocol: a start request that returns a session URL, then a
+// single upload-and-finalize request carrying the bytes.
+func uploadGeminiFile(ctx context.Context, apiKey string, data []byte, mimeType string) (*geminiFile, error) {
+	var file geminiFile
+	err := retry.Do(ctx, geminiRetry, func(ctx context.Context) error {
+		start, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			geminiBaseURL+"/upload/v1beta/files?key="+apiKey, bytes.NewReader([]byte(`{"file":{}}`)))
+		if err != nil {
+			return fmt.Errorf("create upload request: %w", err)
+		}
+		start.Header.Set("Content-Type", "application/json")
+		start.Header.Set("X-Goog-Upload-Protocol", "resumable")
+		start.Header.Set("X-Goog-Upload-Command", "start")
+		start.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(data)))
+		start.Header.Set("X-Goog-Upload-Header-Content-Type", mimeType)
+
+		resp, err := geminiClient.Do(start)
+		if err != nil {
+			return fmt.Errorf("gemini file upload: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewHTTPError("gemini", resp, body)
+		}
+		sessionURL := resp.Header.Get("X-Goog-Upload-URL")
+		if sessionURL == "" {
+			return fmt.Errorf("gemini file upload: no upload URL returned")
+		}
+
+		upload, err := http.NewRequestWithContext(ctx, http.MethodPost, sessionURL, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("create upload request: %w", err)
+		}
+		upload.Header.Set("X-Goog-Upload-Offset", "0")
+		upload.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+
+		resp, err = geminiClient.Do(upload)
+		if err != nil {
+			return fmt.Errorf("gemini file upload: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read upload response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewHTTPError("gemini", resp, body)
+		}
+
+		var out struct {
+			File geminiFile `json:"file"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return fmt.Errorf("decode upload response: %w", err)
+		}
+		if out.File.URI == "" {
+			return fmt.Errorf("gemini file upload: no file URI returned")
+		}
+		file = out.File
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func deleteGeminiFile(ctx context.Context, apiKey, name string) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, geminiURL(apiKey, name), nil)
+	if err != nil || authorizeGemini(req) != nil {
+		return
+	}
+	if resp, err := geminiClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,87 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallGemini_LargeFrameUsesFilesAPI(t *testing.T) {
+	var steps []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/upload/v1beta/files":
+			steps = append(steps, "start")
+			if r.Header.Get("X-Goog-Upload-Header-Content-Length") != "10" {
+				t.Errorf("declared length = %q", r.Header.Get("X-Goog-Upload-Header-Content-Length"))
+			}
+			w.Header().Set("X-Goog-Upload-URL", server.URL+"/upload-session/1")
+		case r.URL.Path == "/upload-session/1":
+			steps = append(steps, "upload")
+			if body, _ := io.ReadAll(r.Body); string(body) != "0123456789" {
+				t.Errorf("uploaded %q", body)
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"file": map[string]any{"name": "files/abc", "uri": "https://files.example/abc"},
+			})
+		case r.Method == http.MethodDelete:
+			steps = append(steps, "delete "+r.URL.Path)
+		default:
+			steps = append(steps, "generate")
+			var req geminiRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			img := req.Contents[0].Parts[1]
+			if img.InlineData != nil || img.FileData == nil || img.FileData.FileURI != "https://files.example/abc" {
+				t.Errorf("image part = %+v", img)
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"candidates": []map[string]any{
+					{"content": map[string]any{"parts": []map[string]any{{"text": "big frame"}}}},
+				},
+			})
+		}
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	SetGeminiFileThreshold(8)
+	defer SetGeminiFileThreshold(0)
+
+	desc, err := callGemini(context.Background(), "key", []byte("0123456789"), "prompt")
+	if err != nil {
+		t.Fatalf("callGemini error: %v", err)
+	}
+	if desc != "big frame" {
+		t.Errorf("desc = %q", desc)
+	}
+	want := []string{"start", "upload", "generate", "delete /v1beta/files/abc"}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %v, want %v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %q, want %q", i, steps[i], want[i])
+		}
+	}
+}
+
+func TestImagePart_SmallFrameInlined(t *testing.T) {
+	SetGeminiFileThreshold(8)
+	defer SetGeminiFileThreshold(0)
+
+	part, cleanup, err := imagePart(context.Background(), "key", []byte("tiny"))
+	if err != nil {
+		t.Fatalf("imagePart error: %v", err)
+	}
+	cleanup()
+	if part.InlineData == nil || part.FileData != nil {
+		t.Errorf("part = %+v, want inline data", part)
+	}
+}
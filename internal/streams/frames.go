@@ -0,0 +1,98 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// analysisBatchSize is how many keyframes the per-frame analysis streams
+// send in one request. Their answers are a few short fields per frame, so
+// larger batches than the descriptions' are fine.
+const analysisBatchSize = 8
+
+// frameAnswer is one keyframe's answer to a per-frame analysis.
+type frameAnswer[T any] struct {
+	kf    KeyframeInput
+	value T
+	err   error
+}
+
+// analyzeFrames asks Gemini the same structured question about every
+// keyframe, in JSON-mode batches. prompt is formatted with the batch size
+// and must ask for a JSON array of objects carrying each frame's
+// "frame_index" beside T's fields. Every frame gets an answer or an error;
+// once ctx ends or its token budget runs out the remaining frames are
+// dropped, so fewer answers than keyframes means the run was cut short.
+func analyzeFrames[T any](ctx context.Context, apiKey string, keyframes []KeyframeInput, prompt string) []frameAnswer[T] {
+	var answers []frameAnswer[T]
+	var batch []loadedFrame
+	flush := func() {
+		var ready []loadedFrame
+		for _, lf := range batch {
+			if lf.err != nil {
+				answers = append(answers, frameAnswer[T]{kf: lf.kf, err: lf.err})
+			} else {
+				ready = append(ready, lf)
+			}
+		}
+		if len(ready) > 0 {
+			answers = append(answers, askFrames[T](withHedging(ctx), apiKey, ready, prompt)...)
+		}
+		for i := range batch {
+			batch[i].release()
+		}
+		batch = batch[:0]
+	}
+
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+	for lf := range prefetchFrames(fetchCtx, keyframes) {
+		if ctx.Err() != nil || BudgetExhausted(ctx) {
+			lf.release()
+			break
+		}
+		batch = append(batch, lf)
+		if len(batch) == analysisBatchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 && ctx.Err() == nil {
+		flush()
+	}
+	for i := range batch {
+		batch[i].release()
+	}
+	return answers
+}
+
+func askFrames[T any](ctx context.Context, apiKey string, frames []loadedFrame, prompt string) []frameAnswer[T] {
+	var raw []json.RawMessage
+	err := callGeminiBatch(ctx, apiKey, fmt.Sprintf(prompt, len(frames)), frames, &raw)
+
+	byIndex := make(map[int]json.RawMessage, len(raw))
+	for _, r := range raw {
+		var id struct {
+			FrameIndex int `json:"frame_index"`
+		}
+		if json.Unmarshal(r, &id) == nil {
+			byIndex[id.FrameIndex] = r
+		}
+	}
+	answers := make([]frameAnswer[T], len(frames))
+	for i, lf := range frames {
+		a := &answers[i]
+		a.kf = lf.kf
+		switch r, ok := byIndex[lf.kf.FrameIndex]; {
+		case err != nil:
+			a.err = err
+		case !ok:
+			a.err = fmt.Errorf("no answer returned for frame %d", lf.kf.FrameIndex)
+		default:
+			if err := json.Unmarshal(r, &a.value); err != nil {
+				a.err = fmt.Errorf("decode answer for frame %d: %w", lf.kf.FrameIndex, err)
+			}
+		}
+	}
+	return answers
+}
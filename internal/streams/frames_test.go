@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubFrameAnswers points Gemini at a server answering per-frame analysis
+// batches with answer(frame index) for each labelled frame; a nil answer
+// leaves the frame out. It returns the prompts received.
+func stubFrameAnswers(t *testing.T, answer func(idx int) map[string]any) *[]string {
+	t.Helper()
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.GenerationConfig == nil || req.GenerationConfig.ResponseMimeType != "application/json" {
+			t.Error("frame analysis should use JSON mode")
+		}
+		parts := req.Contents[0].Parts
+		prompts = append(prompts, parts[0].Text)
+		frames := []map[string]any{}
+		for _, p := range parts[1:] {
+			var idx int
+			var ts float64
+			if _, err := fmt.Sscanf(p.Text, "Frame %d at %fs:", &idx, &ts); err != nil {
+				continue
+			}
+			if a := answer(idx); a != nil {
+				a["frame_index"] = idx
+				frames = append(frames, a)
+			}
+		}
+		text, _ := json.Marshal(frames)
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": string(text)}}}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	t.Cleanup(func() { geminiBaseURL = old })
+	return &prompts
+}
+
+func testKeyframes(n int) []KeyframeInput {
+	var keyframes []KeyframeInput
+	for i := range n {
+		keyframes = append(keyframes, KeyframeInput{FrameIndex: i, TimestampSec: float64(i) * 1.5, ImageBytes: []byte("img")})
+	}
+	return keyframes
+}
+
+func TestAnalyzeFrames_Batches(t *testing.T) {
+	prompts := stubFrameAnswers(t, func(idx int) map[string]any {
+		if idx == 3 {
+			return nil
+		}
+		return map[string]any{"value": idx * 10}
+	})
+	keyframes := testKeyframes(analysisBatchSize + 2)
+	keyframes[5].ImageBytes, keyframes[5].Fetch = nil, func(context.Context) ([]byte, error) { return nil, fmt.Errorf("gone") }
+
+	answers := analyzeFrames[struct{ Value int }](context.Background(), "key", keyframes, "Frames: %d")
+	if len(*prompts) != 2 || (*prompts)[0] != fmt.Sprintf("Frames: %d", analysisBatchSize-1) || (*prompts)[1] != "Frames: 2" {
+		t.Errorf("prompts = %q", *prompts)
+	}
+	if len(answers) != len(keyframes) {
+		t.Fatalf("%d answers for %d frames", len(answers), len(keyframes))
+	}
+	for _, a := range answers {
+		switch idx := a.kf.FrameIndex; idx {
+		case 3, 5:
+			if a.err == nil {
+				t.Errorf("frame %d: no error", idx)
+			}
+		default:
+			if a.err != nil || a.value.Value != idx*10 {
+				t.Errorf("frame %d: %+v, %v", idx, a.value, a.err)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// geminiHedgeAfter is how long a per-frame Gemini request may go unanswered
+// before a second, identical request is sent (0 = never hedge).
+var geminiHedgeAfter time.Duration
+
+// SetGeminiHedge enables hedged per-frame requests. Each hedge is a real
+// request and draws from the rate limit budget like any other.
+func SetGeminiHedge(after time.Duration) {
+	geminiHedgeAfter = after
+}
+
+var errHedgeLost = errors.New("hedged request superseded")
+
+type hedgeKey struct{}
+
+// withHedging marks ctx so Gemini requests made under it may be hedged.
+// Only per-frame calls opt in: their latency is predictable enough for a
+// fixed threshold, unlike summaries over a whole timeline.
+func withHedging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hedgeKey{}, true)
+}
+
+// postGeminiHedged is postGemini, except that under withHedging a second
+// request is started if the first has not answered within geminiHedgeAfter.
+// The first success wins and the other request is cancelled; if both fail
+// the first error is returned.
+func postGeminiHedged(ctx context.Context, url string, bodyBytes []byte, tokens int) ([]byte, error) {
+	after := geminiHedgeAfter
+	if after <= 0 || ctx.Value(hedgeKey{}) == nil {
+		return postGemini(ctx, url, bodyBytes, tokens)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errHedgeLost)
+
+	type outcome struct {
+		body []byte
+		err  error
+	}
+	results := make(chan outcome, 2)
+	launch := func() {
+		go func() {
+			body, err := postGemini(ctx, url, bodyBytes, tokens)
+			results <- outcome{body, err}
+		}()
+	}
+
+	launch()
+	inFlight := 1
+	timer := time.NewTimer(after)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if inFlight == 1 && firstErr == nil {
+				launch()
+				inFlight++
+			}
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				return r.body, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if inFlight == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
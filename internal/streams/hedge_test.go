@@ -0,0 +1,79 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunVLM_HedgesSlowFrame(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := "hedge"
+		if calls.Add(1) == 1 {
+			// The first attempt stalls well past the hedge threshold
+			time.Sleep(300 * time.Millisecond)
+			text = "original"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": text}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	SetGeminiHedge(20 * time.Millisecond)
+	defer SetGeminiHedge(0)
+
+	t0 := time.Now()
+	result, err := RunVLM(context.Background(), []KeyframeInput{{ImageBytes: []byte("img")}}, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if got := result.Frames[0].Description; got != "hedge" {
+		t.Errorf("desc = %q, want the hedged answer", got)
+	}
+	if elapsed := time.Since(t0); elapsed >= 300*time.Millisecond {
+		t.Errorf("took %v, should not wait for the slow request", elapsed)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2", n)
+	}
+}
+
+func TestCallGeminiText_NotHedged(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": "ok"}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	SetGeminiHedge(time.Millisecond)
+	defer SetGeminiHedge(0)
+
+	if _, err := callGeminiText(context.Background(), "key", "prompt"); err != nil {
+		t.Fatalf("callGeminiText error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("calls = %d, want 1 (only frame requests are hedged)", n)
+	}
+}
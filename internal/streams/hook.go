@@ -0,0 +1,159 @@
+package streams
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// HookResult is the output of the hook_analysis stream: how the ad's opening
+// seconds try to stop the scroll.
+type HookResult struct {
+	WindowSec float64 `json:"window_sec"` // how much of the ad was assessed, from the start
+	Frames    []int   `json:"frames"`     // indexes of the keyframes shown to the model
+	Spoken    string  `json:"spoken"`     // transcript within the window
+
+	HookType       string   `json:"hook_type"` // one of hookTypes
+	ProductVisible bool     `json:"product_visible"`
+	TextOnScreen   bool     `json:"text_on_screen"`
+	OnScreenText   string   `json:"on_screen_text,omitempty"`
+	Techniques     []string `json:"techniques"` // from hookTechniques
+	Strength       string   `json:"strength"`   // "weak", "moderate" or "strong"
+	Rationale      string   `json:"rationale"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// HookWindowSec is the opening the hook_analysis stream assesses.
+const HookWindowSec = 3.0
+
+// hookMaxFrames caps the keyframes sent; windows with more are thinned
+// evenly.
+const hookMaxFrames = 6
+
+var (
+	hookTypes      = []string{"other", "question", "bold_claim", "problem", "demonstration", "testimonial", "before_after", "curiosity", "humor", "shock", "offer", "story"}
+	hookTechniques = []string{"face_to_camera", "direct_address", "fast_cuts", "motion", "close_up", "text_overlay", "pattern_interrupt", "product_in_use", "bright_colors", "sound_effect", "unexpected_visual", "social_proof"}
+	hookStrengths  = []string{"moderate", "weak", "strong"}
+)
+
+const hookPromptTemplate = `These %d frames are the first %.0f seconds of a video advertisement, the "hook" meant to stop viewers from scrolling past. Each image is preceded by its frame index and timestamp.
+Spoken in that time: %s
+
+Assess the hook:
+- hook_type: the main device, one of "question", "bold_claim", "problem", "demonstration", "testimonial", "before_after", "curiosity", "humor", "shock", "offer", "story", or "other"
+- product_visible: true if the advertised product can be seen
+- text_on_screen: true if any text is overlaid or shown; on_screen_text: that text, lines joined with " / "
+- techniques: the attention techniques used, any of "face_to_camera", "direct_address", "fast_cuts", "motion", "close_up", "text_overlay", "pattern_interrupt", "product_in_use", "bright_colors", "sound_effect", "unexpected_visual", "social_proof"
+- strength: how likely the opening is to hold a scrolling viewer, "weak", "moderate" or "strong"
+- rationale: one sentence explaining the strength
+
+Respond with a JSON object:
+{"hook_type": "<type>", "product_visible": <bool>, "text_on_screen": <bool>, "on_screen_text": "<text>", "techniques": ["<technique>"], "strength": "<strength>", "rationale": "<sentence>"}`
+
+// RunHook assesses the ad's first HookWindowSec from the keyframes in it
+// and the transcript, which may be nil. When no keyframe falls inside the
+// window, the first keyframe stands in.
+func RunHook(ctx context.Context, keyframes []KeyframeInput, asr *ASRResult, apiKey string) (*HookResult, error) {
+	window := hookKeyframes(keyframes)
+	if len(window) == 0 {
+		return nil, errors.New("no keyframes")
+	}
+	spoken := hookTranscript(asr)
+
+	var frames []loadedFrame
+	defer func() {
+		for i := range frames {
+			frames[i].release()
+		}
+	}()
+	for lf := range prefetchFrames(ctx, window) {
+		frames = append(frames, lf)
+	}
+	if len(frames) < len(window) {
+		return nil, context.Cause(ctx)
+	}
+	for _, lf := range frames {
+		if lf.err != nil {
+			return nil, lf.err
+		}
+	}
+
+	said := fmt.Sprintf("%q", spoken)
+	if spoken == "" {
+		said = "(nothing)"
+	}
+	var answer HookResult
+	prompt := fmt.Sprintf(hookPromptTemplate, len(frames), HookWindowSec, said)
+	if err := callGeminiBatch(withHedging(ctx), apiKey, prompt, frames, &answer); err != nil {
+		return nil, fmt.Errorf("assess hook: %w", err)
+	}
+
+	res := &HookResult{
+		WindowSec:      HookWindowSec,
+		Frames:         []int{},
+		Spoken:         spoken,
+		HookType:       oneOf(answer.HookType, hookTypes),
+		ProductVisible: answer.ProductVisible,
+		TextOnScreen:   answer.TextOnScreen,
+		OnScreenText:   strings.TrimSpace(answer.OnScreenText),
+		Techniques:     []string{},
+		Strength:       oneOf(answer.Strength, hookStrengths),
+		Rationale:      strings.TrimSpace(answer.Rationale),
+		Provenance:     geminiProvenance(hookPromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	for _, kf := range window {
+		res.Frames = append(res.Frames, kf.FrameIndex)
+	}
+	for _, t := range answer.Techniques {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if slices.Contains(hookTechniques, t) && !slices.Contains(res.Techniques, t) {
+			res.Techniques = append(res.Techniques, t)
+		}
+	}
+	if !res.TextOnScreen {
+		res.OnScreenText = ""
+	}
+	return res, nil
+}
+
+// hookKeyframes picks up to hookMaxFrames keyframes inside the window, in
+// time order, or the first keyframe if none is.
+func hookKeyframes(keyframes []KeyframeInput) []KeyframeInput {
+	sorted := slices.Clone(keyframes)
+	slices.SortStableFunc(sorted, func(a, b KeyframeInput) int { return cmp.Compare(a.TimestampSec, b.TimestampSec) })
+	var in []KeyframeInput
+	for _, kf := range sorted {
+		if kf.TimestampSec < HookWindowSec {
+			in = append(in, kf)
+		}
+	}
+	if len(in) == 0 {
+		return sorted[:min(len(sorted), 1)]
+	}
+	if len(in) <= hookMaxFrames {
+		return in
+	}
+	out := make([]KeyframeInput, hookMaxFrames)
+	for i := range out {
+		out[i] = in[i*(len(in)-1)/(hookMaxFrames-1)]
+	}
+	return out
+}
+
+// hookTranscript is the text of the segments that start inside the window.
+func hookTranscript(asr *ASRResult) string {
+	if asr == nil {
+		return ""
+	}
+	var said []string
+	for _, s := range asr.Segments {
+		if s.Start < HookWindowSec {
+			said = append(said, strings.TrimSpace(s.Text))
+		}
+	}
+	return strings.Join(said, " ")
+}
@@ -0,0 +1,71 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunHook(t *testing.T) {
+	var prompt string
+	var images int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		parts := req.Contents[0].Parts
+		prompt = parts[0].Text
+		images = (len(parts) - 1) / 2
+		w.Write(mockGeminiResponse(`{"hook_type": "Question", "product_visible": true, "text_on_screen": false,
+			"on_screen_text": "ignored", "techniques": ["face_to_camera", "Fast_Cuts", "lens_flare", "fast_cuts"],
+			"strength": "strong", "rationale": " Opens on a direct question. "}`))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	// keyframes at 0, 1.5, 3 and 4.5s: the last two are outside the window
+	asr := &ASRResult{Segments: []ASRSegment{
+		{Start: 0.2, End: 2.5, Text: "Ever lost your keys?"},
+		{Start: 3.5, End: 5, Text: "Never again."},
+	}}
+	res, err := RunHook(context.Background(), testKeyframes(4), asr, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images != 2 || !slices.Equal(res.Frames, []int{0, 1}) {
+		t.Errorf("sent %d frames, result frames %v", images, res.Frames)
+	}
+	if !strings.Contains(prompt, `Spoken in that time: "Ever lost your keys?"`) {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if res.HookType != "question" || !res.ProductVisible || res.TextOnScreen || res.OnScreenText != "" {
+		t.Errorf("result = %+v", res)
+	}
+	if !slices.Equal(res.Techniques, []string{"face_to_camera", "fast_cuts"}) || res.Strength != "strong" || res.Rationale != "Opens on a direct question." {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestHookKeyframes(t *testing.T) {
+	var many []KeyframeInput
+	for i := range 12 {
+		many = append(many, KeyframeInput{FrameIndex: i, TimestampSec: float64(i) * 0.25})
+	}
+	var idx []int
+	for _, kf := range hookKeyframes(many) {
+		idx = append(idx, kf.FrameIndex)
+	}
+	if !slices.Equal(idx, []int{0, 2, 4, 6, 8, 11}) {
+		t.Errorf("thinned to %v", idx)
+	}
+
+	late := []KeyframeInput{{FrameIndex: 7, TimestampSec: 8}, {FrameIndex: 5, TimestampSec: 4}}
+	if got := hookKeyframes(late); len(got) != 1 || got[0].FrameIndex != 5 {
+		t.Errorf("no frame in window: got %+v", got)
+	}
+}
@@ -0,0 +1,256 @@
+package streams
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Canned answers for mock mode, used when no fixture overrides them. They
+// describe the same imaginary 15-second ad so that every stream agrees.
+const (
+	mockDeepgramResponse = `{
+  "metadata": {"duration": 15.0},
+  "results": {"utterances": [
+    {"start": 0.0, "end": 4.0, "transcript": "Meet the bottle that goes everywhere you do.", "confidence": 0.98},
+    {"start": 4.5, "end": 9.0, "transcript": "It keeps drinks cold for twenty-four hours.", "confidence": 0.97},
+    {"start": 9.5, "end": 14.5, "transcript": "Order today and get twenty percent off.", "confidence": 0.98}
+  ], "channels": [{"detected_language": "en"}]}
+}`
+	mockKeyMoments = `[
+  {"type": "hook", "start": 0.0, "end": 3.0, "description": "A hiker pulls the bottle from a backpack at sunrise."},
+  {"type": "product_reveal", "start": 4.0, "end": 8.0, "description": "Close-up of the bottle with condensation on its side."},
+  {"type": "offer", "start": 9.5, "end": 14.5, "description": "Twenty percent off is announced."},
+  {"type": "cta", "start": 12.0, "end": 15.0, "description": "Order today."}
+]`
+	mockSummary    = "An ad for an insulated bottle that keeps drinks cold for twenty-four hours, shown on a sunrise hike. It closes with twenty percent off and a call to order today."
+	mockMusic      = `{"has_music": true, "genre": "folk", "mood": "uplifting", "energy": "medium", "vocals": false}`
+	mockHook       = `{"hook_type": "demonstration", "product_visible": true, "text_on_screen": false, "on_screen_text": "", "techniques": ["close_up", "motion"], "strength": "moderate", "rationale": "The bottle is shown straight away, but nothing in the opening is unexpected."}`
+	mockComparison = `{"shared_elements": ["insulated bottle", "sunrise hike", "twenty percent off"], "hook": {"a": "Opens on the bottle at sunrise.", "b": "Opens on the bottle at sunrise.", "difference": "None."}, "pacing": {"a": "Calm.", "b": "Calm.", "difference": "None."}, "differences": [], "summary": "The two ads are the same."}`
+	mockEntities   = `[{"type": "price", "segment": 2, "text": "twenty percent off", "value": "20%"}]`
+	mockFrame      = "Medium shot of a hiker holding a steel water bottle on a ridge at sunrise; static shot with a slow zoom in. Warm orange palette, calm and unhurried pacing, no motion blur."
+)
+
+// mockProviders answers Deepgram and Gemini requests locally with canned,
+// deterministic results.
+type mockProviders struct {
+	deepgram   []byte // /v1/listen response body
+	frame      string // every frame description
+	keyMoments string
+	summary    string // summaries and rolling story summaries
+}
+
+// UseMockProviders replaces both provider clients with a transport that
+// never leaves the process, for local development without API keys or
+// credits. Files in fixtureDir, if given, override the canned answers:
+// deepgram.json (a /v1/listen response), frame.txt, key_moments.json and
+// summary.txt. The Files API is not mocked, so frames are always inlined.
+func UseMockProviders(fixtureDir string) error {
+	m := &mockProviders{
+		deepgram:   []byte(mockDeepgramResponse),
+		frame:      mockFrame,
+		keyMoments: mockKeyMoments,
+		summary:    mockSummary,
+	}
+	if fixtureDir != "" {
+		for name, dst := range map[string]*string{
+			"frame.txt":        &m.frame,
+			"key_moments.json": &m.keyMoments,
+			"summary.txt":      &m.summary,
+		} {
+			data, err := readFixture(fixtureDir, name)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				*dst = strings.TrimSpace(string(data))
+			}
+		}
+		data, err := readFixture(fixtureDir, "deepgram.json")
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			m.deepgram = data
+		}
+		if !json.Valid(m.deepgram) || !json.Valid([]byte(m.keyMoments)) {
+			return fmt.Errorf("mock fixtures in %s: invalid JSON", fixtureDir)
+		}
+	}
+
+	client := &http.Client{Transport: m}
+	SetDeepgramHTTPClient(client)
+	SetGeminiHTTPClient(client)
+	SetGeminiFileThreshold(0)
+	return nil
+}
+
+// readFixture returns nil for a fixture that does not exist.
+func readFixture(dir, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mock fixture: %w", err)
+	}
+	return data, nil
+}
+
+func (m *mockProviders) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		// Drain the upload as a real provider would, so video readers are
+		// consumed and closed normally
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	path := req.URL.Path
+	switch {
+	case path == "/v1/listen":
+		return mockResponse(req, http.StatusOK, m.deepgram), nil
+	case strings.HasSuffix(path, ":generateContent"):
+		var gr geminiRequest
+		if err := json.Unmarshal(body, &gr); err != nil {
+			return mockResponse(req, http.StatusBadRequest, []byte(`{"error":{"message":"invalid request"}}`)), nil
+		}
+		return mockResponse(req, http.StatusOK, mockGeminiResponse(m.answer(gr))), nil
+	case strings.HasSuffix(path, ":batchGenerateContent"):
+		return mockResponse(req, http.StatusOK, m.batch(body)), nil
+	case req.Method == http.MethodGet:
+		// Warm-up probes
+		return mockResponse(req, http.StatusOK, []byte(`{}`)), nil
+	}
+	return mockResponse(req, http.StatusNotFound, []byte(`{"error":{"message":"not mocked"}}`)), nil
+}
+
+// answer picks the canned text for a generateContent request by the prompt
+// template it was built from.
+func (m *mockProviders) answer(gr geminiRequest) string {
+	var parts []geminiPart
+	if len(gr.Contents) > 0 {
+		parts = gr.Contents[0].Parts
+	}
+	var prompt string
+	if len(parts) > 0 {
+		prompt = parts[0].Text
+	}
+
+	switch {
+	case fromTemplate(prompt, vlmBatchPromptTemplate):
+		return perFrame(parts, map[string]any{"description": m.frame})
+	case fromTemplate(prompt, vlmPromptTemplate):
+		return m.frame
+	case fromTemplate(prompt, keyMomentsPromptTemplate):
+		return m.keyMoments
+	case fromTemplate(prompt, musicPromptTemplate):
+		return mockMusic
+	case fromTemplate(prompt, hookPromptTemplate):
+		return mockHook
+	case fromTemplate(prompt, comparePromptTemplate):
+		return mockComparison
+	case fromTemplate(prompt, entitiesPromptTemplate):
+		return mockEntities
+	}
+	for template, answer := range mockFrameAnswers {
+		if fromTemplate(prompt, template) {
+			return perFrame(parts, answer)
+		}
+	}
+	return m.summary
+}
+
+// mockFrameAnswers are what the per-frame analyses answer for every frame,
+// by prompt template.
+var mockFrameAnswers = map[string]map[string]any{
+	peoplePromptTemplate:        {"people_count": 1, "framing": "upper_body", "emotion": "calm"},
+	presenterPromptTemplate:     {"presenter": true, "same_as_previous": true},
+	contentRatingPromptTemplate: {"rating": "safe", "violence": false, "substances": false},
+	productsPromptTemplate:      {"products": []map[string]any{{"label": "water bottle", "box_2d": []int{250, 400, 850, 600}}}},
+	onScreenTextPromptTemplate:  {"text": "20% OFF / Order today"},
+}
+
+// perFrame answers a batch request with answer for each frame labelled in
+// parts, adding its frame_index.
+func perFrame(parts []geminiPart, answer map[string]any) string {
+	frames := []map[string]any{}
+	for _, p := range parts[1:] {
+		var idx int
+		var ts float64
+		if _, err := fmt.Sscanf(p.Text, "Frame %d at %fs:", &idx, &ts); err == nil {
+			frame := map[string]any{"frame_index": idx}
+			for k, v := range answer {
+				frame[k] = v
+			}
+			frames = append(frames, frame)
+		}
+	}
+	out, _ := json.Marshal(frames)
+	return string(out)
+}
+
+// batch answers a Batch API submission as already finished.
+func (m *mockProviders) batch(body []byte) []byte {
+	var create geminiBatchCreate
+	json.Unmarshal(body, &create)
+
+	type inlined struct {
+		Metadata map[string]string `json:"metadata"`
+		Response json.RawMessage   `json:"response"`
+	}
+	responses := []inlined{}
+	for _, r := range create.Batch.InputConfig.Requests.Requests {
+		responses = append(responses, inlined{r.Metadata, mockGeminiResponse(m.answer(r.Request))})
+	}
+	out, _ := json.Marshal(map[string]any{
+		"name":     "batches/mock",
+		"done":     true,
+		"metadata": map[string]string{"state": batchSucceeded},
+		"response": map[string]any{"inlinedResponses": map[string]any{"inlinedResponses": responses}},
+	})
+	return out
+}
+
+// fromTemplate reports whether prompt starts with template's fixed text,
+// up to its first formatting verb.
+func fromTemplate(prompt, template string) bool {
+	if i := strings.IndexByte(template, '%'); i >= 0 {
+		template = template[:i]
+	}
+	return strings.HasPrefix(prompt, template)
+}
+
+func mockGeminiResponse(text string) []byte {
+	out, _ := json.Marshal(map[string]any{
+		"candidates": []map[string]any{{
+			"content":      map[string]any{"parts": []map[string]string{{"text": text}}},
+			"finishReason": "STOP",
+		}},
+	})
+	return out
+}
+
+func mockResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,107 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func withMockProviders(t *testing.T, fixtureDir string) {
+	t.Helper()
+	oldDeepgram, oldGemini, oldThreshold := deepgramClient, geminiClient, geminiFileThreshold
+	t.Cleanup(func() {
+		deepgramClient, geminiClient, geminiFileThreshold = oldDeepgram, oldGemini, oldThreshold
+	})
+	if err := UseMockProviders(fixtureDir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockProviders_AllStreams(t *testing.T) {
+	withMockProviders(t, "")
+	withBatchPoll(t)
+	ctx := context.Background()
+
+	asr, err := RunASR(ctx, bytes.NewReader([]byte("video")), 5, "", ASROptions{})
+	if err != nil {
+		t.Fatalf("RunASR: %v", err)
+	}
+	if len(asr.Segments) != 3 || asr.DurationSec != 15 {
+		t.Errorf("ASR = %d segments over %.1fs, want 3 over 15s", len(asr.Segments), asr.DurationSec)
+	}
+
+	keyframes := []KeyframeInput{
+		{FrameIndex: 0, TimestampSec: 0, ImageBytes: []byte("a")},
+		{FrameIndex: 1, TimestampSec: 5, ImageBytes: []byte("b")},
+		{FrameIndex: 2, TimestampSec: 10, ImageBytes: []byte("c")},
+	}
+	for _, opts := range []VLMOptions{{}, {BatchSize: 2}, {Context: ContextRollingSummary, SummaryEvery: 1}} {
+		vlm, err := RunVLM(ctx, keyframes, "", opts)
+		if err != nil {
+			t.Fatalf("RunVLM(%+v): %v", opts, err)
+		}
+		for _, f := range vlm.Frames {
+			if f.Description != mockFrame {
+				t.Errorf("RunVLM(%+v) frame %d = %q", opts, f.FrameIndex, f.Description)
+			}
+		}
+	}
+	vlm, err := RunVLMBatch(ctx, keyframes, "", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLMBatch: %v", err)
+	}
+	if len(vlm.Frames) != 3 || vlm.Frames[2].Description != mockFrame {
+		t.Errorf("RunVLMBatch frames = %+v", vlm.Frames)
+	}
+
+	tl := BuildTimeline(asr, vlm)
+	moments, err := RunKeyMoments(ctx, tl, "")
+	if err != nil {
+		t.Fatalf("RunKeyMoments: %v", err)
+	}
+	if len(moments.Moments) != 4 {
+		t.Errorf("got %d key moments, want 4", len(moments.Moments))
+	}
+	summary, err := RunSummaries(ctx, tl, "")
+	if err != nil {
+		t.Fatalf("RunSummaries: %v", err)
+	}
+	if summary.Combined != mockSummary {
+		t.Errorf("summary = %q", summary.Combined)
+	}
+}
+
+func TestMockProviders_Fixtures(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "frame.txt"), []byte("A red square.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "deepgram.json"),
+		[]byte(`{"metadata":{"duration":2},"results":{"utterances":[{"start":0,"end":2,"transcript":"Hi."}]}}`), 0o644)
+	withMockProviders(t, dir)
+
+	asr, err := RunASR(context.Background(), strings.NewReader(""), 0, "", ASROptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asr.Segments) != 1 || asr.Segments[0].Text != "Hi." {
+		t.Errorf("segments = %+v, want the fixture's", asr.Segments)
+	}
+	vlm, err := RunVLM(context.Background(), []KeyframeInput{{ImageBytes: []byte("a")}}, "", VLMOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlm.Frames[0].Description != "A red square." {
+		t.Errorf("description = %q, want the fixture's", vlm.Frames[0].Description)
+	}
+}
+
+func TestMockProviders_InvalidFixture(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "key_moments.json"), []byte("not json"), 0o644)
+	withMockProviders(t, "")
+	if err := UseMockProviders(dir); err == nil {
+		t.Error("invalid key_moments.json accepted")
+	}
+}
@@ -0,0 +1,67 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Models a request may choose instead of the defaults, which are always
+// allowed.
+var (
+	allowedDeepgramModels []string
+	allowedGeminiModels   []string
+)
+
+// SetAllowedModels sets the Deepgram and Gemini models requests may choose.
+func SetAllowedModels(deepgram, gemini []string) {
+	allowedDeepgramModels, allowedGeminiModels = deepgram, gemini
+}
+
+// ValidateDeepgramModel rejects a Deepgram model requests may not choose.
+func ValidateDeepgramModel(model string) error {
+	if model != deepgramModel && !slices.Contains(allowedDeepgramModels, model) {
+		return fmt.Errorf("deepgram model %q is not allowed", model)
+	}
+	return nil
+}
+
+// ValidateGeminiModel rejects a Gemini model requests may not choose.
+func ValidateGeminiModel(model string) error {
+	if model != geminiModel && !slices.Contains(allowedGeminiModels, model) {
+		return fmt.Errorf("gemini model %q is not allowed", model)
+	}
+	return nil
+}
+
+type geminiModelKey struct{}
+
+// withGeminiModel makes Gemini calls under ctx use model; "" keeps the
+// default.
+func withGeminiModel(ctx context.Context, model string) context.Context {
+	if model == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, geminiModelKey{}, model)
+}
+
+// geminiModelFor is the model Gemini calls under ctx use.
+func geminiModelFor(ctx context.Context) string {
+	if model, ok := ctx.Value(geminiModelKey{}).(string); ok {
+		return model
+	}
+	return geminiModel
+}
+
+// withModel records in p a model other than the default chosen for the
+// calls under ctx, as "model" in its params, so Outdated leaves it alone.
+func withModel(ctx context.Context, p *Provenance) *Provenance {
+	if model := geminiModelFor(ctx); model != geminiModel {
+		p.Model = model
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params["model"] = model
+	}
+	return p
+}
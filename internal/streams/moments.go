@@ -0,0 +1,81 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// KeyMomentsResult is the output of the key-moments step.
+type KeyMomentsResult struct {
+	Moments    []KeyMoment `json:"moments"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type KeyMoment struct {
+	Type        string  `json:"type"` // "hook" | "product_reveal" | "offer" | "cta"
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Description string  `json:"description"`
+}
+
+var keyMomentTypes = map[string]bool{
+	"hook":           true,
+	"product_reveal": true,
+	"offer":          true,
+	"cta":            true,
+}
+
+const keyMomentsPromptTemplate = `Below is the merged timeline of a video advertisement (%.1fs long).
+SPEECH lines are the transcript, VISUAL lines describe keyframes.
+
+%s
+Identify the ad's key moments. Use only these types:
+- hook: the opening attention grabber
+- product_reveal: the first clear showing of the product
+- offer: a price, discount, promo code, or other incentive
+- cta: a call to action (shop now, download, visit, swipe up)
+
+Return a JSON array of objects with fields "type", "start", "end" (seconds) and
+"description" (one short sentence). Omit a type if it does not occur. Times must
+fall within the timeline.`
+
+// RunKeyMoments asks Gemini to locate the hook, product reveal, offer and CTA
+// in a merged timeline. Unknown types are dropped and times are clamped to
+// the timeline duration.
+func RunKeyMoments(ctx context.Context, tl *Timeline, apiKey string) (*KeyMomentsResult, error) {
+	result := &KeyMomentsResult{
+		Provenance: geminiProvenance(keyMomentsPromptVersion, map[string]string{
+			"response_mime_type": "application/json",
+		}),
+	}
+	if len(tl.Entries) == 0 {
+		return result, nil
+	}
+
+	prompt := fmt.Sprintf(keyMomentsPromptTemplate, tl.DurationSec, tl.Render())
+
+	var moments []KeyMoment
+	if err := callGeminiJSON(ctx, apiKey, prompt, &moments); err != nil {
+		return nil, fmt.Errorf("key moments: %w", err)
+	}
+
+	result.Moments = filterMoments(moments, tl.DurationSec)
+	result.normalize()
+	return result, nil
+}
+
+func filterMoments(moments []KeyMoment, duration float64) []KeyMoment {
+	var out []KeyMoment
+	for _, m := range moments {
+		m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+		if !keyMomentTypes[m.Type] {
+			continue
+		}
+		m.Start = min(max(m.Start, 0), duration)
+		m.End = min(max(m.End, m.Start), duration)
+		m.Description = strings.TrimSpace(m.Description)
+		out = append(out, m)
+	}
+	return out
+}
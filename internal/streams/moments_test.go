@@ -0,0 +1,74 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunKeyMoments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.GenerationConfig == nil || req.GenerationConfig.ResponseMimeType != "application/json" {
+			t.Errorf("expected JSON response mode, got %+v", req.GenerationConfig)
+		}
+		if len(req.Contents[0].Parts) != 1 {
+			t.Errorf("expected text-only request, got %d parts", len(req.Contents[0].Parts))
+		}
+		if !strings.Contains(req.Contents[0].Parts[0].Text, "SPEECH: Shop now") {
+			t.Errorf("prompt missing rendered timeline")
+		}
+
+		answer := `[
+			{"type": "CTA", "start": 6.0, "end": 30.0, "description": "Narrator says shop now."},
+			{"type": "hook", "start": -1, "end": 2.0, "description": " Mirror close-up. "},
+			{"type": "testimonial", "start": 3.0, "end": 4.0, "description": "ignored"}
+		]`
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": answer}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	tl := BuildTimeline(
+		&ASRResult{Segments: []ASRSegment{{Start: 6.0, End: 8.5, Text: "Shop now"}}},
+		&VLMResult{Frames: []VLMFrame{{FrameIndex: 0, TimestampSec: 0, Description: "Mirror."}}},
+	)
+
+	result, err := RunKeyMoments(context.Background(), tl, "key")
+	if err != nil {
+		t.Fatalf("RunKeyMoments error: %v", err)
+	}
+	if len(result.Moments) != 2 {
+		t.Fatalf("expected 2 moments, got %d: %+v", len(result.Moments), result.Moments)
+	}
+	hook, cta := result.Moments[0], result.Moments[1]
+	if hook.Type != "hook" || hook.Start != 0 || hook.Description != "Mirror close-up." {
+		t.Errorf("hook = %+v", hook)
+	}
+	if cta.Type != "cta" || cta.End != 8.5 {
+		t.Errorf("cta = %+v, want type cta clamped to end 8.5", cta)
+	}
+}
+
+func TestRunKeyMoments_EmptyTimeline(t *testing.T) {
+	result, err := RunKeyMoments(context.Background(), &Timeline{}, "key")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(result.Moments) != 0 {
+		t.Errorf("expected no moments, got %d", len(result.Moments))
+	}
+}
@@ -0,0 +1,110 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MusicResult is the output of the music stream: what the soundtrack's
+// backing music is like.
+type MusicResult struct {
+	HasMusic bool   `json:"has_music"`
+	Genre    string `json:"genre"`  // one of musicGenres
+	Mood     string `json:"mood"`   // one of musicMoods
+	Energy   string `json:"energy"` // "low", "medium", "high", or "none" without music
+	Vocals   bool   `json:"vocals"` // the music has singing or rap, not counting voice-over
+
+	// ClipSec is how much of the soundtrack was listened to, from the start
+	ClipSec    float64     `json:"clip_sec"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+var (
+	musicGenres = []string{"none", "pop", "hip_hop", "electronic", "rock", "r_and_b", "jazz", "classical", "country", "folk", "latin", "ambient", "cinematic", "lo_fi", "other"}
+	musicMoods  = []string{"none", "happy", "uplifting", "energetic", "playful", "calm", "romantic", "inspirational", "dramatic", "tense", "sad"}
+	musicEnergy = []string{"none", "low", "medium", "high"}
+)
+
+const (
+	// musicClipSec caps the audio sent for classification; an ad's backing
+	// track rarely changes character after the first minute
+	musicClipSec = 60
+
+	// musicSampleRate keeps the WAV clip small while leaving the timbre
+	// that tells genres apart
+	musicSampleRate = 16000
+)
+
+const musicPromptTemplate = `Listen to this soundtrack from a video advertisement and classify its backing music, ignoring any voice-over or dialogue.
+
+Report:
+- has_music: false if there is no music, only speech, sound effects or silence
+- genre: one of "pop", "hip_hop", "electronic", "rock", "r_and_b", "jazz", "classical", "country", "folk", "latin", "ambient", "cinematic", "lo_fi", "other", or "none" without music
+- mood: one of "happy", "uplifting", "energetic", "playful", "calm", "romantic", "inspirational", "dramatic", "tense", "sad", or "none" without music
+- energy: "low", "medium" or "high", or "none" without music
+- vocals: true if the music itself has singing or rap
+
+Respond with a JSON object:
+{"has_music": <bool>, "genre": "<genre>", "mood": "<mood>", "energy": "<energy>", "vocals": <bool>}`
+
+// ExtractAudioClip decodes up to musicClipSec of input's first audio track,
+// a path or URL ffmpeg can read, into a mono WAV.
+func ExtractAudioClip(ctx context.Context, input string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostdin",
+		"-i", input,
+		"-map", "0:a:0",
+		"-t", strconv.Itoa(musicClipSec),
+		"-ac", "1", "-ar", strconv.Itoa(musicSampleRate),
+		// no metadata chunk, so the header is the plain 44 bytes
+		"-map_metadata", "-1", "-flags", "+bitexact",
+		"-f", "wav", "-",
+	)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "matches no streams") {
+			return nil, ErrNoAudio
+		}
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, tail(stderr.String(), 5))
+	}
+	return stdout.Bytes(), nil
+}
+
+// RunMusic classifies the backing music of clip, a WAV from
+// ExtractAudioClip, with Gemini. Answers outside the allowed values are
+// recorded as "none", and a clip without music has every field "none".
+func RunMusic(ctx context.Context, clip []byte, apiKey string) (*MusicResult, error) {
+	var answer MusicResult
+	parts := []geminiPart{
+		{Text: musicPromptTemplate},
+		{InlineData: &geminiInline{MimeType: "audio/wav", Data: encodeBase64(clip)}},
+	}
+	if err := generateJSON(ctx, apiKey, parts, &answer); err != nil {
+		return nil, fmt.Errorf("classify music: %w", err)
+	}
+
+	res := &MusicResult{
+		HasMusic:   answer.HasMusic,
+		Genre:      oneOf(answer.Genre, musicGenres),
+		Mood:       oneOf(answer.Mood, musicMoods),
+		Energy:     oneOf(answer.Energy, musicEnergy),
+		Vocals:     answer.Vocals,
+		ClipSec:    round3(wavSeconds(clip)),
+		Provenance: geminiProvenance(musicPromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	if !res.HasMusic {
+		res.Genre, res.Mood, res.Energy, res.Vocals = "none", "none", "none", false
+	}
+	return res, nil
+}
+
+// wavSeconds is the duration of a 16-bit mono WAV at musicSampleRate.
+func wavSeconds(wav []byte) float64 {
+	const header = 44
+	return float64(max(len(wav)-header, 0)) / 2 / musicSampleRate
+}
@@ -0,0 +1,84 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// stubMusic points Gemini at a server answering with answer, checking that
+// the request carries the audio clip.
+func stubMusic(t *testing.T, answer string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		parts := req.Contents[0].Parts
+		if len(parts) != 2 || parts[1].InlineData == nil || parts[1].InlineData.MimeType != "audio/wav" {
+			t.Errorf("request parts = %+v", parts)
+		}
+		w.Write(mockGeminiResponse(answer))
+	}))
+	t.Cleanup(server.Close)
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	t.Cleanup(func() { geminiBaseURL = old })
+}
+
+func TestRunMusic(t *testing.T) {
+	clip := make([]byte, 44+2*musicSampleRate*3) // 3s
+
+	stubMusic(t, `{"has_music": true, "genre": "Hip_Hop", "mood": "smug", "energy": "high", "vocals": true}`)
+	res, err := RunMusic(context.Background(), clip, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MusicResult{HasMusic: true, Genre: "hip_hop", Mood: "none", Energy: "high", Vocals: true, ClipSec: 3}
+	res.Provenance = nil
+	if *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+
+	stubMusic(t, `{"has_music": false, "genre": "pop", "mood": "calm", "energy": "low", "vocals": true}`)
+	res, err = RunMusic(context.Background(), clip, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Genre != "none" || res.Mood != "none" || res.Energy != "none" || res.Vocals {
+		t.Errorf("no music: result = %+v", res)
+	}
+}
+
+func TestExtractAudioClip(t *testing.T) {
+	if !FFmpegAvailable() {
+		t.Skip("ffmpeg not installed")
+	}
+	dir := t.TempDir()
+	withAudio := filepath.Join(dir, "audio.mp4")
+	silent := filepath.Join(dir, "silent.mp4")
+	for _, args := range [][]string{
+		{"-f", "lavfi", "-i", "testsrc=duration=2", "-f", "lavfi", "-i", "sine=frequency=440:duration=2", "-shortest", withAudio},
+		{"-f", "lavfi", "-i", "testsrc=duration=2", silent},
+	} {
+		gen := exec.Command("ffmpeg", append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+		if out, err := gen.CombinedOutput(); err != nil {
+			t.Fatalf("generate video: %v: %s", err, out)
+		}
+	}
+
+	clip, err := ExtractAudioClip(context.Background(), withAudio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sec := wavSeconds(clip); sec < 1.9 || sec > 2.1 {
+		t.Errorf("clip is %vs, want 2", sec)
+	}
+	if _, err := ExtractAudioClip(context.Background(), silent); !errors.Is(err, ErrNoAudio) {
+		t.Errorf("err = %v, want ErrNoAudio", err)
+	}
+}
@@ -0,0 +1,108 @@
+package streams
+
+import (
+	"math"
+	"sort"
+)
+
+// Results are normalized before they leave this package so that re-running
+// the same job produces byte-identical files: entries are sorted by time with
+// explicit tie-breakers and times are rounded to milliseconds. Callers that
+// process frames out of order (e.g. in parallel) can rely on this.
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+func (r *ASRResult) normalize() {
+	r.DurationSec = round3(r.DurationSec)
+	for i := range r.Segments {
+		s := &r.Segments[i]
+		s.Start, s.End, s.Confidence = round3(s.Start), round3(s.End), round3(s.Confidence)
+	}
+	sort.SliceStable(r.Segments, func(i, j int) bool {
+		a, b := r.Segments[i], r.Segments[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.End < b.End
+	})
+}
+
+func (r *VLMResult) normalize() {
+	for i := range r.Frames {
+		r.Frames[i].TimestampSec = round3(r.Frames[i].TimestampSec)
+	}
+	sort.SliceStable(r.Frames, func(i, j int) bool {
+		a, b := r.Frames[i], r.Frames[j]
+		if a.TimestampSec != b.TimestampSec {
+			return a.TimestampSec < b.TimestampSec
+		}
+		return a.FrameIndex < b.FrameIndex
+	})
+}
+
+func (tl *Timeline) normalize() {
+	tl.DurationSec = round3(tl.DurationSec)
+	for i := range tl.Entries {
+		e := &tl.Entries[i]
+		e.Start, e.End = round3(e.Start), round3(e.End)
+	}
+	sort.SliceStable(tl.Entries, func(i, j int) bool {
+		a, b := tl.Entries[i], tl.Entries[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source // "speech" before "visual"
+		}
+		return frameIndexOf(a) < frameIndexOf(b)
+	})
+}
+
+func (r *KeyMomentsResult) normalize() {
+	for i := range r.Moments {
+		m := &r.Moments[i]
+		m.Start, m.End = round3(m.Start), round3(m.End)
+	}
+	sort.SliceStable(r.Moments, func(i, j int) bool {
+		a, b := r.Moments[i], r.Moments[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.Type < b.Type
+	})
+}
+
+func frameIndexOf(e TimelineEntry) int {
+	if e.FrameIndex == nil {
+		return -1
+	}
+	return *e.FrameIndex
+}
+
+func (r *PeopleResult) normalize() {
+	for i := range r.Frames {
+		r.Frames[i].TimestampSec = round3(r.Frames[i].TimestampSec)
+	}
+	sort.SliceStable(r.Frames, func(i, j int) bool {
+		a, b := r.Frames[i], r.Frames[j]
+		if a.TimestampSec != b.TimestampSec {
+			return a.TimestampSec < b.TimestampSec
+		}
+		return a.FrameIndex < b.FrameIndex
+	})
+}
+
+func (r *ProductsResult) normalize() {
+	for i := range r.Frames {
+		r.Frames[i].TimestampSec = round3(r.Frames[i].TimestampSec)
+	}
+	sort.SliceStable(r.Frames, func(i, j int) bool {
+		a, b := r.Frames[i], r.Frames[j]
+		if a.TimestampSec != b.TimestampSec {
+			return a.TimestampSec < b.TimestampSec
+		}
+		return a.FrameIndex < b.FrameIndex
+	})
+}
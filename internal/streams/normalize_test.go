@@ -0,0 +1,64 @@
+package streams
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestVLMResultNormalize_SortsAndRounds(t *testing.T) {
+	r := &VLMResult{Frames: []VLMFrame{
+		{FrameIndex: 9, TimestampSec: 4.00000001, Description: "c"},
+		{FrameIndex: 2, TimestampSec: 1.2345678, Description: "a"},
+		{FrameIndex: 5, TimestampSec: 1.2345678, Description: "b"},
+	}}
+	r.normalize()
+
+	wantIdx := []int{2, 5, 9}
+	for i, want := range wantIdx {
+		if r.Frames[i].FrameIndex != want {
+			t.Errorf("frame %d index = %d, want %d", i, r.Frames[i].FrameIndex, want)
+		}
+	}
+	if r.Frames[0].TimestampSec != 1.235 || r.Frames[2].TimestampSec != 4 {
+		t.Errorf("timestamps not rounded: %v, %v", r.Frames[0].TimestampSec, r.Frames[2].TimestampSec)
+	}
+}
+
+func TestASRResultNormalize_ByteStable(t *testing.T) {
+	build := func(segs ...ASRSegment) []byte {
+		r := &ASRResult{DurationSec: 10.0000004, Segments: segs}
+		r.normalize()
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return b
+	}
+
+	a := ASRSegment{Start: 0.1 + 0.2, End: 1, Text: "a", Confidence: 0.98765}
+	b := ASRSegment{Start: 2, End: 3, Text: "b", Confidence: 0.5}
+
+	first := build(a, b)
+	second := build(b, ASRSegment{Start: 0.3, End: 1, Text: "a", Confidence: 0.98765})
+	if !bytes.Equal(first, second) {
+		t.Errorf("outputs differ:\n%s\n%s", first, second)
+	}
+}
+
+func TestTimelineNormalize_TieBreak(t *testing.T) {
+	two, one := 2, 1
+	tl := &Timeline{Entries: []TimelineEntry{
+		{Start: 1, Source: "visual", FrameIndex: &two},
+		{Start: 1, Source: "visual", FrameIndex: &one},
+		{Start: 1, Source: "speech"},
+	}}
+	tl.normalize()
+
+	if tl.Entries[0].Source != "speech" {
+		t.Errorf("entry 0 source = %q, want speech", tl.Entries[0].Source)
+	}
+	if *tl.Entries[1].FrameIndex != 1 || *tl.Entries[2].FrameIndex != 2 {
+		t.Errorf("visual entries not ordered by frame index")
+	}
+}
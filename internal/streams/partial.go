@@ -0,0 +1,18 @@
+package streams
+
+import "slices"
+
+// Partial methods report whether a per-frame result was stored cut short,
+// by the job's deadline or its token budget, so that it is run again
+// rather than reused.
+
+func (r *VLMResult) Partial() bool {
+	return r.Incomplete || slices.ContainsFunc(r.SkippedFrames, func(f SkippedFrame) bool { return f.Reason == SkipOverBudget })
+}
+
+func (r *PeopleResult) Partial() bool        { return r.Incomplete }
+func (r *PresenterResult) Partial() bool     { return r.Incomplete }
+func (r *VisualStatsResult) Partial() bool   { return r.Incomplete }
+func (r *ContentRatingResult) Partial() bool { return r.Incomplete }
+func (r *ProductsResult) Partial() bool      { return r.Incomplete }
+func (r *CTAResult) Partial() bool           { return r.Incomplete }
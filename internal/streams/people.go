@@ -0,0 +1,88 @@
+package streams
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// PeopleResult is the output of the people stream: who is on screen in
+// each keyframe, as fields rather than prose.
+type PeopleResult struct {
+	Frames []PeopleFrame `json:"frames"`
+
+	// FirstPersonSec is the timestamp of the first keyframe showing a
+	// person, nil if none does
+	FirstPersonSec *float64    `json:"first_person_sec"`
+	Incomplete     bool        `json:"incomplete,omitempty"` // the job ended before every frame was analyzed
+	Provenance     *Provenance `json:"provenance,omitempty"`
+}
+
+type PeopleFrame struct {
+	FrameIndex   int     `json:"frame_index"`
+	TimestampSec float64 `json:"timestamp_sec"`
+	PeopleCount  int     `json:"people_count"`
+	Framing      string  `json:"framing"` // one of framings
+	Emotion      string  `json:"emotion"` // one of emotions
+	Error        string  `json:"error,omitempty"`
+}
+
+var (
+	framings = []string{"none", "face_closeup", "upper_body", "full_body", "wide"}
+	emotions = []string{"none", "happy", "excited", "neutral", "calm", "surprised", "sad", "angry", "fearful", "disgusted"}
+)
+
+const peoplePromptTemplate = `Analyze these %d frames from a video advertisement. Each image is preceded by its frame index and timestamp.
+
+For each frame report the people visible in it:
+- people_count: how many people are visible, 0 if none (count body parts such as hands as a person only if that is all there is)
+- framing: how the most prominent person is framed: "face_closeup" (face fills much of the frame), "upper_body", "full_body", "wide" (people small in a wider scene), or "none" when nobody is visible
+- emotion: the emotion the most prominent person displays: "happy", "excited", "neutral", "calm", "surprised", "sad", "angry", "fearful", "disgusted", or "none" when nobody is visible or no face can be seen
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "people_count": <int>, "framing": "<framing>", "emotion": "<emotion>"}]`
+
+// RunPeople counts the people in each keyframe and reports their framing
+// and displayed emotion. Answers outside the allowed values are recorded
+// as "none".
+func RunPeople(ctx context.Context, keyframes []KeyframeInput, apiKey string) (*PeopleResult, error) {
+	result := &PeopleResult{
+		Frames:     []PeopleFrame{},
+		Provenance: geminiProvenance(peoplePromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	answers := analyzeFrames[PeopleFrame](ctx, apiKey, keyframes, peoplePromptTemplate)
+	for _, a := range answers {
+		f := PeopleFrame{FrameIndex: a.kf.FrameIndex, TimestampSec: a.kf.TimestampSec}
+		if a.err != nil {
+			f.Error = a.err.Error()
+		} else {
+			f.PeopleCount = max(a.value.PeopleCount, 0)
+			f.Framing = oneOf(a.value.Framing, framings)
+			f.Emotion = oneOf(a.value.Emotion, emotions)
+			if f.PeopleCount == 0 {
+				f.Framing, f.Emotion = "none", "none"
+			}
+		}
+		result.Frames = append(result.Frames, f)
+	}
+	result.Incomplete = len(answers) < len(keyframes)
+	result.normalize()
+
+	for _, f := range result.Frames {
+		if f.PeopleCount > 0 {
+			ts := f.TimestampSec
+			result.FirstPersonSec = &ts
+			break
+		}
+	}
+	return result, nil
+}
+
+// oneOf returns v, lowercased, if it is one of allowed, and else allowed[0].
+func oneOf(v string, allowed []string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if slices.Contains(allowed, v) {
+		return v
+	}
+	return allowed[0]
+}
@@ -0,0 +1,39 @@
+package streams
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunPeople(t *testing.T) {
+	stubFrameAnswers(t, func(idx int) map[string]any {
+		switch idx {
+		case 0:
+			return map[string]any{"people_count": 0, "framing": "wide", "emotion": "happy"}
+		case 1:
+			return map[string]any{"people_count": 1, "framing": "Face_Closeup", "emotion": "excited"}
+		}
+		return map[string]any{"people_count": 3, "framing": "crowd", "emotion": "smug"}
+	})
+
+	res, err := RunPeople(context.Background(), testKeyframes(3), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frames) != 3 || res.Incomplete {
+		t.Fatalf("result = %+v", res)
+	}
+	want := []PeopleFrame{
+		{FrameIndex: 0, TimestampSec: 0, PeopleCount: 0, Framing: "none", Emotion: "none"},
+		{FrameIndex: 1, TimestampSec: 1.5, PeopleCount: 1, Framing: "face_closeup", Emotion: "excited"},
+		{FrameIndex: 2, TimestampSec: 3, PeopleCount: 3, Framing: "none", Emotion: "none"},
+	}
+	for i, f := range res.Frames {
+		if f != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, f, want[i])
+		}
+	}
+	if res.FirstPersonSec == nil || *res.FirstPersonSec != 1.5 {
+		t.Errorf("first person at %v, want 1.5", res.FirstPersonSec)
+	}
+}
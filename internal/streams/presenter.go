@@ -0,0 +1,202 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// PresenterResult is the output of the presenter stream: who carries the
+// ad across its keyframes. Presenter IDs are assigned in order of first
+// appearance; frames without a presenter have 0.
+type PresenterResult struct {
+	Frames   []PresenterFrame   `json:"frames"`
+	Segments []PresenterSegment `json:"segments"`
+
+	Presenters int     `json:"presenters"` // distinct presenters seen
+	Coverage   float64 `json:"coverage"`   // share of analyzed frames showing a presenter
+
+	// Format is "single_presenter" when one presenter carries most of the
+	// ad (typical UGC), "multi_presenter" when several appear, and
+	// "montage" when no one does
+	Format string `json:"format"`
+
+	Incomplete bool        `json:"incomplete,omitempty"` // the job ended or ran out of tokens before every frame was analyzed
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type PresenterFrame struct {
+	FrameIndex   int     `json:"frame_index"`
+	TimestampSec float64 `json:"timestamp_sec"`
+	Presenter    int     `json:"presenter"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// PresenterSegment is a run of consecutive keyframes showing the same
+// presenter.
+type PresenterSegment struct {
+	Presenter int     `json:"presenter"`
+	Start     float64 `json:"start"` // first frame's timestamp
+	End       float64 `json:"end"`   // last frame's timestamp
+	Frames    int     `json:"frames"`
+}
+
+// singlePresenterCoverage is the share of frames one presenter must appear
+// in for an ad to count as "single_presenter" rather than a montage with a
+// cameo.
+const singlePresenterCoverage = 0.5
+
+const presenterPromptTemplate = `Analyze these %d consecutive frames from a video advertisement. Each image is preceded by its frame index and timestamp.
+%s
+A presenter is a person who carries the ad on screen: talking to the camera, demonstrating the product, or the clear main subject of the shot. Crowds, passers-by, hands alone, and people in a product's packaging or in on-screen graphics are not presenters.
+
+For each frame report:
+- presenter: true if the frame shows a presenter
+- same_as_previous: true if that presenter is the same person as in the most recent earlier frame that showed a presenter (judge by face, hair and build; a change of outfit or setting alone does not make a different person); false if it is someone else or no earlier frame showed one
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "presenter": <bool>, "same_as_previous": <bool>}]`
+
+const presenterReferenceNote = `The first image, frame %d, is only a reference: it is the most recent earlier frame showing a presenter. Do not report it.
+`
+
+type presenterAnswer struct {
+	FrameIndex     int  `json:"frame_index"`
+	Presenter      bool `json:"presenter"`
+	SameAsPrevious bool `json:"same_as_previous"`
+}
+
+// RunPresenter tracks the presenter across consecutive keyframes. Frames go
+// to Gemini in batches, each preceded by the last frame that showed a
+// presenter, so a presenter who returns after a cutaway keeps their ID.
+// Frames that fail to load or get no answer keep the current presenter
+// undecided: they are reported with an error and do not break a run.
+func RunPresenter(ctx context.Context, keyframes []KeyframeInput, apiKey string) (*PresenterResult, error) {
+	result := &PresenterResult{
+		Frames:     []PresenterFrame{},
+		Segments:   []PresenterSegment{},
+		Provenance: geminiProvenance(presenterPromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+
+	var (
+		ref     *loadedFrame // latest frame that showed a presenter
+		current int          // its presenter ID
+		batch   []loadedFrame
+	)
+	flush := func() {
+		var ready []loadedFrame
+		for _, lf := range batch {
+			if lf.err == nil {
+				ready = append(ready, lf)
+			}
+		}
+		answers := map[int]presenterAnswer{}
+		var err error
+		if len(ready) > 0 {
+			answers, err = askPresenter(withHedging(ctx), apiKey, ref, ready)
+		}
+		for _, lf := range batch {
+			f := PresenterFrame{FrameIndex: lf.kf.FrameIndex, TimestampSec: round3(lf.kf.TimestampSec)}
+			a, ok := answers[lf.kf.FrameIndex]
+			switch {
+			case lf.err != nil:
+				f.Error = lf.err.Error()
+			case err != nil:
+				f.Error = err.Error()
+			case !ok:
+				f.Error = fmt.Sprintf("no answer returned for frame %d", lf.kf.FrameIndex)
+			case a.Presenter:
+				if !a.SameAsPrevious || current == 0 {
+					result.Presenters++
+					current = result.Presenters
+				}
+				f.Presenter = current
+				ref = &loadedFrame{kf: lf.kf, img: bytes.Clone(lf.img)}
+			}
+			result.Frames = append(result.Frames, f)
+		}
+		for i := range batch {
+			batch[i].release()
+		}
+		batch = batch[:0]
+	}
+
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+	for lf := range prefetchFrames(fetchCtx, keyframes) {
+		if ctx.Err() != nil || BudgetExhausted(ctx) {
+			lf.release()
+			break
+		}
+		batch = append(batch, lf)
+		if len(batch) == analysisBatchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 && ctx.Err() == nil {
+		flush()
+	}
+	for i := range batch {
+		batch[i].release()
+	}
+	result.Incomplete = len(result.Frames) < len(keyframes)
+	result.summarize()
+	return result, nil
+}
+
+func askPresenter(ctx context.Context, apiKey string, ref *loadedFrame, frames []loadedFrame) (map[int]presenterAnswer, error) {
+	note := ""
+	if ref != nil {
+		note = fmt.Sprintf(presenterReferenceNote, ref.kf.FrameIndex)
+		frames = append([]loadedFrame{*ref}, frames...)
+	}
+	var out []presenterAnswer
+	prompt := fmt.Sprintf(presenterPromptTemplate, len(frames), note)
+	if err := callGeminiBatch(ctx, apiKey, prompt, frames, &out); err != nil {
+		return nil, err
+	}
+	answers := make(map[int]presenterAnswer, len(out))
+	for _, a := range out {
+		answers[a.FrameIndex] = a
+	}
+	if ref != nil {
+		delete(answers, ref.kf.FrameIndex)
+	}
+	return answers, nil
+}
+
+// summarize derives the segments, coverage and format from the frames.
+// Frames with an error neither extend nor end a segment.
+func (r *PresenterResult) summarize() {
+	var analyzed, shown int
+	var seg *PresenterSegment
+	for _, f := range r.Frames {
+		if f.Error != "" {
+			continue
+		}
+		analyzed++
+		if f.Presenter == 0 {
+			seg = nil
+			continue
+		}
+		shown++
+		if seg == nil || seg.Presenter != f.Presenter {
+			r.Segments = append(r.Segments, PresenterSegment{Presenter: f.Presenter, Start: f.TimestampSec})
+			seg = &r.Segments[len(r.Segments)-1]
+		}
+		seg.End = f.TimestampSec
+		seg.Frames++
+	}
+	if analyzed > 0 {
+		r.Coverage = round3(float64(shown) / float64(analyzed))
+	}
+
+	switch {
+	case r.Presenters == 1 && r.Coverage >= singlePresenterCoverage:
+		r.Format = "single_presenter"
+	case r.Presenters > 1:
+		r.Format = "multi_presenter"
+	default:
+		r.Format = "montage"
+	}
+}
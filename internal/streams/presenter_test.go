@@ -0,0 +1,65 @@
+package streams
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunPresenter(t *testing.T) {
+	presenter := map[int]bool{0: false, 1: true, 3: true, 4: false, 8: true} // frame -> same_as_previous
+	prompts := stubFrameAnswers(t, func(idx int) map[string]any {
+		same, ok := presenter[idx]
+		return map[string]any{"presenter": ok, "same_as_previous": same}
+	})
+
+	res, err := RunPresenter(context.Background(), testKeyframes(10), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, f := range res.Frames {
+		ids = append(ids, f.Presenter)
+	}
+	if got, want := ids, []int{1, 1, 0, 1, 2, 0, 0, 0, 2, 0}; !slices.Equal(got, want) {
+		t.Errorf("presenters = %v, want %v", got, want)
+	}
+	if len(*prompts) != 2 || !strings.Contains((*prompts)[1], "frame 4, is only a reference") {
+		t.Errorf("second batch not anchored on frame 4: %q", *prompts)
+	}
+	want := []PresenterSegment{{1, 0, 1.5, 2}, {1, 4.5, 4.5, 1}, {2, 6, 6, 1}, {2, 12, 12, 1}}
+	if len(res.Segments) != len(want) {
+		t.Fatalf("segments = %+v", res.Segments)
+	}
+	for i, s := range res.Segments {
+		if s != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+	if res.Presenters != 2 || res.Coverage != 0.5 || res.Format != "multi_presenter" {
+		t.Errorf("presenters %d, coverage %v, format %q", res.Presenters, res.Coverage, res.Format)
+	}
+}
+
+func TestPresenterFormat(t *testing.T) {
+	for _, tc := range []struct {
+		ids  []int
+		want string
+	}{
+		{[]int{1, 1, 0, 1}, "single_presenter"},
+		{[]int{0, 1, 0, 0}, "montage"},
+		{[]int{0, 0, 0}, "montage"},
+		{[]int{1, 2}, "multi_presenter"},
+	} {
+		r := &PresenterResult{}
+		for i, id := range tc.ids {
+			r.Frames = append(r.Frames, PresenterFrame{FrameIndex: i, Presenter: id})
+			r.Presenters = max(r.Presenters, id)
+		}
+		r.summarize()
+		if r.Format != tc.want {
+			t.Errorf("%v: format %q, want %q", tc.ids, r.Format, tc.want)
+		}
+	}
+}
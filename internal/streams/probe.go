@@ -0,0 +1,187 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VideoMeta describes the video file itself, as read by ffprobe.
+type VideoMeta struct {
+	DurationSec float64     `json:"duration_sec"`
+	SizeBytes   int64       `json:"size_bytes"`
+	BitRate     int64       `json:"bit_rate"` // bits per second, all streams
+	Container   string      `json:"container"`
+	Video       *VideoTrack `json:"video,omitempty"`
+	Audio       *AudioTrack `json:"audio,omitempty"` // nil for silent videos
+	Provenance  *Provenance `json:"provenance,omitempty"`
+}
+
+type VideoTrack struct {
+	Codec string `json:"codec"`
+	// Width, Height and AspectRatio are as displayed, after any rotation
+	// the file asks players to apply; phone videos are often stored
+	// landscape and rotated to portrait.
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	AspectRatio string  `json:"aspect_ratio"` // e.g. "9:16"
+	Rotation    int     `json:"rotation"`     // degrees
+	FPS         float64 `json:"fps"`
+	BitRate     int64   `json:"bit_rate,omitempty"`
+}
+
+type AudioTrack struct {
+	Codec         string `json:"codec"`
+	Channels      int    `json:"channels"`
+	ChannelLayout string `json:"channel_layout,omitempty"` // e.g. "stereo"
+	SampleRate    int    `json:"sample_rate"`
+	BitRate       int64  `json:"bit_rate,omitempty"`
+}
+
+// ffprobeOutput is the subset of `ffprobe -show_format -show_streams` JSON
+// used. ffprobe prints most numbers as strings.
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType     string            `json:"codec_type"`
+		CodecName     string            `json:"codec_name"`
+		Width         int               `json:"width"`
+		Height        int               `json:"height"`
+		AvgFrameRate  string            `json:"avg_frame_rate"`
+		RFrameRate    string            `json:"r_frame_rate"`
+		BitRate       string            `json:"bit_rate"`
+		Channels      int               `json:"channels"`
+		ChannelLayout string            `json:"channel_layout"`
+		SampleRate    string            `json:"sample_rate"`
+		Tags          map[string]string `json:"tags"`
+		SideDataList  []struct {
+			Rotation int `json:"rotation"`
+		} `json:"side_data_list"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+// ProbeAvailable reports whether ffprobe is on the PATH.
+var ProbeAvailable = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("ffprobe")
+	return err == nil
+})
+
+// RunProbe runs ffprobe on input, a path or URL ffprobe can read. Only the
+// container header is read, not the whole video.
+func RunProbe(ctx context.Context, input string) (*VideoMeta, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		input,
+	)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffprobe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseProbe(stdout.Bytes())
+}
+
+func parseProbe(data []byte) (*VideoMeta, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decode ffprobe output: %w", err)
+	}
+
+	meta := &VideoMeta{
+		DurationSec: parseFloat(out.Format.Duration),
+		SizeBytes:   parseInt(out.Format.Size),
+		BitRate:     parseInt(out.Format.BitRate),
+		Container:   out.Format.FormatName,
+		Provenance:  &Provenance{Provider: "ffmpeg", Model: "ffprobe"},
+	}
+	for _, s := range out.Streams {
+		switch {
+		case s.CodecType == "video" && meta.Video == nil && s.Disposition.AttachedPic == 0:
+			v := &VideoTrack{
+				Codec:   s.CodecName,
+				Width:   s.Width,
+				Height:  s.Height,
+				FPS:     parseRate(s.AvgFrameRate),
+				BitRate: parseInt(s.BitRate),
+			}
+			if v.FPS == 0 {
+				v.FPS = parseRate(s.RFrameRate)
+			}
+			if len(s.SideDataList) > 0 && s.SideDataList[0].Rotation != 0 {
+				v.Rotation = s.SideDataList[0].Rotation
+			} else if r, err := strconv.Atoi(s.Tags["rotate"]); err == nil {
+				v.Rotation = r
+			}
+			if (v.Rotation/90)%2 != 0 {
+				v.Width, v.Height = v.Height, v.Width
+			}
+			v.AspectRatio = aspectRatio(v.Width, v.Height)
+			meta.Video = v
+		case s.CodecType == "audio" && meta.Audio == nil:
+			meta.Audio = &AudioTrack{
+				Codec:         s.CodecName,
+				Channels:      s.Channels,
+				ChannelLayout: s.ChannelLayout,
+				SampleRate:    int(parseInt(s.SampleRate)),
+				BitRate:       parseInt(s.BitRate),
+			}
+		}
+	}
+	if meta.Video == nil {
+		return nil, fmt.Errorf("ffprobe: no video stream")
+	}
+	return meta, nil
+}
+
+// parseRate reads an ffprobe frame rate such as "30000/1001".
+func parseRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return parseFloat(s)
+	}
+	n, d := parseFloat(num), parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func aspectRatio(w, h int) string {
+	if w <= 0 || h <= 0 {
+		return ""
+	}
+	g := gcd(w, h)
+	return fmt.Sprintf("%d:%d", w/g, h/g)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
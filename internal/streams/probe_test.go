@@ -0,0 +1,80 @@
+package streams
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProbe(t *testing.T) {
+	// Trimmed from ffprobe on a phone recording: stored landscape, rotated
+	// to portrait for display, with cover art attached
+	data := []byte(`{
+  "streams": [
+    {"codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "disposition": {"attached_pic": 1}},
+    {"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+     "avg_frame_rate": "30000/1001", "r_frame_rate": "30/1", "bit_rate": "7840201",
+     "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}], "disposition": {"attached_pic": 0}},
+    {"codec_type": "audio", "codec_name": "aac", "channels": 2, "channel_layout": "stereo", "sample_rate": "48000", "bit_rate": "128000"}
+  ],
+  "format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "15.015000", "size": "14980213", "bit_rate": "7981464"}
+}`)
+	meta, err := parseProbe(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.DurationSec != 15.015 || meta.SizeBytes != 14980213 || meta.BitRate != 7981464 {
+		t.Errorf("format = %v s, %d bytes, %d b/s", meta.DurationSec, meta.SizeBytes, meta.BitRate)
+	}
+	v := meta.Video
+	if v.Codec != "h264" || v.Width != 1080 || v.Height != 1920 || v.AspectRatio != "9:16" || v.Rotation != -90 {
+		t.Errorf("video = %+v, want rotated 1080x1920 h264", v)
+	}
+	if v.FPS < 29.97 || v.FPS > 29.98 {
+		t.Errorf("fps = %v, want 29.97", v.FPS)
+	}
+	if a := meta.Audio; a == nil || a.Channels != 2 || a.SampleRate != 48000 || a.ChannelLayout != "stereo" {
+		t.Errorf("audio = %+v", a)
+	}
+}
+
+func TestParseProbe_Silent(t *testing.T) {
+	meta, err := parseProbe([]byte(`{"streams": [{"codec_type": "video", "codec_name": "vp9", "width": 1280, "height": 720, "avg_frame_rate": "25/1", "tags": {"rotate": "0"}}], "format": {"duration": "6.0"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Audio != nil {
+		t.Errorf("audio = %+v, want none", meta.Audio)
+	}
+	if meta.Video.AspectRatio != "16:9" || meta.Video.FPS != 25 {
+		t.Errorf("video = %+v", meta.Video)
+	}
+}
+
+func TestParseProbe_NoVideo(t *testing.T) {
+	if _, err := parseProbe([]byte(`{"streams": [{"codec_type": "audio", "codec_name": "mp3"}], "format": {}}`)); err == nil {
+		t.Error("expected an error for a file without video")
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	video := filepath.Join(t.TempDir(), "video.mp4")
+	gen := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=320x240:rate=25",
+		"-f", "lavfi", "-i", "sine=duration=2", "-shortest", video)
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Fatalf("generate video: %v: %s", err, out)
+	}
+
+	meta, err := RunProbe(context.Background(), video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Video.AspectRatio != "4:3" || meta.Video.FPS != 25 || meta.Audio == nil || meta.Audio.Channels != 1 {
+		t.Errorf("meta = %+v, video %+v, audio %+v", meta, meta.Video, meta.Audio)
+	}
+}
@@ -0,0 +1,163 @@
+package streams
+
+import (
+	"context"
+	"strings"
+)
+
+// ProductsResult is the output of the products stream: where the advertised
+// product appears in each keyframe.
+type ProductsResult struct {
+	Frames []ProductFrame `json:"frames"`
+
+	// FirstSeenSec is the timestamp of the first keyframe showing the
+	// product, nil if none does
+	FirstSeenSec *float64 `json:"first_seen_sec"`
+
+	// VisibleSec estimates how long the product is on screen: each keyframe
+	// stands for the time halfway to its neighbours
+	VisibleSec float64 `json:"visible_sec"`
+
+	Incomplete bool        `json:"incomplete,omitempty"` // the job ended before every frame was analyzed
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type ProductFrame struct {
+	FrameIndex   int         `json:"frame_index"`
+	TimestampSec float64     `json:"timestamp_sec"`
+	Detections   []Detection `json:"detections"`
+
+	// ScreenShare is the share of the frame the product's boxes cover,
+	// overlaps counted once
+	ScreenShare float64 `json:"screen_share"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Detection is one sighting of the product. The box is in fractions of the
+// frame's width and height, from its top-left corner.
+type Detection struct {
+	Label string  `json:"label"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	W     float64 `json:"w"`
+	H     float64 `json:"h"`
+}
+
+const productsPromptTemplate = `Find the advertised product in these %d frames from a video advertisement. Each image is preceded by its frame index and timestamp.
+
+The advertised product is what the ad is selling: the item itself, its packaging, or the app or device screen showing it. Ignore other objects, logos in the background, and people unless the product is worn by them.
+
+For each frame give a bounding box around every visible instance of the product, with a short label naming it (for example "water bottle" or "app screen"). Boxes are [ymin, xmin, ymax, xmax] normalized to 0-1000. Give an empty list when the product is not visible.
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "products": [{"label": "<label>", "box_2d": [<ymin>, <xmin>, <ymax>, <xmax>]}]}]`
+
+type productsAnswer struct {
+	Products []struct {
+		Label string    `json:"label"`
+		Box   []float64 `json:"box_2d"`
+	} `json:"products"`
+}
+
+// RunProducts localizes the advertised product in each keyframe with
+// Gemini's bounding boxes. Malformed boxes are dropped.
+func RunProducts(ctx context.Context, keyframes []KeyframeInput, apiKey string) (*ProductsResult, error) {
+	result := &ProductsResult{
+		Frames:     []ProductFrame{},
+		Provenance: geminiProvenance(productsPromptVersion, map[string]string{"response_mime_type": "application/json"}),
+	}
+	answers := analyzeFrames[productsAnswer](ctx, apiKey, keyframes, productsPromptTemplate)
+	for _, a := range answers {
+		f := ProductFrame{FrameIndex: a.kf.FrameIndex, TimestampSec: a.kf.TimestampSec, Detections: []Detection{}}
+		if a.err != nil {
+			f.Error = a.err.Error()
+		}
+		for _, p := range a.value.Products {
+			if d, ok := toDetection(p.Label, p.Box); ok {
+				f.Detections = append(f.Detections, d)
+			}
+		}
+		f.ScreenShare = round3(coverage(f.Detections))
+		result.Frames = append(result.Frames, f)
+	}
+	result.Incomplete = len(answers) < len(keyframes)
+	result.normalize()
+	result.summarize()
+	return result, nil
+}
+
+// toDetection converts a [ymin, xmin, ymax, xmax] box on Gemini's 0-1000
+// scale, clamping it to the frame.
+func toDetection(label string, box []float64) (Detection, bool) {
+	if len(box) != 4 {
+		return Detection{}, false
+	}
+	for i := range box {
+		box[i] = min(max(box[i], 0), 1000) / 1000
+	}
+	ymin, xmin, ymax, xmax := box[0], box[1], box[2], box[3]
+	if xmax <= xmin || ymax <= ymin {
+		return Detection{}, false
+	}
+	return Detection{
+		Label: strings.TrimSpace(label),
+		X:     round3(xmin),
+		Y:     round3(ymin),
+		W:     round3(xmax - xmin),
+		H:     round3(ymax - ymin),
+	}, true
+}
+
+// coverage is the area of the union of the boxes, measured on a 100×100
+// grid, which is finer than the boxes are accurate.
+func coverage(boxes []Detection) float64 {
+	if len(boxes) == 1 {
+		return boxes[0].W * boxes[0].H
+	}
+	const n = 100
+	var cells int
+	for y := range n {
+		for x := range n {
+			cx, cy := (float64(x)+0.5)/n, (float64(y)+0.5)/n
+			for _, b := range boxes {
+				if cx >= b.X && cx < b.X+b.W && cy >= b.Y && cy < b.Y+b.H {
+					cells++
+					break
+				}
+			}
+		}
+	}
+	return float64(cells) / (n * n)
+}
+
+// summarize derives when the product is first seen and how long it is
+// visible. Frames with an error count as not showing it.
+func (r *ProductsResult) summarize() {
+	var visible float64
+	for i, f := range r.Frames {
+		if len(f.Detections) == 0 {
+			continue
+		}
+		if r.FirstSeenSec == nil {
+			ts := f.TimestampSec
+			r.FirstSeenSec = &ts
+		}
+		// Half the gap to each neighbour; the first and last frames reach
+		// as far out as they do in, but not before the start
+		var before, after float64
+		if i > 0 {
+			before = (f.TimestampSec - r.Frames[i-1].TimestampSec) / 2
+		}
+		if i < len(r.Frames)-1 {
+			after = (r.Frames[i+1].TimestampSec - f.TimestampSec) / 2
+		}
+		if i == 0 {
+			before = min(after, f.TimestampSec)
+		}
+		if i == len(r.Frames)-1 {
+			after = before
+		}
+		visible += max(before, 0) + max(after, 0)
+	}
+	r.VisibleSec = round3(visible)
+}
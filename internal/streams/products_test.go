@@ -0,0 +1,59 @@
+package streams
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunProducts(t *testing.T) {
+	stubFrameAnswers(t, func(idx int) map[string]any {
+		switch idx {
+		case 1:
+			return map[string]any{"products": []map[string]any{
+				{"label": " bottle ", "box_2d": []int{0, 0, 500, 500}},
+				{"label": "bottle", "box_2d": []int{250, 250, 750, 750}},
+				{"label": "inverted", "box_2d": []int{500, 500, 100, 100}},
+			}}
+		case 2:
+			return map[string]any{"products": []map[string]any{{"label": "bottle", "box_2d": []int{-50, 800, 400, 1200}}}}
+		}
+		return map[string]any{"products": []any{}}
+	})
+
+	// keyframes at 0, 1.5, 3 and 4.5s
+	res, err := RunProducts(context.Background(), testKeyframes(4), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frames) != 4 || res.Incomplete {
+		t.Fatalf("result = %+v", res)
+	}
+	if f := res.Frames[1]; len(f.Detections) != 2 || f.Detections[0] != (Detection{"bottle", 0, 0, 0.5, 0.5}) || f.ScreenShare != 0.438 {
+		t.Errorf("frame 1 = %+v", f)
+	}
+	if f := res.Frames[2]; len(f.Detections) != 1 || f.Detections[0] != (Detection{"bottle", 0.8, 0, 0.2, 0.4}) || f.ScreenShare != 0.08 {
+		t.Errorf("frame 2 = %+v", f)
+	}
+	if res.FirstSeenSec == nil || *res.FirstSeenSec != 1.5 {
+		t.Errorf("first seen at %v, want 1.5", res.FirstSeenSec)
+	}
+	// frames 1 and 2 each stand for 0.75s either side
+	if res.VisibleSec != 3 {
+		t.Errorf("visible for %vs, want 3", res.VisibleSec)
+	}
+}
+
+func TestProductsVisibleSec_Edges(t *testing.T) {
+	seen := []Detection{{Label: "bottle", W: 1, H: 1}}
+	r := &ProductsResult{Frames: []ProductFrame{
+		{TimestampSec: 1, Detections: seen},
+		{TimestampSec: 3},
+		{TimestampSec: 5, Detections: seen},
+	}}
+	r.summarize()
+	// 0-2s and 4-6s: the first frame reaches back as far as it does on, the
+	// last on as far as it does back
+	if r.VisibleSec != 4 {
+		t.Errorf("visible for %vs, want 4", r.VisibleSec)
+	}
+}
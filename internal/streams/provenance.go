@@ -0,0 +1,111 @@
+package streams
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provenance records which provider, model, prompt and parameters produced an
+// artifact, so results generated months apart can be compared.
+type Provenance struct {
+	Provider      string            `json:"provider"`
+	Model         string            `json:"model"`
+	PromptVersion string            `json:"prompt_version,omitempty"`
+	Params        map[string]string `json:"params,omitempty"`
+}
+
+// Prompt template versions. Bump the matching constant whenever a template's
+// wording changes.
+const (
+	vlmPromptVersion           = "vlm-v1"
+	vlmBatchPromptVersion      = "vlm-batch-v1"
+	storySummaryPromptVersion  = "vlm-story-v1"
+	keyMomentsPromptVersion    = "key-moments-v1"
+	summaryPromptVersion       = "summary-v1"
+	peoplePromptVersion        = "people-v1"
+	presenterPromptVersion     = "presenter-v1"
+	contentRatingPromptVersion = "content-rating-v1"
+	productsPromptVersion      = "products-v1"
+	onScreenTextPromptVersion  = "on-screen-text-v1"
+	musicPromptVersion         = "music-v1"
+	hookPromptVersion          = "hook-v1"
+	comparePromptVersion       = "compare-v1"
+	entitiesPromptVersion      = "entities-v1"
+)
+
+// Output format versions, written to each result as schema_version. Bump
+// the matching constant when a change could break readers: a field removed,
+// renamed or given another meaning. New optional fields need no bump.
+// Results stored before the field existed have none, and are version 1.
+const (
+	ASRSchemaVersion = 1
+	VLMSchemaVersion = 1
+)
+
+const (
+	deepgramModel = "nova-3"
+	geminiModel   = "gemini-2.0-flash"
+)
+
+func geminiProvenance(promptVersion string, params map[string]string) *Provenance {
+	return &Provenance{
+		Provider:      "google-gemini",
+		Model:         geminiModel,
+		PromptVersion: promptVersion,
+		Params:        params,
+	}
+}
+
+// currentPromptVersions holds the templates in use, by family: the version
+// without its "-vN" suffix.
+var currentPromptVersions = func() map[string]string {
+	m := map[string]string{}
+	for _, v := range []string{
+		vlmPromptVersion, vlmBatchPromptVersion, storySummaryPromptVersion,
+		keyMomentsPromptVersion, summaryPromptVersion, peoplePromptVersion,
+		presenterPromptVersion, contentRatingPromptVersion, productsPromptVersion,
+		onScreenTextPromptVersion, musicPromptVersion, hookPromptVersion,
+		comparePromptVersion, entitiesPromptVersion,
+	} {
+		m[promptFamily(v)] = v
+	}
+	return m
+}()
+
+func promptFamily(version string) string {
+	if i := strings.LastIndex(version, "-v"); i > 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// Outdated reports why a result with provenance p would come out differently
+// if it were produced now: an older prompt template, or a model other than
+// the current default. A model chosen through parameters is not a default
+// and is left alone, and neither is a custom prompt. It returns ""
+// for current results and for those of providers without prompts or
+// versioned models.
+func Outdated(p *Provenance) string {
+	if p == nil {
+		return ""
+	}
+	for _, v := range []string{p.PromptVersion, p.Params["summary_prompt"]} {
+		if v == "" || strings.HasPrefix(v, "custom-") {
+			continue
+		}
+		current, ok := currentPromptVersions[promptFamily(v)]
+		switch {
+		case !ok:
+			return fmt.Sprintf("prompt %s is no longer used", v)
+		case v != current:
+			return fmt.Sprintf("prompt %s, now %s", v, current)
+		}
+	}
+	switch {
+	case p.Provider == "google-gemini" && p.Params["model"] == "" && p.Model != geminiModel:
+		return fmt.Sprintf("model %s, now %s", p.Model, geminiModel)
+	case p.Provider == "deepgram" && p.Params["model"] == "" && p.Model != deepgramModel:
+		return fmt.Sprintf("model %s, now %s", p.Model, deepgramModel)
+	}
+	return ""
+}
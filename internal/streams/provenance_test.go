@@ -0,0 +1,26 @@
+package streams
+
+import "testing"
+
+func TestOutdated(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    *Provenance
+		want string
+	}{
+		{"none", nil, ""},
+		{"current", geminiProvenance(vlmPromptVersion, nil), ""},
+		{"current batch", geminiProvenance(vlmBatchPromptVersion, map[string]string{"mode": "batch"}), ""},
+		{"old prompt", geminiProvenance("summary-v0", nil), "prompt summary-v0, now " + summaryPromptVersion},
+		{"retired prompt", geminiProvenance("slogan-v2", nil), "prompt slogan-v2 is no longer used"},
+		{"old story prompt", geminiProvenance(vlmPromptVersion, map[string]string{"summary_prompt": "vlm-story-v0"}), "prompt vlm-story-v0, now " + storySummaryPromptVersion},
+		{"old gemini model", &Provenance{Provider: "google-gemini", Model: "gemini-1.5-flash", PromptVersion: peoplePromptVersion}, "model gemini-1.5-flash, now " + geminiModel},
+		{"old deepgram model", &Provenance{Provider: "deepgram", Model: "nova-2"}, "model nova-2, now " + deepgramModel},
+		{"chosen deepgram model", &Provenance{Provider: "deepgram", Model: "nova-2", Params: map[string]string{"model": "nova-2"}}, ""},
+		{"local", &Provenance{Provider: "local", Model: "rgb-histogram"}, ""},
+	} {
+		if got := Outdated(tc.p); got != tc.want {
+			t.Errorf("%s: Outdated = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
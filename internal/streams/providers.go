@@ -0,0 +1,159 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/breaker"
+	"github.com/nikipaj1/video-description-pipeline/internal/health"
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+	"github.com/nikipaj1/video-description-pipeline/internal/ratelimit"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// ErrProviderUnavailable is returned without calling the provider when its
+// circuit breaker is open.
+var ErrProviderUnavailable = errors.New("provider unavailable")
+
+// ErrProviderDegraded is returned without calling the provider while its
+// recent error rate is above the health threshold.
+var ErrProviderDegraded = errors.New("provider degraded")
+
+// Process-wide provider guards, installed once at startup. Nil disables them.
+var (
+	geminiLimiter   ratelimit.Limiter
+	deepgramBreaker *breaker.Breaker
+	deepgramHealth  *health.Tracker
+	geminiHealth    *health.Tracker
+)
+
+// Each provider gets its own connection pool. Deepgram transcribes the whole
+// video before sending response headers, so it gets a long header timeout;
+// Gemini answers per frame and should never take minutes.
+var (
+	deepgramTransport = httpclient.Options{
+		MaxIdleConnsPerHost:   8,
+		MaxConnsPerHost:       32,
+		ResponseHeaderTimeout: 5 * time.Minute,
+	}
+	geminiTransport = httpclient.Options{
+		MaxIdleConnsPerHost:   32,
+		MaxConnsPerHost:       128,
+		ResponseHeaderTimeout: 90 * time.Second,
+	}
+
+	deepgramClient = httpclient.New(deepgramTransport)
+	geminiClient   = httpclient.New(geminiTransport)
+)
+
+// SetTransportOptions rebuilds both provider clients, with the non-zero
+// fields of each Options overriding the built-in settings above.
+func SetTransportOptions(deepgram, gemini httpclient.Options) {
+	deepgramClient = httpclient.New(deepgramTransport.Override(deepgram))
+	geminiClient = httpclient.New(geminiTransport.Override(gemini))
+}
+
+// SetDeepgramHTTPClient replaces the client used for Deepgram calls, e.g. to
+// route through a proxy or a test transport.
+func SetDeepgramHTTPClient(c *http.Client) {
+	deepgramClient = c
+}
+
+// SetGeminiHTTPClient replaces the client used for Gemini calls.
+func SetGeminiHTTPClient(c *http.Client) {
+	geminiClient = c
+}
+
+// WrapTransports wraps the transport of each provider client, e.g. to
+// record its traffic. Call it after any other client setup.
+func WrapTransports(wrap func(provider string, rt http.RoundTripper) http.RoundTripper) {
+	deepgramClient = wrapClient(deepgramClient, wrap("deepgram", transportOf(deepgramClient)))
+	geminiClient = wrapClient(geminiClient, wrap("gemini", transportOf(geminiClient)))
+}
+
+func transportOf(c *http.Client) http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+func wrapClient(c *http.Client, rt http.RoundTripper) *http.Client {
+	wrapped := *c
+	wrapped.Transport = rt
+	return &wrapped
+}
+
+// Retry policies for transient provider failures.
+var (
+	deepgramRetry = retry.Default
+	geminiRetry   = retry.Default
+)
+
+// SetRetryPolicies replaces the per-provider retry policies.
+func SetRetryPolicies(deepgram, gemini retry.Policy) {
+	deepgramRetry = deepgram
+	geminiRetry = gemini
+}
+
+// SetGeminiLimiter installs the limiter shared by all jobs.
+func SetGeminiLimiter(l ratelimit.Limiter) {
+	geminiLimiter = l
+}
+
+// SetDeepgramBreaker installs the circuit breaker guarding Deepgram calls.
+func SetDeepgramBreaker(b *breaker.Breaker) {
+	deepgramBreaker = b
+}
+
+// SetProviderHealth installs the error-rate trackers for each provider.
+func SetProviderHealth(deepgram, gemini *health.Tracker) {
+	deepgramHealth = deepgram
+	geminiHealth = gemini
+}
+
+// DeepgramHealth returns an error wrapping ErrProviderDegraded while Deepgram
+// is failing too often to be worth calling.
+func DeepgramHealth() error {
+	if err := deepgramHealth.Check(); err != nil {
+		return fmt.Errorf("deepgram: %w: %v", ErrProviderDegraded, err)
+	}
+	return nil
+}
+
+// GeminiHealth is DeepgramHealth for Gemini.
+func GeminiHealth() error {
+	if err := geminiHealth.Check(); err != nil {
+		return fmt.Errorf("gemini: %w: %v", ErrProviderDegraded, err)
+	}
+	return nil
+}
+
+// recordHealth reports a call result to a health tracker. Calls ended by the
+// job's own context say nothing about the provider, but a per-frame timeout
+// means the provider was too slow and counts as a failure.
+func recordHealth(ctx context.Context, t *health.Tracker, healthy bool) {
+	if t == nil {
+		return
+	}
+	if !healthy && ctx.Err() != nil && !errors.Is(context.Cause(ctx), errFrameTimeout) {
+		return
+	}
+	t.Record(healthy)
+}
+
+// recordOutcome reports a call result to a breaker. Failures caused by the
+// caller's own context ending say nothing about provider health.
+func recordOutcome(ctx context.Context, b *breaker.Breaker, healthy bool) {
+	if b == nil {
+		return
+	}
+	if !healthy && ctx.Err() != nil {
+		b.Release()
+		return
+	}
+	b.Record(healthy)
+}
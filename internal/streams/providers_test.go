@@ -0,0 +1,147 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/health"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// Tests exercise single attempts unless they opt into retries explicitly.
+func TestMain(m *testing.M) {
+	SetRetryPolicies(retry.Policy{MaxAttempts: 1}, retry.Policy{MaxAttempts: 1})
+	os.Exit(m.Run())
+}
+
+func withRetries(t *testing.T) {
+	t.Helper()
+	fast := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	SetRetryPolicies(fast, fast)
+	t.Cleanup(func() {
+		SetRetryPolicies(retry.Policy{MaxAttempts: 1}, retry.Policy{MaxAttempts: 1})
+	})
+}
+
+func TestRunASR_RetriesTransientFailure(t *testing.T) {
+	withRetries(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The body must be replayed in full on retry
+		if body, _ := io.ReadAll(r.Body); string(body) != "video" {
+			t.Errorf("attempt %d body = %q", calls, body)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"results": map[string]any{
+				"utterances": []map[string]any{{"start": 0.0, "end": 1.0, "transcript": "Hi"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := deepgramBaseURL
+	deepgramBaseURL = server.URL
+	defer func() { deepgramBaseURL = old }()
+
+	result, err := RunASR(context.Background(), strings.NewReader("video"), 5, "key", ASROptions{})
+	if err != nil {
+		t.Fatalf("RunASR error: %v", err)
+	}
+	if calls != 2 || len(result.Segments) != 1 {
+		t.Errorf("calls = %d, segments = %d", calls, len(result.Segments))
+	}
+}
+
+func TestCallGemini_NoRetryOnClientError(t *testing.T) {
+	withRetries(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	if _, err := callGemini(context.Background(), "key", []byte("img"), "prompt"); err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRunASR_NoRetryWithoutSeeker(t *testing.T) {
+	withRetries(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	old := deepgramBaseURL
+	deepgramBaseURL = server.URL
+	defer func() { deepgramBaseURL = old }()
+
+	// io.MultiReader hides the underlying Seeker
+	video := io.MultiReader(strings.NewReader("video"))
+	if _, err := RunASR(context.Background(), video, 5, "key", ASROptions{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 for a non-rewindable body", calls)
+	}
+}
+
+func TestGemini_SkippedWhileDegraded(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	SetProviderHealth(nil, health.New(time.Minute, 0.5, 2))
+	defer SetProviderHealth(nil, nil)
+
+	for range 2 {
+		callGeminiText(context.Background(), "key", "prompt")
+	}
+	if err := GeminiHealth(); !errors.Is(err, ErrProviderDegraded) {
+		t.Fatalf("GeminiHealth = %v, want ErrProviderDegraded", err)
+	}
+
+	_, err := callGeminiText(context.Background(), "key", "prompt")
+	if !errors.Is(err, ErrProviderDegraded) {
+		t.Errorf("err = %v, want ErrProviderDegraded", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (degraded provider not called)", calls)
+	}
+	if DeepgramHealth() != nil {
+		t.Error("deepgram should be unaffected")
+	}
+}
@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QualityScore summarizes how trustworthy a job's extraction is. Score is in
+// [0, 1]; Flagged is set when it falls below the configured threshold.
+type QualityScore struct {
+	Score          float64  `json:"score"`
+	VLMErrorRate   float64  `json:"vlm_error_rate"`
+	VLMBlocked     int      `json:"vlm_blocked_frames"`
+	ASRConfidence  float64  `json:"asr_confidence"`
+	SpeechCoverage float64  `json:"speech_coverage"`
+	Flagged        bool     `json:"flagged"`
+	Reasons        []string `json:"reasons,omitempty"`
+}
+
+// Component weights. Speech coverage is weighted lightly since music-only
+// ads legitimately have little speech.
+const (
+	weightVLM      = 0.45
+	weightASR      = 0.40
+	weightCoverage = 0.15
+)
+
+// ScoreQuality computes the quality score from whichever stream results are
+// available. Missing streams are left out and the remaining weights are
+// renormalized; with neither stream the score is 0.
+func ScoreQuality(asr *ASRResult, vlm *VLMResult, threshold float64) *QualityScore {
+	q := &QualityScore{}
+	var total, weight float64
+
+	if vlm != nil && len(vlm.Frames) > 0 {
+		var failed int
+		for _, f := range vlm.Frames {
+			switch {
+			case f.Blocked:
+				q.VLMBlocked++
+			case strings.HasPrefix(f.Description, "[Error:"):
+				failed++
+			}
+		}
+		n := float64(len(vlm.Frames))
+		q.VLMErrorRate = round3(float64(failed) / n)
+		total += weightVLM * (1 - float64(failed+q.VLMBlocked)/n)
+		weight += weightVLM
+
+		if failed > 0 {
+			q.Reasons = append(q.Reasons, fmt.Sprintf("%d of %d frames failed", failed, len(vlm.Frames)))
+		}
+		if q.VLMBlocked > 0 {
+			q.Reasons = append(q.Reasons, fmt.Sprintf("%d frames blocked by safety filters", q.VLMBlocked))
+		}
+	}
+
+	if asr != nil {
+		var spoken, confWeighted float64
+		for _, s := range asr.Segments {
+			d := max(s.End-s.Start, 0)
+			spoken += d
+			confWeighted += s.Confidence * d
+		}
+		if spoken > 0 {
+			q.ASRConfidence = round3(confWeighted / spoken)
+		}
+		if asr.DurationSec > 0 {
+			q.SpeechCoverage = round3(min(spoken/asr.DurationSec, 1))
+		}
+
+		total += weightASR*q.ASRConfidence + weightCoverage*q.SpeechCoverage
+		weight += weightASR + weightCoverage
+
+		if len(asr.Segments) > 0 && q.ASRConfidence < 0.7 {
+			q.Reasons = append(q.Reasons, fmt.Sprintf("low ASR confidence %.2f", q.ASRConfidence))
+		}
+	}
+
+	if weight > 0 {
+		q.Score = round3(total / weight)
+	}
+	if q.Score < threshold {
+		q.Flagged = true
+		if weight == 0 {
+			q.Reasons = append(q.Reasons, "no stream results")
+		}
+	}
+	return q
+}
@@ -0,0 +1,67 @@
+package streams
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreQuality_Clean(t *testing.T) {
+	asr := &ASRResult{DurationSec: 10, Segments: []ASRSegment{
+		{Start: 0, End: 4, Text: "a", Confidence: 0.9},
+		{Start: 5, End: 9, Text: "b", Confidence: 1.0},
+	}}
+	vlm := &VLMResult{Frames: []VLMFrame{
+		{Description: "ok"}, {Description: "ok"},
+	}}
+
+	q := ScoreQuality(asr, vlm, 0.6)
+
+	if q.ASRConfidence != 0.95 {
+		t.Errorf("asr confidence = %v, want 0.95", q.ASRConfidence)
+	}
+	if q.SpeechCoverage != 0.8 {
+		t.Errorf("coverage = %v, want 0.8", q.SpeechCoverage)
+	}
+	// 0.45*1 + 0.40*0.95 + 0.15*0.8 = 0.95
+	if math.Abs(q.Score-0.95) > 1e-9 {
+		t.Errorf("score = %v, want 0.95", q.Score)
+	}
+	if q.Flagged || len(q.Reasons) != 0 {
+		t.Errorf("unexpected flag: %+v", q)
+	}
+}
+
+func TestScoreQuality_ErrorsAndBlocked(t *testing.T) {
+	vlm := &VLMResult{Frames: []VLMFrame{
+		{Description: "ok"},
+		{Description: "[Error: gemini returned 500: boom]"},
+		{Description: "[Error: blocked by gemini safety filters: SAFETY]", Blocked: true},
+		{Description: "[Error: timeout]"},
+	}}
+
+	q := ScoreQuality(nil, vlm, 0.6)
+
+	if q.VLMErrorRate != 0.5 {
+		t.Errorf("error rate = %v, want 0.5", q.VLMErrorRate)
+	}
+	if q.VLMBlocked != 1 {
+		t.Errorf("blocked = %d, want 1", q.VLMBlocked)
+	}
+	// Only VLM present: 1 of 4 frames usable
+	if q.Score != 0.25 {
+		t.Errorf("score = %v, want 0.25", q.Score)
+	}
+	if !q.Flagged {
+		t.Error("expected job to be flagged")
+	}
+	if len(q.Reasons) != 2 {
+		t.Errorf("reasons = %v", q.Reasons)
+	}
+}
+
+func TestScoreQuality_NoResults(t *testing.T) {
+	q := ScoreQuality(nil, nil, 0.6)
+	if q.Score != 0 || !q.Flagged {
+		t.Errorf("q = %+v, want flagged zero score", q)
+	}
+}
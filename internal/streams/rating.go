@@ -0,0 +1,172 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// ContentRatingResult is the output of the content_rating stream: a rating
+// for each keyframe and the ad's, which is its worst frame's.
+type ContentRatingResult struct {
+	Frames []RatingFrame `json:"frames"`
+
+	Rating     string `json:"rating"` // one of Ratings, "safe" if no frame was rated
+	Violence   bool   `json:"violence"`
+	Substances bool   `json:"substances"`
+
+	// Quarantine is set when Rating reaches the configured threshold, for
+	// the ad to be held back pending review
+	Quarantine bool `json:"quarantine"`
+
+	Incomplete bool        `json:"incomplete,omitempty"` // the job ended before every frame was rated
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type RatingFrame struct {
+	FrameIndex   int     `json:"frame_index"`
+	TimestampSec float64 `json:"timestamp_sec"`
+	Rating       string  `json:"rating"`
+	Violence     bool    `json:"violence"`
+	Substances   bool    `json:"substances"` // alcohol, tobacco or drugs shown or used
+	Blocked      bool    `json:"blocked,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// Ratings are the content ratings, least severe first.
+var Ratings = []string{"safe", "suggestive", "explicit"}
+
+// RatingOptions configures RunContentRating.
+type RatingOptions struct {
+	// ModerationURL, if set, rates frames with a moderation API instead of
+	// Gemini: each keyframe is POSTed to it as image/jpeg and it answers
+	// {"rating", "violence", "substances"}
+	ModerationURL string
+
+	// QuarantineAt is the rating from which the ad is quarantined
+	QuarantineAt string
+}
+
+const contentRatingPromptTemplate = `Rate these %d frames from a video advertisement for brand safety. Each image is preceded by its frame index and timestamp.
+
+For each frame report:
+- rating: "safe" for content suitable for all audiences; "suggestive" for revealing clothing, sexualized poses or innuendo without nudity; "explicit" for nudity or sexual activity
+- violence: true if the frame shows weapons used against people, fighting, injury or blood
+- substances: true if the frame shows alcohol, tobacco, vaping or drugs, or people using them
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "rating": "<rating>", "violence": <bool>, "substances": <bool>}]`
+
+type ratingAnswer struct {
+	Rating     string `json:"rating"`
+	Violence   bool   `json:"violence"`
+	Substances bool   `json:"substances"`
+}
+
+// moderationClient calls the moderation API, one frame at a time.
+var moderationClient = &http.Client{Timeout: 30 * time.Second}
+
+// RunContentRating rates each keyframe with Gemini or, if configured, a
+// moderation API. Frames Gemini's own safety filters refuse to look at are
+// rated explicit, erring towards review.
+func RunContentRating(ctx context.Context, keyframes []KeyframeInput, apiKey string, opts RatingOptions) (*ContentRatingResult, error) {
+	result := &ContentRatingResult{Frames: []RatingFrame{}}
+	var answers []frameAnswer[ratingAnswer]
+	if opts.ModerationURL != "" {
+		result.Provenance = &Provenance{Provider: "moderation-api", Model: moderationHost(opts.ModerationURL)}
+		answers = moderateFrames(ctx, opts.ModerationURL, keyframes)
+	} else {
+		result.Provenance = geminiProvenance(contentRatingPromptVersion, map[string]string{"response_mime_type": "application/json"})
+		answers = analyzeFrames[ratingAnswer](ctx, apiKey, keyframes, contentRatingPromptTemplate)
+	}
+
+	severity := 0
+	for _, a := range answers {
+		f := RatingFrame{FrameIndex: a.kf.FrameIndex, TimestampSec: round3(a.kf.TimestampSec)}
+		switch {
+		case errors.Is(a.err, ErrBlocked):
+			f.Rating, f.Blocked = "explicit", true
+		case a.err != nil:
+			f.Error = a.err.Error()
+			result.Frames = append(result.Frames, f)
+			continue
+		default:
+			f.Rating = oneOf(a.value.Rating, Ratings)
+			f.Violence, f.Substances = a.value.Violence, a.value.Substances
+		}
+		severity = max(severity, slices.Index(Ratings, f.Rating))
+		result.Violence = result.Violence || f.Violence
+		result.Substances = result.Substances || f.Substances
+		result.Frames = append(result.Frames, f)
+	}
+	result.Rating = Ratings[severity]
+	if at := slices.Index(Ratings, opts.QuarantineAt); at > 0 {
+		result.Quarantine = severity >= at
+	}
+	result.Incomplete = len(answers) < len(keyframes)
+	return result, nil
+}
+
+// moderateFrames asks the moderation API about each keyframe in turn.
+func moderateFrames(ctx context.Context, endpoint string, keyframes []KeyframeInput) []frameAnswer[ratingAnswer] {
+	var answers []frameAnswer[ratingAnswer]
+	for lf := range prefetchFrames(ctx, keyframes) {
+		if ctx.Err() != nil {
+			lf.release()
+			break
+		}
+		a := frameAnswer[ratingAnswer]{kf: lf.kf, err: lf.err}
+		if a.err == nil {
+			a.value, a.err = moderate(ctx, endpoint, lf.img)
+		}
+		lf.release()
+		answers = append(answers, a)
+	}
+	return answers
+}
+
+func moderate(ctx context.Context, endpoint string, img []byte) (ratingAnswer, error) {
+	var out ratingAnswer
+	err := retry.Do(ctx, retry.Default, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(img))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "image/jpeg")
+		resp, err := moderationClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 300 {
+			return retry.NewHTTPError("moderation", resp, body)
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return fmt.Errorf("decode moderation response: %w", err)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// moderationHost names the moderation API in provenance without any
+// credentials its URL carries.
+func moderationHost(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRunContentRating(t *testing.T) {
+	stubFrameAnswers(t, func(idx int) map[string]any {
+		switch idx {
+		case 0:
+			return map[string]any{"rating": "safe"}
+		case 1:
+			return map[string]any{"rating": "Suggestive", "substances": true}
+		}
+		return nil
+	})
+
+	for _, tc := range []struct {
+		quarantineAt string
+		quarantine   bool
+	}{
+		{"explicit", false},
+		{"suggestive", true},
+		{"", false},
+	} {
+		res, err := RunContentRating(context.Background(), testKeyframes(3), "key", RatingOptions{QuarantineAt: tc.quarantineAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Frames) != 3 || res.Frames[1].Rating != "suggestive" || res.Frames[2].Error == "" {
+			t.Fatalf("frames = %+v", res.Frames)
+		}
+		if res.Rating != "suggestive" || !res.Substances || res.Violence {
+			t.Errorf("rollup = %+v", res)
+		}
+		if res.Quarantine != tc.quarantine {
+			t.Errorf("quarantine at %q = %v, want %v", tc.quarantineAt, res.Quarantine, tc.quarantine)
+		}
+	}
+}
+
+func TestRunContentRating_ModerationAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("content type %q", r.Header.Get("Content-Type"))
+		}
+		rating := map[string]any{"rating": "safe"}
+		if string(img) == "fight" {
+			rating = map[string]any{"rating": "unknown", "violence": true}
+		}
+		json.NewEncoder(w).Encode(rating)
+	}))
+	defer server.Close()
+
+	res, err := RunContentRating(context.Background(), []KeyframeInput{
+		{FrameIndex: 0, ImageBytes: []byte("beach")},
+		{FrameIndex: 1, TimestampSec: 2, ImageBytes: []byte("fight")},
+	}, "", RatingOptions{ModerationURL: server.URL + "/v1/rate", QuarantineAt: "explicit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frames) != 2 || res.Frames[1].Rating != "safe" || !res.Frames[1].Violence {
+		t.Fatalf("frames = %+v", res.Frames)
+	}
+	if res.Rating != "safe" || !res.Violence || res.Quarantine {
+		t.Errorf("rollup = %+v", res)
+	}
+	if res.Provenance.Provider != "moderation-api" || res.Provenance.Model != server.Listener.Addr().String() {
+		t.Errorf("provenance = %+v", res.Provenance)
+	}
+}
@@ -0,0 +1,86 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/replay"
+)
+
+// runReplayScenario runs every stream once against whatever provider
+// clients are installed. testdata/replay holds its recorded traffic;
+// re-record it with PROVIDER_RECORD_DIR after changing prompts or requests.
+func runReplayScenario(t *testing.T) (*ASRResult, *VLMResult, *KeyMomentsResult, *SummaryResult) {
+	t.Helper()
+	ctx := context.Background()
+
+	video := []byte("replay scenario video")
+	asr, err := RunASR(ctx, bytes.NewReader(video), int64(len(video)), "key", ASROptions{})
+	if err != nil {
+		t.Fatalf("RunASR: %v", err)
+	}
+	vlm, err := RunVLM(ctx, []KeyframeInput{
+		{FrameIndex: 0, TimestampSec: 0, ImageBytes: []byte("frame 0")},
+		{FrameIndex: 1, TimestampSec: 5, ImageBytes: []byte("frame 1")},
+	}, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLM: %v", err)
+	}
+	tl := BuildTimeline(asr, vlm)
+	moments, err := RunKeyMoments(ctx, tl, "key")
+	if err != nil {
+		t.Fatalf("RunKeyMoments: %v", err)
+	}
+	summary, err := RunSummaries(ctx, tl, "key")
+	if err != nil {
+		t.Fatalf("RunSummaries: %v", err)
+	}
+	return asr, vlm, moments, summary
+}
+
+func withReplay(t *testing.T, provider string) *replay.Replayer {
+	t.Helper()
+	r, err := replay.Load("testdata/replay/" + provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := deepgramClient
+	oldGemini := geminiClient
+	t.Cleanup(func() { deepgramClient, geminiClient = old, oldGemini })
+	if provider == "deepgram" {
+		SetDeepgramHTTPClient(&http.Client{Transport: r})
+	} else {
+		SetGeminiHTTPClient(&http.Client{Transport: r})
+	}
+	return r
+}
+
+func TestReplay_RecordedScenario(t *testing.T) {
+	oldThreshold := geminiFileThreshold
+	SetGeminiFileThreshold(0)
+	t.Cleanup(func() { SetGeminiFileThreshold(oldThreshold) })
+	deepgram := withReplay(t, "deepgram")
+	gemini := withReplay(t, "gemini")
+
+	asr, vlm, moments, summary := runReplayScenario(t)
+
+	if len(asr.Segments) != 3 || asr.Segments[2].Text != "Order today and get twenty percent off." || asr.Language != "en" {
+		t.Errorf("ASR = %+v", asr)
+	}
+	if len(vlm.Frames) != 2 || vlm.Frames[1].Description == "" || vlm.Frames[1].Blocked {
+		t.Errorf("VLM frames = %+v", vlm.Frames)
+	}
+	if len(moments.Moments) != 4 || moments.Moments[0].Type != "hook" {
+		t.Errorf("key moments = %+v", moments.Moments)
+	}
+	if summary.Combined == "" || summary.SoundOff == "" || summary.EyesClosed == "" {
+		t.Errorf("summaries = %+v", summary)
+	}
+	for _, r := range []*replay.Replayer{deepgram, gemini} {
+		if unused := r.Unused(); len(unused) > 0 {
+			t.Errorf("recorded calls not made: %v", unused)
+		}
+	}
+}
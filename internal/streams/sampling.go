@@ -0,0 +1,73 @@
+package streams
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Keyframe selection strategies used when an ad has more keyframes than
+// VLMOptions.MaxFrames.
+const (
+	SelectByEntropy = "entropy" // keep the highest entropy_score frames
+	SelectEvenly    = "even"    // keep frames spread evenly over time
+)
+
+// SkippedFrame is a keyframe left out by the frame cap.
+type SkippedFrame struct {
+	FrameIndex   int     `json:"frame_index"`
+	TimestampSec float64 `json:"timestamp_sec"`
+	Reason       string  `json:"reason,omitempty"` // SkipOverBudget, or empty for the frame cap
+}
+
+// selectKeyframes caps keyframes at maxFrames using the given strategy
+// (entropy when empty). Kept frames stay in time order. maxFrames <= 0
+// keeps everything.
+func selectKeyframes(keyframes []KeyframeInput, maxFrames int, strategy string) (kept []KeyframeInput, skipped []SkippedFrame) {
+	if maxFrames <= 0 || len(keyframes) <= maxFrames {
+		return keyframes, nil
+	}
+
+	ordered := slices.Clone(keyframes)
+	slices.SortStableFunc(ordered, byTime)
+
+	keep := make([]bool, len(ordered))
+	switch strategy {
+	case SelectEvenly:
+		for k := range maxFrames {
+			i := 0
+			if maxFrames > 1 {
+				i = int(math.Round(float64(k) * float64(len(ordered)-1) / float64(maxFrames-1)))
+			}
+			keep[i] = true
+		}
+	default:
+		idx := make([]int, len(ordered))
+		for i := range idx {
+			idx[i] = i
+		}
+		// Highest entropy first; earlier frames win ties
+		slices.SortStableFunc(idx, func(a, b int) int {
+			return cmp.Compare(ordered[b].EntropyScore, ordered[a].EntropyScore)
+		})
+		for _, i := range idx[:maxFrames] {
+			keep[i] = true
+		}
+	}
+
+	for i, kf := range ordered {
+		if keep[i] {
+			kept = append(kept, kf)
+		} else {
+			skipped = append(skipped, SkippedFrame{FrameIndex: kf.FrameIndex, TimestampSec: round3(kf.TimestampSec)})
+		}
+	}
+	return kept, skipped
+}
+
+func byTime(a, b KeyframeInput) int {
+	if c := cmp.Compare(a.TimestampSec, b.TimestampSec); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.FrameIndex, b.FrameIndex)
+}
@@ -0,0 +1,54 @@
+package streams
+
+import (
+	"fmt"
+	"testing"
+)
+
+func samplingInputs() []KeyframeInput {
+	entropy := []float64{0.1, 0.9, 0.3, 0.8, 0.2, 0.7}
+	var kfs []KeyframeInput
+	// Deliberately out of time order
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		kfs = append(kfs, KeyframeInput{FrameIndex: i, TimestampSec: float64(i) * 1.5, EntropyScore: entropy[i]})
+	}
+	return kfs
+}
+
+func frameIndexes(kfs []KeyframeInput) string {
+	var idx []int
+	for _, kf := range kfs {
+		idx = append(idx, kf.FrameIndex)
+	}
+	return fmt.Sprint(idx)
+}
+
+func TestSelectKeyframes_Entropy(t *testing.T) {
+	kept, skipped := selectKeyframes(samplingInputs(), 3, "")
+	if got := frameIndexes(kept); got != "[1 3 5]" {
+		t.Errorf("kept = %s, want [1 3 5]", got)
+	}
+	if fmt.Sprint(skipped) != "[{0 0 } {2 3 } {4 6 }]" {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
+
+func TestSelectKeyframes_Evenly(t *testing.T) {
+	kept, skipped := selectKeyframes(samplingInputs(), 3, SelectEvenly)
+	if got := frameIndexes(kept); got != "[0 3 5]" {
+		t.Errorf("kept = %s, want [0 3 5]", got)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
+
+func TestSelectKeyframes_UnderCap(t *testing.T) {
+	in := samplingInputs()
+	for _, n := range []int{0, 6, 10} {
+		kept, skipped := selectKeyframes(in, n, SelectByEntropy)
+		if len(kept) != len(in) || skipped != nil {
+			t.Errorf("max %d: kept %d, skipped %v", n, len(kept), skipped)
+		}
+	}
+}
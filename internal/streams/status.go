@@ -0,0 +1,148 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusWindow is how far back ProviderStatuses looks, and statusSamples
+// how many calls per provider it keeps at most.
+const (
+	statusWindow  = 5 * time.Minute
+	statusSamples = 512
+)
+
+// ProviderStatus summarizes a provider's recent calls for dashboards.
+type ProviderStatus struct {
+	Provider  string         `json:"provider"`
+	Calls     int            `json:"calls"` // in the last Window
+	Window    string         `json:"window"`
+	ErrorRate float64        `json:"error_rate"`
+	LatencyMs LatencySummary `json:"latency_ms"`
+
+	// Degraded is why calls are being skipped, if they are
+	Degraded string `json:"degraded,omitempty"`
+	// Breaker is the circuit breaker's state, if the provider has one
+	Breaker string `json:"breaker,omitempty"`
+	// RateLimit holds the rate-limit headers of the latest response that
+	// had any, e.g. "x-ratelimit-remaining-requests"
+	RateLimit map[string]string `json:"rate_limit,omitempty"`
+}
+
+// LatencySummary gives percentiles of call durations, 0 without calls.
+type LatencySummary struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+type callSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// callStats keeps a provider's most recent calls.
+type callStats struct {
+	mu        sync.Mutex
+	samples   []callSample // ring of statusSamples
+	next      int
+	rateLimit map[string]string
+}
+
+var (
+	deepgramStats = &callStats{}
+	geminiStats   = &callStats{}
+)
+
+// observe records a call that took since start, with its response if one
+// arrived. Calls that failed because ctx ended are left out, as they say
+// nothing about the provider.
+func (s *callStats) observe(ctx context.Context, start time.Time, resp *http.Response, failed bool) {
+	if failed && ctx.Err() != nil && !errors.Is(context.Cause(ctx), errFrameTimeout) {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sample := callSample{at: now, latency: now.Sub(start), failed: failed}
+	if len(s.samples) < statusSamples {
+		s.samples = append(s.samples, sample)
+	} else {
+		s.samples[s.next] = sample
+		s.next = (s.next + 1) % statusSamples
+	}
+	if resp == nil {
+		return
+	}
+	var limits map[string]string
+	for k, v := range resp.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-ratelimit-") || strings.HasPrefix(k, "ratelimit") || k == "retry-after" {
+			if limits == nil {
+				limits = map[string]string{}
+			}
+			limits[k] = v[0]
+		}
+	}
+	if limits != nil {
+		s.rateLimit = limits
+	}
+}
+
+func (s *callStats) status(provider string) ProviderStatus {
+	st := ProviderStatus{Provider: provider, Window: statusWindow.String()}
+	cutoff := time.Now().Add(-statusWindow)
+
+	s.mu.Lock()
+	var latencies []time.Duration
+	var failed int
+	for _, c := range s.samples {
+		if c.at.After(cutoff) {
+			latencies = append(latencies, c.latency)
+			if c.failed {
+				failed++
+			}
+		}
+	}
+	st.RateLimit = maps.Clone(s.rateLimit)
+	s.mu.Unlock()
+
+	st.Calls = len(latencies)
+	if st.Calls == 0 {
+		return st
+	}
+	st.ErrorRate = round3(float64(failed) / float64(st.Calls))
+	slices.Sort(latencies)
+	pct := func(p float64) float64 {
+		d := latencies[int(p*float64(len(latencies)-1))]
+		return round3(float64(d) / float64(time.Millisecond))
+	}
+	st.LatencyMs = LatencySummary{P50: pct(0.5), P90: pct(0.9), P99: pct(0.99)}
+	return st
+}
+
+// ProviderStatuses reports each provider's recent latency (until response
+// headers), error rate, health, breaker state and rate-limit headroom. Only
+// transcription and generateContent calls are counted; as with the health
+// trackers, 4xx answers other than 429 are not failures.
+func ProviderStatuses() []ProviderStatus {
+	dg := deepgramStats.status("deepgram")
+	if err := deepgramHealth.Check(); err != nil {
+		dg.Degraded = err.Error()
+	}
+	if deepgramBreaker != nil {
+		dg.Breaker = deepgramBreaker.State()
+	}
+	gem := geminiStats.status("gemini")
+	if err := geminiHealth.Check(); err != nil {
+		gem.Degraded = err.Error()
+	}
+	return []ProviderStatus{dg, gem}
+}
@@ -0,0 +1,62 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/breaker"
+)
+
+func TestCallStats_Status(t *testing.T) {
+	s := &callStats{}
+	if st := s.status("gemini"); st.Calls != 0 || st.LatencyMs.P50 != 0 || st.RateLimit != nil {
+		t.Fatalf("empty status = %+v", st)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		s.observe(ctx, now.Add(-time.Duration(i)*10*time.Millisecond), nil, i == 10)
+	}
+	resp := &http.Response{Header: http.Header{"X-Ratelimit-Remaining-Requests": {"42"}, "Content-Type": {"application/json"}}}
+	s.observe(ctx, now, resp, false)
+	s.observe(ctx, now, &http.Response{Header: http.Header{}}, false) // keeps the last headers seen
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	s.observe(cancelled, now, nil, true) // the caller's doing, not counted
+
+	st := s.status("gemini")
+	if st.Calls != 12 || st.ErrorRate != round3(1.0/12) {
+		t.Errorf("calls = %d, error rate = %v", st.Calls, st.ErrorRate)
+	}
+	if st.LatencyMs.P50 < 40 || st.LatencyMs.P99 < 90 || st.LatencyMs.P50 > st.LatencyMs.P90 {
+		t.Errorf("latency = %+v", st.LatencyMs)
+	}
+	if len(st.RateLimit) != 1 || st.RateLimit["x-ratelimit-remaining-requests"] != "42" {
+		t.Errorf("rate limit = %v", st.RateLimit)
+	}
+}
+
+func TestProviderStatuses_Deepgram(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	old, oldStats := deepgramBaseURL, deepgramStats
+	deepgramBaseURL, deepgramStats = server.URL, &callStats{}
+	defer func() { deepgramBaseURL, deepgramStats = old, oldStats }()
+	SetDeepgramBreaker(breaker.New(1, time.Minute))
+	defer SetDeepgramBreaker(nil)
+
+	callDeepgram(context.Background(), server.URL+"/v1/listen", strings.NewReader("video"), 5, "key")
+
+	st := ProviderStatuses()[0]
+	if st.Provider != "deepgram" || st.Calls != 1 || st.ErrorRate != 1 || st.Breaker != breaker.StateOpen {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,119 @@
+package streams
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Continuity context strategies for VLM prompts.
+const (
+	ContextPreviousFrame  = "previous_frame"  // the last description, verbatim
+	ContextRollingSummary = "rolling_summary" // a condensed story so far plus the last description
+)
+
+// defaultSummaryEvery is how many descriptions accumulate before the rolling
+// summary is condensed, when VLMOptions.SummaryEvery is unset.
+const defaultSummaryEvery = 5
+
+// maxStoryChars caps the rolling summary when it has to be trimmed locally
+// because condensing it through Gemini failed.
+const maxStoryChars = 600
+
+const storySummaryPromptTemplate = `Below is a summary of a video advertisement so far, followed by descriptions of the frames that came next.
+Summary so far: %s
+
+Next frames:
+%s
+
+Rewrite the summary to cover everything above in at most 60 words: who and what is on screen, the product, the setting and how the story has progressed. Reply with the summary only.`
+
+// storyContext produces the continuity text for each VLM prompt.
+type storyContext struct {
+	apiKey  string
+	rolling bool
+	every   int
+	timeout time.Duration
+
+	summary string   // condensed story before pending
+	pending []string // descriptions not yet folded into summary
+	last    string
+}
+
+func newStoryContext(apiKey string, opts VLMOptions) *storyContext {
+	return &storyContext{
+		apiKey:  apiKey,
+		rolling: opts.Context == ContextRollingSummary,
+		every:   summaryEvery(opts),
+		timeout: opts.FrameTimeout,
+	}
+}
+
+func summaryEvery(opts VLMOptions) int {
+	if opts.SummaryEvery > 0 {
+		return opts.SummaryEvery
+	}
+	return defaultSummaryEvery
+}
+
+// String is the context for the next prompt.
+func (s *storyContext) String() string {
+	switch {
+	case s.last == "":
+		return "This is the first frame of the ad."
+	case !s.rolling || s.summary == "":
+		return s.last
+	default:
+		return fmt.Sprintf("Story so far: %s\nPrevious frame: %s", s.summary, s.last)
+	}
+}
+
+// add records a new description. In rolling mode every s.every descriptions
+// are folded into the summary, so prompts stay the same size however long
+// the ad is.
+func (s *storyContext) add(ctx context.Context, desc string) {
+	s.last = desc
+	if !s.rolling {
+		return
+	}
+	s.pending = append(s.pending, desc)
+	if len(s.pending) >= s.every {
+		s.condense(ctx)
+	}
+}
+
+func (s *storyContext) condense(ctx context.Context) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	prompt := fmt.Sprintf(storySummaryPromptTemplate,
+		cmp.Or(s.summary, "(none yet)"), "- "+strings.Join(s.pending, "\n- "))
+	summary, err := callGeminiText(ctx, s.apiKey, prompt)
+	if err != nil || summary == "" {
+		// Fall back to the first sentence of each description, dropping the
+		// oldest text once over budget.
+		parts := []string{s.summary}
+		for _, d := range s.pending {
+			parts = append(parts, firstSentence(d))
+		}
+		summary = strings.TrimSpace(strings.Join(parts, " "))
+		if r := []rune(summary); len(r) > maxStoryChars {
+			summary = "…" + string(r[len(r)-maxStoryChars:])
+		}
+	}
+	s.summary = summary
+	s.pending = s.pending[:0]
+}
+
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, ".!?"); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunVLM_RollingSummary(t *testing.T) {
+	var prompts []string
+	frames, summaries := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		parts := req.Contents[0].Parts
+
+		var text string
+		if len(parts) == 1 {
+			summaries++
+			text = fmt.Sprintf("summary %d", summaries)
+		} else {
+			prompts = append(prompts, parts[0].Text)
+			text = fmt.Sprintf("Frame %d shows a kitchen. More detail follows.", frames)
+			frames++
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": text}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	var keyframes []KeyframeInput
+	for i := range 5 {
+		keyframes = append(keyframes, KeyframeInput{FrameIndex: i, TimestampSec: float64(i), ImageBytes: []byte("img")})
+	}
+
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{Context: ContextRollingSummary, SummaryEvery: 2})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if len(result.Frames) != 5 {
+		t.Fatalf("expected 5 frames, got %d", len(result.Frames))
+	}
+	if summaries != 2 {
+		t.Errorf("summary calls = %d, want 2", summaries)
+	}
+	if est := VLMCalls(5, VLMOptions{Context: ContextRollingSummary, SummaryEvery: 2}, false); est != frames+summaries {
+		t.Errorf("VLMCalls = %d, made %d", est, frames+summaries)
+	}
+	if strings.Contains(prompts[1], "Story so far") {
+		t.Error("second prompt should carry only the previous frame before any summary exists")
+	}
+	if !strings.Contains(prompts[4], "Story so far: summary 2\nPrevious frame: Frame 3 shows") {
+		t.Errorf("fifth prompt lacks rolling context: %s", prompts[4])
+	}
+	if p := result.Provenance.Params; p["context"] != ContextRollingSummary || p["summary_every"] != "2" {
+		t.Errorf("provenance params = %v", p)
+	}
+}
+
+func TestStoryContext_FallsBackToTrimming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	story := newStoryContext("key", VLMOptions{Context: ContextRollingSummary, SummaryEvery: 2})
+	long := strings.Repeat("word ", 200)
+	for range 30 {
+		story.add(context.Background(), "A woman opens the fridge. "+long)
+	}
+	if !strings.HasPrefix(story.summary, "…") || len([]rune(story.summary)) != maxStoryChars+1 {
+		t.Errorf("summary not trimmed: %d runes", len([]rune(story.summary)))
+	}
+	if strings.Contains(story.summary, "word") {
+		t.Error("fallback should keep only first sentences")
+	}
+}
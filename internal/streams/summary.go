@@ -0,0 +1,76 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+)
+
+// SummaryResult holds the ad summaries written to summary.json.
+type SummaryResult struct {
+	Combined   string      `json:"combined"`
+	SoundOff   string      `json:"sound_off"`   // visual channel only
+	EyesClosed string      `json:"eyes_closed"` // audio channel only
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+const (
+	noSpeechSummary = "No speech detected; nothing is conveyed with eyes closed."
+	noVisualSummary = "No visual descriptions available."
+)
+
+const summaryPromptTemplate = `Below is %s of a video advertisement (%.1fs long).
+
+%s
+%s
+
+Write a 3-4 sentence summary. State the product, the core message, and any offer
+or call to action. Only use information present above; if something cannot be
+determined from it, say so plainly.`
+
+// RunSummaries produces a combined summary plus a "sound off" summary built
+// from visual entries only and an "eyes closed" summary built from speech
+// only. Each summary sees nothing but its own channel, so the restricted
+// summaries reflect what a viewer would actually take away.
+func RunSummaries(ctx context.Context, tl *Timeline, apiKey string) (*SummaryResult, error) {
+	result := &SummaryResult{
+		Provenance: geminiProvenance(summaryPromptVersion, nil),
+	}
+
+	visual := tl.Filter("visual")
+	speech := tl.Filter("speech")
+
+	var err error
+	result.Combined, err = summarize(ctx, apiKey, tl,
+		"the merged timeline (SPEECH is the transcript, VISUAL describes keyframes)",
+		"Summarize the ad as a viewer with sound on would experience it.")
+	if err != nil {
+		return nil, fmt.Errorf("combined summary: %w", err)
+	}
+
+	result.SoundOff = noVisualSummary
+	if len(visual.Entries) > 0 {
+		result.SoundOff, err = summarize(ctx, apiKey, visual,
+			"the visual-only timeline",
+			"Summarize what a viewer would understand watching muted in a feed, with no audio at all.")
+		if err != nil {
+			return nil, fmt.Errorf("sound-off summary: %w", err)
+		}
+	}
+
+	result.EyesClosed = noSpeechSummary
+	if len(speech.Entries) > 0 {
+		result.EyesClosed, err = summarize(ctx, apiKey, speech,
+			"the transcript",
+			"Summarize what a listener would understand from the audio alone, without seeing the screen.")
+		if err != nil {
+			return nil, fmt.Errorf("eyes-closed summary: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+func summarize(ctx context.Context, apiKey string, tl *Timeline, what, instruction string) (string, error) {
+	prompt := fmt.Sprintf(summaryPromptTemplate, what, tl.DurationSec, tl.Render(), instruction)
+	return callGeminiText(ctx, apiKey, prompt)
+}
@@ -0,0 +1,92 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunSummaries_ChannelIsolation(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Contents[0].Parts[0].Text
+		prompts = append(prompts, prompt)
+
+		answer := "combined"
+		switch {
+		case strings.Contains(prompt, "muted"):
+			answer = "sound off"
+		case strings.Contains(prompt, "audio alone"):
+			answer = "eyes closed"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": answer}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	tl := BuildTimeline(
+		&ASRResult{Segments: []ASRSegment{{Start: 1, End: 2, Text: "Use code SAVE20"}}},
+		&VLMResult{Frames: []VLMFrame{{FrameIndex: 0, TimestampSec: 0, Description: "A sneaker spins."}}},
+	)
+
+	result, err := RunSummaries(context.Background(), tl, "key")
+	if err != nil {
+		t.Fatalf("RunSummaries error: %v", err)
+	}
+	if result.Combined != "combined" || result.SoundOff != "sound off" || result.EyesClosed != "eyes closed" {
+		t.Errorf("result = %+v", result)
+	}
+	if len(prompts) != 3 {
+		t.Fatalf("expected 3 calls, got %d", len(prompts))
+	}
+	for _, p := range prompts {
+		if strings.Contains(p, "muted") && strings.Contains(p, "SAVE20") {
+			t.Error("sound-off prompt must not include speech")
+		}
+		if strings.Contains(p, "audio alone") && strings.Contains(p, "sneaker") {
+			t.Error("eyes-closed prompt must not include visuals")
+		}
+	}
+}
+
+func TestRunSummaries_NoSpeech(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": "summary"}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	tl := BuildTimeline(nil, &VLMResult{Frames: []VLMFrame{{TimestampSec: 0, Description: "Logo."}}})
+
+	result, err := RunSummaries(context.Background(), tl, "key")
+	if err != nil {
+		t.Fatalf("RunSummaries error: %v", err)
+	}
+	if result.EyesClosed != noSpeechSummary {
+		t.Errorf("eyes_closed = %q", result.EyesClosed)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls (no eyes-closed call), got %d", calls)
+	}
+}
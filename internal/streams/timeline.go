@@ -0,0 +1,89 @@
+package streams
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Timeline merges ASR segments and VLM frame descriptions into a single
+// time-ordered sequence.
+type Timeline struct {
+	DurationSec float64         `json:"duration_sec"`
+	Entries     []TimelineEntry `json:"entries"`
+}
+
+type TimelineEntry struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Source     string  `json:"source"` // "speech" | "visual"
+	FrameIndex *int    `json:"frame_index,omitempty"`
+	Text       string  `json:"text"`
+}
+
+// BuildTimeline merges the two stream outputs. Either argument may be nil.
+// A frame's description is considered to hold until the next frame.
+func BuildTimeline(asr *ASRResult, vlm *VLMResult) *Timeline {
+	tl := &Timeline{}
+
+	if asr != nil {
+		for _, s := range asr.Segments {
+			tl.Entries = append(tl.Entries, TimelineEntry{
+				Start:  s.Start,
+				End:    s.End,
+				Source: "speech",
+				Text:   s.Text,
+			})
+			tl.DurationSec = max(tl.DurationSec, s.End)
+		}
+	}
+
+	if vlm != nil {
+		for i, f := range vlm.Frames {
+			if strings.HasPrefix(f.Description, "[Error:") {
+				continue
+			}
+			end := f.TimestampSec
+			if i+1 < len(vlm.Frames) {
+				end = vlm.Frames[i+1].TimestampSec
+			}
+			idx := f.FrameIndex
+			tl.Entries = append(tl.Entries, TimelineEntry{
+				Start:      f.TimestampSec,
+				End:        end,
+				Source:     "visual",
+				FrameIndex: &idx,
+				Text:       f.Description,
+			})
+			tl.DurationSec = max(tl.DurationSec, end)
+		}
+	}
+
+	tl.normalize()
+	return tl
+}
+
+// Filter returns a timeline containing only entries from the given source.
+// DurationSec is kept so prompts still know the full ad length.
+func (tl *Timeline) Filter(source string) *Timeline {
+	out := &Timeline{DurationSec: tl.DurationSec}
+	for _, e := range tl.Entries {
+		if e.Source == source {
+			out.Entries = append(out.Entries, e)
+		}
+	}
+	return out
+}
+
+// Render formats the timeline as plain text lines for use in LLM prompts.
+func (tl *Timeline) Render() string {
+	var b strings.Builder
+	for _, e := range tl.Entries {
+		switch e.Source {
+		case "speech":
+			fmt.Fprintf(&b, "[%.1fs-%.1fs] SPEECH: %s\n", e.Start, e.End, e.Text)
+		default:
+			fmt.Fprintf(&b, "[%.1fs] VISUAL: %s\n", e.Start, e.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTimeline_MergesAndSorts(t *testing.T) {
+	asr := &ASRResult{Segments: []ASRSegment{
+		{Start: 0.5, End: 2.0, Text: "Tired of dull skin?"},
+		{Start: 6.0, End: 8.5, Text: "Shop now"},
+	}}
+	vlm := &VLMResult{Frames: []VLMFrame{
+		{FrameIndex: 0, TimestampSec: 0.0, Description: "A woman looks in a mirror."},
+		{FrameIndex: 3, TimestampSec: 3.0, Description: "[Error: gemini returned 500: x]"},
+		{FrameIndex: 7, TimestampSec: 5.0, Description: "Close-up of the serum bottle."},
+	}}
+
+	tl := BuildTimeline(asr, vlm)
+
+	if len(tl.Entries) != 4 {
+		t.Fatalf("expected 4 entries (error frame dropped), got %d", len(tl.Entries))
+	}
+	wantSources := []string{"visual", "speech", "visual", "speech"}
+	for i, want := range wantSources {
+		if tl.Entries[i].Source != want {
+			t.Errorf("entry %d source = %q, want %q", i, tl.Entries[i].Source, want)
+		}
+	}
+	// First frame holds until the next frame (including the errored one)
+	if tl.Entries[0].End != 3.0 {
+		t.Errorf("entry 0 end = %.1f, want 3.0", tl.Entries[0].End)
+	}
+	if tl.Entries[2].FrameIndex == nil || *tl.Entries[2].FrameIndex != 7 {
+		t.Errorf("entry 2 frame index = %v, want 7", tl.Entries[2].FrameIndex)
+	}
+	if tl.DurationSec != 8.5 {
+		t.Errorf("duration = %.1f, want 8.5", tl.DurationSec)
+	}
+}
+
+func TestBuildTimeline_NilInputs(t *testing.T) {
+	tl := BuildTimeline(nil, nil)
+	if len(tl.Entries) != 0 || tl.DurationSec != 0 {
+		t.Errorf("expected empty timeline, got %+v", tl)
+	}
+}
+
+func TestTimeline_Render(t *testing.T) {
+	tl := BuildTimeline(
+		&ASRResult{Segments: []ASRSegment{{Start: 1, End: 2, Text: "Hi"}}},
+		&VLMResult{Frames: []VLMFrame{{FrameIndex: 0, TimestampSec: 0, Description: "Logo."}}},
+	)
+	got := tl.Render()
+	want := "[0.0s] VISUAL: Logo.\n[1.0s-2.0s] SPEECH: Hi\n"
+	if got != want {
+		t.Errorf("render = %q, want %q", got, want)
+	}
+	if !strings.HasSuffix(got, "\n") {
+		t.Error("render should end with newline")
+	}
+}
@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VertexScope is the OAuth2 scope Vertex AI tokens need.
+const VertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// geminiVertex, when set, sends Gemini requests through Vertex AI: to a
+// regional aiplatform endpoint, authenticated with OAuth2 access tokens
+// rather than an API key.
+var geminiVertex *vertexEndpoint
+
+type vertexEndpoint struct {
+	root   string // e.g. https://europe-west4-aiplatform.googleapis.com/v1beta1
+	parent string // projects/{project}/locations/{location}
+	token  func(context.Context) (string, error)
+}
+
+// SetGeminiVertex routes Gemini through Vertex AI in project and location
+// (a region such as "europe-west4", or "global"), with access tokens from
+// token (e.g. gcpauth.TokenSource's). An empty project goes back to the
+// API-key endpoint.
+func SetGeminiVertex(project, location string, token func(context.Context) (string, error)) {
+	if project == "" {
+		geminiVertex = nil
+		return
+	}
+	host := location + "-aiplatform.googleapis.com"
+	if location == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	geminiVertex = &vertexEndpoint{
+		root:   "https://" + host + "/v1beta1",
+		parent: fmt.Sprintf("projects/%s/locations/%s", project, location),
+		token:  token,
+	}
+}
+
+// geminiURL is the address of a Gemini API resource: a model method such
+// as "models/gemini-2.0-flash:generateContent", a collection such as
+// "cachedContents", or a resource name the API returned, optionally
+// followed by a query. On the API-key endpoint the key is added to the
+// query; Vertex AI requests carry a token instead (see authorizeGemini),
+// and models are Google's publisher models in the configured location.
+func geminiURL(apiKey, resource string) string {
+	v := geminiVertex
+	if v == nil {
+		sep := "?"
+		if strings.Contains(resource, "?") {
+			sep = "&"
+		}
+		return geminiBaseURL + "/v1beta/" + resource + sep + "key=" + apiKey
+	}
+	if strings.HasPrefix(resource, "projects/") {
+		return v.root + "/" + resource
+	}
+	if strings.HasPrefix(resource, "models/") {
+		resource = "publishers/google/" + resource
+	}
+	return v.root + "/" + v.parent + "/" + resource
+}
+
+// geminiModelName is the resource name of the model calls under ctx use,
+// as request bodies refer to it.
+func geminiModelName(ctx context.Context) string {
+	if v := geminiVertex; v != nil {
+		return v.parent + "/publishers/google/models/" + geminiModelFor(ctx)
+	}
+	return "models/" + geminiModelFor(ctx)
+}
+
+// authorizeGemini adds a Vertex AI access token to req. Requests to the
+// API-key endpoint carry the key in their URL and are left alone.
+func authorizeGemini(req *http.Request) error {
+	v := geminiVertex
+	if v == nil {
+		return nil
+	}
+	token, err := v.token(req.Context())
+	if err != nil {
+		return fmt.Errorf("vertex ai token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
@@ -0,0 +1,80 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withVertex(t *testing.T, root string, token func(context.Context) (string, error)) {
+	t.Helper()
+	old := geminiVertex
+	geminiVertex = &vertexEndpoint{root: root, parent: "projects/p1/locations/europe-west4", token: token}
+	t.Cleanup(func() { geminiVertex = old })
+}
+
+func TestSetGeminiVertex(t *testing.T) {
+	old := geminiVertex
+	defer func() { geminiVertex = old }()
+
+	SetGeminiVertex("p1", "europe-west4", nil)
+	if got, want := geminiURL("", "models/m:generateContent"), "https://europe-west4-aiplatform.googleapis.com/v1beta1/projects/p1/locations/europe-west4/publishers/google/models/m:generateContent"; got != want {
+		t.Errorf("regional url = %s, want %s", got, want)
+	}
+	if got, want := geminiURL("", "projects/p1/locations/europe-west4/cachedContents/c1?updateMask=ttl"), "https://europe-west4-aiplatform.googleapis.com/v1beta1/projects/p1/locations/europe-west4/cachedContents/c1?updateMask=ttl"; got != want {
+		t.Errorf("resource url = %s, want %s", got, want)
+	}
+
+	SetGeminiVertex("p1", "global", nil)
+	if got := geminiURL("", "cachedContents"); got != "https://aiplatform.googleapis.com/v1beta1/projects/p1/locations/global/cachedContents" {
+		t.Errorf("global url = %s", got)
+	}
+
+	SetGeminiVertex("", "", nil)
+	if got := geminiURL("k", "cachedContents/c1?updateMask=ttl"); got != geminiBaseURL+"/v1beta/cachedContents/c1?updateMask=ttl&key=k" {
+		t.Errorf("api key url = %s", got)
+	}
+}
+
+func TestGenerateContent_Vertex(t *testing.T) {
+	var path, auth, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, query = r.URL.Path, r.Header.Get("Authorization"), r.URL.RawQuery
+		w.Write(mockGeminiResponse("ok"))
+	}))
+	defer server.Close()
+	withVertex(t, server.URL+"/v1beta1", func(context.Context) (string, error) { return "tok", nil })
+
+	text, err := generateContent(context.Background(), "", geminiRequest{Contents: []geminiContent{{Parts: []geminiPart{{Text: "hi"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ok" {
+		t.Errorf("text = %q", text)
+	}
+	if want := "/v1beta1/projects/p1/locations/europe-west4/publishers/google/models/" + geminiModel + ":generateContent"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if auth != "Bearer tok" || query != "" {
+		t.Errorf("authorization = %q, query = %q", auth, query)
+	}
+	if got := geminiModelName(context.Background()); !strings.HasPrefix(got, "projects/p1/locations/europe-west4/publishers/google/models/") {
+		t.Errorf("model name = %s", got)
+	}
+}
+
+func TestGenerateContent_VertexTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent without a token")
+	}))
+	defer server.Close()
+	withVertex(t, server.URL+"/v1beta1", func(context.Context) (string, error) { return "", errors.New("no credentials") })
+
+	_, err := generateContent(context.Background(), "", geminiRequest{Contents: []geminiContent{{Parts: []geminiPart{{Text: "hi"}}}}})
+	if err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("err = %v", err)
+	}
+}
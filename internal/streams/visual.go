@@ -0,0 +1,187 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"sort"
+)
+
+// VisualStatsResult is the output of the visual_stats stream: each
+// keyframe's palette, brightness and contrast, and the same for the ad as a
+// whole, computed locally from the keyframe images.
+type VisualStatsResult struct {
+	Frames []VisualFrame `json:"frames"`
+
+	// Palette, Brightness and Contrast cover the whole ad, each analyzed
+	// keyframe weighing the same
+	Palette    []Swatch `json:"palette"`
+	Brightness float64  `json:"brightness"`
+	Contrast   float64  `json:"contrast"`
+
+	Incomplete bool        `json:"incomplete,omitempty"` // the job ended before every frame was analyzed
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+type VisualFrame struct {
+	FrameIndex   int      `json:"frame_index"`
+	TimestampSec float64  `json:"timestamp_sec"`
+	Palette      []Swatch `json:"palette"`
+	Brightness   float64  `json:"brightness"` // mean luma, 0 (black) to 1 (white)
+	Contrast     float64  `json:"contrast"`   // RMS contrast: luma standard deviation, 0 to 0.5
+	Error        string   `json:"error,omitempty"`
+}
+
+// Swatch is one dominant color and the share of pixels near it.
+type Swatch struct {
+	Hex   string  `json:"hex"`
+	Share float64 `json:"share"`
+}
+
+const (
+	paletteSize = 5
+
+	// paletteBits is the bits kept per RGB channel when grouping pixels into
+	// colors: 4 gives 4096 buckets, coarse enough that a brand color's
+	// compression noise lands in one of them
+	paletteBits = 4
+
+	// visualSamplePixels caps the pixels read per frame; larger images are
+	// sampled on a grid
+	visualSamplePixels = 65536
+)
+
+// colorBuckets counts pixels by quantized color. Each bucket keeps the sum
+// of its pixels' exact colors so a swatch is their mean rather than the
+// bucket's corner.
+type colorBuckets struct {
+	n, r, g, b [1 << (3 * paletteBits)]float64
+	total      float64
+}
+
+func (c *colorBuckets) add(r, g, b uint8) {
+	const shift = 8 - paletteBits
+	i := int(r>>shift)<<(2*paletteBits) | int(g>>shift)<<paletteBits | int(b>>shift)
+	c.n[i]++
+	c.r[i] += float64(r)
+	c.g[i] += float64(g)
+	c.b[i] += float64(b)
+	c.total++
+}
+
+// merge adds o's pixels to c, scaled so that o weighs the same as any other
+// frame whatever its size.
+func (c *colorBuckets) merge(o *colorBuckets) {
+	if o.total == 0 {
+		return
+	}
+	weight := 1 / o.total
+	c.total++
+	for i := range c.n {
+		c.n[i] += o.n[i] * weight
+		c.r[i] += o.r[i] * weight
+		c.g[i] += o.g[i] * weight
+		c.b[i] += o.b[i] * weight
+	}
+}
+
+// palette returns the paletteSize fullest buckets, largest first.
+func (c *colorBuckets) palette() []Swatch {
+	idx := make([]int, 0, len(c.n))
+	for i, n := range c.n {
+		if n > 0 {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return c.n[idx[a]] > c.n[idx[b]] })
+	swatches := []Swatch{}
+	for _, i := range idx[:min(len(idx), paletteSize)] {
+		n := c.n[i]
+		swatches = append(swatches, Swatch{
+			Hex:   fmt.Sprintf("#%02x%02x%02x", uint8(math.Round(c.r[i]/n)), uint8(math.Round(c.g[i]/n)), uint8(math.Round(c.b[i]/n))),
+			Share: round3(n / c.total),
+		})
+	}
+	return swatches
+}
+
+// RunVisualStats measures each keyframe's dominant colors, brightness and
+// contrast. It makes no provider calls; frames that fail to load or decode
+// are reported with an error and left out of the ad's figures.
+func RunVisualStats(ctx context.Context, keyframes []KeyframeInput) (*VisualStatsResult, error) {
+	result := &VisualStatsResult{
+		Frames:     []VisualFrame{},
+		Palette:    []Swatch{},
+		Provenance: &Provenance{Provider: "local", Model: "rgb-histogram"},
+	}
+	var ad colorBuckets
+	var analyzed int
+	for lf := range prefetchFrames(ctx, keyframes) {
+		if ctx.Err() != nil {
+			lf.release()
+			break
+		}
+		f := VisualFrame{FrameIndex: lf.kf.FrameIndex, TimestampSec: round3(lf.kf.TimestampSec), Palette: []Swatch{}}
+		err := lf.err
+		if err == nil {
+			var buckets colorBuckets
+			f.Brightness, f.Contrast, err = measureImage(lf.img, &buckets)
+			if err == nil {
+				f.Palette = buckets.palette()
+				ad.merge(&buckets)
+				result.Brightness += f.Brightness
+				result.Contrast += f.Contrast
+				analyzed++
+			}
+		}
+		if err != nil {
+			f.Error = err.Error()
+		}
+		lf.release()
+		result.Frames = append(result.Frames, f)
+	}
+	if analyzed > 0 {
+		result.Palette = ad.palette()
+		result.Brightness = round3(result.Brightness / float64(analyzed))
+		result.Contrast = round3(result.Contrast / float64(analyzed))
+	}
+	result.Incomplete = len(result.Frames) < len(keyframes)
+	return result, nil
+}
+
+// measureImage decodes img and returns its mean luma and RMS contrast, both
+// on a 0-1 scale, adding its pixels to buckets.
+func measureImage(img []byte, buckets *colorBuckets) (brightness, contrast float64, err error) {
+	m, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return 0, 0, fmt.Errorf("decode keyframe: %w", err)
+	}
+	b := m.Bounds()
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > visualSamplePixels {
+		step++
+	}
+
+	var sum, sumSq float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+			buckets.add(c.R, c.G, c.B)
+			// ITU-R BT.601 luma
+			l := (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+			sum += l
+			sumSq += l * l
+		}
+	}
+	if buckets.total == 0 {
+		return 0, 0, fmt.Errorf("decode keyframe: empty image")
+	}
+	n := buckets.total
+	mean := sum / n
+	return round3(mean), round3(math.Sqrt(max(sumSq/n-mean*mean, 0))), nil
+}
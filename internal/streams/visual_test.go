@@ -0,0 +1,78 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// testImage encodes a w×h PNG painted by fill.
+func testImage(t *testing.T, w, h int, fill func(x, y int) color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRunVisualStats(t *testing.T) {
+	red := color.RGBA{200, 16, 32, 255}
+	split := testImage(t, 40, 10, func(x, y int) color.Color {
+		if x < 30 {
+			return red
+		}
+		return color.White
+	})
+	// a larger frame, sampled on a grid, still weighs as much as the first
+	black := testImage(t, 400, 400, func(x, y int) color.Color { return color.Black })
+
+	res, err := RunVisualStats(context.Background(), []KeyframeInput{
+		{FrameIndex: 0, TimestampSec: 0, ImageBytes: split},
+		{FrameIndex: 1, TimestampSec: 1.5, ImageBytes: []byte("not an image")},
+		{FrameIndex: 2, TimestampSec: 3, ImageBytes: black},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frames) != 3 || res.Incomplete {
+		t.Fatalf("result = %+v", res)
+	}
+
+	first := res.Frames[0]
+	if len(first.Palette) != 2 || first.Palette[0] != (Swatch{"#c81020", 0.75}) || first.Palette[1] != (Swatch{"#ffffff", 0.25}) {
+		t.Errorf("frame 0 palette = %+v", first.Palette)
+	}
+	// luma: red 0.299*200+0.587*16+0.114*32 = 72.65/255 = 0.285, white 1
+	if first.Brightness != 0.464 || first.Contrast != 0.309 {
+		t.Errorf("frame 0 brightness %v contrast %v", first.Brightness, first.Contrast)
+	}
+	if res.Frames[1].Error == "" {
+		t.Error("undecodable frame has no error")
+	}
+	if f := res.Frames[2]; f.Brightness != 0 || f.Contrast != 0 || f.Palette[0] != (Swatch{"#000000", 1}) {
+		t.Errorf("frame 2 = %+v", f)
+	}
+
+	want := []Swatch{{"#000000", 0.5}, {"#c81020", 0.375}, {"#ffffff", 0.125}}
+	if len(res.Palette) != len(want) {
+		t.Fatalf("ad palette = %+v", res.Palette)
+	}
+	for i, s := range res.Palette {
+		if s != want[i] {
+			t.Errorf("ad swatch %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+	if res.Brightness != 0.232 || res.Contrast != 0.155 {
+		t.Errorf("ad brightness %v contrast %v", res.Brightness, res.Contrast)
+	}
+}
@@ -2,31 +2,53 @@ package streams
 
 import (
 	"bytes"
+	"cmp"
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/bufpool"
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
 )
 
 // VLMResult is the output of the Gemini VLM description stream.
 type VLMResult struct {
-	Frames []VLMFrame `json:"frames"`
+	SchemaVersion int            `json:"schema_version"`
+	Frames        []VLMFrame     `json:"frames"`
+	SkippedFrames []SkippedFrame `json:"skipped_frames,omitempty"` // left out by the frame cap or token budget
+	Incomplete    bool           `json:"incomplete,omitempty"`     // the job ended before every frame was described
+	Provenance    *Provenance    `json:"provenance,omitempty"`
 }
 
 type VLMFrame struct {
 	FrameIndex   int     `json:"frame_index"`
 	TimestampSec float64 `json:"timestamp_sec"`
 	Description  string  `json:"description"`
+	Blocked      bool    `json:"blocked,omitempty"` // refused by Gemini safety filters
 }
 
-const vlmPromptTemplate = `Analyze this frame from a video advertisement.
+// ErrBlocked is returned when Gemini refuses to answer because of its
+// safety filters.
+var ErrBlocked = errors.New("blocked by gemini safety filters")
+
+var errFrameTimeout = errors.New("gemini request exceeded frame timeout")
+
+// VLM prompts are a per-request header followed by instructions that are
+// the same for every request of a run, which can be cached.
+const (
+	vlmFrameHeader = `Analyze this frame from a video advertisement.
 Previous frame context: %s
-Timestamp: %.1fs
+Timestamp: %.1fs`
 
-Describe in 2-3 sentences covering:
+	vlmInstructions = `Describe in 2-3 sentences covering:
 1. What is happening visually (people, product, setting, action)
 2. Camera movement and shot type (close-up, wide shot, zoom in, pan, cut, handheld shake, tracking)
 3. Emotional tone, color palette, pacing feel
@@ -34,43 +56,435 @@ Describe in 2-3 sentences covering:
 
 Be specific and concrete. Use explicit motion vocabulary: cut, zoom, pan, handheld, slow motion, fast cut, tracking shot, static shot, dolly, whip pan.`
 
-// KeyframeInput represents a keyframe with its metadata and image bytes.
+	vlmPromptTemplate = vlmFrameHeader + "\n\n" + vlmInstructions
+
+	vlmBatchHeader = `Analyze these %d consecutive frames from a video advertisement. Each image is preceded by its frame index and timestamp.
+Context before these frames: %s`
+
+	vlmBatchInstructions = `For each frame, describe in 2-3 sentences covering:
+1. What is happening visually (people, product, setting, action)
+2. Camera movement and shot type (close-up, wide shot, zoom in, pan, cut, handheld shake, tracking)
+3. Emotional tone, color palette, pacing feel
+4. Any motion blur, fast cuts, slow motion, or speed ramp effects
+
+Be specific and concrete. Use explicit motion vocabulary: cut, zoom, pan, handheld, slow motion, fast cut, tracking shot, static shot, dolly, whip pan.
+
+Respond with a JSON array holding one object per frame, in the order given:
+[{"frame_index": <int>, "description": "<text>"}]`
+
+	vlmBatchPromptTemplate = vlmBatchHeader + "\n\n" + vlmBatchInstructions
+)
+
+// maxPromptTemplate bounds a custom VLM prompt template, in bytes.
+const maxPromptTemplate = 8 << 10
+
+// ValidatePromptTemplate checks a custom VLM prompt template: its only
+// formatting verbs, besides %%, are the previous frame's context as a
+// string (%s, %q or %v) followed by the frame's timestamp in seconds as a
+// float (%f, %g, %e or %v, with optional flags, width and precision).
+func ValidatePromptTemplate(t string) error {
+	if strings.TrimSpace(t) == "" {
+		return errors.New("prompt template is empty")
+	}
+	if len(t) > maxPromptTemplate {
+		return fmt.Errorf("prompt template is longer than %d bytes", maxPromptTemplate)
+	}
+	var verbs []rune
+	for i := 0; i < len(t); i++ {
+		if t[i] != '%' {
+			continue
+		}
+		// flags, width and precision, then the verb
+		j := i + 1
+		for j < len(t) && strings.IndexByte("+-# 0123456789.", t[j]) >= 0 {
+			j++
+		}
+		if j == len(t) {
+			return errors.New("prompt template ends in the middle of a verb")
+		}
+		if t[j] == '%' && j == i+1 {
+			i = j
+			continue
+		}
+		verbs = append(verbs, rune(t[j]))
+		i = j
+	}
+	if len(verbs) != 2 || !strings.ContainsRune("sqv", verbs[0]) || !strings.ContainsRune("fgev", verbs[1]) {
+		return fmt.Errorf("prompt template must have exactly two verbs, the previous frame's context (%%s) and the timestamp (%%.1f), in that order; it has %q", string(verbs))
+	}
+	return nil
+}
+
+// customPromptVersion identifies a custom prompt template in provenance:
+// "custom-" and the start of its SHA-256.
+func customPromptVersion(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return "custom-" + hex.EncodeToString(sum[:6])
+}
+
+// vlmLanguageInstruction is added to VLM prompts for ads in a language
+// other than English, so that their on-screen copy is kept.
+const vlmLanguageInstruction = `The ad's language is %q (a BCP 47 code). Quote any visible text (captions, titles, packaging, signs) in its original language, followed by an English translation in brackets, and note cultural references a viewer outside that market might miss.`
+
+// VLMOptions tunes a VLM run. The zero value describes one frame per request.
+type VLMOptions struct {
+	// BatchSize sends up to this many consecutive keyframes in one Gemini
+	// request. Values <= 1 disable batching.
+	BatchSize int
+
+	// MaxFrames caps how many keyframes are described (0 = no cap). Frames
+	// over the cap are chosen by Selection and listed in SkippedFrames.
+	MaxFrames int
+	Selection string // SelectByEntropy (default) or SelectEvenly
+
+	// FrameTimeout bounds each Gemini request, retries included, so one hung
+	// call cannot use up the rest of the job's deadline (0 = no limit).
+	FrameTimeout time.Duration
+
+	// Context is the continuity given to each prompt: ContextPreviousFrame
+	// (default) or ContextRollingSummary, which condenses the descriptions
+	// into a short story every SummaryEvery frames (default 5).
+	Context      string
+	SummaryEvery int
+
+	// Language is the ad's detected language as a BCP 47 code. Unless it is
+	// empty or English, prompts ask for visible text to be transcribed and
+	// translated.
+	Language string
+
+	// PromptTemplate, if set, replaces the prompt each frame is described
+	// with; see ValidatePromptTemplate. Frames are then described one per
+	// request, whatever BatchSize says.
+	PromptTemplate string
+
+	// Model, if set, is the Gemini model frames are described with instead
+	// of the default; see ValidateGeminiModel.
+	Model string
+}
+
+// KeyframeInput represents a keyframe with its metadata and image source.
+// Either ImageBytes is set up front or Fetch loads the JPEG on demand, which
+// keeps memory per job bounded regardless of frame count. FetchInto, when
+// set, is preferred over Fetch: it loads the JPEG into a pooled buffer that
+// is reused once the frame has been described.
 type KeyframeInput struct {
 	FrameIndex   int
 	TimestampSec float64
+	EntropyScore float64
 	ImageBytes   []byte // JPEG bytes
+	Fetch        func(ctx context.Context) ([]byte, error)
+	FetchInto    func(ctx context.Context, buf *bytes.Buffer) error
+}
+
+// vlmPrefetch is how many frames are fetched ahead of the one being described.
+const vlmPrefetch = 2
+
+type loadedFrame struct {
+	kf  KeyframeInput
+	img []byte
+	buf *bytes.Buffer // backs img when loaded by FetchInto
+	err error
+}
+
+// release returns the frame's pooled buffer, if any. img must not be used
+// afterwards.
+func (lf *loadedFrame) release() {
+	bufpool.Put(lf.buf)
+	lf.buf, lf.img = nil, nil
+}
+
+// prefetchFrames loads keyframe images in order, at most vlmPrefetch ahead of
+// the consumer. Every keyframe is delivered, with err set if its fetch failed,
+// until ctx ends; the consumer may stop reading once it has.
+func prefetchFrames(ctx context.Context, keyframes []KeyframeInput) <-chan loadedFrame {
+	ch := make(chan loadedFrame, vlmPrefetch)
+	go func() {
+		defer close(ch)
+		for _, kf := range keyframes {
+			lf := loadedFrame{kf: kf, img: kf.ImageBytes}
+			switch {
+			case lf.img != nil:
+			case kf.FetchInto != nil:
+				lf.buf = bufpool.Get()
+				if lf.err = kf.FetchInto(ctx, lf.buf); lf.err == nil {
+					lf.img = lf.buf.Bytes()
+				}
+			case kf.Fetch != nil:
+				lf.img, lf.err = kf.Fetch(ctx)
+			}
+			if lf.err != nil {
+				lf.err = fmt.Errorf("fetch keyframe: %w", lf.err)
+			}
+			select {
+			case ch <- lf:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+type frameProgressKey struct{}
+
+// WithFrameProgress makes RunVLM under ctx call fn each time a keyframe has
+// been described, or failed, with how many of the total it has done.
+func WithFrameProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, frameProgressKey{}, fn)
+}
+
+func reportFrame(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(frameProgressKey{}).(func(done, total int)); ok {
+		fn(done, total)
+	}
+}
+
+// FramesInFlight is the most keyframe images RunVLM holds in memory at once
+// with the given options: the batch being described, the prefetch window and
+// the frame being fetched.
+func FramesInFlight(opts VLMOptions) int {
+	return max(opts.BatchSize, 1) + vlmPrefetch + 1
+}
+
+// VLMCalls estimates how many Gemini requests describing frames keyframes
+// takes with opts: one per frame MaxFrames keeps, or per BatchSize of them,
+// plus a story summary every SummaryEvery frames with ContextRollingSummary.
+// Through the Batch API (batch) each frame is one request of the batch.
+// Retries are not counted.
+func VLMCalls(frames int, opts VLMOptions, batch bool) int {
+	if opts.MaxFrames > 0 {
+		frames = min(frames, opts.MaxFrames)
+	}
+	if batch {
+		return frames
+	}
+	size := max(opts.BatchSize, 1)
+	if opts.PromptTemplate != "" {
+		size = 1
+	}
+	calls := (frames + size - 1) / size
+	if opts.Context == ContextRollingSummary {
+		calls += frames / summaryEvery(opts)
+	}
+	return calls
 }
 
 // RunVLM generates visual descriptions for each keyframe via Gemini 2.0 Flash.
-// Sequential per-frame: each prompt includes previous frame's description for continuity.
-func RunVLM(ctx context.Context, keyframes []KeyframeInput, apiKey string) (*VLMResult, error) {
-	result := &VLMResult{}
-	prevDesc := "This is the first frame of the ad."
+// Sequential per-frame: each prompt includes previous frame's description for continuity,
+// or with ContextRollingSummary a compact summary of the story so far as well.
+// With opts.BatchSize > 1, consecutive frames share a request and the last
+// description of one batch is the context for the next.
+func RunVLM(ctx context.Context, keyframes []KeyframeInput, apiKey string, opts VLMOptions) (*VLMResult, error) {
+	if opts.Model != "" {
+		if err := ValidateGeminiModel(opts.Model); err != nil {
+			return nil, err
+		}
+		ctx = withGeminiModel(ctx, opts.Model)
+	}
+	batchSize := max(opts.BatchSize, 1)
+	promptVersion := vlmPromptVersion
+	params := map[string]string{"context": ContextPreviousFrame}
+	if opts.Context == ContextRollingSummary {
+		params["context"] = ContextRollingSummary
+		params["summary_every"] = strconv.Itoa(summaryEvery(opts))
+		params["summary_prompt"] = storySummaryPromptVersion
+	}
+	switch {
+	case opts.PromptTemplate != "":
+		if err := ValidatePromptTemplate(opts.PromptTemplate); err != nil {
+			return nil, err
+		}
+		batchSize, promptVersion = 1, customPromptVersion(opts.PromptTemplate)
+	case batchSize > 1:
+		promptVersion = vlmBatchPromptVersion
+		params["batch_size"] = strconv.Itoa(batchSize)
+	}
 
-	for _, kf := range keyframes {
-		prompt := fmt.Sprintf(vlmPromptTemplate, prevDesc, kf.TimestampSec)
+	keyframes, skipped := selectKeyframes(keyframes, opts.MaxFrames, opts.Selection)
+	if skipped != nil {
+		params["max_frames"] = strconv.Itoa(opts.MaxFrames)
+		params["selection"] = cmp.Or(opts.Selection, SelectByEntropy)
+	}
+	lang := promptLanguage(opts.Language)
+	if lang != "" {
+		params["language"] = lang
+	}
+	if limit := tokenBudget(ctx).Limit(); limit > 0 {
+		params["token_budget"] = strconv.FormatInt(limit, 10)
+	}
 
-		desc, err := callGemini(ctx, apiKey, kf.ImageBytes, prompt)
-		if err != nil {
-			desc = fmt.Sprintf("[Error: %v]", err)
+	result := &VLMResult{
+		SchemaVersion: VLMSchemaVersion,
+		SkippedFrames: skipped,
+		Provenance:    withModel(ctx, geminiProvenance(promptVersion, params)),
+	}
+	story := newStoryContext(apiKey, opts)
+	cache := newPromptCache(apiKey)
+	defer cache.close(ctx)
+
+	var batch []loadedFrame
+	flush := func() {
+		descs, errs := describeFrames(ctx, apiKey, batch, story.String(), lang, cache, opts)
+		for i, lf := range batch {
+			desc, err := descs[i], errs[i]
+			if err != nil {
+				desc = fmt.Sprintf("[Error: %v]", err)
+			}
+
+			result.Frames = append(result.Frames, VLMFrame{
+				FrameIndex:   lf.kf.FrameIndex,
+				TimestampSec: lf.kf.TimestampSec,
+				Description:  desc,
+				Blocked:      errors.Is(err, ErrBlocked),
+			})
+			if err == nil {
+				story.add(ctx, desc)
+			}
+			batch[i].release()
+			reportFrame(ctx, len(result.Frames), len(keyframes))
 		}
+		batch = batch[:0]
+	}
 
-		result.Frames = append(result.Frames, VLMFrame{
-			FrameIndex:   kf.FrameIndex,
-			TimestampSec: kf.TimestampSec,
-			Description:  desc,
-		})
-		if err == nil {
-			prevDesc = desc
+	// Once the job's context ends the frames described so far are kept and
+	// the rest are dropped, rather than each failing with a deadline error.
+	// Once the token budget runs out the rest are skipped instead.
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+	taken, overBudgetFrames := 0, 0
+	for lf := range prefetchFrames(fetchCtx, keyframes) {
+		if ctx.Err() != nil {
+			break
+		}
+		if BudgetExhausted(ctx) {
+			lf.release()
+			over := overBudget(keyframes[taken:])
+			result.SkippedFrames = append(result.SkippedFrames, over...)
+			overBudgetFrames = len(over)
+			break
 		}
+		taken++
+		batch = append(batch, lf)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 && ctx.Err() == nil {
+		flush()
 	}
+	result.Incomplete = len(result.Frames)+overBudgetFrames < len(keyframes)
 
+	result.normalize()
 	return result, nil
 }
 
+// describeFrames returns a description or an error for each frame. Frames
+// whose image loaded are described together in one request when there is
+// more than one of them.
+func describeFrames(ctx context.Context, apiKey string, frames []loadedFrame, prevDesc, lang string, cache *promptCache, opts VLMOptions) ([]string, []error) {
+	ctx = withHedging(ctx)
+	timeout := opts.FrameTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errFrameTimeout)
+		defer cancel()
+	}
+
+	descs := make([]string, len(frames))
+	errs := make([]error, len(frames))
+
+	var ready []int
+	for i, lf := range frames {
+		if lf.err != nil {
+			errs[i] = lf.err
+			continue
+		}
+		ready = append(ready, i)
+	}
+
+	switch len(ready) {
+	case 0:
+	case 1:
+		lf := frames[ready[0]]
+		var prompt string
+		if opts.PromptTemplate != "" {
+			prompt = withLanguage(fmt.Sprintf(opts.PromptTemplate, prevDesc, lf.kf.TimestampSec), lang)
+		} else {
+			ctx, prompt = cache.prompt(ctx, fmt.Sprintf(vlmFrameHeader, prevDesc, lf.kf.TimestampSec), withLanguage(vlmInstructions, lang))
+		}
+		descs[ready[0]], errs[ready[0]] = callGemini(ctx, apiKey, lf.img, prompt)
+	default:
+		sub := make([]loadedFrame, len(ready))
+		for j, i := range ready {
+			sub[j] = frames[i]
+		}
+		var out []struct {
+			FrameIndex  int    `json:"frame_index"`
+			Description string `json:"description"`
+		}
+		ctx, prompt := cache.prompt(ctx, fmt.Sprintf(vlmBatchHeader, len(sub), prevDesc), withLanguage(vlmBatchInstructions, lang))
+		err := callGeminiBatch(ctx, apiKey, prompt, sub, &out)
+
+		byIndex := make(map[int]string, len(out))
+		for _, d := range out {
+			byIndex[d.FrameIndex] = strings.TrimSpace(d.Description)
+		}
+		for _, i := range ready {
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			idx := frames[i].kf.FrameIndex
+			if descs[i] = byIndex[idx]; descs[i] == "" {
+				errs[i] = fmt.Errorf("no description returned for frame %d", idx)
+			}
+		}
+	}
+
+	// Report the frame timeout rather than a transport-wrapped deadline error
+	if errors.Is(context.Cause(ctx), errFrameTimeout) {
+		for i, err := range errs {
+			if errors.Is(err, errFrameTimeout) || errors.Is(err, context.DeadlineExceeded) {
+				errs[i] = fmt.Errorf("%w of %s", errFrameTimeout, timeout)
+			}
+		}
+	}
+	return descs, errs
+}
+
+// promptLanguage is lang if the prompts should mention it, or "" for
+// English and unknown languages.
+func promptLanguage(lang string) string {
+	lang = strings.TrimSpace(lang)
+	primary, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	if primary == "" || primary == "en" {
+		return ""
+	}
+	return lang
+}
+
+// withLanguage adds vlmLanguageInstruction to a VLM prompt when lang is set,
+// ahead of the response format if the prompt ends with one.
+func withLanguage(prompt, lang string) string {
+	if lang == "" {
+		return prompt
+	}
+	instruction := fmt.Sprintf(vlmLanguageInstruction, lang)
+	if i := strings.LastIndex(prompt, "\n\nRespond with"); i >= 0 {
+		return prompt[:i] + "\n\n" + instruction + prompt[i:]
+	}
+	return prompt + "\n\n" + instruction
+}
+
 // geminiRequest is the Gemini REST API request body.
 type geminiRequest struct {
-	Contents []geminiContent `json:"contents"`
+	Contents         []geminiContent         `json:"contents"`
+	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	CachedContent    string                  `json:"cachedContent,omitempty"` // e.g. "cachedContents/abc123"
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
 }
 
 type geminiContent struct {
@@ -80,6 +494,7 @@ type geminiContent struct {
 type geminiPart struct {
 	Text       string          `json:"text,omitempty"`
 	InlineData *geminiInline   `json:"inline_data,omitempty"`
+	FileData   *geminiFileData `json:"file_data,omitempty"`
 }
 
 type geminiInline struct {
@@ -94,71 +509,207 @@ type geminiResponse struct {
 				Text string `json:"text"`
 			} `json:"parts"`
 		} `json:"content"`
+		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error"`
+	UsageMetadata struct {
+		TotalTokenCount int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// postGemini makes a single generateContent attempt and returns the body of
+// a 200 response. Each attempt draws from the rate limit budget.
+func postGemini(ctx context.Context, url string, bodyBytes []byte, tokens int) ([]byte, error) {
+	if err := GeminiHealth(); err != nil {
+		return nil, err
+	}
+	if geminiLimiter != nil {
+		if err := geminiLimiter.Wait(ctx, tokens); err != nil {
+			return nil, fmt.Errorf("gemini rate limit: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := authorizeGemini(req); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := geminiClient.Do(req)
+	if err != nil {
+		recordHealth(ctx, geminiHealth, false)
+		geminiStats.observe(ctx, start, nil, true)
+		return nil, fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+	healthy := resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
+	recordHealth(ctx, geminiHealth, healthy)
+	geminiStats.observe(ctx, start, resp, !healthy)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, retry.NewHTTPError("gemini", resp, respBody)
+	}
+	return respBody, nil
+}
+
+// blockedFinishReasons are candidate finish reasons that mean the answer was
+// withheld for policy reasons rather than failing.
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"PROHIBITED_CONTENT": true,
+	"BLOCKLIST":          true,
+	"SPII":               true,
 }
 
 // geminiBaseURL can be overridden in tests.
 var geminiBaseURL = "https://generativelanguage.googleapis.com"
 
+// Gemini bills each inline image at a flat token count; text is estimated at
+// roughly four characters per token. outputTokenAllowance covers the answer.
+const (
+	imageTokens          = 258
+	outputTokenAllowance = 300
+)
+
+func estimateTokens(req geminiRequest) int {
+	n := outputTokenAllowance
+	for _, c := range req.Contents {
+		for _, p := range c.Parts {
+			n += len(p.Text) / 4
+			if p.InlineData != nil || p.FileData != nil {
+				n += imageTokens
+			}
+		}
+	}
+	return n
+}
+
 func callGemini(ctx context.Context, apiKey string, imageBytes []byte, prompt string) (string, error) {
-	url := fmt.Sprintf(
-		"%s/v1beta/models/gemini-2.0-flash:generateContent?key=%s",
-		geminiBaseURL, apiKey,
-	)
+	img, cleanup, err := imagePart(ctx, apiKey, imageBytes)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
 
 	reqBody := geminiRequest{
 		Contents: []geminiContent{{
-			Parts: []geminiPart{
-				{Text: prompt},
-				{InlineData: &geminiInline{
-					MimeType: "image/jpeg",
-					Data:     base64.StdEncoding.EncodeToString(imageBytes),
-				}},
-			},
+			Parts: []geminiPart{{Text: prompt}, img},
 		}},
 	}
+	return generateContent(ctx, apiKey, reqBody)
+}
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
+// callGeminiText sends a text-only prompt and returns the model's answer.
+func callGeminiText(ctx context.Context, apiKey, prompt string) (string, error) {
+	return generateContent(ctx, apiKey, geminiRequest{
+		Contents: []geminiContent{{
+			Parts: []geminiPart{{Text: prompt}},
+		}},
+	})
+}
+
+// callGeminiJSON sends a text-only prompt in JSON response mode and decodes
+// the model's answer into out.
+func callGeminiJSON(ctx context.Context, apiKey, prompt string, out any) error {
+	return generateJSON(ctx, apiKey, []geminiPart{{Text: prompt}}, out)
+}
+
+// callGeminiBatch sends several keyframes in one JSON-mode request, each
+// image labelled with its frame index and timestamp.
+func callGeminiBatch(ctx context.Context, apiKey, prompt string, frames []loadedFrame, out any) error {
+	parts := []geminiPart{{Text: prompt}}
+	for _, lf := range frames {
+		img, cleanup, err := imagePart(ctx, apiKey, lf.img)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		parts = append(parts,
+			geminiPart{Text: fmt.Sprintf("Frame %d at %.1fs:", lf.kf.FrameIndex, lf.kf.TimestampSec)},
+			img,
+		)
 	}
+	return generateJSON(ctx, apiKey, parts, out)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+func generateJSON(ctx context.Context, apiKey string, parts []geminiPart, out any) error {
+	reqBody := geminiRequest{
+		Contents:         []geminiContent{{Parts: parts}},
+		GenerationConfig: &geminiGenerationConfig{ResponseMimeType: "application/json"},
+	}
+	text, err := generateContent(ctx, apiKey, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("decode model json: %w", err)
+	}
+	return nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("gemini request: %w", err)
+func generateContent(ctx context.Context, apiKey string, reqBody geminiRequest) (string, error) {
+	if name, ok := ctx.Value(cachedContentKey{}).(string); ok {
+		reqBody.CachedContent = name
 	}
-	defer resp.Body.Close()
+	url := geminiURL(apiKey, "models/"+geminiModelFor(ctx)+":generateContent")
 
-	respBody, err := io.ReadAll(resp.Body)
+	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return "", fmt.Errorf("marshal request: %w", err)
 	}
+	tokens := estimateTokens(reqBody)
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("gemini returned %d: %s", resp.StatusCode, string(respBody))
+	var respBody []byte
+	err = retry.Do(ctx, geminiRetry, func(ctx context.Context) error {
+		var err error
+		respBody, err = postGeminiHedged(ctx, url, bodyBytes, tokens)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
 
 	var gemResp geminiResponse
 	if err := json.Unmarshal(respBody, &gemResp); err != nil {
 		return "", fmt.Errorf("decode response: %w", err)
 	}
+	// Without usage metadata the estimate stands in
+	tokenBudget(ctx).add(cmp.Or(gemResp.UsageMetadata.TotalTokenCount, tokens))
+	return gemResp.text()
+}
+
+// text returns the answer in a generateContent response, or why there is
+// none.
+func (r *geminiResponse) text() (string, error) {
+	if r.Error != nil {
+		return "", fmt.Errorf("gemini error: %s", r.Error.Message)
+	}
+
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return "", fmt.Errorf("%w: %s", ErrBlocked, r.PromptFeedback.BlockReason)
+	}
 
-	if gemResp.Error != nil {
-		return "", fmt.Errorf("gemini error: %s", gemResp.Error.Message)
+	if len(r.Candidates) > 0 && blockedFinishReasons[r.Candidates[0].FinishReason] {
+		return "", fmt.Errorf("%w: %s", ErrBlocked, r.Candidates[0].FinishReason)
 	}
 
-	if len(gemResp.Candidates) == 0 || len(gemResp.Candidates[0].Content.Parts) == 0 {
+	if len(r.Candidates) == 0 || len(r.Candidates[0].Content.Parts) == 0 {
 		return "", fmt.Errorf("empty response from gemini")
 	}
 
-	return strings.TrimSpace(gemResp.Candidates[0].Content.Parts[0].Text), nil
+	return strings.TrimSpace(r.Candidates[0].Content.Parts[0].Text), nil
 }
@@ -1,12 +1,20 @@
 package streams
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // ---------------------------------------------------------------------------
@@ -135,6 +143,34 @@ func TestCallGemini_HTTPError(t *testing.T) {
 	}
 }
 
+func TestCallGemini_SafetyBlocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{}, "finishReason": "SAFETY"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	_, err := callGemini(context.Background(), "key", []byte("img"), "prompt")
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+
+	result, err := RunVLM(context.Background(), []KeyframeInput{{ImageBytes: []byte("img")}}, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if !result.Frames[0].Blocked {
+		t.Error("frame should be marked blocked")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // RunVLM
 // ---------------------------------------------------------------------------
@@ -183,7 +219,7 @@ func TestRunVLM_SequentialProcessing(t *testing.T) {
 		{FrameIndex: 5, TimestampSec: 2.5, ImageBytes: []byte("img2")},
 	}
 
-	result, err := RunVLM(context.Background(), keyframes, "key")
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{})
 	if err != nil {
 		t.Fatalf("RunVLM error: %v", err)
 	}
@@ -206,6 +242,9 @@ func TestRunVLM_SequentialProcessing(t *testing.T) {
 	if callCount != 2 {
 		t.Errorf("expected 2 API calls, got %d", callCount)
 	}
+	if p := result.Provenance; p == nil || p.Model != "gemini-2.0-flash" || p.PromptVersion != vlmPromptVersion {
+		t.Errorf("provenance = %+v", result.Provenance)
+	}
 }
 
 func TestRunVLM_ErrorContinues(t *testing.T) {
@@ -238,7 +277,7 @@ func TestRunVLM_ErrorContinues(t *testing.T) {
 		{FrameIndex: 3, TimestampSec: 1.5, ImageBytes: []byte("img2")},
 	}
 
-	result, err := RunVLM(context.Background(), keyframes, "key")
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{})
 	if err != nil {
 		t.Fatalf("RunVLM should not return error: %v", err)
 	}
@@ -256,8 +295,41 @@ func TestRunVLM_ErrorContinues(t *testing.T) {
 	}
 }
 
+func TestRunVLM_FrameProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{
+					"parts": []map[string]any{{"text": "A frame"}},
+				}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	keyframes := []KeyframeInput{
+		{FrameIndex: 0, TimestampSec: 0.0, ImageBytes: []byte("img1")},
+		{FrameIndex: 3, TimestampSec: 1.5, ImageBytes: []byte("img2")},
+		{FrameIndex: 6, TimestampSec: 3.0, ImageBytes: []byte("img3")},
+	}
+	var got []string
+	ctx := WithFrameProgress(context.Background(), func(done, total int) {
+		got = append(got, fmt.Sprintf("%d/%d", done, total))
+	})
+	if _, err := RunVLM(ctx, keyframes, "key", VLMOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1/3", "2/3", "3/3"}; !slices.Equal(got, want) {
+		t.Errorf("progress = %v, want %v", got, want)
+	}
+}
+
 func TestRunVLM_EmptyKeyframes(t *testing.T) {
-	result, err := RunVLM(context.Background(), nil, "key")
+	result, err := RunVLM(context.Background(), nil, "key", VLMOptions{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -280,3 +352,470 @@ func TestVLMPromptTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestRunVLM_Language(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Contents[0].Parts[0].Text)
+		w.Write(mockGeminiResponse("Una botella."))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	for _, lang := range []string{"", "en-US", "es-419"} {
+		prompts = nil
+		res, err := RunVLM(context.Background(), testKeyframes(1), "key", VLMOptions{Language: lang})
+		if err != nil {
+			t.Fatal(err)
+		}
+		asked := strings.Contains(prompts[0], `The ad's language is "es-419"`)
+		if asked != (lang == "es-419") || asked != (res.Provenance.Params["language"] == "es-419") {
+			t.Errorf("language %q: prompt = %q, provenance = %v", lang, prompts[0], res.Provenance.Params)
+		}
+	}
+}
+
+func TestRunVLM_PromptTemplate(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Contents[0].Parts[0].Text)
+		w.Write(mockGeminiResponse("A bottle."))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	tmpl := "Before: %s. Describe the product placement at %.1fs; 100%% literal."
+	res, err := RunVLM(context.Background(), testKeyframes(3), "key", VLMOptions{PromptTemplate: tmpl, BatchSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 3 || !strings.HasPrefix(prompts[1], "Before: A bottle.. Describe the product placement at ") || !strings.HasSuffix(prompts[1], "s; 100% literal.") {
+		t.Errorf("prompts = %q", prompts)
+	}
+	if v := res.Provenance.PromptVersion; !strings.HasPrefix(v, "custom-") || Outdated(res.Provenance) != "" {
+		t.Errorf("provenance = %+v", res.Provenance)
+	}
+}
+
+func TestRunVLM_Model(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write(mockGeminiResponse("A bottle."))
+	}))
+	defer server.Close()
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+	SetAllowedModels(nil, []string{"gemini-1.5-pro"})
+	defer SetAllowedModels(nil, nil)
+
+	res, err := RunVLM(context.Background(), testKeyframes(2), "key", VLMOptions{Model: "gemini-1.5-pro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "/v1beta/models/gemini-1.5-pro:generateContent" {
+		t.Errorf("paths = %v", paths)
+	}
+	if p := res.Provenance; p.Model != "gemini-1.5-pro" || p.Params["model"] != "gemini-1.5-pro" || Outdated(p) != "" {
+		t.Errorf("provenance = %+v", p)
+	}
+	if _, err := RunVLM(context.Background(), testKeyframes(1), "key", VLMOptions{Model: "gemini-ultra"}); err == nil {
+		t.Error("model outside the allowlist was used")
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	SetAllowedModels([]string{"nova-2"}, nil)
+	defer SetAllowedModels(nil, nil)
+	if err := ValidateDeepgramModel("nova-2"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateDeepgramModel(deepgramModel); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateDeepgramModel("whisper-large"); err == nil {
+		t.Error("whisper-large allowed")
+	}
+	if err := ValidateGeminiModel(geminiModel); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateGeminiModel("gemini-1.5-pro"); err == nil {
+		t.Error("gemini-1.5-pro allowed without being listed")
+	}
+}
+
+func TestValidatePromptTemplate(t *testing.T) {
+	for tmpl, ok := range map[string]bool{
+		vlmPromptTemplate:                     true,
+		"Context: %v. At %5.2f seconds. 50%%": true,
+		"At %.1fs after %s":                   false,
+		"Context: %s":                         false,
+		"%s %.1f %d":                          false,
+		"%[2]s %[1]f":                         false,
+		"%s at %.1":                           false,
+		"  ":                                  false,
+	} {
+		if err := ValidatePromptTemplate(tmpl); (err == nil) != ok {
+			t.Errorf("%q: %v", tmpl, err)
+		}
+	}
+}
+
+func TestWithLanguage(t *testing.T) {
+	got := withLanguage("Describe.\n\nRespond with a JSON array.", "pt-BR")
+	if !strings.HasPrefix(got, "Describe.\n\nThe ad's language is \"pt-BR\"") || !strings.HasSuffix(got, "\n\nRespond with a JSON array.") {
+		t.Errorf("withLanguage = %q", got)
+	}
+	if got := withLanguage("Describe.", ""); got != "Describe." {
+		t.Errorf("withLanguage without a language = %q", got)
+	}
+}
+
+type countingLimiter struct{ calls, tokens int }
+
+func (l *countingLimiter) Wait(_ context.Context, tokens int) error {
+	l.calls++
+	l.tokens += tokens
+	return nil
+}
+
+func TestGenerateContent_UsesLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": "ok"}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	lim := &countingLimiter{}
+	SetGeminiLimiter(lim)
+	defer SetGeminiLimiter(nil)
+
+	if _, err := callGemini(context.Background(), "key", []byte("img"), strings.Repeat("x", 400)); err != nil {
+		t.Fatalf("callGemini error: %v", err)
+	}
+	if lim.calls != 1 {
+		t.Fatalf("limiter calls = %d, want 1", lim.calls)
+	}
+	// 400/4 text + image + output allowance
+	if want := 100 + imageTokens + outputTokenAllowance; lim.tokens != want {
+		t.Errorf("tokens = %d, want %d", lim.tokens, want)
+	}
+}
+
+func TestRunVLM_FetchIntoPooledBuffers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{
+					{"text": "saw " + req.Contents[0].Parts[1].InlineData.Data},
+				}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	keyframes := make([]KeyframeInput, 4)
+	for i := range keyframes {
+		keyframes[i] = KeyframeInput{
+			FrameIndex: i,
+			FetchInto: func(ctx context.Context, buf *bytes.Buffer) error {
+				if i == 2 {
+					return errors.New("object missing")
+				}
+				buf.WriteString(strings.Repeat(string(rune('a'+i)), 100))
+				return nil
+			},
+		}
+	}
+
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	for i, f := range result.Frames {
+		want := "saw " + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune('a'+i)), 100)))
+		if i == 2 {
+			want = "[Error: fetch keyframe: object missing]"
+		}
+		if f.Description != want {
+			t.Errorf("frame %d desc = %q, want %q", i, f.Description, want)
+		}
+	}
+}
+
+func TestRunVLM_LazyFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{
+					{"text": "saw " + req.Contents[0].Parts[1].InlineData.Data},
+				}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	var mu sync.Mutex
+	fetched, maxAhead := 0, 0
+	described := 0
+	keyframes := make([]KeyframeInput, 6)
+	for i := range keyframes {
+		keyframes[i] = KeyframeInput{
+			FrameIndex:   i,
+			TimestampSec: float64(i),
+			Fetch: func(ctx context.Context) ([]byte, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				fetched++
+				maxAhead = max(maxAhead, fetched-described)
+				if i == 3 {
+					return nil, errors.New("object missing")
+				}
+				return []byte{'a' + byte(i)}, nil
+			},
+		}
+	}
+
+	// Count frames as described via the limiter hook, which runs per call
+	lim := &callbackLimiter{fn: func() { mu.Lock(); described++; mu.Unlock() }}
+	SetGeminiLimiter(lim)
+	defer SetGeminiLimiter(nil)
+
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if len(result.Frames) != 6 {
+		t.Fatalf("expected 6 frames, got %d", len(result.Frames))
+	}
+	if !strings.Contains(result.Frames[3].Description, "fetch keyframe: object missing") {
+		t.Errorf("frame 3 desc = %q", result.Frames[3].Description)
+	}
+	if result.Frames[4].Description != "saw "+base64.StdEncoding.EncodeToString([]byte("e")) {
+		t.Errorf("frame 4 desc = %q", result.Frames[4].Description)
+	}
+	// One being described, vlmPrefetch buffered, one in flight
+	if maxAhead > vlmPrefetch+2 {
+		t.Errorf("fetched %d frames ahead, want at most %d", maxAhead, vlmPrefetch+2)
+	}
+}
+
+type callbackLimiter struct{ fn func() }
+
+func (l *callbackLimiter) Wait(context.Context, int) error {
+	l.fn()
+	return nil
+}
+
+func TestRunVLM_Batched(t *testing.T) {
+	var images []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		// Frames are labelled "Frame N at Ts:" ahead of each image
+		var frames []map[string]any
+		n := 0
+		for _, p := range req.Contents[0].Parts {
+			if p.InlineData != nil {
+				n++
+				continue
+			}
+			var idx int
+			var ts float64
+			if _, err := fmt.Sscanf(p.Text, "Frame %d at %fs:", &idx, &ts); err == nil {
+				if idx == 4 {
+					continue // model omits this frame
+				}
+				frames = append(frames, map[string]any{"frame_index": idx, "description": fmt.Sprintf("frame %d", idx)})
+			}
+		}
+		images = append(images, n)
+
+		text := "single frame"
+		if n > 1 {
+			if req.GenerationConfig == nil || req.GenerationConfig.ResponseMimeType != "application/json" {
+				t.Error("batched request should use JSON mode")
+			}
+			b, _ := json.Marshal(frames)
+			text = string(b)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": text}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	var keyframes []KeyframeInput
+	for i := range 5 {
+		keyframes = append(keyframes, KeyframeInput{FrameIndex: i, TimestampSec: float64(i), ImageBytes: []byte("img")})
+	}
+
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if fmt.Sprint(images) != "[2 2 1]" {
+		t.Errorf("images per request = %v, want [2 2 1]", images)
+	}
+	if est := VLMCalls(5, VLMOptions{BatchSize: 2}, false); est != len(images) {
+		t.Errorf("VLMCalls = %d, made %d", est, len(images))
+	}
+	if len(result.Frames) != 5 {
+		t.Fatalf("expected 5 frames, got %d", len(result.Frames))
+	}
+	for i, want := range []string{"frame 0", "frame 1", "frame 2", "frame 3", "single frame"} {
+		if got := result.Frames[i].Description; got != want {
+			t.Errorf("frame %d desc = %q, want %q", i, got, want)
+		}
+	}
+	if p := result.Provenance; p.PromptVersion != vlmBatchPromptVersion || p.Params["batch_size"] != "2" {
+		t.Errorf("provenance = %+v", p)
+	}
+}
+
+func TestRunVLM_BatchMissingFrame(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{
+					{"text": `[{"frame_index": 0, "description": "first"}]`},
+				}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	keyframes := []KeyframeInput{
+		{FrameIndex: 0, ImageBytes: []byte("a")},
+		{FrameIndex: 1, TimestampSec: 1, ImageBytes: []byte("b")},
+	}
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{BatchSize: 4})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if result.Frames[0].Description != "first" {
+		t.Errorf("frame 0 desc = %q", result.Frames[0].Description)
+	}
+	if !strings.Contains(result.Frames[1].Description, "no description returned for frame 1") {
+		t.Errorf("frame 1 desc = %q", result.Frames[1].Description)
+	}
+}
+
+func TestRunVLM_FrameTimeout(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			time.Sleep(300 * time.Millisecond) // well past the frame timeout
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": "second"}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	keyframes := []KeyframeInput{
+		{FrameIndex: 0, ImageBytes: []byte("a")},
+		{FrameIndex: 1, TimestampSec: 1, ImageBytes: []byte("b")},
+	}
+	result, err := RunVLM(context.Background(), keyframes, "key", VLMOptions{FrameTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if !strings.Contains(result.Frames[0].Description, "frame timeout of 50ms") {
+		t.Errorf("frame 0 desc = %q", result.Frames[0].Description)
+	}
+	if result.Frames[1].Description != "second" {
+		t.Errorf("frame 1 desc = %q", result.Frames[1].Description)
+	}
+}
+
+func TestRunVLM_StopsWhenJobEnds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]any{{"text": "ok"}}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	old := geminiBaseURL
+	geminiBaseURL = server.URL
+	defer func() { geminiBaseURL = old }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The job ends as the second frame is being described
+	calls := 0
+	SetGeminiLimiter(&callbackLimiter{fn: func() {
+		if calls++; calls == 2 {
+			cancel()
+		}
+	}})
+	defer SetGeminiLimiter(nil)
+
+	var keyframes []KeyframeInput
+	for i := range 5 {
+		keyframes = append(keyframes, KeyframeInput{FrameIndex: i, TimestampSec: float64(i), ImageBytes: []byte("img")})
+	}
+	result, err := RunVLM(ctx, keyframes, "key", VLMOptions{})
+	if err != nil {
+		t.Fatalf("RunVLM error: %v", err)
+	}
+	if !result.Incomplete {
+		t.Error("result should be marked incomplete")
+	}
+	if calls != 2 || len(result.Frames) != 2 {
+		t.Fatalf("calls = %d, frames = %d; want 2 and 2", calls, len(result.Frames))
+	}
+	if result.Frames[0].Description != "ok" {
+		t.Errorf("frame 0 desc = %q", result.Frames[0].Description)
+	}
+}
@@ -0,0 +1,74 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/retry"
+)
+
+// WarmUp opens connections to Deepgram and Gemini so the first job does not
+// pay for DNS lookups and TLS handshakes. Each provider gets a cheap
+// authenticated metadata call; with verify set a non-200 answer is an error,
+// which surfaces a bad API key at boot rather than on the first job.
+// Providers without a key are skipped. A self-hosted Deepgram has no
+// projects API, so it only gets a connection, which verify does not check
+// beyond. Through Vertex AI, Gemini is asked
+// to count the tokens of a one-word prompt, which also fetches the first
+// access token.
+func WarmUp(ctx context.Context, deepgramKey, geminiKey string, verify bool) error {
+	var errs []error
+	if deepgramSelfHosted {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, deepgramBaseURL+"/", nil)
+		if err == nil {
+			err = warmUp(deepgramClient, "deepgram", req, false)
+		}
+		errs = append(errs, err)
+	} else if deepgramKey != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, deepgramBaseURL+"/v1/projects", nil)
+		if err == nil {
+			authorizeDeepgram(req, deepgramKey)
+			err = warmUp(deepgramClient, "deepgram", req, verify)
+		}
+		errs = append(errs, err)
+	}
+	if geminiVertex != nil {
+		body := `{"contents": [{"role": "user", "parts": [{"text": "hello"}]}]}`
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiURL("", "models/"+geminiModel+":countTokens"), strings.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			err = authorizeGemini(req)
+		}
+		if err == nil {
+			err = warmUp(geminiClient, "gemini", req, verify)
+		}
+		errs = append(errs, err)
+	} else if geminiKey != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, geminiURL(geminiKey, "models/"+geminiModel), nil)
+		if err == nil {
+			err = warmUp(geminiClient, "gemini", req, verify)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// warmUp sends req and drains the response so the connection returns to the
+// client's idle pool. Without verify any response counts as success.
+func warmUp(client *http.Client, provider string, req *http.Request, verify bool) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s warm-up: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if verify && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s warm-up: %w", provider, retry.NewHTTPError(provider, resp, body))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWarmUp(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/projects" && r.Header.Get("Authorization") != "Token dg-key" {
+			t.Errorf("deepgram auth = %q", r.Header.Get("Authorization"))
+		}
+		if strings.HasPrefix(r.URL.Path, "/v1beta/") && r.URL.Query().Get("key") == "bad" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	oldDG, oldGem := deepgramBaseURL, geminiBaseURL
+	deepgramBaseURL, geminiBaseURL = server.URL, server.URL
+	defer func() { deepgramBaseURL, geminiBaseURL = oldDG, oldGem }()
+
+	if err := WarmUp(context.Background(), "dg-key", "gem-key", true); err != nil {
+		t.Fatalf("WarmUp error: %v", err)
+	}
+	if len(paths) != 2 || paths[1] != "/v1beta/models/"+geminiModel {
+		t.Errorf("paths = %v", paths)
+	}
+
+	// A rejected key only fails when verifying
+	if err := WarmUp(context.Background(), "", "bad", false); err != nil {
+		t.Errorf("unverified WarmUp error: %v", err)
+	}
+	err := WarmUp(context.Background(), "", "bad", true)
+	if err == nil || !strings.Contains(err.Error(), "gemini warm-up: gemini returned 403") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/httpclient"
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+// Payload is the body POSTed to a webhook receiver when a job finishes.
+type Payload struct {
+	Event     string     `json:"event"` // "extraction.completed"
+	AdID      string     `json:"ad_id"`
+	Result    any        `json:"result"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Artifact is a produced file with a short-lived presigned download URL, so
+// receivers can fetch it without storage credentials.
+type Artifact struct {
+	Stream    string    `json:"stream"`
+	R2Key     string    `json:"r2_key"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+const maxAttempts = 3
+
+// httpClient is used for deliveries to the configured receiver; receivers
+// get a bounded amount of time.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// publicClient is used for caller-supplied receivers. It connects to public
+// addresses only and does not follow redirects, so callers cannot have
+// payloads, and the presigned URLs in them, sent into the private network.
+var publicClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         httpclient.PublicDialer(10 * time.Second).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// retryDelay is the wait before the second attempt; it doubles each time.
+// Overridden in tests.
+var retryDelay = time.Second
+
+// ValidateURL checks that a caller-supplied webhook target is an absolute
+// https URL.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook_url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid webhook_url: must be an absolute https URL")
+	}
+	return nil
+}
+
+// Deliver POSTs the payload to the configured receiver, retrying network
+// errors, 429s and 5xx responses with exponential backoff. With secret set
+// every attempt is signed, as client.VerifyWebhook checks, with the time it
+// is sent.
+func Deliver(ctx context.Context, target, secret string, p *Payload) error {
+	return deliver(ctx, httpClient, target, secret, p)
+}
+
+// DeliverPublic is Deliver for a caller-supplied target, such as a
+// request's webhook_url, which must resolve to a public address.
+func DeliverPublic(ctx context.Context, target, secret string, p *Payload) error {
+	return deliver(ctx, publicClient, target, secret, p)
+}
+
+func deliver(ctx context.Context, c *http.Client, target, secret string, p *Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	delay := retryDelay
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		retry, err := post(ctx, c, target, secret, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return fmt.Errorf("deliver webhook: %w", lastErr)
+}
+
+func post(ctx context.Context, c *http.Client, target, secret string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		client.SignWebhook(req.Header, secret, body, time.Now())
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %d: %s", resp.StatusCode, string(msg))
+}
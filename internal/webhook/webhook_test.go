@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/pkg/client"
+)
+
+func TestDeliver_RetriesThenSucceeds(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	calls := 0
+	var got Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content-type = %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	p := &Payload{
+		Event: "extraction.completed",
+		AdID:  "ad-1",
+		Artifacts: []Artifact{
+			{Stream: "asr", R2Key: "ads/ad-1/extraction/asr_results.json", URL: "https://signed"},
+		},
+	}
+	if err := Deliver(context.Background(), server.URL, "", p); err != nil {
+		t.Fatalf("Deliver error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+	if got.AdID != "ad-1" || len(got.Artifacts) != 1 || got.Artifacts[0].URL != "https://signed" {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestDeliver_NoRetryOnClientError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := Deliver(context.Background(), server.URL, "", &Payload{}); err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestDeliver_SignsEachAttempt(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	var stamps []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := client.VerifyWebhook(r.Header, "s3cret", body, 0, time.Now()); err != nil {
+			t.Errorf("attempt %d: %v", len(stamps)+1, err)
+		}
+		stamps = append(stamps, r.Header.Get(client.SignatureTimestampHeader))
+		if len(stamps) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	if err := Deliver(context.Background(), server.URL, "s3cret", &Payload{Event: "extraction.completed", AdID: "ad-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stamps) != 2 {
+		t.Errorf("%d attempts, want 2", len(stamps))
+	}
+}
+
+func TestDeliver_UnsignedWithoutSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(client.SignatureHeader) != "" {
+			t.Error("signed without a secret")
+		}
+	}))
+	defer server.Close()
+	if err := Deliver(context.Background(), server.URL, "", &Payload{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeliverPublic_RefusesPrivateAddresses(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	calls := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	err := DeliverPublic(context.Background(), server.URL, "", &Payload{})
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("err = %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("%d calls reached a loopback receiver", calls)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, tc := range []struct {
+		url string
+		ok  bool
+	}{
+		{"https://hooks.example.com/x", true},
+		{"http://hooks.example.com/x", false},
+		{"ftp://example.com", false},
+		{"/relative", false},
+		{"https://", false},
+	} {
+		if err := ValidateURL(tc.url); (err == nil) != tc.ok {
+			t.Errorf("ValidateURL(%q) err = %v, want ok=%v", tc.url, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+// Delivery is one job taken from a Source.
+type Delivery struct {
+	Request handler.ExtractRequest
+
+	// Done is called once the job has finished: with its response to
+	// acknowledge it, or with the job's error so the source may redeliver it.
+	Done func(resp *handler.ExtractResponse, err error)
+}
+
+// Source hands out jobs. Receive blocks until one is available and returns
+// io.EOF once the source is exhausted.
+type Source interface {
+	Receive(ctx context.Context) (*Delivery, error)
+}
+
+// Extractor runs a job, as handler.ExtractHandler does.
+type Extractor interface {
+	Extract(ctx context.Context, body handler.ExtractRequest) (*handler.ExtractResponse, error)
+}
+
+// Run takes jobs from src and runs up to concurrency of them at once,
+// receiving only while a slot is free so that jobs a busy worker cannot
+// start stay with the source for other workers. When ctx ends no more jobs
+// are received and Run waits for those in flight, which keep running to
+// completion. It returns nil once src is exhausted or ctx ends.
+func Run(ctx context.Context, src Source, ex Extractor, concurrency int) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		d, err := src.Receive(ctx)
+		if err != nil {
+			<-sem
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("receive: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// A shutdown drains jobs rather than abandoning them half done
+			resp, err := ex.Extract(context.WithoutCancel(ctx), d.Request)
+			if err != nil {
+				log.Printf("job %s failed: %v", d.Request.AdID, err)
+			} else {
+				log.Printf("job %s done in %.0fms", resp.AdID, resp.ProcessingTimeMs)
+			}
+			if d.Done != nil {
+				d.Done(resp, err)
+			}
+		}()
+	}
+}
+
+// LineSource reads jobs as JSON lines, each an /extract request body. It
+// stands in for a queue: anything that can write to a pipe or file can feed
+// a worker. Lines that do not decode are logged and skipped.
+type LineSource struct {
+	mu sync.Mutex
+	sc *bufio.Scanner
+}
+
+func NewLineSource(r io.Reader) *LineSource {
+	return &LineSource{sc: bufio.NewScanner(r)}
+}
+
+// Receive returns the next job. A read blocked on an idle pipe is not
+// interrupted by ctx.
+func (s *LineSource) Receive(ctx context.Context) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.sc.Scan() {
+		if len(s.sc.Bytes()) == 0 {
+			continue
+		}
+		var req handler.ExtractRequest
+		if err := json.Unmarshal(s.sc.Bytes(), &req); err != nil {
+			log.Printf("WARN: skipping job line: %v", err)
+			continue
+		}
+		return &Delivery{Request: req}, nil
+	}
+	if err := s.sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nikipaj1/video-description-pipeline/internal/handler"
+)
+
+type fakeExtractor struct {
+	mu       sync.Mutex
+	ads      []string
+	running  atomic.Int32
+	maxSeen  atomic.Int32
+	delay    time.Duration
+	failAdID string
+}
+
+func (f *fakeExtractor) Extract(ctx context.Context, body handler.ExtractRequest) (*handler.ExtractResponse, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		m := f.maxSeen.Load()
+		if n <= m || f.maxSeen.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.ads = append(f.ads, body.AdID)
+	f.mu.Unlock()
+	if body.AdID == f.failAdID {
+		return nil, errors.New("boom")
+	}
+	return &handler.ExtractResponse{AdID: body.AdID}, nil
+}
+
+func TestRun_LineSource(t *testing.T) {
+	input := `{"ad_id":"a"}
+
+not json
+{"ad_id":"b","priority":"batch"}
+{"ad_id":"c"}
+`
+	ex := &fakeExtractor{delay: 20 * time.Millisecond}
+	if err := Run(context.Background(), NewLineSource(strings.NewReader(input)), ex, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(ex.ads)
+	if !slices.Equal(ex.ads, []string{"a", "b", "c"}) {
+		t.Errorf("ran %v, want [a b c]", ex.ads)
+	}
+	if m := ex.maxSeen.Load(); m != 2 {
+		t.Errorf("max concurrent jobs = %d, want 2", m)
+	}
+}
+
+type chanSource chan *Delivery
+
+func (c chanSource) Receive(ctx context.Context) (*Delivery, error) {
+	select {
+	case d := <-c:
+		return d, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRun_AcksAndDrainsOnShutdown(t *testing.T) {
+	src := make(chanSource, 2)
+	var mu sync.Mutex
+	results := map[string]error{}
+	for _, id := range []string{"ok", "bad"} {
+		src <- &Delivery{
+			Request: handler.ExtractRequest{AdID: id},
+			Done: func(_ *handler.ExtractResponse, err error) {
+				mu.Lock()
+				results[id] = err
+				mu.Unlock()
+			},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ex := &fakeExtractor{delay: 50 * time.Millisecond, failAdID: "bad"}
+	go func() {
+		// Shut down while both jobs are still running
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := Run(ctx, src, ex, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run returned only after both jobs finished and were acknowledged
+	if len(results) != 2 {
+		t.Fatalf("acknowledged %d jobs, want 2", len(results))
+	}
+	if results["ok"] != nil || results["bad"] == nil {
+		t.Errorf("acks = %v, want ok acked and bad returned with its error", results)
+	}
+}
@@ -0,0 +1,324 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to one pipeline instance.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   func(ctx context.Context) (string, error)
+}
+
+// apiVersion prefixes the paths of the API version this client speaks.
+const apiVersion = "/v1"
+
+// New returns a client for the instance at baseURL, e.g.
+// "http://pipeline:8080". A nil httpClient uses http.DefaultClient; jobs can
+// take minutes, so any client timeout must allow for that.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/") + apiVersion, http: httpClient}
+}
+
+// WithToken returns a copy of c that sends the bearer token token returns
+// with every request: an API key, or an OIDC access token renewed as it
+// expires.
+func (c *Client) WithToken(token func(ctx context.Context) (string, error)) *Client {
+	cc := *c
+	cc.token = token
+	return &cc
+}
+
+// StaticToken is a token function for WithToken that always returns token.
+func StaticToken(token string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) { return token, nil }
+}
+
+// APIError is a request the server answered with an error. Code and
+// Details are those of the server's Error body; a body that is not one,
+// e.g. from a proxy, is kept whole as Message.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    map[string]any
+	RetryAfter time.Duration // server's suggested wait before retrying, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("pipeline: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// Extract runs a job and waits for its result.
+func (c *Client) Extract(ctx context.Context, req ExtractRequest) (*ExtractResponse, error) {
+	resp, err := c.post(ctx, "/extract", req, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out ExtractResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("pipeline: decode response: %w", err)
+	}
+	return &out, nil
+}
+
+// ExtractWithProgress is Extract with the server reporting progress as it
+// goes. onProgress, if not nil, is called with each "progress" event. The
+// server sends heartbeats throughout, so proxies with short idle timeouts
+// do not cut the request off. A job that fails after the stream started is
+// returned as an *APIError with the status it would have had.
+func (c *Client) ExtractWithProgress(ctx context.Context, req ExtractRequest, onProgress func(ProgressEvent)) (*ExtractResponse, error) {
+	resp, err := c.post(ctx, "/extract", req, "application/x-ndjson")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 16<<20) // the result event can be large
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var ev ProgressEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("pipeline: decode progress: %w", err)
+		}
+		switch ev.Event {
+		case "result":
+			if ev.Result == nil {
+				return nil, errors.New("pipeline: result event without a result")
+			}
+			return ev.Result, nil
+		case "error":
+			return nil, &APIError{StatusCode: ev.Status, Message: ev.Error}
+		default:
+			if onProgress != nil {
+				onProgress(ev)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("pipeline: read progress: %w", err)
+	}
+	return nil, errors.New("pipeline: progress stream ended without a result")
+}
+
+// Submit starts a job without waiting for it; follow it with Job, or
+// WaitJob.
+func (c *Client) Submit(ctx context.Context, req ExtractRequest) (*Job, error) {
+	resp, err := c.post(ctx, "/extract?async=true", req, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out Job
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("pipeline: decode response: %w", err)
+	}
+	return &out, nil
+}
+
+// Job returns a submitted job's current state.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var out Job
+	if err := c.get(ctx, "/jobs/"+url.PathEscape(id), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelJob cancels a queued or running job and returns its state. It may
+// still be winding down: WaitJob returns once it is cancelled.
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+	var out Job
+	if err := c.send(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitJob polls a submitted job every interval until it finishes or ctx
+// ends, and returns its last state.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || job.Done() {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DownloadLink returns a link to one of an ad's stored artifacts, e.g.
+// "bundle.zip", that needs no credentials and stops working after ttl. A
+// zero ttl leaves the lifetime to the server.
+func (c *Client) DownloadLink(ctx context.Context, adID, artifact string, ttl time.Duration) (*DownloadLink, error) {
+	q := url.Values{"artifact": {artifact}}
+	if ttl > 0 {
+		q.Set("expires", ttl.String())
+	}
+	var out DownloadLink
+	if err := c.get(ctx, "/results/"+url.PathEscape(adID)+"/download?"+q.Encode(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Results returns every JSON artifact stored for an ad.
+func (c *Client) Results(ctx context.Context, adID string) (*Results, error) {
+	var out Results
+	if err := c.get(ctx, "/results/"+url.PathEscape(adID), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Ads returns a page of up to limit ads with extraction results whose IDs
+// start with prefix; both may be zero. Pass the previous page's NextToken
+// as token to continue, and "" for the first page.
+func (c *Client) Ads(ctx context.Context, prefix, token string, limit int) (*AdList, error) {
+	q := url.Values{}
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	if token != "" {
+		q.Set("token", token)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out AdList
+	if err := c.get(ctx, "/ads?"+q.Encode(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Purge deletes every artifact stored for an ad and returns the deleted
+// keys.
+func (c *Client) Purge(ctx context.Context, adID string) (*Purged, error) {
+	var out Purged
+	if err := c.send(ctx, http.MethodDelete, "/results/"+url.PathEscape(adID), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Transcript decodes an ad's stored asr_results.json into v.
+func (c *Client) Transcript(ctx context.Context, adID string, v any) error {
+	return c.get(ctx, "/results/"+url.PathEscape(adID)+"/asr", v)
+}
+
+// FrameDescriptions decodes an ad's stored vlm_results.json into v.
+func (c *Client) FrameDescriptions(ctx context.Context, adID string, v any) error {
+	return c.get(ctx, "/results/"+url.PathEscape(adID)+"/vlm", v)
+}
+
+// Compare asks the server to compare two extracted ads, such as an
+// original and its variant.
+func (c *Client) Compare(ctx context.Context, req CompareRequest) (*Comparison, error) {
+	resp, err := c.post(ctx, "/compare", req, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out Comparison
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("pipeline: decode response: %w", err)
+	}
+	return &out, nil
+}
+
+// get decodes the JSON response to a GET of path into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	return c.send(ctx, http.MethodGet, path, out)
+}
+
+// send decodes the JSON response to a bodiless request into out.
+func (c *Client) send(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("pipeline: decode response: %w", err)
+	}
+	return nil
+}
+
+// post sends body as JSON and returns a 200 or 202 response, or the error
+// the server answered with.
+func (c *Client) post(ctx context.Context, path string, body any, accept string) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.token != nil {
+		token, err := c.token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	var body Error
+	if json.Unmarshal(msg, &body) == nil && body.Code != "" {
+		apiErr.Code, apiErr.Message, apiErr.Details = body.Code, body.Message, body.Details
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return nil, apiErr
+}
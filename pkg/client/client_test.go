@@ -0,0 +1,338 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/extract" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		var req ExtractRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.AdID != "ad1" || req.Priority != PriorityBatch {
+			t.Errorf("request = %+v", req)
+		}
+		json.NewEncoder(w).Encode(ExtractResponse{
+			AdID:    req.AdID,
+			Streams: []StreamResult{{Stream: "vlm", Status: "success", ResultCount: 3}},
+			Quality: &QualityScore{Score: 0.9},
+		})
+	}))
+	defer server.Close()
+
+	resp, err := New(server.URL+"/", nil).Extract(context.Background(), ExtractRequest{AdID: "ad1", Priority: PriorityBatch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AdID != "ad1" || len(resp.Streams) != 1 || resp.Quality.Score != 0.9 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestExtract_ErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code":"forbidden","message":"forbidden: the key lacks the \"extract\" scope","details":{"scope":"extract"}}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, nil).Extract(context.Background(), ExtractRequest{AdID: "ad1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != 403 || apiErr.Code != CodeForbidden || apiErr.Message != `forbidden: the key lacks the "extract" scope` || apiErr.Details["scope"] != "extract" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestExtract_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "memory budget exhausted", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, nil).Extract(context.Background(), ExtractRequest{AdID: "ad1"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != 503 || apiErr.RetryAfter != 30*time.Second || apiErr.Message != "memory budget exhausted" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer key1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(ExtractResponse{AdID: "ad1"})
+	}))
+	defer server.Close()
+
+	c := New(server.URL, nil)
+	if _, err := c.WithToken(StaticToken("key1")).Extract(context.Background(), ExtractRequest{AdID: "ad1"}); err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *APIError
+	if _, err := c.Extract(context.Background(), ExtractRequest{AdID: "ad1"}); !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Errorf("without a token: %v", err)
+	}
+	failing := func(context.Context) (string, error) { return "", errors.New("token endpoint down") }
+	if _, err := c.WithToken(failing).Extract(context.Background(), ExtractRequest{AdID: "ad1"}); err == nil {
+		t.Error("request sent without its token")
+	}
+}
+
+func TestExtractWithProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		final   string
+		wantErr int // APIError status, 0 for success
+	}{
+		{"result", `{"event":"result","elapsed_ms":30,"result":{"ad_id":"ad1","streams":[]}}`, 0},
+		{"error", `{"event":"error","elapsed_ms":30,"status":500,"error":"download video: boom"}`, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept") != "application/x-ndjson" {
+					t.Errorf("Accept = %q", r.Header.Get("Accept"))
+				}
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				fmt.Fprintln(w, `{"event":"progress","stage":"queued","elapsed_ms":0}`)
+				fmt.Fprintln(w, `{"event":"progress","stage":"extracting","elapsed_ms":10}`)
+				fmt.Fprintln(w, tt.final)
+			}))
+			defer server.Close()
+
+			var stages []string
+			resp, err := New(server.URL, nil).ExtractWithProgress(context.Background(), ExtractRequest{AdID: "ad1"},
+				func(ev ProgressEvent) { stages = append(stages, ev.Stage) })
+			if len(stages) != 2 || stages[1] != "extracting" {
+				t.Errorf("stages = %v", stages)
+			}
+			if tt.wantErr == 0 {
+				if err != nil || resp.AdID != "ad1" {
+					t.Errorf("got %+v, %v", resp, err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantErr {
+				t.Errorf("err = %v, want APIError %d", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractWithProgress_Truncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"event":"progress","stage":"queued","elapsed_ms":0}`)
+	}))
+	defer server.Close()
+
+	if _, err := New(server.URL, nil).ExtractWithProgress(context.Background(), ExtractRequest{AdID: "ad1"}, nil); err == nil {
+		t.Error("stream without a final event should be an error")
+	}
+}
+
+func TestDownloadLink(t *testing.T) {
+	expires := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/results/ad 1/download" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		if q := r.URL.Query(); q.Get("artifact") != "bundle.zip" || q.Get("expires") != "168h0m0s" {
+			t.Errorf("query = %v", q)
+		}
+		json.NewEncoder(w).Encode(DownloadLink{URL: "https://r2.example.com/signed", ExpiresAt: expires})
+	}))
+	defer server.Close()
+
+	link, err := New(server.URL, nil).DownloadLink(context.Background(), "ad 1", "bundle.zip", 7*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.URL != "https://r2.example.com/signed" || !link.ExpiresAt.Equal(expires) {
+		t.Errorf("link = %+v", link)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/compare" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		var req CompareRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(Comparison{
+			AdA:         req.AdA,
+			AdB:         req.AdB,
+			PacingB:     Pacing{DurationSec: 15, Shots: 6},
+			Differences: []Difference{{Aspect: "offer", A: "10% off", B: "free shipping"}},
+		})
+	}))
+	defer server.Close()
+
+	cmp, err := New(server.URL, nil).Compare(context.Background(), CompareRequest{AdA: "orig", AdB: "variant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.AdA != "orig" || cmp.AdB != "variant" || cmp.PacingB.Shots != 6 || len(cmp.Differences) != 1 {
+		t.Errorf("comparison = %+v", cmp)
+	}
+}
+
+func TestSubmitAndWaitJob(t *testing.T) {
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/extract":
+			if r.URL.Query().Get("async") != "true" {
+				t.Errorf("query = %s", r.URL.RawQuery)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(Job{ID: "j1", AdID: "ad1", Status: "queued"})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/jobs/j1":
+			polls++
+			job := Job{ID: "j1", AdID: "ad1", Status: "running", Progress: 0.5}
+			if polls == 3 {
+				job.Status, job.Progress = "succeeded", 1
+				job.Result = &ExtractResponse{AdID: "ad1"}
+			}
+			json.NewEncoder(w).Encode(job)
+		default:
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, nil)
+	job, err := c.Submit(context.Background(), ExtractRequest{AdID: "ad1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "j1" || job.Done() {
+		t.Errorf("submitted = %+v", job)
+	}
+	job, err = c.WaitJob(context.Background(), job.ID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "succeeded" || job.Result == nil || polls != 3 {
+		t.Errorf("job = %+v after %d polls", job, polls)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/jobs/j1":
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(Job{ID: "j1", Status: "running"})
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/jobs/j2":
+			http.Error(w, "job already succeeded", http.StatusConflict)
+		default:
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, nil)
+	job, err := c.CancelJob(context.Background(), "j1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "j1" {
+		t.Errorf("job = %+v", job)
+	}
+	if _, err := c.CancelJob(context.Background(), "j2"); err == nil {
+		t.Error("cancelling a finished job succeeded")
+	}
+	if !(&Job{Status: "cancelled"}).Done() {
+		t.Error("cancelled job is not done")
+	}
+}
+
+func TestAds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/ads" || r.URL.RawQuery != "limit=2&prefix=ad&token=ad2" {
+			t.Errorf("got %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"ads":[{"ad_id":"ad3","streams":{"asr":"2025-01-02T03:04:05Z"},"updated_at":"2025-01-02T03:04:05Z"}]}`))
+	}))
+	defer server.Close()
+
+	list, err := New(server.URL, nil).Ads(context.Background(), "ad", "ad2", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Ads) != 1 || list.Ads[0].Streams["asr"].IsZero() || list.NextToken != "" {
+		t.Errorf("list = %+v", list)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/v1/results/ad1" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"ad_id":"ad1","deleted":["ads/ad1/extraction/asr_results.json"]}`))
+	}))
+	defer server.Close()
+
+	purged, err := New(server.URL, nil).Purge(context.Background(), "ad1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged.Deleted) != 1 || purged.Deleted[0] != "ads/ad1/extraction/asr_results.json" {
+		t.Errorf("purged = %+v", purged)
+	}
+}
+
+func TestResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/results/ad1":
+			w.Write([]byte(`{"ad_id":"ad1","artifacts":{"asr_results":{"transcript":"hi"}}}`))
+		case "/v1/results/ad1/asr":
+			w.Write([]byte(`{"transcript":"hi"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, nil)
+	res, err := c.Results(context.Background(), "ad1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Artifacts["asr_results"]) != `{"transcript":"hi"}` {
+		t.Errorf("results = %+v", res)
+	}
+	var asr struct {
+		Transcript string `json:"transcript"`
+	}
+	if err := c.Transcript(context.Background(), "ad1", &asr); err != nil || asr.Transcript != "hi" {
+		t.Errorf("transcript = %+v, %v", asr, err)
+	}
+	var apiErr *APIError
+	if err := c.FrameDescriptions(context.Background(), "ad1", &asr); !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("vlm err = %v", err)
+	}
+}
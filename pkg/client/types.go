@@ -0,0 +1,295 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Wire types of the extraction API. The server uses these same types, so
+// they cannot drift from what it sends.
+
+// ExtractRequest is the body of POST /extract.
+type ExtractRequest struct {
+	AdID         string `json:"ad_id"`
+	Bundle       *bool  `json:"bundle,omitempty"`       // overrides BUNDLE_ARTIFACTS
+	WebhookURL   string `json:"webhook_url,omitempty"`  // overrides WEBHOOK_URL; https, public hosts only
+	MaxFrames    *int   `json:"max_frames,omitempty"`   // overrides VLM_MAX_FRAMES
+	Multilingual *bool  `json:"multilingual,omitempty"` // overrides VLM_MULTILINGUAL
+	Priority     string `json:"priority,omitempty"`     // PriorityInteractive (default) or PriorityBatch
+	Tenant       string `json:"tenant,omitempty"`       // must match a tenant-bound key; ignored for other credentials
+
+	// QueuePriority orders jobs waiting for a worker slot: higher runs
+	// first, equal in arrival order. From -10 to 10, default 0; backfills
+	// use QueuePriorityBackfill so on-demand jobs overtake them
+	QueuePriority int `json:"queue_priority,omitempty"`
+
+	// VideoURL, if set, is an https URL the ad's video is downloaded from
+	// and stored in R2 under AdID before the job runs, unless the ad
+	// already has a video and Force is not set
+	VideoURL string `json:"video_url,omitempty"`
+
+	// Streams, if set, restricts the job to these streams, e.g. ["vlm"] to
+	// redo frame descriptions without transcribing again. Other streams'
+	// stored results are left as they are and read where needed.
+	Streams []string `json:"streams,omitempty"`
+
+	// Force runs every stream again, even those whose results are already
+	// stored; otherwise they are reported "cached". Streams named in
+	// Streams always run again.
+	Force bool `json:"force,omitempty"`
+
+	// VLMPromptTemplate, if set, replaces the prompt frames are described
+	// with for this job. It must format the previous frame's context with
+	// %s and then the frame's timestamp in seconds with a float verb such as
+	// %.1f; frames are then described one per request. Stored frame
+	// descriptions are not reused.
+	VLMPromptTemplate string `json:"vlm_prompt_template,omitempty"`
+
+	// Language is the BCP 47 code of the ad's spoken language, e.g. "es" or
+	// "pt-BR", or "multi" for ads that switch languages; without it the
+	// language is detected. Stored transcripts are not reused.
+	Language string `json:"language,omitempty"`
+
+	// ASRModel and VLMModel choose the Deepgram and Gemini models for this
+	// job, e.g. "nova-2" or "gemini-1.5-pro", from those the server allows
+	// (DEEPGRAM_MODELS, GEMINI_MODELS). Stored results of the stream are
+	// not reused.
+	ASRModel string `json:"asr_model,omitempty"`
+	VLMModel string `json:"vlm_model,omitempty"`
+
+	// DeepgramParams set Deepgram query parameters on top of DEEPGRAM_PARAMS,
+	// e.g. {"diarize": "true"}; an empty value removes one
+	DeepgramParams map[string]string `json:"deepgram_params,omitempty"`
+
+	// DryRun reads the ad's video and keyframes from R2 and reports what
+	// the job would do, in ExtractResponse.DryRun and as stream statuses
+	// "would_run", "cached" or "skipped", without calling Deepgram or
+	// Gemini or storing anything
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// IncludeResults embeds the asr and vlm outputs, as stored in R2, in
+	// their StreamResult, sparing a GET of each for small ads
+	IncludeResults bool `json:"include_results,omitempty"`
+
+	// Mode ModeFillMissing runs only the streams whose results are not
+	// stored, e.g. to retry a job some streams of which failed. It may not
+	// be combined with Force.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Job priorities. Batch jobs describe frames through Gemini's Batch API at
+// about half the cost, taking up to GEMINI_BATCH_TIMEOUT.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// ModeFillMissing, as ExtractRequest.Mode, keeps every stored result,
+// whatever the request's models or prompt and whether or not what it was
+// derived from runs again, and runs only the missing streams.
+const ModeFillMissing = "fill-missing"
+
+// Bounds of ExtractRequest.QueuePriority, and the priority backfills queue at.
+const (
+	QueuePriorityMin      = -10
+	QueuePriorityMax      = 10
+	QueuePriorityBackfill = -5
+)
+
+// StreamResult is the outcome of one stream of a job.
+type StreamResult struct {
+	Stream      string          `json:"stream"`
+	Status      string          `json:"status"` // "success" | "partial" | "cached" | "error" | "skipped" | "unavailable", or "would_run" in dry runs
+	ResultCount int             `json:"result_count"`
+	R2Key       string          `json:"r2_key,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"` // the output at R2Key, with include_results
+}
+
+// ExtractResponse is the result of a finished job.
+type ExtractResponse struct {
+	AdID             string         `json:"ad_id"`
+	Partial          bool           `json:"partial,omitempty"` // the job deadline cut some streams short
+	Streams          []StreamResult `json:"streams"`
+	Quality          *QualityScore  `json:"quality"`
+	ProcessingTimeMs float64        `json:"processing_time_ms"`
+	GeminiTokens     int64          `json:"gemini_tokens,omitempty"` // used by the job's streams
+
+	// Quarantined is set when the content_rating stream rated the ad at or
+	// above the server's quarantine threshold; hold it back for review
+	Quarantined bool `json:"quarantined,omitempty"`
+
+	DryRun *DryRun `json:"dry_run,omitempty"` // for dry runs only
+}
+
+// DryRun is what a dry run found: the job's inputs and what describing its
+// frames would cost. FramesDescribed and GeminiCalls are 0 unless the vlm
+// stream would run.
+type DryRun struct {
+	VideoBytes          int64 `json:"video_bytes"`
+	Keyframes           int   `json:"keyframes"`                      // in the ad's keyframe metadata
+	UnreadableKeyframes int   `json:"unreadable_keyframes,omitempty"` // listed but failing to download
+	FramesDescribed     int   `json:"frames_described"`               // those max_frames keeps
+	GeminiCalls         int   `json:"gemini_calls"`                   // estimated requests to describe them
+}
+
+// QualityScore rates a job's output; Flagged jobs scored below the
+// server's review threshold for the listed reasons.
+type QualityScore struct {
+	Score          float64  `json:"score"`
+	VLMErrorRate   float64  `json:"vlm_error_rate"`
+	VLMBlocked     int      `json:"vlm_blocked_frames"`
+	ASRConfidence  float64  `json:"asr_confidence"`
+	SpeechCoverage float64  `json:"speech_coverage"`
+	Flagged        bool     `json:"flagged"`
+	Reasons        []string `json:"reasons,omitempty"`
+}
+
+// ProgressEvent is one line of a /extract progress stream. Every stream
+// ends with a single "result" or "error" event.
+type ProgressEvent struct {
+	Event     string           `json:"event"` // "progress" | "stream" | "frame" | "result" | "error"
+	Stage     string           `json:"stage,omitempty"`
+	ElapsedMs int64            `json:"elapsed_ms"`
+	Status    int              `json:"status,omitempty"` // HTTP status the error would have had
+	Error     string           `json:"error,omitempty"`
+	Result    *ExtractResponse `json:"result,omitempty"`
+
+	// "stream" and "frame" events, sent by the gRPC ExtractStream only: a
+	// stream started ("running") or finished, and a keyframe described
+	Stream      *StreamResult `json:"stream,omitempty"`
+	FramesDone  int           `json:"frames_done,omitempty"`
+	FramesTotal int           `json:"frames_total,omitempty"`
+}
+
+// Job is an asynchronous extraction, as POST /extract?async=true accepts it
+// and GET /jobs/{id} reports it.
+type Job struct {
+	ID     string `json:"job_id"`
+	AdID   string `json:"ad_id"`
+	Status string `json:"status"` // "queued" | "running" | "succeeded" | "failed" | "cancelled"
+	Stage  string `json:"stage,omitempty"`
+
+	// Streams lists every stream the job runs, "pending" or "running" until
+	// it has a StreamResult status ("cancelled" if the job was), and
+	// Progress the share that finished
+	Streams  []StreamResult `json:"streams"`
+	Progress float64        `json:"progress"`
+
+	Result    *ExtractResponse `json:"result,omitempty"` // once succeeded, or cancelled part way
+	Error     string           `json:"error,omitempty"`  // once failed or cancelled
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Done reports whether the job has finished, successfully or not, or was
+// cancelled.
+func (j *Job) Done() bool {
+	return j.Status == "succeeded" || j.Status == "failed" || j.Status == "cancelled"
+}
+
+// Error is the body of every error response. Code names the kind of error,
+// one of the Code constants, and Details, when set, gives specifics such as
+// the scope a key lacks.
+type Error struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Error codes, each sent with one HTTP status.
+const (
+	CodeInvalidRequest   = "invalid_request"    // 400
+	CodeUnauthenticated  = "unauthenticated"    // 401
+	CodeForbidden        = "forbidden"          // 403
+	CodeNotFound         = "not_found"          // 404
+	CodeMethodNotAllowed = "method_not_allowed" // 405
+	CodeConflict         = "conflict"           // 409
+	CodeTooLarge         = "too_large"          // 413
+	CodeUnprocessable    = "unprocessable"      // 422
+	CodeRateLimited      = "rate_limited"       // 429
+	CodeInternal         = "internal"           // 500
+	CodeUpstream         = "upstream_error"     // 502: R2 or a provider failed
+	CodeUnavailable      = "unavailable"        // 503
+	CodeTimeout          = "timeout"            // 504
+)
+
+// Results is the body of a GET /results/{ad_id} response: each JSON
+// artifact stored for the ad, as stored, by its file name without ".json",
+// e.g. "asr_results".
+type Results struct {
+	AdID      string                     `json:"ad_id"`
+	Artifacts map[string]json.RawMessage `json:"artifacts"`
+}
+
+// AdList is the body of a GET /ads response: a page of the ads with
+// extraction results. NextToken, while set, fetches the next page.
+type AdList struct {
+	Ads       []AdSummary `json:"ads"`
+	NextToken string      `json:"next_token,omitempty"`
+}
+
+// AdSummary is an ad's stored results: when each stream's was written, by
+// stream name, and the latest of any result file.
+type AdSummary struct {
+	AdID      string               `json:"ad_id"`
+	Streams   map[string]time.Time `json:"streams"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// Purged is the body of a DELETE /results/{ad_id} response: the keys
+// removed from R2.
+type Purged struct {
+	AdID    string   `json:"ad_id"`
+	Deleted []string `json:"deleted"`
+}
+
+// DownloadLink is the body of a GET /results/{ad_id}/download response: a
+// URL anyone can fetch the artifact from until ExpiresAt.
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CompareRequest is the body of a POST /compare request. Both ads must have
+// been extracted.
+type CompareRequest struct {
+	AdA string `json:"ad_a"`
+	AdB string `json:"ad_b"`
+}
+
+// Comparison is the body of a POST /compare response. Pacing is measured
+// from the ads' timelines; the other fields are the model's reading.
+type Comparison struct {
+	AdA            string           `json:"ad_a"`
+	AdB            string           `json:"ad_b"`
+	PacingA        Pacing           `json:"pacing_a"`
+	PacingB        Pacing           `json:"pacing_b"`
+	SharedElements []string         `json:"shared_elements"`
+	Hook           AspectComparison `json:"hook"`
+	Pacing         AspectComparison `json:"pacing"`
+	Differences    []Difference     `json:"differences"`
+	Summary        string           `json:"summary"`
+}
+
+// Pacing is how densely an ad packs its shots and words.
+type Pacing struct {
+	DurationSec    float64 `json:"duration_sec"`
+	Shots          int     `json:"shots"`
+	SecPerShot     float64 `json:"sec_per_shot"`
+	WordsPerMinute float64 `json:"words_per_minute"`
+}
+
+// AspectComparison describes one aspect in each ad and how they differ.
+type AspectComparison struct {
+	A          string `json:"a"`
+	B          string `json:"b"`
+	Difference string `json:"difference"`
+}
+
+// Difference is any other aspect in which the compared ads differ.
+type Difference struct {
+	Aspect string `json:"aspect"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signature headers. X-Signature is "sha256=" and the hex
+// HMAC-SHA256, keyed with the shared secret, of the X-Signature-Timestamp
+// value (Unix seconds), a ".", and the raw body.
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// DefaultWebhookTolerance is how old a webhook's timestamp may be before
+// VerifyWebhook treats it as a replay.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// SignWebhook sets the signature headers of a webhook request carrying
+// body, as sent at now.
+func SignWebhook(h http.Header, secret string, body []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	h.Set(SignatureTimestampHeader, ts)
+	h.Set(SignatureHeader, "sha256="+webhookMAC(secret, ts, body))
+}
+
+// VerifyWebhook checks that a webhook carrying body was signed with secret
+// no more than tolerance before now (DefaultWebhookTolerance if zero).
+// Receivers should verify the raw body before decoding it.
+func VerifyWebhook(h http.Header, secret string, body []byte, tolerance time.Duration, now time.Time) error {
+	if tolerance == 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	ts := h.Get(SignatureTimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("webhook: missing or invalid " + SignatureTimestampHeader)
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return errors.New("webhook: timestamp outside the replay window")
+	}
+	sig, ok := strings.CutPrefix(h.Get(SignatureHeader), "sha256=")
+	if !ok || !hmac.Equal([]byte(sig), []byte(webhookMAC(secret, ts, body))) {
+		return errors.New("webhook: signature mismatch")
+	}
+	return nil
+}
+
+func webhookMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
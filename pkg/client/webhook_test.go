@@ -0,0 +1,46 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"event":"extraction.completed","ad_id":"ad1"}`)
+	sent := time.Unix(1_760_000_000, 0)
+	h := http.Header{}
+	SignWebhook(h, "s3cret", body, sent)
+
+	if got := h.Get(SignatureTimestampHeader); got != "1760000000" {
+		t.Errorf("timestamp = %q", got)
+	}
+	if err := VerifyWebhook(h, "s3cret", body, 0, sent.Add(time.Minute)); err != nil {
+		t.Fatalf("valid webhook: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		secret string
+		body   []byte
+		now    time.Time
+		header http.Header
+	}{
+		"wrong secret":    {"other", body, sent, h},
+		"altered body":    {"s3cret", []byte(`{"event":"extraction.completed","ad_id":"ad2"}`), sent, h},
+		"replayed":        {"s3cret", body, sent.Add(6 * time.Minute), h},
+		"from the future": {"s3cret", body, sent.Add(-6 * time.Minute), h},
+		"unsigned":        {"s3cret", body, sent, http.Header{}},
+		"moved timestamp": {"s3cret", body, sent, func() http.Header {
+			moved := h.Clone()
+			moved.Set(SignatureTimestampHeader, "1760000060")
+			return moved
+		}()},
+	} {
+		if err := VerifyWebhook(tc.header, tc.secret, tc.body, 0, tc.now); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+	if err := VerifyWebhook(h, "s3cret", body, time.Hour, sent.Add(30*time.Minute)); err != nil {
+		t.Errorf("within a custom tolerance: %v", err)
+	}
+}
@@ -0,0 +1,1101 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: pkg/pipelinepb/pipeline.proto
+
+package pipelinepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ExtractRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	AdId              string                 `protobuf:"bytes,1,opt,name=ad_id,json=adId,proto3" json:"ad_id,omitempty"`
+	Bundle            *bool                  `protobuf:"varint,2,opt,name=bundle,proto3,oneof" json:"bundle,omitempty"`
+	WebhookUrl        string                 `protobuf:"bytes,3,opt,name=webhook_url,json=webhookUrl,proto3" json:"webhook_url,omitempty"`
+	MaxFrames         *int32                 `protobuf:"varint,4,opt,name=max_frames,json=maxFrames,proto3,oneof" json:"max_frames,omitempty"`
+	Multilingual      *bool                  `protobuf:"varint,5,opt,name=multilingual,proto3,oneof" json:"multilingual,omitempty"`
+	Priority          string                 `protobuf:"bytes,6,opt,name=priority,proto3" json:"priority,omitempty"`
+	Tenant            string                 `protobuf:"bytes,7,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	QueuePriority     int32                  `protobuf:"varint,8,opt,name=queue_priority,json=queuePriority,proto3" json:"queue_priority,omitempty"`
+	VideoUrl          string                 `protobuf:"bytes,9,opt,name=video_url,json=videoUrl,proto3" json:"video_url,omitempty"`
+	Streams           []string               `protobuf:"bytes,10,rep,name=streams,proto3" json:"streams,omitempty"`
+	Force             bool                   `protobuf:"varint,11,opt,name=force,proto3" json:"force,omitempty"`
+	VlmPromptTemplate string                 `protobuf:"bytes,12,opt,name=vlm_prompt_template,json=vlmPromptTemplate,proto3" json:"vlm_prompt_template,omitempty"`
+	Language          string                 `protobuf:"bytes,13,opt,name=language,proto3" json:"language,omitempty"`
+	AsrModel          string                 `protobuf:"bytes,14,opt,name=asr_model,json=asrModel,proto3" json:"asr_model,omitempty"`
+	VlmModel          string                 `protobuf:"bytes,15,opt,name=vlm_model,json=vlmModel,proto3" json:"vlm_model,omitempty"`
+	DeepgramParams    map[string]string      `protobuf:"bytes,16,rep,name=deepgram_params,json=deepgramParams,proto3" json:"deepgram_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DryRun            bool                   `protobuf:"varint,17,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	IncludeResults    bool                   `protobuf:"varint,18,opt,name=include_results,json=includeResults,proto3" json:"include_results,omitempty"`
+	Mode              string                 `protobuf:"bytes,19,opt,name=mode,proto3" json:"mode,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ExtractRequest) Reset() {
+	*x = ExtractRequest{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractRequest) ProtoMessage() {}
+
+func (x *ExtractRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractRequest.ProtoReflect.Descriptor instead.
+func (*ExtractRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExtractRequest) GetAdId() string {
+	if x != nil {
+		return x.AdId
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetBundle() bool {
+	if x != nil && x.Bundle != nil {
+		return *x.Bundle
+	}
+	return false
+}
+
+func (x *ExtractRequest) GetWebhookUrl() string {
+	if x != nil {
+		return x.WebhookUrl
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetMaxFrames() int32 {
+	if x != nil && x.MaxFrames != nil {
+		return *x.MaxFrames
+	}
+	return 0
+}
+
+func (x *ExtractRequest) GetMultilingual() bool {
+	if x != nil && x.Multilingual != nil {
+		return *x.Multilingual
+	}
+	return false
+}
+
+func (x *ExtractRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetQueuePriority() int32 {
+	if x != nil {
+		return x.QueuePriority
+	}
+	return 0
+}
+
+func (x *ExtractRequest) GetVideoUrl() string {
+	if x != nil {
+		return x.VideoUrl
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetStreams() []string {
+	if x != nil {
+		return x.Streams
+	}
+	return nil
+}
+
+func (x *ExtractRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+func (x *ExtractRequest) GetVlmPromptTemplate() string {
+	if x != nil {
+		return x.VlmPromptTemplate
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetAsrModel() string {
+	if x != nil {
+		return x.AsrModel
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetVlmModel() string {
+	if x != nil {
+		return x.VlmModel
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetDeepgramParams() map[string]string {
+	if x != nil {
+		return x.DeepgramParams
+	}
+	return nil
+}
+
+func (x *ExtractRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ExtractRequest) GetIncludeResults() bool {
+	if x != nil {
+		return x.IncludeResults
+	}
+	return false
+}
+
+func (x *ExtractRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+type StreamResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stream        string                 `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	ResultCount   int32                  `protobuf:"varint,3,opt,name=result_count,json=resultCount,proto3" json:"result_count,omitempty"`
+	R2Key         string                 `protobuf:"bytes,4,opt,name=r2_key,json=r2Key,proto3" json:"r2_key,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	Result        []byte                 `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"` // the JSON output, with include_results
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamResult) Reset() {
+	*x = StreamResult{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamResult) ProtoMessage() {}
+
+func (x *StreamResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamResult.ProtoReflect.Descriptor instead.
+func (*StreamResult) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamResult) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *StreamResult) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StreamResult) GetResultCount() int32 {
+	if x != nil {
+		return x.ResultCount
+	}
+	return 0
+}
+
+func (x *StreamResult) GetR2Key() string {
+	if x != nil {
+		return x.R2Key
+	}
+	return ""
+}
+
+func (x *StreamResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *StreamResult) GetResult() []byte {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type QualityScore struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Score            float64                `protobuf:"fixed64,1,opt,name=score,proto3" json:"score,omitempty"`
+	VlmErrorRate     float64                `protobuf:"fixed64,2,opt,name=vlm_error_rate,json=vlmErrorRate,proto3" json:"vlm_error_rate,omitempty"`
+	VlmBlockedFrames int32                  `protobuf:"varint,3,opt,name=vlm_blocked_frames,json=vlmBlockedFrames,proto3" json:"vlm_blocked_frames,omitempty"`
+	AsrConfidence    float64                `protobuf:"fixed64,4,opt,name=asr_confidence,json=asrConfidence,proto3" json:"asr_confidence,omitempty"`
+	SpeechCoverage   float64                `protobuf:"fixed64,5,opt,name=speech_coverage,json=speechCoverage,proto3" json:"speech_coverage,omitempty"`
+	Flagged          bool                   `protobuf:"varint,6,opt,name=flagged,proto3" json:"flagged,omitempty"`
+	Reasons          []string               `protobuf:"bytes,7,rep,name=reasons,proto3" json:"reasons,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *QualityScore) Reset() {
+	*x = QualityScore{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QualityScore) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QualityScore) ProtoMessage() {}
+
+func (x *QualityScore) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QualityScore.ProtoReflect.Descriptor instead.
+func (*QualityScore) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *QualityScore) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *QualityScore) GetVlmErrorRate() float64 {
+	if x != nil {
+		return x.VlmErrorRate
+	}
+	return 0
+}
+
+func (x *QualityScore) GetVlmBlockedFrames() int32 {
+	if x != nil {
+		return x.VlmBlockedFrames
+	}
+	return 0
+}
+
+func (x *QualityScore) GetAsrConfidence() float64 {
+	if x != nil {
+		return x.AsrConfidence
+	}
+	return 0
+}
+
+func (x *QualityScore) GetSpeechCoverage() float64 {
+	if x != nil {
+		return x.SpeechCoverage
+	}
+	return 0
+}
+
+func (x *QualityScore) GetFlagged() bool {
+	if x != nil {
+		return x.Flagged
+	}
+	return false
+}
+
+func (x *QualityScore) GetReasons() []string {
+	if x != nil {
+		return x.Reasons
+	}
+	return nil
+}
+
+type ExtractResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	AdId             string                 `protobuf:"bytes,1,opt,name=ad_id,json=adId,proto3" json:"ad_id,omitempty"`
+	Partial          bool                   `protobuf:"varint,2,opt,name=partial,proto3" json:"partial,omitempty"`
+	Streams          []*StreamResult        `protobuf:"bytes,3,rep,name=streams,proto3" json:"streams,omitempty"`
+	Quality          *QualityScore          `protobuf:"bytes,4,opt,name=quality,proto3" json:"quality,omitempty"`
+	ProcessingTimeMs float64                `protobuf:"fixed64,5,opt,name=processing_time_ms,json=processingTimeMs,proto3" json:"processing_time_ms,omitempty"`
+	GeminiTokens     int64                  `protobuf:"varint,6,opt,name=gemini_tokens,json=geminiTokens,proto3" json:"gemini_tokens,omitempty"`
+	Quarantined      bool                   `protobuf:"varint,7,opt,name=quarantined,proto3" json:"quarantined,omitempty"`
+	DryRun           *DryRun                `protobuf:"bytes,8,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ExtractResponse) Reset() {
+	*x = ExtractResponse{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractResponse) ProtoMessage() {}
+
+func (x *ExtractResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractResponse.ProtoReflect.Descriptor instead.
+func (*ExtractResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ExtractResponse) GetAdId() string {
+	if x != nil {
+		return x.AdId
+	}
+	return ""
+}
+
+func (x *ExtractResponse) GetPartial() bool {
+	if x != nil {
+		return x.Partial
+	}
+	return false
+}
+
+func (x *ExtractResponse) GetStreams() []*StreamResult {
+	if x != nil {
+		return x.Streams
+	}
+	return nil
+}
+
+func (x *ExtractResponse) GetQuality() *QualityScore {
+	if x != nil {
+		return x.Quality
+	}
+	return nil
+}
+
+func (x *ExtractResponse) GetProcessingTimeMs() float64 {
+	if x != nil {
+		return x.ProcessingTimeMs
+	}
+	return 0
+}
+
+func (x *ExtractResponse) GetGeminiTokens() int64 {
+	if x != nil {
+		return x.GeminiTokens
+	}
+	return 0
+}
+
+func (x *ExtractResponse) GetQuarantined() bool {
+	if x != nil {
+		return x.Quarantined
+	}
+	return false
+}
+
+func (x *ExtractResponse) GetDryRun() *DryRun {
+	if x != nil {
+		return x.DryRun
+	}
+	return nil
+}
+
+type DryRun struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	VideoBytes          int64                  `protobuf:"varint,1,opt,name=video_bytes,json=videoBytes,proto3" json:"video_bytes,omitempty"`
+	Keyframes           int32                  `protobuf:"varint,2,opt,name=keyframes,proto3" json:"keyframes,omitempty"`
+	UnreadableKeyframes int32                  `protobuf:"varint,3,opt,name=unreadable_keyframes,json=unreadableKeyframes,proto3" json:"unreadable_keyframes,omitempty"`
+	FramesDescribed     int32                  `protobuf:"varint,4,opt,name=frames_described,json=framesDescribed,proto3" json:"frames_described,omitempty"`
+	GeminiCalls         int32                  `protobuf:"varint,5,opt,name=gemini_calls,json=geminiCalls,proto3" json:"gemini_calls,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *DryRun) Reset() {
+	*x = DryRun{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRun) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRun) ProtoMessage() {}
+
+func (x *DryRun) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRun.ProtoReflect.Descriptor instead.
+func (*DryRun) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DryRun) GetVideoBytes() int64 {
+	if x != nil {
+		return x.VideoBytes
+	}
+	return 0
+}
+
+func (x *DryRun) GetKeyframes() int32 {
+	if x != nil {
+		return x.Keyframes
+	}
+	return 0
+}
+
+func (x *DryRun) GetUnreadableKeyframes() int32 {
+	if x != nil {
+		return x.UnreadableKeyframes
+	}
+	return 0
+}
+
+func (x *DryRun) GetFramesDescribed() int32 {
+	if x != nil {
+		return x.FramesDescribed
+	}
+	return 0
+}
+
+func (x *DryRun) GetGeminiCalls() int32 {
+	if x != nil {
+		return x.GeminiCalls
+	}
+	return 0
+}
+
+type ProgressEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         string                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"` // "progress" | "stream" | "frame" | "result"
+	Stage         string                 `protobuf:"bytes,2,opt,name=stage,proto3" json:"stage,omitempty"`
+	ElapsedMs     int64                  `protobuf:"varint,3,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	Result        *ExtractResponse       `protobuf:"bytes,4,opt,name=result,proto3" json:"result,omitempty"`
+	Stream        *StreamResult          `protobuf:"bytes,5,opt,name=stream,proto3" json:"stream,omitempty"`
+	FramesDone    int32                  `protobuf:"varint,6,opt,name=frames_done,json=framesDone,proto3" json:"frames_done,omitempty"`
+	FramesTotal   int32                  `protobuf:"varint,7,opt,name=frames_total,json=framesTotal,proto3" json:"frames_total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProgressEvent) Reset() {
+	*x = ProgressEvent{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProgressEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressEvent) ProtoMessage() {}
+
+func (x *ProgressEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressEvent.ProtoReflect.Descriptor instead.
+func (*ProgressEvent) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProgressEvent) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetResult() *ExtractResponse {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetStream() *StreamResult {
+	if x != nil {
+		return x.Stream
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetFramesDone() int32 {
+	if x != nil {
+		return x.FramesDone
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetFramesTotal() int32 {
+	if x != nil {
+		return x.FramesTotal
+	}
+	return 0
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	AdId          string                 `protobuf:"bytes,2,opt,name=ad_id,json=adId,proto3" json:"ad_id,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Stage         string                 `protobuf:"bytes,4,opt,name=stage,proto3" json:"stage,omitempty"`
+	Streams       []*StreamResult        `protobuf:"bytes,5,rep,name=streams,proto3" json:"streams,omitempty"`
+	Progress      float64                `protobuf:"fixed64,6,opt,name=progress,proto3" json:"progress,omitempty"`
+	Result        *ExtractResponse       `protobuf:"bytes,7,opt,name=result,proto3" json:"result,omitempty"`
+	Error         string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Job) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Job) GetAdId() string {
+	if x != nil {
+		return x.AdId
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *Job) GetStreams() []*StreamResult {
+	if x != nil {
+		return x.Streams
+	}
+	return nil
+}
+
+func (x *Job) GetProgress() float64 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Job) GetResult() *ExtractResponse {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type GetResultsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AdId          string                 `protobuf:"bytes,1,opt,name=ad_id,json=adId,proto3" json:"ad_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResultsRequest) Reset() {
+	*x = GetResultsRequest{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResultsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResultsRequest) ProtoMessage() {}
+
+func (x *GetResultsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResultsRequest.ProtoReflect.Descriptor instead.
+func (*GetResultsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetResultsRequest) GetAdId() string {
+	if x != nil {
+		return x.AdId
+	}
+	return ""
+}
+
+type Results struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	AdId  string                 `protobuf:"bytes,1,opt,name=ad_id,json=adId,proto3" json:"ad_id,omitempty"`
+	// Each JSON artifact, as stored, by its file name without ".json"
+	Artifacts     map[string][]byte `protobuf:"bytes,2,rep,name=artifacts,proto3" json:"artifacts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Results) Reset() {
+	*x = Results{}
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Results) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Results) ProtoMessage() {}
+
+func (x *Results) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_pipelinepb_pipeline_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Results.ProtoReflect.Descriptor instead.
+func (*Results) Descriptor() ([]byte, []int) {
+	return file_pkg_pipelinepb_pipeline_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Results) GetAdId() string {
+	if x != nil {
+		return x.AdId
+	}
+	return ""
+}
+
+func (x *Results) GetArtifacts() map[string][]byte {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+var File_pkg_pipelinepb_pipeline_proto protoreflect.FileDescriptor
+
+const file_pkg_pipelinepb_pipeline_proto_rawDesc = "" +
+	"\n" +
+	"\x1dpkg/pipelinepb/pipeline.proto\x12\vpipeline.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfc\x05\n" +
+	"\x0eExtractRequest\x12\x13\n" +
+	"\x05ad_id\x18\x01 \x01(\tR\x04adId\x12\x1b\n" +
+	"\x06bundle\x18\x02 \x01(\bH\x00R\x06bundle\x88\x01\x01\x12\x1f\n" +
+	"\vwebhook_url\x18\x03 \x01(\tR\n" +
+	"webhookUrl\x12\"\n" +
+	"\n" +
+	"max_frames\x18\x04 \x01(\x05H\x01R\tmaxFrames\x88\x01\x01\x12'\n" +
+	"\fmultilingual\x18\x05 \x01(\bH\x02R\fmultilingual\x88\x01\x01\x12\x1a\n" +
+	"\bpriority\x18\x06 \x01(\tR\bpriority\x12\x16\n" +
+	"\x06tenant\x18\a \x01(\tR\x06tenant\x12%\n" +
+	"\x0equeue_priority\x18\b \x01(\x05R\rqueuePriority\x12\x1b\n" +
+	"\tvideo_url\x18\t \x01(\tR\bvideoUrl\x12\x18\n" +
+	"\astreams\x18\n" +
+	" \x03(\tR\astreams\x12\x14\n" +
+	"\x05force\x18\v \x01(\bR\x05force\x12.\n" +
+	"\x13vlm_prompt_template\x18\f \x01(\tR\x11vlmPromptTemplate\x12\x1a\n" +
+	"\blanguage\x18\r \x01(\tR\blanguage\x12\x1b\n" +
+	"\tasr_model\x18\x0e \x01(\tR\basrModel\x12\x1b\n" +
+	"\tvlm_model\x18\x0f \x01(\tR\bvlmModel\x12X\n" +
+	"\x0fdeepgram_params\x18\x10 \x03(\v2/.pipeline.v1.ExtractRequest.DeepgramParamsEntryR\x0edeepgramParams\x12\x17\n" +
+	"\adry_run\x18\x11 \x01(\bR\x06dryRun\x12'\n" +
+	"\x0finclude_results\x18\x12 \x01(\bR\x0eincludeResults\x12\x12\n" +
+	"\x04mode\x18\x13 \x01(\tR\x04mode\x1aA\n" +
+	"\x13DeepgramParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\t\n" +
+	"\a_bundleB\r\n" +
+	"\v_max_framesB\x0f\n" +
+	"\r_multilingual\"\xa6\x01\n" +
+	"\fStreamResult\x12\x16\n" +
+	"\x06stream\x18\x01 \x01(\tR\x06stream\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12!\n" +
+	"\fresult_count\x18\x03 \x01(\x05R\vresultCount\x12\x15\n" +
+	"\x06r2_key\x18\x04 \x01(\tR\x05r2Key\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12\x16\n" +
+	"\x06result\x18\x06 \x01(\fR\x06result\"\xfc\x01\n" +
+	"\fQualityScore\x12\x14\n" +
+	"\x05score\x18\x01 \x01(\x01R\x05score\x12$\n" +
+	"\x0evlm_error_rate\x18\x02 \x01(\x01R\fvlmErrorRate\x12,\n" +
+	"\x12vlm_blocked_frames\x18\x03 \x01(\x05R\x10vlmBlockedFrames\x12%\n" +
+	"\x0easr_confidence\x18\x04 \x01(\x01R\rasrConfidence\x12'\n" +
+	"\x0fspeech_coverage\x18\x05 \x01(\x01R\x0espeechCoverage\x12\x18\n" +
+	"\aflagged\x18\x06 \x01(\bR\aflagged\x12\x18\n" +
+	"\areasons\x18\a \x03(\tR\areasons\"\xcd\x02\n" +
+	"\x0fExtractResponse\x12\x13\n" +
+	"\x05ad_id\x18\x01 \x01(\tR\x04adId\x12\x18\n" +
+	"\apartial\x18\x02 \x01(\bR\apartial\x123\n" +
+	"\astreams\x18\x03 \x03(\v2\x19.pipeline.v1.StreamResultR\astreams\x123\n" +
+	"\aquality\x18\x04 \x01(\v2\x19.pipeline.v1.QualityScoreR\aquality\x12,\n" +
+	"\x12processing_time_ms\x18\x05 \x01(\x01R\x10processingTimeMs\x12#\n" +
+	"\rgemini_tokens\x18\x06 \x01(\x03R\fgeminiTokens\x12 \n" +
+	"\vquarantined\x18\a \x01(\bR\vquarantined\x12,\n" +
+	"\adry_run\x18\b \x01(\v2\x13.pipeline.v1.DryRunR\x06dryRun\"\xc8\x01\n" +
+	"\x06DryRun\x12\x1f\n" +
+	"\vvideo_bytes\x18\x01 \x01(\x03R\n" +
+	"videoBytes\x12\x1c\n" +
+	"\tkeyframes\x18\x02 \x01(\x05R\tkeyframes\x121\n" +
+	"\x14unreadable_keyframes\x18\x03 \x01(\x05R\x13unreadableKeyframes\x12)\n" +
+	"\x10frames_described\x18\x04 \x01(\x05R\x0fframesDescribed\x12!\n" +
+	"\fgemini_calls\x18\x05 \x01(\x05R\vgeminiCalls\"\x87\x02\n" +
+	"\rProgressEvent\x12\x14\n" +
+	"\x05event\x18\x01 \x01(\tR\x05event\x12\x14\n" +
+	"\x05stage\x18\x02 \x01(\tR\x05stage\x12\x1d\n" +
+	"\n" +
+	"elapsed_ms\x18\x03 \x01(\x03R\telapsedMs\x124\n" +
+	"\x06result\x18\x04 \x01(\v2\x1c.pipeline.v1.ExtractResponseR\x06result\x121\n" +
+	"\x06stream\x18\x05 \x01(\v2\x19.pipeline.v1.StreamResultR\x06stream\x12\x1f\n" +
+	"\vframes_done\x18\x06 \x01(\x05R\n" +
+	"framesDone\x12!\n" +
+	"\fframes_total\x18\a \x01(\x05R\vframesTotal\"&\n" +
+	"\rGetJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\xf2\x02\n" +
+	"\x03Job\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x13\n" +
+	"\x05ad_id\x18\x02 \x01(\tR\x04adId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x14\n" +
+	"\x05stage\x18\x04 \x01(\tR\x05stage\x123\n" +
+	"\astreams\x18\x05 \x03(\v2\x19.pipeline.v1.StreamResultR\astreams\x12\x1a\n" +
+	"\bprogress\x18\x06 \x01(\x01R\bprogress\x124\n" +
+	"\x06result\x18\a \x01(\v2\x1c.pipeline.v1.ExtractResponseR\x06result\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"(\n" +
+	"\x11GetResultsRequest\x12\x13\n" +
+	"\x05ad_id\x18\x01 \x01(\tR\x04adId\"\x9f\x01\n" +
+	"\aResults\x12\x13\n" +
+	"\x05ad_id\x18\x01 \x01(\tR\x04adId\x12A\n" +
+	"\tartifacts\x18\x02 \x03(\v2#.pipeline.v1.Results.ArtifactsEntryR\tartifacts\x1a<\n" +
+	"\x0eArtifactsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x012\xd1\x02\n" +
+	"\bPipeline\x12D\n" +
+	"\aExtract\x12\x1b.pipeline.v1.ExtractRequest\x1a\x1c.pipeline.v1.ExtractResponse\x12J\n" +
+	"\rExtractStream\x12\x1b.pipeline.v1.ExtractRequest\x1a\x1a.pipeline.v1.ProgressEvent0\x01\x127\n" +
+	"\x06Submit\x12\x1b.pipeline.v1.ExtractRequest\x1a\x10.pipeline.v1.Job\x126\n" +
+	"\x06GetJob\x12\x1a.pipeline.v1.GetJobRequest\x1a\x10.pipeline.v1.Job\x12B\n" +
+	"\n" +
+	"GetResults\x12\x1e.pipeline.v1.GetResultsRequest\x1a\x14.pipeline.v1.ResultsB?Z=github.com/nikipaj1/video-description-pipeline/pkg/pipelinepbb\x06proto3"
+
+var (
+	file_pkg_pipelinepb_pipeline_proto_rawDescOnce sync.Once
+	file_pkg_pipelinepb_pipeline_proto_rawDescData []byte
+)
+
+func file_pkg_pipelinepb_pipeline_proto_rawDescGZIP() []byte {
+	file_pkg_pipelinepb_pipeline_proto_rawDescOnce.Do(func() {
+		file_pkg_pipelinepb_pipeline_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_pipelinepb_pipeline_proto_rawDesc), len(file_pkg_pipelinepb_pipeline_proto_rawDesc)))
+	})
+	return file_pkg_pipelinepb_pipeline_proto_rawDescData
+}
+
+var file_pkg_pipelinepb_pipeline_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_pkg_pipelinepb_pipeline_proto_goTypes = []any{
+	(*ExtractRequest)(nil),        // 0: pipeline.v1.ExtractRequest
+	(*StreamResult)(nil),          // 1: pipeline.v1.StreamResult
+	(*QualityScore)(nil),          // 2: pipeline.v1.QualityScore
+	(*ExtractResponse)(nil),       // 3: pipeline.v1.ExtractResponse
+	(*DryRun)(nil),                // 4: pipeline.v1.DryRun
+	(*ProgressEvent)(nil),         // 5: pipeline.v1.ProgressEvent
+	(*GetJobRequest)(nil),         // 6: pipeline.v1.GetJobRequest
+	(*Job)(nil),                   // 7: pipeline.v1.Job
+	(*GetResultsRequest)(nil),     // 8: pipeline.v1.GetResultsRequest
+	(*Results)(nil),               // 9: pipeline.v1.Results
+	nil,                           // 10: pipeline.v1.ExtractRequest.DeepgramParamsEntry
+	nil,                           // 11: pipeline.v1.Results.ArtifactsEntry
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_pkg_pipelinepb_pipeline_proto_depIdxs = []int32{
+	10, // 0: pipeline.v1.ExtractRequest.deepgram_params:type_name -> pipeline.v1.ExtractRequest.DeepgramParamsEntry
+	1,  // 1: pipeline.v1.ExtractResponse.streams:type_name -> pipeline.v1.StreamResult
+	2,  // 2: pipeline.v1.ExtractResponse.quality:type_name -> pipeline.v1.QualityScore
+	4,  // 3: pipeline.v1.ExtractResponse.dry_run:type_name -> pipeline.v1.DryRun
+	3,  // 4: pipeline.v1.ProgressEvent.result:type_name -> pipeline.v1.ExtractResponse
+	1,  // 5: pipeline.v1.ProgressEvent.stream:type_name -> pipeline.v1.StreamResult
+	1,  // 6: pipeline.v1.Job.streams:type_name -> pipeline.v1.StreamResult
+	3,  // 7: pipeline.v1.Job.result:type_name -> pipeline.v1.ExtractResponse
+	12, // 8: pipeline.v1.Job.created_at:type_name -> google.protobuf.Timestamp
+	12, // 9: pipeline.v1.Job.updated_at:type_name -> google.protobuf.Timestamp
+	11, // 10: pipeline.v1.Results.artifacts:type_name -> pipeline.v1.Results.ArtifactsEntry
+	0,  // 11: pipeline.v1.Pipeline.Extract:input_type -> pipeline.v1.ExtractRequest
+	0,  // 12: pipeline.v1.Pipeline.ExtractStream:input_type -> pipeline.v1.ExtractRequest
+	0,  // 13: pipeline.v1.Pipeline.Submit:input_type -> pipeline.v1.ExtractRequest
+	6,  // 14: pipeline.v1.Pipeline.GetJob:input_type -> pipeline.v1.GetJobRequest
+	8,  // 15: pipeline.v1.Pipeline.GetResults:input_type -> pipeline.v1.GetResultsRequest
+	3,  // 16: pipeline.v1.Pipeline.Extract:output_type -> pipeline.v1.ExtractResponse
+	5,  // 17: pipeline.v1.Pipeline.ExtractStream:output_type -> pipeline.v1.ProgressEvent
+	7,  // 18: pipeline.v1.Pipeline.Submit:output_type -> pipeline.v1.Job
+	7,  // 19: pipeline.v1.Pipeline.GetJob:output_type -> pipeline.v1.Job
+	9,  // 20: pipeline.v1.Pipeline.GetResults:output_type -> pipeline.v1.Results
+	16, // [16:21] is the sub-list for method output_type
+	11, // [11:16] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_pkg_pipelinepb_pipeline_proto_init() }
+func file_pkg_pipelinepb_pipeline_proto_init() {
+	if File_pkg_pipelinepb_pipeline_proto != nil {
+		return
+	}
+	file_pkg_pipelinepb_pipeline_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_pipelinepb_pipeline_proto_rawDesc), len(file_pkg_pipelinepb_pipeline_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_pipelinepb_pipeline_proto_goTypes,
+		DependencyIndexes: file_pkg_pipelinepb_pipeline_proto_depIdxs,
+		MessageInfos:      file_pkg_pipelinepb_pipeline_proto_msgTypes,
+	}.Build()
+	File_pkg_pipelinepb_pipeline_proto = out.File
+	file_pkg_pipelinepb_pipeline_proto_goTypes = nil
+	file_pkg_pipelinepb_pipeline_proto_depIdxs = nil
+}
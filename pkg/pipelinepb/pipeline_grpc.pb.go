@@ -0,0 +1,293 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pkg/pipelinepb/pipeline.proto
+
+package pipelinepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pipeline_Extract_FullMethodName       = "/pipeline.v1.Pipeline/Extract"
+	Pipeline_ExtractStream_FullMethodName = "/pipeline.v1.Pipeline/ExtractStream"
+	Pipeline_Submit_FullMethodName        = "/pipeline.v1.Pipeline/Submit"
+	Pipeline_GetJob_FullMethodName        = "/pipeline.v1.Pipeline/GetJob"
+	Pipeline_GetResults_FullMethodName    = "/pipeline.v1.Pipeline/GetResults"
+)
+
+// PipelineClient is the client API for Pipeline service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PipelineClient interface {
+	// Extract runs a job and answers once it has finished, as POST /extract.
+	Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error)
+	// ExtractStream runs a job as Extract does, streaming its progress: stage
+	// changes, streams starting and finishing, and each keyframe described.
+	// The last event carries the result; a failed job ends the call with an
+	// error status instead.
+	ExtractStream(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error)
+	// Submit starts an async job, as POST /extract?async=true.
+	Submit(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetJob reports an async job, as GET /jobs/{id}.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetResults returns an ad's stored results, as GET /results/{ad_id}.
+	GetResults(ctx context.Context, in *GetResultsRequest, opts ...grpc.CallOption) (*Results, error)
+}
+
+type pipelineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPipelineClient(cc grpc.ClientConnInterface) PipelineClient {
+	return &pipelineClient{cc}
+}
+
+func (c *pipelineClient) Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExtractResponse)
+	err := c.cc.Invoke(ctx, Pipeline_Extract_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineClient) ExtractStream(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pipeline_ServiceDesc.Streams[0], Pipeline_ExtractStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExtractRequest, ProgressEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pipeline_ExtractStreamClient = grpc.ServerStreamingClient[ProgressEvent]
+
+func (c *pipelineClient) Submit(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Pipeline_Submit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Pipeline_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pipelineClient) GetResults(ctx context.Context, in *GetResultsRequest, opts ...grpc.CallOption) (*Results, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Results)
+	err := c.cc.Invoke(ctx, Pipeline_GetResults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PipelineServer is the server API for Pipeline service.
+// All implementations must embed UnimplementedPipelineServer
+// for forward compatibility.
+type PipelineServer interface {
+	// Extract runs a job and answers once it has finished, as POST /extract.
+	Extract(context.Context, *ExtractRequest) (*ExtractResponse, error)
+	// ExtractStream runs a job as Extract does, streaming its progress: stage
+	// changes, streams starting and finishing, and each keyframe described.
+	// The last event carries the result; a failed job ends the call with an
+	// error status instead.
+	ExtractStream(*ExtractRequest, grpc.ServerStreamingServer[ProgressEvent]) error
+	// Submit starts an async job, as POST /extract?async=true.
+	Submit(context.Context, *ExtractRequest) (*Job, error)
+	// GetJob reports an async job, as GET /jobs/{id}.
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// GetResults returns an ad's stored results, as GET /results/{ad_id}.
+	GetResults(context.Context, *GetResultsRequest) (*Results, error)
+	mustEmbedUnimplementedPipelineServer()
+}
+
+// UnimplementedPipelineServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPipelineServer struct{}
+
+func (UnimplementedPipelineServer) Extract(context.Context, *ExtractRequest) (*ExtractResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Extract not implemented")
+}
+func (UnimplementedPipelineServer) ExtractStream(*ExtractRequest, grpc.ServerStreamingServer[ProgressEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method ExtractStream not implemented")
+}
+func (UnimplementedPipelineServer) Submit(context.Context, *ExtractRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Submit not implemented")
+}
+func (UnimplementedPipelineServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedPipelineServer) GetResults(context.Context, *GetResultsRequest) (*Results, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResults not implemented")
+}
+func (UnimplementedPipelineServer) mustEmbedUnimplementedPipelineServer() {}
+func (UnimplementedPipelineServer) testEmbeddedByValue()                  {}
+
+// UnsafePipelineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PipelineServer will
+// result in compilation errors.
+type UnsafePipelineServer interface {
+	mustEmbedUnimplementedPipelineServer()
+}
+
+func RegisterPipelineServer(s grpc.ServiceRegistrar, srv PipelineServer) {
+	// If the following call pancis, it indicates UnimplementedPipelineServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pipeline_ServiceDesc, srv)
+}
+
+func _Pipeline_Extract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineServer).Extract(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pipeline_Extract_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineServer).Extract(ctx, req.(*ExtractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pipeline_ExtractStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExtractRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PipelineServer).ExtractStream(m, &grpc.GenericServerStream[ExtractRequest, ProgressEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pipeline_ExtractStreamServer = grpc.ServerStreamingServer[ProgressEvent]
+
+func _Pipeline_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pipeline_Submit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineServer).Submit(ctx, req.(*ExtractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pipeline_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pipeline_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pipeline_GetResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PipelineServer).GetResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pipeline_GetResults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PipelineServer).GetResults(ctx, req.(*GetResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Pipeline_ServiceDesc is the grpc.ServiceDesc for Pipeline service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pipeline_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Pipeline",
+	HandlerType: (*PipelineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Extract",
+			Handler:    _Pipeline_Extract_Handler,
+		},
+		{
+			MethodName: "Submit",
+			Handler:    _Pipeline_Submit_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Pipeline_GetJob_Handler,
+		},
+		{
+			MethodName: "GetResults",
+			Handler:    _Pipeline_GetResults_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExtractStream",
+			Handler:       _Pipeline_ExtractStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/pipelinepb/pipeline.proto",
+}